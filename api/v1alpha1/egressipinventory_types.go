@@ -0,0 +1,114 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// EgressIPInventorySpec defines the desired state of EgressIPInventory
+type EgressIPInventorySpec struct {
+	// Webhooks notified of egress address changes before the inventory lists an added address or drops a removed
+	// one.
+	// +optional
+	Webhooks []EgressIPInventoryWebhook `json:"webhooks,omitempty"`
+
+	// Number of change records kept in status.
+	//+kubebuilder:default=100
+	//+kubebuilder:validation:Minimum=1
+	// +optional
+	HistoryLimit int32 `json:"historyLimit,omitempty"`
+}
+
+// EgressIPInventoryWebhook is an endpoint notified of egress address changes.
+type EgressIPInventoryWebhook struct {
+	// URL the change notifications are posted to.
+	//+kubebuilder:validation:Pattern=`^https?://`
+	URL string `json:"url"`
+
+	// Reference to the secret holding the HMAC-SHA256 key the notifications are signed with, in key "key". The
+	// notifications are not signed if unset.
+	// +optional
+	SecretRef *EgressIPInventorySecretReference `json:"secretRef,omitempty"`
+}
+
+// EgressIPInventorySecretReference references a secret by namespace and name.
+type EgressIPInventorySecretReference struct {
+	// Namespace of the secret.
+	Namespace string `json:"namespace"`
+
+	// Name of the secret.
+	Name string `json:"name"`
+}
+
+// EgressIPInventoryStatus defines the observed state of EgressIPInventory
+type EgressIPInventoryStatus struct {
+	// Egress addresses of every StaticGatewayConfiguration.
+	Gateways []GatewayEgressAddresses `json:"gateways,omitempty"`
+
+	// Sorted union of the egress addresses of all gateways.
+	Addresses []string `json:"addresses,omitempty"`
+
+	// Egress address changes, latest first.
+	History []EgressIPChange `json:"history,omitempty"`
+
+	// Time the inventory was last updated.
+	LastUpdateTime *metav1.Time `json:"lastUpdateTime,omitempty"`
+}
+
+// GatewayEgressAddresses lists the egress addresses of a StaticGatewayConfiguration.
+type GatewayEgressAddresses struct {
+	// Namespace/name of the StaticGatewayConfiguration.
+	Gateway string `json:"gateway"`
+
+	// Public IP prefix, or the private IPs of the gateway nodes if public IPs are not provisioned.
+	Addresses []string `json:"addresses,omitempty"`
+
+	// Whether the addresses are private IPs.
+	Private bool `json:"private,omitempty"`
+}
+
+// EgressIPChange records the egress addresses added to or removed from a gateway.
+type EgressIPChange struct {
+	// Time of the change.
+	Time metav1.Time `json:"time"`
+
+	// Namespace/name of the StaticGatewayConfiguration.
+	Gateway string `json:"gateway"`
+
+	// Addresses added.
+	Added []string `json:"added,omitempty"`
+
+	// Addresses removed.
+	Removed []string `json:"removed,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:scope=Cluster
+//+kubebuilder:printcolumn:name="Addresses",type=string,JSONPath=`.status.addresses`
+//+kubebuilder:printcolumn:name="Updated",type=date,JSONPath=`.status.lastUpdateTime`
+
+// EgressIPInventory is the Schema for the egressipinventories API, a read-only cluster-wide view of the egress
+// addresses of all gateways
+type EgressIPInventory struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   EgressIPInventorySpec   `json:"spec,omitempty"`
+	Status EgressIPInventoryStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// EgressIPInventoryList contains a list of EgressIPInventory
+type EgressIPInventoryList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []EgressIPInventory `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&EgressIPInventory{}, &EgressIPInventoryList{})
+}
@@ -0,0 +1,115 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// EgressIPReclaimPolicy defines what happens to a claimed public IP prefix when the claiming gateway is deleted.
+// +kubebuilder:validation:Enum=Retain;Delete
+type EgressIPReclaimPolicy string
+
+const (
+	// EgressIPReclaimRetain keeps the prefix reserved for the namespace/name of the gateway, so that a recreated
+	// gateway egresses from the same addresses.
+	EgressIPReclaimRetain EgressIPReclaimPolicy = "Retain"
+
+	// EgressIPReclaimDelete deletes the claim, returning the prefix to the pool.
+	EgressIPReclaimDelete EgressIPReclaimPolicy = "Delete"
+)
+
+// EgressIPPoolClaim claims a public IP prefix of an EgressIPPool as the egress prefix of a gateway.
+type EgressIPPoolClaim struct {
+	// Name of the EgressIPPool.
+	PoolName string `json:"poolName"`
+
+	// What happens to the claimed prefix when the gateway is deleted, Retain (default) or Delete.
+	//+kubebuilder:default=Retain
+	// +optional
+	ReclaimPolicy EgressIPReclaimPolicy `json:"reclaimPolicy,omitempty"`
+}
+
+// EgressIPPoolPrefix is a public IP prefix owned by the pool.
+type EgressIPPoolPrefix struct {
+	// Name of the prefix, unique within the pool.
+	//+kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	//+kubebuilder:validation:MaxLength=24
+	Name string `json:"name"`
+
+	// BYO Resource ID of public IP prefix. A public IP prefix managed by the pool is created if empty.
+	// +optional
+	PublicIpPrefixId string `json:"publicIpPrefixId,omitempty"`
+
+	// Length of the managed public IP prefix, which must match the public IP prefix size of the gateways claiming it.
+	// Ignored for BYO public IP prefixes.
+	//+kubebuilder:validation:Minimum=0
+	//+kubebuilder:validation:Maximum=31
+	// +optional
+	PrefixLength int32 `json:"prefixLength,omitempty"`
+}
+
+// EgressIPPoolSpec defines the desired state of EgressIPPool
+type EgressIPPoolSpec struct {
+	// Public IP prefixes of the pool.
+	//+kubebuilder:validation:MinItems=1
+	Prefixes []EgressIPPoolPrefix `json:"prefixes"`
+}
+
+// EgressIPPoolPrefixStatus provides details about a public IP prefix of the pool and its claim.
+type EgressIPPoolPrefixStatus struct {
+	// Name of the prefix in the pool.
+	Name string `json:"name"`
+
+	// Resource ID of the public IP prefix.
+	PublicIpPrefixId string `json:"publicIpPrefixId,omitempty"`
+
+	// IP prefix CIDR.
+	IpPrefix string `json:"ipPrefix,omitempty"`
+
+	// Length of the public IP prefix.
+	PrefixLength int32 `json:"prefixLength,omitempty"`
+
+	// Whether the public IP prefix is created and deleted by the pool.
+	Managed bool `json:"managed,omitempty"`
+
+	// StaticGatewayConfiguration in <namespace>/<name> pattern the prefix is claimed by, empty if free.
+	ClaimedBy string `json:"claimedBy,omitempty"`
+
+	// Reclaim policy of the claim.
+	ReclaimPolicy EgressIPReclaimPolicy `json:"reclaimPolicy,omitempty"`
+}
+
+// EgressIPPoolStatus defines the observed state of EgressIPPool
+type EgressIPPoolStatus struct {
+	// Public IP prefixes of the pool and their claims.
+	Prefixes []EgressIPPoolPrefixStatus `json:"prefixes,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:scope=Cluster
+
+// EgressIPPool is the Schema for the egressippools API, public IP prefixes claimed by StaticGatewayConfigurations
+// whose lifetime is independent of the gateways
+type EgressIPPool struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   EgressIPPoolSpec   `json:"spec,omitempty"`
+	Status EgressIPPoolStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// EgressIPPoolList contains a list of EgressIPPool
+type EgressIPPoolList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []EgressIPPool `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&EgressIPPool{}, &EgressIPPoolList{})
+}
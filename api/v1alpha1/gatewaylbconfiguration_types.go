@@ -30,6 +30,22 @@ type GatewayLBConfigurationSpec struct {
 	// BYO Resource ID of public IP prefix to be used as outbound.
 	// +optional
 	PublicIpPrefixId string `json:"publicIpPrefixId,omitempty"`
+
+	// Claim of a public IP prefix of an EgressIPPool to be used as outbound.
+	// +optional
+	EgressIpPoolClaim *EgressIPPoolClaim `json:"egressIpPoolClaim,omitempty"`
+
+	// Number of sNAT IPs per gateway VM.
+	// +optional
+	SnatIpCount int32 `json:"snatIpCount,omitempty"`
+
+	// Names of the dedicated egress IPs, each one a secondary IP configuration of every gateway VM.
+	// +optional
+	DedicatedEgressIps []string `json:"dedicatedEgressIps,omitempty"`
+
+	// Whether to create one frontend and backend pool per availability zone.
+	// +optional
+	ZoneAware bool `json:"zoneAware,omitempty"`
 }
 
 // GatewayLBConfigurationStatus defines the observed state of GatewayLBConfiguration
@@ -45,6 +61,12 @@ type GatewayLBConfigurationStatus struct {
 
 	// Egress IP Prefix CIDR used for this gateway configuration.
 	EgressIpPrefix string `json:"egressIpPrefix,omitempty"`
+
+	// Per-zone gateway frontends, only populated when zoneAware is enabled.
+	ZonalFrontends []ZonalFrontend `json:"zonalFrontends,omitempty"`
+
+	// Private IPs of the dedicated egress IPs.
+	DedicatedEgressIps []DedicatedEgressIpStatus `json:"dedicatedEgressIps,omitempty"`
 }
 
 //+kubebuilder:object:root=true
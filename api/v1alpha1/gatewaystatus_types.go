@@ -15,6 +15,50 @@ type GatewayConfiguration struct {
 	StaticGatewayConfiguration string `json:"staticGatewayConfiguration,omitempty"`
 	// Network interface name
 	InterfaceName string `json:"interfaceName,omitempty"`
+	// Drain status of the gateway on this node, empty if the gateway is not draining
+	Drain *GatewayDrainStatus `json:"drain,omitempty"`
+	// Error of the last reconcile of the gateway on this node, empty if it succeeded
+	LastError string `json:"lastError,omitempty"`
+	// Time of the last reconcile of the gateway on this node
+	LastReconcileTime *metav1.Time `json:"lastReconcileTime,omitempty"`
+	// Blocks of sNAT source ports given to the pods using the gateway on this node
+	SnatPortBlocks []SnatPortBlock `json:"snatPortBlocks,omitempty"`
+}
+
+type SnatPortBlock struct {
+	// PodEndpoint in <namespace>/<name> pattern
+	PodEndpoint string `json:"podEndpoint,omitempty"`
+	// sNAT IP of the connections of the pod
+	Ip string `json:"ip,omitempty"`
+	// First sNAT source port of the block
+	PortStart int32 `json:"portStart,omitempty"`
+	// Last sNAT source port of the block
+	PortEnd int32 `json:"portEnd,omitempty"`
+}
+
+// GatewayDrainPhase is the phase of draining a gateway on a node
+// +kubebuilder:validation:Enum=Draining;Drained
+type GatewayDrainPhase string
+
+const (
+	// GatewayDrainPhaseDraining means the gateway is reported unhealthy to the load balancer
+	// and existing connections are still going through it
+	GatewayDrainPhaseDraining GatewayDrainPhase = "Draining"
+	// GatewayDrainPhaseDrained means the gateway can be taken down without disrupting traffic
+	GatewayDrainPhaseDrained GatewayDrainPhase = "Drained"
+)
+
+type GatewayDrainStatus struct {
+	// Phase of the drain
+	Phase GatewayDrainPhase `json:"phase,omitempty"`
+	// Time the gateway started draining
+	StartTime metav1.Time `json:"startTime,omitempty"`
+	// Time the drain completed
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+	// Number of connections tracked through the gateway at the last check
+	ActiveConnections int32 `json:"activeConnections,omitempty"`
+	// Whether the drain completed because of timeout while connections were still active
+	TimedOut bool `json:"timedOut,omitempty"`
 }
 
 type PeerConfiguration struct {
@@ -35,6 +79,8 @@ type GatewayStatusSpec struct {
 	ReadyGatewayConfigurations []GatewayConfiguration `json:"readyGatewayConfigurations,omitempty"`
 	// List of ready peer configurations
 	ReadyPeerConfigurations []PeerConfiguration `json:"readyPeerConfigurations,omitempty"`
+	// Availability zone of the gateway node
+	Zone string `json:"zone,omitempty"`
 }
 
 // GatewayStatusStatus defines the observed state of GatewayStatus
@@ -30,6 +30,22 @@ type GatewayVMConfigurationSpec struct {
 	// BYO Resource ID of public IP prefix to be used as outbound.
 	// +optional
 	PublicIpPrefixId string `json:"publicIpPrefixId,omitempty"`
+
+	// Claim of a public IP prefix of an EgressIPPool to be used as outbound.
+	// +optional
+	EgressIpPoolClaim *EgressIPPoolClaim `json:"egressIpPoolClaim,omitempty"`
+
+	// Number of sNAT IPs per gateway VM.
+	// +optional
+	SnatIpCount int32 `json:"snatIpCount,omitempty"`
+
+	// Names of the dedicated egress IPs, each one a secondary IP configuration of every gateway VM.
+	// +optional
+	DedicatedEgressIps []string `json:"dedicatedEgressIps,omitempty"`
+
+	// Whether to add gateway VMs to the backend pool of their availability zone.
+	// +optional
+	ZoneAware bool `json:"zoneAware,omitempty"`
 }
 
 // GatewayVMConfigurationStatus defines the observed state of GatewayVMConfiguration
@@ -42,6 +58,9 @@ type GatewayVMConfigurationStatus struct {
 
 	// Gateway VM profile
 	GatewayVMProfiles []GatewayVMProfile `json:"gatewayVMProfiles,omitempty"`
+
+	// Private and public IPs of the dedicated egress IPs on all gateway VMs.
+	DedicatedEgressIps []DedicatedEgressIpStatus `json:"dedicatedEgressIps,omitempty"`
 }
 
 // GatewayVMProfile provides details about gateway VM side configuration.
@@ -49,6 +68,17 @@ type GatewayVMProfile struct {
 	NodeName    string `json:"nodeName,omitempty"`
 	PrimaryIP   string `json:"primaryIP,omitempty"`
 	SecondaryIP string `json:"secondaryIP,omitempty"`
+	// All sNAT IPs of the VM when the gateway has more than one, SecondaryIP being the first.
+	SecondaryIPs []string `json:"secondaryIPs,omitempty"`
+	// Private IPs of the dedicated egress IPs on the VM.
+	DedicatedIPs []GatewayVMDedicatedIP `json:"dedicatedIPs,omitempty"`
+}
+
+// GatewayVMDedicatedIP is the private IP, and public IP if any, of a dedicated egress IP on a gateway VM.
+type GatewayVMDedicatedIP struct {
+	Name     string `json:"name"`
+	IP       string `json:"ip,omitempty"`
+	PublicIP string `json:"publicIP,omitempty"`
 }
 
 //+kubebuilder:object:root=true
@@ -23,6 +23,27 @@ type PodEndpointSpec struct {
 
 	// public key on pod side.
 	PodPublicKey string `json:"podPublicKey,omitempty"`
+
+	// Name of the dedicated egress IP of the StaticGatewayConfiguration the pod uses, if any.
+	// +optional
+	DedicatedEgressIp string `json:"dedicatedEgressIp,omitempty"`
+
+	// Name of the node the pod runs on.
+	// +optional
+	NodeName string `json:"nodeName,omitempty"`
+
+	// UID of the pod.
+	// +optional
+	PodUid string `json:"podUid,omitempty"`
+
+	// ID of the pod sandbox container the wireguard interface is attached to.
+	// +optional
+	ContainerId string `json:"containerId,omitempty"`
+
+	// Path of the pod network namespace on the node, used to update the wireguard interface of the
+	// running pod when the gateway changes.
+	// +optional
+	NetnsPath string `json:"netnsPath,omitempty"`
 }
 
 // PodEndpointStatus defines the observed state of PodEndpoint
@@ -37,6 +37,19 @@ const (
 	RouteAzureNetworking RouteType = "azureNetworking"
 )
 
+// DedicatedEgressIp is an egress IP used only by some pods of the gateway.
+type DedicatedEgressIp struct {
+	// Name of the dedicated egress IP. Pods select it with the kubernetes.azure.com/dedicated-egress-ip annotation.
+	// +kubebuilder:validation:Pattern=`^[a-z]([-a-z0-9]*[a-z0-9])?$`
+	// +kubebuilder:validation:MaxLength=20
+	Name string `json:"name"`
+
+	// Whether the pods of the namespace using the gateway without selecting a dedicated egress IP use this one
+	// instead of the shared sNAT IPs. At most one dedicated egress IP can be the namespace default.
+	// +optional
+	NamespaceDefault bool `json:"namespaceDefault,omitempty"`
+}
+
 // StaticGatewayConfigurationSpec defines the desired state of StaticGatewayConfiguration
 type StaticGatewayConfigurationSpec struct {
 	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
@@ -62,8 +75,55 @@ type StaticGatewayConfigurationSpec struct {
 	// +optional
 	PublicIpPrefixId string `json:"publicIpPrefixId,omitempty"`
 
+	// Claim of a public IP prefix of an EgressIPPool to be used as outbound, so that the egress IPs outlive the
+	// gateway. This can only be specified when provisionPublicIps is true and publicIpPrefixId is empty.
+	// +optional
+	EgressIpPoolClaim *EgressIPPoolClaim `json:"egressIpPoolClaim,omitempty"`
+
+	// Number of sNAT IPs per gateway VM, each one a secondary IP configuration of the VM NIC with its own
+	// public IP when provisionPublicIps is true. Outbound connections are spread across the sNAT IPs so that
+	// busy gateways do not run out of sNAT ports. Defaults to 1.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=16
+	// +optional
+	SnatIpCount int32 `json:"snatIpCount,omitempty"`
+
+	// Egress IPs dedicated to some pods of the gateway, each one a secondary IP configuration of every gateway
+	// VM NIC with its own public IP when provisionPublicIps is true.
+	// +kubebuilder:validation:MaxItems=8
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	DedicatedEgressIps []DedicatedEgressIp `json:"dedicatedEgressIps,omitempty"`
+
+	// Size of the block of sNAT source ports given to each pod using the gateway on a gateway node, so that
+	// outbound connections can be attributed to a pod from the sNAT IP and source port alone. The blocks are
+	// recorded in the GatewayStatus of the node. The size is halved, down to 64, until every pod gets a block.
+	// Disabled when 0.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=32768
+	// +optional
+	SnatPortBlockSize int32 `json:"snatPortBlockSize,omitempty"`
+
 	// CIDRs to be excluded from the default route.
 	ExcludeCidrs []string `json:"excludeCidrs,omitempty"`
+
+	// Whether to create one gateway frontend and backend pool per availability zone of the gateway VMs,
+	// so that pods are served by gateways in their own zone when possible.
+	// +optional
+	ZoneAware bool `json:"zoneAware,omitempty"`
+
+	// MTU of the wireguard interfaces of the gateway and of the pods using it.
+	// Defaults to the MTU of the node's eth0 minus the wireguard overhead.
+	// +kubebuilder:validation:Minimum=1280
+	// +kubebuilder:validation:Maximum=9000
+	// +optional
+	Mtu int32 `json:"mtu,omitempty"`
+
+	// Whether to clamp the TCP MSS of connections through the gateway to the path MTU, so that
+	// endpoints blocking ICMP do not send segments too large for the wireguard tunnel.
+	// +optional
+	TcpMssClamping bool `json:"tcpMssClamping,omitempty"`
 }
 
 // GatewayProfile provides details about gateway side configuration.
@@ -81,6 +141,32 @@ type GatewayServerProfile struct {
 	PrivateKeySecretRef *corev1.ObjectReference `json:"privateKeySecretRef,omitempty"`
 }
 
+// ZonalFrontend provides details about the gateway frontend serving one availability zone.
+type ZonalFrontend struct {
+	// Availability zone of the gateway VMs behind this frontend.
+	Zone string `json:"zone"`
+
+	// Gateway frontend IP for this zone.
+	Ip string `json:"ip"`
+}
+
+// DedicatedEgressIpStatus shows the egress IPs and the pods of a dedicated egress IP.
+type DedicatedEgressIpStatus struct {
+	// Name of the dedicated egress IP.
+	Name string `json:"name"`
+
+	// Private IPs of the dedicated egress IP on the gateway VMs, each one mapped to its own public IP
+	// when provisionPublicIps is true.
+	PrivateIps []string `json:"privateIps,omitempty"`
+
+	// Public IPs of the dedicated egress IP on the gateway VMs, the source IPs seen by external services
+	// when provisionPublicIps is true.
+	PublicIps []string `json:"publicIps,omitempty"`
+
+	// Pods using the dedicated egress IP.
+	Pods []string `json:"pods,omitempty"`
+}
+
 // StaticGatewayConfigurationStatus defines the observed state of StaticGatewayConfiguration
 type StaticGatewayConfigurationStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
@@ -91,6 +177,12 @@ type StaticGatewayConfigurationStatus struct {
 
 	// Gateway server profile.
 	GatewayServerProfile `json:"gatewayServerProfile,omitempty"`
+
+	// Per-zone gateway frontends, only populated when zoneAware is enabled.
+	ZonalFrontends []ZonalFrontend `json:"zonalFrontends,omitempty"`
+
+	// Dedicated egress IPs and the pods using them.
+	DedicatedEgressIps []DedicatedEgressIpStatus `json:"dedicatedEgressIps,omitempty"`
 }
 
 //+kubebuilder:object:root=true
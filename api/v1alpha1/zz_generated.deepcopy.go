@@ -14,9 +14,392 @@ import (
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DedicatedEgressIp) DeepCopyInto(out *DedicatedEgressIp) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DedicatedEgressIp.
+func (in *DedicatedEgressIp) DeepCopy() *DedicatedEgressIp {
+	if in == nil {
+		return nil
+	}
+	out := new(DedicatedEgressIp)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DedicatedEgressIpStatus) DeepCopyInto(out *DedicatedEgressIpStatus) {
+	*out = *in
+	if in.PrivateIps != nil {
+		in, out := &in.PrivateIps, &out.PrivateIps
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.PublicIps != nil {
+		in, out := &in.PublicIps, &out.PublicIps
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Pods != nil {
+		in, out := &in.Pods, &out.Pods
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DedicatedEgressIpStatus.
+func (in *DedicatedEgressIpStatus) DeepCopy() *DedicatedEgressIpStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(DedicatedEgressIpStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EgressIPChange) DeepCopyInto(out *EgressIPChange) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+	if in.Added != nil {
+		in, out := &in.Added, &out.Added
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Removed != nil {
+		in, out := &in.Removed, &out.Removed
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EgressIPChange.
+func (in *EgressIPChange) DeepCopy() *EgressIPChange {
+	if in == nil {
+		return nil
+	}
+	out := new(EgressIPChange)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EgressIPInventory) DeepCopyInto(out *EgressIPInventory) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EgressIPInventory.
+func (in *EgressIPInventory) DeepCopy() *EgressIPInventory {
+	if in == nil {
+		return nil
+	}
+	out := new(EgressIPInventory)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *EgressIPInventory) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EgressIPInventoryList) DeepCopyInto(out *EgressIPInventoryList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]EgressIPInventory, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EgressIPInventoryList.
+func (in *EgressIPInventoryList) DeepCopy() *EgressIPInventoryList {
+	if in == nil {
+		return nil
+	}
+	out := new(EgressIPInventoryList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *EgressIPInventoryList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EgressIPInventorySecretReference) DeepCopyInto(out *EgressIPInventorySecretReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EgressIPInventorySecretReference.
+func (in *EgressIPInventorySecretReference) DeepCopy() *EgressIPInventorySecretReference {
+	if in == nil {
+		return nil
+	}
+	out := new(EgressIPInventorySecretReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EgressIPInventorySpec) DeepCopyInto(out *EgressIPInventorySpec) {
+	*out = *in
+	if in.Webhooks != nil {
+		in, out := &in.Webhooks, &out.Webhooks
+		*out = make([]EgressIPInventoryWebhook, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EgressIPInventorySpec.
+func (in *EgressIPInventorySpec) DeepCopy() *EgressIPInventorySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(EgressIPInventorySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EgressIPInventoryStatus) DeepCopyInto(out *EgressIPInventoryStatus) {
+	*out = *in
+	if in.Gateways != nil {
+		in, out := &in.Gateways, &out.Gateways
+		*out = make([]GatewayEgressAddresses, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Addresses != nil {
+		in, out := &in.Addresses, &out.Addresses
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.History != nil {
+		in, out := &in.History, &out.History
+		*out = make([]EgressIPChange, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.LastUpdateTime != nil {
+		in, out := &in.LastUpdateTime, &out.LastUpdateTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EgressIPInventoryStatus.
+func (in *EgressIPInventoryStatus) DeepCopy() *EgressIPInventoryStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(EgressIPInventoryStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EgressIPInventoryWebhook) DeepCopyInto(out *EgressIPInventoryWebhook) {
+	*out = *in
+	if in.SecretRef != nil {
+		in, out := &in.SecretRef, &out.SecretRef
+		*out = new(EgressIPInventorySecretReference)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EgressIPInventoryWebhook.
+func (in *EgressIPInventoryWebhook) DeepCopy() *EgressIPInventoryWebhook {
+	if in == nil {
+		return nil
+	}
+	out := new(EgressIPInventoryWebhook)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EgressIPPool) DeepCopyInto(out *EgressIPPool) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EgressIPPool.
+func (in *EgressIPPool) DeepCopy() *EgressIPPool {
+	if in == nil {
+		return nil
+	}
+	out := new(EgressIPPool)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *EgressIPPool) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EgressIPPoolClaim) DeepCopyInto(out *EgressIPPoolClaim) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EgressIPPoolClaim.
+func (in *EgressIPPoolClaim) DeepCopy() *EgressIPPoolClaim {
+	if in == nil {
+		return nil
+	}
+	out := new(EgressIPPoolClaim)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EgressIPPoolList) DeepCopyInto(out *EgressIPPoolList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]EgressIPPool, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EgressIPPoolList.
+func (in *EgressIPPoolList) DeepCopy() *EgressIPPoolList {
+	if in == nil {
+		return nil
+	}
+	out := new(EgressIPPoolList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *EgressIPPoolList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EgressIPPoolPrefix) DeepCopyInto(out *EgressIPPoolPrefix) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EgressIPPoolPrefix.
+func (in *EgressIPPoolPrefix) DeepCopy() *EgressIPPoolPrefix {
+	if in == nil {
+		return nil
+	}
+	out := new(EgressIPPoolPrefix)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EgressIPPoolPrefixStatus) DeepCopyInto(out *EgressIPPoolPrefixStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EgressIPPoolPrefixStatus.
+func (in *EgressIPPoolPrefixStatus) DeepCopy() *EgressIPPoolPrefixStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(EgressIPPoolPrefixStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EgressIPPoolSpec) DeepCopyInto(out *EgressIPPoolSpec) {
+	*out = *in
+	if in.Prefixes != nil {
+		in, out := &in.Prefixes, &out.Prefixes
+		*out = make([]EgressIPPoolPrefix, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EgressIPPoolSpec.
+func (in *EgressIPPoolSpec) DeepCopy() *EgressIPPoolSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(EgressIPPoolSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EgressIPPoolStatus) DeepCopyInto(out *EgressIPPoolStatus) {
+	*out = *in
+	if in.Prefixes != nil {
+		in, out := &in.Prefixes, &out.Prefixes
+		*out = make([]EgressIPPoolPrefixStatus, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EgressIPPoolStatus.
+func (in *EgressIPPoolStatus) DeepCopy() *EgressIPPoolStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(EgressIPPoolStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GatewayConfiguration) DeepCopyInto(out *GatewayConfiguration) {
 	*out = *in
+	if in.Drain != nil {
+		in, out := &in.Drain, &out.Drain
+		*out = new(GatewayDrainStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.LastReconcileTime != nil {
+		in, out := &in.LastReconcileTime, &out.LastReconcileTime
+		*out = (*in).DeepCopy()
+	}
+	if in.SnatPortBlocks != nil {
+		in, out := &in.SnatPortBlocks, &out.SnatPortBlocks
+		*out = make([]SnatPortBlock, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GatewayConfiguration.
@@ -29,16 +412,56 @@ func (in *GatewayConfiguration) DeepCopy() *GatewayConfiguration {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GatewayDrainStatus) DeepCopyInto(out *GatewayDrainStatus) {
+	*out = *in
+	in.StartTime.DeepCopyInto(&out.StartTime)
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GatewayDrainStatus.
+func (in *GatewayDrainStatus) DeepCopy() *GatewayDrainStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(GatewayDrainStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GatewayEgressAddresses) DeepCopyInto(out *GatewayEgressAddresses) {
+	*out = *in
+	if in.Addresses != nil {
+		in, out := &in.Addresses, &out.Addresses
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GatewayEgressAddresses.
+func (in *GatewayEgressAddresses) DeepCopy() *GatewayEgressAddresses {
+	if in == nil {
+		return nil
+	}
+	out := new(GatewayEgressAddresses)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GatewayLBConfiguration) DeepCopyInto(out *GatewayLBConfiguration) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
+	in.Spec.DeepCopyInto(&out.Spec)
 	if in.Status != nil {
 		in, out := &in.Status, &out.Status
 		*out = new(GatewayLBConfigurationStatus)
-		**out = **in
+		(*in).DeepCopyInto(*out)
 	}
 }
 
@@ -96,6 +519,16 @@ func (in *GatewayLBConfigurationList) DeepCopyObject() runtime.Object {
 func (in *GatewayLBConfigurationSpec) DeepCopyInto(out *GatewayLBConfigurationSpec) {
 	*out = *in
 	out.GatewayVmssProfile = in.GatewayVmssProfile
+	if in.EgressIpPoolClaim != nil {
+		in, out := &in.EgressIpPoolClaim, &out.EgressIpPoolClaim
+		*out = new(EgressIPPoolClaim)
+		**out = **in
+	}
+	if in.DedicatedEgressIps != nil {
+		in, out := &in.DedicatedEgressIps, &out.DedicatedEgressIps
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GatewayLBConfigurationSpec.
@@ -111,6 +544,18 @@ func (in *GatewayLBConfigurationSpec) DeepCopy() *GatewayLBConfigurationSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GatewayLBConfigurationStatus) DeepCopyInto(out *GatewayLBConfigurationStatus) {
 	*out = *in
+	if in.ZonalFrontends != nil {
+		in, out := &in.ZonalFrontends, &out.ZonalFrontends
+		*out = make([]ZonalFrontend, len(*in))
+		copy(*out, *in)
+	}
+	if in.DedicatedEgressIps != nil {
+		in, out := &in.DedicatedEgressIps, &out.DedicatedEgressIps
+		*out = make([]DedicatedEgressIpStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GatewayLBConfigurationStatus.
@@ -208,7 +653,9 @@ func (in *GatewayStatusSpec) DeepCopyInto(out *GatewayStatusSpec) {
 	if in.ReadyGatewayConfigurations != nil {
 		in, out := &in.ReadyGatewayConfigurations, &out.ReadyGatewayConfigurations
 		*out = make([]GatewayConfiguration, len(*in))
-		copy(*out, *in)
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
 	if in.ReadyPeerConfigurations != nil {
 		in, out := &in.ReadyPeerConfigurations, &out.ReadyPeerConfigurations
@@ -247,7 +694,7 @@ func (in *GatewayVMConfiguration) DeepCopyInto(out *GatewayVMConfiguration) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
+	in.Spec.DeepCopyInto(&out.Spec)
 	if in.Status != nil {
 		in, out := &in.Status, &out.Status
 		*out = new(GatewayVMConfigurationStatus)
@@ -309,6 +756,16 @@ func (in *GatewayVMConfigurationList) DeepCopyObject() runtime.Object {
 func (in *GatewayVMConfigurationSpec) DeepCopyInto(out *GatewayVMConfigurationSpec) {
 	*out = *in
 	out.GatewayVmssProfile = in.GatewayVmssProfile
+	if in.EgressIpPoolClaim != nil {
+		in, out := &in.EgressIpPoolClaim, &out.EgressIpPoolClaim
+		*out = new(EgressIPPoolClaim)
+		**out = **in
+	}
+	if in.DedicatedEgressIps != nil {
+		in, out := &in.DedicatedEgressIps, &out.DedicatedEgressIps
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GatewayVMConfigurationSpec.
@@ -327,7 +784,16 @@ func (in *GatewayVMConfigurationStatus) DeepCopyInto(out *GatewayVMConfiguration
 	if in.GatewayVMProfiles != nil {
 		in, out := &in.GatewayVMProfiles, &out.GatewayVMProfiles
 		*out = make([]GatewayVMProfile, len(*in))
-		copy(*out, *in)
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.DedicatedEgressIps != nil {
+		in, out := &in.DedicatedEgressIps, &out.DedicatedEgressIps
+		*out = make([]DedicatedEgressIpStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
 }
 
@@ -341,9 +807,34 @@ func (in *GatewayVMConfigurationStatus) DeepCopy() *GatewayVMConfigurationStatus
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GatewayVMDedicatedIP) DeepCopyInto(out *GatewayVMDedicatedIP) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GatewayVMDedicatedIP.
+func (in *GatewayVMDedicatedIP) DeepCopy() *GatewayVMDedicatedIP {
+	if in == nil {
+		return nil
+	}
+	out := new(GatewayVMDedicatedIP)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GatewayVMProfile) DeepCopyInto(out *GatewayVMProfile) {
 	*out = *in
+	if in.SecondaryIPs != nil {
+		in, out := &in.SecondaryIPs, &out.SecondaryIPs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.DedicatedIPs != nil {
+		in, out := &in.DedicatedIPs, &out.DedicatedIPs
+		*out = make([]GatewayVMDedicatedIP, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GatewayVMProfile.
@@ -475,6 +966,21 @@ func (in *PodEndpointStatus) DeepCopy() *PodEndpointStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SnatPortBlock) DeepCopyInto(out *SnatPortBlock) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SnatPortBlock.
+func (in *SnatPortBlock) DeepCopy() *SnatPortBlock {
+	if in == nil {
+		return nil
+	}
+	out := new(SnatPortBlock)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *StaticGatewayConfiguration) DeepCopyInto(out *StaticGatewayConfiguration) {
 	*out = *in
@@ -538,6 +1044,16 @@ func (in *StaticGatewayConfigurationList) DeepCopyObject() runtime.Object {
 func (in *StaticGatewayConfigurationSpec) DeepCopyInto(out *StaticGatewayConfigurationSpec) {
 	*out = *in
 	out.GatewayVmssProfile = in.GatewayVmssProfile
+	if in.EgressIpPoolClaim != nil {
+		in, out := &in.EgressIpPoolClaim, &out.EgressIpPoolClaim
+		*out = new(EgressIPPoolClaim)
+		**out = **in
+	}
+	if in.DedicatedEgressIps != nil {
+		in, out := &in.DedicatedEgressIps, &out.DedicatedEgressIps
+		*out = make([]DedicatedEgressIp, len(*in))
+		copy(*out, *in)
+	}
 	if in.ExcludeCidrs != nil {
 		in, out := &in.ExcludeCidrs, &out.ExcludeCidrs
 		*out = make([]string, len(*in))
@@ -559,6 +1075,18 @@ func (in *StaticGatewayConfigurationSpec) DeepCopy() *StaticGatewayConfiguration
 func (in *StaticGatewayConfigurationStatus) DeepCopyInto(out *StaticGatewayConfigurationStatus) {
 	*out = *in
 	in.GatewayServerProfile.DeepCopyInto(&out.GatewayServerProfile)
+	if in.ZonalFrontends != nil {
+		in, out := &in.ZonalFrontends, &out.ZonalFrontends
+		*out = make([]ZonalFrontend, len(*in))
+		copy(*out, *in)
+	}
+	if in.DedicatedEgressIps != nil {
+		in, out := &in.DedicatedEgressIps, &out.DedicatedEgressIps
+		*out = make([]DedicatedEgressIpStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StaticGatewayConfigurationStatus.
@@ -570,3 +1098,18 @@ func (in *StaticGatewayConfigurationStatus) DeepCopy() *StaticGatewayConfigurati
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ZonalFrontend) DeepCopyInto(out *ZonalFrontend) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ZonalFrontend.
+func (in *ZonalFrontend) DeepCopy() *ZonalFrontend {
+	if in == nil {
+		return nil
+	}
+	out := new(ZonalFrontend)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -5,18 +5,15 @@ package main
 
 import (
 	"errors"
-	"net"
 
 	"github.com/containernetworking/cni/pkg/skel"
 	"github.com/containernetworking/cni/pkg/types"
 	type100 "github.com/containernetworking/cni/pkg/types/100"
 	"github.com/containernetworking/cni/pkg/version"
-	"github.com/containernetworking/plugins/pkg/ns"
 	bv "github.com/containernetworking/plugins/pkg/utils/buildversion"
-	"github.com/vishvananda/netlink"
-	"golang.org/x/sys/unix"
 
 	"github.com/Azure/kube-egress-gateway/pkg/cni/conf"
+	"github.com/Azure/kube-egress-gateway/pkg/cni/ipam"
 	"github.com/Azure/kube-egress-gateway/pkg/consts"
 	"github.com/Azure/kube-egress-gateway/pkg/logger"
 )
@@ -45,75 +42,30 @@ func cmdAdd(args *skel.CmdArgs) error {
 		return errors.New("ipam should not be empty")
 	}
 
-	podNetNS, err := ns.GetNS(args.Netns)
+	poolConfig, err := ipam.ParsePoolConfig(args.StdinData)
 	if err != nil {
 		return err
 	}
-	defer func() {
-		if err := podNetNS.Close(); err != nil {
-			// Log error but don't fail the operation
-			log.Error(err, "failed to close pod network namespace")
-		}
-	}()
-
-	var v4Address, v6Address net.IPNet
-	var ipv4AddrFound, ipv6AddrFound bool
-	var extraRoutes []*types.Route
-	err = podNetNS.Do(func(netNS ns.NetNS) error {
-		eth0Link, err := netlink.LinkByName("eth0")
-		if err != nil {
-			return err
-		}
-
-		addrList, err := netlink.AddrList(eth0Link, netlink.FAMILY_V6)
+	if len(poolConfig.Ranges) > 0 {
+		// allocate a tunnel address from the ranges
+		pool, err := ipam.NewPool(*poolConfig)
 		if err != nil {
 			return err
 		}
-		for _, item := range addrList {
-			if item.Scope == unix.RT_SCOPE_LINK {
-				v6Address = *item.IPNet
-				ipv6AddrFound = true
-			}
-		}
-
-		addrList, err = netlink.AddrList(eth0Link, netlink.FAMILY_V4)
+		result, err := pool.Allocate(args.ContainerID, poolConfig.GatewayName)
 		if err != nil {
 			return err
 		}
-		for _, item := range addrList {
-			if item.Scope == unix.RT_SCOPE_UNIVERSE {
-				v4Address = *item.IPNet
-				ipv4AddrFound = true
-			}
-		}
-		return nil
-	})
+		return types.PrintResult(result, config.CNIVersion)
+	}
 
+	v4Address, v6Address, err := ipam.PodAddresses(args.Netns)
 	if err != nil {
 		return err
 	}
-	if !ipv4AddrFound {
-		return errors.New("there is no enough ipv4 addr allocated for this pod")
-	}
-	if !ipv6AddrFound {
-		return errors.New("there is no enough ipv6 addr allocated for this pod")
-	}
 
-	result := &type100.Result{
-		CNIVersion: type100.ImplementedSpecVersion,
-		IPs: []*type100.IPConfig{
-			{
-				Address: v6Address,
-				Gateway: net.ParseIP(consts.GatewayIP),
-			},
-			{
-				Address: v4Address,
-			},
-		},
-		Routes: extraRoutes,
-	}
 	// outputCmdArgs(args)
-	return types.PrintResult(result, config.CNIVersion)
+	return types.PrintResult(ipam.PodResult(v4Address, v6Address), config.CNIVersion)
 }
 
 func cmdDel(args *skel.CmdArgs) error {
@@ -123,15 +75,53 @@ func cmdDel(args *skel.CmdArgs) error {
 		return err
 	}
 
+	poolConfig, err := ipam.ParsePoolConfig(args.StdinData)
+	if err != nil {
+		return err
+	}
+	if len(poolConfig.Ranges) > 0 {
+		pool, err := ipam.NewPool(*poolConfig)
+		if err != nil {
+			return err
+		}
+		if err := pool.Release(args.ContainerID); err != nil {
+			return err
+		}
+	}
 	return types.PrintResult(&type100.Result{}, config.CNIVersion)
 }
 
 func cmdCheck(args *skel.CmdArgs) error {
+	log := logger.GetLogger()
 	// get cni config
 	config, err := conf.ParseCNIConfig(args.StdinData)
 	if err != nil {
 		return err
 	}
+	if config.IPAM.Type == "" {
+		return errors.New("ipam should not be empty")
+	}
 
-	return types.PrintResult(&type100.Result{}, config.CNIVersion)
+	poolConfig, err := ipam.ParsePoolConfig(args.StdinData)
+	if err != nil {
+		return err
+	}
+	if len(poolConfig.Ranges) > 0 {
+		pool, err := ipam.NewPool(*poolConfig)
+		if err != nil {
+			return err
+		}
+		if err := pool.Check(args.ContainerID); err != nil {
+			return types.NewError(types.ErrInternal, "tunnel address check failed", err.Error())
+		}
+		return nil
+	}
+
+	// the addresses handed out in cmdAdd are derived from pod's eth0, make sure they are still there
+	v4Address, v6Address, err := ipam.PodAddresses(args.Netns)
+	if err != nil {
+		return types.NewError(types.ErrInternal, "pod addresses check failed", err.Error())
+	}
+	log.V(5).Info("CHECK - pod addresses found", "ipv4", v4Address.String(), "ipv6", v6Address.String())
+	return nil
 }
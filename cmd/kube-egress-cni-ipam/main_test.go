@@ -153,13 +153,66 @@ var _ = Describe("Test kube-egress-cni-ipam operations", func() {
 		Expect(err).NotTo(HaveOccurred())
 	})
 
-	It("should not report error in cmdCheck", func() {
+	It("should not report error in cmdCheck when eth0 has ipv4 and ipv6 ips", func() {
+		err := targetNS.Do(func(ns.NetNS) error {
+			defer GinkgoRecover()
+			Expect(netlink.LinkAdd(&netlink.Dummy{LinkAttrs: netlink.LinkAttrs{Name: ifName}})).To(Succeed())
+			eth0, err := netlink.LinkByName(ifName)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(netlink.AddrAdd(eth0, &netlink.Addr{IPNet: ipv4Net})).To(Succeed())
+			Expect(netlink.AddrAdd(eth0, &netlink.Addr{IPNet: ipv6Net})).To(Succeed())
+			return nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		err = originalNS.Do(func(ns.NetNS) error {
+			defer GinkgoRecover()
+			err := testutils.CmdCheckWithArgs(args, func() error {
+				return cmdCheck(args)
+			})
+			Expect(err).NotTo(HaveOccurred())
+			return nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should report error in cmdCheck when eth0 is not found", func() {
 		err := originalNS.Do(func(ns.NetNS) error {
 			defer GinkgoRecover()
 			err := testutils.CmdCheckWithArgs(args, func() error {
 				return cmdCheck(args)
 			})
+			Expect(err).To(HaveOccurred())
+			return nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should allocate, check and release tunnel address from ranges without pod eth0", func() {
+		dataDir := GinkgoT().TempDir()
+		args.StdinData = []byte(`{"cniVersion":"1.0.0","ipam":{"type":"kube-egress-cni-ipam","dataDir":"` + dataDir + `","ranges":[{"subnet":"100.64.1.0/24"}]},"name":"mynet","type":"kube-egress-cni"}`)
+		err := originalNS.Do(func(ns.NetNS) error {
+			defer GinkgoRecover()
+			r, _, err := testutils.CmdAddWithArgs(args, func() error {
+				return cmdAdd(args)
+			})
 			Expect(err).NotTo(HaveOccurred())
+			resultType, err := r.GetAsVersion(type100.ImplementedSpecVersion)
+			Expect(err).NotTo(HaveOccurred())
+			result := resultType.(*type100.Result)
+			Expect(result.IPs).To(HaveLen(1))
+			Expect(result.IPs[0].Address.String()).To(Equal("100.64.1.2/24"))
+			Expect(result.IPs[0].Gateway.String()).To(Equal("100.64.1.1"))
+
+			Expect(testutils.CmdCheckWithArgs(args, func() error {
+				return cmdCheck(args)
+			})).To(Succeed())
+			Expect(testutils.CmdDelWithArgs(args, func() error {
+				return cmdDel(args)
+			})).To(Succeed())
+			Expect(testutils.CmdCheckWithArgs(args, func() error {
+				return cmdCheck(args)
+			})).NotTo(Succeed())
 			return nil
 		})
 		Expect(err).NotTo(HaveOccurred())
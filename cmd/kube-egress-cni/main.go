@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"time"
 
 	"github.com/containernetworking/cni/pkg/skel"
 	"github.com/containernetworking/cni/pkg/types"
@@ -19,25 +20,42 @@ import (
 	grpc_retry "github.com/grpc-ecosystem/go-grpc-middleware/retry"
 	grpc_prometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
 	"github.com/vishvananda/netlink"
-	"golang.zx2c4.com/wireguard/wgctrl"
-	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
+	healthgrpc "google.golang.org/grpc/health/grpc_health_v1"
 	"k8s.io/klog/v2"
 
+	"github.com/Azure/kube-egress-gateway/pkg/cni/attach"
 	"github.com/Azure/kube-egress-gateway/pkg/cni/conf"
 	"github.com/Azure/kube-egress-gateway/pkg/cni/ipam"
 	"github.com/Azure/kube-egress-gateway/pkg/cni/routes"
+	"github.com/Azure/kube-egress-gateway/pkg/cni/sandbox"
 	"github.com/Azure/kube-egress-gateway/pkg/cni/wireguard"
 	v1 "github.com/Azure/kube-egress-gateway/pkg/cniprotocol/v1"
 	"github.com/Azure/kube-egress-gateway/pkg/consts"
+	"github.com/Azure/kube-egress-gateway/pkg/netfilter"
+	"github.com/Azure/kube-egress-gateway/pkg/tracing"
+)
+
+const (
+	// errPluginNotAvailable is the CNI 1.1 error code for a plugin that cannot service ADD requests
+	errPluginNotAvailable uint = 50
+
+	// statusTimeout is the timeout of cni manager health check in STATUS
+	statusTimeout = 5 * time.Second
+
+	// tracingFlushTimeout is the timeout of exporting the spans of ADD before the plugin exits
+	tracingFlushTimeout = 2 * time.Second
 )
 
 func main() {
-	skel.PluginMainFuncs(skel.CNIFuncs{Add: cmdAdd, Check: cmdCheck, Del: cmdDel}, version.All, bv.BuildString(consts.KubeEgressCNIName))
+	skel.PluginMainFuncs(skel.CNIFuncs{Add: cmdAdd, Check: cmdCheck, Del: cmdDel, GC: cmdGC, Status: cmdStatus}, version.All, bv.BuildString(consts.KubeEgressCNIName))
 }
 
-func cmdAdd(args *skel.CmdArgs) error {
+func cmdAdd(args *skel.CmdArgs) (err error) {
 	// get cni config
 	config, err := conf.ParseCNIConfig(args.StdinData)
 	if err != nil {
@@ -50,156 +68,116 @@ func cmdAdd(args *skel.CmdArgs) error {
 		return fmt.Errorf("failed to load k8s metadata: %w", err)
 	}
 
-	// get prevResult
-	result, err := type100.NewResultFromResult(config.PrevResult)
-	if err != nil {
-		return fmt.Errorf("failed to convert result to current version: %w", err)
-	}
-
-	// exchange public key with daemon
-	conn, err := grpc.NewClient(config.SocketPath,
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
-		grpc.WithStreamInterceptor(grpc_retry.StreamClientInterceptor()),
-		grpc.WithUnaryInterceptor(grpc_retry.UnaryClientInterceptor()),
-		grpc.WithUnaryInterceptor(grpc_prometheus.UnaryClientInterceptor),
-		grpc.WithStreamInterceptor(grpc_prometheus.StreamClientInterceptor),
-		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
-			d := net.Dialer{}
-			return d.DialContext(ctx, "tcp", addr)
-		}),
-	)
-	if err != nil {
-		return fmt.Errorf("failed to contact cni manager daemon: %w", err)
-	}
+	// trace the pod attach, cni manager and gateway daemons continue the trace
+	ctx, span, flush := startAddSpan(config, args, k8sInfo)
 	defer func() {
-		if err := conn.Close(); err != nil {
-			// Log error but don't fail the operation
-			klog.ErrorS(err, "failed to close gRPC connection")
-		}
+		tracing.EndSpan(span, err)
+		flush()
 	}()
-	client := v1.NewNicServiceClient(conn)
 
-	// check if pod does not have gateway annotation, then skip the whole process
-	resp, err := client.PodRetrieve(context.Background(), &v1.PodRetrieveRequest{
-		PodConfig: &v1.PodInfo{
-			PodName:      string(k8sInfo.K8S_POD_NAME),
-			PodNamespace: string(k8sInfo.K8S_POD_NAMESPACE),
-		},
-	})
-	if err != nil {
-		return fmt.Errorf("failed to get pod (%s/%s) annotations: %w", string(k8sInfo.K8S_POD_NAME), string(k8sInfo.K8S_POD_NAMESPACE), err)
-	}
-	annotations := resp.GetAnnotations()
-	gwName, ok := annotations[consts.CNIGatewayAnnotationKey]
-	if !ok {
-		// pod does not use egress gateway, nothing else to do
-		return types.PrintResult(result, config.CNIVersion)
-	}
-
-	// allocate ip
-	if config == nil || config.IPAM.Type == "" {
-		return errors.New("ipam should not be empty")
+	// get prevResult, there is none when the plugin is invoked alone as a Multus network attachment
+	result := &type100.Result{CNIVersion: type100.ImplementedSpecVersion}
+	if config.PrevResult != nil {
+		if result, err = type100.NewResultFromResult(config.PrevResult); err != nil {
+			return fmt.Errorf("failed to convert result to current version: %w", err)
+		}
 	}
 
-	err = wireguard.WithWireGuardNic(args.ContainerID, args.Netns, consts.WireguardLinkName, ipam.New(config.IPAM.Type, args.StdinData), config.ExcludedCIDRs, result, func(podNs ns.NetNS, allowedIPNet string) error {
-		//generate private key
-		privateKey, err := wgtypes.GeneratePrivateKey()
-		if err != nil {
-			return fmt.Errorf("failed to generate wg private key: %w", err)
-		}
-		var wgDevice *wgtypes.Device
-		err = podNs.Do(func(nn ns.NetNS) error {
-			wgclient, err := wgctrl.New()
-			if err != nil {
-				return fmt.Errorf("failed to create wg client: %w", err)
-			}
-			defer func() {
-				if err := wgclient.Close(); err != nil {
-					// Log error but don't fail the operation
-					klog.ErrorS(err, "failed to close wireguard client")
-				}
-			}()
-			wgDevice, err = wgclient.Device(consts.WireguardLinkName)
-			if err != nil {
-				return fmt.Errorf("failed to find wg device (%s): %w", consts.WireguardLinkName, err)
-			}
-			return nil
-		})
-		if err != nil {
-			return err
-		}
+	// exchange public key with daemon
+	return withCNIManagerConn(config.SocketPath, func(conn *grpc.ClientConn) error {
+		client := v1.NewNicServiceClient(conn)
 
-		resp, err := client.NicAdd(context.Background(), &v1.NicAddRequest{
+		// check if pod does not have gateway annotation, then skip the whole process
+		resp, err := client.PodRetrieve(ctx, &v1.PodRetrieveRequest{
 			PodConfig: &v1.PodInfo{
 				PodName:      string(k8sInfo.K8S_POD_NAME),
 				PodNamespace: string(k8sInfo.K8S_POD_NAMESPACE),
 			},
-			PublicKey:   privateKey.PublicKey().String(),
-			ListenPort:  int32(wgDevice.ListenPort),
-			AllowedIp:   allowedIPNet,
-			GatewayName: gwName,
 		})
 		if err != nil {
-			return fmt.Errorf("failed to send nicAdd request: %w", err)
+			return fmt.Errorf("failed to get pod (%s/%s) annotations: %w", string(k8sInfo.K8S_POD_NAME), string(k8sInfo.K8S_POD_NAMESPACE), err)
+		}
+		annotations := resp.GetAnnotations()
+		gwName, ok := annotations[consts.CNIGatewayAnnotationKey]
+		record := &sandbox.Record{
+			ContainerID:  args.ContainerID,
+			NetnsPath:    args.Netns,
+			PodName:      string(k8sInfo.K8S_POD_NAME),
+			PodNamespace: string(k8sInfo.K8S_POD_NAMESPACE),
+			PodUID:       string(k8sInfo.K8S_POD_UID),
+		}
+		if !ok {
+			// pod does not use egress gateway, nothing else to do but recording the sandbox
+			// in case the pod is annotated later
+			saveSandbox(config, record)
+			return types.PrintResult(result, config.CNIVersion)
 		}
 
-		gwPublicKey, err := wgtypes.ParseKey(resp.PublicKey)
-		if err != nil {
-			return fmt.Errorf("failed to parse gateway public key: %w", err)
+		// allocate ip
+		if config == nil || config.IPAM.Type == "" {
+			return errors.New("ipam should not be empty")
 		}
 
-		return podNs.Do(func(nn ns.NetNS) error {
-			wgclient, err := wgctrl.New()
-			if err != nil {
-				return fmt.Errorf("failed to create wg client: %w", err)
-			}
-			defer func() {
-				if err := wgclient.Close(); err != nil {
-					// Log error but don't fail the operation
-					klog.ErrorS(err, "failed to close wireguard client")
-				}
-			}()
-			err = wgclient.ConfigureDevice(consts.WireguardLinkName, wgtypes.Config{
-				PrivateKey: &privateKey,
-				Peers: []wgtypes.PeerConfig{
-					{
-						PublicKey: gwPublicKey,
-						Endpoint: &net.UDPAddr{
-							IP:   net.ParseIP(resp.EndpointIp),
-							Port: int(resp.ListenPort),
-						},
-						AllowedIPs: []net.IPNet{
-							{
-								IP:   net.IPv4zero,
-								Mask: net.CIDRMask(0, 8*len(net.IPv4zero)),
-							},
-							{
-								IP:   net.IPv6zero,
-								Mask: net.CIDRMask(0, 8*len(net.IPv6zero)),
-							},
-						},
-					},
-				},
-			})
-			if err != nil {
-				return fmt.Errorf("failed to configure wg device: %w", err)
+		// firewall backend must be detected in host network namespace
+		firewallBackend, err := netfilter.ParseBackend(config.FirewallBackend)
+		if err != nil {
+			return err
+		}
+		firewallBackend = netfilter.DetectBackend(firewallBackend)
 
+		// kube-egress-cni-ipam allocates the tunnel address from the range of the gateway, if any
+		ipamConf := args.StdinData
+		if config.IPAM.Type == consts.KubeEgressIPAMCNIName {
+			if ipamConf, err = ipam.SetGatewayName(args.StdinData, record.PodNamespace+"/"+gwName); err != nil {
+				return err
 			}
+		}
 
-			if os.Getenv("IS_UNIT_TEST_ENV") != "true" {
-				if err := routes.SetPodRoutes(consts.WireguardLinkName, resp, config.ExcludedCIDRs, "/proc/sys", result); err != nil {
-					return fmt.Errorf("failed to setup pod routes: %w", err)
-				}
-			}
-			return nil
-		})
+		err = attach.Attach(ctx, client, record, gwName, ipam.New(config.IPAM.Type, ipamConf), config.ExcludedCIDRs, firewallBackend, result)
+		if err != nil {
+			return err
+		}
+		if traceID := tracing.TraceID(ctx); traceID != "" {
+			klog.InfoS("attached pod to gateway", "pod", record.PodNamespace+"/"+record.PodName, "gateway", gwName, "traceID", traceID)
+		}
+		saveSandbox(config, record)
+		// outputCmdArgs(args)
+		return types.PrintResult(result, config.CNIVersion)
 	})
+}
 
+// startAddSpan sets up tracing as configured in cni config and starts the span of the ADD request. The returned
+// function exports the spans and must be called after the span ends, as the plugin exits right after. Tracing
+// failures are logged only, the pod network is set up regardless.
+func startAddSpan(config *conf.CNIConfig, args *skel.CmdArgs, k8sInfo *conf.K8sConfig) (context.Context, trace.Span, func()) {
+	shutdown, err := tracing.Setup(consts.KubeEgressCNIName, config.TracingEndpoint)
 	if err != nil {
-		return err
+		klog.ErrorS(err, "failed to set up tracing")
+		shutdown = func(context.Context) error { return nil }
+	}
+	ctx, span := tracing.Tracer().Start(context.Background(), "cni add", trace.WithAttributes(
+		attribute.String("pod", string(k8sInfo.K8S_POD_NAMESPACE)+"/"+string(k8sInfo.K8S_POD_NAME)),
+		attribute.String("containerID", args.ContainerID),
+	))
+	flush := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), tracingFlushTimeout)
+		defer cancel()
+		if err := shutdown(ctx); err != nil {
+			klog.ErrorS(err, "failed to export spans")
+		}
+	}
+	return ctx, span, flush
+}
+
+// saveSandbox records the pod sandbox for cni manager if enabled in cni config. Failures are logged only,
+// the pod network is set up regardless.
+func saveSandbox(config *conf.CNIConfig, record *sandbox.Record) {
+	if config.SandboxDir == "" {
+		return
+	}
+	if err := sandbox.NewStore(config.SandboxDir).Save(record); err != nil {
+		klog.ErrorS(err, "failed to record pod sandbox", "containerID", record.ContainerID)
 	}
-	// outputCmdArgs(args)
-	return types.PrintResult(result, config.CNIVersion)
 }
 
 func cmdDel(args *skel.CmdArgs) error {
@@ -211,6 +189,12 @@ func cmdDel(args *skel.CmdArgs) error {
 		return nil
 	}
 
+	if config.SandboxDir != "" {
+		if err := sandbox.NewStore(config.SandboxDir).Delete(args.ContainerID); err != nil {
+			logger.Error(err, "failed to delete pod sandbox record")
+		}
+	}
+
 	// get k8s metadata
 	k8sInfo, err := conf.LoadK8sInfo(args.Args)
 	if err != nil {
@@ -248,45 +232,216 @@ func cmdDel(args *skel.CmdArgs) error {
 		logger.Error(err, "failed to delete ip")
 		return nil
 	}
-	conn, err := grpc.NewClient(config.SocketPath,
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
-		grpc.WithStreamInterceptor(grpc_retry.StreamClientInterceptor()),
-		grpc.WithUnaryInterceptor(grpc_retry.UnaryClientInterceptor()),
-		grpc.WithUnaryInterceptor(grpc_prometheus.UnaryClientInterceptor),
-		grpc.WithStreamInterceptor(grpc_prometheus.StreamClientInterceptor),
-		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
-			d := net.Dialer{}
-			return d.DialContext(ctx, "tcp", addr)
-		}))
+	err = withCNIManagerConn(config.SocketPath, func(conn *grpc.ClientConn) error {
+		_, err := v1.NewNicServiceClient(conn).NicDel(context.Background(), &v1.NicDelRequest{
+			PodConfig: &v1.PodInfo{
+				PodName:      string(k8sInfo.K8S_POD_NAME),
+				PodNamespace: string(k8sInfo.K8S_POD_NAMESPACE),
+				PodUid:       string(k8sInfo.K8S_POD_UID),
+			},
+			ContainerId: args.ContainerID,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to send nicDel request: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		logger.Error(err, "failed to delete pod endpoint")
+	}
+
+	return nil
+}
+
+func cmdCheck(args *skel.CmdArgs) error {
+	// get cni config
+	config, err := conf.ParseCNIConfig(args.StdinData)
 	if err != nil {
-		logger.Error(err, "failed to contact cni manager daemon")
+		return fmt.Errorf("failed to parse CNI config: %w", err)
+	}
+
+	// get k8s metadata
+	k8sInfo, err := conf.LoadK8sInfo(args.Args)
+	if err != nil {
+		return fmt.Errorf("failed to load k8s metadata: %w", err)
+	}
+
+	// get prevResult, which carries the wireguard interface and address added in cmdAdd
+	if config.PrevResult == nil {
+		return types.NewError(types.ErrInvalidNetworkConfig, "prevResult is required in CHECK", "")
+	}
+	result, err := type100.NewResultFromResult(config.PrevResult)
+	if err != nil {
+		return fmt.Errorf("failed to convert result to current version: %w", err)
+	}
+
+	var gateway *v1.NicCheckResponse
+	err = withCNIManagerConn(config.SocketPath, func(conn *grpc.ClientConn) error {
+		client := v1.NewNicServiceClient(conn)
+		podInfo := &v1.PodInfo{
+			PodName:      string(k8sInfo.K8S_POD_NAME),
+			PodNamespace: string(k8sInfo.K8S_POD_NAMESPACE),
+		}
+		resp, err := client.PodRetrieve(context.Background(), &v1.PodRetrieveRequest{PodConfig: podInfo})
+		if err != nil {
+			return fmt.Errorf("failed to get pod (%s/%s) annotations: %w", string(k8sInfo.K8S_POD_NAME), string(k8sInfo.K8S_POD_NAMESPACE), err)
+		}
+		gwName, ok := resp.GetAnnotations()[consts.CNIGatewayAnnotationKey]
+		if !ok {
+			return nil
+		}
+
+		// fetch the current gateway configuration the pod nic is expected to match
+		gateway, err = client.NicCheck(context.Background(), &v1.NicCheckRequest{PodConfig: podInfo, GatewayName: gwName})
+		if err != nil {
+			return fmt.Errorf("failed to send nicCheck request: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if gateway == nil {
+		// pod does not use egress gateway, nothing to check
 		return nil
 	}
+
+	// firewall backend must be detected in host network namespace
+	firewallBackend, err := netfilter.ParseBackend(config.FirewallBackend)
+	if err != nil {
+		return err
+	}
+	firewallBackend = netfilter.DetectBackend(firewallBackend)
+
+	if os.Getenv("IS_UNIT_TEST_ENV") != "true" {
+		if err := ipam.New(config.IPAM.Type, args.StdinData).CheckIP(); err != nil {
+			return types.NewError(types.ErrInternal, "ipam check failed", err.Error())
+		}
+	}
+
+	podNs, err := ns.GetNS(args.Netns)
+	if err != nil {
+		return fmt.Errorf("failed to get pod namespace: %w", err)
+	}
 	defer func() {
-		if err := conn.Close(); err != nil {
-			logger.Error(err, "failed to close connection")
+		if err := podNs.Close(); err != nil {
+			klog.ErrorS(err, "failed to close pod namespace")
 		}
 	}()
-	client := v1.NewNicServiceClient(conn)
-	_, err = client.NicDel(context.Background(), &v1.NicDelRequest{
-		PodConfig: &v1.PodInfo{
-			PodName:      string(k8sInfo.K8S_POD_NAME),
-			PodNamespace: string(k8sInfo.K8S_POD_NAMESPACE),
-		},
+	err = podNs.Do(func(nn ns.NetNS) error {
+		if err := wireguard.CheckWireGuardNic(consts.WireguardLinkName, result, gateway); err != nil {
+			return err
+		}
+		if os.Getenv("IS_UNIT_TEST_ENV") != "true" {
+			return routes.CheckPodRoutes(consts.WireguardLinkName, gateway, config.ExcludedCIDRs, firewallBackend)
+		}
+		return nil
 	})
 	if err != nil {
-		logger.Error(err, "failed to send nicDel request")
+		return types.NewError(types.ErrInternal, "pod network does not match gateway configuration", err.Error())
 	}
-
 	return nil
 }
 
-func cmdCheck(args *skel.CmdArgs) error {
+func cmdStatus(args *skel.CmdArgs) error {
 	// get cni config
 	config, err := conf.ParseCNIConfig(args.StdinData)
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to parse CNI config: %w", err)
+	}
+
+	err = withCNIManagerConn(config.SocketPath, func(conn *grpc.ClientConn) error {
+		ctx, cancel := context.WithTimeout(context.Background(), statusTimeout)
+		defer cancel()
+		resp, err := healthgrpc.NewHealthClient(conn).Check(ctx, &healthgrpc.HealthCheckRequest{})
+		if err != nil {
+			return types.NewError(errPluginNotAvailable, "cni manager daemon health check failed", err.Error())
+		}
+		if resp.GetStatus() != healthgrpc.HealthCheckResponse_SERVING {
+			return types.NewError(errPluginNotAvailable, "cni manager daemon is not serving", resp.GetStatus().String())
+		}
+		return nil
+	})
+	var cniErr *types.Error
+	if err != nil && !errors.As(err, &cniErr) {
+		// connection to the cni manager daemon could not be created
+		return types.NewError(errPluginNotAvailable, "failed to contact cni manager daemon", err.Error())
+	}
+	return err
+}
+
+func cmdGC(args *skel.CmdArgs) error {
+	// get cni config
+	config, err := conf.ParseCNIConfig(args.StdinData)
+	if err != nil {
+		return fmt.Errorf("failed to parse CNI config: %w", err)
+	}
+
+	validContainerIDs := make([]string, 0, len(config.ValidAttachments))
+	for _, attachment := range config.ValidAttachments {
+		validContainerIDs = append(validContainerIDs, attachment.ContainerID)
+	}
+
+	// wireguard links are created in host namespace and moved into pod namespace in cmdAdd,
+	// remove the ones left behind by interrupted calls
+	var errs []error
+	if err := wireguard.DeleteStaleHostLinks(validContainerIDs); err != nil {
+		errs = append(errs, fmt.Errorf("failed to delete stale wireguard links: %w", err))
+	}
+	if config.SandboxDir != "" {
+		if err := sandbox.NewStore(config.SandboxDir).DeleteStale(validContainerIDs); err != nil {
+			errs = append(errs, fmt.Errorf("failed to delete stale pod sandbox records: %w", err))
+		}
+	}
+
+	err = withCNIManagerConn(config.SocketPath, func(conn *grpc.ClientConn) error {
+		resp, err := v1.NewNicServiceClient(conn).NicGC(context.Background(), &v1.NicGCRequest{ValidContainerIds: validContainerIDs})
+		if err != nil {
+			return fmt.Errorf("failed to send nicGC request: %w", err)
+		}
+		for _, pod := range resp.GetDeletedPods() {
+			klog.InfoS("deleted orphaned pod endpoint", "pod", pod.GetPodNamespace()+"/"+pod.GetPodName())
+		}
+		return nil
+	})
+	return errors.Join(append(errs, err)...)
+}
+
+// withCNIManagerConn calls fn with a gRPC client connection to the cni manager daemon, which is closed
+// once fn returns.
+func withCNIManagerConn(socketPath string, fn func(conn *grpc.ClientConn) error) error {
+	conn, err := newCNIManagerConn(socketPath)
+	if err != nil {
+		return fmt.Errorf("failed to contact cni manager daemon: %w", err)
 	}
+	defer func() {
+		if err := conn.Close(); err != nil {
+			// Log error but don't fail the operation
+			klog.ErrorS(err, "failed to close gRPC connection")
+		}
+	}()
+	return fn(conn)
+}
 
-	return types.PrintResult(&type100.Result{}, config.CNIVersion)
+// newCNIManagerConn creates a gRPC client connection to the cni manager daemon, socketPath is either
+// a tcp address or a unix socket path prefixed with unix://.
+func newCNIManagerConn(socketPath string) (*grpc.ClientConn, error) {
+	network, address := conf.ParseSocketPath(socketPath)
+	target := socketPath
+	if network == "unix" {
+		// dialer below connects to the socket, skip name resolution of the target
+		target = "passthrough:///" + address
+	}
+	return grpc.NewClient(target,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithStreamInterceptor(grpc_retry.StreamClientInterceptor()),
+		grpc.WithUnaryInterceptor(grpc_retry.UnaryClientInterceptor()),
+		grpc.WithUnaryInterceptor(grpc_prometheus.UnaryClientInterceptor),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+		grpc.WithStreamInterceptor(grpc_prometheus.StreamClientInterceptor),
+		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			d := net.Dialer{}
+			return d.DialContext(ctx, network, addr)
+		}),
+	)
 }
@@ -4,10 +4,14 @@
 package main
 
 import (
+	"errors"
 	"net"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/containernetworking/cni/pkg/skel"
+	"github.com/containernetworking/cni/pkg/types"
 	type100 "github.com/containernetworking/cni/pkg/types/100"
 	"github.com/containernetworking/plugins/pkg/ns"
 	"github.com/containernetworking/plugins/pkg/testutils"
@@ -15,6 +19,7 @@ import (
 	. "github.com/onsi/gomega"
 	"github.com/vishvananda/netlink"
 
+	"github.com/Azure/kube-egress-gateway/pkg/cni/sandbox"
 	cniprotocol "github.com/Azure/kube-egress-gateway/pkg/cniprotocol/v1"
 	"github.com/Azure/kube-egress-gateway/pkg/consts"
 )
@@ -31,7 +36,7 @@ func createCmdArgs(targetNS ns.NetNS) *skel.CmdArgs {
 func createCmdArgsWithCustomExcludedCIDRs(targetNS ns.NetNS, excludedCIDRs []string) *skel.CmdArgs {
 	conf := `{"cniVersion":"1.0.0","excludedCIDRs":["` + joinCIDRs(excludedCIDRs) + `"],"socketPath":"localhost:50051","gatewayName":"test","ipam":{"type":"static","addresses":[{"address":"fe80::5/64"},{"address":"10.4.0.5/24"}]},"name":"mynet","type":"kube-egress-cni","prevResult":{"cniVersion":"1.0.0","interfaces":[{"name":"eth0","sandbox":"somepath"}],"ips":[{"interface":0,"address":"10.2.0.1/24"}],"dns":{}}}`
 	return &skel.CmdArgs{
-		Args:        `IgnoreUnknown=true;K8S_POD_NAMESPACE=testns;K8S_POD_NAME=testpod`,
+		Args:        `IgnoreUnknown=true;K8S_POD_NAMESPACE=testns;K8S_POD_NAME=testpod;K8S_POD_UID=testuid`,
 		ContainerID: "test-container",
 		Netns:       targetNS.Path(),
 		IfName:      ifName,
@@ -94,6 +99,36 @@ var _ = Describe("Test kube-egress-cni operations", func() {
 		Expect(len(result.Interfaces)).To(Equal(1))
 	})
 
+	It("should record sandbox of pod not using any staticGatewayConfiguration in cmdAdd and delete it in cmdDel", func() {
+		grpcTestServer, err := cniprotocol.StartTestServer(testAddr, nil, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(grpcTestServer).NotTo(BeNil())
+		defer grpcTestServer.GracefulStop()
+
+		sandboxDir := GinkgoT().TempDir()
+		args.StdinData = []byte(strings.Replace(string(args.StdinData), `"socketPath"`, `"sandboxDir":"`+sandboxDir+`","socketPath"`, 1))
+		_, _, err = testutils.CmdAddWithArgs(args, func() error {
+			return cmdAdd(args)
+		})
+		Expect(err).NotTo(HaveOccurred())
+		<-grpcTestServer.Received
+		record, err := sandbox.NewStore(sandboxDir).Get(args.ContainerID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(record).To(Equal(&sandbox.Record{
+			ContainerID:  "test-container",
+			NetnsPath:    targetNS.Path(),
+			PodName:      "testpod",
+			PodNamespace: "testns",
+			PodUID:       "testuid",
+		}))
+
+		Expect(testutils.CmdDelWithArgs(args, func() error {
+			return cmdDel(args)
+		})).To(Succeed())
+		_, err = sandbox.NewStore(sandboxDir).Get(args.ContainerID)
+		Expect(errors.Is(err, os.ErrNotExist)).To(BeTrue())
+	})
+
 	It("should configure pod namespace as expected in cmdAdd", func() {
 		err := targetNS.Do(func(ns.NetNS) error {
 			defer GinkgoRecover()
@@ -158,12 +193,97 @@ var _ = Describe("Test kube-egress-cni operations", func() {
 		Expect(ok).To(BeTrue())
 		Expect(req.GetPodConfig().GetPodNamespace()).To(Equal("testns"))
 		Expect(req.GetPodConfig().GetPodName()).To(Equal("testpod"))
+		Expect(req.GetPodConfig().GetPodUid()).To(Equal("testuid"))
+		Expect(req.GetContainerId()).To(Equal("test-container"))
+	})
+
+	It("should not report error in cmdCheck when pod does not use any staticGatewayConfiguration", func() {
+		grpcTestServer, err := cniprotocol.StartTestServer(testAddr, nil, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(grpcTestServer).NotTo(BeNil())
+		defer grpcTestServer.GracefulStop()
+
+		err = testutils.CmdCheckWithArgs(args, func() error {
+			return cmdCheck(args)
+		})
+		Expect(err).NotTo(HaveOccurred())
+		msg := <-grpcTestServer.Received
+		_, ok := msg.(*cniprotocol.PodRetrieveRequest)
+		Expect(ok).To(BeTrue())
+	})
+
+	It("should report error in cmdCheck when wireguard nic is missing", func() {
+		grpcTestServer, err := cniprotocol.StartTestServer(testAddr, nil, map[string]string{consts.CNIGatewayAnnotationKey: "test-sgw"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(grpcTestServer).NotTo(BeNil())
+		defer grpcTestServer.GracefulStop()
+
+		err = testutils.CmdCheckWithArgs(args, func() error {
+			return cmdCheck(args)
+		})
+		Expect(err).To(HaveOccurred())
+		cniErr, ok := err.(*types.Error)
+		Expect(ok).To(BeTrue())
+		Expect(cniErr.Code).To(Equal(uint(types.ErrInternal)))
+
+		msg := <-grpcTestServer.Received
+		_, ok = msg.(*cniprotocol.PodRetrieveRequest)
+		Expect(ok).To(BeTrue())
+		msg = <-grpcTestServer.Received
+		req, ok := msg.(*cniprotocol.NicCheckRequest)
+		Expect(ok).To(BeTrue())
+		Expect(req.GetPodConfig().GetPodNamespace()).To(Equal("testns"))
+		Expect(req.GetPodConfig().GetPodName()).To(Equal("testpod"))
+		Expect(req.GetGatewayName()).To(Equal("test-sgw"))
 	})
 
-	It("should not report error in cmdCheck", func() {
+	It("should report error in cmdCheck when prevResult is missing", func() {
+		args.StdinData = []byte(`{"cniVersion":"1.0.0","socketPath":"localhost:50051","ipam":{"type":"static"},"name":"mynet","type":"kube-egress-cni"}`)
 		err := testutils.CmdCheckWithArgs(args, func() error {
 			return cmdCheck(args)
 		})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should not report error in cmdStatus when cni manager is serving", func() {
+		grpcTestServer, err := cniprotocol.StartTestServer(testAddr, nil, nil)
 		Expect(err).NotTo(HaveOccurred())
+		Expect(grpcTestServer).NotTo(BeNil())
+		defer grpcTestServer.GracefulStop()
+
+		Expect(cmdStatus(args)).To(Succeed())
+	})
+
+	It("should connect to cni manager through unix socket in cmdStatus", func() {
+		socketPath := "unix://" + filepath.Join(GinkgoT().TempDir(), "cnimanager.sock")
+		grpcTestServer, err := cniprotocol.StartTestServer(socketPath, nil, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(grpcTestServer).NotTo(BeNil())
+		defer grpcTestServer.GracefulStop()
+
+		args.StdinData = []byte(`{"cniVersion":"1.1.0","socketPath":"` + socketPath + `","ipam":{"type":"static"},"name":"mynet","type":"kube-egress-cni"}`)
+		Expect(cmdStatus(args)).To(Succeed())
+	})
+
+	It("should report plugin not available in cmdStatus when cni manager is down", func() {
+		err := cmdStatus(args)
+		Expect(err).To(HaveOccurred())
+		cniErr, ok := err.(*types.Error)
+		Expect(ok).To(BeTrue())
+		Expect(cniErr.Code).To(Equal(errPluginNotAvailable))
+	})
+
+	It("should send valid container IDs to cni manager in cmdGC", func() {
+		grpcTestServer, err := cniprotocol.StartTestServer(testAddr, nil, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(grpcTestServer).NotTo(BeNil())
+		defer grpcTestServer.GracefulStop()
+
+		args.StdinData = []byte(`{"cniVersion":"1.1.0","socketPath":"localhost:50051","ipam":{"type":"static"},"name":"mynet","type":"kube-egress-cni","cni.dev/valid-attachments":[{"containerID":"container1","ifname":"eth0"},{"containerID":"container2","ifname":"eth0"}]}`)
+		Expect(cmdGC(args)).To(Succeed())
+		msg := <-grpcTestServer.Received
+		req, ok := msg.(*cniprotocol.NicGCRequest)
+		Expect(ok).To(BeTrue())
+		Expect(req.GetValidContainerIds()).To(Equal([]string{"container1", "container2"}))
 	})
 })
@@ -8,6 +8,7 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strconv"
 	"time"
 
@@ -25,6 +26,7 @@ import (
 	"go.uber.org/zap"
 	"golang.org/x/sync/errgroup"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/health"
 	healthgrpc "google.golang.org/grpc/health/grpc_health_v1"
 
@@ -39,15 +41,20 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
 	"sigs.k8s.io/controller-runtime/pkg/cluster"
+	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/manager/signals"
 
 	current "github.com/Azure/kube-egress-gateway/api/v1alpha1"
 	"github.com/Azure/kube-egress-gateway/controllers/cnimanager"
 	cniconf "github.com/Azure/kube-egress-gateway/pkg/cni/conf"
+	"github.com/Azure/kube-egress-gateway/pkg/cni/ipam"
+	"github.com/Azure/kube-egress-gateway/pkg/cni/sandbox"
 	cniprotocol "github.com/Azure/kube-egress-gateway/pkg/cniprotocol/v1"
 	"github.com/Azure/kube-egress-gateway/pkg/consts"
 	"github.com/Azure/kube-egress-gateway/pkg/logger"
 	"github.com/Azure/kube-egress-gateway/pkg/metrics"
+	"github.com/Azure/kube-egress-gateway/pkg/netfilter"
+	"github.com/Azure/kube-egress-gateway/pkg/tracing"
 )
 
 // serveCmd represents the serve command
@@ -62,8 +69,14 @@ var (
 	confFileName              string
 	exceptionCidrs            string
 	cniUninstallConfigMapName string
+	firewallBackend           string
+	grpcSocketPath            string
 	grpcPort                  int
 	metricsPort               int
+	enablePodNicAgent         bool
+	masterCNIConf             string
+	multusNetworkAttachment   string
+	tracingEndpoint           string
 )
 
 func init() {
@@ -78,10 +91,16 @@ func init() {
 	// Cobra supports local flags which will only run when this command
 	// is called directly, e.g.:
 	// serveCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
-	serveCmd.Flags().IntVar(&grpcPort, "grpc-server-port", 50051, "The port the grpc server listens on.")
+	serveCmd.Flags().StringVar(&grpcSocketPath, "grpc-socket-path", "/var/run/kube-egress-gateway/cnimanager.sock", "Path of the unix socket the grpc server listens on, only connections from root processes are accepted. Empty value disables the unix socket.")
+	serveCmd.Flags().IntVar(&grpcPort, "grpc-server-port", 0, "The port the grpc server listens on localhost, connections to it are not checked with SO_PEERCRED. 0 disables the tcp listener.")
 	serveCmd.Flags().IntVar(&metricsPort, "metrics-bind-port", 8080, "The port the metric endpoint binds to.")
 	serveCmd.Flags().StringVar(&exceptionCidrs, "exception-cidrs", "", "Cidrs that should bypass egress gateway separated with ',', e.g. intra-cluster traffic")
 	serveCmd.Flags().StringVar(&confFileName, "cni-conf-file", "01-egressgateway.conflist", "Name of the new cni configuration file")
+	serveCmd.Flags().StringVar(&firewallBackend, "firewall-backend", "", "The backend the cni plugin programs pod netfilter rules with, one of auto, iptables or nftables. Empty value means auto.")
+	serveCmd.Flags().BoolVar(&enablePodNicAgent, "enable-pod-nic-agent", false, "Whether to update the wireguard interfaces of running pods in place when their gateway changes, and to attach or detach running pods when their gateway annotation changes, requires access to pod network namespaces in /var/run/netns")
+	serveCmd.Flags().StringVar(&masterCNIConf, "master-cni-conf", "", "Rule selecting the cni conf file the plugin is chained to, one of network:<network name>, glob:<file name pattern> or file:<file name>. Empty value selects the first conf file that is not Multus'.")
+	serveCmd.Flags().StringVar(&multusNetworkAttachment, "multus-network-attachment", "", "Name of the Multus NetworkAttachmentDefinition the plugin is registered as in cniManager namespace, instead of being chained to the master cni conf file. Empty value disables Multus mode.")
+	serveCmd.Flags().StringVar(&tracingEndpoint, "tracing-endpoint", "", "The OTLP/HTTP endpoint, e.g. http://127.0.0.1:4318, spans of pod attach are exported to by cni manager and the cni plugin. The endpoint must be reachable from the host network namespace. Empty value disables tracing.")
 	serveCmd.Flags().StringVar(&cniUninstallConfigMapName, "cni-uninstall-configmap-name", "cni-uninstall", "Name of the configmap that indicates whether to uninstall cni plugin or not, the configMap should be in the same namespace as the cniManager pod")
 }
 
@@ -95,18 +114,60 @@ func ServiceLauncher(cmd *cobra.Command, args []string) {
 	logger.SetDefaultLogger(zapr.NewLogger(zapLog))
 	logger := logger.GetLogger()
 
+	shutdownTracing, err := tracing.Setup("kube-egress-gateway-cnimanager", tracingEndpoint)
+	if err != nil {
+		logger.Error(err, "failed to set up tracing")
+		os.Exit(1)
+	}
+	defer func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(shutdownCtx); err != nil {
+			logger.Error(err, "failed to flush spans")
+		}
+	}()
+
 	// Register metrics
 	prometheus.MustRegister(
 		metrics.CNIManagerPodEndpointOperationFailCount,
 		metrics.CNIManagerConfigOperationFailCount,
 		metrics.CNIManagerNodeTaintOperationFailCount,
+		metrics.CNIManagerRequestLatency,
+		metrics.CNIManagerCacheMissCount,
+		metrics.CNIManagerMasterConfInfo,
 	)
 	grpc_prometheus.EnableHandlingTimeHistogram()
 
 	k8sCluster := getKubeCluster(ctx, logger)
 	k8sClient := k8sCluster.GetClient()
 
-	cniConfMgr, err := cniconf.NewCNIConfManager(consts.CNIConfDir, confFileName, exceptionCidrs, cniUninstallConfigMapName, k8sClient, grpcPort)
+	if _, err := netfilter.ParseBackend(firewallBackend); err != nil {
+		logger.Error(err, "invalid firewall backend")
+		os.Exit(1)
+	}
+
+	if grpcSocketPath == "" && grpcPort == 0 {
+		logger.Error(nil, "either grpc-socket-path or grpc-server-port must be set")
+		os.Exit(1)
+	}
+	// cni plugin prefers the unix socket, tcp listener is kept for compatibility
+	cniSocketPath := consts.UnixSocketScheme + grpcSocketPath
+	if grpcSocketPath == "" {
+		cniSocketPath = fmt.Sprintf("127.0.0.1:%d", grpcPort)
+	}
+
+	// the cni plugin records pod sandboxes only for the agent to attach running pods to gateways
+	sandboxDir := ""
+	if enablePodNicAgent {
+		sandboxDir = consts.CNISandboxDir
+	}
+	// tunnel address ranges of the node are only read at startup
+	ipamRanges, err := getIPAMRanges(ctx, k8sCluster.GetAPIReader())
+	if err != nil {
+		logger.Error(err, "failed to get ipam ranges of the node")
+		os.Exit(1)
+	}
+	cniConfMgr, err := cniconf.NewCNIConfManager(consts.CNIConfDir, confFileName, exceptionCidrs, cniUninstallConfigMapName, k8sClient, cniSocketPath, firewallBackend, sandboxDir, ipamRanges, masterCNIConf, multusNetworkAttachment, tracingEndpoint)
 	if err != nil {
 		logger.Error(err, "failed to create cni config manager")
 		os.Exit(1)
@@ -130,10 +191,17 @@ func ServiceLauncher(cmd *cobra.Command, args []string) {
 		return nil
 	})
 
-	// Start metrics HTTP server
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("", healthgrpc.HealthCheckResponse_SERVING)
+
+	// Start metrics HTTP server, which also serves health probes since grpc server is not reachable from kubelet
+	mux := http.NewServeMux()
+	mux.Handle("/", promhttp.Handler())
+	mux.HandleFunc("/healthz", healthzHandler(healthServer))
+	mux.HandleFunc("/readyz", healthzHandler(healthServer))
 	metricsServer := &http.Server{
 		Addr:    ":" + strconv.Itoa(metricsPort),
-		Handler: promhttp.Handler(),
+		Handler: mux,
 	}
 	g.Go(func() error {
 		logger.Info("starting metrics server", "port", metricsPort)
@@ -150,9 +218,11 @@ func ServiceLauncher(cmd *cobra.Command, args []string) {
 		return metricsServer.Shutdown(shutdownCtx)
 	})
 
-	nicSvc := cnimanager.NewNicService(k8sClient)
+	nicSvc := cnimanager.NewNicService(k8sClient, k8sCluster.GetAPIReader(), os.Getenv(consts.NodeNameEnvKey))
 	server := grpc.NewServer(
 		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		// only root processes on the host, i.e. the cni plugin, may connect through the unix socket
+		grpc.Creds(cnimanager.NewPeerCredentials(0)),
 		grpc.StreamInterceptor(grpc_middleware.ChainStreamServer(
 			grpc_ctxtags.StreamServerInterceptor(),
 			grpc_zap.StreamServerInterceptor(zapLog),
@@ -161,27 +231,56 @@ func ServiceLauncher(cmd *cobra.Command, args []string) {
 		)),
 		grpc.UnaryInterceptor(grpc_middleware.ChainUnaryServer(
 			grpc_ctxtags.UnaryServerInterceptor(),
+			cnimanager.UnaryServerTracingInterceptor,
 			grpc_zap.UnaryServerInterceptor(
 				zapLog,
 				grpc_zap.WithDecider(func(fullMethodName string, err error) bool {
 					return fullMethodName != "/grpc.health.v1.Health/Check"
 				})),
 			grpc_prometheus.UnaryServerInterceptor,
+			cnimanager.UnaryServerMetricsInterceptor,
 			grpc_recovery.UnaryServerInterceptor(),
 		)),
 	)
 
-	healthServer := health.NewServer()
-	healthServer.SetServingStatus("", healthgrpc.HealthCheckResponse_SERVING)
 	healthgrpc.RegisterHealthServer(server, healthServer)
 
 	cniprotocol.RegisterNicServiceServer(server, nicSvc)
-	var listener net.Listener
-	listener, err = net.Listen("tcp", fmt.Sprintf(":%d", grpcPort))
+	gwConfigInformer, err := k8sCluster.GetCache().GetInformer(ctx, &current.StaticGatewayConfiguration{})
+	if err != nil {
+		logger.Error(err, "failed to get StaticGatewayConfiguration informer")
+		os.Exit(1)
+	}
+	if _, err := gwConfigInformer.AddEventHandler(nicSvc.GatewayEventHandler()); err != nil {
+		logger.Error(err, "failed to add StaticGatewayConfiguration event handler")
+		os.Exit(1)
+	}
+	gwStatusInformer, err := k8sCluster.GetCache().GetInformer(ctx, &current.GatewayStatus{})
 	if err != nil {
-		logger.Error(err, "failed to listen")
+		logger.Error(err, "failed to get GatewayStatus informer")
 		os.Exit(1)
 	}
+	if _, err := gwStatusInformer.AddEventHandler(nicSvc.GatewayStatusEventHandler()); err != nil {
+		logger.Error(err, "failed to add GatewayStatus event handler")
+		os.Exit(1)
+	}
+	var listeners []net.Listener
+	if grpcSocketPath != "" {
+		listener, err := listenUnixSocket(grpcSocketPath)
+		if err != nil {
+			logger.Error(err, "failed to listen on unix socket", "path", grpcSocketPath)
+			os.Exit(1)
+		}
+		listeners = append(listeners, listener)
+	}
+	if grpcPort != 0 {
+		listener, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(grpcPort)))
+		if err != nil {
+			logger.Error(err, "failed to listen")
+			os.Exit(1)
+		}
+		listeners = append(listeners, listener)
+	}
 
 	g.Go(func() error {
 		<-ctx.Done()
@@ -189,9 +288,55 @@ func ServiceLauncher(cmd *cobra.Command, args []string) {
 		server.GracefulStop()
 		return nil
 	})
-	err = server.Serve(listener)
-	if err != nil {
-		logger.Error(err, "failed to serve")
+	for _, listener := range listeners {
+		g.Go(func() error {
+			logger.Info("starting grpc server", "address", listener.Addr().String())
+			if err := server.Serve(listener); err != nil {
+				logger.Error(err, "failed to serve", "address", listener.Addr().String())
+				return err
+			}
+			return nil
+		})
+	}
+	if enablePodNicAgent {
+		// the agent watches gateways through the same endpoint as the cni plugin
+		conn, err := grpc.NewClient(cniSocketPath, grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithStatsHandler(otelgrpc.NewClientHandler()))
+		if err != nil {
+			logger.Error(err, "failed to create grpc client for pod nic agent")
+			os.Exit(1)
+		}
+		defer func() { _ = conn.Close() }()
+		nicClient := cniprotocol.NewNicServiceClient(conn)
+		agent := cnimanager.NewPodNicAgent(k8sCluster.GetAPIReader(), nicClient, os.Getenv(consts.NodeNameEnvKey), cnimanager.NewPodNicUpdater(cniConfMgr.ExceptionCidrs()))
+		g.Go(func() error {
+			logger.Info("starting pod nic agent")
+			return agent.Start(log.IntoContext(ctx, logger.WithName("pod-nic-agent")))
+		})
+
+		// firewall backend must be detected in host network namespace, as the cni plugin does
+		backend, _ := netfilter.ParseBackend(firewallBackend)
+		// running pods are allocated tunnel addresses from the same store as kube-egress-cni-ipam
+		var pool *ipam.Pool
+		if ranges := cniConfMgr.IPAMRanges(); len(ranges) > 0 {
+			if pool, err = ipam.NewPool(ipam.PoolConfig{Ranges: ranges}); err != nil {
+				logger.Error(err, "failed to create ipam pool")
+				os.Exit(1)
+			}
+		}
+		sandboxAgent := cnimanager.NewSandboxAgent(k8sClient, sandbox.NewStore(sandboxDir), cnimanager.NewPodAttacher(nicClient, cniConfMgr.ExceptionCidrs(), netfilter.DetectBackend(backend), pool))
+		podInformer, err := k8sCluster.GetCache().GetInformer(ctx, &corev1.Pod{})
+		if err != nil {
+			logger.Error(err, "failed to get pod informer")
+			os.Exit(1)
+		}
+		if _, err := podInformer.AddEventHandler(sandboxAgent.PodEventHandler()); err != nil {
+			logger.Error(err, "failed to add pod event handler")
+			os.Exit(1)
+		}
+		g.Go(func() error {
+			logger.Info("starting pod sandbox agent")
+			return sandboxAgent.Start(log.IntoContext(ctx, logger.WithName("pod-sandbox-agent")))
+		})
 	}
 	// wait for all context to be done
 	err = g.Wait()
@@ -221,6 +366,16 @@ func getKubeCluster(ctx context.Context, logger logr.Logger) cluster.Cluster {
 					// we only watch the node where cniManager pod is running
 					Field: fields.OneTermEqualSelector("metadata.name", os.Getenv(consts.NodeNameEnvKey)),
 				},
+				&corev1.Pod{}: {
+					// we only watch pods scheduled to the node where cniManager pod is running
+					Field: fields.OneTermEqualSelector("spec.nodeName", os.Getenv(consts.NodeNameEnvKey)),
+				},
+			},
+		}
+		options.Client = client.Options{
+			Cache: &client.CacheOptions{
+				// podEndpoints of all nodes are not worth caching, they are only read before being written
+				DisableFor: []client.Object{&current.PodEndpoint{}},
 			},
 		}
 	})
@@ -231,6 +386,15 @@ func getKubeCluster(ctx context.Context, logger logr.Logger) cluster.Cluster {
 	return k8sCluster
 }
 
+// getIPAMRanges returns the tunnel address ranges annotated on the node where cniManager pod is running.
+func getIPAMRanges(ctx context.Context, apiReader client.Reader) (string, error) {
+	node := &corev1.Node{}
+	if err := apiReader.Get(ctx, client.ObjectKey{Name: os.Getenv(consts.NodeNameEnvKey)}, node); err != nil {
+		return "", err
+	}
+	return node.GetAnnotations()[consts.IPAMRangesAnnotationKey], nil
+}
+
 func startKubeCluster(ctx context.Context, k8sCluster cluster.Cluster, handler toolscache.ResourceEventHandler, logger logr.Logger) {
 	nodeInformer, err := k8sCluster.GetCache().GetInformer(ctx, &corev1.Node{})
 	if err != nil {
@@ -243,6 +407,14 @@ func startKubeCluster(ctx context.Context, k8sCluster cluster.Cluster, handler t
 		os.Exit(1)
 	}
 
+	// start pod and StaticGatewayConfiguration informers before serving, so that cni requests are served from cache
+	for _, obj := range []client.Object{&corev1.Pod{}, &current.StaticGatewayConfiguration{}} {
+		if _, err := k8sCluster.GetCache().GetInformer(ctx, obj); err != nil {
+			logger.Error(err, "failed to get informer", "type", fmt.Sprintf("%T", obj))
+			os.Exit(1)
+		}
+	}
+
 	go func() {
 		if err := k8sCluster.Start(ctx); err != nil {
 			logger.Error(err, "failed to start k8s client cache")
@@ -283,3 +455,34 @@ func removeNodeTaintIfNeeded(obj interface{}, isReady func() bool, k8sClient cli
 		}
 	}
 }
+
+// listenUnixSocket listens on the unix socket at path, replacing the socket left by a previous run.
+// The socket is only accessible by root.
+func listenUnixSocket(path string) (net.Listener, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create socket directory: %w", err)
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to remove stale socket: %w", err)
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		_ = listener.Close()
+		return nil, fmt.Errorf("failed to set socket permission: %w", err)
+	}
+	return listener, nil
+}
+
+func healthzHandler(healthServer *health.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp, err := healthServer.Check(r.Context(), &healthgrpc.HealthCheckRequest{})
+		if err != nil || resp.GetStatus() != healthgrpc.HealthCheckResponse_SERVING {
+			http.Error(w, "not serving", http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}
+}
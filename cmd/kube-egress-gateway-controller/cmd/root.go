@@ -186,6 +186,21 @@ func startControllers(cmd *cobra.Command, args []string) {
 		setupLog.Error(err, "unable to create controller", "controller", "GatewayVMConfiguration")
 		os.Exit(1)
 	}
+	if err = (&controllers.EgressIPPoolReconciler{
+		Client:       mgr.GetClient(),
+		AzureManager: az,
+		Recorder:     mgr.GetEventRecorderFor("egressIPPool-controller"), //nolint:staticcheck // GetEventRecorderFor is deprecated but still functional; migrating to the new events API is out of scope for the dependency bump
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "EgressIPPool")
+		os.Exit(1)
+	}
+	if err = (&controllers.EgressIPInventoryReconciler{
+		Client:   mgr.GetClient(),
+		Recorder: mgr.GetEventRecorderFor("egressIPInventory-controller"), //nolint:staticcheck // GetEventRecorderFor is deprecated but still functional; migrating to the new events API is out of scope for the dependency bump
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "EgressIPInventory")
+		os.Exit(1)
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
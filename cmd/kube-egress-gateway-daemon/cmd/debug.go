@@ -0,0 +1,56 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	controllers "github.com/Azure/kube-egress-gateway/controllers/daemon"
+)
+
+// debugCmd prints the diagnostics report of the daemon running on this node, it runs in the daemon container,
+// e.g. with kubectl exec, as it needs the token the daemon writes at startup.
+var debugCmd = &cobra.Command{
+	Use:   "debug",
+	Short: "Print the diagnostics report of the daemon running on this node",
+	Long: `Print the diagnostics report of the daemon running on this node as JSON: links, addresses, routes, rules,
+firewall rules and wireguard devices of the host and gateway network namespaces, gateway LB probe state and the
+last reconcile errors. Secret keys are redacted. It must run in the daemon container to read the debug token.`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		token, err := os.ReadFile(debugTokenFile)
+		if err != nil {
+			return fmt.Errorf("failed to read debug token: %w", err)
+		}
+		url := "http://" + net.JoinHostPort("127.0.0.1", strconv.Itoa(debugPort)) + controllers.DiagnosticsPath
+		req, err := http.NewRequestWithContext(cmd.Context(), http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+		resp, err := (&http.Client{Timeout: time.Minute}).Do(req)
+		if err != nil {
+			return fmt.Errorf("failed to get diagnostics report: %w", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("failed to get diagnostics report: %s", resp.Status)
+		}
+		_, err = io.Copy(cmd.OutOrStdout(), resp.Body)
+		return err
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(debugCmd)
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/spf13/viper"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
@@ -24,6 +25,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
@@ -31,6 +33,9 @@ import (
 	controllers "github.com/Azure/kube-egress-gateway/controllers/daemon"
 	"github.com/Azure/kube-egress-gateway/pkg/consts"
 	"github.com/Azure/kube-egress-gateway/pkg/healthprobe"
+	"github.com/Azure/kube-egress-gateway/pkg/metrics"
+	"github.com/Azure/kube-egress-gateway/pkg/netfilter"
+	"github.com/Azure/kube-egress-gateway/pkg/tracing"
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -58,6 +63,17 @@ var (
 	gatewayLBProbePort       int
 	lbProbeDrainDelaySeconds int
 	secretNamespace          string
+	firewallBackend          string
+	enableDriftMonitor       bool
+	checkpointFile           string
+	drainAdminPort           int
+	drainConntrackThreshold  int
+	drainTimeoutSeconds      int
+	dataPathCheckSeconds     int
+	dataPathProbeAddress     string
+	debugPort                int
+	debugTokenFile           string
+	tracingEndpoint          string
 	zapOpts                  = zap.Options{
 		Development: true,
 	}
@@ -81,6 +97,24 @@ func init() {
 	rootCmd.Flags().IntVar(&gatewayLBProbePort, "gateway-lb-probe-port", 8082, "The port the gateway lb probe endpoint binds to.")
 	rootCmd.Flags().IntVar(&lbProbeDrainDelaySeconds, "lb-probe-drain-delay-seconds", 10, "Seconds to wait after marking LB probe unhealthy before shutting down (allows LB to drain traffic).")
 	rootCmd.Flags().StringVar(&secretNamespace, "secret-namespace", os.Getenv(consts.PodNamespaceEnvKey), "The namespace to retrieve server privateKey secrets")
+	rootCmd.Flags().StringVar(&firewallBackend, "firewall-backend", string(netfilter.BackendAuto), "The backend to program NAT rules with, one of auto, iptables or nftables. auto uses nftables unless legacy iptables is in use on the node.")
+
+	rootCmd.Flags().BoolVar(&enableDriftMonitor, "enable-drift-monitor", true, "Watch netlink and netfilter changes on the node and repair out-of-band changes to gateway network configuration right away instead of on the next resync.")
+
+	rootCmd.Flags().StringVar(&checkpointFile, "checkpoint-file", "/var/lib/kube-egress-gateway/daemon-checkpoint.json", "The file to checkpoint the applied gateway network configuration to, so that the daemon restarts without disrupting traffic. Empty value disables checkpointing.")
+
+	rootCmd.Flags().IntVar(&drainAdminPort, "drain-admin-port", 8083, "The localhost port of the admin endpoint to drain gateways on this node. 0 disables the endpoint.")
+	rootCmd.Flags().IntVar(&drainConntrackThreshold, "drain-conntrack-threshold", 0, "The number of connections through a draining gateway at or below which the drain completes.")
+	rootCmd.Flags().IntVar(&drainTimeoutSeconds, "drain-timeout-seconds", int(controllers.DefaultDrainTimeout.Seconds()), "Seconds after which a gateway drain completes even if connections are still active.")
+
+	rootCmd.Flags().IntVar(&dataPathCheckSeconds, "datapath-check-interval-seconds", int(controllers.DefaultDataPathCheckInterval.Seconds()), "Seconds between data path checks of the gateways, a gateway failing any check is reported unhealthy to the LB. 0 disables the checks.")
+	rootCmd.Flags().StringVar(&dataPathProbeAddress, "datapath-probe-address", "", "The host:port to connect to from the gateway namespace in data path checks. Empty value disables the active probe.")
+
+	rootCmd.Flags().StringVar(&tracingEndpoint, "tracing-endpoint", "", "The OTLP/HTTP endpoint, e.g. http://127.0.0.1:4318, spans of programming pod peers are exported to. Empty value disables tracing.")
+
+	// shared with the debug command
+	rootCmd.PersistentFlags().IntVar(&debugPort, "debug-port", 8084, "The localhost port of the debug endpoint serving the diagnostics report of this node. 0 disables the endpoint.")
+	rootCmd.PersistentFlags().StringVar(&debugTokenFile, "debug-token-file", "/var/run/kube-egress-gateway-daemon/debug-token", "The file the bearer token of the debug endpoint is written to at startup.")
 
 	zapOpts.BindFlags(goflag.CommandLine)
 	rootCmd.Flags().AddGoFlagSet(goflag.CommandLine)
@@ -98,6 +132,20 @@ func startControllers(cmd *cobra.Command, args []string) {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&zapOpts)))
 
+	// pod peers continue the traces of pod attach started by the cni plugin
+	shutdownTracing, err := tracing.Setup("kube-egress-gateway-daemon", tracingEndpoint)
+	if err != nil {
+		setupLog.Error(err, "unable to set up tracing")
+		os.Exit(1)
+	}
+	defer func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(shutdownCtx); err != nil {
+			setupLog.Error(err, "unable to flush spans")
+		}
+	}()
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Cache: cache.Options{
 			// we only watch secrets in the namespace where the kube-egress-gateway pods are running
@@ -105,6 +153,10 @@ func startControllers(cmd *cobra.Command, args []string) {
 				&corev1.Secret{}: {
 					Field: client.InNamespace(secretNamespace).AsSelector(),
 				},
+				// we only need the node the daemon runs on
+				&corev1.Node{}: {
+					Field: fields.OneTermEqualSelector("metadata.name", os.Getenv(consts.NodeNameEnvKey)),
+				},
 			},
 		},
 		Scheme: scheme,
@@ -125,6 +177,14 @@ func startControllers(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 
+	backend, err := netfilter.ParseBackend(firewallBackend)
+	if err != nil {
+		setupLog.Error(err, "invalid firewall backend")
+		os.Exit(1)
+	}
+	backend = netfilter.DetectBackend(backend)
+	setupLog.Info("using firewall backend", "backend", backend)
+
 	lbProbeServer := healthprobe.NewLBProbeServer(gatewayLBProbePort, time.Duration(lbProbeDrainDelaySeconds)*time.Second)
 	if err := mgr.Add(manager.RunnableFunc(lbProbeServer.Start)); err != nil {
 		setupLog.Error(err, "unbaled to set up gateway health probe server")
@@ -132,19 +192,85 @@ func startControllers(cmd *cobra.Command, args []string) {
 	}
 
 	gwCleanupEvents := make(chan event.GenericEvent)
-	if err = (&controllers.StaticGatewayConfigurationReconciler{
-		Client:        mgr.GetClient(),
-		TickerEvents:  gwCleanupEvents,
-		LBProbeServer: lbProbeServer,
-	}).SetupWithManager(mgr); err != nil {
+	peerCleanupEvents := make(chan event.GenericEvent)
+
+	var driftMonitor *controllers.DriftMonitor
+	if enableDriftMonitor {
+		ctrlmetrics.Registry.MustRegister(metrics.GatewayDaemonDriftRepairedCount)
+		driftMonitor = &controllers.DriftMonitor{
+			Client:          mgr.GetClient(),
+			GatewayEvents:   gwCleanupEvents,
+			PeerEvents:      peerCleanupEvents,
+			FirewallBackend: backend,
+		}
+		if err := driftMonitor.SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to set up drift monitor")
+			os.Exit(1)
+		}
+	}
+
+	var checkpoint *controllers.Checkpoint
+	if checkpointFile != "" {
+		checkpoint = controllers.NewCheckpoint(checkpointFile)
+		if err := checkpoint.Load(); err != nil {
+			// start from scratch rather than refusing to start
+			setupLog.Error(err, "unable to load checkpoint, ignoring it")
+			checkpoint = controllers.NewCheckpoint(checkpointFile)
+		}
+	}
+
+	drainer := &controllers.Drainer{
+		Client:             mgr.GetClient(),
+		GatewayEvents:      gwCleanupEvents,
+		AdminPort:          drainAdminPort,
+		ConntrackThreshold: drainConntrackThreshold,
+		Timeout:            time.Duration(drainTimeoutSeconds) * time.Second,
+	}
+	if err := drainer.SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to set up gateway drainer")
+		os.Exit(1)
+	}
+
+	diagnostics := &controllers.Diagnostics{
+		Port:            debugPort,
+		TokenFile:       debugTokenFile,
+		LBProbeServer:   lbProbeServer,
+		FirewallBackend: backend,
+	}
+	if err := diagnostics.SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to set up debug endpoint")
+		os.Exit(1)
+	}
+
+	warnings := controllers.NewWarningRecorder(mgr.GetEventRecorderFor("gateway-daemon"), controllers.DefaultWarningInterval) //nolint:staticcheck // GetEventRecorderFor is deprecated but still functional; migrating to the new events API is out of scope
+
+	gwReconciler := &controllers.StaticGatewayConfigurationReconciler{
+		Client:          mgr.GetClient(),
+		TickerEvents:    gwCleanupEvents,
+		LBProbeServer:   lbProbeServer,
+		FirewallBackend: backend,
+		DriftMonitor:    driftMonitor,
+		Checkpoint:      checkpoint,
+		Drainer:         drainer,
+		Diagnostics:     diagnostics,
+		Events:          warnings,
+
+		DataPathProbeAddress: dataPathProbeAddress,
+	}
+	if err = gwReconciler.SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "StaticGatewayConfiguration")
 		os.Exit(1)
 	}
+	ctrlmetrics.Registry.MustRegister(metrics.GatewayDaemonSNATPortUtilization)
+	lbProbeServer.SetChecker(gwReconciler, time.Duration(dataPathCheckSeconds)*time.Second)
 
-	peerCleanupEvents := make(chan event.GenericEvent)
 	if err = (&controllers.PodEndpointReconciler{
 		Client:       mgr.GetClient(),
 		TickerEvents: peerCleanupEvents,
+		DriftMonitor: driftMonitor,
+		Checkpoint:   checkpoint,
+		Diagnostics:  diagnostics,
+		Events:       warnings,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "PodEndpoint")
 		os.Exit(1)
@@ -162,6 +288,10 @@ func startControllers(cmd *cobra.Command, args []string) {
 
 	setupLog.Info("starting manager")
 	ctx := ctrl.SetupSignalHandler()
+	if err := gwReconciler.RestoreCheckpoint(ctx); err != nil {
+		// gateways are reported healthy after their first reconcile instead
+		setupLog.Error(err, "unable to restore gateways from checkpoint")
+	}
 	startCleanupTicker(ctx, gwCleanupEvents, 1*time.Minute)   // clean up gwConfig network namespace every 1 min
 	startCleanupTicker(ctx, peerCleanupEvents, 1*time.Minute) // clean up wireguard peer configurations every 1 min
 	if err := mgr.Start(ctx); err != nil {
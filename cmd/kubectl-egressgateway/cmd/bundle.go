@@ -0,0 +1,156 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+package cmd
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// daemonPodLabel selects the gateway daemon pods
+	daemonPodLabel = "kube-egress-gateway-control-plane"
+	// daemonPodLabelValue is the value of daemonPodLabel on the gateway daemon pods
+	daemonPodLabelValue = "daemon-manager"
+	// daemonContainer is the container of the gateway daemon pods running the daemon
+	daemonContainer = "daemon"
+)
+
+var (
+	bundleFile      string
+	bundleDebugPort int
+	bundleTimeout   time.Duration
+)
+
+// runDebug runs the debug command in a daemon pod and returns its output, it is replaced in tests.
+var runDebug = func(ctx context.Context, pod *corev1.Pod) ([]byte, error) {
+	args := []string{"exec", "--namespace", pod.Namespace, pod.Name, "--container", daemonContainer}
+	if kubeconfig != "" {
+		args = append(args, "--kubeconfig", kubeconfig)
+	}
+	if kubeContext != "" {
+		args = append(args, "--context", kubeContext)
+	}
+	args = append(args, "--", "/kube-egress-gateway-daemon", "debug", "--debug-port="+strconv.Itoa(bundleDebugPort))
+	stderr := bytes.NewBuffer(nil)
+	cmd := exec.CommandContext(ctx, "kubectl", args...)
+	cmd.Stderr = stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return output, nil
+}
+
+var bundleCmd = &cobra.Command{
+	Use:   "bundle",
+	Short: "Collect the diagnostics report of every gateway node into a tarball for support cases",
+	Long: `Collect the diagnostics report of every gateway node into a gzipped tarball for support cases. The report is
+read from the debug endpoint of each gateway daemon with kubectl exec, so kubectl must be in PATH and allowed to
+exec into the daemon pods. Daemon pods are looked up in all namespaces unless --namespace is set. The tarball has
+one <node>.json file per gateway node and errors.txt listing the nodes that failed. Secret keys are redacted by
+the daemons.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, _, err := newClient()
+		if err != nil {
+			return err
+		}
+		file := bundleFile
+		if file == "" {
+			file = fmt.Sprintf("egressgateway-bundle-%s.tar.gz", time.Now().UTC().Format("20060102-150405"))
+		}
+		out, err := os.Create(file)
+		if err != nil {
+			return fmt.Errorf("failed to create bundle file: %w", err)
+		}
+		defer out.Close()
+		nodes, failed, err := writeBundle(cmd.Context(), c, out)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Wrote %s with the reports of %d gateway nodes, %d failed\n", file, nodes, failed)
+		return nil
+	},
+}
+
+func init() {
+	bundleCmd.Flags().StringVarP(&bundleFile, "file", "f", "", "Path of the tarball to write. Empty value writes egressgateway-bundle-<time>.tar.gz in the current directory.")
+	bundleCmd.Flags().IntVar(&bundleDebugPort, "debug-port", 8084, "The debug port of the gateway daemons.")
+	bundleCmd.Flags().DurationVar(&bundleTimeout, "timeout", time.Minute, "Timeout to collect the report of each gateway node.")
+	rootCmd.AddCommand(bundleCmd)
+}
+
+// writeBundle writes the reports of all gateway daemon pods to w as a gzipped tarball and returns the number of
+// reports collected and failed. Pods failing to report are listed in errors.txt instead.
+func writeBundle(ctx context.Context, c client.Client, w io.Writer) (int, int, error) {
+	podList := &corev1.PodList{}
+	listOpts := []client.ListOption{client.MatchingLabels{daemonPodLabel: daemonPodLabelValue}}
+	if namespace != "" {
+		listOpts = append(listOpts, client.InNamespace(namespace))
+	}
+	if err := c.List(ctx, podList, listOpts...); err != nil {
+		return 0, 0, fmt.Errorf("failed to list gateway daemon pods: %w", err)
+	}
+
+	gzipWriter := gzip.NewWriter(w)
+	tarWriter := tar.NewWriter(gzipWriter)
+	addFile := func(name string, data []byte) error {
+		if err := tarWriter.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(len(data)), ModTime: time.Now()}); err != nil {
+			return err
+		}
+		_, err := tarWriter.Write(data)
+		return err
+	}
+
+	var reports int
+	errs := bytes.NewBuffer(nil)
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		name := pod.Spec.NodeName
+		if name == "" {
+			name = pod.Name
+		}
+		if pod.Status.Phase != corev1.PodRunning {
+			fmt.Fprintf(errs, "%s: pod %s/%s is %s\n", name, pod.Namespace, pod.Name, pod.Status.Phase)
+			continue
+		}
+		podCtx, cancel := context.WithTimeout(ctx, bundleTimeout)
+		report, err := runDebug(podCtx, pod)
+		cancel()
+		if err != nil {
+			fmt.Fprintf(errs, "%s: pod %s/%s: %v\n", name, pod.Namespace, pod.Name, err)
+			continue
+		}
+		if err := addFile(name+".json", report); err != nil {
+			return 0, 0, fmt.Errorf("failed to write bundle: %w", err)
+		}
+		reports++
+	}
+	failed := len(podList.Items) - reports
+	if errs.Len() > 0 {
+		if err := addFile("errors.txt", errs.Bytes()); err != nil {
+			return 0, 0, fmt.Errorf("failed to write bundle: %w", err)
+		}
+	}
+	if err := tarWriter.Close(); err != nil {
+		return 0, 0, fmt.Errorf("failed to write bundle: %w", err)
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return 0, 0, fmt.Errorf("failed to write bundle: %w", err)
+	}
+	return reports, failed, nil
+}
@@ -0,0 +1,79 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+package cmd
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestWriteBundle(t *testing.T) {
+	daemonPod := func(name, node string, phase corev1.PodPhase) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "kube-egress-gateway-system", Labels: map[string]string{daemonPodLabel: daemonPodLabelValue}},
+			Spec:       corev1.PodSpec{NodeName: node},
+			Status:     corev1.PodStatus{Phase: phase},
+		}
+	}
+	other := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "default"}, Status: corev1.PodStatus{Phase: corev1.PodRunning}}
+	c := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(
+		daemonPod("daemon-1", "gw1", corev1.PodRunning),
+		daemonPod("daemon-2", "gw2", corev1.PodRunning),
+		daemonPod("daemon-3", "gw3", corev1.PodPending),
+		other,
+	).Build()
+
+	origRunDebug := runDebug
+	defer func() { runDebug = origRunDebug }()
+	runDebug = func(ctx context.Context, pod *corev1.Pod) ([]byte, error) {
+		if pod.Name == "daemon-2" {
+			return nil, errors.New("connection refused")
+		}
+		return []byte(`{"nodeName":"` + pod.Spec.NodeName + `"}`), nil
+	}
+
+	buf := bytes.NewBuffer(nil)
+	reports, failed, err := writeBundle(context.Background(), c, buf)
+	if err != nil {
+		t.Fatalf("writeBundle returns unexpected error: %v", err)
+	}
+	if reports != 1 || failed != 2 {
+		t.Fatalf("writeBundle returns unexpected counts: reports %d, failed %d", reports, failed)
+	}
+
+	gzipReader, err := gzip.NewReader(buf)
+	if err != nil {
+		t.Fatalf("bundle is not gzipped: %v", err)
+	}
+	files := map[string]string{}
+	tarReader := tar.NewReader(gzipReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("bundle is not a tarball: %v", err)
+		}
+		data, _ := io.ReadAll(tarReader)
+		files[header.Name] = string(data)
+	}
+	expected := map[string]string{
+		"gw1.json":   `{"nodeName":"gw1"}`,
+		"errors.txt": "gw2: pod kube-egress-gateway-system/daemon-2: connection refused\ngw3: pod kube-egress-gateway-system/daemon-3 is Pending\n",
+	}
+	if !reflect.DeepEqual(files, expected) {
+		t.Fatalf("bundle has unexpected files: got: %v, expected: %v", files, expected)
+	}
+}
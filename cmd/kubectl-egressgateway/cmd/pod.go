@@ -0,0 +1,95 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Azure/kube-egress-gateway/pkg/inspect"
+)
+
+var podCmd = &cobra.Command{
+	Use:   "pod <[namespace/]pod>",
+	Short: "Show the StaticGatewayConfiguration, gateway nodes, peer presence and egress IP of a pod",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, defaultNamespace, err := newClient()
+		if err != nil {
+			return err
+		}
+		key, err := objectKey(args[0], defaultNamespace)
+		if err != nil {
+			return err
+		}
+		report, err := inspect.GetPodReport(cmd.Context(), c, key)
+		if err != nil {
+			return fmt.Errorf("failed to get pod %s: %w", key, err)
+		}
+		return print(cmd.OutOrStdout(), report, func(w io.Writer) { printPodReport(w, report) })
+	},
+}
+
+var whyCmd = &cobra.Command{
+	Use:   "why <[namespace/]pod>",
+	Short: "Explain the first broken link from a pod to its egress gateway",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, defaultNamespace, err := newClient()
+		if err != nil {
+			return err
+		}
+		key, err := objectKey(args[0], defaultNamespace)
+		if err != nil {
+			return err
+		}
+		diagnosis, err := inspect.Diagnose(cmd.Context(), c, key)
+		if err != nil {
+			return fmt.Errorf("failed to diagnose pod %s: %w", key, err)
+		}
+		return print(cmd.OutOrStdout(), diagnosis, func(w io.Writer) {
+			if diagnosis.Healthy {
+				fmt.Fprintf(w, "OK: %s\n", diagnosis.Message)
+			} else {
+				fmt.Fprintf(w, "Broken at %s: %s\n", diagnosis.Check, diagnosis.Message)
+			}
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(podCmd)
+	rootCmd.AddCommand(whyCmd)
+}
+
+func printPodReport(w io.Writer, report *inspect.PodReport) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "Pod:\t%s/%s\n", report.Namespace, report.Name)
+	fmt.Fprintf(tw, "Node:\t%s\n", valueOrNone(report.NodeName))
+	fmt.Fprintf(tw, "StaticGatewayConfiguration:\t%s\n", valueOrNone(report.Gateway))
+	if report.Gateway == "" {
+		_ = tw.Flush()
+		return
+	}
+	fmt.Fprintf(tw, "StaticGatewayConfiguration found:\t%s\n", yesNo(report.GatewayFound))
+	fmt.Fprintf(tw, "Egress IP prefix:\t%s\n", valueOrNone(report.EgressIpPrefix))
+	fmt.Fprintf(tw, "PodEndpoint found:\t%s\n", yesNo(report.PodEndpointFound))
+	fmt.Fprintf(tw, "Pod tunnel IP:\t%s\n", valueOrNone(report.PodIpAddress))
+	fmt.Fprintf(tw, "Pod public key:\t%s\n", valueOrNone(report.PodPublicKey))
+	_ = tw.Flush()
+
+	fmt.Fprintln(w)
+	tw = tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "GATEWAY NODE\tREADY\tPEER\tEGRESS IP")
+	for _, node := range report.GatewayNodes {
+		egressIP := node.EgressIP
+		if report.EgressIpPrefix != "" {
+			egressIP = report.EgressIpPrefix
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", node.Name, yesNo(node.Ready), yesNo(node.PeerPresent), valueOrNone(egressIP))
+	}
+	_ = tw.Flush()
+}
@@ -0,0 +1,127 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	current "github.com/Azure/kube-egress-gateway/api/v1alpha1"
+)
+
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+var (
+	kubeconfig  string
+	kubeContext string
+	namespace   string
+	output      string
+)
+
+// rootCmd represents the base command when called without any subcommands
+var rootCmd = &cobra.Command{
+	Use:   "kubectl-egressgateway",
+	Short: "Inspect kube-egress-gateway static gateways and the pods using them",
+	Long: `Inspect kube-egress-gateway static gateways and the pods using them, joining StaticGatewayConfiguration,
+GatewayLBConfiguration, GatewayVMConfiguration, secrets, PodEndpoints and GatewayStatuses of gateway nodes.
+Nothing is changed in the cluster: only API objects are read, except by bundle which also reads the diagnostics
+report of gateway daemons with kubectl exec. Installed in PATH, it runs as "kubectl egressgateway".`,
+	SilenceUsage: true,
+}
+
+// Execute adds all child commands to the root command and sets flags appropriately.
+// This is called by main.main(). It only needs to happen once to the rootCmd.
+func Execute() {
+	err := rootCmd.Execute()
+	if err != nil {
+		os.Exit(1)
+	}
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&kubeconfig, "kubeconfig", "", "Path to the kubeconfig file. Empty value uses KUBECONFIG or ~/.kube/config.")
+	rootCmd.PersistentFlags().StringVar(&kubeContext, "context", "", "The kubeconfig context to use.")
+	rootCmd.PersistentFlags().StringVarP(&namespace, "namespace", "n", "", "Namespace of the object when the name is not in <namespace>/<name> format. Empty value uses the kubeconfig context namespace.")
+	rootCmd.PersistentFlags().StringVarP(&output, "output", "o", outputText, "Output format, one of text or json.")
+}
+
+func newClient() (client.Client, string, error) {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = kubeconfig
+	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, &clientcmd.ConfigOverrides{CurrentContext: kubeContext})
+	restConfig, err := clientConfig.ClientConfig()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to load kubeconfig: %w", err)
+	}
+	defaultNamespace, _, err := clientConfig.Namespace()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get namespace from kubeconfig: %w", err)
+	}
+
+	scheme := runtime.NewScheme()
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(current.AddToScheme(scheme))
+	c, err := client.New(restConfig, client.Options{Scheme: scheme})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create k8s client: %w", err)
+	}
+	return c, defaultNamespace, nil
+}
+
+// objectKey parses name in <namespace>/<name> or <name> format, the latter in the namespace of the flag or of
+// the kubeconfig context.
+func objectKey(name, defaultNamespace string) (types.NamespacedName, error) {
+	if ns, n, ok := strings.Cut(name, "/"); ok {
+		if ns == "" || n == "" {
+			return types.NamespacedName{}, fmt.Errorf("%q is not in <namespace>/<name> format", name)
+		}
+		return types.NamespacedName{Namespace: ns, Name: n}, nil
+	}
+	if namespace != "" {
+		defaultNamespace = namespace
+	}
+	return types.NamespacedName{Namespace: defaultNamespace, Name: name}, nil
+}
+
+// print writes report as json if requested, otherwise with printText.
+func print(w io.Writer, report interface{}, printText func(io.Writer)) error {
+	switch output {
+	case outputJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	case outputText:
+		printText(w)
+		return nil
+	default:
+		return fmt.Errorf("unknown output format %q, expecting text or json", output)
+	}
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+func valueOrNone(s string) string {
+	if s == "" {
+		return "<none>"
+	}
+	return s
+}
@@ -0,0 +1,64 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Azure/kube-egress-gateway/pkg/inspect"
+)
+
+var statusCmd = &cobra.Command{
+	Use:   "status <[namespace/]staticgatewayconfiguration>",
+	Short: "Show a StaticGatewayConfiguration with its derived objects and readiness per gateway node",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, defaultNamespace, err := newClient()
+		if err != nil {
+			return err
+		}
+		key, err := objectKey(args[0], defaultNamespace)
+		if err != nil {
+			return err
+		}
+		report, err := inspect.GetGatewayReport(cmd.Context(), c, key)
+		if err != nil {
+			return fmt.Errorf("failed to get StaticGatewayConfiguration %s: %w", key, err)
+		}
+		return print(cmd.OutOrStdout(), report, func(w io.Writer) { printGatewayReport(w, report) })
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(statusCmd)
+}
+
+func printGatewayReport(w io.Writer, report *inspect.GatewayReport) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "StaticGatewayConfiguration:\t%s/%s\n", report.Namespace, report.Name)
+	if report.NodepoolName != "" {
+		fmt.Fprintf(tw, "Gateway nodepool:\t%s\n", report.NodepoolName)
+	} else {
+		fmt.Fprintf(tw, "Gateway vmss:\t%s\n", valueOrNone(report.VmssName))
+	}
+	fmt.Fprintf(tw, "Frontend:\t%s:%d\n", valueOrNone(report.FrontendIP), report.Port)
+	fmt.Fprintf(tw, "Egress IP prefix:\t%s\n", valueOrNone(report.EgressIpPrefix))
+	fmt.Fprintf(tw, "Public key:\t%s\n", valueOrNone(report.PublicKey))
+	fmt.Fprintf(tw, "Private key secret:\t%s\n", yesNo(report.SecretFound))
+	fmt.Fprintf(tw, "GatewayLBConfiguration:\t%s\n", yesNo(report.LBConfigFound))
+	fmt.Fprintf(tw, "GatewayVMConfiguration:\t%s\n", yesNo(report.VMConfigFound))
+	fmt.Fprintf(tw, "PodEndpoints:\t%d\n", report.PodEndpoints)
+	_ = tw.Flush()
+
+	fmt.Fprintln(w)
+	tw = tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NODE\tPRIMARY IP\tSECONDARY IP\tREADY\tINTERFACE\tDRAIN\tPEERS\tLAST ERROR")
+	for _, node := range report.Nodes {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%d\t%s\n", node.Name, valueOrNone(node.PrimaryIP), valueOrNone(node.SecondaryIP), yesNo(node.Ready), valueOrNone(node.InterfaceName), valueOrNone(node.DrainPhase), node.Peers, valueOrNone(node.LastError))
+	}
+	_ = tw.Flush()
+}
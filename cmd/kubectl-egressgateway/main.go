@@ -0,0 +1,9 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+package main
+
+import "github.com/Azure/kube-egress-gateway/cmd/kubectl-egressgateway/cmd"
+
+func main() {
+	cmd.Execute()
+}
@@ -0,0 +1,168 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+package cnimanager
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/containernetworking/plugins/pkg/ns"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	current "github.com/Azure/kube-egress-gateway/api/v1alpha1"
+	"github.com/Azure/kube-egress-gateway/pkg/cni/routes"
+	"github.com/Azure/kube-egress-gateway/pkg/cni/wireguard"
+	cniprotocol "github.com/Azure/kube-egress-gateway/pkg/cniprotocol/v1"
+	"github.com/Azure/kube-egress-gateway/pkg/consts"
+)
+
+const (
+	// agentResyncPeriod is how often PodNicAgent lists the PodEndpoints of the node
+	agentResyncPeriod = 30 * time.Second
+	// agentRetryPeriod is how long PodNicAgent waits before watching the gateway of a pod again after a failure
+	agentRetryPeriod = 5 * time.Second
+)
+
+// PodNicUpdater updates the wireguard interface of the pod in network namespace netnsPath to match the gateway.
+type PodNicUpdater func(netnsPath string, gateway *cniprotocol.WatchGatewayResponse) error
+
+// PodNicAgent keeps the wireguard interfaces of running pods on the node in sync with their gateway. It
+// watches the gateway of every PodEndpoint of the node through WatchGateway and updates the pod nic on
+// each change, so that pods don't need to be restarted when gateway frontend, key or routes change.
+type PodNicAgent struct {
+	// k8sClient lists PodEndpoints of the node
+	k8sClient client.Reader
+	nicClient cniprotocol.NicServiceClient
+	nodeName  string
+	updateNic PodNicUpdater
+
+	wg sync.WaitGroup
+	// running watches keyed by pod, only accessed by the resync loop
+	watches map[types.NamespacedName]podWatch
+}
+
+type podWatch struct {
+	spec   current.PodEndpointSpec
+	cancel context.CancelFunc
+}
+
+func NewPodNicAgent(k8sClient client.Reader, nicClient cniprotocol.NicServiceClient, nodeName string, updateNic PodNicUpdater) *PodNicAgent {
+	return &PodNicAgent{
+		k8sClient: k8sClient,
+		nicClient: nicClient,
+		nodeName:  nodeName,
+		updateNic: updateNic,
+		watches:   make(map[types.NamespacedName]podWatch),
+	}
+}
+
+// Start watches the gateways of the pods on the node until ctx is done.
+func (a *PodNicAgent) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx)
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := a.resync(ctx); err != nil {
+			logger.Error(err, "failed to resync pod nics")
+		}
+	}, agentResyncPeriod)
+	for _, watch := range a.watches {
+		watch.cancel()
+	}
+	a.wg.Wait()
+	return nil
+}
+
+// resync starts watching the gateways of new PodEndpoints and stops watching the deleted ones.
+func (a *PodNicAgent) resync(ctx context.Context) error {
+	podEndpoints := &current.PodEndpointList{}
+	if err := a.k8sClient.List(ctx, podEndpoints, client.MatchingLabels{consts.PodEndpointNodeNameLabel: a.nodeName}); err != nil {
+		return fmt.Errorf("failed to list PodEndpoints on node %s: %w", a.nodeName, err)
+	}
+	seen := make(map[types.NamespacedName]bool)
+	for _, podEndpoint := range podEndpoints.Items {
+		if podEndpoint.Spec.NetnsPath == "" {
+			// created by an older cni plugin, the pod nic can't be found
+			continue
+		}
+		key := client.ObjectKeyFromObject(&podEndpoint)
+		seen[key] = true
+		if watch, ok := a.watches[key]; ok {
+			if watch.spec == podEndpoint.Spec {
+				continue
+			}
+			watch.cancel()
+		}
+		watchCtx, cancel := context.WithCancel(ctx)
+		a.watches[key] = podWatch{spec: podEndpoint.Spec, cancel: cancel}
+		a.wg.Add(1)
+		go func() {
+			defer a.wg.Done()
+			a.watch(watchCtx, key, podEndpoint.Spec)
+		}()
+	}
+	for key, watch := range a.watches {
+		if !seen[key] {
+			watch.cancel()
+			delete(a.watches, key)
+		}
+	}
+	return nil
+}
+
+// watch updates the pod nic on every gateway change until ctx is done, the stream is reopened on failure.
+func (a *PodNicAgent) watch(ctx context.Context, key types.NamespacedName, spec current.PodEndpointSpec) {
+	logger := log.FromContext(ctx).WithValues("pod", key, "gateway", spec.StaticGatewayConfiguration)
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := a.watchOnce(ctx, key, spec); err != nil && ctx.Err() == nil {
+			logger.Error(err, "failed to watch gateway of pod")
+		}
+	}, agentRetryPeriod)
+}
+
+func (a *PodNicAgent) watchOnce(ctx context.Context, key types.NamespacedName, spec current.PodEndpointSpec) error {
+	stream, err := a.nicClient.WatchGateway(ctx, &cniprotocol.WatchGatewayRequest{
+		PodConfig: &cniprotocol.PodInfo{
+			PodName:      key.Name,
+			PodNamespace: key.Namespace,
+			PodUid:       spec.PodUid,
+		},
+		GatewayName: spec.StaticGatewayConfiguration,
+	})
+	if err != nil {
+		return err
+	}
+	for {
+		gateway, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		if err := a.updateNic(spec.NetnsPath, gateway); err != nil {
+			return fmt.Errorf("failed to update nic in %s: %w", spec.NetnsPath, err)
+		}
+	}
+}
+
+// NewPodNicUpdater returns a PodNicUpdater updating the peer, MTU and routes of the wireguard interface of the pod.
+// excludedCIDRs are the CIDRs that should not go through the egress gateway, as in cni config.
+func NewPodNicUpdater(excludedCIDRs []string) PodNicUpdater {
+	return func(netnsPath string, gateway *cniprotocol.WatchGatewayResponse) error {
+		podNs, err := ns.GetNS(netnsPath)
+		if err != nil {
+			return fmt.Errorf("failed to get pod namespace: %w", err)
+		}
+		defer func() { _ = podNs.Close() }()
+		return podNs.Do(func(ns.NetNS) error {
+			if err := wireguard.UpdatePeer(consts.WireguardLinkName, gateway); err != nil {
+				return err
+			}
+			if err := wireguard.SetLinkMTU(consts.WireguardLinkName, int(gateway.GetMtu())); err != nil {
+				return err
+			}
+			return routes.UpdatePodRoutes(consts.WireguardLinkName, gateway, excludedCIDRs)
+		})
+	}
+}
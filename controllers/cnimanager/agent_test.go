@@ -0,0 +1,113 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+package cnimanager_test
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	current "github.com/Azure/kube-egress-gateway/api/v1alpha1"
+	"github.com/Azure/kube-egress-gateway/controllers/cnimanager"
+	cniprotocol "github.com/Azure/kube-egress-gateway/pkg/cniprotocol/v1"
+	"github.com/Azure/kube-egress-gateway/pkg/consts"
+)
+
+var _ = Describe("PodNicAgent", func() {
+	var (
+		fakeClient     client.Client
+		service        *cnimanager.NicService
+		gatewayProfile *current.StaticGatewayConfiguration
+		podEndpoint    *current.PodEndpoint
+		updates        chan string
+		startAgent     func()
+	)
+	BeforeEach(func() {
+		apischeme := runtime.NewScheme()
+		utilruntime.Must(clientgoscheme.AddToScheme(apischeme))
+		utilruntime.Must(current.AddToScheme(apischeme))
+		gatewayProfile = &current.StaticGatewayConfiguration{
+			ObjectMeta: metav1.ObjectMeta{Name: "tgw1", Namespace: "default"},
+			Status: current.StaticGatewayConfigurationStatus{
+				EgressIpPrefix: "13.66.156.240/30",
+				GatewayServerProfile: current.GatewayServerProfile{
+					Ip:        "10.0.1.1",
+					PublicKey: "somerandompublickey",
+					Port:      54321,
+				},
+			},
+		}
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "test",
+				Namespace:   "default",
+				UID:         "pod-uid-1",
+				Annotations: map[string]string{consts.CNIGatewayAnnotationKey: "tgw1"},
+			},
+			Spec: corev1.PodSpec{NodeName: "node1"},
+		}
+		podEndpoint = &current.PodEndpoint{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test",
+				Namespace: "default",
+				Labels:    map[string]string{consts.PodEndpointNodeNameLabel: "node1"},
+			},
+			Spec: current.PodEndpointSpec{
+				StaticGatewayConfiguration: "tgw1",
+				NodeName:                   "node1",
+				PodUid:                     "pod-uid-1",
+				ContainerId:                "container1",
+				NetnsPath:                  "/var/run/netns/cni-1",
+			},
+		}
+		fakeClient = fake.NewClientBuilder().WithScheme(apischeme).WithRuntimeObjects(gatewayProfile, pod).Build()
+		service = cnimanager.NewNicService(fakeClient, fakeClient, "node1")
+		updates = make(chan string, 10)
+		startAgent = func() {
+			agent := cnimanager.NewPodNicAgent(fakeClient, serveNicService(service), "node1", func(netnsPath string, gateway *cniprotocol.WatchGatewayResponse) error {
+				updates <- fmt.Sprintf("%s %s:%d", netnsPath, gateway.GetEndpointIp(), gateway.GetListenPort())
+				return nil
+			})
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				_ = agent.Start(ctx)
+			}()
+			DeferCleanup(func() {
+				cancel()
+				Eventually(done).Should(BeClosed())
+			})
+		}
+	})
+
+	It("should update pod nic when gateway changes", func() {
+		Expect(fakeClient.Create(context.Background(), podEndpoint)).To(Succeed())
+		startAgent()
+		Eventually(updates).Should(Receive(Equal("/var/run/netns/cni-1 10.0.1.1:54321")))
+
+		updated := &current.StaticGatewayConfiguration{}
+		Expect(fakeClient.Get(context.Background(), client.ObjectKeyFromObject(gatewayProfile), updated)).To(Succeed())
+		updated.Status.Ip = "10.0.1.2"
+		Expect(fakeClient.Update(context.Background(), updated)).To(Succeed())
+		service.GatewayEventHandler().OnUpdate(gatewayProfile, updated)
+		Eventually(updates).Should(Receive(Equal("/var/run/netns/cni-1 10.0.1.2:54321")))
+	})
+
+	It("should skip pod endpoints without network namespace path", func() {
+		podEndpoint.Spec.NetnsPath = ""
+		Expect(fakeClient.Create(context.Background(), podEndpoint)).To(Succeed())
+		startAgent()
+		Consistently(updates, 500*time.Millisecond).ShouldNot(Receive())
+	})
+})
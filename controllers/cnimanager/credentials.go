@@ -0,0 +1,83 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+package cnimanager
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"golang.org/x/sys/unix"
+	"google.golang.org/grpc/credentials"
+)
+
+// PeerCredAuthInfo holds the credentials of the process on the other end of a unix socket connection.
+type PeerCredAuthInfo struct {
+	credentials.CommonAuthInfo
+	Ucred *unix.Ucred
+}
+
+func (PeerCredAuthInfo) AuthType() string {
+	return "peercred"
+}
+
+// peerCredentials is a grpc transport credentials that accepts unix socket connections only from
+// processes running as allowedUID, checked with SO_PEERCRED. Connections of other networks are
+// accepted as is, they are expected to be bound to loopback interface. No transport security is
+// applied, so clients connect with insecure credentials.
+type peerCredentials struct {
+	allowedUID uint32
+}
+
+// NewPeerCredentials returns a grpc server transport credentials accepting unix socket peers running as allowedUID.
+func NewPeerCredentials(allowedUID uint32) credentials.TransportCredentials {
+	return &peerCredentials{allowedUID: allowedUID}
+}
+
+func (c *peerCredentials) ClientHandshake(_ context.Context, _ string, conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	return conn, PeerCredAuthInfo{CommonAuthInfo: credentials.CommonAuthInfo{SecurityLevel: credentials.NoSecurity}}, nil
+}
+
+func (c *peerCredentials) ServerHandshake(conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	authInfo := PeerCredAuthInfo{CommonAuthInfo: credentials.CommonAuthInfo{SecurityLevel: credentials.NoSecurity}}
+	unixConn, ok := conn.(*net.UnixConn)
+	if !ok {
+		return conn, authInfo, nil
+	}
+	ucred, err := getPeerCred(unixConn)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get peer credentials: %w", err)
+	}
+	if ucred.Uid != c.allowedUID {
+		return nil, nil, fmt.Errorf("peer process %d running as uid %d is not allowed", ucred.Pid, ucred.Uid)
+	}
+	authInfo.Ucred = ucred
+	return conn, authInfo, nil
+}
+
+func (c *peerCredentials) Info() credentials.ProtocolInfo {
+	return credentials.ProtocolInfo{SecurityProtocol: "peercred"}
+}
+
+func (c *peerCredentials) Clone() credentials.TransportCredentials {
+	return &peerCredentials{allowedUID: c.allowedUID}
+}
+
+func (c *peerCredentials) OverrideServerName(string) error {
+	return nil
+}
+
+func getPeerCred(conn *net.UnixConn) (*unix.Ucred, error) {
+	rawConn, err := conn.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+	var ucred *unix.Ucred
+	var sockErr error
+	if err := rawConn.Control(func(fd uintptr) {
+		ucred, sockErr = unix.GetsockoptUcred(int(fd), unix.SOL_SOCKET, unix.SO_PEERCRED)
+	}); err != nil {
+		return nil, err
+	}
+	return ucred, sockErr
+}
@@ -0,0 +1,58 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+package cnimanager_test
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/Azure/kube-egress-gateway/controllers/cnimanager"
+)
+
+var _ = Describe("PeerCredentials", func() {
+	accept := func(network, address string) (net.Conn, net.Conn) {
+		listener, err := net.Listen(network, address)
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(listener.Close)
+		client, err := net.Dial(network, listener.Addr().String())
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(client.Close)
+		server, err := listener.Accept()
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(server.Close)
+		return client, server
+	}
+
+	When("peer connects through unix socket", func() {
+		var server net.Conn
+		BeforeEach(func() {
+			_, server = accept("unix", filepath.Join(GinkgoT().TempDir(), "test.sock"))
+		})
+
+		It("should accept peer running as allowed uid", func() {
+			_, authInfo, err := cnimanager.NewPeerCredentials(uint32(os.Getuid())).ServerHandshake(server)
+			Expect(err).NotTo(HaveOccurred())
+			peerCred, ok := authInfo.(cnimanager.PeerCredAuthInfo)
+			Expect(ok).To(BeTrue())
+			Expect(peerCred.Ucred).NotTo(BeNil())
+			Expect(peerCred.Ucred.Pid).To(Equal(int32(os.Getpid())))
+		})
+
+		It("should reject peer running as other uid", func() {
+			_, _, err := cnimanager.NewPeerCredentials(uint32(os.Getuid()) + 1).ServerHandshake(server)
+			Expect(err).To(MatchError(ContainSubstring("is not allowed")))
+		})
+	})
+
+	When("peer connects through tcp", func() {
+		It("should accept peer", func() {
+			_, server := accept("tcp", "127.0.0.1:0")
+			_, _, err := cnimanager.NewPeerCredentials(uint32(os.Getuid()) + 1).ServerHandshake(server)
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+})
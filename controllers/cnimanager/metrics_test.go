@@ -0,0 +1,39 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+package cnimanager_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/Azure/kube-egress-gateway/controllers/cnimanager"
+	"github.com/Azure/kube-egress-gateway/pkg/metrics"
+)
+
+var _ = Describe("UnaryServerMetricsInterceptor", func() {
+	BeforeEach(func() {
+		metrics.CNIManagerRequestLatency.Reset()
+	})
+
+	It("should record latency by method and status code", func() {
+		info := &grpc.UnaryServerInfo{FullMethod: "/pkg.cniprotocol.v1.NicService/NicAdd"}
+		_, err := cnimanager.UnaryServerMetricsInterceptor(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+		_, err = cnimanager.UnaryServerMetricsInterceptor(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, status.Error(codes.PermissionDenied, "denied")
+		})
+		Expect(status.Code(err)).To(Equal(codes.PermissionDenied))
+
+		Expect(testutil.CollectAndCount(metrics.CNIManagerRequestLatency)).To(Equal(2))
+		Expect(metrics.CNIManagerRequestLatency.DeleteLabelValues("NicAdd", "OK")).To(BeTrue())
+		Expect(metrics.CNIManagerRequestLatency.DeleteLabelValues("NicAdd", "PermissionDenied")).To(BeTrue())
+	})
+})
@@ -0,0 +1,201 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+package cnimanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	type100 "github.com/containernetworking/cni/pkg/types/100"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/Azure/kube-egress-gateway/pkg/cni/attach"
+	"github.com/Azure/kube-egress-gateway/pkg/cni/ipam"
+	"github.com/Azure/kube-egress-gateway/pkg/cni/sandbox"
+	cniprotocol "github.com/Azure/kube-egress-gateway/pkg/cniprotocol/v1"
+	"github.com/Azure/kube-egress-gateway/pkg/consts"
+	"github.com/Azure/kube-egress-gateway/pkg/netfilter"
+)
+
+// PodAttacher attaches a running pod sandbox to a gateway, or detaches it from its gateway.
+type PodAttacher interface {
+	Attach(ctx context.Context, record *sandbox.Record, gwName string) error
+	Detach(ctx context.Context, record *sandbox.Record) error
+}
+
+// SandboxAgent switches running pods of the node onto a gateway, or off it, when the gateway annotation of
+// the pod is added, changed or removed, so that pods don't need to be restarted. It works on the pod sandboxes
+// recorded by the cni plugin, which carry the pod network namespace and the eth0 routes before attaching.
+type SandboxAgent struct {
+	// k8sClient reads pods of the node
+	k8sClient client.Reader
+	sandboxes *sandbox.Store
+	attacher  PodAttacher
+	// trigger requests a resync before the next period
+	trigger chan struct{}
+}
+
+func NewSandboxAgent(k8sClient client.Reader, sandboxes *sandbox.Store, attacher PodAttacher) *SandboxAgent {
+	return &SandboxAgent{
+		k8sClient: k8sClient,
+		sandboxes: sandboxes,
+		attacher:  attacher,
+		trigger:   make(chan struct{}, 1),
+	}
+}
+
+// Start attaches and detaches pod sandboxes on pod events and periodically until ctx is done.
+func (a *SandboxAgent) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx)
+	timer := time.NewTimer(agentResyncPeriod)
+	defer timer.Stop()
+	for {
+		if err := a.resync(ctx); err != nil {
+			logger.Error(err, "failed to resync pod sandboxes")
+		}
+		timer.Reset(agentResyncPeriod)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-a.trigger:
+		case <-timer.C:
+		}
+	}
+}
+
+// PodEventHandler returns the handler of pod informer events triggering a resync when the gateway
+// annotation of a pod changes.
+func (a *SandboxAgent) PodEventHandler() toolscache.ResourceEventHandler {
+	return toolscache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) { a.notify() },
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldPod, ok := oldObj.(*corev1.Pod)
+			newPod, ok2 := newObj.(*corev1.Pod)
+			if !ok || !ok2 || oldPod.Annotations[consts.CNIGatewayAnnotationKey] != newPod.Annotations[consts.CNIGatewayAnnotationKey] {
+				a.notify()
+			}
+		},
+	}
+}
+
+func (a *SandboxAgent) notify() {
+	select {
+	case a.trigger <- struct{}{}:
+	default:
+		// a resync is already pending
+	}
+}
+
+// resync attaches or detaches every recorded pod sandbox whose gateway differs from the pod annotation.
+func (a *SandboxAgent) resync(ctx context.Context) error {
+	records, err := a.sandboxes.List()
+	if err != nil {
+		return fmt.Errorf("failed to list pod sandboxes: %w", err)
+	}
+	var errs []error
+	for _, record := range records {
+		if err := a.reconcile(ctx, record); err != nil {
+			errs = append(errs, fmt.Errorf("failed to reconcile sandbox %s of pod %s/%s: %w", record.ContainerID, record.PodNamespace, record.PodName, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (a *SandboxAgent) reconcile(ctx context.Context, record *sandbox.Record) error {
+	logger := log.FromContext(ctx).WithValues("pod", record.PodNamespace+"/"+record.PodName, "containerID", record.ContainerID)
+	pod := &corev1.Pod{}
+	if err := a.k8sClient.Get(ctx, client.ObjectKey{Name: record.PodName, Namespace: record.PodNamespace}, pod); err != nil {
+		if apierrors.IsNotFound(err) {
+			// pod is being deleted, the record is removed by cni plugin
+			return nil
+		}
+		return err
+	}
+	if pod.DeletionTimestamp != nil || (record.PodUID != "" && record.PodUID != string(pod.UID)) {
+		// sandbox of a terminating pod, or of a deleted pod recreated with the same name
+		return nil
+	}
+	gwName := pod.Annotations[consts.CNIGatewayAnnotationKey]
+	if gwName == record.GatewayName {
+		return nil
+	}
+
+	if record.GatewayName != "" {
+		logger.Info("detaching pod from gateway", "gateway", record.GatewayName)
+		if err := a.attacher.Detach(ctx, record); err != nil {
+			return fmt.Errorf("failed to detach from gateway %s: %w", record.GatewayName, err)
+		}
+		if err := a.save(record); err != nil {
+			return err
+		}
+	}
+	if gwName != "" {
+		logger.Info("attaching pod to gateway", "gateway", gwName)
+		if err := a.attacher.Attach(ctx, record, gwName); err != nil {
+			return fmt.Errorf("failed to attach to gateway %s: %w", gwName, err)
+		}
+		if err := a.save(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// save updates the record unless cni plugin deleted it in the meantime, i.e. the sandbox is gone.
+func (a *SandboxAgent) save(record *sandbox.Record) error {
+	if _, err := a.sandboxes.Get(record.ContainerID); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	return a.sandboxes.Save(record)
+}
+
+type podAttacher struct {
+	nicClient       cniprotocol.NicServiceClient
+	excludedCIDRs   []string
+	firewallBackend netfilter.Backend
+	// pool allocates tunnel addresses when ipam ranges are configured, nil if pod eth0 addresses are used
+	pool *ipam.Pool
+}
+
+// NewPodAttacher returns a PodAttacher setting up pod sandboxes as the cni plugin does, through nicClient.
+// excludedCIDRs are the CIDRs that should not go through the egress gateway, as in cni config. pool is the
+// tunnel address pool shared with kube-egress-cni-ipam, nil if no ipam ranges are configured.
+func NewPodAttacher(nicClient cniprotocol.NicServiceClient, excludedCIDRs []string, firewallBackend netfilter.Backend, pool *ipam.Pool) PodAttacher {
+	return &podAttacher{
+		nicClient:       nicClient,
+		excludedCIDRs:   excludedCIDRs,
+		firewallBackend: firewallBackend,
+		pool:            pool,
+	}
+}
+
+func (p *podAttacher) Attach(ctx context.Context, record *sandbox.Record, gwName string) error {
+	return attach.Attach(ctx, p.nicClient, record, gwName, p.ipProvider(record, gwName), p.excludedCIDRs, p.firewallBackend, &type100.Result{})
+}
+
+func (p *podAttacher) Detach(ctx context.Context, record *sandbox.Record) error {
+	if err := attach.Detach(ctx, p.nicClient, record); err != nil {
+		return err
+	}
+	if p.pool == nil {
+		return nil
+	}
+	return p.pool.Release(record.ContainerID)
+}
+
+func (p *podAttacher) ipProvider(record *sandbox.Record, gwName string) ipam.IPProvider {
+	if p.pool != nil {
+		return ipam.NewPoolIPProvider(p.pool, record.ContainerID, record.PodNamespace+"/"+gwName)
+	}
+	return ipam.NewPodIPProvider(record.NetnsPath)
+}
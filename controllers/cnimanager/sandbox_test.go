@@ -0,0 +1,152 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+package cnimanager_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/Azure/kube-egress-gateway/controllers/cnimanager"
+	"github.com/Azure/kube-egress-gateway/pkg/cni/sandbox"
+	"github.com/Azure/kube-egress-gateway/pkg/consts"
+)
+
+type testAttacher struct {
+	calls chan string
+	err   error
+}
+
+func (t *testAttacher) Attach(_ context.Context, record *sandbox.Record, gwName string) error {
+	t.calls <- "attach " + record.ContainerID + " " + gwName
+	if t.err != nil {
+		return t.err
+	}
+	record.GatewayName = gwName
+	record.Eth0Routes = []sandbox.Route{{Gw: "10.244.0.1"}}
+	return nil
+}
+
+func (t *testAttacher) Detach(_ context.Context, record *sandbox.Record) error {
+	t.calls <- "detach " + record.ContainerID + " " + record.GatewayName
+	if t.err != nil {
+		return t.err
+	}
+	record.GatewayName = ""
+	record.Eth0Routes = nil
+	return nil
+}
+
+var _ = Describe("SandboxAgent", func() {
+	var (
+		fakeClient client.Client
+		pod        *corev1.Pod
+		record     *sandbox.Record
+		store      *sandbox.Store
+		attacher   *testAttacher
+		agent      *cnimanager.SandboxAgent
+		startAgent func()
+	)
+	BeforeEach(func() {
+		apischeme := runtime.NewScheme()
+		utilruntime.Must(clientgoscheme.AddToScheme(apischeme))
+		pod = &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "test",
+				Namespace:   "default",
+				UID:         "pod-uid-1",
+				Annotations: map[string]string{consts.CNIGatewayAnnotationKey: "tgw1"},
+			},
+			Spec: corev1.PodSpec{NodeName: "node1"},
+		}
+		record = &sandbox.Record{
+			ContainerID:  "container1",
+			NetnsPath:    "/var/run/netns/cni-1",
+			PodName:      "test",
+			PodNamespace: "default",
+			PodUID:       "pod-uid-1",
+		}
+		fakeClient = fake.NewClientBuilder().WithScheme(apischeme).WithRuntimeObjects(pod).Build()
+		store = sandbox.NewStore(GinkgoT().TempDir())
+		attacher = &testAttacher{calls: make(chan string, 10)}
+		agent = cnimanager.NewSandboxAgent(fakeClient, store, attacher)
+		startAgent = func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				_ = agent.Start(ctx)
+			}()
+			DeferCleanup(func() {
+				cancel()
+				Eventually(done).Should(BeClosed())
+			})
+		}
+	})
+
+	It("should attach pod sandbox when pod is annotated", func() {
+		Expect(store.Save(record)).To(Succeed())
+		startAgent()
+		Eventually(attacher.calls).Should(Receive(Equal("attach container1 tgw1")))
+		Eventually(func() string {
+			got, _ := store.Get("container1")
+			return got.GatewayName
+		}).Should(Equal("tgw1"))
+		Consistently(attacher.calls, 500*time.Millisecond).ShouldNot(Receive())
+	})
+
+	It("should detach pod sandbox when pod annotation is removed", func() {
+		record.GatewayName = "tgw1"
+		Expect(store.Save(record)).To(Succeed())
+		startAgent()
+		Consistently(attacher.calls, 500*time.Millisecond).ShouldNot(Receive())
+
+		updated := pod.DeepCopy()
+		delete(updated.Annotations, consts.CNIGatewayAnnotationKey)
+		Expect(fakeClient.Update(context.Background(), updated)).To(Succeed())
+		agent.PodEventHandler().OnUpdate(pod, updated)
+		Eventually(attacher.calls).Should(Receive(Equal("detach container1 tgw1")))
+		Eventually(func() string {
+			got, _ := store.Get("container1")
+			return got.GatewayName
+		}).Should(BeEmpty())
+	})
+
+	It("should move pod sandbox when pod gateway changes", func() {
+		record.GatewayName = "tgw0"
+		Expect(store.Save(record)).To(Succeed())
+		startAgent()
+		Eventually(attacher.calls).Should(Receive(Equal("detach container1 tgw0")))
+		Eventually(attacher.calls).Should(Receive(Equal("attach container1 tgw1")))
+	})
+
+	It("should skip sandbox of another pod with the same name", func() {
+		record.PodUID = "pod-uid-0"
+		Expect(store.Save(record)).To(Succeed())
+		startAgent()
+		Consistently(attacher.calls, 500*time.Millisecond).ShouldNot(Receive())
+	})
+
+	It("should stop attaching pod sandbox once its record is deleted", func() {
+		attacher.err = errors.New("netns not found")
+		Expect(store.Save(record)).To(Succeed())
+		startAgent()
+		Eventually(attacher.calls).Should(Receive(Equal("attach container1 tgw1")))
+		Expect(store.Delete("container1")).To(Succeed())
+		agent.PodEventHandler().OnAdd(pod, false)
+		Consistently(attacher.calls, 500*time.Millisecond).ShouldNot(Receive())
+		_, err := store.Get("container1")
+		Expect(errors.Is(err, os.ErrNotExist)).To(BeTrue())
+	})
+})
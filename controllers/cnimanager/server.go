@@ -5,59 +5,137 @@ package cnimanager
 //+kubebuilder:rbac:groups=egressgateway.kubernetes.azure.com,resources=staticgatewayconfigurations,verbs=get;list;watch
 //+kubebuilder:rbac:groups=egressgateway.kubernetes.azure.com,resources=staticgatewayconfigurations/status,verbs=get;
 //+kubebuilder:rbac:groups=egressgateway.kubernetes.azure.com,resources=podendpoints,verbs=list;watch;create;update;patch;delete;
+//+kubebuilder:rbac:groups=egressgateway.kubernetes.azure.com,resources=gatewaystatuses,verbs=get;list;watch
 //+kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch
 //+kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch
 //+kubebuilder:rbac:groups=core,resources=nodes,verbs=get;list;watch;update;patch
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"sync"
 
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	toolscache "k8s.io/client-go/tools/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
 
 	current "github.com/Azure/kube-egress-gateway/api/v1alpha1"
 	cniprotocol "github.com/Azure/kube-egress-gateway/pkg/cniprotocol/v1"
+	"github.com/Azure/kube-egress-gateway/pkg/consts"
 	"github.com/Azure/kube-egress-gateway/pkg/metrics"
+	"github.com/Azure/kube-egress-gateway/pkg/tracing"
 )
 
 type NicService struct {
+	// k8sClient reads pods and StaticGatewayConfigurations from informer cache
 	k8sClient client.Client
+	// apiReader reads from API server directly, for objects missing in the cache
+	apiReader client.Reader
+	// name of the node cni manager runs on
+	nodeName string
+	// channels of WatchGateway streams, keyed by StaticGatewayConfiguration
+	watchersLock sync.Mutex
+	watchers     map[types.NamespacedName]map[chan struct{}]struct{}
 	cniprotocol.UnimplementedNicServiceServer
 }
 
-func NewNicService(k8sClient client.Client) *NicService {
-	return &NicService{k8sClient: k8sClient}
+func NewNicService(k8sClient client.Client, apiReader client.Reader, nodeName string) *NicService {
+	return &NicService{
+		k8sClient: k8sClient,
+		apiReader: apiReader,
+		nodeName:  nodeName,
+		watchers:  make(map[types.NamespacedName]map[chan struct{}]struct{}),
+	}
+}
+
+// getCached reads the object from informer cache and falls back to API server when it's not found,
+// e.g. a pod scheduled right before the CNI request may not be in the cache yet.
+func (s *NicService) getCached(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+	err := s.k8sClient.Get(ctx, key, obj)
+	if !apierrors.IsNotFound(err) {
+		return err
+	}
+	gvk, gvkErr := apiutil.GVKForObject(obj, s.k8sClient.Scheme())
+	if gvkErr == nil {
+		metrics.CNIManagerCacheMissCount.WithLabelValues(gvk.Kind).Inc()
+	}
+	return s.apiReader.Get(ctx, key, obj)
 }
 
 // NicAdd add nic
 
 func (s *NicService) NicAdd(ctx context.Context, in *cniprotocol.NicAddRequest) (*cniprotocol.NicAddResponse, error) {
 	gwConfig := &current.StaticGatewayConfiguration{}
-	if err := s.k8sClient.Get(ctx, client.ObjectKey{Name: in.GetGatewayName(), Namespace: in.GetPodConfig().GetPodNamespace()}, gwConfig); err != nil {
+	spanCtx, span := tracing.Tracer().Start(ctx, "get StaticGatewayConfiguration")
+	err := s.getCached(spanCtx, client.ObjectKey{Name: in.GetGatewayName(), Namespace: in.GetPodConfig().GetPodNamespace()}, gwConfig)
+	tracing.EndSpan(span, err)
+	if err != nil {
 		return nil, status.Errorf(codes.Unknown, "failed to retrieve StaticGatewayConfiguration %s/%s: %s", in.GetPodConfig().GetPodNamespace(), in.GetGatewayName(), err)
 	}
 	if len(gwConfig.Status.EgressIpPrefix) == 0 {
 		return nil, status.Errorf(codes.FailedPrecondition, "the egress IP prefix is not ready yet.")
 	}
-	pod := &corev1.Pod{}
-	if err := s.k8sClient.Get(ctx, client.ObjectKey{Name: in.GetPodConfig().GetPodName(), Namespace: in.GetPodConfig().GetPodNamespace()}, pod); err != nil {
-		return nil, status.Errorf(codes.Unknown, "failed to retrieve pod %s/%s: %s", in.GetPodConfig().GetPodNamespace(), in.GetPodConfig().GetPodName(), err)
+	spanCtx, span = tracing.Tracer().Start(ctx, "authorize pod")
+	pod, err := s.authorizePod(spanCtx, in.GetPodConfig(), in.GetGatewayName())
+	tracing.EndSpan(span, err)
+	if err != nil {
+		return nil, err
+	}
+	spanCtx, span = tracing.Tracer().Start(ctx, "select gateway frontend")
+	endpointIP, err := s.selectFrontendIP(spanCtx, gwConfig, pod)
+	tracing.EndSpan(span, err)
+	if err != nil {
+		return nil, status.Errorf(codes.Unknown, "failed to select gateway frontend for pod %s/%s: %s", in.GetPodConfig().GetPodNamespace(), in.GetPodConfig().GetPodName(), err)
+	}
+	dedicatedEgressIP := pod.Annotations[consts.CNIDedicatedEgressIPAnnotationKey]
+	if dedicatedEgressIP != "" && !hasDedicatedEgressIP(gwConfig, dedicatedEgressIP) {
+		return nil, status.Errorf(codes.InvalidArgument, "pod %s/%s uses dedicated egress IP %s not defined in gateway %s", in.GetPodConfig().GetPodNamespace(), in.GetPodConfig().GetPodName(), dedicatedEgressIP, in.GetGatewayName())
 	}
 	podEndpoint := &current.PodEndpoint{ObjectMeta: metav1.ObjectMeta{Name: in.GetPodConfig().GetPodName(), Namespace: in.GetPodConfig().GetPodNamespace()}}
-	if _, err := controllerutil.CreateOrUpdate(ctx, s.k8sClient, podEndpoint, func() error {
+	spanCtx, span = tracing.Tracer().Start(ctx, "create or update PodEndpoint")
+	_, err = controllerutil.CreateOrUpdate(spanCtx, s.k8sClient, podEndpoint, func() error {
+		if podEndpoint.Spec.PodUid != string(pod.UID) {
+			// endpoint is left by a deleted pod with the same name, take it over
+			podEndpoint.OwnerReferences = nil
+		}
 		if err := controllerutil.SetControllerReference(pod, podEndpoint, s.k8sClient.Scheme()); err != nil {
 			return err
 		}
 		podEndpoint.Spec.PodIpAddress = in.GetAllowedIp()
 		podEndpoint.Spec.StaticGatewayConfiguration = in.GetGatewayName()
 		podEndpoint.Spec.PodPublicKey = in.PublicKey
+		podEndpoint.Spec.NodeName = pod.Spec.NodeName
+		podEndpoint.Spec.PodUid = string(pod.UID)
+		podEndpoint.Spec.ContainerId = in.GetContainerId()
+		podEndpoint.Spec.NetnsPath = in.GetNetnsPath()
+		podEndpoint.Spec.DedicatedEgressIp = dedicatedEgressIP
+		if podEndpoint.Labels == nil {
+			podEndpoint.Labels = make(map[string]string)
+		}
+		podEndpoint.Labels[consts.PodEndpointNodeNameLabel] = pod.Spec.NodeName
+		if podEndpoint.Annotations == nil {
+			podEndpoint.Annotations = make(map[string]string)
+		}
+		// gateway daemons continue the trace of the pod attach when programming the peer
+		tracing.InjectAnnotations(ctx, podEndpoint.Annotations)
 		return nil
-	}); err != nil {
+	})
+	tracing.EndSpan(span, err)
+	if err != nil {
 		metrics.CNIManagerPodEndpointOperationFailCount.WithLabelValues(
 			in.GetPodConfig().GetPodNamespace(),
 			"create_or_update",
@@ -66,23 +144,147 @@ func (s *NicService) NicAdd(ctx context.Context, in *cniprotocol.NicAddRequest)
 		return nil, status.Errorf(codes.Unknown, "failed to update PodEndpoint %s/%s: %s", in.GetPodConfig().GetPodNamespace(), in.GetPodConfig().GetPodName(), err)
 	}
 
-	defaultRoute := cniprotocol.DefaultRoute_DEFAULT_ROUTE_STATIC_EGRESS_GATEWAY
-	if gwConfig.Spec.DefaultRoute == current.RouteAzureNetworking {
-		defaultRoute = cniprotocol.DefaultRoute_DEFAULT_ROUTE_AZURE_NETWORKING
-	}
 	return &cniprotocol.NicAddResponse{
-		EndpointIp:     gwConfig.Status.Ip,
+		EndpointIp:     endpointIP,
 		ListenPort:     gwConfig.Status.Port,
 		PublicKey:      gwConfig.Status.PublicKey,
 		ExceptionCidrs: gwConfig.Spec.ExcludeCidrs,
-		DefaultRoute:   defaultRoute,
+		DefaultRoute:   toDefaultRoute(gwConfig.Spec.DefaultRoute),
+		Mtu:            gwConfig.Spec.Mtu,
 	}, nil
 }
 
+// toDefaultRoute converts the default route of a StaticGatewayConfiguration to its cni protocol value.
+func toDefaultRoute(routeType current.RouteType) cniprotocol.DefaultRoute {
+	if routeType == current.RouteAzureNetworking {
+		return cniprotocol.DefaultRoute_DEFAULT_ROUTE_AZURE_NETWORKING
+	}
+	return cniprotocol.DefaultRoute_DEFAULT_ROUTE_STATIC_EGRESS_GATEWAY
+}
+
+// authorizePod makes sure the pod in the request exists, is bound to this node, uses the gateway and,
+// when provided, has the same UID, so that a nic can't be added for pods on other nodes.
+func (s *NicService) authorizePod(ctx context.Context, podInfo *cniprotocol.PodInfo, gatewayName string) (*corev1.Pod, error) {
+	pod := &corev1.Pod{}
+	if err := s.getCached(ctx, client.ObjectKey{Name: podInfo.GetPodName(), Namespace: podInfo.GetPodNamespace()}, pod); err != nil {
+		return nil, status.Errorf(codes.Unknown, "failed to retrieve pod %s/%s: %s", podInfo.GetPodNamespace(), podInfo.GetPodName(), err)
+	}
+	if pod.Spec.NodeName != s.nodeName {
+		return nil, status.Errorf(codes.PermissionDenied, "pod %s/%s is not bound to node %s", podInfo.GetPodNamespace(), podInfo.GetPodName(), s.nodeName)
+	}
+	if pod.Annotations[consts.CNIGatewayAnnotationKey] != gatewayName {
+		return nil, status.Errorf(codes.PermissionDenied, "pod %s/%s does not use gateway %s", podInfo.GetPodNamespace(), podInfo.GetPodName(), gatewayName)
+	}
+	if podInfo.GetPodUid() != "" && podInfo.GetPodUid() != string(pod.UID) {
+		return nil, status.Errorf(codes.PermissionDenied, "pod %s/%s UID %s does not match %s", podInfo.GetPodNamespace(), podInfo.GetPodName(), podInfo.GetPodUid(), pod.UID)
+	}
+	return pod, nil
+}
+
+// hasDedicatedEgressIP returns whether the gateway has a dedicated egress IP with the name.
+func hasDedicatedEgressIP(gwConfig *current.StaticGatewayConfiguration, name string) bool {
+	for _, dedicated := range gwConfig.Spec.DedicatedEgressIps {
+		if dedicated.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// selectFrontendIP returns the gateway frontend IP for the pod. For zone aware gateways, the
+// frontend of the pod's node zone is preferred, otherwise one of the other zones with ready
+// gateways is picked.
+func (s *NicService) selectFrontendIP(ctx context.Context, gwConfig *current.StaticGatewayConfiguration, pod *corev1.Pod) (string, error) {
+	var localFrontends, otherFrontends []current.ZonalFrontend
+	if len(gwConfig.Status.ZonalFrontends) == 0 {
+		return gwConfig.Status.Ip, nil
+	}
+
+	localZone := ""
+	if pod.Spec.NodeName != "" {
+		node := &corev1.Node{}
+		if err := s.k8sClient.Get(ctx, client.ObjectKey{Name: pod.Spec.NodeName}, node); err != nil {
+			return "", fmt.Errorf("failed to retrieve node %s: %w", pod.Spec.NodeName, err)
+		}
+		localZone = getAzureZone(node.Labels[corev1.LabelTopologyZone])
+	}
+	for _, frontend := range gwConfig.Status.ZonalFrontends {
+		if frontend.Ip == "" {
+			continue
+		}
+		if frontend.Zone == localZone {
+			localFrontends = append(localFrontends, frontend)
+		} else {
+			otherFrontends = append(otherFrontends, frontend)
+		}
+	}
+	if len(localFrontends)+len(otherFrontends) == 0 {
+		return gwConfig.Status.Ip, nil
+	}
+
+	gwStatusList := &current.GatewayStatusList{}
+	if err := s.k8sClient.List(ctx, gwStatusList); err != nil {
+		return "", fmt.Errorf("failed to list gateway statuses: %w", err)
+	}
+	gwConfigKey := fmt.Sprintf("%s/%s", gwConfig.Namespace, gwConfig.Name)
+	readyZones := make(map[string]bool)
+	for _, gwStatus := range gwStatusList.Items {
+		for _, conf := range gwStatus.Spec.ReadyGatewayConfigurations {
+			if conf.StaticGatewayConfiguration == gwConfigKey {
+				readyZones[gwStatus.Spec.Zone] = true
+				break
+			}
+		}
+	}
+
+	if len(localFrontends) > 0 && readyZones[localZone] {
+		return localFrontends[0].Ip, nil
+	}
+	var readyFrontends []current.ZonalFrontend
+	for _, frontend := range otherFrontends {
+		if readyZones[frontend.Zone] {
+			readyFrontends = append(readyFrontends, frontend)
+		}
+	}
+	if len(readyFrontends) > 0 {
+		// spread pods of the local zone across the remaining zones
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(pod.Namespace + "/" + pod.Name))
+		return readyFrontends[h.Sum32()%uint32(len(readyFrontends))].Ip, nil
+	}
+	// no ready gateway in any zone, keep using the local zone if possible
+	if len(localFrontends) > 0 {
+		return localFrontends[0].Ip, nil
+	}
+	return otherFrontends[0].Ip, nil
+}
+
+// getAzureZone converts the topology zone label value (e.g. "eastus-1") to the azure availability zone ("1").
+func getAzureZone(topologyZone string) string {
+	return topologyZone[strings.LastIndex(topologyZone, "-")+1:]
+}
+
+// NicDel deletes the PodEndpoint of the pod. Endpoints of other nodes are never deleted, and endpoints
+// recorded for another pod UID or sandbox container are kept, as they belong to a newer pod with the
+// same name and the request is a stale one.
 func (s *NicService) NicDel(ctx context.Context, in *cniprotocol.NicDelRequest) (*cniprotocol.NicDelResponse, error) {
-	podEndpoint := &current.PodEndpoint{ObjectMeta: metav1.ObjectMeta{Name: in.GetPodConfig().GetPodName(), Namespace: in.GetPodConfig().GetPodNamespace()}}
-	if err := s.k8sClient.Delete(ctx, podEndpoint); err != nil {
-		if !apierrors.IsNotFound(err) {
+	logger := log.FromContext(ctx)
+	podEndpoint := &current.PodEndpoint{}
+	if err := s.k8sClient.Get(ctx, client.ObjectKey{Name: in.GetPodConfig().GetPodName(), Namespace: in.GetPodConfig().GetPodNamespace()}, podEndpoint); err != nil {
+		if apierrors.IsNotFound(err) {
+			return &cniprotocol.NicDelResponse{}, nil
+		}
+		return nil, status.Errorf(codes.Unknown, "failed to retrieve PodEndpoint %s/%s: %s", in.GetPodConfig().GetPodNamespace(), in.GetPodConfig().GetPodName(), err)
+	}
+	if podEndpoint.Spec.NodeName != "" && podEndpoint.Spec.NodeName != s.nodeName {
+		return nil, status.Errorf(codes.PermissionDenied, "PodEndpoint %s/%s belongs to node %s", podEndpoint.Namespace, podEndpoint.Name, podEndpoint.Spec.NodeName)
+	}
+	if isStale(in.GetPodConfig().GetPodUid(), podEndpoint.Spec.PodUid) || isStale(in.GetContainerId(), podEndpoint.Spec.ContainerId) {
+		logger.Info("skip deleting PodEndpoint of a newer pod", "podEndpoint", client.ObjectKeyFromObject(podEndpoint), "podUid", podEndpoint.Spec.PodUid, "containerId", podEndpoint.Spec.ContainerId)
+		return &cniprotocol.NicDelResponse{}, nil
+	}
+	if err := s.k8sClient.Delete(ctx, podEndpoint, client.Preconditions{UID: &podEndpoint.UID}); err != nil {
+		if !apierrors.IsNotFound(err) && !apierrors.IsConflict(err) {
 			metrics.CNIManagerPodEndpointOperationFailCount.WithLabelValues(
 				in.GetPodConfig().GetPodNamespace(),
 				"delete",
@@ -94,9 +296,221 @@ func (s *NicService) NicDel(ctx context.Context, in *cniprotocol.NicDelRequest)
 	return &cniprotocol.NicDelResponse{}, nil
 }
 
+// isStale returns true when both the requested and the recorded values are known and differ.
+func isStale(requested, recorded string) bool {
+	return requested != "" && recorded != "" && requested != recorded
+}
+
+// NicGC deletes PodEndpoints of this node whose sandbox container is not in the valid container list
+// provided by the container runtime. PodEndpoints without container ID are kept, as they were created
+// by an older cni plugin and can't be matched against the list.
+func (s *NicService) NicGC(ctx context.Context, in *cniprotocol.NicGCRequest) (*cniprotocol.NicGCResponse, error) {
+	podEndpoints := &current.PodEndpointList{}
+	if err := s.k8sClient.List(ctx, podEndpoints, client.MatchingLabels{consts.PodEndpointNodeNameLabel: s.nodeName}); err != nil {
+		return nil, status.Errorf(codes.Unknown, "failed to list PodEndpoints on node %s: %s", s.nodeName, err)
+	}
+	validContainers := sets.New(in.GetValidContainerIds()...)
+	resp := &cniprotocol.NicGCResponse{}
+	var errs []error
+	for i := range podEndpoints.Items {
+		podEndpoint := &podEndpoints.Items[i]
+		if podEndpoint.Spec.ContainerId == "" || validContainers.Has(podEndpoint.Spec.ContainerId) {
+			continue
+		}
+		// make sure a PodEndpoint recreated for a new sandbox in the meantime is not deleted
+		if err := s.k8sClient.Delete(ctx, podEndpoint, client.Preconditions{UID: &podEndpoint.UID}); err != nil {
+			if !apierrors.IsNotFound(err) && !apierrors.IsConflict(err) {
+				metrics.CNIManagerPodEndpointOperationFailCount.WithLabelValues(
+					podEndpoint.Namespace,
+					"delete",
+					podEndpoint.Name,
+				).Inc()
+				errs = append(errs, fmt.Errorf("failed to delete PodEndpoint %s/%s: %w", podEndpoint.Namespace, podEndpoint.Name, err))
+			}
+			continue
+		}
+		resp.DeletedPods = append(resp.DeletedPods, &cniprotocol.PodInfo{PodName: podEndpoint.Name, PodNamespace: podEndpoint.Namespace})
+	}
+	if len(errs) > 0 {
+		return nil, status.Errorf(codes.Unknown, "failed to garbage collect PodEndpoints: %s", errors.Join(errs...))
+	}
+	return resp, nil
+}
+
+// NicCheck returns the gateway configuration the nic of the pod is expected to match. Any frontend
+// of the gateway is a valid endpoint, since zone aware gateways may fall back to other zones.
+func (s *NicService) NicCheck(ctx context.Context, in *cniprotocol.NicCheckRequest) (*cniprotocol.NicCheckResponse, error) {
+	gwConfig := &current.StaticGatewayConfiguration{}
+	if err := s.getCached(ctx, client.ObjectKey{Name: in.GetGatewayName(), Namespace: in.GetPodConfig().GetPodNamespace()}, gwConfig); err != nil {
+		return nil, status.Errorf(codes.Unknown, "failed to retrieve StaticGatewayConfiguration %s/%s: %s", in.GetPodConfig().GetPodNamespace(), in.GetGatewayName(), err)
+	}
+	if len(gwConfig.Status.EgressIpPrefix) == 0 {
+		return nil, status.Errorf(codes.FailedPrecondition, "the egress IP prefix is not ready yet.")
+	}
+
+	var endpointIPs []string
+	if gwConfig.Status.Ip != "" {
+		endpointIPs = append(endpointIPs, gwConfig.Status.Ip)
+	}
+	for _, frontend := range gwConfig.Status.ZonalFrontends {
+		if frontend.Ip != "" && frontend.Ip != gwConfig.Status.Ip {
+			endpointIPs = append(endpointIPs, frontend.Ip)
+		}
+	}
+
+	return &cniprotocol.NicCheckResponse{
+		EndpointIps:    endpointIPs,
+		ListenPort:     gwConfig.Status.Port,
+		PublicKey:      gwConfig.Status.PublicKey,
+		ExceptionCidrs: gwConfig.Spec.ExcludeCidrs,
+		DefaultRoute:   toDefaultRoute(gwConfig.Spec.DefaultRoute),
+	}, nil
+}
+
+// WatchGateway streams the gateway configuration of the pod nic, the current one first and then every time
+// it changes, so that the nic of a running pod can be updated in place. The stream is kept open while the
+// gateway is not ready, e.g. being reprovisioned.
+func (s *NicService) WatchGateway(in *cniprotocol.WatchGatewayRequest, stream grpc.ServerStreamingServer[cniprotocol.WatchGatewayResponse]) error {
+	ctx := stream.Context()
+	pod, err := s.authorizePod(ctx, in.GetPodConfig(), in.GetGatewayName())
+	if err != nil {
+		return err
+	}
+	key := types.NamespacedName{Name: in.GetGatewayName(), Namespace: pod.Namespace}
+	// register before reading the gateway, so that no change is missed in between
+	changed := s.addGatewayWatcher(key)
+	defer s.removeGatewayWatcher(key, changed)
+
+	var sent *cniprotocol.WatchGatewayResponse
+	for {
+		resp, err := s.getGatewayConfig(ctx, key, pod)
+		if err != nil && status.Code(err) != codes.FailedPrecondition {
+			return err
+		}
+		if err == nil && !proto.Equal(resp, sent) {
+			if err := stream.Send(resp); err != nil {
+				return err
+			}
+			sent = resp
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-changed:
+		}
+	}
+}
+
+// getGatewayConfig returns the current gateway configuration of the pod nic.
+func (s *NicService) getGatewayConfig(ctx context.Context, key types.NamespacedName, pod *corev1.Pod) (*cniprotocol.WatchGatewayResponse, error) {
+	gwConfig := &current.StaticGatewayConfiguration{}
+	if err := s.getCached(ctx, key, gwConfig); err != nil {
+		return nil, status.Errorf(codes.Unknown, "failed to retrieve StaticGatewayConfiguration %s: %s", key, err)
+	}
+	if len(gwConfig.Status.EgressIpPrefix) == 0 {
+		return nil, status.Errorf(codes.FailedPrecondition, "the egress IP prefix is not ready yet.")
+	}
+	endpointIP, err := s.selectFrontendIP(ctx, gwConfig, pod)
+	if err != nil {
+		return nil, status.Errorf(codes.Unknown, "failed to select gateway frontend for pod %s/%s: %s", pod.Namespace, pod.Name, err)
+	}
+	return &cniprotocol.WatchGatewayResponse{
+		EndpointIp:     endpointIP,
+		ListenPort:     gwConfig.Status.Port,
+		PublicKey:      gwConfig.Status.PublicKey,
+		ExceptionCidrs: gwConfig.Spec.ExcludeCidrs,
+		DefaultRoute:   toDefaultRoute(gwConfig.Spec.DefaultRoute),
+		Mtu:            gwConfig.Spec.Mtu,
+	}, nil
+}
+
+func (s *NicService) addGatewayWatcher(key types.NamespacedName) chan struct{} {
+	s.watchersLock.Lock()
+	defer s.watchersLock.Unlock()
+	// buffered, so that changes made while the stream is busy are coalesced into one
+	changed := make(chan struct{}, 1)
+	if s.watchers[key] == nil {
+		s.watchers[key] = make(map[chan struct{}]struct{})
+	}
+	s.watchers[key][changed] = struct{}{}
+	return changed
+}
+
+func (s *NicService) removeGatewayWatcher(key types.NamespacedName, changed chan struct{}) {
+	s.watchersLock.Lock()
+	defer s.watchersLock.Unlock()
+	delete(s.watchers[key], changed)
+	if len(s.watchers[key]) == 0 {
+		delete(s.watchers, key)
+	}
+}
+
+// GatewayEventHandler returns the StaticGatewayConfiguration informer event handler that wakes up the
+// WatchGateway streams of the changed gateway.
+func (s *NicService) GatewayEventHandler() toolscache.ResourceEventHandler {
+	return toolscache.ResourceEventHandlerFuncs{
+		AddFunc:    s.notifyGatewayWatchers,
+		UpdateFunc: func(_, newObj interface{}) { s.notifyGatewayWatchers(newObj) },
+		DeleteFunc: s.notifyGatewayWatchers,
+	}
+}
+
+func (s *NicService) notifyGatewayWatchers(obj interface{}) {
+	if tombstone, ok := obj.(toolscache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	gwConfig, ok := obj.(*current.StaticGatewayConfiguration)
+	if !ok {
+		return
+	}
+	s.notifyWatchers(client.ObjectKeyFromObject(gwConfig))
+}
+
+// GatewayStatusEventHandler returns the GatewayStatus informer event handler that wakes up the WatchGateway
+// streams of the gateways ready, or no longer ready, on the node, as the frontend selected for zone aware
+// gateways depends on the zones having ready gateways.
+func (s *NicService) GatewayStatusEventHandler() toolscache.ResourceEventHandler {
+	return toolscache.ResourceEventHandlerFuncs{
+		AddFunc: s.notifyGatewayStatusWatchers,
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			s.notifyGatewayStatusWatchers(oldObj)
+			s.notifyGatewayStatusWatchers(newObj)
+		},
+		DeleteFunc: s.notifyGatewayStatusWatchers,
+	}
+}
+
+func (s *NicService) notifyGatewayStatusWatchers(obj interface{}) {
+	if tombstone, ok := obj.(toolscache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	gwStatus, ok := obj.(*current.GatewayStatus)
+	if !ok {
+		return
+	}
+	for _, gwConf := range gwStatus.Spec.ReadyGatewayConfigurations {
+		namespace, name, found := strings.Cut(gwConf.StaticGatewayConfiguration, "/")
+		if !found {
+			continue
+		}
+		s.notifyWatchers(types.NamespacedName{Namespace: namespace, Name: name})
+	}
+}
+
+func (s *NicService) notifyWatchers(key types.NamespacedName) {
+	s.watchersLock.Lock()
+	defer s.watchersLock.Unlock()
+	for changed := range s.watchers[key] {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	}
+}
+
 func (s *NicService) PodRetrieve(ctx context.Context, in *cniprotocol.PodRetrieveRequest) (*cniprotocol.PodRetrieveResponse, error) {
 	pod := &corev1.Pod{}
-	if err := s.k8sClient.Get(ctx, client.ObjectKey{Name: in.GetPodConfig().GetPodName(), Namespace: in.GetPodConfig().GetPodNamespace()}, pod); err != nil {
+	if err := s.getCached(ctx, client.ObjectKey{Name: in.GetPodConfig().GetPodName(), Namespace: in.GetPodConfig().GetPodNamespace()}, pod); err != nil {
 		return nil, status.Errorf(codes.Unknown, "failed to retrieve pod %s/%s: %s", in.GetPodConfig().GetPodNamespace(), in.GetPodConfig().GetPodName(), err)
 	}
 	return &cniprotocol.PodRetrieveResponse{
@@ -4,22 +4,33 @@ package cnimanager_test
 
 import (
 	"context"
+	"net"
+	"path/filepath"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"github.com/prometheus/client_golang/prometheus/testutil"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 
 	current "github.com/Azure/kube-egress-gateway/api/v1alpha1"
 	"github.com/Azure/kube-egress-gateway/controllers/cnimanager"
 	cniprotocol "github.com/Azure/kube-egress-gateway/pkg/cniprotocol/v1"
+	"github.com/Azure/kube-egress-gateway/pkg/consts"
 	"github.com/Azure/kube-egress-gateway/pkg/metrics"
+	"github.com/Azure/kube-egress-gateway/pkg/tracing"
 )
 
 var _ = Describe("Server", func() {
@@ -57,31 +68,40 @@ var _ = Describe("Server", func() {
 			ObjectMeta: metav1.ObjectMeta{
 				Name:      "test",
 				Namespace: "default",
+				UID:       "pod-uid-1",
 				Annotations: map[string]string{
-					"key1": "value1",
-					"key2": "value2",
+					"key1":                         "value1",
+					"key2":                         "value2",
+					consts.CNIGatewayAnnotationKey: "tgw1",
 				},
 			},
+			Spec: corev1.PodSpec{
+				NodeName: "node1",
+			},
 		}
 		nicAddInputRequest = &cniprotocol.NicAddRequest{
 			PodConfig: &cniprotocol.PodInfo{
 				PodName:      "test",
 				PodNamespace: "default",
+				PodUid:       "pod-uid-1",
 			},
 			ListenPort:  12345,
 			AllowedIp:   "192.168.1.10/32",
 			PublicKey:   "SOMERANDOMPUBLICKKEY",
 			GatewayName: gatewayProfile.Name,
+			ContainerId: "container1",
+			NetnsPath:   "/var/run/netns/cni-1",
 		}
 		nicDelInputRequest = &cniprotocol.NicDelRequest{
-			PodConfig: nicAddInputRequest.PodConfig,
+			PodConfig:   nicAddInputRequest.PodConfig,
+			ContainerId: "container1",
 		}
 		podRetrieveRequest = &cniprotocol.PodRetrieveRequest{
 			PodConfig: nicAddInputRequest.PodConfig,
 		}
 		fakeClientBuilder.WithRuntimeObjects(gatewayProfile, pod)
 		fakeClient = fakeClientBuilder.Build()
-		service = cnimanager.NewNicService(fakeClient)
+		service = cnimanager.NewNicService(fakeClient, fakeClient, "node1")
 	})
 
 	Context("when gateway is not ready", func() {
@@ -103,7 +123,7 @@ var _ = Describe("Server", func() {
 			fakeClientBuilder.WithScheme(apischeme)
 			fakeClientBuilder.WithRuntimeObjects(gatewayProfile)
 			fakeClient = fakeClientBuilder.Build()
-			service = cnimanager.NewNicService(fakeClient)
+			service = cnimanager.NewNicService(fakeClient, fakeClient, "node1")
 		})
 		When("when gateway is not ready", func() {
 			It("should return error", func() {
@@ -122,6 +142,7 @@ var _ = Describe("Server", func() {
 				Expect(resp.EndpointIp).To(Equal(gatewayProfile.Status.GatewayServerProfile.Ip))
 				Expect(resp.ListenPort).To(Equal(gatewayProfile.Status.GatewayServerProfile.Port))
 				Expect(resp.DefaultRoute).To(Equal(cniprotocol.DefaultRoute_DEFAULT_ROUTE_STATIC_EGRESS_GATEWAY))
+				Expect(resp.Mtu).To(BeZero())
 				podEndpoint := &current.PodEndpoint{}
 				err = fakeClient.Get(context.Background(), client.ObjectKey{
 					Name:      nicAddInputRequest.PodConfig.PodName,
@@ -131,6 +152,33 @@ var _ = Describe("Server", func() {
 				Expect(podEndpoint.Spec.StaticGatewayConfiguration).To(Equal(gatewayProfile.Name))
 				Expect(podEndpoint.Spec.PodPublicKey).To(Equal(nicAddInputRequest.PublicKey))
 				Expect(podEndpoint.Spec.PodIpAddress).To(Equal(nicAddInputRequest.AllowedIp))
+				Expect(podEndpoint.Spec.NodeName).To(Equal("node1"))
+				Expect(podEndpoint.Spec.PodUid).To(Equal("pod-uid-1"))
+				Expect(podEndpoint.Spec.ContainerId).To(Equal("container1"))
+				Expect(podEndpoint.Spec.NetnsPath).To(Equal("/var/run/netns/cni-1"))
+				Expect(podEndpoint.Labels).To(HaveKeyWithValue(consts.PodEndpointNodeNameLabel, "node1"))
+			})
+		})
+		When("pod uses a dedicated egress IP", func() {
+			It("should record the dedicated egress IP in pod endpoint", func() {
+				gatewayProfile.Spec.DedicatedEgressIps = []current.DedicatedEgressIp{{Name: "partner-a"}}
+				Expect(fakeClient.Update(context.Background(), gatewayProfile)).To(Succeed())
+				pod.Annotations[consts.CNIDedicatedEgressIPAnnotationKey] = "partner-a"
+				Expect(fakeClient.Update(context.Background(), pod)).To(Succeed())
+				_, err := service.NicAdd(context.Background(), nicAddInputRequest)
+				Expect(err).NotTo(HaveOccurred())
+				podEndpoint := &current.PodEndpoint{}
+				Expect(fakeClient.Get(context.Background(), client.ObjectKey{
+					Name:      nicAddInputRequest.PodConfig.PodName,
+					Namespace: nicAddInputRequest.PodConfig.PodNamespace,
+				}, podEndpoint)).To(Succeed())
+				Expect(podEndpoint.Spec.DedicatedEgressIp).To(Equal("partner-a"))
+			})
+			It("should return error when the dedicated egress IP is not defined", func() {
+				pod.Annotations[consts.CNIDedicatedEgressIPAnnotationKey] = "partner-b"
+				Expect(fakeClient.Update(context.Background(), pod)).To(Succeed())
+				_, err := service.NicAdd(context.Background(), nicAddInputRequest)
+				Expect(status.Code(err)).To(Equal(codes.InvalidArgument))
 			})
 		})
 		When("gateway has azureNetworking as default route", func() {
@@ -142,6 +190,81 @@ var _ = Describe("Server", func() {
 				Expect(resp.DefaultRoute).To(Equal(cniprotocol.DefaultRoute_DEFAULT_ROUTE_AZURE_NETWORKING))
 			})
 		})
+		When("gateway has MTU", func() {
+			It("should return MTU", func() {
+				gatewayProfile.Spec.Mtu = 1400
+				fakeClient.Update(context.Background(), gatewayProfile) //nolint:errcheck
+				resp, err := service.NicAdd(context.Background(), nicAddInputRequest)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(resp.Mtu).To(Equal(int32(1400)))
+			})
+		})
+		When("request is traced", func() {
+			It("should record trace context in pod endpoint", func() {
+				_, err := tracing.Setup("test", "")
+				Expect(err).NotTo(HaveOccurred())
+				ctx, span := sdktrace.NewTracerProvider().Tracer(tracing.TracerName).Start(context.Background(), "cni.add")
+				defer span.End()
+				_, err = service.NicAdd(ctx, nicAddInputRequest)
+				Expect(err).NotTo(HaveOccurred())
+				podEndpoint := &current.PodEndpoint{}
+				Expect(fakeClient.Get(context.Background(), client.ObjectKey{
+					Name:      nicAddInputRequest.PodConfig.PodName,
+					Namespace: nicAddInputRequest.PodConfig.PodNamespace,
+				}, podEndpoint)).To(Succeed())
+				Expect(podEndpoint.Annotations[tracing.TraceParentAnnotationKey]).To(ContainSubstring(span.SpanContext().TraceID().String()))
+
+				_, err = service.NicAdd(context.Background(), nicAddInputRequest)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(fakeClient.Get(context.Background(), client.ObjectKeyFromObject(podEndpoint), podEndpoint)).To(Succeed())
+				Expect(podEndpoint.Annotations).NotTo(HaveKey(tracing.TraceParentAnnotationKey))
+			})
+		})
+		When("pod is not authorized", func() {
+			expectPermissionDenied := func() {
+				_, err := service.NicAdd(context.Background(), nicAddInputRequest)
+				Expect(status.Code(err)).To(Equal(codes.PermissionDenied))
+				podEndpoint := &current.PodEndpoint{}
+				err = fakeClient.Get(context.Background(), client.ObjectKey{
+					Name:      nicAddInputRequest.PodConfig.PodName,
+					Namespace: nicAddInputRequest.PodConfig.PodNamespace,
+				}, podEndpoint)
+				Expect(apierrors.IsNotFound(err)).To(BeTrue())
+			}
+			It("should reject pod bound to another node", func() {
+				pod.Spec.NodeName = "node2"
+				Expect(fakeClient.Update(context.Background(), pod)).To(Succeed())
+				expectPermissionDenied()
+			})
+			It("should reject pod using another gateway", func() {
+				pod.Annotations[consts.CNIGatewayAnnotationKey] = "tgw2"
+				Expect(fakeClient.Update(context.Background(), pod)).To(Succeed())
+				expectPermissionDenied()
+			})
+			It("should reject pod with different UID", func() {
+				nicAddInputRequest.PodConfig.PodUid = "pod-uid-2"
+				expectPermissionDenied()
+			})
+		})
+		When("pod endpoint of a deleted pod with the same name exists", func() {
+			It("should take over the pod endpoint", func() {
+				oldPod := pod.DeepCopy()
+				oldPod.UID = "pod-uid-0"
+				podEndpoint := &current.PodEndpoint{
+					ObjectMeta: metav1.ObjectMeta{Name: pod.Name, Namespace: pod.Namespace},
+					Spec:       current.PodEndpointSpec{PodUid: "pod-uid-0"},
+				}
+				Expect(controllerutil.SetControllerReference(oldPod, podEndpoint, fakeClient.Scheme())).To(Succeed())
+				Expect(fakeClient.Create(context.Background(), podEndpoint)).To(Succeed())
+
+				_, err := service.NicAdd(context.Background(), nicAddInputRequest)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(fakeClient.Get(context.Background(), client.ObjectKeyFromObject(podEndpoint), podEndpoint)).To(Succeed())
+				Expect(podEndpoint.Spec.PodUid).To(Equal("pod-uid-1"))
+				Expect(podEndpoint.OwnerReferences).To(HaveLen(1))
+				Expect(podEndpoint.OwnerReferences[0].UID).To(BeEquivalentTo("pod-uid-1"))
+			})
+		})
 		When("gateway is not found", func() {
 			It("should return error and don't create pod endpoint", func() {
 				initialCount := testutil.CollectAndCount(metrics.CNIManagerPodEndpointOperationFailCount)
@@ -161,6 +284,129 @@ var _ = Describe("Server", func() {
 		})
 	})
 
+	Context("when gateway is zone aware", func() {
+		var gwStatus *current.GatewayStatus
+		BeforeEach(func() {
+			gatewayProfile.Status.ZonalFrontends = []current.ZonalFrontend{
+				{Zone: "1", Ip: "10.0.1.1"},
+				{Zone: "2", Ip: "10.0.1.2"},
+			}
+			Expect(fakeClient.Update(context.Background(), gatewayProfile)).To(Succeed())
+			pod.Spec.NodeName = "node1"
+			Expect(fakeClient.Update(context.Background(), pod)).To(Succeed())
+			node := &corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:   "node1",
+					Labels: map[string]string{corev1.LabelTopologyZone: "eastus-2"},
+				},
+			}
+			Expect(fakeClient.Create(context.Background(), node)).To(Succeed())
+			gwStatus = &current.GatewayStatus{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "gwnode1",
+					Namespace: "kube-egress-gateway-system",
+				},
+				Spec: current.GatewayStatusSpec{
+					ReadyGatewayConfigurations: []current.GatewayConfiguration{
+						{StaticGatewayConfiguration: "default/tgw1", InterfaceName: "wg-6000"},
+					},
+					Zone: "2",
+				},
+			}
+		})
+		When("local zone has ready gateways", func() {
+			It("should return frontend of the local zone", func() {
+				Expect(fakeClient.Create(context.Background(), gwStatus)).To(Succeed())
+				resp, err := service.NicAdd(context.Background(), nicAddInputRequest)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(resp.EndpointIp).To(Equal("10.0.1.2"))
+			})
+		})
+		When("local zone has no ready gateways", func() {
+			It("should fall back to frontend of another zone", func() {
+				gwStatus.Spec.Zone = "1"
+				Expect(fakeClient.Create(context.Background(), gwStatus)).To(Succeed())
+				resp, err := service.NicAdd(context.Background(), nicAddInputRequest)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(resp.EndpointIp).To(Equal("10.0.1.1"))
+			})
+		})
+		When("no zone has ready gateways", func() {
+			It("should return frontend of the local zone", func() {
+				resp, err := service.NicAdd(context.Background(), nicAddInputRequest)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(resp.EndpointIp).To(Equal("10.0.1.2"))
+			})
+		})
+		When("gateways of the local zone become unready while the gateway is watched", func() {
+			It("should send the frontend of another zone", func() {
+				ctx, cancel := context.WithCancel(context.Background())
+				DeferCleanup(cancel)
+				Expect(fakeClient.Create(ctx, gwStatus)).To(Succeed())
+				otherZone := gwStatus.DeepCopy()
+				otherZone.ResourceVersion = ""
+				otherZone.Name = "gwnode2"
+				otherZone.Spec.Zone = "1"
+				Expect(fakeClient.Create(ctx, otherZone)).To(Succeed())
+
+				stream, err := serveNicService(service).WatchGateway(ctx, &cniprotocol.WatchGatewayRequest{PodConfig: nicAddInputRequest.PodConfig, GatewayName: gatewayProfile.Name})
+				Expect(err).NotTo(HaveOccurred())
+				resp, err := stream.Recv()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(resp.GetEndpointIp()).To(Equal("10.0.1.2"))
+
+				unready := &current.GatewayStatus{}
+				Expect(fakeClient.Get(ctx, client.ObjectKeyFromObject(gwStatus), unready)).To(Succeed())
+				unready.Spec.ReadyGatewayConfigurations = nil
+				Expect(fakeClient.Update(ctx, unready)).To(Succeed())
+				service.GatewayStatusEventHandler().OnUpdate(gwStatus, unready)
+
+				resp, err = stream.Recv()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(resp.GetEndpointIp()).To(Equal("10.0.1.1"))
+			})
+		})
+	})
+
+	Context("when nic is checked", func() {
+		var nicCheckInputRequest *cniprotocol.NicCheckRequest
+		BeforeEach(func() {
+			nicCheckInputRequest = &cniprotocol.NicCheckRequest{
+				PodConfig:   nicAddInputRequest.PodConfig,
+				GatewayName: nicAddInputRequest.GatewayName,
+			}
+		})
+		When("gateway is found", func() {
+			It("should return gateway configuration", func() {
+				resp, err := service.NicCheck(context.Background(), nicCheckInputRequest)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(resp.EndpointIps).To(Equal([]string{gatewayProfile.Status.GatewayServerProfile.Ip}))
+				Expect(resp.ListenPort).To(Equal(gatewayProfile.Status.GatewayServerProfile.Port))
+				Expect(resp.PublicKey).To(Equal(gatewayProfile.Status.GatewayServerProfile.PublicKey))
+				Expect(resp.DefaultRoute).To(Equal(cniprotocol.DefaultRoute_DEFAULT_ROUTE_STATIC_EGRESS_GATEWAY))
+			})
+		})
+		When("gateway is zone aware", func() {
+			It("should return frontends of all zones", func() {
+				gatewayProfile.Status.ZonalFrontends = []current.ZonalFrontend{
+					{Zone: "1", Ip: "10.0.1.1"},
+					{Zone: "2", Ip: "10.0.1.2"},
+				}
+				Expect(fakeClient.Update(context.Background(), gatewayProfile)).To(Succeed())
+				resp, err := service.NicCheck(context.Background(), nicCheckInputRequest)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(resp.EndpointIps).To(Equal([]string{gatewayProfile.Status.GatewayServerProfile.Ip, "10.0.1.1", "10.0.1.2"}))
+			})
+		})
+		When("gateway is not found", func() {
+			It("should return error", func() {
+				Expect(fakeClient.Delete(context.Background(), gatewayProfile)).To(Succeed())
+				_, err := service.NicCheck(context.Background(), nicCheckInputRequest)
+				Expect(err).To(HaveOccurred())
+			})
+		})
+	})
+
 	Context("when nic is deleted", func() {
 		When("pod endpoint is not found", func() {
 			It("should return nothing", func() {
@@ -168,6 +414,97 @@ var _ = Describe("Server", func() {
 				Expect(err).NotTo(HaveOccurred())
 			})
 		})
+		When("pod endpoint is found", func() {
+			var podEndpoint *current.PodEndpoint
+			BeforeEach(func() {
+				podEndpoint = &current.PodEndpoint{
+					ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+					Spec: current.PodEndpointSpec{
+						NodeName:    "node1",
+						PodUid:      "pod-uid-1",
+						ContainerId: "container1",
+					},
+				}
+			})
+			expectPodEndpointDeleted := func(deleted bool) {
+				err := fakeClient.Get(context.Background(), client.ObjectKeyFromObject(podEndpoint), &current.PodEndpoint{})
+				Expect(apierrors.IsNotFound(err)).To(Equal(deleted))
+			}
+			It("should delete pod endpoint of the pod", func() {
+				Expect(fakeClient.Create(context.Background(), podEndpoint)).To(Succeed())
+				_, err := service.NicDel(context.Background(), nicDelInputRequest)
+				Expect(err).NotTo(HaveOccurred())
+				expectPodEndpointDeleted(true)
+			})
+			It("should reject deleting pod endpoint of another node", func() {
+				podEndpoint.Spec.NodeName = "node2"
+				Expect(fakeClient.Create(context.Background(), podEndpoint)).To(Succeed())
+				_, err := service.NicDel(context.Background(), nicDelInputRequest)
+				Expect(status.Code(err)).To(Equal(codes.PermissionDenied))
+				expectPodEndpointDeleted(false)
+			})
+			It("should keep pod endpoint of a newer pod", func() {
+				podEndpoint.Spec.PodUid = "pod-uid-2"
+				Expect(fakeClient.Create(context.Background(), podEndpoint)).To(Succeed())
+				_, err := service.NicDel(context.Background(), nicDelInputRequest)
+				Expect(err).NotTo(HaveOccurred())
+				expectPodEndpointDeleted(false)
+			})
+			It("should keep pod endpoint of a newer sandbox", func() {
+				podEndpoint.Spec.ContainerId = "container2"
+				Expect(fakeClient.Create(context.Background(), podEndpoint)).To(Succeed())
+				_, err := service.NicDel(context.Background(), nicDelInputRequest)
+				Expect(err).NotTo(HaveOccurred())
+				expectPodEndpointDeleted(false)
+			})
+		})
+	})
+
+	Context("when nics are garbage collected", func() {
+		var podEndpoints []*current.PodEndpoint
+		BeforeEach(func() {
+			newPodEndpoint := func(name, nodeName, containerID string) *current.PodEndpoint {
+				return &current.PodEndpoint{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      name,
+						Namespace: "default",
+						Labels:    map[string]string{consts.PodEndpointNodeNameLabel: nodeName},
+					},
+					Spec: current.PodEndpointSpec{NodeName: nodeName, ContainerId: containerID},
+				}
+			}
+			podEndpoints = []*current.PodEndpoint{
+				newPodEndpoint("valid", "node1", "container1"),
+				newPodEndpoint("orphaned", "node1", "container2"),
+				newPodEndpoint("legacy", "node1", ""),
+				newPodEndpoint("other-node", "node2", "container3"),
+			}
+			for _, podEndpoint := range podEndpoints {
+				Expect(fakeClient.Create(context.Background(), podEndpoint)).To(Succeed())
+			}
+		})
+
+		It("should only delete orphaned pod endpoints of this node", func() {
+			resp, err := service.NicGC(context.Background(), &cniprotocol.NicGCRequest{ValidContainerIds: []string{"container1"}})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.GetDeletedPods()).To(HaveLen(1))
+			Expect(resp.GetDeletedPods()[0].GetPodName()).To(Equal("orphaned"))
+			Expect(resp.GetDeletedPods()[0].GetPodNamespace()).To(Equal("default"))
+
+			podEndpointList := &current.PodEndpointList{}
+			Expect(fakeClient.List(context.Background(), podEndpointList)).To(Succeed())
+			var names []string
+			for _, podEndpoint := range podEndpointList.Items {
+				names = append(names, podEndpoint.Name)
+			}
+			Expect(names).To(ConsistOf("valid", "legacy", "other-node"))
+		})
+
+		It("should delete nothing when all containers are valid", func() {
+			resp, err := service.NicGC(context.Background(), &cniprotocol.NicGCRequest{ValidContainerIds: []string{"container1", "container2"}})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.GetDeletedPods()).To(BeEmpty())
+		})
 	})
 
 	Context("metrics tracking", func() {
@@ -182,6 +519,75 @@ var _ = Describe("Server", func() {
 		})
 	})
 
+	Context("when gateway is watched", func() {
+		var nicClient cniprotocol.NicServiceClient
+		var ctx context.Context
+		BeforeEach(func() {
+			nicClient = serveNicService(service)
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithCancel(context.Background())
+			DeferCleanup(cancel)
+		})
+
+		It("should send current configuration and every change", func() {
+			stream, err := nicClient.WatchGateway(ctx, &cniprotocol.WatchGatewayRequest{PodConfig: nicAddInputRequest.PodConfig, GatewayName: gatewayProfile.Name})
+			Expect(err).NotTo(HaveOccurred())
+			resp, err := stream.Recv()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.GetEndpointIp()).To(Equal(gatewayProfile.Status.Ip))
+			Expect(resp.GetListenPort()).To(Equal(gatewayProfile.Status.Port))
+			Expect(resp.GetPublicKey()).To(Equal(gatewayProfile.Status.PublicKey))
+			Expect(resp.GetDefaultRoute()).To(Equal(cniprotocol.DefaultRoute_DEFAULT_ROUTE_STATIC_EGRESS_GATEWAY))
+
+			updated := &current.StaticGatewayConfiguration{}
+			Expect(fakeClient.Get(ctx, client.ObjectKeyFromObject(gatewayProfile), updated)).To(Succeed())
+			updated.Status.Port = 6000
+			updated.Spec.ExcludeCidrs = []string{"10.0.0.0/8"}
+			Expect(fakeClient.Update(ctx, updated)).To(Succeed())
+			service.GatewayEventHandler().OnUpdate(gatewayProfile, updated)
+
+			resp, err = stream.Recv()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.GetListenPort()).To(Equal(int32(6000)))
+			Expect(resp.GetExceptionCidrs()).To(Equal([]string{"10.0.0.0/8"}))
+		})
+
+		It("should reject pod using another gateway", func() {
+			stream, err := nicClient.WatchGateway(ctx, &cniprotocol.WatchGatewayRequest{PodConfig: nicAddInputRequest.PodConfig, GatewayName: "tgw2"})
+			Expect(err).NotTo(HaveOccurred())
+			_, err = stream.Recv()
+			Expect(status.Code(err)).To(Equal(codes.PermissionDenied))
+		})
+	})
+
+	Context("when objects are missing in the cache", func() {
+		BeforeEach(func() {
+			metrics.CNIManagerCacheMissCount.Reset()
+			apischeme := runtime.NewScheme()
+			utilruntime.Must(clientgoscheme.AddToScheme(apischeme))
+			utilruntime.Must(current.AddToScheme(apischeme))
+			cacheClient := fake.NewClientBuilder().WithScheme(apischeme).Build()
+			service = cnimanager.NewNicService(cacheClient, fakeClient, "node1")
+		})
+
+		It("should read pod from API server", func() {
+			resp, err := service.PodRetrieve(context.Background(), podRetrieveRequest)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.GetAnnotations()).To(Equal(pod.Annotations))
+			Expect(testutil.ToFloat64(metrics.CNIManagerCacheMissCount.WithLabelValues("Pod"))).To(Equal(float64(1)))
+		})
+
+		It("should read gateway from API server", func() {
+			resp, err := service.NicCheck(context.Background(), &cniprotocol.NicCheckRequest{
+				PodConfig:   nicAddInputRequest.PodConfig,
+				GatewayName: gatewayProfile.Name,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.GetPublicKey()).To(Equal(gatewayProfile.Status.PublicKey))
+			Expect(testutil.ToFloat64(metrics.CNIManagerCacheMissCount.WithLabelValues("StaticGatewayConfiguration"))).To(Equal(float64(1)))
+		})
+	})
+
 	Context("requesting pod metadata", func() {
 		When("pod is found", func() {
 			It("should return pods'annotations", func() {
@@ -200,3 +606,18 @@ var _ = Describe("Server", func() {
 		})
 	})
 })
+
+// serveNicService serves the service on a unix socket and returns a client connected to it.
+func serveNicService(service *cnimanager.NicService) cniprotocol.NicServiceClient {
+	socketPath := filepath.Join(GinkgoT().TempDir(), "cnimanager.sock")
+	listener, err := net.Listen("unix", socketPath)
+	Expect(err).NotTo(HaveOccurred())
+	server := grpc.NewServer()
+	cniprotocol.RegisterNicServiceServer(server, service)
+	go func() { _ = server.Serve(listener) }()
+	DeferCleanup(server.Stop)
+	conn, err := grpc.NewClient("unix://"+socketPath, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	Expect(err).NotTo(HaveOccurred())
+	DeferCleanup(conn.Close)
+	return cniprotocol.NewNicServiceClient(conn)
+}
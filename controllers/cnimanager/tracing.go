@@ -0,0 +1,24 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+package cnimanager
+
+import (
+	"context"
+
+	grpc_ctxtags "github.com/grpc-ecosystem/go-grpc-middleware/tags"
+	"google.golang.org/grpc"
+
+	"github.com/Azure/kube-egress-gateway/pkg/tracing"
+)
+
+// traceIDTag is the request log field the trace ID is recorded in
+const traceIDTag = "trace.id"
+
+// UnaryServerTracingInterceptor tags the request with the ID of the trace started by the cni plugin, so that the
+// request log of a slow pod start can be looked up by trace. It must run after the ctxtags interceptor.
+func UnaryServerTracingInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if traceID := tracing.TraceID(ctx); traceID != "" {
+		grpc_ctxtags.Extract(ctx).Set(traceIDTag, traceID)
+	}
+	return handler(ctx, req)
+}
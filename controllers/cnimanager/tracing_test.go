@@ -0,0 +1,35 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+package cnimanager_test
+
+import (
+	"context"
+
+	grpc_ctxtags "github.com/grpc-ecosystem/go-grpc-middleware/tags"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"google.golang.org/grpc"
+
+	"github.com/Azure/kube-egress-gateway/controllers/cnimanager"
+	"github.com/Azure/kube-egress-gateway/pkg/tracing"
+)
+
+var _ = Describe("UnaryServerTracingInterceptor", func() {
+	It("should tag request with trace ID", func() {
+		info := &grpc.UnaryServerInfo{FullMethod: "/pkg.cniprotocol.v1.NicService/NicAdd"}
+		handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, nil
+		}
+		ctx := grpc_ctxtags.SetInContext(context.Background(), grpc_ctxtags.NewTags())
+		_, err := cnimanager.UnaryServerTracingInterceptor(ctx, nil, info, handler)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(grpc_ctxtags.Extract(ctx).Values()).To(BeEmpty())
+
+		ctx, span := sdktrace.NewTracerProvider().Tracer(tracing.TracerName).Start(ctx, "NicAdd")
+		defer span.End()
+		_, err = cnimanager.UnaryServerTracingInterceptor(ctx, nil, info, handler)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(grpc_ctxtags.Extract(ctx).Values()).To(HaveKeyWithValue("trace.id", span.SpanContext().TraceID().String()))
+	})
+})
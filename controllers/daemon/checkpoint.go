@@ -0,0 +1,405 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+
+	"github.com/containernetworking/plugins/pkg/ns"
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netlink/nl"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/Azure/kube-egress-gateway/pkg/consts"
+)
+
+const checkpointVersion = 1
+
+// GatewayCheckpoint is the network configuration applied on the node for a StaticGatewayConfiguration.
+type GatewayCheckpoint struct {
+	// UID of the StaticGatewayConfiguration, also used by the LB health probe
+	UID string `json:"uid"`
+	// InterfaceName is the wireguard link in the gateway namespace
+	InterfaceName string `json:"interfaceName"`
+	// ListenPort is the wireguard listen port
+	ListenPort int32 `json:"listenPort"`
+	// SNATIP is the VM secondary IP that egress traffic is sNATed to, the first one if there are several
+	SNATIP string `json:"snatIP"`
+	// SNATIPs are all the VM secondary IPs that egress traffic is sNATed to, when there are several
+	SNATIPs []string `json:"snatIPs,omitempty"`
+	// ILBIPs are the ILB frontend IPs added to the host
+	ILBIPs []string `json:"ilbIPs,omitempty"`
+	// Mark is the connection mark of the gateway sNAT rules
+	Mark int `json:"mark"`
+	// Peers are the wireguard peers of the gateway, by PodEndpoint namespace/name
+	Peers map[string]PeerCheckpoint `json:"peers,omitempty"`
+}
+
+// snatIPs returns all the sNAT IPs of the gateway.
+func (g GatewayCheckpoint) snatIPs() []string {
+	if len(g.SNATIPs) > 0 {
+		return g.SNATIPs
+	}
+	return []string{g.SNATIP}
+}
+
+// PeerCheckpoint is the wireguard peer configured for a PodEndpoint.
+type PeerCheckpoint struct {
+	PublicKey string `json:"publicKey"`
+	PodIP     string `json:"podIP"`
+}
+
+type checkpointFile struct {
+	Version int `json:"version"`
+	// Gateways by StaticGatewayConfiguration namespace/name
+	Gateways map[string]*GatewayCheckpoint `json:"gateways"`
+}
+
+// Checkpoint persists the network configuration applied by the daemon on the node, so that a
+// restarted daemon does not tear down configuration it cannot justify yet. Gateways and peers
+// loaded from the checkpoint are protected from cleanup until the API server state is confirmed
+// by a reconcile. A nil *Checkpoint is valid and disables checkpointing.
+type Checkpoint struct {
+	path string
+	lock sync.Mutex
+	data checkpointFile
+	// checkpointed gateways and peers not confirmed by a reconcile since the daemon started
+	unconfirmedGateways map[string]bool
+	unconfirmedPeers    map[string]bool
+}
+
+// NewCheckpoint creates a Checkpoint stored in path.
+func NewCheckpoint(path string) *Checkpoint {
+	return &Checkpoint{
+		path:                path,
+		data:                checkpointFile{Version: checkpointVersion, Gateways: map[string]*GatewayCheckpoint{}},
+		unconfirmedGateways: map[string]bool{},
+		unconfirmedPeers:    map[string]bool{},
+	}
+}
+
+// Load reads the checkpoint file, a missing file is an empty checkpoint.
+// Everything loaded is unconfirmed until reported by the reconcilers.
+func (c *Checkpoint) Load() error {
+	if c == nil {
+		return nil
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	content, err := os.ReadFile(c.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("failed to read checkpoint %s: %w", c.path, err)
+	}
+	data := checkpointFile{}
+	if err := json.Unmarshal(content, &data); err != nil {
+		return fmt.Errorf("failed to parse checkpoint %s: %w", c.path, err)
+	}
+	if data.Version != checkpointVersion {
+		return fmt.Errorf("unsupported checkpoint version %d in %s", data.Version, c.path)
+	}
+	if data.Gateways == nil {
+		data.Gateways = map[string]*GatewayCheckpoint{}
+	}
+	c.data = data
+	for gwKey, gateway := range c.data.Gateways {
+		c.unconfirmedGateways[gwKey] = true
+		for peerKey := range gateway.Peers {
+			c.unconfirmedPeers[peerKey] = true
+		}
+	}
+	return nil
+}
+
+// Gateways returns a copy of the checkpointed gateways.
+func (c *Checkpoint) Gateways() map[string]GatewayCheckpoint {
+	if c == nil {
+		return nil
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	gateways := make(map[string]GatewayCheckpoint, len(c.data.Gateways))
+	for gwKey, gateway := range c.data.Gateways {
+		gateways[gwKey] = *gateway
+	}
+	return gateways
+}
+
+// setGateway records the configuration applied for a gateway and confirms it.
+func (c *Checkpoint) setGateway(gwKey string, gateway GatewayCheckpoint) error {
+	if c == nil {
+		return nil
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	delete(c.unconfirmedGateways, gwKey)
+	if existing, ok := c.data.Gateways[gwKey]; ok {
+		gateway.Peers = existing.Peers
+		if reflect.DeepEqual(*existing, gateway) {
+			return nil
+		}
+	}
+	c.data.Gateways[gwKey] = &gateway
+	return c.save()
+}
+
+// deleteGateway removes the gateway using the wireguard link interfaceName.
+func (c *Checkpoint) deleteGateway(interfaceName string) error {
+	if c == nil {
+		return nil
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for gwKey, gateway := range c.data.Gateways {
+		if gateway.InterfaceName == interfaceName {
+			delete(c.data.Gateways, gwKey)
+			delete(c.unconfirmedGateways, gwKey)
+			return c.save()
+		}
+	}
+	return nil
+}
+
+// setPeer records the peer applied for a PodEndpoint and confirms it.
+// Peers of gateways that are not checkpointed yet are not recorded.
+func (c *Checkpoint) setPeer(gwKey, peerKey string, peer PeerCheckpoint) error {
+	if c == nil {
+		return nil
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	delete(c.unconfirmedPeers, peerKey)
+	gateway, ok := c.data.Gateways[gwKey]
+	if !ok {
+		return nil
+	}
+	if existing, ok := gateway.Peers[peerKey]; ok && existing == peer {
+		return nil
+	}
+	if gateway.Peers == nil {
+		gateway.Peers = map[string]PeerCheckpoint{}
+	}
+	gateway.Peers[peerKey] = peer
+	return c.save()
+}
+
+// deletePeer removes the peer with publicKey from the gateway using the wireguard link interfaceName.
+func (c *Checkpoint) deletePeer(interfaceName, publicKey string) error {
+	if c == nil {
+		return nil
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, gateway := range c.data.Gateways {
+		if gateway.InterfaceName != interfaceName {
+			continue
+		}
+		for peerKey, peer := range gateway.Peers {
+			if peer.PublicKey == publicKey {
+				delete(gateway.Peers, peerKey)
+				delete(c.unconfirmedPeers, peerKey)
+				return c.save()
+			}
+		}
+	}
+	return nil
+}
+
+// confirmGateway marks a gateway as confirmed by the API server, either reconciled or not applying anymore.
+func (c *Checkpoint) confirmGateway(gwKey string) {
+	if c == nil {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	delete(c.unconfirmedGateways, gwKey)
+}
+
+// confirmPeer marks a peer as confirmed by the API server.
+func (c *Checkpoint) confirmPeer(peerKey string) {
+	if c == nil {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	delete(c.unconfirmedPeers, peerKey)
+}
+
+// unconfirmedGateway returns the checkpointed gateway if it has not been confirmed yet.
+func (c *Checkpoint) unconfirmedGateway(gwKey string) (GatewayCheckpoint, bool) {
+	if c == nil {
+		return GatewayCheckpoint{}, false
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	gateway, ok := c.data.Gateways[gwKey]
+	if !ok || !c.unconfirmedGateways[gwKey] {
+		return GatewayCheckpoint{}, false
+	}
+	return *gateway, true
+}
+
+// unconfirmedPeer returns the checkpointed peer if it has not been confirmed yet.
+func (c *Checkpoint) unconfirmedPeer(peerKey string) (string, PeerCheckpoint, bool) {
+	if c == nil {
+		return "", PeerCheckpoint{}, false
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if !c.unconfirmedPeers[peerKey] {
+		return "", PeerCheckpoint{}, false
+	}
+	for _, gateway := range c.data.Gateways {
+		if peer, ok := gateway.Peers[peerKey]; ok {
+			return gateway.InterfaceName, peer, true
+		}
+	}
+	return "", PeerCheckpoint{}, false
+}
+
+// save writes the checkpoint atomically, the caller must hold the lock.
+func (c *Checkpoint) save() error {
+	content, err := json.MarshalIndent(c.data, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal checkpoint: %w", err)
+	}
+	dir := filepath.Dir(c.path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create checkpoint directory %s: %w", dir, err)
+	}
+	tmp, err := os.CreateTemp(dir, filepath.Base(c.path)+".tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary checkpoint file: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.Write(content); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to sync checkpoint: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close checkpoint: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), c.path); err != nil {
+		return fmt.Errorf("failed to replace checkpoint %s: %w", c.path, err)
+	}
+	return nil
+}
+
+// RestoreCheckpoint verifies the network configuration recorded in the checkpoint against the kernel
+// and reports the gateways found intact to the LB health probe right away, so that traffic keeps
+// flowing while the restarted daemon waits for informer sync and reconciles.
+// It must be called after SetupWithManager.
+func (r *StaticGatewayConfigurationReconciler) RestoreCheckpoint(ctx context.Context) error {
+	log := log.FromContext(ctx)
+	gateways := r.Checkpoint.Gateways()
+	if len(gateways) == 0 {
+		return nil
+	}
+
+	eth0, err := r.Netlink.LinkByName("eth0")
+	if err != nil {
+		return fmt.Errorf("failed to retrieve link eth0: %w", err)
+	}
+	hostAddrs, err := r.Netlink.AddrList(eth0, nl.FAMILY_ALL)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve IP addresses for eth0: %w", err)
+	}
+
+	gwns, err := r.NetNS.GetNS(consts.GatewayNetnsName)
+	if err != nil {
+		return fmt.Errorf("failed to get network namespace %s: %w", consts.GatewayNetnsName, err)
+	}
+	defer func() { _ = gwns.Close() }()
+
+	for gwKey, gateway := range gateways {
+		if err := r.verifyCheckpoint(gwns, hostAddrs, gateway); err != nil {
+			log.Info("Gateway network differs from checkpoint, waiting for reconcile", "gwConfig", gwKey, "reason", err.Error())
+			continue
+		}
+		log.Info("Restored gateway from checkpoint", "gwConfig", gwKey)
+		if err := r.LBProbeServer.AddGateway(gateway.UID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *StaticGatewayConfigurationReconciler) verifyCheckpoint(gwns ns.NetNS, hostAddrs []netlink.Addr, gateway GatewayCheckpoint) error {
+	for _, ilbIP := range gateway.ILBIPs {
+		if !hasAddr(hostAddrs, ilbIP) {
+			return fmt.Errorf("ILB IP %s not found on eth0", ilbIP)
+		}
+	}
+
+	return gwns.Do(func(nn ns.NetNS) error {
+		wgLink, err := r.Netlink.LinkByName(gateway.InterfaceName)
+		if err != nil {
+			return fmt.Errorf("failed to get wireguard link %s: %w", gateway.InterfaceName, err)
+		}
+		if wgLink.Attrs().Flags&net.FlagUp == 0 {
+			return fmt.Errorf("wireguard link %s is down", gateway.InterfaceName)
+		}
+
+		wgClient, err := r.WgCtrl.New()
+		if err != nil {
+			return fmt.Errorf("failed to create wgctrl client: %w", err)
+		}
+		defer func() { _ = wgClient.Close() }()
+		device, err := wgClient.Device(gateway.InterfaceName)
+		if err != nil {
+			return fmt.Errorf("failed to get wireguard link configuration: %w", err)
+		}
+		if device.ListenPort != int(gateway.ListenPort) {
+			return fmt.Errorf("wireguard link %s listens on port %d instead of %d", gateway.InterfaceName, device.ListenPort, gateway.ListenPort)
+		}
+		devicePeers := make(map[string]bool, len(device.Peers))
+		for _, peer := range device.Peers {
+			devicePeers[peer.PublicKey.String()] = true
+		}
+		for peerKey, peer := range gateway.Peers {
+			if !devicePeers[peer.PublicKey] {
+				return fmt.Errorf("wireguard peer of PodEndpoint %s not found", peerKey)
+			}
+		}
+
+		hostLink, err := r.Netlink.LinkByName(consts.HostLinkName)
+		if err != nil {
+			return fmt.Errorf("failed to get host link in gateway namespace: %w", err)
+		}
+		addrs, err := r.Netlink.AddrList(hostLink, nl.FAMILY_ALL)
+		if err != nil {
+			return fmt.Errorf("failed to list addresses on host0 in gateway namespace: %w", err)
+		}
+		for _, snatIP := range gateway.snatIPs() {
+			if !hasAddr(addrs, snatIP) {
+				return fmt.Errorf("sNAT IP %s not found on host0", snatIP)
+			}
+		}
+		return nil
+	})
+}
+
+func hasAddr(addrs []netlink.Addr, ip string) bool {
+	for _, addr := range addrs {
+		if addr.IP.String() == ip {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,205 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+package daemon
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netlink/nl"
+	"go.uber.org/mock/gomock"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+
+	"github.com/Azure/kube-egress-gateway/pkg/consts"
+	"github.com/Azure/kube-egress-gateway/pkg/healthprobe"
+	"github.com/Azure/kube-egress-gateway/pkg/netlinkwrapper/mocknetlinkwrapper"
+	"github.com/Azure/kube-egress-gateway/pkg/netnswrapper/mocknetnswrapper"
+	"github.com/Azure/kube-egress-gateway/pkg/wgctrlwrapper/mockwgctrlwrapper"
+)
+
+var _ = Describe("Daemon checkpoint unit tests", func() {
+	var (
+		path    string
+		gwKey   = testNamespace + "/" + testName
+		peerKey = testNamespace + "/pod"
+		gateway = GatewayCheckpoint{
+			UID:           testUID,
+			InterfaceName: "wg-6000",
+			ListenPort:    6000,
+			SNATIP:        "10.0.0.6",
+			ILBIPs:        []string{ilbIP},
+			Mark:          6000,
+		}
+		peer = PeerCheckpoint{PublicKey: pubK, PodIP: "10.244.0.10/32"}
+	)
+
+	BeforeEach(func() {
+		path = filepath.Join(GinkgoT().TempDir(), "checkpoint", "checkpoint.json")
+	})
+
+	Context("Test persistence", func() {
+		It("should start empty when checkpoint does not exist", func() {
+			c := NewCheckpoint(path)
+			Expect(c.Load()).To(Succeed())
+			Expect(c.Gateways()).To(BeEmpty())
+		})
+
+		It("should restore applied configuration as unconfirmed", func() {
+			c := NewCheckpoint(path)
+			Expect(c.setGateway(gwKey, gateway)).To(Succeed())
+			Expect(c.setPeer(gwKey, peerKey, peer)).To(Succeed())
+			// nothing written by the current daemon needs protection
+			_, ok := c.unconfirmedGateway(gwKey)
+			Expect(ok).To(BeFalse())
+
+			restored := NewCheckpoint(path)
+			Expect(restored.Load()).To(Succeed())
+			expected := gateway
+			expected.Peers = map[string]PeerCheckpoint{peerKey: peer}
+			Expect(restored.Gateways()).To(Equal(map[string]GatewayCheckpoint{gwKey: expected}))
+			unconfirmed, ok := restored.unconfirmedGateway(gwKey)
+			Expect(ok).To(BeTrue())
+			Expect(unconfirmed.InterfaceName).To(Equal("wg-6000"))
+			wgLinkName, unconfirmedPeer, ok := restored.unconfirmedPeer(peerKey)
+			Expect(ok).To(BeTrue())
+			Expect(wgLinkName).To(Equal("wg-6000"))
+			Expect(unconfirmedPeer).To(Equal(peer))
+
+			restored.confirmGateway(gwKey)
+			restored.confirmPeer(peerKey)
+			_, ok = restored.unconfirmedGateway(gwKey)
+			Expect(ok).To(BeFalse())
+			_, _, ok = restored.unconfirmedPeer(peerKey)
+			Expect(ok).To(BeFalse())
+		})
+
+		It("should keep peers when gateway is updated", func() {
+			c := NewCheckpoint(path)
+			Expect(c.setGateway(gwKey, gateway)).To(Succeed())
+			Expect(c.setPeer(gwKey, peerKey, peer)).To(Succeed())
+			updated := gateway
+			updated.SNATIP = "10.0.0.7"
+			Expect(c.setGateway(gwKey, updated)).To(Succeed())
+			Expect(c.Gateways()[gwKey].SNATIP).To(Equal("10.0.0.7"))
+			Expect(c.Gateways()[gwKey].Peers).To(HaveKey(peerKey))
+		})
+
+		It("should not record peers of unknown gateways", func() {
+			c := NewCheckpoint(path)
+			Expect(c.setPeer(gwKey, peerKey, peer)).To(Succeed())
+			Expect(c.Gateways()).To(BeEmpty())
+			_, err := os.Stat(path)
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+
+		It("should delete peers and gateways", func() {
+			c := NewCheckpoint(path)
+			Expect(c.setGateway(gwKey, gateway)).To(Succeed())
+			Expect(c.setPeer(gwKey, peerKey, peer)).To(Succeed())
+			Expect(c.deletePeer("wg-6000", pubK)).To(Succeed())
+			Expect(c.Gateways()[gwKey].Peers).To(BeEmpty())
+			Expect(c.deleteGateway("wg-6000")).To(Succeed())
+
+			restored := NewCheckpoint(path)
+			Expect(restored.Load()).To(Succeed())
+			Expect(restored.Gateways()).To(BeEmpty())
+		})
+
+		It("should report error for corrupted checkpoint", func() {
+			Expect(os.MkdirAll(filepath.Dir(path), 0700)).To(Succeed())
+			Expect(os.WriteFile(path, []byte("{"), 0600)).To(Succeed())
+			Expect(NewCheckpoint(path).Load()).NotTo(Succeed())
+			Expect(os.WriteFile(path, []byte(`{"version": 100}`), 0600)).To(Succeed())
+			Expect(NewCheckpoint(path).Load()).NotTo(Succeed())
+		})
+
+		It("should be a no-op when checkpointing is disabled", func() {
+			var c *Checkpoint
+			Expect(c.Load()).To(Succeed())
+			Expect(c.setGateway(gwKey, gateway)).To(Succeed())
+			Expect(c.setPeer(gwKey, peerKey, peer)).To(Succeed())
+			Expect(c.Gateways()).To(BeEmpty())
+			_, ok := c.unconfirmedGateway(gwKey)
+			Expect(ok).To(BeFalse())
+		})
+	})
+
+	Context("Test restore", func() {
+		var (
+			r       *StaticGatewayConfigurationReconciler
+			mnl     *mocknetlinkwrapper.MockInterface
+			mns     *mocknetnswrapper.MockInterface
+			mwg     *mockwgctrlwrapper.MockInterface
+			mclient *mockwgctrlwrapper.MockClient
+			eth0    = &netlink.Device{LinkAttrs: netlink.LinkAttrs{Name: "eth0"}}
+			host0   = &netlink.Device{LinkAttrs: netlink.LinkAttrs{Name: "host0"}}
+			wg0     = &netlink.Wireguard{LinkAttrs: netlink.LinkAttrs{Name: "wg-6000", Flags: net.FlagUp}}
+			gwns    = &mocknetnswrapper.MockNetNS{Name: consts.GatewayNetnsName}
+		)
+
+		BeforeEach(func() {
+			mctrl := gomock.NewController(GinkgoT())
+			mnl = mocknetlinkwrapper.NewMockInterface(mctrl)
+			mns = mocknetnswrapper.NewMockInterface(mctrl)
+			mwg = mockwgctrlwrapper.NewMockInterface(mctrl)
+			mclient = mockwgctrlwrapper.NewMockClient(mctrl)
+			c := NewCheckpoint(path)
+			Expect(c.setGateway(gwKey, gateway)).To(Succeed())
+			Expect(c.setPeer(gwKey, peerKey, peer)).To(Succeed())
+			r = &StaticGatewayConfigurationReconciler{
+				LBProbeServer: healthprobe.NewLBProbeServer(1000, 0),
+				Checkpoint:    NewCheckpoint(path),
+				Netlink:       mnl,
+				NetNS:         mns,
+				WgCtrl:        mwg,
+			}
+			Expect(r.Checkpoint.Load()).To(Succeed())
+		})
+
+		It("should report intact gateways healthy", func() {
+			pubKey, _ := wgtypes.ParseKey(pubK)
+			gomock.InOrder(
+				mnl.EXPECT().LinkByName("eth0").Return(eth0, nil),
+				mnl.EXPECT().AddrList(eth0, nl.FAMILY_ALL).Return([]netlink.Addr{{IPNet: getIPNetWithActualIP(ilbIPCidr), Label: consts.ILBIPLabel}}, nil),
+				mns.EXPECT().GetNS(consts.GatewayNetnsName).Return(gwns, nil),
+				mnl.EXPECT().LinkByName("wg-6000").Return(wg0, nil),
+				mwg.EXPECT().New().Return(mclient, nil),
+				mclient.EXPECT().Device("wg-6000").Return(&wgtypes.Device{ListenPort: 6000, Peers: []wgtypes.Peer{{PublicKey: pubKey}}}, nil),
+				mnl.EXPECT().LinkByName("host0").Return(host0, nil),
+				mnl.EXPECT().AddrList(host0, nl.FAMILY_ALL).Return([]netlink.Addr{{IPNet: getIPNet("10.0.0.6/32")}}, nil),
+				mclient.EXPECT().Close().Return(nil),
+			)
+			Expect(r.RestoreCheckpoint(context.TODO())).To(Succeed())
+			Expect(r.LBProbeServer.GetGateways()).To(Equal([]string{testUID}))
+		})
+
+		It("should wait for reconcile when wireguard peer is missing", func() {
+			gomock.InOrder(
+				mnl.EXPECT().LinkByName("eth0").Return(eth0, nil),
+				mnl.EXPECT().AddrList(eth0, nl.FAMILY_ALL).Return([]netlink.Addr{{IPNet: getIPNetWithActualIP(ilbIPCidr), Label: consts.ILBIPLabel}}, nil),
+				mns.EXPECT().GetNS(consts.GatewayNetnsName).Return(gwns, nil),
+				mnl.EXPECT().LinkByName("wg-6000").Return(wg0, nil),
+				mwg.EXPECT().New().Return(mclient, nil),
+				mclient.EXPECT().Device("wg-6000").Return(&wgtypes.Device{ListenPort: 6000}, nil),
+				mclient.EXPECT().Close().Return(nil),
+			)
+			Expect(r.RestoreCheckpoint(context.TODO())).To(Succeed())
+			Expect(r.LBProbeServer.GetGateways()).To(BeEmpty())
+		})
+
+		It("should wait for reconcile when ILB IP is missing", func() {
+			gomock.InOrder(
+				mnl.EXPECT().LinkByName("eth0").Return(eth0, nil),
+				mnl.EXPECT().AddrList(eth0, nl.FAMILY_ALL).Return([]netlink.Addr{}, nil),
+				mns.EXPECT().GetNS(consts.GatewayNetnsName).Return(gwns, nil),
+			)
+			Expect(r.RestoreCheckpoint(context.TODO())).To(Succeed())
+			Expect(r.LBProbeServer.GetGateways()).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,272 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/containernetworking/plugins/pkg/ns"
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netlink/nl"
+	"golang.org/x/sys/unix"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	egressgatewayv1alpha1 "github.com/Azure/kube-egress-gateway/api/v1alpha1"
+	"github.com/Azure/kube-egress-gateway/pkg/consts"
+	"github.com/Azure/kube-egress-gateway/pkg/healthprobe"
+	"github.com/Azure/kube-egress-gateway/pkg/metrics"
+)
+
+const (
+	dataPathCheckConfiguration = "configuration"
+	dataPathCheckWireguard     = "wireguard"
+	dataPathCheckSNAT          = "snat"
+	dataPathCheckRoutes        = "routes"
+	dataPathCheckProbe         = "probe"
+
+	// DefaultDataPathCheckInterval is the default interval of the gateway data path checks
+	DefaultDataPathCheckInterval = 10 * time.Second
+
+	// dataPathProbeTimeout is the timeout of the active probe from the gateway namespace
+	dataPathProbeTimeout = 3 * time.Second
+
+	// snatPortRange is the number of ports sNAT allocates unprivileged source ports from, i.e. 1024-65535
+	snatPortRange = 65535 - 1024 + 1
+)
+
+var _ healthprobe.Checker = &StaticGatewayConfigurationReconciler{}
+
+// CheckGateway implements healthprobe.Checker. It checks that the network configuration of the gateway
+// applied by the last reconcile is still in place: the wireguard link is up and listens on the gateway
+// port, the sNAT rules use the VM secondary IP and the routes between the host and the gateway namespace
+// exist. If DataPathProbeAddress is set, it also connects to it from the gateway namespace.
+func (r *StaticGatewayConfigurationReconciler) CheckGateway(ctx context.Context, gatewayUID string) []healthprobe.CheckResult {
+	gwConfig, err := r.getGatewayByUID(ctx, gatewayUID)
+	if err != nil {
+		return []healthprobe.CheckResult{dataPathCheckResult(dataPathCheckConfiguration, err)}
+	}
+	// getVMIP logs every lookup, which is too noisy for periodic checks
+	vmPrimaryIP, vmSecondaryIPs, _, err := r.getVMIP(log.IntoContext(ctx, logr.Discard()), gwConfig)
+	if err != nil {
+		return []healthprobe.CheckResult{dataPathCheckResult(dataPathCheckConfiguration, err)}
+	}
+
+	gwns, err := r.NetNS.GetNS(consts.GatewayNetnsName)
+	if err != nil {
+		err = fmt.Errorf("failed to get network namespace %s: %w", consts.GatewayNetnsName, err)
+		return []healthprobe.CheckResult{dataPathCheckResult(dataPathCheckConfiguration, err)}
+	}
+	defer func() { _ = gwns.Close() }()
+
+	linkName := getWireguardInterfaceName(gwConfig)
+	results := []healthprobe.CheckResult{
+		dataPathCheckResult(dataPathCheckWireguard, r.checkWireguardLink(gwns, linkName, int(gwConfig.Status.Port))),
+		dataPathCheckResult(dataPathCheckSNAT, r.checkGatewaySNAT(ctx, gwns, linkName, vmSecondaryIPs)),
+		dataPathCheckResult(dataPathCheckRoutes, r.checkGatewayRoutes(gwns, vmPrimaryIP, vmSecondaryIPs)),
+	}
+	if r.DataPathProbeAddress != "" {
+		results = append(results, dataPathCheckResult(dataPathCheckProbe, r.probeDataPath(gwns, vmSecondaryIPs[0])))
+	}
+	if err := r.observeSNATPortUtilization(gwns, client.ObjectKeyFromObject(gwConfig).String(), linkName, vmSecondaryIPs); err != nil {
+		log.FromContext(ctx).Error(err, "failed to observe sNAT port utilization", "gateway", gatewayUID)
+	}
+	return results
+}
+
+func dataPathCheckResult(name string, err error) healthprobe.CheckResult {
+	if err != nil {
+		return healthprobe.CheckResult{Name: name, Healthy: false, Message: err.Error()}
+	}
+	return healthprobe.CheckResult{Name: name, Healthy: true}
+}
+
+func (r *StaticGatewayConfigurationReconciler) getGatewayByUID(
+	ctx context.Context,
+	gatewayUID string,
+) (*egressgatewayv1alpha1.StaticGatewayConfiguration, error) {
+	gwConfigList := &egressgatewayv1alpha1.StaticGatewayConfigurationList{}
+	if err := r.List(ctx, gwConfigList); err != nil {
+		return nil, fmt.Errorf("failed to list staticGatewayConfigurations: %w", err)
+	}
+	for i := range gwConfigList.Items {
+		if string(gwConfigList.Items[i].GetUID()) == gatewayUID {
+			return &gwConfigList.Items[i], nil
+		}
+	}
+	return nil, fmt.Errorf("staticGatewayConfiguration with UID %s not found", gatewayUID)
+}
+
+func (r *StaticGatewayConfigurationReconciler) checkWireguardLink(gwns ns.NetNS, linkName string, listenPort int) error {
+	return gwns.Do(func(nn ns.NetNS) error {
+		wgLink, err := r.Netlink.LinkByName(linkName)
+		if err != nil {
+			return fmt.Errorf("failed to get wireguard link %s: %w", linkName, err)
+		}
+		if wgLink.Attrs().Flags&net.FlagUp == 0 {
+			return fmt.Errorf("wireguard link %s is down", linkName)
+		}
+
+		wgClient, err := r.WgCtrl.New()
+		if err != nil {
+			return fmt.Errorf("failed to create wgctrl client: %w", err)
+		}
+		defer func() { _ = wgClient.Close() }()
+		device, err := wgClient.Device(linkName)
+		if err != nil {
+			return fmt.Errorf("failed to get wireguard link configuration: %w", err)
+		}
+		if device.ListenPort != listenPort {
+			return fmt.Errorf("wireguard link %s listens on port %d instead of %d", linkName, device.ListenPort, listenPort)
+		}
+		return nil
+	})
+}
+
+func (r *StaticGatewayConfigurationReconciler) checkGatewaySNAT(ctx context.Context, gwns ns.NetNS, linkName string, snatIPs []string) error {
+	mark, err := getPacketMark(linkName)
+	if err != nil {
+		return err
+	}
+	return gwns.Do(func(nn ns.NetNS) error {
+		ok, err := r.Netfilter.CheckGatewaySNAT(ctx, linkName, mark, snatIPs)
+		if err != nil {
+			return fmt.Errorf("failed to check sNAT rules: %w", err)
+		}
+		if !ok {
+			return fmt.Errorf("sNAT rules of link %s to %s are missing", linkName, strings.Join(snatIPs, ","))
+		}
+		return nil
+	})
+}
+
+// observeSNATPortUtilization reports the source port utilization of each sNAT IP of the gateway from conntrack.
+// An sNAT IP runs out of source ports per destination address, port and protocol, so the busiest destination
+// of each IP is reported.
+func (r *StaticGatewayConfigurationReconciler) observeSNATPortUtilization(gwns ns.NetNS, gateway, linkName string, snatIPs []string) error {
+	mark, err := getPacketMark(linkName)
+	if err != nil {
+		return err
+	}
+	type destination struct {
+		snatIP   string
+		protocol uint8
+		ip       string
+		port     uint16
+	}
+	ports := make(map[destination]int)
+	if err := gwns.Do(func(nn ns.NetNS) error {
+		flows, err := r.Netlink.ConntrackTableList(netlink.ConntrackTable, unix.AF_INET)
+		if err != nil {
+			return fmt.Errorf("failed to list conntrack flows: %w", err)
+		}
+		for _, flow := range flows {
+			if flow.Mark != uint32(mark) {
+				continue
+			}
+			// replies of sNATed connections are destined to the sNAT IP
+			ports[destination{
+				snatIP:   flow.Reverse.DstIP.String(),
+				protocol: flow.Forward.Protocol,
+				ip:       flow.Forward.DstIP.String(),
+				port:     flow.Forward.DstPort,
+			}]++
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	used := make(map[string]int)
+	for dst, n := range ports {
+		used[dst.snatIP] = max(used[dst.snatIP], n)
+	}
+	// sNAT IPs may have been removed since last check
+	clearSNATPortUtilization(gateway)
+	for _, snatIP := range snatIPs {
+		metrics.GatewayDaemonSNATPortUtilization.WithLabelValues(gateway, snatIP).Set(float64(used[snatIP]) / snatPortRange)
+	}
+	return nil
+}
+
+func clearSNATPortUtilization(gateway string) {
+	metrics.GatewayDaemonSNATPortUtilization.DeletePartialMatch(prometheus.Labels{"gateway": gateway})
+}
+
+// checkGatewayRoutes checks the routes added by reconcileVethPair.
+func (r *StaticGatewayConfigurationReconciler) checkGatewayRoutes(gwns ns.NetNS, vmPrimaryIP string, vmSecondaryIPs []string) error {
+	hostGatewayLink, err := r.Netlink.LinkByName(consts.HostVethLinkName)
+	if err != nil {
+		return fmt.Errorf("failed to get veth link in host namespace: %w", err)
+	}
+	routes, err := r.Netlink.RouteList(hostGatewayLink, nl.FAMILY_ALL)
+	if err != nil {
+		return fmt.Errorf("failed to list routes in host namespace: %w", err)
+	}
+	for _, vmSecondaryIP := range vmSecondaryIPs {
+		if !hasRoute(routes, func(route netlink.Route) bool {
+			return route.Dst != nil && route.Dst.IP.String() == vmSecondaryIP
+		}) {
+			return fmt.Errorf("route to sNAT IP %s via %s not found", vmSecondaryIP, consts.HostVethLinkName)
+		}
+	}
+
+	return gwns.Do(func(nn ns.NetNS) error {
+		hostLink, err := r.Netlink.LinkByName(consts.HostLinkName)
+		if err != nil {
+			return fmt.Errorf("failed to get host link in gateway namespace: %w", err)
+		}
+		routes, err := r.Netlink.RouteList(hostLink, nl.FAMILY_ALL)
+		if err != nil {
+			return fmt.Errorf("failed to list routes in gateway namespace: %w", err)
+		}
+		if !hasRoute(routes, func(route netlink.Route) bool {
+			return route.Dst != nil && route.Dst.IP.String() == vmPrimaryIP
+		}) {
+			return fmt.Errorf("route to VM primary IP %s via %s not found", vmPrimaryIP, consts.HostLinkName)
+		}
+		if !hasRoute(routes, func(route netlink.Route) bool {
+			return isDefaultRoute(route) && route.Gw.String() == vmPrimaryIP
+		}) {
+			return fmt.Errorf("default route via %s not found in gateway namespace", vmPrimaryIP)
+		}
+		return nil
+	})
+}
+
+// probeDataPath connects to DataPathProbeAddress from the gateway namespace with the sNAT IP as source,
+// the same path as the traffic egressing through the gateway.
+func (r *StaticGatewayConfigurationReconciler) probeDataPath(gwns ns.NetNS, snatIP string) error {
+	return gwns.Do(func(nn ns.NetNS) error {
+		dialer := &net.Dialer{
+			Timeout:   dataPathProbeTimeout,
+			LocalAddr: &net.TCPAddr{IP: net.ParseIP(snatIP)},
+		}
+		conn, err := dialer.Dial("tcp", r.DataPathProbeAddress)
+		if err != nil {
+			return fmt.Errorf("failed to connect to %s from %s: %w", r.DataPathProbeAddress, snatIP, err)
+		}
+		_ = conn.Close()
+		return nil
+	})
+}
+
+func hasRoute(routes []netlink.Route, match func(netlink.Route) bool) bool {
+	for _, route := range routes {
+		if match(route) {
+			return true
+		}
+	}
+	return false
+}
+
+func isDefaultRoute(route netlink.Route) bool {
+	return route.Dst == nil || route.Dst.IP.IsUnspecified()
+}
@@ -0,0 +1,237 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+package daemon
+
+import (
+	"context"
+	"net"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netlink/nl"
+	"go.uber.org/mock/gomock"
+	"golang.org/x/sys/unix"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	egressgatewayv1alpha1 "github.com/Azure/kube-egress-gateway/api/v1alpha1"
+	"github.com/Azure/kube-egress-gateway/pkg/consts"
+	"github.com/Azure/kube-egress-gateway/pkg/healthprobe"
+	"github.com/Azure/kube-egress-gateway/pkg/imds"
+	fakeiptables "github.com/Azure/kube-egress-gateway/pkg/iptableswrapper"
+	"github.com/Azure/kube-egress-gateway/pkg/metrics"
+	"github.com/Azure/kube-egress-gateway/pkg/netfilter"
+	"github.com/Azure/kube-egress-gateway/pkg/netlinkwrapper/mocknetlinkwrapper"
+	"github.com/Azure/kube-egress-gateway/pkg/netnswrapper/mocknetnswrapper"
+	"github.com/Azure/kube-egress-gateway/pkg/wgctrlwrapper/mockwgctrlwrapper"
+)
+
+var _ = Describe("Daemon gateway data path health unit tests", func() {
+	var (
+		r           *StaticGatewayConfigurationReconciler
+		mnl         *mocknetlinkwrapper.MockInterface
+		mns         *mocknetnswrapper.MockInterface
+		mwg         *mockwgctrlwrapper.MockInterface
+		mclient     *mockwgctrlwrapper.MockClient
+		gwConfig    *egressgatewayv1alpha1.StaticGatewayConfiguration
+		vmConfig    *egressgatewayv1alpha1.GatewayVMConfiguration
+		wg0         *netlink.Wireguard
+		hostGateway = &netlink.Veth{LinkAttrs: netlink.LinkAttrs{Name: consts.HostVethLinkName, Index: 10}}
+		host0       = &netlink.Veth{LinkAttrs: netlink.LinkAttrs{Name: consts.HostLinkName, Index: 2}}
+		gwns        = &mocknetnswrapper.MockNetNS{Name: consts.GatewayNetnsName}
+	)
+
+	getTestChecker := func() {
+		mctrl := gomock.NewController(GinkgoT())
+		cl := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(gwConfig, vmConfig).Build()
+		mnl = mocknetlinkwrapper.NewMockInterface(mctrl)
+		mns = mocknetnswrapper.NewMockInterface(mctrl)
+		mwg = mockwgctrlwrapper.NewMockInterface(mctrl)
+		mclient = mockwgctrlwrapper.NewMockClient(mctrl)
+		r = &StaticGatewayConfigurationReconciler{
+			Client:    cl,
+			Netlink:   mnl,
+			NetNS:     mns,
+			Netfilter: netfilter.NewIPTables(fakeiptables.NewFake()),
+			WgCtrl:    mwg,
+		}
+	}
+
+	expectWireguard := func(listenPort int) {
+		gomock.InOrder(
+			mnl.EXPECT().LinkByName("wg-6000").Return(wg0, nil),
+			mwg.EXPECT().New().Return(mclient, nil),
+			mclient.EXPECT().Device("wg-6000").Return(&wgtypes.Device{ListenPort: listenPort}, nil),
+			mclient.EXPECT().Close().Return(nil),
+		)
+	}
+
+	expectRoutes := func(gwnsRoutes ...netlink.Route) {
+		gomock.InOrder(
+			mnl.EXPECT().LinkByName(consts.HostVethLinkName).Return(hostGateway, nil),
+			mnl.EXPECT().RouteList(hostGateway, nl.FAMILY_ALL).Return([]netlink.Route{{LinkIndex: 10, Dst: getIPNet(vmConfig.Status.GatewayVMProfiles[0].SecondaryIP + "/32")}}, nil),
+			mnl.EXPECT().LinkByName(consts.HostLinkName).Return(host0, nil),
+			mnl.EXPECT().RouteList(host0, nl.FAMILY_ALL).Return(gwnsRoutes, nil),
+		)
+	}
+
+	expectConntrack := func(flows ...*netlink.ConntrackFlow) {
+		mnl.EXPECT().ConntrackTableList(netlink.ConntrackTableType(netlink.ConntrackTable), netlink.InetFamily(unix.AF_INET)).Return(flows, nil)
+	}
+
+	gwnsRoutes := []netlink.Route{
+		{LinkIndex: 2, Scope: netlink.SCOPE_LINK, Dst: getIPNet("10.0.0.5/32")},
+		{LinkIndex: 2, Gw: net.ParseIP("10.0.0.5")},
+	}
+
+	checkResults := func() map[string]healthprobe.CheckResult {
+		results := make(map[string]healthprobe.CheckResult)
+		for _, result := range r.CheckGateway(context.TODO(), testUID) {
+			results[result.Name] = result
+		}
+		return results
+	}
+
+	BeforeEach(func() {
+		origNodeMeta := nodeMeta
+		DeferCleanup(func() { nodeMeta = origNodeMeta })
+		nodeMeta = &imds.InstanceMetadata{
+			Compute: &imds.ComputeMetadata{OSProfile: imds.OSProfile{ComputerName: testNodeName}},
+		}
+		gwConfig = &egressgatewayv1alpha1.StaticGatewayConfiguration{
+			ObjectMeta: metav1.ObjectMeta{Name: testName, Namespace: testNamespace, UID: testUID},
+			Spec:       egressgatewayv1alpha1.StaticGatewayConfigurationSpec{GatewayNodepoolName: testNodepoolName},
+			Status:     getTestGwConfigStatus(),
+		}
+		vmConfig = &egressgatewayv1alpha1.GatewayVMConfiguration{
+			ObjectMeta: metav1.ObjectMeta{Name: testName, Namespace: testNamespace},
+			Status: &egressgatewayv1alpha1.GatewayVMConfigurationStatus{
+				GatewayVMProfiles: []egressgatewayv1alpha1.GatewayVMProfile{
+					{NodeName: testNodeName, PrimaryIP: "10.0.0.5", SecondaryIP: "10.0.0.6"},
+				},
+			},
+		}
+		wg0 = &netlink.Wireguard{LinkAttrs: netlink.LinkAttrs{Name: "wg-6000", Flags: net.FlagUp}}
+	})
+
+	It("should report healthy gateway", func() {
+		getTestChecker()
+		Expect(r.Netfilter.EnsureGatewaySNAT(context.TODO(), "wg-6000", 6000, []string{"10.0.0.6"}, nil, nil)).To(Succeed())
+		mns.EXPECT().GetNS(consts.GatewayNetnsName).Return(gwns, nil)
+		expectWireguard(6000)
+		expectRoutes(gwnsRoutes...)
+		expectConntrack()
+
+		results := r.CheckGateway(context.TODO(), testUID)
+		Expect(results).To(Equal([]healthprobe.CheckResult{
+			{Name: dataPathCheckWireguard, Healthy: true},
+			{Name: dataPathCheckSNAT, Healthy: true},
+			{Name: dataPathCheckRoutes, Healthy: true},
+		}))
+	})
+
+	It("should report broken data path", func() {
+		getTestChecker()
+		wg0.Flags = 0
+		mns.EXPECT().GetNS(consts.GatewayNetnsName).Return(gwns, nil)
+		mnl.EXPECT().LinkByName("wg-6000").Return(wg0, nil)
+		// default route is gone
+		expectRoutes(gwnsRoutes[0])
+		expectConntrack()
+
+		results := checkResults()
+		Expect(results).To(HaveLen(3))
+		Expect(results[dataPathCheckWireguard].Healthy).To(BeFalse())
+		Expect(results[dataPathCheckWireguard].Message).To(ContainSubstring("is down"))
+		Expect(results[dataPathCheckSNAT].Healthy).To(BeFalse())
+		Expect(results[dataPathCheckSNAT].Message).To(ContainSubstring("sNAT rules of link wg-6000 to 10.0.0.6 are missing"))
+		Expect(results[dataPathCheckRoutes].Healthy).To(BeFalse())
+		Expect(results[dataPathCheckRoutes].Message).To(ContainSubstring("default route"))
+	})
+
+	It("should report wrong wireguard listen port", func() {
+		getTestChecker()
+		Expect(r.Netfilter.EnsureGatewaySNAT(context.TODO(), "wg-6000", 6000, []string{"10.0.0.6"}, nil, nil)).To(Succeed())
+		mns.EXPECT().GetNS(consts.GatewayNetnsName).Return(gwns, nil)
+		expectWireguard(6001)
+		expectRoutes(gwnsRoutes...)
+		expectConntrack()
+
+		results := checkResults()
+		Expect(results[dataPathCheckWireguard].Healthy).To(BeFalse())
+		Expect(results[dataPathCheckWireguard].Message).To(ContainSubstring("listens on port 6001 instead of 6000"))
+		Expect(results[dataPathCheckSNAT].Healthy).To(BeTrue())
+		Expect(results[dataPathCheckRoutes].Healthy).To(BeTrue())
+	})
+
+	It("should report sNAT port utilization", func() {
+		vmConfig.Status.GatewayVMProfiles[0].SecondaryIPs = []string{"10.0.0.6", "10.0.0.7"}
+		getTestChecker()
+		Expect(r.Netfilter.EnsureGatewaySNAT(context.TODO(), "wg-6000", 6000, []string{"10.0.0.6", "10.0.0.7"}, nil, nil)).To(Succeed())
+		mns.EXPECT().GetNS(consts.GatewayNetnsName).Return(gwns, nil)
+		expectWireguard(6000)
+		mnl.EXPECT().LinkByName(consts.HostVethLinkName).Return(hostGateway, nil)
+		mnl.EXPECT().RouteList(hostGateway, nl.FAMILY_ALL).Return([]netlink.Route{
+			{LinkIndex: 10, Dst: getIPNet("10.0.0.6/32")},
+			{LinkIndex: 10, Dst: getIPNet("10.0.0.7/32")},
+		}, nil)
+		mnl.EXPECT().LinkByName(consts.HostLinkName).Return(host0, nil)
+		mnl.EXPECT().RouteList(host0, nl.FAMILY_ALL).Return(gwnsRoutes, nil)
+		flow := func(srcPort uint16, dstIP string, mark uint32) *netlink.ConntrackFlow {
+			return &netlink.ConntrackFlow{
+				Mark:    mark,
+				Forward: netlink.IPTuple{Protocol: unix.IPPROTO_TCP, SrcIP: net.ParseIP("10.243.0.4"), DstIP: net.ParseIP(dstIP), SrcPort: srcPort, DstPort: 443},
+				Reverse: netlink.IPTuple{Protocol: unix.IPPROTO_TCP, SrcIP: net.ParseIP(dstIP), DstIP: net.ParseIP("10.0.0.6"), SrcPort: 443, DstPort: srcPort},
+			}
+		}
+		expectConntrack(
+			flow(1000, "1.1.1.1", 6000),
+			flow(1001, "1.1.1.1", 6000),
+			flow(1002, "1.1.1.2", 6000),
+			// not sNATed by this gateway
+			flow(1003, "1.1.1.1", 6001),
+		)
+
+		results := checkResults()
+		Expect(results[dataPathCheckSNAT].Healthy).To(BeTrue())
+		Expect(results[dataPathCheckRoutes].Healthy).To(BeTrue())
+		gateway := testNamespace + "/" + testName
+		Expect(testutil.ToFloat64(metrics.GatewayDaemonSNATPortUtilization.WithLabelValues(gateway, "10.0.0.6"))).To(Equal(2.0 / snatPortRange))
+		Expect(testutil.ToFloat64(metrics.GatewayDaemonSNATPortUtilization.WithLabelValues(gateway, "10.0.0.7"))).To(BeZero())
+	})
+
+	It("should report unknown gateway", func() {
+		getTestChecker()
+		results := r.CheckGateway(context.TODO(), "unknown")
+		Expect(results).To(HaveLen(1))
+		Expect(results[0].Name).To(Equal(dataPathCheckConfiguration))
+		Expect(results[0].Healthy).To(BeFalse())
+	})
+
+	It("should probe data path from gateway namespace", func() {
+		vmConfig.Status.GatewayVMProfiles[0].SecondaryIP = "127.0.0.1"
+		getTestChecker()
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		r.DataPathProbeAddress = listener.Addr().String()
+		Expect(r.Netfilter.EnsureGatewaySNAT(context.TODO(), "wg-6000", 6000, []string{"127.0.0.1"}, nil, nil)).To(Succeed())
+
+		mns.EXPECT().GetNS(consts.GatewayNetnsName).Return(gwns, nil).Times(2)
+		expectWireguard(6000)
+		expectRoutes(gwnsRoutes...)
+		expectConntrack()
+		Expect(checkResults()[dataPathCheckProbe]).To(Equal(healthprobe.CheckResult{Name: dataPathCheckProbe, Healthy: true}))
+
+		Expect(listener.Close()).To(Succeed())
+		expectWireguard(6000)
+		expectRoutes(gwnsRoutes...)
+		expectConntrack()
+		result := checkResults()[dataPathCheckProbe]
+		Expect(result.Healthy).To(BeFalse())
+		Expect(result.Message).To(ContainSubstring("failed to connect to " + r.DataPathProbeAddress))
+	})
+})
@@ -0,0 +1,448 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package daemon
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/containernetworking/plugins/pkg/ns"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	"github.com/Azure/kube-egress-gateway/pkg/consts"
+	"github.com/Azure/kube-egress-gateway/pkg/healthprobe"
+	"github.com/Azure/kube-egress-gateway/pkg/netfilter"
+	"github.com/Azure/kube-egress-gateway/pkg/netlinkwrapper"
+	"github.com/Azure/kube-egress-gateway/pkg/netnswrapper"
+	"github.com/Azure/kube-egress-gateway/pkg/wgctrlwrapper"
+)
+
+const (
+	// DiagnosticsPath is the path of the diagnostics report on the debug endpoint
+	DiagnosticsPath = "/debug"
+
+	// redacted replaces secret keys in the diagnostics report
+	redacted = "<redacted>"
+)
+
+var _ manager.Runnable = &Diagnostics{}
+
+// Diagnostics serves a report of the gateway network configuration of this node on a local debug endpoint,
+// so that gateways can be debugged without logging in to the node. The endpoint only listens on localhost and
+// requires the bearer token written to TokenFile at startup, so only processes in the daemon container can read it.
+//
+// It also keeps the last reconcile error of every object, reported by the reconcilers.
+type Diagnostics struct {
+	// Port is the port of the debug endpoint, it only listens on localhost
+	Port int
+	// TokenFile is the file the bearer token of the debug endpoint is written to
+	TokenFile       string
+	LBProbeServer   *healthprobe.LBProbeServer
+	FirewallBackend netfilter.Backend
+	Netlink         netlinkwrapper.Interface
+	NetNS           netnswrapper.Interface
+	Netfilter       netfilter.Interface
+	WgCtrl          wgctrlwrapper.Interface
+
+	lock  sync.Mutex
+	token string
+	// controller/namespace/name -> last reconcile error
+	reconcileErrors map[string]ReconcileError
+}
+
+// DiagnosticsReport is the response of the debug endpoint.
+type DiagnosticsReport struct {
+	NodeName        string            `json:"nodeName"`
+	Time            time.Time         `json:"time"`
+	FirewallBackend string            `json:"firewallBackend"`
+	Namespaces      []NamespaceReport `json:"namespaces"`
+	LBProbe         []LBProbeReport   `json:"lbProbe,omitempty"`
+	ReconcileErrors []ReconcileError  `json:"reconcileErrors,omitempty"`
+	// Errors are the parts of the report that could not be collected
+	Errors []string `json:"errors,omitempty"`
+}
+
+// NamespaceReport is the network configuration of a network namespace.
+type NamespaceReport struct {
+	Name   string       `json:"name"`
+	Links  []LinkReport `json:"links,omitempty"`
+	Routes []string     `json:"routes,omitempty"`
+	Rules  []string     `json:"rules,omitempty"`
+	// Firewall is the dump of the netfilter rules owned by kube-egress-gateway
+	Firewall  string                  `json:"firewall,omitempty"`
+	Wireguard []WireguardDeviceReport `json:"wireguard,omitempty"`
+}
+
+// LinkReport is a network link and its addresses.
+type LinkReport struct {
+	Name      string   `json:"name"`
+	Index     int      `json:"index"`
+	Type      string   `json:"type"`
+	MTU       int      `json:"mtu"`
+	Flags     string   `json:"flags"`
+	OperState string   `json:"operState"`
+	Alias     string   `json:"alias,omitempty"`
+	Addresses []string `json:"addresses,omitempty"`
+}
+
+// WireguardDeviceReport is a wireguard device with its private key redacted.
+type WireguardDeviceReport struct {
+	Name         string                `json:"name"`
+	PrivateKey   string                `json:"privateKey"`
+	PublicKey    string                `json:"publicKey"`
+	ListenPort   int                   `json:"listenPort"`
+	FirewallMark int                   `json:"firewallMark,omitempty"`
+	Peers        []WireguardPeerReport `json:"peers,omitempty"`
+}
+
+// WireguardPeerReport is a wireguard peer with its preshared key redacted.
+type WireguardPeerReport struct {
+	PublicKey         string     `json:"publicKey"`
+	PresharedKey      string     `json:"presharedKey,omitempty"`
+	Endpoint          string     `json:"endpoint,omitempty"`
+	AllowedIPs        []string   `json:"allowedIPs,omitempty"`
+	LastHandshakeTime *time.Time `json:"lastHandshakeTime,omitempty"`
+	ReceiveBytes      int64      `json:"receiveBytes"`
+	TransmitBytes     int64      `json:"transmitBytes"`
+}
+
+// LBProbeReport is the health of a gateway reported to the LB.
+type LBProbeReport struct {
+	GatewayUID string                    `json:"gatewayUID"`
+	Health     healthprobe.GatewayHealth `json:"health"`
+}
+
+// ReconcileError is the last error of a reconciler on an object, it is cleared when the object is reconciled successfully.
+type ReconcileError struct {
+	Controller string    `json:"controller"`
+	Object     string    `json:"object"`
+	Error      string    `json:"error"`
+	Time       time.Time `json:"time"`
+}
+
+// SetupWithManager adds the debug endpoint to the Manager.
+func (d *Diagnostics) SetupWithManager(mgr manager.Manager) error {
+	d.initialize()
+	if d.Port == 0 {
+		return nil
+	}
+	if d.TokenFile == "" {
+		return fmt.Errorf("debug endpoint requires a token file")
+	}
+	d.Netlink = netlinkwrapper.NewNetLink()
+	d.NetNS = netnswrapper.NewNetNS()
+	d.WgCtrl = wgctrlwrapper.NewWgCtrl()
+	nf, err := netfilter.New(d.FirewallBackend)
+	if err != nil {
+		return fmt.Errorf("failed to create netfilter interface: %w", err)
+	}
+	d.Netfilter = nf
+	return mgr.Add(d)
+}
+
+func (d *Diagnostics) initialize() {
+	d.reconcileErrors = make(map[string]ReconcileError)
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable, every gateway node serves its own endpoint.
+func (d *Diagnostics) NeedLeaderElection() bool {
+	return false
+}
+
+// Start implements manager.Runnable, it writes a new token and serves the debug endpoint.
+func (d *Diagnostics) Start(ctx context.Context) error {
+	log := log.FromContext(ctx)
+
+	if err := d.writeToken(); err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(DiagnosticsPath, d.serveHTTP)
+
+	httpServer := &http.Server{
+		Addr:              net.JoinHostPort("127.0.0.1", strconv.Itoa(d.Port)),
+		Handler:           mux,
+		MaxHeaderBytes:    1 << 20,
+		ReadHeaderTimeout: 32 * time.Second,
+	}
+
+	go func() {
+		log.Info("Starting debug server")
+		if err := httpServer.ListenAndServe(); err != nil {
+			if errors.Is(err, http.ErrServerClosed) {
+				return
+			}
+			log.Error(err, "failed to start debug server")
+		}
+	}()
+
+	<-ctx.Done()
+	log.Info("Stopping debug server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		log.Error(err, "failed to gracefully shutdown debug server")
+		return err
+	}
+	return nil
+}
+
+// writeToken generates the bearer token of the debug endpoint and writes it to TokenFile, readable by the owner only.
+func (d *Diagnostics) writeToken() error {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Errorf("failed to generate debug token: %w", err)
+	}
+	token := hex.EncodeToString(buf)
+	if err := os.MkdirAll(filepath.Dir(d.TokenFile), 0700); err != nil {
+		return fmt.Errorf("failed to create directory of debug token file %s: %w", d.TokenFile, err)
+	}
+	if err := os.WriteFile(d.TokenFile, []byte(token), 0600); err != nil {
+		return fmt.Errorf("failed to write debug token file %s: %w", d.TokenFile, err)
+	}
+	d.lock.Lock()
+	d.token = token
+	d.lock.Unlock()
+	return nil
+}
+
+// serveHTTP returns the diagnostics report on GET with a valid bearer token.
+func (d *Diagnostics) serveHTTP(resp http.ResponseWriter, req *http.Request) {
+	if !d.authorized(req) {
+		resp.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if req.Method != http.MethodGet {
+		resp.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	resp.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(resp)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(d.Report(req.Context()))
+}
+
+func (d *Diagnostics) authorized(req *http.Request) bool {
+	d.lock.Lock()
+	token := d.token
+	d.lock.Unlock()
+	provided, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	return ok && token != "" && subtle.ConstantTimeCompare([]byte(provided), []byte(token)) == 1
+}
+
+// Report collects the diagnostics report, parts that fail to be collected are listed in its errors.
+func (d *Diagnostics) Report(ctx context.Context) *DiagnosticsReport {
+	report := &DiagnosticsReport{
+		NodeName:        os.Getenv(consts.NodeNameEnvKey),
+		Time:            time.Now(),
+		FirewallBackend: string(d.FirewallBackend),
+	}
+	addError := func(err error) {
+		report.Errors = append(report.Errors, err.Error())
+	}
+
+	// the daemon runs in the host network namespace
+	hostReport, err := d.namespaceReport(ctx, "host", addError)
+	if err != nil {
+		addError(err)
+	}
+	report.Namespaces = append(report.Namespaces, *hostReport)
+
+	gwns, err := d.NetNS.GetNS(consts.GatewayNetnsName)
+	if err != nil {
+		addError(fmt.Errorf("failed to get gateway network namespace %s: %w", consts.GatewayNetnsName, err))
+	} else {
+		if err := gwns.Do(func(nn ns.NetNS) error {
+			gwReport, err := d.namespaceReport(ctx, consts.GatewayNetnsName, addError)
+			report.Namespaces = append(report.Namespaces, *gwReport)
+			return err
+		}); err != nil {
+			addError(err)
+		}
+		_ = gwns.Close()
+	}
+
+	if d.LBProbeServer != nil {
+		for _, uid := range d.LBProbeServer.GetGateways() {
+			report.LBProbe = append(report.LBProbe, LBProbeReport{GatewayUID: uid, Health: d.LBProbeServer.GetGatewayHealth(uid)})
+		}
+		sort.Slice(report.LBProbe, func(i, j int) bool { return report.LBProbe[i].GatewayUID < report.LBProbe[j].GatewayUID })
+	}
+	report.ReconcileErrors = d.ReconcileErrors()
+	return report
+}
+
+// namespaceReport collects the network configuration of the network namespace of the calling thread. Failures
+// of the optional parts are passed to addError, the returned error means the links could not be listed.
+func (d *Diagnostics) namespaceReport(ctx context.Context, name string, addError func(error)) (*NamespaceReport, error) {
+	report := &NamespaceReport{Name: name}
+
+	if firewall, err := d.Netfilter.Dump(ctx); err != nil {
+		addError(fmt.Errorf("failed to dump firewall rules in namespace %s: %w", name, err))
+	} else {
+		report.Firewall = firewall
+	}
+
+	// routes of all tables, gateway routes are in per-gateway tables
+	routes, err := d.Netlink.RouteListFiltered(netlink.FAMILY_ALL, &netlink.Route{Table: unix.RT_TABLE_UNSPEC}, netlink.RT_FILTER_TABLE)
+	if err != nil {
+		addError(fmt.Errorf("failed to list routes in namespace %s: %w", name, err))
+	}
+	for _, route := range routes {
+		report.Routes = append(report.Routes, route.String())
+	}
+
+	rules, err := d.Netlink.RuleList(netlink.FAMILY_ALL)
+	if err != nil {
+		addError(fmt.Errorf("failed to list rules in namespace %s: %w", name, err))
+	}
+	for _, rule := range rules {
+		report.Rules = append(report.Rules, rule.String())
+	}
+
+	report.Wireguard, err = d.wireguardReport()
+	if err != nil {
+		addError(fmt.Errorf("failed to list wireguard devices in namespace %s: %w", name, err))
+	}
+
+	links, err := d.Netlink.LinkList()
+	if err != nil {
+		return report, fmt.Errorf("failed to list links in namespace %s: %w", name, err)
+	}
+	for _, link := range links {
+		attrs := link.Attrs()
+		linkReport := LinkReport{
+			Name:      attrs.Name,
+			Index:     attrs.Index,
+			Type:      link.Type(),
+			MTU:       attrs.MTU,
+			Flags:     attrs.Flags.String(),
+			OperState: attrs.OperState.String(),
+			Alias:     attrs.Alias,
+		}
+		addresses, err := d.Netlink.AddrList(link, netlink.FAMILY_ALL)
+		if err != nil {
+			addError(fmt.Errorf("failed to list addresses of link %s in namespace %s: %w", attrs.Name, name, err))
+		}
+		for _, address := range addresses {
+			linkReport.Addresses = append(linkReport.Addresses, address.IPNet.String())
+		}
+		report.Links = append(report.Links, linkReport)
+	}
+	return report, nil
+}
+
+// wireguardReport lists the wireguard devices in the network namespace of the calling thread, private and
+// preshared keys are redacted.
+func (d *Diagnostics) wireguardReport() ([]WireguardDeviceReport, error) {
+	wgClient, err := d.WgCtrl.New()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create wireguard client: %w", err)
+	}
+	defer func() { _ = wgClient.Close() }()
+
+	devices, err := wgClient.Devices()
+	if err != nil {
+		return nil, err
+	}
+	var reports []WireguardDeviceReport
+	for _, device := range devices {
+		deviceReport := WireguardDeviceReport{
+			Name:         device.Name,
+			PrivateKey:   redactKey(device.PrivateKey),
+			PublicKey:    device.PublicKey.String(),
+			ListenPort:   device.ListenPort,
+			FirewallMark: device.FirewallMark,
+		}
+		for _, peer := range device.Peers {
+			peerReport := WireguardPeerReport{
+				PublicKey:     peer.PublicKey.String(),
+				PresharedKey:  redactKey(peer.PresharedKey),
+				ReceiveBytes:  peer.ReceiveBytes,
+				TransmitBytes: peer.TransmitBytes,
+			}
+			if peer.Endpoint != nil {
+				peerReport.Endpoint = peer.Endpoint.String()
+			}
+			for _, allowedIP := range peer.AllowedIPs {
+				peerReport.AllowedIPs = append(peerReport.AllowedIPs, allowedIP.String())
+			}
+			if !peer.LastHandshakeTime.IsZero() {
+				lastHandshakeTime := peer.LastHandshakeTime
+				peerReport.LastHandshakeTime = &lastHandshakeTime
+			}
+			deviceReport.Peers = append(deviceReport.Peers, peerReport)
+		}
+		reports = append(reports, deviceReport)
+	}
+	return reports, nil
+}
+
+// redactKey hides a secret key, an empty string means the key is not set.
+func redactKey(key wgtypes.Key) string {
+	if key == (wgtypes.Key{}) {
+		return ""
+	}
+	return redacted
+}
+
+// ReconcileErrors returns the last reconcile error of every object that failed its last reconcile.
+func (d *Diagnostics) ReconcileErrors() []ReconcileError {
+	if d == nil {
+		return nil
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	var reconcileErrors []ReconcileError
+	for _, reconcileError := range d.reconcileErrors {
+		reconcileErrors = append(reconcileErrors, reconcileError)
+	}
+	sort.Slice(reconcileErrors, func(i, j int) bool {
+		if reconcileErrors[i].Controller != reconcileErrors[j].Controller {
+			return reconcileErrors[i].Controller < reconcileErrors[j].Controller
+		}
+		return reconcileErrors[i].Object < reconcileErrors[j].Object
+	})
+	return reconcileErrors
+}
+
+// recordReconcile records the result of a reconcile of the object by controller, a nil error clears the previous error.
+// Requests without name come from the cleanup ticker.
+func (d *Diagnostics) recordReconcile(controller string, req types.NamespacedName, err error) {
+	if d == nil {
+		return
+	}
+	object := req.String()
+	if req.Name == "" {
+		object = "cleanup"
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	key := controller + "/" + object
+	if err == nil {
+		delete(d.reconcileErrors, key)
+		return
+	}
+	d.reconcileErrors[key] = ReconcileError{Controller: controller, Object: object, Error: err.Error(), Time: time.Now()}
+}
@@ -0,0 +1,198 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netlink/nl"
+	"go.uber.org/mock/gomock"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+	"k8s.io/apimachinery/pkg/types"
+	utiliptables "k8s.io/kubernetes/pkg/util/iptables"
+
+	"github.com/Azure/kube-egress-gateway/pkg/consts"
+	"github.com/Azure/kube-egress-gateway/pkg/healthprobe"
+	fakeiptables "github.com/Azure/kube-egress-gateway/pkg/iptableswrapper"
+	"github.com/Azure/kube-egress-gateway/pkg/netfilter"
+	"github.com/Azure/kube-egress-gateway/pkg/netlinkwrapper/mocknetlinkwrapper"
+	"github.com/Azure/kube-egress-gateway/pkg/netnswrapper/mocknetnswrapper"
+	"github.com/Azure/kube-egress-gateway/pkg/wgctrlwrapper/mockwgctrlwrapper"
+)
+
+var _ = Describe("Daemon diagnostics unit tests", func() {
+	var (
+		d       *Diagnostics
+		mnl     *mocknetlinkwrapper.MockInterface
+		mns     *mocknetnswrapper.MockInterface
+		mwg     *mockwgctrlwrapper.MockInterface
+		mclient *mockwgctrlwrapper.MockClient
+		gwns    = &mocknetnswrapper.MockNetNS{Name: consts.GatewayNetnsName}
+		eth0    = &netlink.Device{LinkAttrs: netlink.LinkAttrs{Name: "eth0", Index: 2, MTU: 1500, Flags: net.FlagUp}}
+		wg0     = &netlink.Wireguard{LinkAttrs: netlink.LinkAttrs{Name: "wg-6000", Index: 3, MTU: 1420, Flags: net.FlagUp}}
+	)
+
+	getTestDiagnostics := func() {
+		mctrl := gomock.NewController(GinkgoT())
+		mnl = mocknetlinkwrapper.NewMockInterface(mctrl)
+		mns = mocknetnswrapper.NewMockInterface(mctrl)
+		mwg = mockwgctrlwrapper.NewMockInterface(mctrl)
+		mclient = mockwgctrlwrapper.NewMockClient(mctrl)
+		fipt := fakeiptables.NewFake()
+		for _, table := range []utiliptables.Table{utiliptables.TableNAT, utiliptables.TableMangle} {
+			_, err := fipt.EnsureChain(table, utiliptables.ChainPrerouting)
+			Expect(err).NotTo(HaveOccurred())
+		}
+		lbProbeServer := healthprobe.NewLBProbeServer(1000, 0)
+		Expect(lbProbeServer.AddGateway(testUID)).To(Succeed())
+		d = &Diagnostics{
+			TokenFile:       filepath.Join(GinkgoT().TempDir(), "debug", "token"),
+			LBProbeServer:   lbProbeServer,
+			FirewallBackend: netfilter.BackendIPTables,
+			Netlink:         mnl,
+			NetNS:           mns,
+			Netfilter:       netfilter.NewIPTables(fipt),
+			WgCtrl:          mwg,
+		}
+		d.initialize()
+	}
+
+	expectNamespace := func(link netlink.Link, addr string, devices []*wgtypes.Device) {
+		mnl.EXPECT().RouteListFiltered(nl.FAMILY_ALL, gomock.Any(), netlink.RT_FILTER_TABLE).Return([]netlink.Route{{LinkIndex: link.Attrs().Index, Dst: getIPNet("10.0.0.0/24")}}, nil)
+		mnl.EXPECT().RuleList(nl.FAMILY_ALL).Return([]netlink.Rule{*netlink.NewRule()}, nil)
+		mwg.EXPECT().New().Return(mclient, nil)
+		mclient.EXPECT().Devices().Return(devices, nil)
+		mclient.EXPECT().Close().Return(nil)
+		mnl.EXPECT().LinkList().Return([]netlink.Link{link}, nil)
+		mnl.EXPECT().AddrList(link, nl.FAMILY_ALL).Return([]netlink.Addr{{IPNet: getIPNetWithActualIP(addr)}}, nil)
+	}
+
+	It("should report network configuration of both namespaces with keys redacted", func() {
+		getTestDiagnostics()
+		privateKey, err := wgtypes.GeneratePrivateKey()
+		Expect(err).NotTo(HaveOccurred())
+		handshake := time.Now()
+		devices := []*wgtypes.Device{{
+			Name:       "wg-6000",
+			PrivateKey: privateKey,
+			PublicKey:  privateKey.PublicKey(),
+			ListenPort: 6000,
+			Peers: []wgtypes.Peer{{
+				PublicKey:         privateKey.PublicKey(),
+				PresharedKey:      privateKey,
+				Endpoint:          &net.UDPAddr{IP: net.ParseIP("10.0.0.7"), Port: 51820},
+				AllowedIPs:        []net.IPNet{*getIPNet("10.244.0.5/32")},
+				LastHandshakeTime: handshake,
+			}},
+		}}
+		expectNamespace(eth0, "10.0.0.5/24", nil)
+		mns.EXPECT().GetNS(consts.GatewayNetnsName).Return(gwns, nil)
+		expectNamespace(wg0, "10.0.0.6/32", devices)
+
+		report := d.Report(context.TODO())
+		Expect(report.Errors).To(BeEmpty())
+		Expect(report.FirewallBackend).To(Equal("iptables"))
+		Expect(report.Namespaces).To(HaveLen(2))
+		Expect(report.Namespaces[0].Name).To(Equal("host"))
+		Expect(report.Namespaces[0].Links).To(Equal([]LinkReport{{Name: "eth0", Index: 2, Type: "device", MTU: 1500, Flags: "up", OperState: "unknown", Addresses: []string{"10.0.0.5/24"}}}))
+		Expect(report.Namespaces[0].Routes).To(HaveLen(1))
+		Expect(report.Namespaces[0].Rules).To(HaveLen(1))
+		Expect(report.Namespaces[0].Firewall).To(ContainSubstring("*nat"))
+		Expect(report.Namespaces[0].Wireguard).To(BeEmpty())
+		Expect(report.Namespaces[1].Name).To(Equal(consts.GatewayNetnsName))
+		Expect(report.Namespaces[1].Wireguard).To(Equal([]WireguardDeviceReport{{
+			Name:       "wg-6000",
+			PrivateKey: redacted,
+			PublicKey:  privateKey.PublicKey().String(),
+			ListenPort: 6000,
+			Peers: []WireguardPeerReport{{
+				PublicKey:         privateKey.PublicKey().String(),
+				PresharedKey:      redacted,
+				Endpoint:          "10.0.0.7:51820",
+				AllowedIPs:        []string{"10.244.0.5/32"},
+				LastHandshakeTime: &handshake,
+			}},
+		}}))
+		Expect(report.LBProbe).To(HaveLen(1))
+		Expect(report.LBProbe[0].GatewayUID).To(Equal(testUID))
+		Expect(report.LBProbe[0].Health.Active).To(BeTrue())
+
+		data, err := json.Marshal(report)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).NotTo(ContainSubstring(privateKey.String()))
+	})
+
+	It("should report missing gateway namespace as error", func() {
+		getTestDiagnostics()
+		expectNamespace(eth0, "10.0.0.5/24", nil)
+		mns.EXPECT().GetNS(consts.GatewayNetnsName).Return(nil, errors.New("not found"))
+
+		report := d.Report(context.TODO())
+		Expect(report.Namespaces).To(HaveLen(1))
+		Expect(report.Errors).To(HaveLen(1))
+		Expect(report.Errors[0]).To(ContainSubstring(consts.GatewayNetnsName))
+	})
+
+	It("should keep the last reconcile error of every object", func() {
+		getTestDiagnostics()
+		gateway := types.NamespacedName{Namespace: testNamespace, Name: testName}
+		d.recordReconcile("StaticGatewayConfiguration", gateway, errors.New("failed"))
+		d.recordReconcile("PodEndpoint", types.NamespacedName{}, errors.New("cleanup failed"))
+		reconcileErrors := d.ReconcileErrors()
+		Expect(reconcileErrors).To(HaveLen(2))
+		Expect(reconcileErrors[0].Controller).To(Equal("PodEndpoint"))
+		Expect(reconcileErrors[0].Object).To(Equal("cleanup"))
+		Expect(reconcileErrors[1].Object).To(Equal(gateway.String()))
+		Expect(reconcileErrors[1].Error).To(Equal("failed"))
+
+		d.recordReconcile("StaticGatewayConfiguration", gateway, nil)
+		Expect(d.ReconcileErrors()).To(HaveLen(1))
+
+		var nilDiagnostics *Diagnostics
+		nilDiagnostics.recordReconcile("StaticGatewayConfiguration", gateway, errors.New("failed"))
+		Expect(nilDiagnostics.ReconcileErrors()).To(BeEmpty())
+	})
+
+	It("should require the token written to the token file", func() {
+		getTestDiagnostics()
+		Expect(d.writeToken()).To(Succeed())
+		info, err := os.Stat(d.TokenFile)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(info.Mode().Perm()).To(Equal(os.FileMode(0600)))
+		token, err := os.ReadFile(d.TokenFile)
+		Expect(err).NotTo(HaveOccurred())
+
+		serve := func(method, authorization string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(method, DiagnosticsPath, nil)
+			if authorization != "" {
+				req.Header.Set("Authorization", authorization)
+			}
+			resp := httptest.NewRecorder()
+			d.serveHTTP(resp, req)
+			return resp
+		}
+		Expect(serve(http.MethodGet, "").Code).To(Equal(http.StatusUnauthorized))
+		Expect(serve(http.MethodGet, "Bearer wrong").Code).To(Equal(http.StatusUnauthorized))
+		Expect(serve(http.MethodPost, "Bearer "+string(token)).Code).To(Equal(http.StatusMethodNotAllowed))
+
+		expectNamespace(eth0, "10.0.0.5/24", nil)
+		mns.EXPECT().GetNS(consts.GatewayNetnsName).Return(gwns, nil)
+		expectNamespace(wg0, "10.0.0.6/32", nil)
+		resp := serve(http.MethodGet, "Bearer "+string(token))
+		Expect(resp.Code).To(Equal(http.StatusOK))
+		report := &DiagnosticsReport{}
+		Expect(json.Unmarshal(resp.Body.Bytes(), report)).To(Succeed())
+		Expect(report.Namespaces).To(HaveLen(2))
+	})
+})
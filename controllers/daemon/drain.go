@@ -0,0 +1,457 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/containernetworking/plugins/pkg/ns"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	egressgatewayv1alpha1 "github.com/Azure/kube-egress-gateway/api/v1alpha1"
+	"github.com/Azure/kube-egress-gateway/pkg/consts"
+)
+
+const (
+	// DefaultDrainTimeout is the default time to wait for connections through a draining gateway to close
+	DefaultDrainTimeout = 5 * time.Minute
+
+	// drainCheckInterval is how often the connections through a draining gateway are counted
+	drainCheckInterval = 10 * time.Second
+
+	// drainAdminPath is the path of the node-wide drain on the admin endpoint,
+	// a single gateway is drained at drainAdminPath/<namespace>/<name>
+	drainAdminPath = "/drain"
+)
+
+var _ manager.Runnable = &Drainer{}
+
+// Drainer tracks the gateways to drain on this node, for planned maintenance. A gateway is drained
+// when the node has consts.GatewayDrainAnnotationKey annotation matching it, or when it is requested
+// through the local admin endpoint. Drains requested through the admin endpoint are not persisted and
+// are lost when the daemon restarts.
+//
+// The StaticGatewayConfiguration reconciler does the actual drain: it reports the gateway unhealthy to
+// the LB, so that new connections go to other nodes, and waits for the existing connections to close.
+type Drainer struct {
+	client.Client
+	GatewayEvents chan<- event.GenericEvent
+	// AdminPort is the port of the admin endpoint, it only listens on localhost
+	AdminPort int
+	// ConntrackThreshold is the number of connections through the gateway at or below which the drain completes
+	ConntrackThreshold int
+	// Timeout is the time after which the drain completes even if connections are still active
+	Timeout time.Duration
+
+	lock sync.Mutex
+	// drains requested through the admin endpoint
+	adminNode     bool
+	adminGateways map[types.NamespacedName]bool
+	// status of the gateways being drained
+	drains map[types.NamespacedName]*egressgatewayv1alpha1.GatewayDrainStatus
+}
+
+// drainAdminStatus is the response of the admin endpoint
+type drainAdminStatus struct {
+	// Node is true when all gateways on the node are requested to drain through the admin endpoint
+	Node bool `json:"node"`
+	// Gateways are the gateways requested to drain through the admin endpoint
+	Gateways []string `json:"gateways,omitempty"`
+	// Drains are the gateways being drained, requested by either the admin endpoint or the node annotation
+	Drains map[string]egressgatewayv1alpha1.GatewayDrainStatus `json:"drains,omitempty"`
+}
+
+// SetupWithManager adds the admin endpoint of the drainer to the Manager.
+func (d *Drainer) SetupWithManager(mgr manager.Manager) error {
+	d.initialize()
+	if d.AdminPort == 0 {
+		return nil
+	}
+	return mgr.Add(d)
+}
+
+func (d *Drainer) initialize() {
+	if d.Timeout <= 0 {
+		d.Timeout = DefaultDrainTimeout
+	}
+	d.adminGateways = make(map[types.NamespacedName]bool)
+	d.drains = make(map[types.NamespacedName]*egressgatewayv1alpha1.GatewayDrainStatus)
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable, every gateway node serves its own endpoint.
+func (d *Drainer) NeedLeaderElection() bool {
+	return false
+}
+
+// Start implements manager.Runnable and serves the admin endpoint.
+func (d *Drainer) Start(ctx context.Context) error {
+	log := log.FromContext(ctx)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(drainAdminPath, d.serveHTTP)
+	mux.HandleFunc(drainAdminPath+"/", d.serveHTTP)
+
+	httpServer := &http.Server{
+		Addr:              net.JoinHostPort("127.0.0.1", strconv.Itoa(d.AdminPort)),
+		Handler:           mux,
+		MaxHeaderBytes:    1 << 20,
+		ReadHeaderTimeout: 32 * time.Second,
+	}
+
+	go func() {
+		log.Info("Starting gateway drain admin server")
+		if err := httpServer.ListenAndServe(); err != nil {
+			if errors.Is(err, http.ErrServerClosed) {
+				return
+			}
+			log.Error(err, "failed to start gateway drain admin server")
+		}
+	}()
+
+	<-ctx.Done()
+	log.Info("Stopping gateway drain admin server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		log.Error(err, "failed to gracefully shutdown gateway drain admin server")
+		return err
+	}
+	return nil
+}
+
+// serveHTTP reports the drains on GET, requests a drain on POST and cancels it on DELETE.
+func (d *Drainer) serveHTTP(resp http.ResponseWriter, req *http.Request) {
+	var gateway *types.NamespacedName
+	switch subPaths := strings.Split(strings.TrimPrefix(req.URL.Path, drainAdminPath), "/"); {
+	case len(subPaths) == 1 && subPaths[0] == "":
+		// node-wide drain
+	case len(subPaths) == 3 && subPaths[0] == "" && subPaths[1] != "" && subPaths[2] != "":
+		gateway = &types.NamespacedName{Namespace: subPaths[1], Name: subPaths[2]}
+	default:
+		resp.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	switch req.Method {
+	case http.MethodGet:
+		resp.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(resp).Encode(d.adminStatus())
+		return
+	case http.MethodPost, http.MethodDelete:
+		drain := req.Method == http.MethodPost
+		d.lock.Lock()
+		if gateway == nil {
+			d.adminNode = drain
+		} else if drain {
+			d.adminGateways[*gateway] = true
+		} else {
+			delete(d.adminGateways, *gateway)
+		}
+		d.lock.Unlock()
+		log.FromContext(req.Context()).Info("Gateway drain requested through admin endpoint", "drain", drain, "gateway", gateway)
+		if err := d.notify(req.Context(), gateway); err != nil {
+			resp.WriteHeader(http.StatusInternalServerError)
+			_, _ = resp.Write([]byte(err.Error()))
+			return
+		}
+		resp.WriteHeader(http.StatusAccepted)
+	default:
+		resp.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (d *Drainer) adminStatus() drainAdminStatus {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	status := drainAdminStatus{Node: d.adminNode, Drains: make(map[string]egressgatewayv1alpha1.GatewayDrainStatus)}
+	for gateway := range d.adminGateways {
+		status.Gateways = append(status.Gateways, gateway.String())
+	}
+	sort.Strings(status.Gateways)
+	for gateway, drain := range d.drains {
+		status.Drains[gateway.String()] = *drain
+	}
+	return status
+}
+
+// notify enqueues the gateway, or all gateways on the node if gateway is nil, to its reconciler.
+func (d *Drainer) notify(ctx context.Context, gateway *types.NamespacedName) error {
+	var gateways []types.NamespacedName
+	if gateway != nil {
+		gateways = append(gateways, *gateway)
+	} else {
+		gwConfigList := &egressgatewayv1alpha1.StaticGatewayConfigurationList{}
+		if err := d.List(ctx, gwConfigList); err != nil {
+			return fmt.Errorf("failed to list staticGatewayConfigurations: %w", err)
+		}
+		for _, gwConfig := range gwConfigList.Items {
+			if applyToNode(&gwConfig) {
+				gateways = append(gateways, client.ObjectKeyFromObject(&gwConfig))
+			}
+		}
+	}
+	for _, gateway := range gateways {
+		select {
+		case d.GatewayEvents <- event.GenericEvent{
+			Object: &metav1.PartialObjectMetadata{
+				ObjectMeta: metav1.ObjectMeta{Name: gateway.Name, Namespace: gateway.Namespace},
+			},
+		}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// requested returns whether the gateway should be drained on this node.
+func (d *Drainer) requested(ctx context.Context, gateway types.NamespacedName) (bool, error) {
+	if d == nil {
+		return false, nil
+	}
+	d.lock.Lock()
+	adminRequested := d.adminNode || d.adminGateways[gateway]
+	d.lock.Unlock()
+	if adminRequested {
+		return true, nil
+	}
+
+	node := &corev1.Node{}
+	if err := d.Get(ctx, types.NamespacedName{Name: os.Getenv(consts.NodeNameEnvKey)}, node); err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to get current node: %w", err)
+	}
+	value, ok := node.GetAnnotations()[consts.GatewayDrainAnnotationKey]
+	if !ok {
+		return false, nil
+	}
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == consts.GatewayDrainAllValue || item == gateway.String() {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (d *Drainer) drainStatus(gateway types.NamespacedName) *egressgatewayv1alpha1.GatewayDrainStatus {
+	if d == nil {
+		return nil
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if drain, ok := d.drains[gateway]; ok {
+		return drain.DeepCopy()
+	}
+	return nil
+}
+
+func (d *Drainer) setDrainStatus(gateway types.NamespacedName, drain *egressgatewayv1alpha1.GatewayDrainStatus) {
+	if d == nil {
+		return
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.drains[gateway] = drain.DeepCopy()
+}
+
+// clearDrainStatus forgets the drain of the gateway, and returns whether it was draining.
+func (d *Drainer) clearDrainStatus(gateway types.NamespacedName) bool {
+	if d == nil {
+		return false
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	_, ok := d.drains[gateway]
+	delete(d.drains, gateway)
+	return ok
+}
+
+// mapNodeToGateways enqueues the gateways on this node when its drain annotation changes.
+func (r *StaticGatewayConfigurationReconciler) mapNodeToGateways(ctx context.Context, node client.Object) []reconcile.Request {
+	log := log.FromContext(ctx)
+	if node.GetName() != os.Getenv(consts.NodeNameEnvKey) {
+		return nil
+	}
+	gwConfigList := &egressgatewayv1alpha1.StaticGatewayConfigurationList{}
+	if err := r.List(ctx, gwConfigList); err != nil {
+		log.Error(err, "failed to list staticGatewayConfigurations")
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, gwConfig := range gwConfigList.Items {
+		if applyToNode(&gwConfig) {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&gwConfig)})
+		}
+	}
+	return requests
+}
+
+// reconcileDrain reports the gateway unhealthy to the LB when it is requested to drain, and counts the
+// connections still going through it until the drain completes. It returns when to check the gateway again.
+func (r *StaticGatewayConfigurationReconciler) reconcileDrain(
+	ctx context.Context,
+	gwConfig *egressgatewayv1alpha1.StaticGatewayConfiguration,
+) (time.Duration, error) {
+	log := log.FromContext(ctx)
+	key := client.ObjectKeyFromObject(gwConfig)
+	gatewayUID := string(gwConfig.GetUID())
+	ifName := getWireguardInterfaceName(gwConfig)
+
+	requested, err := r.Drainer.requested(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	if !requested {
+		if r.Drainer.clearDrainStatus(key) {
+			log.Info("Gateway drain cancelled")
+		}
+		r.LBProbeServer.SetDraining(gatewayUID, false)
+		return 0, r.updateGatewayDrainStatus(ctx, ifName, nil)
+	}
+
+	r.LBProbeServer.SetDraining(gatewayUID, true)
+	drain := r.Drainer.drainStatus(key)
+	if drain == nil {
+		// resume the drain recorded before the daemon restarted, if any
+		if drain, err = r.getGatewayDrainStatus(ctx, ifName); err != nil {
+			return 0, err
+		}
+		if drain == nil {
+			log.Info("Draining gateway")
+			drain = &egressgatewayv1alpha1.GatewayDrainStatus{
+				Phase:     egressgatewayv1alpha1.GatewayDrainPhaseDraining,
+				StartTime: metav1.Now().Rfc3339Copy(),
+			}
+		}
+	}
+	if drain.Phase == egressgatewayv1alpha1.GatewayDrainPhaseDraining {
+		connections, err := r.countGatewayConnections(ctx, ifName)
+		if err != nil {
+			return 0, err
+		}
+		drain.ActiveConnections = int32(connections)
+		if connections <= r.Drainer.ConntrackThreshold || time.Since(drain.StartTime.Time) >= r.Drainer.Timeout {
+			now := metav1.Now().Rfc3339Copy()
+			drain.Phase = egressgatewayv1alpha1.GatewayDrainPhaseDrained
+			drain.CompletionTime = &now
+			drain.TimedOut = connections > r.Drainer.ConntrackThreshold
+			log.Info("Gateway drained", "activeConnections", connections, "timedOut", drain.TimedOut)
+		}
+	}
+	r.Drainer.setDrainStatus(key, drain)
+	if err := r.updateGatewayDrainStatus(ctx, ifName, drain); err != nil {
+		return 0, err
+	}
+	if drain.Phase == egressgatewayv1alpha1.GatewayDrainPhaseDraining {
+		return drainCheckInterval, nil
+	}
+	return 0, nil
+}
+
+// countGatewayConnections counts the connections marked by the gateway sNAT rules in the gateway namespace.
+func (r *StaticGatewayConfigurationReconciler) countGatewayConnections(ctx context.Context, ifName string) (int, error) {
+	mark, err := getPacketMark(ifName)
+	if err != nil {
+		return 0, err
+	}
+
+	gwns, err := r.NetNS.GetNS(consts.GatewayNetnsName)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get network namespace %s: %w", consts.GatewayNetnsName, err)
+	}
+	defer func() { _ = gwns.Close() }()
+
+	connections := 0
+	if err := gwns.Do(func(nn ns.NetNS) error {
+		for _, family := range []netlink.InetFamily{unix.AF_INET, unix.AF_INET6} {
+			flows, err := r.Netlink.ConntrackTableList(netlink.ConntrackTable, family)
+			if err != nil {
+				return fmt.Errorf("failed to list conntrack flows: %w", err)
+			}
+			for _, flow := range flows {
+				if flow.Mark == uint32(mark) {
+					connections++
+				}
+			}
+		}
+		return nil
+	}); err != nil {
+		return 0, err
+	}
+	return connections, nil
+}
+
+func (r *StaticGatewayConfigurationReconciler) getGatewayDrainStatus(
+	ctx context.Context,
+	ifName string,
+) (*egressgatewayv1alpha1.GatewayDrainStatus, error) {
+	gwStatus := &egressgatewayv1alpha1.GatewayStatus{}
+	if err := r.Get(ctx, gatewayStatusKey(), gwStatus); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get gateway status: %w", err)
+	}
+	for _, gwConf := range gwStatus.Spec.ReadyGatewayConfigurations {
+		if gwConf.InterfaceName == ifName {
+			return gwConf.Drain, nil
+		}
+	}
+	return nil, nil
+}
+
+// updateGatewayDrainStatus reports the drain status of the gateway in the gateway status object of this node.
+func (r *StaticGatewayConfigurationReconciler) updateGatewayDrainStatus(
+	ctx context.Context,
+	ifName string,
+	drain *egressgatewayv1alpha1.GatewayDrainStatus,
+) error {
+	gwStatus := &egressgatewayv1alpha1.GatewayStatus{}
+	if err := r.Get(ctx, gatewayStatusKey(), gwStatus); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to get gateway status: %w", err)
+	}
+	for i, gwConf := range gwStatus.Spec.ReadyGatewayConfigurations {
+		if gwConf.InterfaceName != ifName {
+			continue
+		}
+		if equality.Semantic.DeepEqual(gwConf.Drain, drain) {
+			return nil
+		}
+		gwStatus.Spec.ReadyGatewayConfigurations[i].Drain = drain.DeepCopy()
+		if err := r.Update(ctx, gwStatus); err != nil {
+			return fmt.Errorf("failed to update gwStatus object: %w", err)
+		}
+		return nil
+	}
+	return nil
+}
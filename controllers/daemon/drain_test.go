@@ -0,0 +1,284 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/vishvananda/netlink"
+	"go.uber.org/mock/gomock"
+	"golang.org/x/sys/unix"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	egressgatewayv1alpha1 "github.com/Azure/kube-egress-gateway/api/v1alpha1"
+	"github.com/Azure/kube-egress-gateway/pkg/consts"
+	"github.com/Azure/kube-egress-gateway/pkg/healthprobe"
+	"github.com/Azure/kube-egress-gateway/pkg/netlinkwrapper/mocknetlinkwrapper"
+	"github.com/Azure/kube-egress-gateway/pkg/netnswrapper/mocknetnswrapper"
+)
+
+var _ = Describe("Daemon gateway drain unit tests", func() {
+	var (
+		r        *StaticGatewayConfigurationReconciler
+		d        *Drainer
+		mnl      *mocknetlinkwrapper.MockInterface
+		mns      *mocknetnswrapper.MockInterface
+		gwEvents chan event.GenericEvent
+		gwConfig *egressgatewayv1alpha1.StaticGatewayConfiguration
+		node     *corev1.Node
+		gwStatus *egressgatewayv1alpha1.GatewayStatus
+		gwKey    = types.NamespacedName{Namespace: testNamespace, Name: testName}
+		gwns     = &mocknetnswrapper.MockNetNS{Name: consts.GatewayNetnsName}
+		// flows marked by wg-6000 gateway
+		flow  = &netlink.ConntrackFlow{Mark: 6000}
+		other = &netlink.ConntrackFlow{Mark: 6001}
+	)
+
+	getTestDrainer := func() {
+		mctrl := gomock.NewController(GinkgoT())
+		cl := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(gwConfig, node, gwStatus).Build()
+		gwEvents = make(chan event.GenericEvent, 10)
+		d = &Drainer{Client: cl, GatewayEvents: gwEvents, ConntrackThreshold: 1}
+		d.initialize()
+		mnl = mocknetlinkwrapper.NewMockInterface(mctrl)
+		mns = mocknetnswrapper.NewMockInterface(mctrl)
+		r = &StaticGatewayConfigurationReconciler{
+			Client:        cl,
+			LBProbeServer: healthprobe.NewLBProbeServer(1000, 0),
+			Drainer:       d,
+			Netlink:       mnl,
+			NetNS:         mns,
+		}
+		Expect(r.LBProbeServer.AddGateway(testUID)).To(Succeed())
+	}
+
+	expectConnections := func(flows ...*netlink.ConntrackFlow) {
+		gomock.InOrder(
+			mns.EXPECT().GetNS(consts.GatewayNetnsName).Return(gwns, nil),
+			mnl.EXPECT().ConntrackTableList(netlink.ConntrackTableType(netlink.ConntrackTable), netlink.InetFamily(unix.AF_INET)).Return(flows, nil),
+			mnl.EXPECT().ConntrackTableList(netlink.ConntrackTableType(netlink.ConntrackTable), netlink.InetFamily(unix.AF_INET6)).Return(nil, nil),
+		)
+	}
+
+	reportedDrain := func() *egressgatewayv1alpha1.GatewayDrainStatus {
+		status := &egressgatewayv1alpha1.GatewayStatus{}
+		Expect(r.Get(context.TODO(), types.NamespacedName{Namespace: testPodNamespace, Name: testNodeName}, status)).To(Succeed())
+		Expect(status.Spec.ReadyGatewayConfigurations).To(HaveLen(1))
+		return status.Spec.ReadyGatewayConfigurations[0].Drain
+	}
+
+	BeforeEach(func() {
+		origNodeTags := nodeTags
+		DeferCleanup(func() { nodeTags = origNodeTags })
+		nodeTags = map[string]string{consts.AKSNodepoolTagKey: testNodepoolName}
+		_ = os.Setenv(consts.PodNamespaceEnvKey, testPodNamespace)
+		_ = os.Setenv(consts.NodeNameEnvKey, testNodeName)
+		DeferCleanup(func() {
+			_ = os.Setenv(consts.PodNamespaceEnvKey, "")
+			_ = os.Setenv(consts.NodeNameEnvKey, "")
+		})
+		gwConfig = &egressgatewayv1alpha1.StaticGatewayConfiguration{
+			ObjectMeta: metav1.ObjectMeta{Name: testName, Namespace: testNamespace, UID: testUID},
+			Spec:       egressgatewayv1alpha1.StaticGatewayConfigurationSpec{GatewayNodepoolName: testNodepoolName},
+			Status:     getTestGwConfigStatus(),
+		}
+		node = &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: testNodeName}}
+		gwStatus = &egressgatewayv1alpha1.GatewayStatus{
+			ObjectMeta: metav1.ObjectMeta{Name: testNodeName, Namespace: testPodNamespace},
+			Spec: egressgatewayv1alpha1.GatewayStatusSpec{
+				ReadyGatewayConfigurations: []egressgatewayv1alpha1.GatewayConfiguration{
+					{StaticGatewayConfiguration: gwKey.String(), InterfaceName: "wg-6000"},
+				},
+			},
+		}
+	})
+
+	Context("Test drain request", func() {
+		It("should not drain without annotation", func() {
+			getTestDrainer()
+			requested, err := d.requested(context.TODO(), gwKey)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(requested).To(BeFalse())
+		})
+
+		It("should drain all gateways on the node", func() {
+			node.Annotations = map[string]string{consts.GatewayDrainAnnotationKey: "*"}
+			getTestDrainer()
+			requested, err := d.requested(context.TODO(), gwKey)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(requested).To(BeTrue())
+		})
+
+		It("should drain listed gateways only", func() {
+			node.Annotations = map[string]string{consts.GatewayDrainAnnotationKey: "ns/other, " + gwKey.String()}
+			getTestDrainer()
+			requested, err := d.requested(context.TODO(), gwKey)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(requested).To(BeTrue())
+			requested, err = d.requested(context.TODO(), types.NamespacedName{Namespace: "ns", Name: "another"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(requested).To(BeFalse())
+		})
+
+		It("should not drain when drainer is not configured", func() {
+			var nilDrainer *Drainer
+			requested, err := nilDrainer.requested(context.TODO(), gwKey)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(requested).To(BeFalse())
+			Expect(nilDrainer.clearDrainStatus(gwKey)).To(BeFalse())
+		})
+
+		It("should map node annotation changes to gateways on the node", func() {
+			getTestDrainer()
+			Expect(r.mapNodeToGateways(context.TODO(), node)).To(Equal([]reconcile.Request{{NamespacedName: gwKey}}))
+			Expect(r.mapNodeToGateways(context.TODO(), &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "other"}})).To(BeEmpty())
+		})
+	})
+
+	Context("Test drain", func() {
+		BeforeEach(func() {
+			node.Annotations = map[string]string{consts.GatewayDrainAnnotationKey: gwKey.String()}
+			getTestDrainer()
+		})
+
+		It("should wait for connections to close", func() {
+			expectConnections(flow, flow, other)
+			requeueAfter, err := r.reconcileDrain(context.TODO(), gwConfig)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(requeueAfter).To(Equal(drainCheckInterval))
+			Expect(r.LBProbeServer.IsDraining(testUID)).To(BeTrue())
+			Expect(r.LBProbeServer.GetGateways()).To(Equal([]string{testUID}))
+			drain := reportedDrain()
+			Expect(drain).NotTo(BeNil())
+			Expect(drain.Phase).To(Equal(egressgatewayv1alpha1.GatewayDrainPhaseDraining))
+			Expect(drain.ActiveConnections).To(Equal(int32(2)))
+			Expect(drain.CompletionTime).To(BeNil())
+
+			expectConnections(flow, other)
+			requeueAfter, err = r.reconcileDrain(context.TODO(), gwConfig)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(requeueAfter).To(BeZero())
+			drain = reportedDrain()
+			Expect(drain.Phase).To(Equal(egressgatewayv1alpha1.GatewayDrainPhaseDrained))
+			Expect(drain.ActiveConnections).To(Equal(int32(1)))
+			Expect(drain.CompletionTime).NotTo(BeNil())
+			Expect(drain.TimedOut).To(BeFalse())
+
+			// no more conntrack checks once drained
+			requeueAfter, err = r.reconcileDrain(context.TODO(), gwConfig)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(requeueAfter).To(BeZero())
+			Expect(r.LBProbeServer.IsDraining(testUID)).To(BeTrue())
+		})
+
+		It("should complete drain after timeout", func() {
+			d.Timeout = time.Minute
+			d.setDrainStatus(gwKey, &egressgatewayv1alpha1.GatewayDrainStatus{
+				Phase:     egressgatewayv1alpha1.GatewayDrainPhaseDraining,
+				StartTime: metav1.NewTime(time.Now().Add(-2 * time.Minute)),
+			})
+			expectConnections(flow, flow)
+			requeueAfter, err := r.reconcileDrain(context.TODO(), gwConfig)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(requeueAfter).To(BeZero())
+			drain := reportedDrain()
+			Expect(drain.Phase).To(Equal(egressgatewayv1alpha1.GatewayDrainPhaseDrained))
+			Expect(drain.TimedOut).To(BeTrue())
+		})
+
+		It("should resume drain reported before restart", func() {
+			startTime := metav1.NewTime(time.Now().Add(-time.Hour).Truncate(time.Second))
+			status := &egressgatewayv1alpha1.GatewayStatus{}
+			Expect(r.Get(context.TODO(), types.NamespacedName{Namespace: testPodNamespace, Name: testNodeName}, status)).To(Succeed())
+			status.Spec.ReadyGatewayConfigurations[0].Drain = &egressgatewayv1alpha1.GatewayDrainStatus{
+				Phase:     egressgatewayv1alpha1.GatewayDrainPhaseDraining,
+				StartTime: startTime,
+			}
+			Expect(r.Update(context.TODO(), status)).To(Succeed())
+
+			expectConnections(flow, flow, flow)
+			_, err := r.reconcileDrain(context.TODO(), gwConfig)
+			Expect(err).NotTo(HaveOccurred())
+			drain := reportedDrain()
+			Expect(drain.StartTime.Equal(&startTime)).To(BeTrue())
+			Expect(drain.TimedOut).To(BeTrue())
+		})
+
+		It("should undrain when drain is cancelled", func() {
+			expectConnections(flow, flow)
+			_, err := r.reconcileDrain(context.TODO(), gwConfig)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(r.LBProbeServer.IsDraining(testUID)).To(BeTrue())
+
+			node.Annotations = nil
+			Expect(r.Update(context.TODO(), node)).To(Succeed())
+			requeueAfter, err := r.reconcileDrain(context.TODO(), gwConfig)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(requeueAfter).To(BeZero())
+			Expect(r.LBProbeServer.IsDraining(testUID)).To(BeFalse())
+			Expect(reportedDrain()).To(BeNil())
+			Expect(d.drainStatus(gwKey)).To(BeNil())
+		})
+	})
+
+	Context("Test admin endpoint", func() {
+		BeforeEach(func() {
+			getTestDrainer()
+		})
+
+		serve := func(method, path string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(method, path, nil)
+			resp := httptest.NewRecorder()
+			d.serveHTTP(resp, req)
+			return resp
+		}
+
+		It("should drain a single gateway", func() {
+			Expect(serve(http.MethodPost, "/drain/"+gwKey.String()).Code).To(Equal(http.StatusAccepted))
+			Expect(gwEvents).To(Receive(WithTransform(func(e event.GenericEvent) string { return e.Object.GetName() }, Equal(testName))))
+			requested, err := d.requested(context.TODO(), gwKey)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(requested).To(BeTrue())
+
+			resp := serve(http.MethodGet, "/drain")
+			Expect(resp.Code).To(Equal(http.StatusOK))
+			status := drainAdminStatus{}
+			Expect(json.Unmarshal(resp.Body.Bytes(), &status)).To(Succeed())
+			Expect(status.Node).To(BeFalse())
+			Expect(status.Gateways).To(Equal([]string{gwKey.String()}))
+
+			Expect(serve(http.MethodDelete, "/drain/"+gwKey.String()).Code).To(Equal(http.StatusAccepted))
+			requested, err = d.requested(context.TODO(), gwKey)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(requested).To(BeFalse())
+		})
+
+		It("should drain all gateways on the node", func() {
+			Expect(serve(http.MethodPost, "/drain").Code).To(Equal(http.StatusAccepted))
+			Expect(gwEvents).To(Receive(WithTransform(func(e event.GenericEvent) string { return e.Object.GetName() }, Equal(testName))))
+			requested, err := d.requested(context.TODO(), types.NamespacedName{Namespace: "ns", Name: "any"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(requested).To(BeTrue())
+		})
+
+		It("should reject invalid requests", func() {
+			Expect(serve(http.MethodPost, "/drain/"+testNamespace).Code).To(Equal(http.StatusBadRequest))
+			Expect(serve(http.MethodPost, "/drain/a/b/c").Code).To(Equal(http.StatusBadRequest))
+			Expect(serve(http.MethodPut, "/drain").Code).To(Equal(http.StatusMethodNotAllowed))
+			Expect(gwEvents).NotTo(Receive())
+		})
+	})
+})
@@ -0,0 +1,451 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netns"
+	"golang.org/x/sys/unix"
+	"golang.org/x/time/rate"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	egressgatewayv1alpha1 "github.com/Azure/kube-egress-gateway/api/v1alpha1"
+	"github.com/Azure/kube-egress-gateway/pkg/consts"
+	"github.com/Azure/kube-egress-gateway/pkg/metrics"
+	"github.com/Azure/kube-egress-gateway/pkg/netfilter"
+)
+
+const (
+	driftResourceGateway     = "StaticGatewayConfiguration"
+	driftResourcePodEndpoint = "PodEndpoint"
+
+	driftSourceLink      = "link"
+	driftSourceAddr      = "addr"
+	driftSourceRoute     = "route"
+	driftSourceNetfilter = "netfilter"
+
+	// hostNetns is the name used for the network namespace the daemon runs in
+	hostNetns = ""
+
+	// per item backoff, so that the daemon does not fight with another agent in a tight loop
+	driftBaseDelay = 10 * time.Millisecond
+	driftMaxDelay  = 5 * time.Minute
+	// overall rate limit of drift repairs
+	driftQPS   = 10
+	driftBurst = 100
+
+	netlinkResubscribeDelay = 5 * time.Second
+	netfilterCheckInterval  = 30 * time.Second
+)
+
+// driftItem is a StaticGatewayConfiguration or a PodEndpoint whose network configuration has drifted
+type driftItem struct {
+	resource string
+	types.NamespacedName
+}
+
+type linkState struct {
+	name string
+	up   bool
+}
+
+var _ manager.Runnable = &DriftMonitor{}
+
+// DriftMonitor watches netlink and netfilter changes on the gateway node and enqueues
+// the StaticGatewayConfigurations and PodEndpoints whose network configuration has been
+// changed by someone else, so that it is repaired right away instead of on the next resync.
+type DriftMonitor struct {
+	client.Client
+	GatewayEvents   chan<- event.GenericEvent
+	PeerEvents      chan<- event.GenericEvent
+	FirewallBackend netfilter.Backend
+	Netfilter       netfilter.Interface
+
+	queue workqueue.TypedRateLimitingInterface[driftItem]
+	lock  sync.Mutex
+	// drift detected but not repaired yet, and what revealed it
+	pending map[driftItem]string
+	// last time each item drifted, to reset its backoff
+	lastDrift map[driftItem]time.Time
+	// known links in each network namespace, by index
+	links map[string]map[int]linkState
+}
+
+// SetupWithManager adds the drift monitor to the Manager.
+func (m *DriftMonitor) SetupWithManager(mgr ctrl.Manager) error {
+	nf, err := netfilter.New(m.FirewallBackend)
+	if err != nil {
+		return err
+	}
+	m.Netfilter = nf
+	m.initialize()
+	return mgr.Add(m)
+}
+
+func (m *DriftMonitor) initialize() {
+	m.queue = workqueue.NewTypedRateLimitingQueueWithConfig(
+		workqueue.NewTypedMaxOfRateLimiter(
+			workqueue.NewTypedItemExponentialFailureRateLimiter[driftItem](driftBaseDelay, driftMaxDelay),
+			&workqueue.TypedBucketRateLimiter[driftItem]{Limiter: rate.NewLimiter(rate.Limit(driftQPS), driftBurst)},
+		),
+		workqueue.TypedRateLimitingQueueConfig[driftItem]{Name: "drift-monitor"},
+	)
+	m.pending = make(map[driftItem]string)
+	m.lastDrift = make(map[driftItem]time.Time)
+	m.links = map[string]map[int]linkState{
+		hostNetns:               {},
+		consts.GatewayNetnsName: {},
+	}
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable, every gateway node runs its own monitor.
+func (m *DriftMonitor) NeedLeaderElection() bool {
+	return false
+}
+
+// Start implements manager.Runnable.
+func (m *DriftMonitor) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("drift-monitor")
+	ctx = log.IntoContext(ctx, logger)
+	defer m.queue.ShutDown()
+
+	logger.Info("Starting drift monitor")
+	go m.watchNetlink(ctx, hostNetns)
+	go m.watchNetlink(ctx, consts.GatewayNetnsName)
+	// netfilter rules are only monitored in the host network namespace, the daemon runs there
+	go m.Netfilter.Monitor(ctx, netfilterCheckInterval, func() {
+		m.enqueueGateways(ctx, driftSourceNetfilter, func(*egressgatewayv1alpha1.StaticGatewayConfiguration) bool { return true })
+	})
+	go m.runWorker(ctx)
+
+	<-ctx.Done()
+	logger.Info("Stopping drift monitor")
+	return nil
+}
+
+// repaired records that item has been reconciled successfully.
+// It is a no-op when the drift monitor is not enabled.
+func (m *DriftMonitor) repaired(resource string, key types.NamespacedName) {
+	if m == nil {
+		return
+	}
+	item := driftItem{resource: resource, NamespacedName: key}
+	m.lock.Lock()
+	source, ok := m.pending[item]
+	delete(m.pending, item)
+	m.lock.Unlock()
+	if ok {
+		metrics.GatewayDaemonDriftRepairedCount.WithLabelValues(resource, source).Inc()
+	}
+}
+
+func (m *DriftMonitor) watchNetlink(ctx context.Context, nsName string) {
+	log := log.FromContext(ctx).WithValues("netns", nsName)
+	for {
+		if err := m.subscribe(ctx, nsName); err != nil {
+			log.Error(err, "netlink subscription failed, resubscribing", "delay", netlinkResubscribeDelay)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(netlinkResubscribeDelay):
+		}
+	}
+}
+
+func (m *DriftMonitor) subscribe(ctx context.Context, nsName string) error {
+	var nsHandle *netns.NsHandle
+	if nsName != hostNetns {
+		handle, err := netns.GetFromName(nsName)
+		if err != nil {
+			return fmt.Errorf("failed to get network namespace %s: %w", nsName, err)
+		}
+		defer func() { _ = handle.Close() }()
+		nsHandle = &handle
+	}
+
+	// links are listed again below
+	m.lock.Lock()
+	m.links[nsName] = map[int]linkState{}
+	m.lock.Unlock()
+
+	done := make(chan struct{})
+	linkCh := make(chan netlink.LinkUpdate)
+	addrCh := make(chan netlink.AddrUpdate)
+	routeCh := make(chan netlink.RouteUpdate)
+	// channels of started subscriptions are drained until closed, so that their goroutines can exit
+	var drains []func()
+	defer func() {
+		close(done)
+		for _, drain := range drains {
+			go drain()
+		}
+	}()
+	errCh := make(chan error, 1)
+	onError := func(err error) {
+		select {
+		case errCh <- err:
+		default:
+		}
+	}
+
+	if err := netlink.LinkSubscribeWithOptions(linkCh, done, netlink.LinkSubscribeOptions{
+		Namespace:     nsHandle,
+		ErrorCallback: onError,
+		// existing links are listed to learn which links are up
+		ListExisting: true,
+	}); err != nil {
+		return fmt.Errorf("failed to subscribe to link updates: %w", err)
+	}
+	drains = append(drains, func() {
+		for range linkCh {
+		}
+	})
+	if err := netlink.AddrSubscribeWithOptions(addrCh, done, netlink.AddrSubscribeOptions{
+		Namespace:     nsHandle,
+		ErrorCallback: onError,
+	}); err != nil {
+		return fmt.Errorf("failed to subscribe to address updates: %w", err)
+	}
+	drains = append(drains, func() {
+		for range addrCh {
+		}
+	})
+	if err := netlink.RouteSubscribeWithOptions(routeCh, done, netlink.RouteSubscribeOptions{
+		Namespace:     nsHandle,
+		ErrorCallback: onError,
+	}); err != nil {
+		return fmt.Errorf("failed to subscribe to route updates: %w", err)
+	}
+	drains = append(drains, func() {
+		for range routeCh {
+		}
+	})
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-errCh:
+			return err
+		case update, ok := <-linkCh:
+			if !ok {
+				return fmt.Errorf("link subscription closed")
+			}
+			m.handleLinkUpdate(ctx, nsName, update)
+		case update, ok := <-addrCh:
+			if !ok {
+				return fmt.Errorf("address subscription closed")
+			}
+			m.handleAddrUpdate(ctx, nsName, update)
+		case update, ok := <-routeCh:
+			if !ok {
+				return fmt.Errorf("route subscription closed")
+			}
+			m.handleRouteUpdate(ctx, nsName, update)
+		}
+	}
+}
+
+// handleLinkUpdate reacts to a link that was up being deleted or set down.
+// Links that were never up, e.g. while they're being created, are ignored.
+func (m *DriftMonitor) handleLinkUpdate(ctx context.Context, nsName string, update netlink.LinkUpdate) {
+	attrs := update.Link.Attrs()
+	deleted := update.Header.Type == unix.RTM_DELLINK
+	up := !deleted && attrs.Flags&net.FlagUp != 0
+
+	m.lock.Lock()
+	links := m.links[nsName]
+	wasUp := links[attrs.Index].up
+	if deleted {
+		delete(links, attrs.Index)
+	} else {
+		links[attrs.Index] = linkState{name: attrs.Name, up: up}
+	}
+	m.lock.Unlock()
+
+	if !wasUp || up {
+		return
+	}
+	log.FromContext(ctx).Info("Detected link change", "netns", nsName, "link", attrs.Name, "deleted", deleted)
+
+	switch {
+	case nsName == hostNetns && attrs.Name == consts.HostVethLinkName,
+		nsName == consts.GatewayNetnsName && attrs.Name == consts.HostLinkName:
+		m.enqueueGateways(ctx, driftSourceLink, func(*egressgatewayv1alpha1.StaticGatewayConfiguration) bool { return true })
+	case nsName == consts.GatewayNetnsName && strings.HasPrefix(attrs.Name, consts.WiregaurdLinkNamePrefix):
+		// wireguard peers are configured by the PodEndpoint reconciler
+		gwConfigs := m.enqueueGateways(ctx, driftSourceLink, func(gwConfig *egressgatewayv1alpha1.StaticGatewayConfiguration) bool {
+			return getWireguardInterfaceName(gwConfig) == attrs.Name
+		})
+		for _, gwConfig := range gwConfigs {
+			m.enqueuePodEndpoints(ctx, driftSourceLink, func(podEndpoint *egressgatewayv1alpha1.PodEndpoint) bool {
+				return podEndpoint.Namespace == gwConfig.Namespace && podEndpoint.Spec.StaticGatewayConfiguration == gwConfig.Name
+			})
+		}
+	}
+}
+
+// handleAddrUpdate reacts to the deletion of the ILB IPs on the host or of the secondary IPs in the gateway namespace.
+func (m *DriftMonitor) handleAddrUpdate(ctx context.Context, nsName string, update netlink.AddrUpdate) {
+	if update.NewAddr {
+		return
+	}
+	ip := update.LinkAddress.IP.String()
+	switch nsName {
+	case hostNetns:
+		m.enqueueGateways(ctx, driftSourceAddr, func(gwConfig *egressgatewayv1alpha1.StaticGatewayConfiguration) bool {
+			if gwConfig.Status.GatewayServerProfile.Ip == ip {
+				return true
+			}
+			for _, frontend := range gwConfig.Status.ZonalFrontends {
+				if frontend.Ip == ip {
+					return true
+				}
+			}
+			return false
+		})
+	case consts.GatewayNetnsName:
+		m.enqueueGateways(ctx, driftSourceAddr, func(gwConfig *egressgatewayv1alpha1.StaticGatewayConfiguration) bool {
+			vmConfig := &egressgatewayv1alpha1.GatewayVMConfiguration{}
+			if err := m.Get(ctx, client.ObjectKeyFromObject(gwConfig), vmConfig); err != nil || vmConfig.Status == nil {
+				return false
+			}
+			for _, vmProfile := range vmConfig.Status.GatewayVMProfiles {
+				if slices.Contains(vmProfileSNATIPs(vmProfile), ip) {
+					return true
+				}
+				for _, dedicated := range vmProfile.DedicatedIPs {
+					if dedicated.IP == ip {
+						return true
+					}
+				}
+			}
+			return false
+		})
+	}
+}
+
+// handleRouteUpdate reacts to the deletion of the routes to the gateway namespace on the host,
+// and of the default route or pod routes in the gateway namespace.
+func (m *DriftMonitor) handleRouteUpdate(ctx context.Context, nsName string, update netlink.RouteUpdate) {
+	if update.Type != unix.RTM_DELROUTE {
+		return
+	}
+	switch nsName {
+	case hostNetns:
+		m.lock.Lock()
+		link := m.links[hostNetns][update.LinkIndex]
+		m.lock.Unlock()
+		if link.name == consts.HostVethLinkName {
+			m.enqueueGateways(ctx, driftSourceRoute, func(*egressgatewayv1alpha1.StaticGatewayConfiguration) bool { return true })
+		}
+	case consts.GatewayNetnsName:
+		if isDefaultRoute(update.Route) {
+			m.enqueueGateways(ctx, driftSourceRoute, func(*egressgatewayv1alpha1.StaticGatewayConfiguration) bool { return true })
+			return
+		}
+		dst := update.Dst.String()
+		m.enqueuePodEndpoints(ctx, driftSourceRoute, func(podEndpoint *egressgatewayv1alpha1.PodEndpoint) bool {
+			_, podIPNet, err := net.ParseCIDR(podEndpoint.Spec.PodIpAddress)
+			return err == nil && podIPNet.String() == dst
+		})
+	}
+}
+
+// enqueueGateways enqueues the active StaticGatewayConfigurations of this node matching filter, and returns them.
+func (m *DriftMonitor) enqueueGateways(
+	ctx context.Context,
+	source string,
+	filter func(*egressgatewayv1alpha1.StaticGatewayConfiguration) bool,
+) []egressgatewayv1alpha1.StaticGatewayConfiguration {
+	log := log.FromContext(ctx)
+	gwConfigList := &egressgatewayv1alpha1.StaticGatewayConfigurationList{}
+	if err := m.List(ctx, gwConfigList); err != nil {
+		log.Error(err, "failed to list staticGatewayConfigurations")
+		return nil
+	}
+	var gwConfigs []egressgatewayv1alpha1.StaticGatewayConfiguration
+	for _, gwConfig := range gwConfigList.Items {
+		if !isReady(&gwConfig) || !applyToNode(&gwConfig) || !gwConfig.DeletionTimestamp.IsZero() || !filter(&gwConfig) {
+			continue
+		}
+		m.enqueue(ctx, driftItem{resource: driftResourceGateway, NamespacedName: client.ObjectKeyFromObject(&gwConfig)}, source)
+		gwConfigs = append(gwConfigs, gwConfig)
+	}
+	return gwConfigs
+}
+
+// enqueuePodEndpoints enqueues the PodEndpoints matching filter.
+func (m *DriftMonitor) enqueuePodEndpoints(
+	ctx context.Context,
+	source string,
+	filter func(*egressgatewayv1alpha1.PodEndpoint) bool,
+) {
+	log := log.FromContext(ctx)
+	podEndpointList := &egressgatewayv1alpha1.PodEndpointList{}
+	if err := m.List(ctx, podEndpointList); err != nil {
+		log.Error(err, "failed to list podEndpoints")
+		return
+	}
+	for _, podEndpoint := range podEndpointList.Items {
+		if !podEndpoint.DeletionTimestamp.IsZero() || !filter(&podEndpoint) {
+			continue
+		}
+		m.enqueue(ctx, driftItem{resource: driftResourcePodEndpoint, NamespacedName: client.ObjectKeyFromObject(&podEndpoint)}, source)
+	}
+}
+
+func (m *DriftMonitor) enqueue(ctx context.Context, item driftItem, source string) {
+	log.FromContext(ctx).Info("Detected drift", "resource", item.resource, "name", item.NamespacedName, "source", source)
+	m.lock.Lock()
+	if _, ok := m.pending[item]; !ok {
+		m.pending[item] = source
+	}
+	// items that have not drifted for a while start over with the base delay
+	if last, ok := m.lastDrift[item]; ok && time.Since(last) > driftMaxDelay {
+		m.queue.Forget(item)
+	}
+	m.lastDrift[item] = time.Now()
+	m.lock.Unlock()
+	m.queue.AddRateLimited(item)
+}
+
+// runWorker sends the drifted items to their reconcilers.
+func (m *DriftMonitor) runWorker(ctx context.Context) {
+	for {
+		item, shutdown := m.queue.Get()
+		if shutdown {
+			return
+		}
+		events := m.GatewayEvents
+		if item.resource == driftResourcePodEndpoint {
+			events = m.PeerEvents
+		}
+		select {
+		case events <- event.GenericEvent{
+			Object: &metav1.PartialObjectMetadata{
+				ObjectMeta: metav1.ObjectMeta{Name: item.Name, Namespace: item.Namespace},
+			},
+		}:
+		case <-ctx.Done():
+		}
+		m.queue.Done(item)
+	}
+}
@@ -0,0 +1,218 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+package daemon
+
+import (
+	"context"
+	"net"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	egressgatewayv1alpha1 "github.com/Azure/kube-egress-gateway/api/v1alpha1"
+	"github.com/Azure/kube-egress-gateway/pkg/consts"
+	"github.com/Azure/kube-egress-gateway/pkg/metrics"
+)
+
+var _ = Describe("Daemon drift monitor unit tests", func() {
+	var (
+		m           *DriftMonitor
+		gwEvents    chan event.GenericEvent
+		peerEvents  chan event.GenericEvent
+		gwConfig    *egressgatewayv1alpha1.StaticGatewayConfiguration
+		vmConfig    *egressgatewayv1alpha1.GatewayVMConfiguration
+		podEndpoint *egressgatewayv1alpha1.PodEndpoint
+		gwKey       = driftItem{resource: driftResourceGateway, NamespacedName: types.NamespacedName{Namespace: testNamespace, Name: testName}}
+		peerKey     = driftItem{resource: driftResourcePodEndpoint, NamespacedName: types.NamespacedName{Namespace: testNamespace, Name: "pod"}}
+	)
+
+	getTestDriftMonitor := func(objects ...runtime.Object) {
+		cl := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(objects...).Build()
+		gwEvents = make(chan event.GenericEvent, 10)
+		peerEvents = make(chan event.GenericEvent, 10)
+		m = &DriftMonitor{Client: cl, GatewayEvents: gwEvents, PeerEvents: peerEvents}
+		m.initialize()
+	}
+
+	linkUpdate := func(msgType uint16, index int, name string, up bool) netlink.LinkUpdate {
+		update := netlink.LinkUpdate{Link: &netlink.Dummy{LinkAttrs: netlink.LinkAttrs{Index: index, Name: name}}}
+		update.Header.Type = msgType
+		if up {
+			update.Link.Attrs().Flags = net.FlagUp
+		}
+		return update
+	}
+
+	pendingItems := func() map[driftItem]string {
+		m.lock.Lock()
+		defer m.lock.Unlock()
+		items := make(map[driftItem]string, len(m.pending))
+		for item, source := range m.pending {
+			items[item] = source
+		}
+		return items
+	}
+
+	BeforeEach(func() {
+		origNodeTags := nodeTags
+		DeferCleanup(func() { nodeTags = origNodeTags })
+		nodeTags = map[string]string{consts.AKSNodepoolTagKey: testNodepoolName}
+		gwConfig = &egressgatewayv1alpha1.StaticGatewayConfiguration{
+			ObjectMeta: metav1.ObjectMeta{Name: testName, Namespace: testNamespace},
+			Spec:       egressgatewayv1alpha1.StaticGatewayConfigurationSpec{GatewayNodepoolName: testNodepoolName},
+			Status:     getTestGwConfigStatus(),
+		}
+		vmConfig = &egressgatewayv1alpha1.GatewayVMConfiguration{
+			ObjectMeta: metav1.ObjectMeta{Name: testName, Namespace: testNamespace},
+			Status: &egressgatewayv1alpha1.GatewayVMConfigurationStatus{
+				GatewayVMProfiles: []egressgatewayv1alpha1.GatewayVMProfile{
+					{NodeName: testNodeName, PrimaryIP: "10.0.0.5", SecondaryIP: "10.0.0.6"},
+				},
+			},
+		}
+		podEndpoint = &egressgatewayv1alpha1.PodEndpoint{
+			ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: testNamespace},
+			Spec: egressgatewayv1alpha1.PodEndpointSpec{
+				StaticGatewayConfiguration: testName,
+				PodIpAddress:               "10.244.0.10/32",
+			},
+		}
+	})
+
+	Context("Test link updates", func() {
+		It("should enqueue gateway and its pod endpoints when wireguard link is deleted", func() {
+			getTestDriftMonitor(gwConfig, podEndpoint)
+			m.handleLinkUpdate(context.TODO(), consts.GatewayNetnsName, linkUpdate(unix.RTM_NEWLINK, 5, "wg-6000", true))
+			Expect(pendingItems()).To(BeEmpty())
+			m.handleLinkUpdate(context.TODO(), consts.GatewayNetnsName, linkUpdate(unix.RTM_DELLINK, 5, "wg-6000", false))
+			Expect(pendingItems()).To(Equal(map[driftItem]string{gwKey: driftSourceLink, peerKey: driftSourceLink}))
+		})
+
+		It("should enqueue gateway when host veth is set down", func() {
+			getTestDriftMonitor(gwConfig, podEndpoint)
+			m.handleLinkUpdate(context.TODO(), hostNetns, linkUpdate(unix.RTM_NEWLINK, 3, consts.HostVethLinkName, true))
+			m.handleLinkUpdate(context.TODO(), hostNetns, linkUpdate(unix.RTM_NEWLINK, 3, consts.HostVethLinkName, false))
+			Expect(pendingItems()).To(Equal(map[driftItem]string{gwKey: driftSourceLink}))
+		})
+
+		It("should ignore links that were never up", func() {
+			getTestDriftMonitor(gwConfig, podEndpoint)
+			m.handleLinkUpdate(context.TODO(), consts.GatewayNetnsName, linkUpdate(unix.RTM_NEWLINK, 5, "wg-6000", false))
+			m.handleLinkUpdate(context.TODO(), consts.GatewayNetnsName, linkUpdate(unix.RTM_DELLINK, 5, "wg-6000", false))
+			Expect(pendingItems()).To(BeEmpty())
+		})
+
+		It("should ignore wireguard links of other gateways", func() {
+			getTestDriftMonitor(gwConfig, podEndpoint)
+			m.handleLinkUpdate(context.TODO(), consts.GatewayNetnsName, linkUpdate(unix.RTM_NEWLINK, 6, "wg-6001", true))
+			m.handleLinkUpdate(context.TODO(), consts.GatewayNetnsName, linkUpdate(unix.RTM_DELLINK, 6, "wg-6001", false))
+			Expect(pendingItems()).To(BeEmpty())
+		})
+
+		It("should ignore gateways not applying to the node", func() {
+			gwConfig.Spec.GatewayNodepoolName = "othergw"
+			getTestDriftMonitor(gwConfig, podEndpoint)
+			m.handleLinkUpdate(context.TODO(), consts.GatewayNetnsName, linkUpdate(unix.RTM_NEWLINK, 2, consts.HostLinkName, true))
+			m.handleLinkUpdate(context.TODO(), consts.GatewayNetnsName, linkUpdate(unix.RTM_DELLINK, 2, consts.HostLinkName, false))
+			Expect(pendingItems()).To(BeEmpty())
+		})
+	})
+
+	Context("Test address updates", func() {
+		It("should enqueue gateway when ILB IP is deleted from host", func() {
+			getTestDriftMonitor(gwConfig)
+			m.handleAddrUpdate(context.TODO(), hostNetns, netlink.AddrUpdate{LinkAddress: *getIPNet("10.0.0.1/32"), NewAddr: false})
+			m.handleAddrUpdate(context.TODO(), hostNetns, netlink.AddrUpdate{LinkAddress: *getIPNet(ilbIPCidr), NewAddr: true})
+			Expect(pendingItems()).To(BeEmpty())
+			m.handleAddrUpdate(context.TODO(), hostNetns, netlink.AddrUpdate{LinkAddress: *getIPNet(ilbIPCidr), NewAddr: false})
+			Expect(pendingItems()).To(Equal(map[driftItem]string{gwKey: driftSourceAddr}))
+		})
+
+		It("should enqueue gateway when secondary IP is deleted from gateway namespace", func() {
+			getTestDriftMonitor(gwConfig, vmConfig)
+			m.handleAddrUpdate(context.TODO(), consts.GatewayNetnsName, netlink.AddrUpdate{LinkAddress: *getIPNet("10.0.0.7/32"), NewAddr: false})
+			Expect(pendingItems()).To(BeEmpty())
+			m.handleAddrUpdate(context.TODO(), consts.GatewayNetnsName, netlink.AddrUpdate{LinkAddress: *getIPNet("10.0.0.6/32"), NewAddr: false})
+			Expect(pendingItems()).To(Equal(map[driftItem]string{gwKey: driftSourceAddr}))
+		})
+	})
+
+	Context("Test route updates", func() {
+		It("should enqueue pod endpoint when its route is deleted", func() {
+			getTestDriftMonitor(gwConfig, podEndpoint)
+			m.handleRouteUpdate(context.TODO(), consts.GatewayNetnsName, netlink.RouteUpdate{Type: unix.RTM_NEWROUTE, Route: netlink.Route{Dst: getIPNet("10.244.0.10/32")}})
+			m.handleRouteUpdate(context.TODO(), consts.GatewayNetnsName, netlink.RouteUpdate{Type: unix.RTM_DELROUTE, Route: netlink.Route{Dst: getIPNet("10.244.0.11/32")}})
+			Expect(pendingItems()).To(BeEmpty())
+			m.handleRouteUpdate(context.TODO(), consts.GatewayNetnsName, netlink.RouteUpdate{Type: unix.RTM_DELROUTE, Route: netlink.Route{Dst: getIPNet("10.244.0.10/32")}})
+			Expect(pendingItems()).To(Equal(map[driftItem]string{peerKey: driftSourceRoute}))
+		})
+
+		It("should enqueue gateway when default route is deleted from gateway namespace", func() {
+			getTestDriftMonitor(gwConfig, podEndpoint)
+			m.handleRouteUpdate(context.TODO(), consts.GatewayNetnsName, netlink.RouteUpdate{Type: unix.RTM_DELROUTE, Route: netlink.Route{Dst: getIPNet("0.0.0.0/0")}})
+			Expect(pendingItems()).To(Equal(map[driftItem]string{gwKey: driftSourceRoute}))
+		})
+
+		It("should enqueue gateway when route via host veth is deleted from host", func() {
+			getTestDriftMonitor(gwConfig, podEndpoint)
+			m.handleLinkUpdate(context.TODO(), hostNetns, linkUpdate(unix.RTM_NEWLINK, 3, consts.HostVethLinkName, true))
+			m.handleRouteUpdate(context.TODO(), hostNetns, netlink.RouteUpdate{Type: unix.RTM_DELROUTE, Route: netlink.Route{LinkIndex: 4, Dst: getIPNet("10.0.0.6/32")}})
+			Expect(pendingItems()).To(BeEmpty())
+			m.handleRouteUpdate(context.TODO(), hostNetns, netlink.RouteUpdate{Type: unix.RTM_DELROUTE, Route: netlink.Route{LinkIndex: 3, Dst: getIPNet("10.0.0.6/32")}})
+			Expect(pendingItems()).To(Equal(map[driftItem]string{gwKey: driftSourceRoute}))
+		})
+	})
+
+	Context("Test repair", func() {
+		It("should send drifted objects to their reconcilers", func() {
+			getTestDriftMonitor(gwConfig, podEndpoint)
+			ctx, cancel := context.WithCancel(context.TODO())
+			defer cancel()
+			go m.runWorker(ctx)
+
+			m.enqueue(ctx, gwKey, driftSourceLink)
+			m.enqueue(ctx, peerKey, driftSourceRoute)
+			var gwEvent, peerEvent event.GenericEvent
+			Eventually(gwEvents).Should(Receive(&gwEvent))
+			Expect(gwEvent.Object.GetNamespace()).To(Equal(testNamespace))
+			Expect(gwEvent.Object.GetName()).To(Equal(testName))
+			Eventually(peerEvents).Should(Receive(&peerEvent))
+			Expect(peerEvent.Object.GetNamespace()).To(Equal(testNamespace))
+			Expect(peerEvent.Object.GetName()).To(Equal("pod"))
+		})
+
+		It("should count repaired drift", func() {
+			getTestDriftMonitor()
+			before := testutil.ToFloat64(metrics.GatewayDaemonDriftRepairedCount.WithLabelValues(driftResourceGateway, driftSourceAddr))
+			m.enqueue(context.TODO(), gwKey, driftSourceAddr)
+			// the first source is kept
+			m.enqueue(context.TODO(), gwKey, driftSourceLink)
+			m.repaired(driftResourceGateway, gwKey.NamespacedName)
+			// reconciling again without drift is not counted
+			m.repaired(driftResourceGateway, gwKey.NamespacedName)
+			Expect(testutil.ToFloat64(metrics.GatewayDaemonDriftRepairedCount.WithLabelValues(driftResourceGateway, driftSourceAddr))).To(Equal(before + 1))
+			Expect(testutil.ToFloat64(metrics.GatewayDaemonDriftRepairedCount.WithLabelValues(driftResourceGateway, driftSourceLink))).To(BeZero())
+			Expect(pendingItems()).To(BeEmpty())
+		})
+
+		It("should be a no-op when drift monitor is disabled", func() {
+			var disabled *DriftMonitor
+			Expect(func() { disabled.repaired(driftResourceGateway, gwKey.NamespacedName) }).NotTo(Panic())
+		})
+	})
+
+	AfterEach(func() {
+		if m != nil {
+			m.queue.ShutDown()
+		}
+	})
+})
@@ -0,0 +1,79 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/Azure/kube-egress-gateway/pkg/consts"
+)
+
+const (
+	// DefaultWarningInterval is the default minimum time between two identical warnings on the same object
+	DefaultWarningInterval = 5 * time.Minute
+
+	// reasonReconcileGatewayError is the reason of warnings about failures to configure a gateway on the node
+	reasonReconcileGatewayError = "ReconcileGatewayError"
+	// reasonReconcilePeerError is the reason of warnings about failures to configure a pod peer on the node
+	reasonReconcilePeerError = "ReconcilePeerError"
+)
+
+// WarningRecorder emits Warning events about reconcile failures on this node, so that they show on the
+// StaticGatewayConfiguration and PodEndpoint instead of only in the daemon logs. The message is prefixed
+// with the node name as every gateway node reports on the same objects. The same warning on the same object
+// is emitted at most once per Interval, so that failures retried with backoff do not flood the events.
+type WarningRecorder struct {
+	Recorder record.EventRecorder
+	// Interval is the minimum time between two identical warnings on the same object
+	Interval time.Duration
+
+	lock sync.Mutex
+	// object uid/reason/message -> time the warning was last emitted
+	emitted map[string]time.Time
+}
+
+// NewWarningRecorder returns a WarningRecorder emitting events with recorder, a non-positive interval uses DefaultWarningInterval.
+func NewWarningRecorder(recorder record.EventRecorder, interval time.Duration) *WarningRecorder {
+	if interval <= 0 {
+		interval = DefaultWarningInterval
+	}
+	return &WarningRecorder{
+		Recorder: recorder,
+		Interval: interval,
+		emitted:  make(map[string]time.Time),
+	}
+}
+
+// warning emits a Warning event with reason and err on obj, unless the same warning was emitted less than Interval ago.
+func (w *WarningRecorder) warning(obj client.Object, reason string, err error) {
+	if w == nil || err == nil {
+		return
+	}
+	message := fmt.Sprintf("node %s: %v", os.Getenv(consts.NodeNameEnvKey), err)
+	key := string(obj.GetUID()) + "/" + reason + "/" + message
+	now := time.Now()
+
+	w.lock.Lock()
+	for k, emitted := range w.emitted {
+		if now.Sub(emitted) >= w.Interval {
+			delete(w.emitted, k)
+		}
+	}
+	_, limited := w.emitted[key]
+	if !limited {
+		w.emitted[key] = now
+	}
+	w.lock.Unlock()
+
+	if !limited {
+		w.Recorder.Event(obj, corev1.EventTypeWarning, reason, message)
+	}
+}
@@ -9,9 +9,13 @@ import (
 	"net"
 	"os"
 	"strings"
+	"sync"
 
 	"github.com/containernetworking/plugins/pkg/ns"
 	"github.com/vishvananda/netlink"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
 	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
@@ -30,6 +34,7 @@ import (
 	"github.com/Azure/kube-egress-gateway/pkg/consts"
 	"github.com/Azure/kube-egress-gateway/pkg/netlinkwrapper"
 	"github.com/Azure/kube-egress-gateway/pkg/netnswrapper"
+	"github.com/Azure/kube-egress-gateway/pkg/tracing"
 	"github.com/Azure/kube-egress-gateway/pkg/wgctrlwrapper"
 )
 
@@ -39,9 +44,17 @@ var _ reconcile.Reconciler = &PodEndpointReconciler{}
 type PodEndpointReconciler struct {
 	client.Client
 	TickerEvents chan event.GenericEvent
+	DriftMonitor *DriftMonitor
+	Checkpoint   *Checkpoint
+	Diagnostics  *Diagnostics
+	Events       *WarningRecorder
 	Netlink      netlinkwrapper.Interface
 	NetNS        netnswrapper.Interface
 	WgCtrl       wgctrlwrapper.Interface
+
+	tracedLock sync.Mutex
+	// PodEndpoint -> traceparent of the last pod attach traced
+	tracedPeers map[types.NamespacedName]string
 }
 
 // PeerUpdateOperation defines the type of operation to perform on peer configurations
@@ -59,6 +72,7 @@ const (
 //+kubebuilder:rbac:groups=core,resources=nodes,verbs=get;list;watch
 //+kubebuilder:rbac:groups=egressgateway.kubernetes.azure.com,resources=staticgatewayconfigurations,verbs=get;list;watch
 //+kubebuilder:rbac:groups=egressgateway.kubernetes.azure.com,resources=gatewaystatuses,verbs=get;list;watch;create;update;patch
+//+kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -69,8 +83,9 @@ const (
 //
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.13.0/pkg/reconcile
-func (r *PodEndpointReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+func (r *PodEndpointReconciler) Reconcile(ctx context.Context, req ctrl.Request) (result ctrl.Result, err error) {
 	log := log.FromContext(ctx)
+	defer func() { r.Diagnostics.recordReconcile("PodEndpoint", req.NamespacedName, err) }()
 
 	// Got an event from cleanup ticker
 	if req.NamespacedName.Namespace == "" && req.NamespacedName.Name == "" {
@@ -83,11 +98,17 @@ func (r *PodEndpointReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 	if err := r.Get(ctx, req.NamespacedName, podEndpoint); err != nil {
 		if apierrors.IsNotFound(err) {
 			// Object not found, return.
+			r.Checkpoint.confirmPeer(req.String())
+			r.forgetTrace(req.NamespacedName)
 			return ctrl.Result{}, nil
 		}
 		log.Error(err, "unable to fetch PodEndpoint instance")
 		return ctrl.Result{}, err
 	}
+	defer func() { r.Events.warning(podEndpoint, reasonReconcilePeerError, err) }()
+
+	ctx, span := r.startPeerSpan(ctx, podEndpoint)
+	defer func() { tracing.EndSpan(span, err) }()
 
 	gwConfigKey := types.NamespacedName{
 		Namespace: podEndpoint.Namespace,
@@ -101,11 +122,47 @@ func (r *PodEndpointReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 
 	if !applyToNode(gwConfig) {
 		// gwConfig does not apply to this node
+		r.Checkpoint.confirmPeer(req.String())
 		return ctrl.Result{}, nil
 	}
 
 	// Reconcile wireguard peer
-	return r.reconcile(ctx, gwConfig, podEndpoint)
+	res, err := r.reconcile(ctx, gwConfig, podEndpoint)
+	if err == nil {
+		r.DriftMonitor.repaired(driftResourcePodEndpoint, req.NamespacedName)
+	}
+	return res, err
+}
+
+// startPeerSpan continues the trace of the pod attach recorded on podEndpoint by cni manager, so that the time
+// to program the peer on this node shows in the trace. Every pod attach is only traced once, later reconciles
+// of the same PodEndpoint are not part of the attach and return a no-op span.
+func (r *PodEndpointReconciler) startPeerSpan(ctx context.Context, podEndpoint *egressgatewayv1alpha1.PodEndpoint) (context.Context, trace.Span) {
+	key := client.ObjectKeyFromObject(podEndpoint)
+	traceParent := podEndpoint.GetAnnotations()[tracing.TraceParentAnnotationKey]
+	r.tracedLock.Lock()
+	if r.tracedPeers == nil {
+		r.tracedPeers = make(map[types.NamespacedName]string)
+	}
+	traced := traceParent == "" || r.tracedPeers[key] == traceParent
+	r.tracedPeers[key] = traceParent
+	r.tracedLock.Unlock()
+	if traced {
+		return ctx, noop.Span{}
+	}
+
+	ctx, span := tracing.Tracer().Start(tracing.ExtractAnnotations(ctx, podEndpoint.GetAnnotations()), "program gateway peer", trace.WithAttributes(
+		attribute.String("podEndpoint", key.String()),
+		attribute.String("node", os.Getenv(consts.NodeNameEnvKey)),
+	))
+	return log.IntoContext(ctx, log.FromContext(ctx).WithValues("traceID", tracing.TraceID(ctx))), span
+}
+
+// forgetTrace forgets the pod attach traced for the deleted PodEndpoint.
+func (r *PodEndpointReconciler) forgetTrace(key types.NamespacedName) {
+	r.tracedLock.Lock()
+	defer r.tracedLock.Unlock()
+	delete(r.tracedPeers, key)
 }
 
 // SetupWithManager sets up the controller with the Manager.
@@ -191,6 +248,14 @@ func (r *PodEndpointReconciler) reconcile(
 		return ctrl.Result{}, err
 	}
 
+	if err := r.Checkpoint.setPeer(client.ObjectKeyFromObject(gwConfig).String(), client.ObjectKeyFromObject(podEndpoint).String(), PeerCheckpoint{
+		PublicKey: podEndpoint.Spec.PodPublicKey,
+		PodIP:     podEndpoint.Spec.PodIpAddress,
+	}); err != nil {
+		// the checkpoint only speeds up restarts, do not fail the reconcile
+		log.Error(err, "failed to write checkpoint")
+	}
+
 	log.Info("Pod wireguard endpoint reconciled")
 	return ctrl.Result{}, nil
 }
@@ -225,6 +290,13 @@ func (r *PodEndpointReconciler) cleanUp(ctx context.Context) error {
 			}
 			peerMap[wglinkName][podEndpoint.Spec.PodPublicKey] = struct{}{}
 		}
+		// keep the peer restored from checkpoint until the PodEndpoint is reconciled
+		if wglinkName, peer, ok := r.Checkpoint.unconfirmedPeer(client.ObjectKeyFromObject(&podEndpoint).String()); ok {
+			if _, exists := peerMap[wglinkName]; !exists {
+				peerMap[wglinkName] = make(map[string]struct{})
+			}
+			peerMap[wglinkName][peer.PublicKey] = struct{}{}
+		}
 	}
 
 	var keep []egressgatewayv1alpha1.PeerConfiguration
@@ -297,6 +369,11 @@ func (r *PodEndpointReconciler) cleanUpWgLink(
 			if err := wgClient.ConfigureDevice(wglinkName, wgConfig); err != nil {
 				return fmt.Errorf("failed to remove peers from wireguard device %s: %w", wglinkName, err)
 			}
+			for _, peer := range wgConfig.Peers {
+				if err := r.Checkpoint.deletePeer(wglinkName, peer.PublicKey.String()); err != nil {
+					log.Error(err, "failed to write checkpoint")
+				}
+			}
 		}
 		return nil
 	}); err != nil {
@@ -13,6 +13,9 @@ import (
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"github.com/vishvananda/netlink"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 	"go.uber.org/mock/gomock"
 	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
 	corev1 "k8s.io/api/core/v1"
@@ -31,6 +34,7 @@ import (
 	"github.com/Azure/kube-egress-gateway/pkg/imds"
 	"github.com/Azure/kube-egress-gateway/pkg/netlinkwrapper/mocknetlinkwrapper"
 	"github.com/Azure/kube-egress-gateway/pkg/netnswrapper/mocknetnswrapper"
+	"github.com/Azure/kube-egress-gateway/pkg/tracing"
 	"github.com/Azure/kube-egress-gateway/pkg/wgctrlwrapper/mockwgctrlwrapper"
 )
 
@@ -94,6 +98,42 @@ var _ = Describe("Daemon PodEndpoint controller unit tests", func() {
 		}
 	}
 
+	Context("Test tracing", func() {
+		var recorder *tracetest.SpanRecorder
+		BeforeEach(func() {
+			recorder = tracetest.NewSpanRecorder()
+			provider := otel.GetTracerProvider()
+			otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+			DeferCleanup(func() { otel.SetTracerProvider(provider) })
+			_, err := tracing.Setup("test", "")
+			Expect(err).NotTo(HaveOccurred())
+			getTestReconciler()
+			podEndpoint = getTestPodEndpoint()
+		})
+
+		It("should continue trace of every pod attach once", func() {
+			_, span := r.startPeerSpan(context.TODO(), podEndpoint)
+			Expect(span.IsRecording()).To(BeFalse())
+
+			attachCtx, attachSpan := tracing.Tracer().Start(context.TODO(), "cni add")
+			podEndpoint.Annotations = map[string]string{}
+			tracing.InjectAnnotations(attachCtx, podEndpoint.Annotations)
+			ctx, span := r.startPeerSpan(context.TODO(), podEndpoint)
+			Expect(span.IsRecording()).To(BeTrue())
+			Expect(tracing.TraceID(ctx)).To(Equal(attachSpan.SpanContext().TraceID().String()))
+			tracing.EndSpan(span, errors.New("failed"))
+			Expect(recorder.Ended()).To(HaveLen(1))
+			Expect(recorder.Ended()[0].Parent().SpanID()).To(Equal(attachSpan.SpanContext().SpanID()))
+
+			_, span = r.startPeerSpan(context.TODO(), podEndpoint)
+			Expect(span.IsRecording()).To(BeFalse())
+
+			r.forgetTrace(client.ObjectKeyFromObject(podEndpoint))
+			_, span = r.startPeerSpan(context.TODO(), podEndpoint)
+			Expect(span.IsRecording()).To(BeTrue())
+		})
+	})
+
 	Context("Skip reconcile", func() {
 		BeforeEach(func() {
 			req = reconcile.Request{
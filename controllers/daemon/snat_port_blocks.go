@@ -0,0 +1,193 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	egressgatewayv1alpha1 "github.com/Azure/kube-egress-gateway/api/v1alpha1"
+	"github.com/Azure/kube-egress-gateway/pkg/netfilter"
+)
+
+// snatPortBlockMinSize is the size port blocks are halved down to when there are more pods than blocks
+const snatPortBlockMinSize = 64
+
+// snatPortBlockPeer is a pod using the gateway, to be given a sNAT port block.
+type snatPortBlockPeer struct {
+	// PodEndpoint in <namespace>/<name> pattern
+	podEndpoint string
+	podIP       string
+}
+
+// snatPortBlockSize returns the size of the port blocks so that every peer gets one: the configured size
+// is halved, down to snatPortBlockMinSize, while there are fewer blocks than peers.
+func snatPortBlockSize(blockSize, snatIPCount, peerCount int) int {
+	for blockSize/2 >= snatPortBlockMinSize && snatIPCount*snatPortBlocksPerIP(blockSize) < peerCount {
+		blockSize /= 2
+	}
+	return blockSize
+}
+
+// snatPortBlocksPerIP returns the number of port blocks of a sNAT IP. The last block is not given out, it is
+// reserved for the shared sNAT rules so that pods without a block never get a port of a block.
+func snatPortBlocksPerIP(blockSize int) int {
+	return (netfilter.SharedSNATPortRangeStart(blockSize) - netfilter.SNATPortRangeStart) / blockSize
+}
+
+// assignSNATPortBlocks gives a block of blockSize sNAT source ports to each of the peers, sorted by PodEndpoint.
+// Slots are numbered across the sNAT IPs first, so that consecutive blocks go to different sNAT IPs.
+//
+// Peers keep their previous block while it is still valid, so that the attribution of their connections does
+// not change as other peers come and go. New peers get the lowest free slots. Previous blocks of another size
+// are not valid, so all peers are reassigned when the block size changes. Peers left without a block when
+// all slots are taken use the shared sNAT rules, which only get the reserved last block of each sNAT IP.
+func assignSNATPortBlocks(
+	peers []snatPortBlockPeer,
+	snatIPs []string,
+	blockSize int,
+	previous []egressgatewayv1alpha1.SnatPortBlock,
+) []egressgatewayv1alpha1.SnatPortBlock {
+	if len(peers) == 0 || len(snatIPs) == 0 || blockSize <= 0 {
+		return nil
+	}
+	blocksPerIP := snatPortBlocksPerIP(blockSize)
+	slotCount := len(snatIPs) * blocksPerIP
+	slotOf := func(block egressgatewayv1alpha1.SnatPortBlock) (int, bool) {
+		ipIdx := slices.Index(snatIPs, block.Ip)
+		offset := int(block.PortStart) - netfilter.SNATPortRangeStart
+		if ipIdx < 0 || offset < 0 || offset%blockSize != 0 || offset/blockSize >= blocksPerIP ||
+			int(block.PortEnd) != int(block.PortStart)+blockSize-1 {
+			return 0, false
+		}
+		return offset/blockSize*len(snatIPs) + ipIdx, true
+	}
+
+	previousBlocks := make(map[string]egressgatewayv1alpha1.SnatPortBlock, len(previous))
+	for _, block := range previous {
+		previousBlocks[block.PodEndpoint] = block
+	}
+	used := make(map[int]bool)
+	slots := make(map[string]int)
+	for _, peer := range peers {
+		block, ok := previousBlocks[peer.podEndpoint]
+		if !ok {
+			continue
+		}
+		if slot, ok := slotOf(block); ok && !used[slot] {
+			used[slot] = true
+			slots[peer.podEndpoint] = slot
+		}
+	}
+	next := 0
+	for _, peer := range peers {
+		if _, ok := slots[peer.podEndpoint]; ok {
+			continue
+		}
+		for next < slotCount && used[next] {
+			next++
+		}
+		if next == slotCount {
+			break
+		}
+		used[next] = true
+		slots[peer.podEndpoint] = next
+	}
+
+	blocks := make([]egressgatewayv1alpha1.SnatPortBlock, 0, len(slots))
+	for _, peer := range peers {
+		slot, ok := slots[peer.podEndpoint]
+		if !ok {
+			continue
+		}
+		portStart := netfilter.SNATPortRangeStart + slot/len(snatIPs)*blockSize
+		blocks = append(blocks, egressgatewayv1alpha1.SnatPortBlock{
+			PodEndpoint: peer.podEndpoint,
+			Ip:          snatIPs[slot%len(snatIPs)],
+			PortStart:   int32(portStart),
+			PortEnd:     int32(portStart + blockSize - 1),
+		})
+	}
+	return blocks
+}
+
+// getSNATPortBlocks gives a sNAT port block to each pod using the gateway without a dedicated egress IP. It returns
+// the blocks to record in the gateway status and the blocks by pod IP to program the sNAT rules with.
+func (r *StaticGatewayConfigurationReconciler) getSNATPortBlocks(
+	ctx context.Context,
+	gwConfig *egressgatewayv1alpha1.StaticGatewayConfiguration,
+	snatIPs []string,
+	dedicatedSNATIPs map[string]string,
+) ([]egressgatewayv1alpha1.SnatPortBlock, map[string]netfilter.SNATPortBlock, error) {
+	if gwConfig.Spec.SnatPortBlockSize <= 0 {
+		return nil, nil, nil
+	}
+
+	podEndpoints := &egressgatewayv1alpha1.PodEndpointList{}
+	if err := r.List(ctx, podEndpoints, client.InNamespace(gwConfig.Namespace)); err != nil {
+		return nil, nil, fmt.Errorf("failed to list podEndpoints: %w", err)
+	}
+	var peers []snatPortBlockPeer
+	for _, podEndpoint := range podEndpoints.Items {
+		if podEndpoint.Spec.StaticGatewayConfiguration != gwConfig.Name {
+			continue
+		}
+		podIP, _, err := net.ParseCIDR(podEndpoint.Spec.PodIpAddress)
+		if err != nil || podIP.To4() == nil {
+			log.FromContext(ctx).Info("Skipping sNAT port block of pod without IPv4 address", "podEndpoint", client.ObjectKeyFromObject(&podEndpoint))
+			continue
+		}
+		if _, ok := dedicatedSNATIPs[podIP.String()]; ok {
+			continue
+		}
+		peers = append(peers, snatPortBlockPeer{
+			podEndpoint: fmt.Sprintf("%s/%s", podEndpoint.Namespace, podEndpoint.Name),
+			podIP:       podIP.String(),
+		})
+	}
+	slices.SortFunc(peers, func(a, b snatPortBlockPeer) int { return strings.Compare(a.podEndpoint, b.podEndpoint) })
+
+	// previous blocks are kept in the gateway status of the node, so that they survive daemon restarts
+	var previous []egressgatewayv1alpha1.SnatPortBlock
+	gwStatus := &egressgatewayv1alpha1.GatewayStatus{}
+	if err := r.Get(ctx, gatewayStatusKey(), gwStatus); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return nil, nil, fmt.Errorf("failed to get gateway status: %w", err)
+		}
+	} else {
+		gwConfigKey := fmt.Sprintf("%s/%s", gwConfig.Namespace, gwConfig.Name)
+		for _, gwConf := range gwStatus.Spec.ReadyGatewayConfigurations {
+			if gwConf.StaticGatewayConfiguration == gwConfigKey {
+				previous = gwConf.SnatPortBlocks
+			}
+		}
+	}
+
+	blockSize := snatPortBlockSize(int(gwConfig.Spec.SnatPortBlockSize), len(snatIPs), len(peers))
+	blocks := assignSNATPortBlocks(peers, snatIPs, blockSize, previous)
+	if len(blocks) < len(peers) {
+		log.FromContext(ctx).Info("Not enough sNAT port blocks for all pods, the others use the shared sNAT rules",
+			"blockSize", blockSize, "pods", len(peers), "blocks", len(blocks))
+	}
+	podIPs := make(map[string]string, len(peers))
+	for _, peer := range peers {
+		podIPs[peer.podEndpoint] = peer.podIP
+	}
+	snatPortBlocks := make(map[string]netfilter.SNATPortBlock, len(blocks))
+	for _, block := range blocks {
+		snatPortBlocks[podIPs[block.PodEndpoint]] = netfilter.SNATPortBlock{
+			IP:        block.Ip,
+			PortStart: int(block.PortStart),
+			PortEnd:   int(block.PortEnd),
+		}
+	}
+	return blocks, snatPortBlocks, nil
+}
@@ -0,0 +1,214 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	utiliptables "k8s.io/kubernetes/pkg/util/iptables"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	egressgatewayv1alpha1 "github.com/Azure/kube-egress-gateway/api/v1alpha1"
+	"github.com/Azure/kube-egress-gateway/pkg/consts"
+	fakeiptables "github.com/Azure/kube-egress-gateway/pkg/iptableswrapper"
+	"github.com/Azure/kube-egress-gateway/pkg/netfilter"
+)
+
+var _ = Describe("Daemon sNAT port block unit tests", func() {
+	peers := func(names ...string) []snatPortBlockPeer {
+		result := make([]snatPortBlockPeer, 0, len(names))
+		for _, name := range names {
+			result = append(result, snatPortBlockPeer{podEndpoint: testNamespace + "/" + name})
+		}
+		return result
+	}
+	block := func(name, ip string, portStart, portEnd int32) egressgatewayv1alpha1.SnatPortBlock {
+		return egressgatewayv1alpha1.SnatPortBlock{PodEndpoint: testNamespace + "/" + name, Ip: ip, PortStart: portStart, PortEnd: portEnd}
+	}
+
+	It("should halve the block size until every peer gets a block", func() {
+		Expect(snatPortBlockSize(4096, 1, 14)).To(Equal(4096))
+		Expect(snatPortBlockSize(4096, 1, 15)).To(Equal(2048))
+		Expect(snatPortBlockSize(4096, 2, 28)).To(Equal(4096))
+		Expect(snatPortBlockSize(32768, 1, 1)).To(Equal(16384))
+		Expect(snatPortBlockSize(128, 1, 5000)).To(Equal(64))
+	})
+
+	It("should spread blocks across sNAT IPs", func() {
+		blocks := assignSNATPortBlocks(peers("pod1", "pod2", "pod3"), []string{"10.0.0.6", "10.0.0.7"}, 1024, nil)
+		Expect(blocks).To(Equal([]egressgatewayv1alpha1.SnatPortBlock{
+			block("pod1", "10.0.0.6", 1024, 2047),
+			block("pod2", "10.0.0.7", 1024, 2047),
+			block("pod3", "10.0.0.6", 2048, 3071),
+		}))
+	})
+
+	It("should keep previous blocks and give new peers the lowest free blocks", func() {
+		previous := []egressgatewayv1alpha1.SnatPortBlock{
+			block("pod1", "10.0.0.6", 1024, 2047),
+			block("pod3", "10.0.0.6", 3072, 4095),
+			// stale block of a peer which is gone
+			block("pod9", "10.0.0.6", 2048, 3071),
+		}
+		blocks := assignSNATPortBlocks(peers("pod2", "pod3", "pod4"), []string{"10.0.0.6"}, 1024, previous)
+		Expect(blocks).To(Equal([]egressgatewayv1alpha1.SnatPortBlock{
+			block("pod2", "10.0.0.6", 1024, 2047),
+			block("pod3", "10.0.0.6", 3072, 4095),
+			block("pod4", "10.0.0.6", 2048, 3071),
+		}))
+	})
+
+	It("should reassign all blocks when the block size changes", func() {
+		previous := []egressgatewayv1alpha1.SnatPortBlock{
+			block("pod1", "10.0.0.6", 2048, 3071),
+			block("pod2", "10.0.0.6", 1024, 2047),
+		}
+		blocks := assignSNATPortBlocks(peers("pod1", "pod2"), []string{"10.0.0.6"}, 512, previous)
+		Expect(blocks).To(Equal([]egressgatewayv1alpha1.SnatPortBlock{
+			block("pod1", "10.0.0.6", 1024, 1535),
+			block("pod2", "10.0.0.6", 1536, 2047),
+		}))
+	})
+
+	It("should leave peers without a block when all blocks are taken", func() {
+		blocks := assignSNATPortBlocks(peers("pod1", "pod2", "pod3"), []string{"10.0.0.6"}, 16384, nil)
+		Expect(blocks).To(Equal([]egressgatewayv1alpha1.SnatPortBlock{
+			block("pod1", "10.0.0.6", 1024, 17407),
+			block("pod2", "10.0.0.6", 17408, 33791),
+		}))
+	})
+
+	It("should leave the last block slot to the shared sNAT rules", func() {
+		snatIPs := []string{"10.0.0.6", "10.0.0.7", "10.0.0.8"}
+		for _, blockSize := range []int{64, 100, 1000, 4096, 16384, 32768} {
+			for _, peerCount := range []int{1, 2, 3, 10, 100, 3000, 5000} {
+				names := make([]string, 0, peerCount)
+				for i := 0; i < peerCount; i++ {
+					names = append(names, fmt.Sprintf("pod%05d", i))
+				}
+				size := snatPortBlockSize(blockSize, len(snatIPs), peerCount)
+				snatPortBlocks := make(map[string]netfilter.SNATPortBlock)
+				usedPorts := make(map[string]int32)
+				for i, b := range assignSNATPortBlocks(peers(names...), snatIPs, size, nil) {
+					Expect(b.PortStart).To(BeNumerically(">", usedPorts[b.Ip]), "blocks should not overlap")
+					usedPorts[b.Ip] = b.PortEnd
+					Expect(b.PortEnd).To(BeNumerically("<", netfilter.SharedSNATPortRangeStart(size)), "block size %d, %d peers", blockSize, peerCount)
+					snatPortBlocks[fmt.Sprintf("10.244.%d.%d", i/256, i%256)] = netfilter.SNATPortBlock{
+						IP: b.Ip, PortStart: int(b.PortStart), PortEnd: int(b.PortEnd),
+					}
+				}
+				// the shared sNAT rules are programmed in the last block slot
+				nf := netfilter.NewIPTables(fakeiptables.NewFake())
+				Expect(nf.EnsureGatewaySNAT(context.TODO(), "wg-6000", 6000, snatIPs, nil, snatPortBlocks)).To(Succeed())
+			}
+		}
+	})
+
+	It("should keep the shared sNAT ports when a pod is added", func() {
+		snatIPs := []string{"10.0.0.6"}
+		fipt := fakeiptables.NewFake()
+		nf := netfilter.NewIPTables(fipt)
+		expectSharedSNATPorts := func(blocks []egressgatewayv1alpha1.SnatPortBlock) {
+			snatPortBlocks := make(map[string]netfilter.SNATPortBlock)
+			for i, b := range blocks {
+				snatPortBlocks[fmt.Sprintf("10.244.0.%d", i+4)] = netfilter.SNATPortBlock{
+					IP: b.Ip, PortStart: int(b.PortStart), PortEnd: int(b.PortEnd),
+				}
+			}
+			Expect(nf.EnsureGatewaySNAT(context.TODO(), "wg-6000", 6000, snatIPs, nil, snatPortBlocks)).To(Succeed())
+			buf := bytes.NewBuffer(nil)
+			Expect(fipt.SaveInto(utiliptables.TableNAT, buf)).To(Succeed())
+			Expect(buf.String()).To(ContainSubstring("-A EGRESS-GATEWAY-SNAT-6000 -o host0 -m connmark --mark 6000 -j SNAT --to-source 10.0.0.6:64512-65535 --random-fully"))
+		}
+
+		// connections without a block, e.g. made before the pod got its block, use the shared sNAT ports
+		blocks := assignSNATPortBlocks(peers("pod1"), snatIPs, 1024, nil)
+		Expect(blocks).To(Equal([]egressgatewayv1alpha1.SnatPortBlock{block("pod1", "10.0.0.6", 1024, 2047)}))
+		expectSharedSNATPorts(blocks)
+
+		// new blocks stay out of the ports of the existing shared connections
+		blocks = assignSNATPortBlocks(peers("pod1", "pod2", "pod3"), snatIPs, 1024, blocks)
+		Expect(blocks).To(Equal([]egressgatewayv1alpha1.SnatPortBlock{
+			block("pod1", "10.0.0.6", 1024, 2047),
+			block("pod2", "10.0.0.6", 2048, 3071),
+			block("pod3", "10.0.0.6", 3072, 4095),
+		}))
+		expectSharedSNATPorts(blocks)
+	})
+
+	Context("Test getSNATPortBlocks", func() {
+		BeforeEach(func() {
+			_ = os.Setenv(consts.PodNamespaceEnvKey, testPodNamespace)
+			_ = os.Setenv(consts.NodeNameEnvKey, testNodeName)
+		})
+
+		AfterEach(func() {
+			_ = os.Setenv(consts.PodNamespaceEnvKey, "")
+			_ = os.Setenv(consts.NodeNameEnvKey, "")
+		})
+
+		It("should give blocks to pods without dedicated egress IPs and keep recorded blocks", func() {
+			gwConfig := &egressgatewayv1alpha1.StaticGatewayConfiguration{
+				ObjectMeta: metav1.ObjectMeta{Name: testName, Namespace: testNamespace},
+				Spec:       egressgatewayv1alpha1.StaticGatewayConfigurationSpec{SnatPortBlockSize: 1024},
+			}
+			podEndpoint := func(name, gateway, podIP string) *egressgatewayv1alpha1.PodEndpoint {
+				return &egressgatewayv1alpha1.PodEndpoint{
+					ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testNamespace},
+					Spec: egressgatewayv1alpha1.PodEndpointSpec{
+						StaticGatewayConfiguration: gateway,
+						PodIpAddress:               podIP,
+					},
+				}
+			}
+			gwStatus := &egressgatewayv1alpha1.GatewayStatus{
+				ObjectMeta: metav1.ObjectMeta{Name: testNodeName, Namespace: testPodNamespace},
+				Spec: egressgatewayv1alpha1.GatewayStatusSpec{
+					ReadyGatewayConfigurations: []egressgatewayv1alpha1.GatewayConfiguration{{
+						StaticGatewayConfiguration: testNamespace + "/" + testName,
+						InterfaceName:              "wg-6000",
+						SnatPortBlocks:             []egressgatewayv1alpha1.SnatPortBlock{block("pod2", "10.0.0.6", 1024, 2047)},
+					}},
+				},
+			}
+			cl := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(
+				gwStatus,
+				podEndpoint("pod1", testName, "10.244.0.4/32"),
+				podEndpoint("pod2", testName, "10.244.0.5/32"),
+				podEndpoint("pod3", testName, "10.244.0.6/32"),
+				podEndpoint("pod4", "other", "10.244.0.7/32"),
+				podEndpoint("pod5", testName, "fd00::5/128"),
+			).Build()
+			r := &StaticGatewayConfigurationReconciler{Client: cl}
+
+			blocks, snatPortBlocks, err := r.getSNATPortBlocks(context.TODO(), gwConfig, []string{"10.0.0.6"}, map[string]string{"10.244.0.6": "10.0.0.9"})
+			Expect(err).To(BeNil())
+			Expect(blocks).To(Equal([]egressgatewayv1alpha1.SnatPortBlock{
+				block("pod1", "10.0.0.6", 2048, 3071),
+				block("pod2", "10.0.0.6", 1024, 2047),
+			}))
+			Expect(snatPortBlocks).To(Equal(map[string]netfilter.SNATPortBlock{
+				"10.244.0.4": {IP: "10.0.0.6", PortStart: 2048, PortEnd: 3071},
+				"10.244.0.5": {IP: "10.0.0.6", PortStart: 1024, PortEnd: 2047},
+			}))
+		})
+
+		It("should not give blocks when disabled", func() {
+			r := &StaticGatewayConfigurationReconciler{Client: fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()}
+			gwConfig := &egressgatewayv1alpha1.StaticGatewayConfiguration{
+				ObjectMeta: metav1.ObjectMeta{Name: testName, Namespace: testNamespace},
+			}
+			blocks, snatPortBlocks, err := r.getSNATPortBlocks(context.TODO(), gwConfig, []string{"10.0.0.6"}, nil)
+			Expect(err).To(BeNil())
+			Expect(blocks).To(BeEmpty())
+			Expect(snatPortBlocks).To(BeEmpty())
+		})
+	})
+})
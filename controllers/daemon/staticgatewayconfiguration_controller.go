@@ -4,13 +4,14 @@
 package daemon
 
 import (
-	"bytes"
 	"context"
 	"fmt"
 	"net"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/containernetworking/plugins/pkg/ns"
 	"github.com/vishvananda/netlink"
@@ -20,13 +21,14 @@ import (
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
-	utiliptables "k8s.io/kubernetes/pkg/util/iptables"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
@@ -34,6 +36,7 @@ import (
 	"github.com/Azure/kube-egress-gateway/pkg/consts"
 	"github.com/Azure/kube-egress-gateway/pkg/healthprobe"
 	"github.com/Azure/kube-egress-gateway/pkg/imds"
+	"github.com/Azure/kube-egress-gateway/pkg/netfilter"
 	"github.com/Azure/kube-egress-gateway/pkg/netlinkwrapper"
 	"github.com/Azure/kube-egress-gateway/pkg/netnswrapper"
 	"github.com/Azure/kube-egress-gateway/pkg/utils/to"
@@ -45,12 +48,23 @@ var _ reconcile.Reconciler = &StaticGatewayConfigurationReconciler{}
 // StaticGatewayConfigurationReconciler reconciles gateway node network according to a StaticGatewayConfiguration object
 type StaticGatewayConfigurationReconciler struct {
 	client.Client
-	TickerEvents  chan event.GenericEvent
-	LBProbeServer *healthprobe.LBProbeServer
-	Netlink       netlinkwrapper.Interface
-	NetNS         netnswrapper.Interface
-	IPTables      utiliptables.Interface
-	WgCtrl        wgctrlwrapper.Interface
+	TickerEvents    chan event.GenericEvent
+	LBProbeServer   *healthprobe.LBProbeServer
+	FirewallBackend netfilter.Backend
+	DriftMonitor    *DriftMonitor
+	Checkpoint      *Checkpoint
+	Drainer         *Drainer
+	Diagnostics     *Diagnostics
+	Events          *WarningRecorder
+	// DataPathProbeAddress is the host:port to connect to from the gateway namespace in data path checks, empty disables the probe
+	DataPathProbeAddress string
+	Netlink              netlinkwrapper.Interface
+	NetNS                netnswrapper.Interface
+	Netfilter            netfilter.Interface
+	WgCtrl               wgctrlwrapper.Interface
+
+	// defaultMTU is the MTU of wireguard links for gateways not setting one, 0 keeps the kernel default
+	defaultMTU int
 }
 
 // +kubebuilder:rbac:groups=egressgateway.kubernetes.azure.com,resources=staticgatewayconfigurations,verbs=get;list;watch
@@ -59,6 +73,7 @@ type StaticGatewayConfigurationReconciler struct {
 // +kubebuilder:rbac:groups=egressgateway.kubernetes.azure.com,resources=gatewayvmconfigurations/status,verbs=get
 // +kubebuilder:rbac:groups=core,namespace=kube-egress-gateway-system,resources=secrets,verbs=get;list;watch
 // +kubebuilder:rbac:groups=egressgateway.kubernetes.azure.com,resources=gatewaystatuses,verbs=get;list;watch;create;update;patch
+// +kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -93,8 +108,9 @@ func InitNodeMetadata() error {
 	return nil
 }
 
-func (r *StaticGatewayConfigurationReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+func (r *StaticGatewayConfigurationReconciler) Reconcile(ctx context.Context, req ctrl.Request) (result ctrl.Result, err error) {
 	log := log.FromContext(ctx)
+	defer func() { r.Diagnostics.recordReconcile("StaticGatewayConfiguration", req.NamespacedName, err) }()
 
 	// Got an event from cleanup ticker
 	if req.NamespacedName.Namespace == "" && req.NamespacedName.Name == "" {
@@ -109,6 +125,9 @@ func (r *StaticGatewayConfigurationReconciler) Reconcile(ctx context.Context, re
 	if err := r.Get(ctx, req.NamespacedName, gwConfig); err != nil {
 		if apierrors.IsNotFound(err) {
 			// Object not found, return.
+			r.Checkpoint.confirmGateway(req.String())
+			r.Drainer.clearDrainStatus(req.NamespacedName)
+			clearSNATPortUtilization(req.String())
 			return ctrl.Result{}, nil
 		}
 		log.Error(err, "unable to fetch StaticGatewayConfiguration instance")
@@ -117,15 +136,20 @@ func (r *StaticGatewayConfigurationReconciler) Reconcile(ctx context.Context, re
 
 	if !isReady(gwConfig) {
 		// gateway setup hasn't completed yet
+		r.Checkpoint.confirmGateway(req.String())
 		return ctrl.Result{}, nil
 	}
 
 	if !applyToNode(gwConfig) {
 		// gwConfig does not apply to this node
+		r.Checkpoint.confirmGateway(req.String())
 		return ctrl.Result{}, nil
 	}
 
 	if !gwConfig.ObjectMeta.DeletionTimestamp.IsZero() {
+		r.Checkpoint.confirmGateway(req.String())
+		r.Drainer.clearDrainStatus(req.NamespacedName)
+		clearSNATPortUtilization(req.String())
 		if err := r.cleanUp(ctx); err != nil {
 			return ctrl.Result{}, fmt.Errorf("failed to clean up deleted StaticGatewayConfiguration %s/%s: %w", gwConfig.Namespace, gwConfig.Name, err)
 		}
@@ -133,20 +157,50 @@ func (r *StaticGatewayConfigurationReconciler) Reconcile(ctx context.Context, re
 	}
 
 	// Reconcile gateway configuration
-	return ctrl.Result{}, r.reconcile(ctx, gwConfig)
+	var requeueAfter time.Duration
+	err = r.reconcile(ctx, gwConfig)
+	if err == nil {
+		r.DriftMonitor.repaired(driftResourceGateway, req.NamespacedName)
+		if requeueAfter, err = r.reconcileDrain(ctx, gwConfig); err != nil {
+			err = fmt.Errorf("failed to reconcile drain of gateway: %w", err)
+		}
+	}
+	r.Events.warning(gwConfig, reasonReconcileGatewayError, err)
+	r.updateGatewayReconcileStatus(ctx, gwConfig, err)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *StaticGatewayConfigurationReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	r.Netlink = netlinkwrapper.NewNetLink()
 	r.NetNS = netnswrapper.NewNetNS()
-	r.IPTables = utiliptables.New(utiliptables.ProtocolIPv4)
+	nf, err := netfilter.New(r.FirewallBackend)
+	if err != nil {
+		return err
+	}
+	r.Netfilter = nf
 	r.WgCtrl = wgctrlwrapper.NewWgCtrl()
+	if eth0, err := r.Netlink.LinkByName("eth0"); err != nil {
+		mgr.GetLogger().Error(err, "failed to get eth0, using kernel default MTU for wireguard links")
+	} else {
+		r.defaultMTU = eth0.Attrs().MTU - consts.WireguardMTUOverhead
+	}
 	controller, err := ctrl.NewControllerManagedBy(mgr).
 		For(&egressgatewayv1alpha1.StaticGatewayConfiguration{}).
 		// We need to watch GatewayVMConfiguration also, because vmSecondaryIP may change, e.g. duing upgrade
 		// we can use EnqueueRequestForObject because GatewayVMConfiguration has the same namespace/name as StaticGatewayConfiguration
 		Watches(&egressgatewayv1alpha1.GatewayVMConfiguration{}, &handler.EnqueueRequestForObject{}).
+		// node annotation requests to drain gateways on the node
+		Watches(
+			&corev1.Node{},
+			handler.EnqueueRequestsFromMapFunc(r.mapNodeToGateways),
+			builder.WithPredicates(predicate.AnnotationChangedPredicate{}),
+		).
+		// pods using dedicated egress IPs or sNAT port blocks need their own sNAT rules
+		Watches(&egressgatewayv1alpha1.PodEndpoint{}, handler.EnqueueRequestsFromMapFunc(r.mapPodEndpointToGateway)).
 		Build(r)
 	if err != nil {
 		return err
@@ -154,6 +208,23 @@ func (r *StaticGatewayConfigurationReconciler) SetupWithManager(mgr ctrl.Manager
 	return controller.Watch(source.Channel(r.TickerEvents, &handler.EnqueueRequestForObject{}))
 }
 
+// mapPodEndpointToGateway enqueues the gateway used by the PodEndpoint if it has dedicated egress IPs or sNAT port blocks.
+func (r *StaticGatewayConfigurationReconciler) mapPodEndpointToGateway(ctx context.Context, o client.Object) []reconcile.Request {
+	podEndpoint, ok := o.(*egressgatewayv1alpha1.PodEndpoint)
+	if !ok || podEndpoint.Spec.StaticGatewayConfiguration == "" {
+		return nil
+	}
+	key := types.NamespacedName{Namespace: podEndpoint.Namespace, Name: podEndpoint.Spec.StaticGatewayConfiguration}
+	gwConfig := &egressgatewayv1alpha1.StaticGatewayConfiguration{}
+	if err := r.Get(ctx, key, gwConfig); err != nil || !applyToNode(gwConfig) {
+		return nil
+	}
+	if len(gwConfig.Spec.DedicatedEgressIps) == 0 && gwConfig.Spec.SnatPortBlockSize == 0 {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: key}}
+}
+
 func (r *StaticGatewayConfigurationReconciler) reconcile(
 	ctx context.Context,
 	gwConfig *egressgatewayv1alpha1.StaticGatewayConfiguration,
@@ -171,42 +242,46 @@ func (r *StaticGatewayConfigurationReconciler) reconcile(
 	if err := r.reconcileIlbIPOnHost(ctx, gwConfig.Status.GatewayServerProfile.Ip); err != nil {
 		return err
 	}
+	// add zonal lb ips as well so that the frontend of the node's zone is accepted
+	for _, frontend := range gwConfig.Status.ZonalFrontends {
+		if frontend.Ip == "" || frontend.Ip == gwConfig.Status.GatewayServerProfile.Ip {
+			continue
+		}
+		if err := r.reconcileIlbIPOnHost(ctx, frontend.Ip); err != nil {
+			return err
+		}
+	}
 
-	// remove secondary ip from eth0
-	vmPrimaryIP, vmSecondaryIP, err := r.getVMIP(ctx, gwConfig)
+	// remove secondary ips from eth0
+	vmPrimaryIP, vmSecondaryIPs, vmDedicatedIPs, err := r.getVMIP(ctx, gwConfig)
 	if err != nil {
 		return err
 	}
+	// dedicated egress IPs are configured on the node like the shared sNAT IPs
+	hostIPs := append(slices.Clone(vmSecondaryIPs), sortedValues(vmDedicatedIPs)...)
 
-	if err := r.removeSecondaryIpFromHost(ctx, vmSecondaryIP); err != nil {
+	if err := r.removeSecondaryIpFromHost(ctx, hostIPs); err != nil {
 		return err
 	}
 
 	// avoid masquerading packets from gateway namespace, as they're already sNATed
-	if err := r.ensureIPTablesChain(
-		ctx,
-		utiliptables.TableNAT,
-		utiliptables.Chain("EGRESS-GATEWAY-SNAT"), // target chain
-		utiliptables.ChainPostrouting,             // source chain
-		"kube-egress-gateway no MASQUERADE",
-		nil); err != nil {
-		return err
+	for _, vmSecondaryIP := range hostIPs {
+		if err := r.Netfilter.EnsureNoSNAT(ctx, vmSecondaryIP); err != nil {
+			return fmt.Errorf("failed to ensure no-sNAT rule for vmSecondaryIP %s: %w", vmSecondaryIP, err)
+		}
 	}
 
-	if err := r.ensureIPTablesChain(
-		ctx,
-		utiliptables.TableNAT,
-		utiliptables.Chain(fmt.Sprintf("EGRESS-%s", strings.ReplaceAll(vmSecondaryIP, ".", "-"))), // target chain
-		utiliptables.Chain("EGRESS-GATEWAY-SNAT"),                                                 // source chain
-		fmt.Sprintf("kube-egress-gateway no sNAT packet from ip %s", vmSecondaryIP),
-		[][]string{
-			{"-s", vmSecondaryIP + "/32", "-j", "ACCEPT"},
-		}); err != nil {
+	dedicatedSNATIPs, err := r.getDedicatedSNATIPs(ctx, gwConfig, vmDedicatedIPs)
+	if err != nil {
+		return err
+	}
+	portBlocks, snatPortBlocks, err := r.getSNATPortBlocks(ctx, gwConfig, vmSecondaryIPs, dedicatedSNATIPs)
+	if err != nil {
 		return err
 	}
 
 	// configure gateway namespace (if not exists)
-	if err := r.configureGatewayNamespace(ctx, gwConfig, privateKey, vmPrimaryIP, vmSecondaryIP); err != nil {
+	if err := r.configureGatewayNamespace(ctx, gwConfig, privateKey, vmPrimaryIP, vmSecondaryIPs, sortedValues(vmDedicatedIPs), dedicatedSNATIPs, snatPortBlocks); err != nil {
 		return err
 	}
 
@@ -214,6 +289,7 @@ func (r *StaticGatewayConfigurationReconciler) reconcile(
 	gwStatus := egressgatewayv1alpha1.GatewayConfiguration{
 		StaticGatewayConfiguration: fmt.Sprintf("%s/%s", gwConfig.Namespace, gwConfig.Name),
 		InterfaceName:              getWireguardInterfaceName(gwConfig),
+		SnatPortBlocks:             portBlocks,
 	}
 	if err := r.updateGatewayNodeStatus(ctx, gwStatus, PeerUpdateOpAdd); err != nil {
 		return err
@@ -223,6 +299,32 @@ func (r *StaticGatewayConfigurationReconciler) reconcile(
 		return err
 	}
 
+	ilbIPs := []string{gwConfig.Status.GatewayServerProfile.Ip}
+	for _, frontend := range gwConfig.Status.ZonalFrontends {
+		if frontend.Ip != "" && frontend.Ip != gwConfig.Status.GatewayServerProfile.Ip {
+			ilbIPs = append(ilbIPs, frontend.Ip)
+		}
+	}
+	mark, err := getPacketMark(gwStatus.InterfaceName)
+	if err != nil {
+		return err
+	}
+	gateway := GatewayCheckpoint{
+		UID:           string(gwConfig.GetUID()),
+		InterfaceName: gwStatus.InterfaceName,
+		ListenPort:    gwConfig.Status.Port,
+		SNATIP:        vmSecondaryIPs[0],
+		ILBIPs:        ilbIPs,
+		Mark:          mark,
+	}
+	if len(vmSecondaryIPs) > 1 {
+		gateway.SNATIPs = vmSecondaryIPs
+	}
+	if err := r.Checkpoint.setGateway(client.ObjectKeyFromObject(gwConfig).String(), gateway); err != nil {
+		// the checkpoint only speeds up restarts, do not fail the reconcile
+		log.Error(err, "failed to write checkpoint")
+	}
+
 	log.Info("Gateway configuration reconciled")
 	return nil
 }
@@ -240,13 +342,25 @@ func (r *StaticGatewayConfigurationReconciler) cleanUp(ctx context.Context) erro
 	hasActiveGateway := false
 	for _, gwConfig := range gwConfigList.Items {
 		if applyToNode(&gwConfig) && gwConfig.DeletionTimestamp.IsZero() {
-			_, vmSecondaryIP, err := r.getVMIP(ctx, &gwConfig)
+			// keep the configuration restored from checkpoint until the gateway is reconciled,
+			// vmConfig may not be available yet right after a restart
+			if gateway, ok := r.Checkpoint.unconfirmedGateway(client.ObjectKeyFromObject(&gwConfig).String()); ok {
+				log.Info("Keeping unconfirmed gateway from checkpoint", "gwConfig", fmt.Sprintf("%s/%s", gwConfig.Namespace, gwConfig.Name))
+				existingWgLinks[gateway.InterfaceName] = struct{}{}
+				for _, snatIP := range gateway.snatIPs() {
+					existingIPs[snatIP] = struct{}{}
+				}
+				hasActiveGateway = true
+			}
+			_, vmSecondaryIPs, vmDedicatedIPs, err := r.getVMIP(ctx, &gwConfig)
 			if err != nil {
 				log.Error(err, "failed to get VM secondaryIP during cleanup", "gwConfig", fmt.Sprintf("%s/%s", gwConfig.Namespace, gwConfig.Name))
 				continue
 			}
 			existingWgLinks[getWireguardInterfaceName(&gwConfig)] = struct{}{}
-			existingIPs[vmSecondaryIP] = struct{}{}
+			for _, vmSecondaryIP := range append(vmSecondaryIPs, sortedValues(vmDedicatedIPs)...) {
+				existingIPs[vmSecondaryIP] = struct{}{}
+			}
 			hasActiveGateway = true
 		}
 	}
@@ -304,14 +418,8 @@ func (r *StaticGatewayConfigurationReconciler) cleanUp(ctx context.Context) erro
 			return fmt.Errorf("failed to cleanup ILB IP on host: %w", err)
 		}
 
-		if err := r.removeIPTablesChains(
-			ctx,
-			utiliptables.TableNAT,
-			[]utiliptables.Chain{utiliptables.Chain("EGRESS-GATEWAY-SNAT")},
-			[]utiliptables.Chain{utiliptables.ChainPostrouting},
-			[]string{"kube-egress-gateway no MASQUERADE"},
-		); err != nil {
-			return fmt.Errorf("failed to delete iptables chain EGRESS-GATEWAY-SNAT: %w", err)
+		if err := r.Netfilter.CleanupNoSNAT(ctx); err != nil {
+			return fmt.Errorf("failed to cleanup no-sNAT rules: %w", err)
 		}
 	}
 
@@ -334,24 +442,12 @@ func (r *StaticGatewayConfigurationReconciler) ensureDeleteLink(ctx context.Cont
 		if err != nil {
 			return err
 		}
-		log.Info("Removing iptables rules", "mark", mark)
-		if err := r.removeIPTablesChains(
-			ctx,
-			utiliptables.TableNAT,
-			[]utiliptables.Chain{
-				utiliptables.Chain(fmt.Sprintf("EGRESS-GATEWAY-MARK-%d", mark)),
-				utiliptables.Chain(fmt.Sprintf("EGRESS-GATEWAY-SNAT-%d", mark)),
-			}, // target chain
-			[]utiliptables.Chain{
-				utiliptables.ChainPrerouting,
-				utiliptables.ChainPostrouting,
-			}, // source chain
-			[]string{
-				fmt.Sprintf("kube-egress-gateway mark packets from gateway link %s", linkName),
-				fmt.Sprintf("kube-egress-gateway sNAT packets from gateway link %s", linkName),
-			},
-		); err != nil {
-			return fmt.Errorf("failed to cleanup iptables rules for link %s and mark %d: %w", linkName, mark, err)
+		log.Info("Removing gateway sNAT rules", "mark", mark)
+		if err := r.Netfilter.DeleteGatewaySNAT(ctx, linkName, mark); err != nil {
+			return fmt.Errorf("failed to cleanup sNAT rules for link %s and mark %d: %w", linkName, mark, err)
+		}
+		if err := r.Netfilter.DeleteTCPMSSClamping(ctx, linkName, mark); err != nil {
+			return fmt.Errorf("failed to cleanup TCP MSS clamping rules for link %s and mark %d: %w", linkName, mark, err)
 		}
 		return nil
 	}); err != nil {
@@ -369,6 +465,10 @@ func (r *StaticGatewayConfigurationReconciler) ensureDeleteLink(ctx context.Cont
 	if err := r.LBProbeServer.RemoveGateway(link.Attrs().Alias); err != nil {
 		return err
 	}
+
+	if err := r.Checkpoint.deleteGateway(linkName); err != nil {
+		log.Error(err, "failed to write checkpoint")
+	}
 	return nil
 }
 
@@ -403,13 +503,7 @@ func (r *StaticGatewayConfigurationReconciler) ensureDeleteIP(ctx context.Contex
 	}
 
 	log.Info("Deleting no-sNAT rule for vmSecondaryIP", "ip", ip.IP.String())
-	if err := r.removeIPTablesChains(
-		ctx,
-		utiliptables.TableNAT,
-		[]utiliptables.Chain{utiliptables.Chain(fmt.Sprintf("EGRESS-%s", strings.ReplaceAll(ip.IP.String(), ".", "-")))}, // target chain
-		[]utiliptables.Chain{utiliptables.Chain("EGRESS-GATEWAY-SNAT")},                                                  // source chain
-		[]string{fmt.Sprintf("kube-egress-gateway no sNAT packet from ip %s", ip.IP.String())},
-	); err != nil {
+	if err := r.Netfilter.DeleteNoSNAT(ctx, ip.IP.String()); err != nil {
 		return fmt.Errorf("failed to clean up no-sNAT rule for vmSecondaryIP %s: %w", ip.IP.String(), err)
 	}
 	return nil
@@ -439,19 +533,22 @@ func (r *StaticGatewayConfigurationReconciler) getWireguardPrivateKey(
 	return &wgPrivateKey, nil
 }
 
+// getVMIP returns the primary IP, the shared sNAT IPs and the dedicated egress IPs by name of the node.
 func (r *StaticGatewayConfigurationReconciler) getVMIP(
 	ctx context.Context,
 	gwConfig *egressgatewayv1alpha1.StaticGatewayConfiguration,
-) (string, string, error) {
+) (string, []string, map[string]string, error) {
 	log := log.FromContext(ctx)
 
 	nodeName := nodeMeta.Compute.OSProfile.ComputerName
-	var primaryIP, secondaryIP string
+	var primaryIP string
+	var secondaryIPs []string
+	var dedicatedIPs map[string]string
 
 	// Fetch the StaticGatewayConfiguration instance.
 	vmConfig := &egressgatewayv1alpha1.GatewayVMConfiguration{}
 	if err := r.Get(ctx, types.NamespacedName{Namespace: gwConfig.Namespace, Name: gwConfig.Name}, vmConfig); err != nil {
-		return "", "", err
+		return "", nil, nil, err
 	}
 
 	log.Info("parsing tags", "tags", nodeMeta.Compute.Tags)
@@ -471,25 +568,105 @@ func (r *StaticGatewayConfigurationReconciler) getVMIP(
 	}
 	// this can happen in cleanup process when vmConfig is not ready yet
 	if vmConfig.Status == nil {
-		return "", "", fmt.Errorf("status is nil for GatewayVMConfiguration %s/%s", vmConfig.Namespace, vmConfig.Name)
+		return "", nil, nil, fmt.Errorf("status is nil for GatewayVMConfiguration %s/%s", vmConfig.Namespace, vmConfig.Name)
 	}
 
 	for _, vmProfile := range vmConfig.Status.GatewayVMProfiles {
-		log.Info("checking vmProfile", "nodeName", vmProfile.NodeName, "primaryIP", vmProfile.PrimaryIP, "secondaryIP", vmProfile.SecondaryIP)
+		log.Info("checking vmProfile", "nodeName", vmProfile.NodeName, "primaryIP", vmProfile.PrimaryIP, "secondaryIPs", vmProfileSNATIPs(vmProfile))
 		if vmProfile.NodeName == nodeName || vmProfile.NodeName == nicName {
 			primaryIP = vmProfile.PrimaryIP
-			secondaryIP = vmProfile.SecondaryIP
+			secondaryIPs = vmProfileSNATIPs(vmProfile)
+			dedicatedIPs = vmProfileDedicatedIPs(vmProfile)
 			break
 		}
 	}
 
-	if primaryIP == "" || secondaryIP == "" {
-		return "", "", fmt.Errorf("failed to find primary or secondary IP for node %s", nodeName)
+	if primaryIP == "" || len(secondaryIPs) == 0 || slices.Contains(secondaryIPs, "") {
+		return "", nil, nil, fmt.Errorf("failed to find primary or secondary IP for node %s", nodeName)
+	}
+
+	log.Info("Found primary and secondary IP for node", "nodeName", nodeName, "primaryIP", primaryIP, "secondaryIPs", secondaryIPs, "dedicatedIPs", dedicatedIPs)
+
+	return primaryIP, secondaryIPs, dedicatedIPs, nil
+}
+
+// vmProfileSNATIPs returns the sNAT IPs of a gateway VM, profiles of gateways with a single sNAT IP
+// only set SecondaryIP.
+func vmProfileSNATIPs(vmProfile egressgatewayv1alpha1.GatewayVMProfile) []string {
+	if len(vmProfile.SecondaryIPs) > 0 {
+		return vmProfile.SecondaryIPs
+	}
+	if vmProfile.SecondaryIP == "" {
+		return nil
+	}
+	return []string{vmProfile.SecondaryIP}
+}
+
+// vmProfileDedicatedIPs returns the dedicated egress IPs of a gateway VM by name.
+func vmProfileDedicatedIPs(vmProfile egressgatewayv1alpha1.GatewayVMProfile) map[string]string {
+	if len(vmProfile.DedicatedIPs) == 0 {
+		return nil
+	}
+	dedicatedIPs := make(map[string]string, len(vmProfile.DedicatedIPs))
+	for _, dedicated := range vmProfile.DedicatedIPs {
+		if dedicated.IP != "" {
+			dedicatedIPs[dedicated.Name] = dedicated.IP
+		}
+	}
+	return dedicatedIPs
+}
+
+func sortedValues(m map[string]string) []string {
+	values := make([]string, 0, len(m))
+	for _, v := range m {
+		values = append(values, v)
 	}
+	slices.Sort(values)
+	return values
+}
 
-	log.Info("Found primary and secondary IP for node", "nodeName", nodeName, "primaryIP", primaryIP, "secondaryIP", secondaryIP)
+// getDedicatedSNATIPs maps the IPs of the pods using the gateway to the dedicated egress IP of the node they
+// use, through the pod annotation or as the namespace default.
+func (r *StaticGatewayConfigurationReconciler) getDedicatedSNATIPs(
+	ctx context.Context,
+	gwConfig *egressgatewayv1alpha1.StaticGatewayConfiguration,
+	vmDedicatedIPs map[string]string,
+) (map[string]string, error) {
+	if len(vmDedicatedIPs) == 0 {
+		return nil, nil
+	}
+	namespaceDefault := ""
+	for _, dedicated := range gwConfig.Spec.DedicatedEgressIps {
+		if dedicated.NamespaceDefault {
+			namespaceDefault = dedicated.Name
+		}
+	}
 
-	return primaryIP, secondaryIP, nil
+	podEndpoints := &egressgatewayv1alpha1.PodEndpointList{}
+	if err := r.List(ctx, podEndpoints, client.InNamespace(gwConfig.Namespace)); err != nil {
+		return nil, fmt.Errorf("failed to list podEndpoints: %w", err)
+	}
+	dedicatedSNATIPs := make(map[string]string)
+	for _, podEndpoint := range podEndpoints.Items {
+		if podEndpoint.Spec.StaticGatewayConfiguration != gwConfig.Name {
+			continue
+		}
+		name := podEndpoint.Spec.DedicatedEgressIp
+		if name == "" {
+			name = namespaceDefault
+		}
+		snatIP, ok := vmDedicatedIPs[name]
+		if !ok {
+			continue
+		}
+		podIP, _, err := net.ParseCIDR(podEndpoint.Spec.PodIpAddress)
+		if err != nil || podIP.To4() == nil {
+			log.FromContext(ctx).Info("Skipping dedicated egress IP of pod without IPv4 address", "podEndpoint", client.ObjectKeyFromObject(&podEndpoint))
+			continue
+		}
+		dedicatedSNATIPs[podIP.String()] = snatIP
+	}
+	return dedicatedSNATIPs, nil
 }
 
 func isReady(gwConfig *egressgatewayv1alpha1.StaticGatewayConfiguration) bool {
@@ -580,7 +757,7 @@ func (r *StaticGatewayConfigurationReconciler) reconcileIlbIPOnHost(ctx context.
 	return nil
 }
 
-func (r *StaticGatewayConfigurationReconciler) removeSecondaryIpFromHost(ctx context.Context, ip string) error {
+func (r *StaticGatewayConfigurationReconciler) removeSecondaryIpFromHost(ctx context.Context, ips []string) error {
 	log := log.FromContext(ctx)
 	eth0, err := r.Netlink.LinkByName("eth0")
 	if err != nil {
@@ -593,7 +770,7 @@ func (r *StaticGatewayConfigurationReconciler) removeSecondaryIpFromHost(ctx con
 	}
 
 	for _, address := range addresses {
-		if address.IP.String() == ip {
+		if slices.Contains(ips, address.IP.String()) {
 			log.Info("Removing secondary IP from eth0", "secondary_ip", address.IP.String())
 			if err := r.Netlink.AddrDel(eth0, &address); err != nil {
 				return fmt.Errorf("failed to remove secondary ip from eth0: %w", err)
@@ -609,7 +786,10 @@ func (r *StaticGatewayConfigurationReconciler) configureGatewayNamespace(
 	gwConfig *egressgatewayv1alpha1.StaticGatewayConfiguration,
 	privateKey *wgtypes.Key,
 	vmPrimaryIP string,
-	vmSecondaryIP string,
+	vmSecondaryIPs []string,
+	vmDedicatedIPs []string,
+	dedicatedSNATIPs map[string]string,
+	snatPortBlocks map[string]netfilter.SNATPortBlock,
 ) error {
 	gwns, err := r.NetNS.GetNS(consts.GatewayNetnsName)
 	if err != nil {
@@ -621,7 +801,7 @@ func (r *StaticGatewayConfigurationReconciler) configureGatewayNamespace(
 		return err
 	}
 
-	if err := r.reconcileVethPair(ctx, gwns, vmPrimaryIP, vmSecondaryIP); err != nil {
+	if err := r.reconcileVethPair(ctx, gwns, vmPrimaryIP, append(slices.Clone(vmSecondaryIPs), vmDedicatedIPs...)); err != nil {
 		return err
 	}
 
@@ -639,28 +819,16 @@ func (r *StaticGatewayConfigurationReconciler) configureGatewayNamespace(
 		if err != nil {
 			return err
 		}
-		if err := r.ensureIPTablesChain(
-			ctx,
-			utiliptables.TableNAT,
-			utiliptables.Chain(fmt.Sprintf("EGRESS-GATEWAY-MARK-%d", mark)), // target chain
-			utiliptables.ChainPrerouting,                                    // source chain
-			fmt.Sprintf("kube-egress-gateway mark packets from gateway link %s", linkName),
-			[][]string{
-				{"-i", linkName, "-j", "CONNMARK", "--set-mark", fmt.Sprintf("%d", mark)},
-			}); err != nil {
-			return err
+		if err := r.Netfilter.EnsureGatewaySNAT(ctx, linkName, mark, vmSecondaryIPs, dedicatedSNATIPs, snatPortBlocks); err != nil {
+			return fmt.Errorf("failed to ensure sNAT rules for link %s: %w", linkName, err)
 		}
 
-		if err := r.ensureIPTablesChain(
-			ctx,
-			utiliptables.TableNAT,
-			utiliptables.Chain(fmt.Sprintf("EGRESS-GATEWAY-SNAT-%d", mark)), // target chain
-			utiliptables.ChainPostrouting,                                   // source chain
-			fmt.Sprintf("kube-egress-gateway sNAT packets from gateway link %s", linkName),
-			[][]string{
-				{"-o", consts.HostLinkName, "-m", "connmark", "--mark", fmt.Sprintf("%d", mark), "-j", "SNAT", "--to-source", vmSecondaryIP},
-			}); err != nil {
-			return err
+		if gwConfig.Spec.TcpMssClamping {
+			if err := r.Netfilter.EnsureTCPMSSClamping(ctx, linkName, mark); err != nil {
+				return fmt.Errorf("failed to ensure TCP MSS clamping rules for link %s: %w", linkName, err)
+			}
+		} else if err := r.Netfilter.DeleteTCPMSSClamping(ctx, linkName, mark); err != nil {
+			return fmt.Errorf("failed to cleanup TCP MSS clamping rules for link %s: %w", linkName, err)
 		}
 
 		return nil
@@ -729,6 +897,13 @@ func (r *StaticGatewayConfigurationReconciler) reconcileWireguardLink(
 			}
 		}
 
+		if mtu := r.getWireguardMTU(gwConfig); mtu != 0 && wgLink.Attrs().MTU != mtu {
+			log.Info("Setting wireguard link MTU", "orig mtu", wgLink.Attrs().MTU, "cur mtu", mtu)
+			if err := r.Netlink.LinkSetMTU(wgLink, mtu); err != nil {
+				return fmt.Errorf("failed to set wireguard link MTU: %w", err)
+			}
+		}
+
 		err = r.Netlink.LinkSetUp(wgLink)
 		if err != nil {
 			return fmt.Errorf("failed to set wireguard link up: %w", err)
@@ -762,6 +937,14 @@ func (r *StaticGatewayConfigurationReconciler) reconcileWireguardLink(
 	})
 }
 
+// getWireguardMTU returns the MTU of the gateway wireguard link, 0 if it should be left to the kernel default.
+func (r *StaticGatewayConfigurationReconciler) getWireguardMTU(gwConfig *egressgatewayv1alpha1.StaticGatewayConfiguration) int {
+	if gwConfig.Spec.Mtu != 0 {
+		return int(gwConfig.Spec.Mtu)
+	}
+	return r.defaultMTU
+}
+
 func (r *StaticGatewayConfigurationReconciler) createWireguardLink(gwns ns.NetNS, linkName, linkAlias string) error {
 	succeed := false
 	attr := netlink.NewLinkAttrs()
@@ -795,10 +978,10 @@ func (r *StaticGatewayConfigurationReconciler) reconcileVethPair(
 	ctx context.Context,
 	gwns ns.NetNS,
 	vmPrimaryIP string,
-	vmSecondaryIP string,
+	vmSecondaryIPs []string,
 ) error {
 	log := log.FromContext(ctx)
-	if err := r.reconcileVethPairInHost(ctx, gwns, vmSecondaryIP); err != nil {
+	if err := r.reconcileVethPairInHost(ctx, gwns, vmSecondaryIPs); err != nil {
 		return fmt.Errorf("failed to reconcile veth pair in host namespace: %w", err)
 	}
 
@@ -808,31 +991,33 @@ func (r *StaticGatewayConfigurationReconciler) reconcileVethPair(
 			return fmt.Errorf("failed to get host link in gateway namespace: %w", err)
 		}
 
-		_, snatIPNet, err := net.ParseCIDR(vmSecondaryIP + "/32")
-		if err != nil {
-			return fmt.Errorf("failed to parse SNAT IP(%s) for host interface: %w", vmSecondaryIP+"/32", err)
-		}
-		hostLinkAddr := netlink.Addr{IPNet: snatIPNet}
-
 		hostLinkAddrs, err := r.Netlink.AddrList(hostLink, nl.FAMILY_ALL)
 		if err != nil {
 			return fmt.Errorf("failed to retrieve address list from wireguard link: %w", err)
 		}
 
-		foundLink := false
-		for _, addr := range hostLinkAddrs {
-			if addr.Equal(hostLinkAddr) {
-				log.Info("Found host link address in gateway namespace")
-				foundLink = true
-				break
+		for _, vmSecondaryIP := range vmSecondaryIPs {
+			_, snatIPNet, err := net.ParseCIDR(vmSecondaryIP + "/32")
+			if err != nil {
+				return fmt.Errorf("failed to parse SNAT IP(%s) for host interface: %w", vmSecondaryIP+"/32", err)
+			}
+			hostLinkAddr := netlink.Addr{IPNet: snatIPNet}
+
+			foundLink := false
+			for _, addr := range hostLinkAddrs {
+				if addr.Equal(hostLinkAddr) {
+					log.Info("Found host link address in gateway namespace", "ip", vmSecondaryIP)
+					foundLink = true
+					break
+				}
 			}
-		}
 
-		if !foundLink {
-			log.Info("Adding host link address in gateway namespace")
-			err = r.Netlink.AddrAdd(hostLink, &hostLinkAddr)
-			if err != nil {
-				return fmt.Errorf("failed to add host link address in gateway namespace: %w", err)
+			if !foundLink {
+				log.Info("Adding host link address in gateway namespace", "ip", vmSecondaryIP)
+				err = r.Netlink.AddrAdd(hostLink, &hostLinkAddr)
+				if err != nil {
+					return fmt.Errorf("failed to add host link address in gateway namespace: %w", err)
+				}
 			}
 		}
 
@@ -871,7 +1056,7 @@ func (r *StaticGatewayConfigurationReconciler) reconcileVethPair(
 func (r *StaticGatewayConfigurationReconciler) reconcileVethPairInHost(
 	ctx context.Context,
 	gwns ns.NetNS,
-	snatIP string,
+	snatIPs []string,
 ) error {
 	log := log.FromContext(ctx)
 	succeed := false
@@ -908,23 +1093,25 @@ func (r *StaticGatewayConfigurationReconciler) reconcileVethPairInHost(
 		return fmt.Errorf("failed to set veth link in host namespace up: %w", err)
 	}
 
-	_, snatIPNet, err := net.ParseCIDR(snatIP + "/32")
-	if err != nil {
-		return fmt.Errorf("failed to parse SNAT IP %s: %w", snatIP+"/32", err)
-	}
-	route := &netlink.Route{
-		LinkIndex: mainLink.Attrs().Index,
-		Scope:     netlink.SCOPE_UNIVERSE,
-		Dst:       snatIPNet,
-	}
-	if err = r.addOrReplaceRoute(ctx, route); err != nil {
-		return fmt.Errorf("failed to create route to SNAT IP %s via gateway interface: %w", snatIP, err)
-	}
-	defer func() {
-		if !succeed {
-			_ = r.Netlink.RouteDel(route)
+	for _, snatIP := range snatIPs {
+		_, snatIPNet, err := net.ParseCIDR(snatIP + "/32")
+		if err != nil {
+			return fmt.Errorf("failed to parse SNAT IP %s: %w", snatIP+"/32", err)
 		}
-	}()
+		route := &netlink.Route{
+			LinkIndex: mainLink.Attrs().Index,
+			Scope:     netlink.SCOPE_UNIVERSE,
+			Dst:       snatIPNet,
+		}
+		if err = r.addOrReplaceRoute(ctx, route); err != nil {
+			return fmt.Errorf("failed to create route to SNAT IP %s via gateway interface: %w", snatIP, err)
+		}
+		defer func() {
+			if !succeed {
+				_ = r.Netlink.RouteDel(route)
+			}
+		}()
+	}
 
 	hostLink, err := r.Netlink.LinkByName(consts.HostLinkName)
 	if err == nil {
@@ -966,96 +1153,13 @@ func (r *StaticGatewayConfigurationReconciler) addOrReplaceRoute(ctx context.Con
 	return nil
 }
 
-func (r *StaticGatewayConfigurationReconciler) ensureIPTablesChain(
-	ctx context.Context,
-	table utiliptables.Table,
-	targetChain utiliptables.Chain,
-	sourceChain utiliptables.Chain,
-	jumpRuleComment string,
-	chainRules [][]string,
-) error {
-	log := log.FromContext(ctx)
-
-	// ensure target chain exists
-	log.Info("Ensuring iptables chain", "table", table, "target chain", targetChain)
-	if _, err := r.IPTables.EnsureChain(table, targetChain); err != nil {
-		return fmt.Errorf("failed to ensure chain %s in table %s: %w", targetChain, table, err)
-	}
-
-	// ensure jump rule exists, we use EnsureRule because we do not want to flush all rules in the source chain
-	log.Info("Ensuring jump rule", "source chain", sourceChain)
-	if _, err := r.IPTables.EnsureRule(utiliptables.Prepend, table, sourceChain, "-m", "comment", "--comment", jumpRuleComment, "-j", string(targetChain)); err != nil {
-		return fmt.Errorf("failed to ensure jump rule from chain %s to chain %s in table %s: %w", sourceChain, targetChain, table, err)
-	}
-
-	if len(chainRules) == 0 {
-		return nil
-	}
-
-	// ensure all rules in the target chain atomically
-	lines := bytes.NewBuffer(nil)
-	writeLine(lines, "*"+string(table))
-	writeLine(lines, utiliptables.MakeChainLine(targetChain))
-	for _, rule := range chainRules {
-		writeRule(lines, string(utiliptables.Append), targetChain, rule...)
-	}
-	writeLine(lines, "COMMIT")
-	log.Info("Restoring rules", "rules", lines.String())
-	if err := r.IPTables.RestoreAll(lines.Bytes(), utiliptables.NoFlushTables, utiliptables.NoRestoreCounters); err != nil {
-		return fmt.Errorf("failed to restore rules in chain %s in table %s: %w", targetChain, table, err)
-	}
-	return nil
-}
-
-func (r *StaticGatewayConfigurationReconciler) removeIPTablesChains(
-	ctx context.Context,
-	table utiliptables.Table,
-	targetChains []utiliptables.Chain,
-	sourceChains []utiliptables.Chain,
-	jumpRuleComments []string,
-) error {
-	log := log.FromContext(ctx)
-
-	iptablesData := bytes.NewBuffer(nil)
-	if err := r.IPTables.SaveInto(table, iptablesData); err != nil {
-		return fmt.Errorf("failed to save iptables data for table %s: %w", table, err)
-	}
-
-	existingChains := utiliptables.GetChainsFromTable(iptablesData.Bytes())
-	for i, targetChain := range targetChains {
-		sourceChain := sourceChains[i]
-		jumpRuleComment := jumpRuleComments[i]
-		if _, ok := existingChains[targetChain]; ok {
-			// delete jump rule first
-			log.Info("Deleting jump rule", "source chain", sourceChain, "target chain", targetChain)
-			if err := r.IPTables.DeleteRule(table, sourceChain, "-m", "comment", "--comment", jumpRuleComment, "-j", string(targetChain)); err != nil {
-				return fmt.Errorf("failed to delete jump rule from chain %s to chain %s in table %s: %w", sourceChain, targetChain, table, err)
-			}
-
-			log.Info("Flushing and deleting chain", "table", table, "target chain", targetChain)
-			lines := bytes.NewBuffer(nil)
-			writeLine(lines, "*"+string(table))
-			writeLine(lines, utiliptables.MakeChainLine(targetChain))
-			writeLine(lines, "-X", string(targetChain))
-			writeLine(lines, "COMMIT")
-			if err := r.IPTables.Restore(table, lines.Bytes(), utiliptables.NoFlushTables, utiliptables.NoRestoreCounters); err != nil {
-				return fmt.Errorf("failed to restore iptables table %s: %w", table, err)
-			}
-		}
-	}
-	return nil
-}
-
 func (r *StaticGatewayConfigurationReconciler) updateGatewayNodeStatus(
 	ctx context.Context,
 	gwConfig egressgatewayv1alpha1.GatewayConfiguration,
 	op PeerUpdateOperation,
 ) error {
 	log := log.FromContext(ctx)
-	gwStatusKey := types.NamespacedName{
-		Namespace: os.Getenv(consts.PodNamespaceEnvKey),
-		Name:      os.Getenv(consts.NodeNameEnvKey),
-	}
+	gwStatusKey := gatewayStatusKey()
 
 	gwStatus := &egressgatewayv1alpha1.GatewayStatus{}
 	if err := r.Get(ctx, gwStatusKey, gwStatus); err != nil {
@@ -1083,6 +1187,7 @@ func (r *StaticGatewayConfigurationReconciler) updateGatewayNodeStatus(
 				},
 				Spec: egressgatewayv1alpha1.GatewayStatusSpec{
 					ReadyGatewayConfigurations: []egressgatewayv1alpha1.GatewayConfiguration{gwConfig},
+					Zone:                       getNodeZone(),
 				},
 			}
 			if err := controllerutil.SetOwnerReference(node, gwStatus, r.Client.Scheme()); err != nil {
@@ -1101,6 +1206,9 @@ func (r *StaticGatewayConfigurationReconciler) updateGatewayNodeStatus(
 				if op == PeerUpdateOpDelete {
 					changed = true
 					gwStatus.Spec.ReadyGatewayConfigurations = append(gwStatus.Spec.ReadyGatewayConfigurations[:i], gwStatus.Spec.ReadyGatewayConfigurations[i+1:]...)
+				} else if !slices.Equal(gwConf.SnatPortBlocks, gwConfig.SnatPortBlocks) {
+					changed = true
+					gwStatus.Spec.ReadyGatewayConfigurations[i].SnatPortBlocks = gwConfig.SnatPortBlocks
 				}
 				found = true
 				break
@@ -1110,6 +1218,10 @@ func (r *StaticGatewayConfigurationReconciler) updateGatewayNodeStatus(
 			gwStatus.Spec.ReadyGatewayConfigurations = append(gwStatus.Spec.ReadyGatewayConfigurations, gwConfig)
 			changed = true
 		}
+		if zone := getNodeZone(); op == PeerUpdateOpAdd && gwStatus.Spec.Zone != zone {
+			gwStatus.Spec.Zone = zone
+			changed = true
+		}
 		if op == PeerUpdateOpDelete {
 			for i := len(gwStatus.Spec.ReadyPeerConfigurations) - 1; i >= 0; i = i - 1 {
 				if gwStatus.Spec.ReadyPeerConfigurations[i].InterfaceName == gwConfig.InterfaceName {
@@ -1128,6 +1240,56 @@ func (r *StaticGatewayConfigurationReconciler) updateGatewayNodeStatus(
 	return nil
 }
 
+// updateGatewayReconcileStatus reports the result of the last reconcile of the gateway in the gateway status object
+// of this node. Only gateways ready on the node have an entry, failures to report are logged and not retried.
+func (r *StaticGatewayConfigurationReconciler) updateGatewayReconcileStatus(
+	ctx context.Context,
+	gwConfig *egressgatewayv1alpha1.StaticGatewayConfiguration,
+	reconcileErr error,
+) {
+	log := log.FromContext(ctx)
+	gwStatus := &egressgatewayv1alpha1.GatewayStatus{}
+	if err := r.Get(ctx, gatewayStatusKey(), gwStatus); err != nil {
+		if !apierrors.IsNotFound(err) {
+			log.Error(err, "failed to get gateway status to report reconcile result")
+		}
+		return
+	}
+	lastError := ""
+	if reconcileErr != nil {
+		lastError = reconcileErr.Error()
+	}
+	now := metav1.Now()
+	gwConfigKey := fmt.Sprintf("%s/%s", gwConfig.Namespace, gwConfig.Name)
+	for i, gwConf := range gwStatus.Spec.ReadyGatewayConfigurations {
+		if gwConf.StaticGatewayConfiguration != gwConfigKey {
+			continue
+		}
+		gwStatus.Spec.ReadyGatewayConfigurations[i].LastError = lastError
+		gwStatus.Spec.ReadyGatewayConfigurations[i].LastReconcileTime = &now
+		if err := r.Update(ctx, gwStatus); err != nil {
+			log.Error(err, "failed to update gateway status to report reconcile result")
+		}
+		return
+	}
+}
+
+// gatewayStatusKey returns the key of the gateway status object of this node.
+func gatewayStatusKey() types.NamespacedName {
+	return types.NamespacedName{
+		Namespace: os.Getenv(consts.PodNamespaceEnvKey),
+		Name:      os.Getenv(consts.NodeNameEnvKey),
+	}
+}
+
+// getNodeZone returns the availability zone of the node, empty if it is not zonal.
+func getNodeZone() string {
+	if nodeMeta == nil || nodeMeta.Compute == nil {
+		return ""
+	}
+	return nodeMeta.Compute.Zone
+}
+
 func getWireguardInterfaceName(gwConfig *egressgatewayv1alpha1.StaticGatewayConfiguration) string {
 	return consts.WiregaurdLinkNamePrefix + fmt.Sprintf("%d", gwConfig.Status.Port)
 }
@@ -1139,15 +1301,3 @@ func getPacketMark(linkName string) (int, error) {
 	}
 	return mark, err
 }
-
-// Similar syntax to utiliptables.Interface.EnsureRule, except you don't pass a table
-// (you must write these rules under the line with the table name)
-func writeRule(lines *bytes.Buffer, position string, chain utiliptables.Chain, args ...string) {
-	fullArgs := append([]string{position, string(chain)}, args...)
-	writeLine(lines, fullArgs...)
-}
-
-// Join all words with spaces, terminate with newline and write to buf.
-func writeLine(lines *bytes.Buffer, words ...string) {
-	lines.WriteString(strings.Join(words, " ") + "\n")
-}
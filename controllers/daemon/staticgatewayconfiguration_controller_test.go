@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
@@ -26,6 +27,7 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
 	utiliptables "k8s.io/kubernetes/pkg/util/iptables"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
@@ -37,6 +39,7 @@ import (
 	"github.com/Azure/kube-egress-gateway/pkg/healthprobe"
 	"github.com/Azure/kube-egress-gateway/pkg/imds"
 	fakeiptables "github.com/Azure/kube-egress-gateway/pkg/iptableswrapper"
+	"github.com/Azure/kube-egress-gateway/pkg/netfilter"
 	"github.com/Azure/kube-egress-gateway/pkg/netlinkwrapper/mocknetlinkwrapper"
 	"github.com/Azure/kube-egress-gateway/pkg/netnswrapper/mocknetnswrapper"
 	"github.com/Azure/kube-egress-gateway/pkg/utils/to"
@@ -72,6 +75,7 @@ var _ = Describe("Daemon StaticGatewayConfiguration controller unit tests", func
 		reconcileErr error
 		gwConfig     *egressgatewayv1alpha1.StaticGatewayConfiguration
 		mclient      *mockwgctrlwrapper.MockClient
+		fipt         *fakeiptables.FakeIPTables
 		node         = &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: testNodeName}}
 	)
 
@@ -81,7 +85,8 @@ var _ = Describe("Daemon StaticGatewayConfiguration controller unit tests", func
 		r = &StaticGatewayConfigurationReconciler{Client: cl, LBProbeServer: healthprobe.NewLBProbeServer(1000, 0)}
 		r.Netlink = mocknetlinkwrapper.NewMockInterface(mctrl)
 		r.NetNS = mocknetnswrapper.NewMockInterface(mctrl)
-		r.IPTables = fakeiptables.NewFake()
+		fipt = fakeiptables.NewFake()
+		r.Netfilter = netfilter.NewIPTables(fipt)
 		r.WgCtrl = mockwgctrlwrapper.NewMockInterface(mctrl)
 		mclient = mockwgctrlwrapper.NewMockClient(mctrl)
 	}
@@ -146,6 +151,28 @@ var _ = Describe("Daemon StaticGatewayConfiguration controller unit tests", func
 				Expect(apierrors.IsNotFound(reconcileErr)).To(BeTrue())
 				Expect(res).To(Equal(ctrl.Result{}))
 			})
+
+			It("should emit rate limited warning event with node name", func() {
+				_ = os.Setenv(consts.NodeNameEnvKey, testNodeName)
+				DeferCleanup(func() { _ = os.Setenv(consts.NodeNameEnvKey, "") })
+				gwConfig.Status = getTestGwConfigStatus()
+				getTestReconciler(gwConfig)
+				recorder := record.NewFakeRecorder(10)
+				r.Events = NewWarningRecorder(recorder, time.Hour)
+				nodeTags = map[string]string{consts.AKSNodepoolTagKey: testNodepoolName}
+				_, reconcileErr = r.Reconcile(context.TODO(), req)
+				Expect(reconcileErr).NotTo(BeNil())
+				_, reconcileErr = r.Reconcile(context.TODO(), req)
+				Expect(reconcileErr).NotTo(BeNil())
+				Expect(recorder.Events).To(HaveLen(1))
+				Expect(<-recorder.Events).To(HavePrefix("Warning ReconcileGatewayError node " + testNodeName + ": "))
+
+				// same warning is emitted again after the interval
+				r.Events.Interval = 0
+				_, reconcileErr = r.Reconcile(context.TODO(), req)
+				Expect(reconcileErr).NotTo(BeNil())
+				Expect(recorder.Events).To(HaveLen(1))
+			})
 		})
 	})
 
@@ -176,6 +203,7 @@ var _ = Describe("Daemon StaticGatewayConfiguration controller unit tests", func
 				Compute: &imds.ComputeMetadata{
 					VMScaleSetName:    vmssName,
 					ResourceGroupName: vmssRG,
+					Zone:              "1",
 					OSProfile: imds.OSProfile{
 						ComputerName: testNodeName,
 					},
@@ -274,10 +302,43 @@ var _ = Describe("Daemon StaticGatewayConfiguration controller unit tests", func
 		})
 
 		It("should retrieve vm ips", func() {
-			primaryIP, secondaryIP, err := r.getVMIP(context.TODO(), gwConfig)
+			primaryIP, secondaryIPs, dedicatedIPs, err := r.getVMIP(context.TODO(), gwConfig)
 			Expect(err).To(BeNil())
 			Expect(primaryIP).To(Equal("10.0.0.5"))
-			Expect(secondaryIP).To(Equal("10.0.0.6"))
+			Expect(secondaryIPs).To(Equal([]string{"10.0.0.6"}))
+			Expect(dedicatedIPs).To(BeEmpty())
+		})
+
+		It("should map pod IPs to dedicated egress IPs", func() {
+			gwConfig.Spec.DedicatedEgressIps = []egressgatewayv1alpha1.DedicatedEgressIp{
+				{Name: "partner-a"},
+				{Name: "partner-b", NamespaceDefault: true},
+			}
+			podEndpoint := func(name, gateway, podIP, dedicatedEgressIP string) *egressgatewayv1alpha1.PodEndpoint {
+				return &egressgatewayv1alpha1.PodEndpoint{
+					ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testNamespace},
+					Spec: egressgatewayv1alpha1.PodEndpointSpec{
+						StaticGatewayConfiguration: gateway,
+						PodIpAddress:               podIP,
+						DedicatedEgressIp:          dedicatedEgressIP,
+					},
+				}
+			}
+			getTestReconciler(
+				podEndpoint("pod1", testName, "10.244.0.4/32", "partner-a"),
+				podEndpoint("pod2", testName, "10.244.0.5/32", ""),
+				podEndpoint("pod3", "other", "10.244.0.6/32", "partner-a"),
+				podEndpoint("pod4", testName, "fd00::4/128", "partner-a"),
+			)
+			dedicatedSNATIPs, err := r.getDedicatedSNATIPs(context.TODO(), gwConfig, map[string]string{
+				"partner-a": "10.0.0.9",
+				"partner-b": "10.0.0.10",
+			})
+			Expect(err).To(BeNil())
+			Expect(dedicatedSNATIPs).To(Equal(map[string]string{
+				"10.244.0.4": "10.0.0.9",
+				"10.244.0.5": "10.0.0.10",
+			}))
 		})
 
 		It("should remove secondary ip from eth0", func() {
@@ -286,7 +347,7 @@ var _ = Describe("Daemon StaticGatewayConfiguration controller unit tests", func
 			mnl.EXPECT().LinkByName("eth0").Return(eth0, nil)
 			mnl.EXPECT().AddrList(eth0, nl.FAMILY_ALL).Return([]netlink.Addr{{IPNet: getIPNetWithActualIP(ilbIPCidr)}}, nil)
 			mnl.EXPECT().AddrDel(eth0, &netlink.Addr{IPNet: getIPNetWithActualIP(ilbIPCidr)}).Return(nil)
-			err := r.removeSecondaryIpFromHost(context.TODO(), "10.0.0.4")
+			err := r.removeSecondaryIpFromHost(context.TODO(), []string{"10.0.0.4"})
 			Expect(err).To(BeNil())
 		})
 
@@ -295,7 +356,7 @@ var _ = Describe("Daemon StaticGatewayConfiguration controller unit tests", func
 			eth0 := &netlink.Device{LinkAttrs: netlink.LinkAttrs{Name: "eth0"}}
 			mnl.EXPECT().LinkByName("eth0").Return(eth0, nil)
 			mnl.EXPECT().AddrList(eth0, nl.FAMILY_ALL).Return([]netlink.Addr{}, nil)
-			err := r.removeSecondaryIpFromHost(context.TODO(), "10.0.0.4")
+			err := r.removeSecondaryIpFromHost(context.TODO(), []string{"10.0.0.4"})
 			Expect(err).To(BeNil())
 		})
 
@@ -328,8 +389,6 @@ var _ = Describe("Daemon StaticGatewayConfiguration controller unit tests", func
 			Expect(reconcileErr.Error()).To(ContainSubstring("failed"))
 
 			expectedDump := getHostNamespaceIptablesDump("10.0.0.6")
-			fipt, ok := r.IPTables.(*fakeiptables.FakeIPTables)
-			Expect(ok).To(BeTrue())
 			buf := bytes.NewBuffer(nil)
 			Expect(fipt.SaveInto("nat", buf)).NotTo(HaveOccurred())
 			Expect(buf.String()).To(Equal(expectedDump))
@@ -389,13 +448,11 @@ var _ = Describe("Daemon StaticGatewayConfiguration controller unit tests", func
 				mnl.EXPECT().LinkSetUp(loop).Return(nil),
 				// setup iptables rule
 			)
-			err := r.configureGatewayNamespace(context.TODO(), gwConfig, &pk, "10.0.0.5", "10.0.0.6")
+			err := r.configureGatewayNamespace(context.TODO(), gwConfig, &pk, "10.0.0.5", []string{"10.0.0.6"}, nil, nil, nil)
 			Expect(err).To(BeNil())
 
 			// verify iptables rules
 			expectedDump := getGatewayNamespaceIptablesDump(6000)
-			fipt, ok := r.IPTables.(*fakeiptables.FakeIPTables)
-			Expect(ok).To(BeTrue())
 			buf := bytes.NewBuffer(nil)
 			Expect(fipt.SaveInto("nat", buf)).NotTo(HaveOccurred())
 			Expect(buf.String()).To(Equal(expectedDump))
@@ -447,18 +504,80 @@ var _ = Describe("Daemon StaticGatewayConfiguration controller unit tests", func
 				mnl.EXPECT().LinkSetUp(loop).Return(nil),
 				// check iptables rule
 			)
-			err := r.configureGatewayNamespace(context.TODO(), gwConfig, &pk, "10.0.0.5", "10.0.0.6")
+			err := r.configureGatewayNamespace(context.TODO(), gwConfig, &pk, "10.0.0.5", []string{"10.0.0.6"}, nil, nil, nil)
 			Expect(err).To(BeNil())
 
 			// verify iptables rules
 			expectedDump := getGatewayNamespaceIptablesDump(6000)
-			fipt, ok := r.IPTables.(*fakeiptables.FakeIPTables)
-			Expect(ok).To(BeTrue())
 			buf := bytes.NewBuffer(nil)
 			Expect(fipt.SaveInto("nat", buf)).NotTo(HaveOccurred())
 			Expect(buf.String()).To(Equal(expectedDump))
 		})
 
+		It("should set wireguard link MTU and TCP MSS clamping rules", func() {
+			gwConfig.Spec.Mtu = 1400
+			gwConfig.Spec.TcpMssClamping = true
+			r.defaultMTU = 1420
+			fipt.AddBuiltinTargets("TCPMSS")
+			_, err := fipt.EnsureChain(utiliptables.TableMangle, utiliptables.ChainForward)
+			Expect(err).NotTo(HaveOccurred())
+			pk, _ := wgtypes.ParseKey(privK)
+			mnl := r.Netlink.(*mocknetlinkwrapper.MockInterface)
+			mns := r.NetNS.(*mocknetnswrapper.MockInterface)
+			mwg := r.WgCtrl.(*mockwgctrlwrapper.MockInterface)
+			la1, la2 := netlink.NewLinkAttrs(), netlink.NewLinkAttrs()
+			la1.Name = "wg-6000"
+			la1.MTU = 1420
+			la2.Name = "host-gateway"
+			wg0 := &netlink.Wireguard{LinkAttrs: la1}
+			veth := &netlink.Veth{LinkAttrs: la2, PeerName: "host0"}
+			host0 := &netlink.Veth{}
+			loop := &netlink.Device{LinkAttrs: netlink.LinkAttrs{Name: "lo"}}
+			device := &wgtypes.Device{Name: "wg-6000", ListenPort: 6000, PrivateKey: pk}
+			gwns := &mocknetnswrapper.MockNetNS{Name: consts.GatewayNetnsName}
+			gwnsRoutes := []netlink.Route{
+				{LinkIndex: 0, Scope: netlink.SCOPE_LINK, Dst: getIPNet("10.0.0.5/32")},
+				{LinkIndex: 0, Scope: netlink.SCOPE_UNIVERSE, Gw: net.ParseIP("10.0.0.5")},
+			}
+			gomock.InOrder(
+				mns.EXPECT().GetNS(consts.GatewayNetnsName).Return(gwns, nil),
+				mnl.EXPECT().LinkByName("wg-6000").Return(wg0, nil),
+				mnl.EXPECT().LinkByName("wg-6000").Return(wg0, nil),
+				mnl.EXPECT().AddrList(wg0, nl.FAMILY_ALL).Return([]netlink.Addr{{IPNet: getIPNetWithActualIP(consts.GatewayIP)}}, nil),
+				// MTU of the gateway overrides the default
+				mnl.EXPECT().LinkSetMTU(wg0, 1400).Return(nil),
+				mnl.EXPECT().LinkSetUp(wg0).Return(nil),
+				mwg.EXPECT().New().Return(mclient, nil),
+				mclient.EXPECT().Device("wg-6000").Return(device, nil),
+				mclient.EXPECT().Close().Return(nil),
+				mnl.EXPECT().LinkByName("host-gateway").Return(veth, nil),
+				mnl.EXPECT().LinkSetUp(veth).Return(nil),
+				mnl.EXPECT().RouteList(nil, nl.FAMILY_ALL).Return([]netlink.Route{{LinkIndex: 0, Scope: netlink.SCOPE_UNIVERSE, Dst: getIPNet("10.0.0.6/32")}}, nil),
+				mnl.EXPECT().LinkByName("host0").Return(host0, netlink.LinkNotFoundError{}),
+				mnl.EXPECT().LinkByName("host0").Return(host0, nil),
+				mnl.EXPECT().AddrList(host0, nl.FAMILY_ALL).Return([]netlink.Addr{{IPNet: getIPNet("10.0.0.6/32")}}, nil),
+				mnl.EXPECT().LinkSetUp(host0).Return(nil),
+				mnl.EXPECT().RouteList(nil, nl.FAMILY_ALL).Return(gwnsRoutes, nil),
+				mnl.EXPECT().RouteList(nil, nl.FAMILY_ALL).Return(gwnsRoutes, nil),
+				mnl.EXPECT().LinkByName("lo").Return(loop, nil),
+				mnl.EXPECT().LinkSetUp(loop).Return(nil),
+			)
+			err = r.configureGatewayNamespace(context.TODO(), gwConfig, &pk, "10.0.0.5", []string{"10.0.0.6"}, nil, nil, nil)
+			Expect(err).To(BeNil())
+
+			buf := bytes.NewBuffer(nil)
+			Expect(fipt.SaveInto(utiliptables.TableMangle, buf)).NotTo(HaveOccurred())
+			Expect(buf.String()).To(ContainSubstring("-A EGRESS-GATEWAY-MSS-6000 -o wg-6000 -p tcp -m tcp --tcp-flags SYN,RST SYN -j TCPMSS --clamp-mss-to-pmtu"))
+		})
+
+		It("should default wireguard link MTU from eth0", func() {
+			Expect(r.getWireguardMTU(gwConfig)).To(Equal(0))
+			r.defaultMTU = 1500 - consts.WireguardMTUOverhead
+			Expect(r.getWireguardMTU(gwConfig)).To(Equal(1420))
+			gwConfig.Spec.Mtu = 1380
+			Expect(r.getWireguardMTU(gwConfig)).To(Equal(1380))
+		})
+
 		It("should delete wireguard link if any setup fails", func() {
 			pk, _ := wgtypes.ParseKey(privK)
 			mnl := r.Netlink.(*mocknetlinkwrapper.MockInterface)
@@ -479,7 +598,7 @@ var _ = Describe("Daemon StaticGatewayConfiguration controller unit tests", func
 				mnl.EXPECT().LinkSetNsFd(wg0, int(gwns.Fd())).Return(fmt.Errorf("failed")),
 				mnl.EXPECT().LinkDel(wg0).Return(nil),
 			)
-			err := r.configureGatewayNamespace(context.TODO(), gwConfig, &pk, "10.0.0.5", "10.0.0.6")
+			err := r.configureGatewayNamespace(context.TODO(), gwConfig, &pk, "10.0.0.5", []string{"10.0.0.6"}, nil, nil, nil)
 			Expect(errors.Unwrap(errors.Unwrap(err))).To(Equal(fmt.Errorf("failed")))
 		})
 
@@ -513,7 +632,7 @@ var _ = Describe("Daemon StaticGatewayConfiguration controller unit tests", func
 				mnl.EXPECT().LinkSetUp(veth).Return(fmt.Errorf("failed")),
 				mnl.EXPECT().LinkDel(veth).Return(nil),
 			)
-			err := r.configureGatewayNamespace(context.TODO(), gwConfig, &pk, "10.0.0.5", "10.0.0.6")
+			err := r.configureGatewayNamespace(context.TODO(), gwConfig, &pk, "10.0.0.5", []string{"10.0.0.6"}, nil, nil, nil)
 			Expect(errors.Unwrap(errors.Unwrap(err))).To(Equal(fmt.Errorf("failed")))
 		})
 
@@ -541,6 +660,7 @@ var _ = Describe("Daemon StaticGatewayConfiguration controller unit tests", func
 				Expect(err).To(BeNil())
 				Expect(len(gwStatus.Spec.ReadyGatewayConfigurations)).To(Equal(1))
 				Expect(gwStatus.Spec.ReadyGatewayConfigurations[0]).To(Equal(gwNamespace))
+				Expect(gwStatus.Spec.Zone).To(Equal("1"))
 			})
 
 			It("should add to existing gateway status object", func() {
@@ -569,6 +689,35 @@ var _ = Describe("Daemon StaticGatewayConfiguration controller unit tests", func
 				}
 				sort.Strings(namespaces)
 				Expect(namespaces).To(Equal([]string{"wg", "wg1"}))
+				Expect(gwStatus.Spec.Zone).To(Equal("1"))
+			})
+
+			It("should report reconcile result in existing gateway status entry", func() {
+				existing := &egressgatewayv1alpha1.GatewayStatus{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      testNodeName,
+						Namespace: testPodNamespace,
+					},
+					Spec: egressgatewayv1alpha1.GatewayStatusSpec{
+						ReadyGatewayConfigurations: []egressgatewayv1alpha1.GatewayConfiguration{
+							{StaticGatewayConfiguration: "other/other", InterfaceName: "wg1"},
+							{StaticGatewayConfiguration: testNamespace + "/" + testName, InterfaceName: "wg"},
+						},
+					},
+				}
+				gwConfig := &egressgatewayv1alpha1.StaticGatewayConfiguration{ObjectMeta: metav1.ObjectMeta{Name: testName, Namespace: testNamespace}}
+				getTestReconciler(node, existing)
+				r.updateGatewayReconcileStatus(context.TODO(), gwConfig, fmt.Errorf("failed"))
+				gwStatus := &egressgatewayv1alpha1.GatewayStatus{}
+				Expect(getGatewayStatus(r.Client, gwStatus)).To(Succeed())
+				Expect(gwStatus.Spec.ReadyGatewayConfigurations[0].LastReconcileTime).To(BeNil())
+				Expect(gwStatus.Spec.ReadyGatewayConfigurations[1].LastError).To(Equal("failed"))
+				Expect(gwStatus.Spec.ReadyGatewayConfigurations[1].LastReconcileTime).NotTo(BeNil())
+
+				r.updateGatewayReconcileStatus(context.TODO(), gwConfig, nil)
+				Expect(getGatewayStatus(r.Client, gwStatus)).To(Succeed())
+				Expect(gwStatus.Spec.ReadyGatewayConfigurations[1].LastError).To(BeEmpty())
+				Expect(gwStatus.Spec.ReadyGatewayConfigurations[1].LastReconcileTime).NotTo(BeNil())
 			})
 
 			It("should remove from existing gateway status object", func() {
@@ -721,8 +870,6 @@ var _ = Describe("Daemon StaticGatewayConfiguration controller unit tests", func
 			// create existing iptables rules first
 			existingHostDump := getHostNamespaceIptablesDump("10.0.0.6", "10.0.0.7")
 			existingGWDump := getGatewayNamespaceIptablesDump(6000, 6001)
-			fipt, ok := r.IPTables.(*fakeiptables.FakeIPTables)
-			Expect(ok).To(BeTrue())
 			Expect(fipt.RestoreAll([]byte(existingHostDump), utiliptables.NoFlushTables, utiliptables.NoRestoreCounters)).NotTo(HaveOccurred())
 			Expect(fipt.RestoreAll([]byte(existingGWDump), utiliptables.NoFlushTables, utiliptables.NoRestoreCounters)).NotTo(HaveOccurred())
 
@@ -772,6 +919,37 @@ var _ = Describe("Daemon StaticGatewayConfiguration controller unit tests", func
 			Expect(r.LBProbeServer.GetGateways()).To(Equal([]string{"notDeletingUID"}))
 		})
 
+		It("should keep unconfirmed gateway restored from checkpoint", func() {
+			// vmConfig is not available yet, e.g. right after the daemon restarts
+			getTestReconciler(node, gwConfig, gwStatus)
+			r.Checkpoint = NewCheckpoint(filepath.Join(GinkgoT().TempDir(), "checkpoint.json"))
+			Expect(r.Checkpoint.setGateway(testNamespace+"/"+testName, GatewayCheckpoint{
+				UID:           testUID,
+				InterfaceName: "wg-6000",
+				ListenPort:    6000,
+				SNATIP:        "10.0.0.6",
+				ILBIPs:        []string{ilbIP},
+				Mark:          6000,
+			})).To(Succeed())
+			Expect(r.Checkpoint.Load()).To(Succeed())
+			mnl := r.Netlink.(*mocknetlinkwrapper.MockInterface)
+			mns := r.NetNS.(*mocknetnswrapper.MockInterface)
+			host0 := &netlink.Device{LinkAttrs: netlink.LinkAttrs{Name: "host0"}}
+			gwns := &mocknetnswrapper.MockNetNS{Name: consts.GatewayNetnsName}
+			gomock.InOrder(
+				mns.EXPECT().GetNS(consts.GatewayNetnsName).Return(gwns, nil),
+				mnl.EXPECT().LinkList().Return([]netlink.Link{
+					&netlink.Device{LinkAttrs: netlink.LinkAttrs{Name: "wg-6000"}},
+					&netlink.Device{LinkAttrs: netlink.LinkAttrs{Name: "host0"}},
+				}, nil),
+				mnl.EXPECT().LinkByName("host0").Return(host0, nil),
+				mnl.EXPECT().AddrList(host0, nl.FAMILY_ALL).Return([]netlink.Addr{{IPNet: getIPNet("10.0.0.6/32")}}, nil),
+			)
+			res, reconcileErr = r.Reconcile(context.TODO(), req)
+			Expect(reconcileErr).To(BeNil())
+			Expect(res).To(Equal(ctrl.Result{}))
+		})
+
 		It("should do fully cleanup when there's no active gwConfig", func() {
 			gwConfig.ObjectMeta.DeletionTimestamp = &metav1.Time{Time: time.Now()}
 			controllerutil.AddFinalizer(gwConfig, consts.SGCFinalizerName)
@@ -784,8 +962,6 @@ var _ = Describe("Daemon StaticGatewayConfiguration controller unit tests", func
 			linkToDel := netlink.Addr{IPNet: getIPNetWithActualIP(ilbIPCidr), Label: "eth0:egress"}
 
 			existingHostDump := getHostNamespaceIptablesDump()
-			fipt, ok := r.IPTables.(*fakeiptables.FakeIPTables)
-			Expect(ok).To(BeTrue())
 			Expect(fipt.RestoreAll([]byte(existingHostDump), utiliptables.NoFlushTables, utiliptables.NoRestoreCounters)).NotTo(HaveOccurred())
 
 			gomock.InOrder(
@@ -879,7 +1055,7 @@ func getGatewayNamespaceIptablesDump(marks ...int) string {
 	}
 	for _, mark := range marks {
 		res += `-A ` + `EGRESS-GATEWAY-MARK-` + strconv.Itoa(mark) + ` -i wg-` + strconv.Itoa(mark) + ` -j CONNMARK --set-mark ` + strconv.Itoa(mark) + `
--A ` + `EGRESS-GATEWAY-SNAT-` + strconv.Itoa(mark) + ` -o host0 -m connmark --mark ` + strconv.Itoa(mark) + ` -j SNAT --to-source 10.0.0.6
+-A ` + `EGRESS-GATEWAY-SNAT-` + strconv.Itoa(mark) + ` -o host0 -m connmark --mark ` + strconv.Itoa(mark) + ` -j SNAT --to-source 10.0.0.6 --random-fully
 `
 	}
 	res += `COMMIT
@@ -11,6 +11,7 @@ import (
 	"strings"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	compute "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute/v7"
 	network "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork/v9"
 	"github.com/google/uuid"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -33,11 +34,28 @@ type agentPoolVMs struct {
 }
 
 type gatewayIPConfig struct {
-	primaryIP   string
-	secondaryIP string
+	primaryIP string
+	// private IPs of the sNAT ipConfigs, empty for the ones not found
+	secondaryIPs []string
+	// public IPs of the sNAT ipConfigs, and the IDs of the ones the NIC does not expand
+	publicIPs   []string
+	publicIPIDs []string
 	subnetID    string
 }
 
+// complete returns whether the primary IP and all sNAT IPs are found.
+func (c gatewayIPConfig) complete() bool {
+	if c.primaryIP == "" || len(c.secondaryIPs) == 0 {
+		return false
+	}
+	for _, ip := range c.secondaryIPs {
+		if ip == "" {
+			return false
+		}
+	}
+	return true
+}
+
 func NewAgentPoolVM(agentPoolName string, c client.StatusClient, manager *azmanager.AzureManager) *agentPoolVMs {
 	return &agentPoolVMs{
 		agentPoolName: agentPoolName,
@@ -49,6 +67,21 @@ func NewAgentPoolVM(agentPoolName string, c client.StatusClient, manager *azmana
 func (a *agentPoolVMs) Reconcile(ctx context.Context, vmConfig *egressgatewayv1alpha1.GatewayVMConfiguration, ipPrefixID string, wantIPConfig bool) ([]string, error) {
 	backendLBPoolID := a.GetLBBackendAddressPoolID(a.GetUniqueID())
 
+	// zone of each gateway VM, keyed by lower-cased VM resource ID
+	var vmZones map[string]string
+	if vmConfig.Spec.ZoneAware {
+		vms, err := a.listPoolVMs(ctx)
+		if err != nil {
+			return nil, err
+		}
+		vmZones = make(map[string]string, len(vms))
+		for _, vm := range vms {
+			if zones := sortedZones(vm.Zones); len(zones) > 0 {
+				vmZones[strings.ToLower(to.Val(vm.ID))] = zones[0]
+			}
+		}
+	}
+
 	secondaryIPs := make([]string, 0)
 
 	nics, err := a.ListNetworkInterfaces(ctx, "" /* empty resource group, just use default */)
@@ -71,11 +104,20 @@ func (a *agentPoolVMs) Reconcile(ctx context.Context, vmConfig *egressgatewayv1a
 	}
 
 	for i := range gatewayNICs {
-		ip, err := a.reconcileNIC(ctx, vmConfig, gatewayNICs[i], ipPrefixID, to.Val(backendLBPoolID), wantIPConfig)
+		nicBackendLBPoolID := to.Val(backendLBPoolID)
+		if vmConfig.Spec.ZoneAware {
+			zone := vmZones[strings.ToLower(nicVMID(gatewayNICs[i]))]
+			if zone == "" && wantIPConfig {
+				// the regional backend pool is not created in zone aware mode
+				return nil, fmt.Errorf("zoneAware is enabled but gateway VM of nic(%s) is not deployed in any availability zone", to.Val(gatewayNICs[i].ID))
+			}
+			nicBackendLBPoolID = to.Val(a.GetLBBackendAddressPoolID(zonalResourceName(a.GetUniqueID(), zone)))
+		}
+		ips, err := a.reconcileNIC(ctx, vmConfig, gatewayNICs[i], ipPrefixID, nicBackendLBPoolID, wantIPConfig)
 		if err != nil {
 			return nil, err
 		}
-		secondaryIPs = append(secondaryIPs, ip)
+		secondaryIPs = append(secondaryIPs, ips...)
 	}
 	return secondaryIPs, nil
 }
@@ -84,19 +126,72 @@ func (a *agentPoolVMs) GetUniqueID() string {
 	return uuid.NewMD5(namespaceAgentPool, []byte(a.agentPoolName)).String()
 }
 
-func (r *agentPoolVMs) getGatewayIPConfig(nic *network.Interface, name string) gatewayIPConfig {
-	result := gatewayIPConfig{}
-	for _, ipConfig := range nic.Properties.IPConfigurations {
+func (a *agentPoolVMs) GetZones(ctx context.Context) ([]string, error) {
+	vms, err := a.listPoolVMs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var zones []*string
+	for _, vm := range vms {
+		zones = append(zones, vm.Zones...)
+	}
+	return sortedZones(zones), nil
+}
+
+// listPoolVMs returns the VMs belonging to the agent pool.
+func (a *agentPoolVMs) listPoolVMs(ctx context.Context) ([]*compute.VirtualMachine, error) {
+	vms, err := a.ListVMs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var result []*compute.VirtualMachine
+	for _, vm := range vms {
+		if vm == nil {
+			continue
+		}
+		if v, ok := vm.Tags[consts.AKSNodepoolTagKey]; ok && strings.EqualFold(to.Val(v), a.agentPoolName) {
+			result = append(result, vm)
+		}
+	}
+	return result, nil
+}
+
+func nicVMID(nic *network.Interface) string {
+	if nic.Properties == nil || nic.Properties.VirtualMachine == nil {
+		return ""
+	}
+	return to.Val(nic.Properties.VirtualMachine.ID)
+}
+
+// getGatewayIPConfig returns the primary IP and subnet of a NIC, and the private and public IPs of its sNAT
+// ipConfigs in the order of ipConfigNames.
+func getGatewayIPConfig(ipConfigs []*network.InterfaceIPConfiguration, ipConfigNames []string) gatewayIPConfig {
+	result := gatewayIPConfig{
+		secondaryIPs: make([]string, len(ipConfigNames)),
+		publicIPs:    make([]string, len(ipConfigNames)),
+		publicIPIDs:  make([]string, len(ipConfigNames)),
+	}
+	for _, ipConfig := range ipConfigs {
 		if ipConfig == nil || ipConfig.Properties == nil {
 			continue
 		}
-		if strings.EqualFold(to.Val(ipConfig.Name), name) {
-			result.secondaryIP = to.Val(ipConfig.Properties.PrivateIPAddress)
-		} else if to.Val(ipConfig.Properties.Primary) {
+		if to.Val(ipConfig.Properties.Primary) {
 			result.primaryIP = to.Val(ipConfig.Properties.PrivateIPAddress)
 			if ipConfig.Properties.Subnet != nil {
 				result.subnetID = to.Val(ipConfig.Properties.Subnet.ID)
 			}
+			continue
+		}
+		for i, name := range ipConfigNames {
+			if strings.EqualFold(to.Val(ipConfig.Name), name) {
+				result.secondaryIPs[i] = to.Val(ipConfig.Properties.PrivateIPAddress)
+				if pip := ipConfig.Properties.PublicIPAddress; pip != nil {
+					result.publicIPIDs[i] = to.Val(pip.ID)
+					if pip.Properties != nil {
+						result.publicIPs[i] = to.Val(pip.Properties.IPAddress)
+					}
+				}
+			}
 		}
 	}
 	return result
@@ -109,10 +204,9 @@ func (r *agentPoolVMs) reconcileNIC(
 	ipPrefixID string,
 	lbBackendpoolID string,
 	wantIPConfig bool,
-) (string, error) {
+) ([]string, error) {
 	logger := log.FromContext(ctx).WithValues("nic", to.Val(nic.ID), "wantIPConfig", wantIPConfig, "ipPrefixID", ipPrefixID)
 	ctx = log.IntoContext(ctx, logger)
-	ipConfigName := managedSubresourceName(vmConfig)
 
 	b, err := json.Marshal(nic)
 	if err != nil {
@@ -121,7 +215,7 @@ func (r *agentPoolVMs) reconcileNIC(
 	logger.Info("reconciling NIC", "before", string(b))
 
 	if nic.Properties == nil {
-		return "", fmt.Errorf("nic(%s) has empty properties", to.Val(nic.ID))
+		return nil, fmt.Errorf("nic(%s) has empty properties", to.Val(nic.ID))
 	}
 
 	forceUpdate := false
@@ -136,82 +230,98 @@ func (r *agentPoolVMs) reconcileNIC(
 
 	needUpdate := false
 
-	// check primary IP & secondary IP
-	ipCfg := r.getGatewayIPConfig(nic, ipConfigName)
-	if !forceUpdate && wantIPConfig && (ipCfg.primaryIP == "" || ipCfg.secondaryIP == "") {
+	// check primary IP & secondary IPs
+	ipConfigNames := managedIPConfigNames(vmConfig)
+	ipCfg := getGatewayIPConfig(nic.Properties.IPConfigurations, ipConfigNames)
+	if !forceUpdate && wantIPConfig && !ipCfg.complete() {
 		forceUpdate = true
-		logger.Info("Force update for missing primary IP and/or secondary IP", "primaryIP", ipCfg.primaryIP, "secondaryIP", ipCfg.secondaryIP)
+		logger.Info("Force update for missing primary IP and/or secondary IP", "primaryIP", ipCfg.primaryIP, "secondaryIPs", ipCfg.secondaryIPs)
 	}
 
 	if ipCfg.subnetID == "" {
-		return "", fmt.Errorf("no subnetID found for NIC(%s)", to.Val(nic.ID))
+		return nil, fmt.Errorf("no subnetID found for NIC(%s)", to.Val(nic.ID))
 	}
 
-	// expected IPconfig
-	expectedIPConfig := &network.InterfaceIPConfiguration{
-		Name: to.Ptr(ipConfigName),
-		Properties: &network.InterfaceIPConfigurationPropertiesFormat{
-			Primary:                 to.Ptr(false),
-			PrivateIPAddressVersion: to.Ptr(network.IPVersionIPv4),
-			Subnet: &network.Subnet{
-				ID: to.Ptr(ipCfg.subnetID),
-			},
-		},
-	}
-
-	if ipPrefixID != "" && wantIPConfig {
-		// todo should we check ip version?
-		expectedPublicIP := &network.PublicIPAddress{
-			Location: to.Ptr(r.Location()),
-			SKU: &network.PublicIPAddressSKU{
-				// todo these should really match the publicIPPrefix settings instead of hardcoded
-				Name: to.Ptr(network.PublicIPAddressSKUNameStandard),
-				Tier: to.Ptr(network.PublicIPAddressSKUTierRegional),
-			},
-			Properties: &network.PublicIPAddressPropertiesFormat{
-				PublicIPAddressVersion: to.Ptr(network.IPVersionIPv4),
-				PublicIPPrefix: &network.SubResource{
-					ID: to.Ptr(ipPrefixID),
+	// expected IPconfigs, one per sNAT IP
+	expectedIPConfigs := make(map[string]*network.InterfaceIPConfiguration, len(ipConfigNames))
+	for i, ipConfigName := range ipConfigNames {
+		expectedIPConfig := &network.InterfaceIPConfiguration{
+			Name: to.Ptr(ipConfigName),
+			Properties: &network.InterfaceIPConfigurationPropertiesFormat{
+				Primary:                 to.Ptr(false),
+				PrivateIPAddressVersion: to.Ptr(network.IPVersionIPv4),
+				Subnet: &network.Subnet{
+					ID: to.Ptr(ipCfg.subnetID),
 				},
-				PublicIPAllocationMethod: to.Ptr(network.IPAllocationMethodStatic),
 			},
 		}
-		pipName, err := r.buildPublicIPName(ipPrefixID, to.Val(nic.Name))
-		if err != nil {
-			return "", err
-		}
 
-		// todo how do we handle if the publicIPPrefix is out of IPs?
-		pip, err := r.CreateOrUpdatePublicIP(ctx, "", pipName, *expectedPublicIP)
-		if err != nil {
-			return "", err
+		if ipPrefixID != "" && wantIPConfig {
+			// todo should we check ip version?
+			expectedPublicIP := &network.PublicIPAddress{
+				Location: to.Ptr(r.Location()),
+				SKU: &network.PublicIPAddressSKU{
+					// todo these should really match the publicIPPrefix settings instead of hardcoded
+					Name: to.Ptr(network.PublicIPAddressSKUNameStandard),
+					Tier: to.Ptr(network.PublicIPAddressSKUTierRegional),
+				},
+				Properties: &network.PublicIPAddressPropertiesFormat{
+					PublicIPAddressVersion: to.Ptr(network.IPVersionIPv4),
+					PublicIPPrefix: &network.SubResource{
+						ID: to.Ptr(ipPrefixID),
+					},
+					PublicIPAllocationMethod: to.Ptr(network.IPAllocationMethodStatic),
+				},
+			}
+			// the public IP of the first ipConfig keeps the name it had before snatIpCount was introduced
+			pipKey := to.Val(nic.Name)
+			if shared := sharedSNATIPCount(vmConfig); i >= shared {
+				pipKey = pipKey + "-" + vmConfig.Spec.DedicatedEgressIps[i-shared]
+			} else if i > 0 {
+				pipKey = fmt.Sprintf("%s-%d", pipKey, i)
+			}
+			pipName, err := r.buildPublicIPName(ipPrefixID, pipKey)
+			if err != nil {
+				return nil, err
+			}
+
+			// todo how do we handle if the publicIPPrefix is out of IPs?
+			pip, err := r.CreateOrUpdatePublicIP(ctx, "", pipName, *expectedPublicIP)
+			if err != nil {
+				return nil, err
+			}
+			expectedIPConfig.Properties.PublicIPAddress = pip
 		}
-		expectedIPConfig.Properties.PublicIPAddress = pip
+		expectedIPConfigs[strings.ToLower(ipConfigName)] = expectedIPConfig
 	}
 
-	found := false
-	for i := 0; i < len(nic.Properties.IPConfigurations); i++ {
-		ipConfig := nic.Properties.IPConfigurations[i]
-		if ipConfig == nil || ipConfig.Properties == nil {
+	found := make(map[string]bool, len(ipConfigNames))
+	ipConfigs := make([]*network.InterfaceIPConfiguration, 0, len(nic.Properties.IPConfigurations))
+	for _, ipConfig := range nic.Properties.IPConfigurations {
+		if ipConfig == nil || ipConfig.Properties == nil || !isManagedIPConfig(vmConfig, to.Val(ipConfig.Name)) {
+			ipConfigs = append(ipConfigs, ipConfig)
 			continue
 		}
 
-		if strings.EqualFold(to.Val(ipConfig.Name), ipConfigName) {
-			if !wantIPConfig || differentNIC(ipConfig, expectedIPConfig) {
-				// remove at i
-				nic.Properties.IPConfigurations = append(nic.Properties.IPConfigurations[:i], nic.Properties.IPConfigurations[i+1:]...)
-				needUpdate = true
-				continue
-			}
-
-			found = true
-			break
+		name := strings.ToLower(to.Val(ipConfig.Name))
+		expectedIPConfig, ok := expectedIPConfigs[name]
+		if !wantIPConfig || !ok || differentNIC(ipConfig, expectedIPConfig) {
+			// drop unwanted or outdated ipConfig
+			needUpdate = true
+			continue
 		}
+		found[name] = true
+		ipConfigs = append(ipConfigs, ipConfig)
 	}
+	nic.Properties.IPConfigurations = ipConfigs
 
-	if wantIPConfig && !found {
-		nic.Properties.IPConfigurations = append(nic.Properties.IPConfigurations, expectedIPConfig)
-		needUpdate = true
+	if wantIPConfig {
+		for _, ipConfigName := range ipConfigNames {
+			if !found[strings.ToLower(ipConfigName)] {
+				nic.Properties.IPConfigurations = append(nic.Properties.IPConfigurations, expectedIPConfigs[strings.ToLower(ipConfigName)])
+				needUpdate = true
+			}
+		}
 	}
 
 	missingLB := true
@@ -250,41 +360,21 @@ func (r *agentPoolVMs) reconcileNIC(
 		nicID := to.Val(nic.ID)
 		nic, err = r.CreateOrUpdateNetworkInterface(ctx, "", to.Val(nic.Name), to.Val(nic))
 		if err != nil {
-			return "", fmt.Errorf("failed to update nic(%s): %w", nicID, err)
+			return nil, fmt.Errorf("failed to update nic(%s): %w", nicID, err)
 		}
-		ipCfg = r.getGatewayIPConfig(nic, ipConfigName)
+		ipCfg = getGatewayIPConfig(nic.Properties.IPConfigurations, ipConfigNames)
 	}
 
 	// return earlier if it's deleting event
 	if !wantIPConfig {
-		return "", nil
+		return ipCfg.secondaryIPs, nil
 	}
 
-	vmprofile := egressgatewayv1alpha1.GatewayVMProfile{
-		NodeName:    to.Val(nic.Name), // is this going to be fine?
-		PrimaryIP:   ipCfg.primaryIP,
-		SecondaryIP: ipCfg.secondaryIP,
-	}
-	if vmConfig.Status == nil {
-		vmConfig.Status = &egressgatewayv1alpha1.GatewayVMConfigurationStatus{}
-	}
-	for i, profile := range vmConfig.Status.GatewayVMProfiles {
-		if profile.NodeName == vmprofile.NodeName {
-			if profile.PrimaryIP != ipCfg.primaryIP || profile.SecondaryIP != ipCfg.secondaryIP {
-				vmConfig.Status.GatewayVMProfiles[i].PrimaryIP = ipCfg.primaryIP
-				vmConfig.Status.GatewayVMProfiles[i].SecondaryIP = ipCfg.secondaryIP
-				logger.Info("GatewayVMConfiguration status updated", "primaryIP", ipCfg.primaryIP, "secondaryIP", ipCfg.secondaryIP)
-				return ipCfg.secondaryIP, nil
-			}
-			logger.Info("GatewayVMConfiguration status not changed", "primaryIP", ipCfg.primaryIP, "secondaryIP", ipCfg.secondaryIP)
-			return ipCfg.secondaryIP, nil
-		}
+	if err := resolveDedicatedPublicIPs(ctx, r.AzureManager, vmConfig, &ipCfg); err != nil {
+		return nil, err
 	}
-
-	logger.Info("GatewayVMConfiguration status updated for new nodes", "nodeName", vmprofile.NodeName, "primaryIP", ipCfg.primaryIP, "secondaryIP", ipCfg.secondaryIP)
-	vmConfig.Status.GatewayVMProfiles = append(vmConfig.Status.GatewayVMProfiles, vmprofile)
-
-	return ipCfg.secondaryIP, nil
+	setGatewayVMProfile(ctx, vmConfig, to.Val(nic.Name), ipCfg)
+	return ipCfg.secondaryIPs, nil
 }
 
 func (a *agentPoolVMs) buildPublicIPName(id string, val string) (string, error) {
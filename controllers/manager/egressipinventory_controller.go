@@ -0,0 +1,348 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package manager
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	egressgatewayv1alpha1 "github.com/Azure/kube-egress-gateway/api/v1alpha1"
+	"github.com/Azure/kube-egress-gateway/pkg/consts"
+	"github.com/Azure/kube-egress-gateway/pkg/metrics"
+)
+
+const (
+	// defaultInventoryHistoryLimit is the number of change records kept if the inventory does not set historyLimit
+	defaultInventoryHistoryLimit = 100
+
+	// webhookSecretKey is the key of the HMAC key in the webhook secret
+	webhookSecretKey = "key"
+
+	// WebhookTimestampHeader is the header carrying the unix time a change notification was signed at
+	WebhookTimestampHeader = "X-Egress-Gateway-Timestamp"
+
+	// WebhookSignatureHeader is the header carrying the "sha256=<hex>" HMAC-SHA256 of "<timestamp>.<body>"
+	WebhookSignatureHeader = "X-Egress-Gateway-Signature"
+
+	// webhookTimeout is the timeout of a single change notification
+	webhookTimeout = 10 * time.Second
+)
+
+var _ reconcile.Reconciler = &EgressIPInventoryReconciler{}
+
+// EgressIPInventoryReconciler aggregates the egress addresses of all GatewayVMConfigurations into EgressIPInventory
+// objects and notifies their webhooks of changes
+type EgressIPInventoryReconciler struct {
+	client.Client
+	Recorder record.EventRecorder
+	// HTTPClient posts the change notifications, a client with webhookTimeout is used if nil
+	HTTPClient *http.Client
+}
+
+// EgressIPChangeNotification is the body of the change notifications posted to the webhooks of an inventory.
+type EgressIPChangeNotification struct {
+	// Name of the EgressIPInventory
+	Inventory string `json:"inventory"`
+	// Changes about to be recorded
+	Changes []egressgatewayv1alpha1.EgressIPChange `json:"changes"`
+	// Egress addresses of all gateways once the changes are recorded
+	Addresses []string `json:"addresses"`
+}
+
+//+kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
+//+kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch
+//+kubebuilder:rbac:groups=egressgateway.kubernetes.azure.com,resources=egressipinventories,verbs=get;list;watch
+//+kubebuilder:rbac:groups=egressgateway.kubernetes.azure.com,resources=egressipinventories/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=egressgateway.kubernetes.azure.com,resources=gatewayvmconfigurations,verbs=get;list;watch
+
+// Reconcile is part of the main kubernetes reconciliation loop which aims to
+// move the current state of the cluster closer to the desired state.
+func (r *EgressIPInventoryReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	inventory := &egressgatewayv1alpha1.EgressIPInventory{}
+	if err := r.Get(ctx, req.NamespacedName, inventory); err != nil {
+		if apierrors.IsNotFound(err) {
+			// Object not found, return.
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "unable to fetch EgressIPInventory instance")
+		return ctrl.Result{}, err
+	}
+
+	if err := r.reconcile(ctx, inventory); err != nil {
+		r.Recorder.Event(inventory, corev1.EventTypeWarning, "ReconcileEgressIPInventoryError", err.Error())
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *EgressIPInventoryReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&egressgatewayv1alpha1.EgressIPInventory{}).
+		// every inventory lists the addresses of all gateways
+		Watches(&egressgatewayv1alpha1.GatewayVMConfiguration{}, handler.EnqueueRequestsFromMapFunc(r.enqueueAllInventories)).
+		Complete(r)
+}
+
+func (r *EgressIPInventoryReconciler) enqueueAllInventories(ctx context.Context, _ client.Object) []reconcile.Request {
+	inventories := &egressgatewayv1alpha1.EgressIPInventoryList{}
+	if err := r.List(ctx, inventories); err != nil {
+		log.FromContext(ctx).Error(err, "failed to list EgressIPInventories")
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, inventory := range inventories.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: inventory.Name}})
+	}
+	return requests
+}
+
+func (r *EgressIPInventoryReconciler) reconcile(ctx context.Context, inventory *egressgatewayv1alpha1.EgressIPInventory) error {
+	log := log.FromContext(ctx)
+	log.Info(fmt.Sprintf("Reconciling EgressIPInventory %s", inventory.Name))
+
+	mc := metrics.NewMetricsContext(
+		os.Getenv(consts.PodNamespaceEnvKey),
+		"reconcile_egress_ip_inventory",
+		"n/a",
+		"n/a",
+		strings.ToLower(inventory.Name),
+	)
+	succeeded := false
+	defer func() { mc.ObserveControllerReconcileMetrics(succeeded) }()
+
+	gateways, err := r.listGatewayEgressAddresses(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := metav1.Now()
+	changes := diffGatewayEgressAddresses(inventory.Status.Gateways, gateways, now)
+	if len(changes) == 0 && equality.Semantic.DeepEqual(inventory.Status.Gateways, gateways) {
+		log.Info("EgressIPInventory is up to date")
+		succeeded = true
+		return nil
+	}
+
+	addresses := unionEgressAddresses(gateways)
+	if len(changes) > 0 {
+		// notify before recording, so that partners allowlist an address before the inventory lists it, and a
+		// failed notification is retried on the next reconcile
+		notification := &EgressIPChangeNotification{Inventory: inventory.Name, Changes: changes, Addresses: addresses}
+		if err := r.notifyWebhooks(ctx, inventory, notification); err != nil {
+			return err
+		}
+	}
+
+	limit := int(inventory.Spec.HistoryLimit)
+	if limit <= 0 {
+		limit = defaultInventoryHistoryLimit
+	}
+	history := append(changes, inventory.Status.History...)
+	if len(history) > limit {
+		history = history[:limit]
+	}
+	inventory.Status = egressgatewayv1alpha1.EgressIPInventoryStatus{
+		Gateways:       gateways,
+		Addresses:      addresses,
+		History:        history,
+		LastUpdateTime: &now,
+	}
+	log.Info(fmt.Sprintf("Updating EgressIPInventory %s", inventory.Name))
+	if err := r.Status().Update(ctx, inventory); err != nil {
+		log.Error(err, "failed to update EgressIPInventory")
+		return err
+	}
+
+	log.Info("EgressIPInventory reconciled")
+	succeeded = true
+	return nil
+}
+
+// listGatewayEgressAddresses returns the egress addresses of every gateway that has been provisioned, sorted by
+// gateway.
+func (r *EgressIPInventoryReconciler) listGatewayEgressAddresses(ctx context.Context) ([]egressgatewayv1alpha1.GatewayEgressAddresses, error) {
+	vmConfigs := &egressgatewayv1alpha1.GatewayVMConfigurationList{}
+	if err := r.List(ctx, vmConfigs); err != nil {
+		return nil, fmt.Errorf("failed to list GatewayVMConfigurations: %w", err)
+	}
+	var gateways []egressgatewayv1alpha1.GatewayEgressAddresses
+	for _, vmConfig := range vmConfigs.Items {
+		if vmConfig.Status == nil || vmConfig.Status.EgressIpPrefix == "" {
+			continue
+		}
+		var addresses []string
+		for _, address := range strings.Split(vmConfig.Status.EgressIpPrefix, ",") {
+			if address = strings.TrimSpace(address); address != "" {
+				addresses = append(addresses, address)
+			}
+		}
+		sort.Strings(addresses)
+		gateways = append(gateways, egressgatewayv1alpha1.GatewayEgressAddresses{
+			// GatewayVMConfiguration shares the namespace and name of its StaticGatewayConfiguration
+			Gateway:   vmConfig.Namespace + "/" + vmConfig.Name,
+			Addresses: addresses,
+			Private:   !vmConfig.Spec.ProvisionPublicIps,
+		})
+	}
+	sort.Slice(gateways, func(i, j int) bool { return gateways[i].Gateway < gateways[j].Gateway })
+	return gateways, nil
+}
+
+// diffGatewayEgressAddresses returns the addresses added to and removed from each gateway, sorted by gateway.
+func diffGatewayEgressAddresses(existing, current []egressgatewayv1alpha1.GatewayEgressAddresses, now metav1.Time) []egressgatewayv1alpha1.EgressIPChange {
+	before := make(map[string][]string)
+	after := make(map[string][]string)
+	var names []string
+	for _, gateway := range existing {
+		before[gateway.Gateway] = gateway.Addresses
+		names = append(names, gateway.Gateway)
+	}
+	for _, gateway := range current {
+		after[gateway.Gateway] = gateway.Addresses
+		if _, ok := before[gateway.Gateway]; !ok {
+			names = append(names, gateway.Gateway)
+		}
+	}
+	sort.Strings(names)
+
+	var changes []egressgatewayv1alpha1.EgressIPChange
+	for _, name := range names {
+		added, removed := diffStrings(before[name], after[name])
+		if len(added) > 0 || len(removed) > 0 {
+			changes = append(changes, egressgatewayv1alpha1.EgressIPChange{Time: now, Gateway: name, Added: added, Removed: removed})
+		}
+	}
+	return changes
+}
+
+func diffStrings(before, after []string) (added, removed []string) {
+	beforeSet := make(map[string]bool, len(before))
+	for _, s := range before {
+		beforeSet[s] = true
+	}
+	afterSet := make(map[string]bool, len(after))
+	for _, s := range after {
+		afterSet[s] = true
+		if !beforeSet[s] {
+			added = append(added, s)
+		}
+	}
+	for _, s := range before {
+		if !afterSet[s] {
+			removed = append(removed, s)
+		}
+	}
+	return added, removed
+}
+
+func unionEgressAddresses(gateways []egressgatewayv1alpha1.GatewayEgressAddresses) []string {
+	seen := make(map[string]bool)
+	var addresses []string
+	for _, gateway := range gateways {
+		for _, address := range gateway.Addresses {
+			if !seen[address] {
+				seen[address] = true
+				addresses = append(addresses, address)
+			}
+		}
+	}
+	sort.Strings(addresses)
+	return addresses
+}
+
+// notifyWebhooks posts notification to every webhook of inventory, signed with the key of the webhook secret.
+func (r *EgressIPInventoryReconciler) notifyWebhooks(
+	ctx context.Context,
+	inventory *egressgatewayv1alpha1.EgressIPInventory,
+	notification *EgressIPChangeNotification,
+) error {
+	if len(inventory.Spec.Webhooks) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return fmt.Errorf("failed to encode change notification: %w", err)
+	}
+	var errs []error
+	for _, webhook := range inventory.Spec.Webhooks {
+		if err := r.notifyWebhook(ctx, webhook, body); err != nil {
+			errs = append(errs, fmt.Errorf("failed to notify webhook %s: %w", webhook.URL, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (r *EgressIPInventoryReconciler) notifyWebhook(ctx context.Context, webhook egressgatewayv1alpha1.EgressIPInventoryWebhook, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if webhook.SecretRef != nil {
+		secret := &corev1.Secret{}
+		if err := r.Get(ctx, types.NamespacedName{Namespace: webhook.SecretRef.Namespace, Name: webhook.SecretRef.Name}, secret); err != nil {
+			return fmt.Errorf("failed to get webhook secret: %w", err)
+		}
+		key, ok := secret.Data[webhookSecretKey]
+		if !ok || len(key) == 0 {
+			return fmt.Errorf("webhook secret %s/%s does not have key %q", secret.Namespace, secret.Name, webhookSecretKey)
+		}
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(WebhookTimestampHeader, timestamp)
+		req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(key, timestamp, body))
+	}
+
+	httpClient := r.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: webhookTimeout}
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+// SignWebhookPayload returns the value of WebhookSignatureHeader for body signed at timestamp, so that receivers
+// can verify notifications and reject replays.
+func SignWebhookPayload(key []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
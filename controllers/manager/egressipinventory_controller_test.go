@@ -0,0 +1,158 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	egressgatewayv1alpha1 "github.com/Azure/kube-egress-gateway/api/v1alpha1"
+)
+
+var _ = Describe("EgressIPInventory controller unit tests", func() {
+	var (
+		r             *EgressIPInventoryReconciler
+		cl            client.Client
+		recorder      *record.FakeRecorder
+		server        *httptest.Server
+		notifications []EgressIPChangeNotification
+		signatures    []string
+		webhookStatus int
+		req           = reconcile.Request{NamespacedName: types.NamespacedName{Name: "inventory"}}
+		inventory     *egressgatewayv1alpha1.EgressIPInventory
+	)
+
+	vmConfig := func(name, egressIPPrefix string, public bool) *egressgatewayv1alpha1.GatewayVMConfiguration {
+		return &egressgatewayv1alpha1.GatewayVMConfiguration{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testNamespace},
+			Spec:       egressgatewayv1alpha1.GatewayVMConfigurationSpec{ProvisionPublicIps: public},
+			Status:     &egressgatewayv1alpha1.GatewayVMConfigurationStatus{EgressIpPrefix: egressIPPrefix},
+		}
+	}
+
+	getInventory := func() *egressgatewayv1alpha1.EgressIPInventory {
+		found := &egressgatewayv1alpha1.EgressIPInventory{}
+		Expect(cl.Get(context.TODO(), req.NamespacedName, found)).To(Succeed())
+		return found
+	}
+
+	BeforeEach(func() {
+		notifications, signatures, webhookStatus = nil, nil, http.StatusOK
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, err := io.ReadAll(r.Body)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(r.Header.Get(WebhookSignatureHeader)).To(Equal(SignWebhookPayload([]byte("secret"), r.Header.Get(WebhookTimestampHeader), body)))
+			signatures = append(signatures, r.Header.Get(WebhookSignatureHeader))
+			var notification EgressIPChangeNotification
+			Expect(json.Unmarshal(body, &notification)).To(Succeed())
+			notifications = append(notifications, notification)
+			w.WriteHeader(webhookStatus)
+		}))
+		DeferCleanup(server.Close)
+		inventory = &egressgatewayv1alpha1.EgressIPInventory{
+			ObjectMeta: metav1.ObjectMeta{Name: "inventory"},
+			Spec: egressgatewayv1alpha1.EgressIPInventorySpec{
+				Webhooks: []egressgatewayv1alpha1.EgressIPInventoryWebhook{{
+					URL:       server.URL,
+					SecretRef: &egressgatewayv1alpha1.EgressIPInventorySecretReference{Namespace: testNamespace, Name: "webhook"},
+				}},
+				HistoryLimit: 2,
+			},
+		}
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "webhook", Namespace: testNamespace},
+			Data:       map[string][]byte{webhookSecretKey: []byte("secret")},
+		}
+		recorder = record.NewFakeRecorder(10)
+		cl = fake.NewClientBuilder().WithScheme(scheme.Scheme).
+			WithStatusSubresource(&egressgatewayv1alpha1.EgressIPInventory{}).
+			WithObjects(inventory, secret,
+				vmConfig("public", "1.2.3.4/31", true),
+				vmConfig("private", "10.0.0.5,10.0.0.4", false),
+				vmConfig("pending", "", true)).
+			Build()
+		r = &EgressIPInventoryReconciler{Client: cl, Recorder: recorder}
+	})
+
+	It("should list the egress addresses of all gateways after notifying webhooks", func() {
+		_, err := r.Reconcile(context.TODO(), req)
+		Expect(err).NotTo(HaveOccurred())
+
+		found := getInventory()
+		Expect(found.Status.Gateways).To(Equal([]egressgatewayv1alpha1.GatewayEgressAddresses{
+			{Gateway: testNamespace + "/private", Addresses: []string{"10.0.0.4", "10.0.0.5"}, Private: true},
+			{Gateway: testNamespace + "/public", Addresses: []string{"1.2.3.4/31"}},
+		}))
+		Expect(found.Status.Addresses).To(Equal([]string{"1.2.3.4/31", "10.0.0.4", "10.0.0.5"}))
+		Expect(found.Status.History).To(HaveLen(2))
+		Expect(found.Status.LastUpdateTime).NotTo(BeNil())
+
+		Expect(notifications).To(HaveLen(1))
+		Expect(notifications[0].Inventory).To(Equal("inventory"))
+		Expect(notifications[0].Addresses).To(Equal(found.Status.Addresses))
+		Expect(notifications[0].Changes).To(HaveLen(2))
+		Expect(notifications[0].Changes[1].Added).To(Equal([]string{"1.2.3.4/31"}))
+
+		By("not notifying again if nothing changed")
+		_, err = r.Reconcile(context.TODO(), req)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(notifications).To(HaveLen(1))
+	})
+
+	It("should record address changes and trim history", func() {
+		_, err := r.Reconcile(context.TODO(), req)
+		Expect(err).NotTo(HaveOccurred())
+
+		private := &egressgatewayv1alpha1.GatewayVMConfiguration{}
+		Expect(cl.Get(context.TODO(), types.NamespacedName{Name: "private", Namespace: testNamespace}, private)).To(Succeed())
+		private.Status.EgressIpPrefix = "10.0.0.4,10.0.0.6"
+		Expect(cl.Update(context.TODO(), private)).To(Succeed())
+
+		_, err = r.Reconcile(context.TODO(), req)
+		Expect(err).NotTo(HaveOccurred())
+
+		found := getInventory()
+		Expect(found.Status.Addresses).To(Equal([]string{"1.2.3.4/31", "10.0.0.4", "10.0.0.6"}))
+		Expect(found.Status.History).To(HaveLen(2))
+		Expect(found.Status.History[0].Gateway).To(Equal(testNamespace + "/private"))
+		Expect(found.Status.History[0].Added).To(Equal([]string{"10.0.0.6"}))
+		Expect(found.Status.History[0].Removed).To(Equal([]string{"10.0.0.5"}))
+		Expect(notifications).To(HaveLen(2))
+	})
+
+	It("should not record changes the webhooks failed to receive", func() {
+		webhookStatus = http.StatusServiceUnavailable
+		_, err := r.Reconcile(context.TODO(), req)
+		Expect(err).To(HaveOccurred())
+		Expect(getInventory().Status.Addresses).To(BeEmpty())
+		Expect(recorder.Events).To(Receive(ContainSubstring("ReconcileEgressIPInventoryError")))
+
+		webhookStatus = http.StatusOK
+		_, err = r.Reconcile(context.TODO(), req)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(getInventory().Status.Addresses).To(HaveLen(3))
+		Expect(notifications).To(HaveLen(2))
+		Expect(notifications[1]).To(Equal(notifications[0]))
+	})
+
+	It("should fail if the webhook secret is missing", func() {
+		Expect(cl.Delete(context.TODO(), &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "webhook", Namespace: testNamespace}})).To(Succeed())
+		_, err := r.Reconcile(context.TODO(), req)
+		Expect(err).To(MatchError(ContainSubstring("failed to get webhook secret")))
+		Expect(notifications).To(BeEmpty())
+	})
+})
@@ -0,0 +1,367 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	egressgatewayv1alpha1 "github.com/Azure/kube-egress-gateway/api/v1alpha1"
+	"github.com/Azure/kube-egress-gateway/pkg/azmanager"
+	"github.com/Azure/kube-egress-gateway/pkg/consts"
+	"github.com/Azure/kube-egress-gateway/pkg/metrics"
+	"github.com/Azure/kube-egress-gateway/pkg/utils/to"
+)
+
+var _ reconcile.Reconciler = &EgressIPPoolReconciler{}
+
+// EgressIPPoolReconciler reconciles the public IP prefixes of an EgressIPPool object
+type EgressIPPoolReconciler struct {
+	client.Client
+	*azmanager.AzureManager
+	Recorder record.EventRecorder
+}
+
+//+kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
+//+kubebuilder:rbac:groups=egressgateway.kubernetes.azure.com,resources=egressippools,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups=egressgateway.kubernetes.azure.com,resources=egressippools/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=egressgateway.kubernetes.azure.com,resources=egressippools/finalizers,verbs=update
+
+// Reconcile is part of the main kubernetes reconciliation loop which aims to
+// move the current state of the cluster closer to the desired state.
+func (r *EgressIPPoolReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	pool := &egressgatewayv1alpha1.EgressIPPool{}
+	if err := r.Get(ctx, req.NamespacedName, pool); err != nil {
+		if apierrors.IsNotFound(err) {
+			// Object not found, return.
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "unable to fetch EgressIPPool instance")
+		return ctrl.Result{}, err
+	}
+
+	if !pool.ObjectMeta.DeletionTimestamp.IsZero() {
+		// Clean up EgressIPPool
+		err := r.ensureDeleted(ctx, pool)
+		if err != nil {
+			r.Recorder.Event(pool, corev1.EventTypeWarning, "EnsureDeleteEgressIPPoolError", err.Error())
+		}
+		return ctrl.Result{}, err
+	}
+
+	err := r.reconcile(ctx, pool)
+	if err != nil {
+		r.Recorder.Event(pool, corev1.EventTypeWarning, "ReconcileEgressIPPoolError", err.Error())
+	} else {
+		r.Recorder.Event(pool, corev1.EventTypeNormal, "ReconcileEgressIPPoolSuccess", "EgressIPPool reconciled")
+	}
+	return ctrl.Result{}, err
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *EgressIPPoolReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&egressgatewayv1alpha1.EgressIPPool{}).
+		Complete(r)
+}
+
+func (r *EgressIPPoolReconciler) reconcile(ctx context.Context, pool *egressgatewayv1alpha1.EgressIPPool) error {
+	log := log.FromContext(ctx)
+	log.Info(fmt.Sprintf("Reconciling EgressIPPool %s", pool.Name))
+
+	mc := metrics.NewMetricsContext(
+		os.Getenv(consts.PodNamespaceEnvKey),
+		"reconcile_egress_ip_pool",
+		r.SubscriptionID(),
+		r.ResourceGroup,
+		strings.ToLower(pool.Name),
+	)
+	succeeded := false
+	defer func() { mc.ObserveControllerReconcileMetrics(succeeded) }()
+
+	if !controllerutil.ContainsFinalizer(pool, consts.EgressIPPoolFinalizerName) {
+		log.Info("Adding finalizer")
+		controllerutil.AddFinalizer(pool, consts.EgressIPPoolFinalizerName)
+		if err := r.Update(ctx, pool); err != nil {
+			log.Error(err, "failed to add finalizer")
+			return err
+		}
+	}
+
+	existing := make(map[string]egressgatewayv1alpha1.EgressIPPoolPrefixStatus)
+	for _, prefix := range pool.Status.Prefixes {
+		existing[prefix.Name] = prefix
+	}
+
+	var errs []error
+	var prefixes []egressgatewayv1alpha1.EgressIPPoolPrefixStatus
+	inSpec := make(map[string]bool)
+	for _, prefix := range pool.Spec.Prefixes {
+		inSpec[prefix.Name] = true
+		previous, found := existing[prefix.Name]
+		status, err := r.ensurePoolPrefix(ctx, pool, prefix)
+		if err != nil {
+			errs = append(errs, err)
+			if found {
+				prefixes = append(prefixes, previous)
+			}
+			continue
+		}
+		if previous.ClaimedBy != "" && previous.PublicIpPrefixId != status.PublicIpPrefixId {
+			// swapping the prefix would silently change the egress IPs of the claiming gateway
+			errs = append(errs, fmt.Errorf("prefix %s is claimed by %s and cannot be replaced", prefix.Name, previous.ClaimedBy))
+			prefixes = append(prefixes, previous)
+			continue
+		}
+		status.ClaimedBy, status.ReclaimPolicy = previous.ClaimedBy, previous.ReclaimPolicy
+		prefixes = append(prefixes, *status)
+	}
+
+	for _, prefix := range pool.Status.Prefixes {
+		if inSpec[prefix.Name] {
+			continue
+		}
+		if prefix.ClaimedBy != "" {
+			errs = append(errs, fmt.Errorf("prefix %s is removed but still claimed by %s", prefix.Name, prefix.ClaimedBy))
+			prefixes = append(prefixes, prefix)
+			continue
+		}
+		if prefix.Managed {
+			if err := deleteManagedPublicIPPrefix(ctx, r.AzureManager, poolPrefixName(pool, prefix.Name)); err != nil {
+				errs = append(errs, err)
+				prefixes = append(prefixes, prefix)
+			}
+		}
+	}
+
+	if !equality.Semantic.DeepEqual(pool.Status.Prefixes, prefixes) {
+		pool.Status.Prefixes = prefixes
+		log.Info(fmt.Sprintf("Updating EgressIPPool %s", pool.Name))
+		if err := r.Status().Update(ctx, pool); err != nil {
+			log.Error(err, "failed to update EgressIPPool status")
+			return err
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	log.Info("EgressIPPool reconciled")
+	succeeded = true
+	return nil
+}
+
+// ensurePoolPrefix returns the status of the public ip prefix of the pool, creating it if it is managed.
+func (r *EgressIPPoolReconciler) ensurePoolPrefix(
+	ctx context.Context,
+	pool *egressgatewayv1alpha1.EgressIPPool,
+	prefix egressgatewayv1alpha1.EgressIPPoolPrefix,
+) (*egressgatewayv1alpha1.EgressIPPoolPrefixStatus, error) {
+	if prefix.PublicIpPrefixId != "" {
+		ipPrefix, err := getBYOPublicIPPrefix(ctx, r.AzureManager, prefix.PublicIpPrefixId)
+		if err != nil {
+			return nil, err
+		}
+		return &egressgatewayv1alpha1.EgressIPPoolPrefixStatus{
+			Name:             prefix.Name,
+			PublicIpPrefixId: to.Val(ipPrefix.ID),
+			IpPrefix:         to.Val(ipPrefix.Properties.IPPrefix),
+			PrefixLength:     to.Val(ipPrefix.Properties.PrefixLength),
+		}, nil
+	}
+
+	publicIpPrefixName := poolPrefixName(pool, prefix.Name)
+	ipPrefix, err := r.GetPublicIPPrefix(ctx, "", publicIpPrefixName)
+	if err != nil {
+		if !isErrorNotFound(err) {
+			return nil, fmt.Errorf("failed to get managed public ip prefix(%s): %w", publicIpPrefixName, err)
+		}
+		if prefix.PrefixLength == 0 {
+			return nil, fmt.Errorf("prefix %s requires prefixLength or publicIpPrefixId", prefix.Name)
+		}
+		log.FromContext(ctx).Info("Creating new managed public ip prefix", "public ip prefix name", publicIpPrefixName)
+		if ipPrefix, err = createManagedPublicIPPrefix(ctx, r.AzureManager, publicIpPrefixName, prefix.PrefixLength); err != nil {
+			return nil, err
+		}
+	}
+	if ipPrefix.Properties == nil {
+		return nil, fmt.Errorf("managed public ip prefix(%s) has empty properties", publicIpPrefixName)
+	}
+	return &egressgatewayv1alpha1.EgressIPPoolPrefixStatus{
+		Name:             prefix.Name,
+		PublicIpPrefixId: to.Val(ipPrefix.ID),
+		IpPrefix:         to.Val(ipPrefix.Properties.IPPrefix),
+		PrefixLength:     to.Val(ipPrefix.Properties.PrefixLength),
+		Managed:          true,
+	}, nil
+}
+
+func (r *EgressIPPoolReconciler) ensureDeleted(ctx context.Context, pool *egressgatewayv1alpha1.EgressIPPool) error {
+	log := log.FromContext(ctx)
+	log.Info(fmt.Sprintf("Reconciling EgressIPPool deletion %s", pool.Name))
+
+	if !controllerutil.ContainsFinalizer(pool, consts.EgressIPPoolFinalizerName) {
+		log.Info("EgressIPPool does not have finalizer, no additional cleanup needed")
+		return nil
+	}
+
+	mc := metrics.NewMetricsContext(
+		os.Getenv(consts.PodNamespaceEnvKey),
+		"delete_egress_ip_pool",
+		r.SubscriptionID(),
+		r.ResourceGroup,
+		strings.ToLower(pool.Name),
+	)
+	succeeded := false
+	defer func() { mc.ObserveControllerReconcileMetrics(succeeded) }()
+
+	// keep the prefixes as long as a gateway egresses from them or reserves them
+	var claims []string
+	for _, prefix := range pool.Status.Prefixes {
+		if prefix.ClaimedBy != "" {
+			claims = append(claims, fmt.Sprintf("%s(%s)", prefix.Name, prefix.ClaimedBy))
+		}
+	}
+	if len(claims) > 0 {
+		return fmt.Errorf("EgressIPPool %s still has claimed prefixes: %s", pool.Name, strings.Join(claims, ", "))
+	}
+
+	managed := make(map[string]bool)
+	for _, prefix := range pool.Spec.Prefixes {
+		if prefix.PublicIpPrefixId == "" {
+			managed[prefix.Name] = true
+		}
+	}
+	for _, prefix := range pool.Status.Prefixes {
+		if prefix.Managed {
+			managed[prefix.Name] = true
+		}
+	}
+	for name := range managed {
+		if err := deleteManagedPublicIPPrefix(ctx, r.AzureManager, poolPrefixName(pool, name)); err != nil {
+			log.Error(err, "failed to delete managed public ip prefix")
+			return err
+		}
+	}
+
+	log.Info("Removing finalizer")
+	controllerutil.RemoveFinalizer(pool, consts.EgressIPPoolFinalizerName)
+	if err := r.Update(ctx, pool); err != nil {
+		log.Error(err, "failed to remove finalizer")
+		return err
+	}
+
+	log.Info("EgressIPPool deletion reconciled")
+	succeeded = true
+	return nil
+}
+
+// poolPrefixName returns the name of the managed public ip prefix of the pool.
+func poolPrefixName(pool *egressgatewayv1alpha1.EgressIPPool, prefixName string) string {
+	return consts.ManagedPoolResourcePrefix + string(pool.GetUID()) + "-" + prefixName
+}
+
+// gatewayKey returns the StaticGatewayConfiguration a GatewayVMConfiguration belongs to in <namespace>/<name> pattern.
+func gatewayKey(vmConfig *egressgatewayv1alpha1.GatewayVMConfiguration) string {
+	// GatewayVMConfiguration shares the namespace and name of its StaticGatewayConfiguration
+	return vmConfig.Namespace + "/" + vmConfig.Name
+}
+
+// claimPoolPrefix returns the prefix of the pool claimed by gateway, claiming a free one of ipPrefixLength if the
+// gateway has none yet.
+func claimPoolPrefix(
+	ctx context.Context,
+	c client.Client,
+	claim *egressgatewayv1alpha1.EgressIPPoolClaim,
+	gateway string,
+	ipPrefixLength int32,
+) (*egressgatewayv1alpha1.EgressIPPoolPrefixStatus, error) {
+	pool := &egressgatewayv1alpha1.EgressIPPool{}
+	if err := c.Get(ctx, client.ObjectKey{Name: claim.PoolName}, pool); err != nil {
+		return nil, fmt.Errorf("failed to get EgressIPPool(%s): %w", claim.PoolName, err)
+	}
+	if !pool.DeletionTimestamp.IsZero() {
+		return nil, fmt.Errorf("EgressIPPool(%s) is being deleted", claim.PoolName)
+	}
+	reclaimPolicy := claim.ReclaimPolicy
+	if reclaimPolicy == "" {
+		reclaimPolicy = egressgatewayv1alpha1.EgressIPReclaimRetain
+	}
+
+	index := -1
+	for i, prefix := range pool.Status.Prefixes {
+		if prefix.ClaimedBy == gateway {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		for i, prefix := range pool.Status.Prefixes {
+			if prefix.ClaimedBy == "" && prefix.IpPrefix != "" && prefix.PrefixLength == ipPrefixLength {
+				index = i
+				break
+			}
+		}
+	}
+	if index < 0 {
+		return nil, fmt.Errorf("EgressIPPool(%s) has no free public ip prefix of length %d", claim.PoolName, ipPrefixLength)
+	}
+
+	prefix := &pool.Status.Prefixes[index]
+	if prefix.PrefixLength != ipPrefixLength {
+		return nil, fmt.Errorf("claimed public ip prefix(%s) has invalid length(%d), required(%d)", prefix.Name, prefix.PrefixLength, ipPrefixLength)
+	}
+	if prefix.ClaimedBy != gateway || prefix.ReclaimPolicy != reclaimPolicy {
+		prefix.ClaimedBy, prefix.ReclaimPolicy = gateway, reclaimPolicy
+		log.FromContext(ctx).Info("Claiming public ip prefix", "pool", pool.Name, "prefix", prefix.Name)
+		if err := c.Status().Update(ctx, pool); err != nil {
+			return nil, fmt.Errorf("failed to claim public ip prefix(%s) of EgressIPPool(%s): %w", prefix.Name, pool.Name, err)
+		}
+	}
+	return prefix, nil
+}
+
+// releasePoolClaims returns the prefixes claimed by gateway with the Delete reclaim policy to their pools, except for
+// the prefix claimed from keepPool. Prefixes claimed with the Retain reclaim policy stay reserved for the gateway.
+func releasePoolClaims(ctx context.Context, c client.Client, gateway, keepPool string) error {
+	pools := &egressgatewayv1alpha1.EgressIPPoolList{}
+	if err := c.List(ctx, pools); err != nil {
+		return fmt.Errorf("failed to list EgressIPPools: %w", err)
+	}
+	for i := range pools.Items {
+		pool := &pools.Items[i]
+		if pool.Name == keepPool {
+			continue
+		}
+		released := false
+		for j, prefix := range pool.Status.Prefixes {
+			if prefix.ClaimedBy == gateway && prefix.ReclaimPolicy == egressgatewayv1alpha1.EgressIPReclaimDelete {
+				log.FromContext(ctx).Info("Releasing public ip prefix", "pool", pool.Name, "prefix", prefix.Name)
+				pool.Status.Prefixes[j].ClaimedBy, pool.Status.Prefixes[j].ReclaimPolicy = "", ""
+				released = true
+			}
+		}
+		if released {
+			if err := c.Status().Update(ctx, pool); err != nil {
+				return fmt.Errorf("failed to release public ip prefix of EgressIPPool(%s): %w", pool.Name, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+package manager
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	network "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork/v9"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.uber.org/mock/gomock"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/cloud-provider-azure/pkg/azclient/publicipprefixclient/mock_publicipprefixclient"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	egressgatewayv1alpha1 "github.com/Azure/kube-egress-gateway/api/v1alpha1"
+	"github.com/Azure/kube-egress-gateway/pkg/azmanager"
+	"github.com/Azure/kube-egress-gateway/pkg/consts"
+	"github.com/Azure/kube-egress-gateway/pkg/utils/to"
+)
+
+var _ = Describe("EgressIPPool controller unit tests", func() {
+	const byoPrefixID = "/subscriptions/testSub/resourceGroups/rg/providers/Microsoft.Network/publicIPPrefixes/byo"
+
+	var (
+		r            *EgressIPPoolReconciler
+		az           *azmanager.AzureManager
+		cl           client.Client
+		pool         *egressgatewayv1alpha1.EgressIPPool
+		prefixClient *mock_publicipprefixclient.MockInterface
+		req          = reconcile.Request{NamespacedName: types.NamespacedName{Name: "pool"}}
+	)
+
+	ipPrefix := func(id, cidr string, length int32) *network.PublicIPPrefix {
+		return &network.PublicIPPrefix{
+			ID:         to.Ptr(id),
+			Properties: &network.PublicIPPrefixPropertiesFormat{IPPrefix: to.Ptr(cidr), PrefixLength: to.Ptr(length)},
+		}
+	}
+
+	getPool := func() *egressgatewayv1alpha1.EgressIPPool {
+		found := &egressgatewayv1alpha1.EgressIPPool{}
+		Expect(cl.Get(context.TODO(), req.NamespacedName, found)).To(Succeed())
+		return found
+	}
+
+	build := func() {
+		cl = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithStatusSubresource(pool).WithObjects(pool).Build()
+		r = &EgressIPPoolReconciler{Client: cl, AzureManager: az, Recorder: record.NewFakeRecorder(10)}
+	}
+
+	BeforeEach(func() {
+		az = getMockAzureManager(gomock.NewController(GinkgoT()))
+		prefixClient = az.PublicIPPrefixClient.(*mock_publicipprefixclient.MockInterface)
+		pool = &egressgatewayv1alpha1.EgressIPPool{
+			ObjectMeta: metav1.ObjectMeta{Name: "pool", UID: "poolUID"},
+			Spec: egressgatewayv1alpha1.EgressIPPoolSpec{
+				Prefixes: []egressgatewayv1alpha1.EgressIPPoolPrefix{
+					{Name: "managed", PrefixLength: 31},
+					{Name: "byo", PublicIpPrefixId: byoPrefixID},
+				},
+			},
+		}
+	})
+
+	It("should create managed prefixes and record all prefixes", func() {
+		build()
+		prefixClient.EXPECT().Get(gomock.Any(), testRG, "egressgateway-pool-poolUID-managed", gomock.Any()).Return(nil, &azcore.ResponseError{StatusCode: http.StatusNotFound})
+		prefixClient.EXPECT().CreateOrUpdate(gomock.Any(), testRG, "egressgateway-pool-poolUID-managed", gomock.Any()).DoAndReturn(
+			func(ctx context.Context, resourceGroupName string, publicIPPrefixName string, prefix network.PublicIPPrefix) (*network.PublicIPPrefix, error) {
+				Expect(to.Val(prefix.Properties.PrefixLength)).To(Equal(int32(31)))
+				return ipPrefix("managedID", "1.2.3.0/31", 31), nil
+			})
+		prefixClient.EXPECT().Get(gomock.Any(), "rg", "byo", gomock.Any()).Return(ipPrefix(byoPrefixID, "1.2.4.0/30", 30), nil)
+
+		_, err := r.Reconcile(context.TODO(), req)
+		Expect(err).NotTo(HaveOccurred())
+
+		found := getPool()
+		Expect(found.Finalizers).To(ContainElement(consts.EgressIPPoolFinalizerName))
+		Expect(found.Status.Prefixes).To(Equal([]egressgatewayv1alpha1.EgressIPPoolPrefixStatus{
+			{Name: "managed", PublicIpPrefixId: "managedID", IpPrefix: "1.2.3.0/31", PrefixLength: 31, Managed: true},
+			{Name: "byo", PublicIpPrefixId: byoPrefixID, IpPrefix: "1.2.4.0/30", PrefixLength: 30},
+		}))
+	})
+
+	It("should keep claims and only delete unclaimed removed prefixes", func() {
+		pool.Spec.Prefixes = pool.Spec.Prefixes[:1]
+		pool.Status.Prefixes = []egressgatewayv1alpha1.EgressIPPoolPrefixStatus{
+			{Name: "managed", PublicIpPrefixId: "managedID", IpPrefix: "1.2.3.0/31", PrefixLength: 31, Managed: true, ClaimedBy: "ns/gw", ReclaimPolicy: egressgatewayv1alpha1.EgressIPReclaimRetain},
+			{Name: "claimed", PublicIpPrefixId: "claimedID", IpPrefix: "1.2.5.0/31", PrefixLength: 31, Managed: true, ClaimedBy: "ns/gw2"},
+			{Name: "unclaimed", PublicIpPrefixId: "unclaimedID", IpPrefix: "1.2.6.0/31", PrefixLength: 31, Managed: true},
+		}
+		build()
+		prefixClient.EXPECT().Get(gomock.Any(), testRG, "egressgateway-pool-poolUID-managed", gomock.Any()).Return(ipPrefix("managedID", "1.2.3.0/31", 31), nil)
+		prefixClient.EXPECT().Get(gomock.Any(), testRG, "egressgateway-pool-poolUID-unclaimed", gomock.Any()).Return(ipPrefix("unclaimedID", "1.2.6.0/31", 31), nil)
+		prefixClient.EXPECT().Delete(gomock.Any(), testRG, "egressgateway-pool-poolUID-unclaimed").Return(nil)
+
+		_, err := r.Reconcile(context.TODO(), req)
+		Expect(err).To(MatchError(ContainSubstring("prefix claimed is removed but still claimed by ns/gw2")))
+
+		found := getPool()
+		Expect(found.Status.Prefixes).To(HaveLen(2))
+		Expect(found.Status.Prefixes[0].ClaimedBy).To(Equal("ns/gw"))
+		Expect(found.Status.Prefixes[1].Name).To(Equal("claimed"))
+	})
+
+	It("should not replace a claimed prefix", func() {
+		pool.Status.Prefixes = []egressgatewayv1alpha1.EgressIPPoolPrefixStatus{
+			{Name: "byo", PublicIpPrefixId: "previousID", IpPrefix: "1.2.5.0/31", PrefixLength: 31, ClaimedBy: "ns/gw"},
+		}
+		pool.Spec.Prefixes = pool.Spec.Prefixes[1:]
+		build()
+		prefixClient.EXPECT().Get(gomock.Any(), "rg", "byo", gomock.Any()).Return(ipPrefix(byoPrefixID, "1.2.4.0/30", 30), nil)
+
+		_, err := r.Reconcile(context.TODO(), req)
+		Expect(err).To(MatchError("prefix byo is claimed by ns/gw and cannot be replaced"))
+		Expect(getPool().Status.Prefixes[0].PublicIpPrefixId).To(Equal("previousID"))
+	})
+
+	When("pool is being deleted", func() {
+		BeforeEach(func() {
+			pool.Finalizers = []string{consts.EgressIPPoolFinalizerName}
+			pool.DeletionTimestamp = to.Ptr(metav1.Now())
+			pool.Status.Prefixes = []egressgatewayv1alpha1.EgressIPPoolPrefixStatus{
+				{Name: "managed", PublicIpPrefixId: "managedID", IpPrefix: "1.2.3.0/31", PrefixLength: 31, Managed: true, ClaimedBy: "ns/gw"},
+			}
+		})
+
+		It("should wait for claims to be released", func() {
+			build()
+			_, err := r.Reconcile(context.TODO(), req)
+			Expect(err).To(MatchError("EgressIPPool pool still has claimed prefixes: managed(ns/gw)"))
+			Expect(getPool().Finalizers).To(ContainElement(consts.EgressIPPoolFinalizerName))
+		})
+
+		It("should delete managed prefixes and remove finalizer", func() {
+			pool.Status.Prefixes[0].ClaimedBy = ""
+			build()
+			prefixClient.EXPECT().Get(gomock.Any(), testRG, "egressgateway-pool-poolUID-managed", gomock.Any()).Return(ipPrefix("managedID", "1.2.3.0/31", 31), nil)
+			prefixClient.EXPECT().Delete(gomock.Any(), testRG, "egressgateway-pool-poolUID-managed").Return(nil)
+
+			_, err := r.Reconcile(context.TODO(), req)
+			Expect(err).NotTo(HaveOccurred())
+			err = cl.Get(context.TODO(), req.NamespacedName, &egressgatewayv1alpha1.EgressIPPool{})
+			Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		})
+	})
+})
@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"slices"
 	"strings"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
@@ -48,6 +49,7 @@ const (
 )
 
 type lbPropertyNames struct {
+	zone         string
 	frontendName string
 	backendName  string
 	lbRuleName   string
@@ -147,7 +149,7 @@ func (r *GatewayLBConfigurationReconciler) reconcile(
 	lbConfig.DeepCopyInto(existing)
 
 	// reconcile LB rule
-	ip, port, err := r.reconcileLBRule(ctx, lbConfig, true)
+	ip, port, zonalFrontends, err := r.reconcileLBRule(ctx, lbConfig, true)
 	if err != nil {
 		log.Error(err, "failed to reconcile LB rules")
 		return ctrl.Result{}, err
@@ -164,6 +166,7 @@ func (r *GatewayLBConfigurationReconciler) reconcile(
 	}
 	lbConfig.Status.FrontendIp = ip
 	lbConfig.Status.ServerPort = port
+	lbConfig.Status.ZonalFrontends = zonalFrontends
 
 	if !equality.Semantic.DeepEqual(existing, lbConfig) {
 		log.Info(fmt.Sprintf("Updating GatewayLBConfiguration %s/%s", lbConfig.Namespace, lbConfig.Name))
@@ -217,7 +220,7 @@ func (r *GatewayLBConfigurationReconciler) ensureDeleted(
 	} // vmConfig is already deleted, continue to clean up lb
 
 	// delete LB rule
-	_, _, err := r.reconcileLBRule(ctx, lbConfig, false)
+	_, _, _, err := r.reconcileLBRule(ctx, lbConfig, false)
 	if err != nil {
 		log.Error(err, "failed to reconcile LB rules")
 		return ctrl.Result{}, err
@@ -241,6 +244,8 @@ type GatewayPool interface {
 		ipPrefixID string,
 		wantIPConfig bool) ([]string, error) // todo refactor to some config struct
 	GetUniqueID() string
+	// GetZones returns the sorted availability zones the gateway VMs are deployed in.
+	GetZones(ctx context.Context) ([]string, error)
 }
 
 func getLBPropertyName(
@@ -263,6 +268,41 @@ func getLBPropertyName(
 	return names, nil
 }
 
+// getLBPropertyNames returns the regional lbPropertyNames when zones is empty,
+// otherwise one lbPropertyNames per zone. All of them share the same probe.
+func getLBPropertyNames(
+	lbConfig *egressgatewayv1alpha1.GatewayLBConfiguration,
+	ap GatewayPool,
+	zones []string,
+) ([]*lbPropertyNames, error) {
+	names, err := getLBPropertyName(lbConfig, ap)
+	if err != nil {
+		return nil, err
+	}
+	if len(zones) == 0 {
+		return []*lbPropertyNames{names}, nil
+	}
+	namesList := make([]*lbPropertyNames, 0, len(zones))
+	for _, zone := range zones {
+		namesList = append(namesList, &lbPropertyNames{
+			zone:         zone,
+			frontendName: zonalResourceName(names.frontendName, zone),
+			backendName:  zonalResourceName(names.backendName, zone),
+			lbRuleName:   zonalResourceName(names.lbRuleName, zone),
+			probeName:    names.probeName,
+		})
+	}
+	return namesList, nil
+}
+
+// zonalResourceName returns the name of LB sub-resource serving the given zone.
+func zonalResourceName(name, zone string) string {
+	if zone == "" {
+		return name
+	}
+	return name + "-" + zone
+}
+
 func (r *GatewayLBConfigurationReconciler) getGatewayLB(ctx context.Context) (*network.LoadBalancer, error) {
 	lb, err := r.GetLB(ctx)
 	if err == nil {
@@ -344,27 +384,44 @@ func (r *agentPoolVMSS) GetUniqueID() string {
 	return *r.vmss.Properties.UniqueID
 }
 
+func (r *agentPoolVMSS) GetZones(ctx context.Context) ([]string, error) {
+	if r.vmss == nil {
+		return nil, nil
+	}
+	return sortedZones(r.vmss.Zones), nil
+}
+
+// sortedZones returns the deduplicated and sorted zones.
+func sortedZones(zones []*string) []string {
+	var result []string
+	for _, zone := range zones {
+		if z := to.Val(zone); z != "" && !slices.Contains(result, z) {
+			result = append(result, z)
+		}
+	}
+	slices.Sort(result)
+	return result
+}
+
 func (r *GatewayLBConfigurationReconciler) reconcileLBRule(
 	ctx context.Context,
 	lbConfig *egressgatewayv1alpha1.GatewayLBConfiguration,
 	needLB bool,
-) (string, int32, error) {
+) (string, int32, []egressgatewayv1alpha1.ZonalFrontend, error) {
 	log := log.FromContext(ctx)
-	frontendIP := ""
 	var lbPort int32
 	updateLB := false
-	deleteFrontend := false
 
 	// get LoadBalancer
 	lb, err := r.getGatewayLB(ctx)
 	if err != nil {
 		log.Error(err, "failed to get LoadBalancer")
-		return "", 0, err
+		return "", 0, nil, err
 	}
 	if lb == nil {
 		if !needLB {
 			log.Info(fmt.Sprintf("gateway lb(%s) not found, no more clean up needed", r.LoadBalancerName()))
-			return "", 0, nil
+			return "", 0, nil, nil
 		} else {
 			lb = &network.LoadBalancer{
 				Name:     to.Ptr(r.LoadBalancerName()),
@@ -380,115 +437,159 @@ func (r *GatewayLBConfigurationReconciler) reconcileLBRule(
 	}
 
 	// get gateway node pool
-	// we need this because each gateway pool needs one frontendConfig and one backendpool
+	// we need this because each gateway pool needs one frontendConfig and one backendpool (per zone if zoneAware)
 	agentPool, err := r.loadPool(ctx, lbConfig)
 	if err != nil {
 		log.Error(err, "failed to load node pool for lbConfig %s/%s", lbConfig.Namespace, lbConfig.Name)
-		return "", 0, err
+		return "", 0, nil, err
 	}
 
-	// get lbPropertyNames
-	names, err := getLBPropertyName(lbConfig, agentPool)
+	var zones []string
+	if lbConfig.Spec.ZoneAware {
+		zones, err = agentPool.GetZones(ctx)
+		if err != nil {
+			log.Error(err, "failed to get availability zones of gateway node pool")
+			return "", 0, nil, err
+		}
+		// when deleting, the sub-resources of both modes are cleaned up below regardless of zones
+		if len(zones) == 0 && needLB {
+			return "", 0, nil, fmt.Errorf("zoneAware is enabled but gateway node pool is not deployed in any availability zone")
+		}
+	}
+
+	// get lbPropertyNames, one for each frontend
+	namesList, err := getLBPropertyNames(lbConfig, agentPool, zones)
 	if err != nil {
 		log.Error(err, "failed to get LB property names for lbConfig %s/%s", lbConfig.Namespace, lbConfig.Name)
-		return "", 0, err
+		return "", 0, nil, err
 	}
 
 	if lb.Properties == nil {
-		return "", 0, fmt.Errorf("lb property is empty")
+		return "", 0, nil, fmt.Errorf("lb property is empty")
 	}
 
-	frontendID := r.GetLBFrontendIPConfigurationID(names.frontendName)
-	frontendIP, err = findFrontendIP(lb, names.frontendName)
-	if err != nil {
-		return "", 0, err
-	}
-	if frontendIP == "" {
-		if needLB {
-			subnet, err := r.GetSubnet(ctx)
-			if err != nil {
-				log.Error(err, "failed to get subnet")
-				return "", 0, err
+	frontendIPs := make([]string, len(namesList))
+	frontendIDs := make([]*string, len(namesList))
+	backendIDs := make([]*string, len(namesList))
+	var subnetID *string
+	for i, names := range namesList {
+		frontendIDs[i] = r.GetLBFrontendIPConfigurationID(names.frontendName)
+		frontendIPs[i], err = findFrontendIP(lb, names.frontendName)
+		if err != nil {
+			return "", 0, nil, err
+		}
+		if frontendIPs[i] == "" {
+			if needLB {
+				if subnetID == nil {
+					subnet, err := r.GetSubnet(ctx)
+					if err != nil {
+						log.Error(err, "failed to get subnet")
+						return "", 0, nil, err
+					}
+					subnetID = subnet.ID
+				}
+				lb.Properties.FrontendIPConfigurations =
+					append(lb.Properties.FrontendIPConfigurations, getExpectedFrontendConfig(to.Ptr(names.frontendName), subnetID))
+				updateLB = true
 			}
-			lb.Properties.FrontendIPConfigurations =
-				append(lb.Properties.FrontendIPConfigurations, getExpectedFrontendConfig(to.Ptr(names.frontendName), subnet.ID))
-			updateLB = true
+		} else {
+			log.Info("Found LB frontendIPConfiguration", "frontendIP", frontendIPs[i], "zone", names.zone)
 		}
-	} else {
-		log.Info("Found LB frontendIPConfiguration", "frontendIP", frontendIP)
-	}
 
-	backendID := r.GetLBBackendAddressPoolID(names.backendName)
-	foundBackend := false
-	for _, backendPool := range lb.Properties.BackendAddressPools {
-		if strings.EqualFold(*backendPool.Name, names.backendName) &&
-			strings.EqualFold(*backendPool.ID, *backendID) {
-			log.Info("Found LB backendAddressPool", "backendName", names.backendName)
-			foundBackend = true
-			break
+		backendIDs[i] = r.GetLBBackendAddressPoolID(names.backendName)
+		foundBackend := false
+		for _, backendPool := range lb.Properties.BackendAddressPools {
+			if strings.EqualFold(*backendPool.Name, names.backendName) &&
+				strings.EqualFold(*backendPool.ID, *backendIDs[i]) {
+				log.Info("Found LB backendAddressPool", "backendName", names.backendName)
+				foundBackend = true
+				break
+			}
 		}
-	}
-	if !foundBackend {
-		if needLB {
-			lb.Properties.BackendAddressPools =
-				append(lb.Properties.BackendAddressPools, getExpectedBackendPool(to.Ptr(names.backendName)))
-			updateLB = true
+		if !foundBackend {
+			if needLB {
+				lb.Properties.BackendAddressPools =
+					append(lb.Properties.BackendAddressPools, getExpectedBackendPool(to.Ptr(names.backendName)))
+				updateLB = true
+			}
 		}
 	}
 
-	probeID := r.GetLBProbeID(names.probeName)
-	expectedLBRule := getExpectedLBRule(&names.lbRuleName, frontendID, backendID, probeID)
-	expectedProbe := getExpectedLBProbe(&names.probeName, r.LBProbePort, lbConfig)
+	// all lb rules of the same lbConfig share one probe
+	probeName := namesList[0].probeName
+	probeID := r.GetLBProbeID(probeName)
+	expectedLBRules := make([]*network.LoadBalancingRule, len(namesList))
+	for i := range namesList {
+		expectedLBRules[i] = getExpectedLBRule(&namesList[i].lbRuleName, frontendIDs[i], backendIDs[i], probeID)
+	}
+	expectedProbe := getExpectedLBProbe(&probeName, r.LBProbePort, lbConfig)
 
 	lbRules := lb.Properties.LoadBalancingRules
 	if needLB {
-		foundRule := false
-		for i := range lbRules {
-			lbRule := lbRules[i]
-			if strings.EqualFold(*lbRule.Name, *expectedLBRule.Name) {
-				if lbRule.Properties == nil {
-					log.Info("Found LB rule with empty properties, dropping")
-					lbRules = append(lbRules[:i], lbRules[i+1:]...)
-				} else if !sameLBRuleConfig(ctx, lbRule, expectedLBRule) {
-					log.Info("Found LB rule with different configuration, dropping")
-					lbRules = append(lbRules[:i], lbRules[i+1:]...)
-				} else {
-					log.Info("Found expected LB rule, keeping")
-					foundRule = true
-					lbPort = to.Val(lbRule.Properties.FrontendPort)
+		var missingRules []*network.LoadBalancingRule
+		for _, expectedLBRule := range expectedLBRules {
+			foundRule := false
+			for i := range lbRules {
+				lbRule := lbRules[i]
+				if strings.EqualFold(*lbRule.Name, *expectedLBRule.Name) {
+					if lbRule.Properties == nil {
+						log.Info("Found LB rule with empty properties, dropping")
+						lbRules = append(lbRules[:i], lbRules[i+1:]...)
+					} else if !sameLBRuleConfig(ctx, lbRule, expectedLBRule) {
+						log.Info("Found LB rule with different configuration, dropping")
+						lbRules = append(lbRules[:i], lbRules[i+1:]...)
+					} else if lbPort != 0 && to.Val(lbRule.Properties.FrontendPort) != lbPort {
+						// all zonal rules must listen on the same port as the gateway interface is shared
+						log.Info("Found LB rule with inconsistent port, dropping", "port", to.Val(lbRule.Properties.FrontendPort))
+						lbRules = append(lbRules[:i], lbRules[i+1:]...)
+					} else {
+						log.Info("Found expected LB rule, keeping")
+						foundRule = true
+						lbPort = to.Val(lbRule.Properties.FrontendPort)
+					}
+					break
 				}
-				break
+			}
+			if !foundRule {
+				missingRules = append(missingRules, expectedLBRule)
 			}
 		}
-		if !foundRule {
-			port, err := selectPortForLBRule(expectedLBRule, lbRules)
-			if err != nil {
-				return "", 0, err
+		// drop rules left over from a different zone layout, e.g. zoneAware being toggled
+		for i := len(lbRules) - 1; i >= 0; i = i - 1 {
+			if ownsLBRule(lbConfig, to.Val(lbRules[i].Name)) && !containsLBRule(expectedLBRules, to.Val(lbRules[i].Name)) {
+				log.Info("Found stale LB rule, dropping", "lbRule", to.Val(lbRules[i].Name))
+				lbRules = append(lbRules[:i], lbRules[i+1:]...)
+				lb.Properties.LoadBalancingRules = lbRules
+				updateLB = true
+			}
+		}
+		if len(missingRules) > 0 {
+			if lbPort == 0 {
+				port, err := selectPortForLBRules(missingRules, lbRules)
+				if err != nil {
+					return "", 0, nil, err
+				}
+				lbPort = port
+			}
+			for _, expectedLBRule := range missingRules {
+				log.Info("Creating new lbRule", "lbRule", to.Val(expectedLBRule.Name), "port", lbPort)
+				expectedLBRule.Properties.FrontendPort = to.Ptr(lbPort)
+				expectedLBRule.Properties.BackendPort = to.Ptr(lbPort)
+				lbRules = append(lbRules, expectedLBRule)
 			}
-			log.Info("Creating new lbRule", "port", port)
-			lbPort = port
-			expectedLBRule.Properties.FrontendPort = &port
-			expectedLBRule.Properties.BackendPort = &port
-			lbRules = append(lbRules, expectedLBRule)
 			lb.Properties.LoadBalancingRules = lbRules
 			updateLB = true
 		}
 	} else {
-		ruleRefCnt := 0
 		for i := len(lbRules) - 1; i >= 0; i = i - 1 {
 			lbRule := lbRules[i]
-			if strings.EqualFold(*lbRule.Name, *expectedLBRule.Name) {
-				log.Info("Found LB rule, dropping")
+			if ownsLBRule(lbConfig, to.Val(lbRule.Name)) {
+				log.Info("Found LB rule, dropping", "lbRule", to.Val(lbRule.Name))
 				lbRules = append(lbRules[:i], lbRules[i+1:]...)
 				updateLB = true
 				lb.Properties.LoadBalancingRules = lbRules
-			} else if strings.EqualFold(to.Val(lbRule.Properties.FrontendIPConfiguration.ID), to.Val(frontendID)) {
-				ruleRefCnt = ruleRefCnt + 1
 			}
 		}
-		if ruleRefCnt == 0 {
-			deleteFrontend = true
-		}
 	}
 
 	probes := lb.Properties.Probes
@@ -534,34 +635,57 @@ func (r *GatewayLBConfigurationReconciler) reconcileLBRule(
 		}
 	}
 
-	if !needLB && deleteFrontend {
-		log.Info(fmt.Sprintf("no more gateway profile referring vmss(%s), deleting frontend and backend", names.frontendName))
-		frontends := lb.Properties.FrontendIPConfigurations
-		for i, frontendConfig := range frontends {
-			if strings.EqualFold(to.Val(frontendConfig.ID), to.Val(frontendID)) {
-				frontends = append(frontends[:i], frontends[i+1:]...)
-				updateLB = true
-				lb.Properties.FrontendIPConfigurations = frontends
-				break
-			}
-		}
-		backends := lb.Properties.BackendAddressPools
-		for i, backendPool := range backends {
-			if strings.EqualFold(to.Val(backendPool.ID), to.Val(backendID)) {
-				backends = append(backends[:i], backends[i+1:]...)
-				updateLB = true
-				lb.Properties.BackendAddressPools = backends
-				break
-			}
-		}
-
+	// drop frontends and backend pools of the gateway node pool which are neither expected by the lbConfig
+	// nor referred to by any lb rule, either regional or zonal, so that toggling zoneAware does not leak them
+	expectedNames := make(map[string]bool, len(namesList))
+	if needLB {
+		for _, names := range namesList {
+			expectedNames[strings.ToLower(names.frontendName)] = true
+			expectedNames[strings.ToLower(names.backendName)] = true
+		}
+	}
+	frontends := lb.Properties.FrontendIPConfigurations
+	for i := len(frontends) - 1; i >= 0; i = i - 1 {
+		name := to.Val(frontends[i].Name)
+		if !ownsPoolResource(agentPool, name) || expectedNames[strings.ToLower(name)] ||
+			referredByLBRule(lbRules, r.GetLBFrontendIPConfigurationID(name), func(rule *network.LoadBalancingRulePropertiesFormat) *network.SubResource {
+				return rule.FrontendIPConfiguration
+			}) {
+			continue
+		}
+		log.Info("Found unused LB frontendIPConfiguration, dropping", "frontendName", name)
+		frontends = append(frontends[:i], frontends[i+1:]...)
+		updateLB = true
+		lb.Properties.FrontendIPConfigurations = frontends
+	}
+	backends := lb.Properties.BackendAddressPools
+	for i := len(backends) - 1; i >= 0; i = i - 1 {
+		name := to.Val(backends[i].Name)
+		if !ownsPoolResource(agentPool, name) || expectedNames[strings.ToLower(name)] ||
+			referredByLBRule(lbRules, r.GetLBBackendAddressPoolID(name), func(rule *network.LoadBalancingRulePropertiesFormat) *network.SubResource {
+				return rule.BackendAddressPool
+			}) {
+			continue
+		}
+		if needLB && backends[i].Properties != nil && len(backends[i].Properties.BackendIPConfigurations) > 0 {
+			// gateway VMs are moved to the new backend pools by the vmConfig, whose update triggers another reconcile
+			log.Info("Found unused LB backendAddressPool still having gateway VMs, keeping for now", "backendName", name)
+			continue
+		}
+		log.Info("Found unused LB backendAddressPool, dropping", "backendName", name)
+		backends = append(backends[:i], backends[i+1:]...)
+		updateLB = true
+		lb.Properties.BackendAddressPools = backends
+	}
+
+	if !needLB {
 		if len(lb.Properties.FrontendIPConfigurations) == 0 {
 			log.Info("Deleting load balancer")
 			if err := r.DeleteLB(ctx); err != nil {
 				log.Error(err, "failed to delete LB")
-				return "", 0, err
+				return "", 0, nil, err
 			}
-			return "", 0, nil
+			return "", 0, nil, nil
 		}
 	}
 
@@ -570,20 +694,73 @@ func (r *GatewayLBConfigurationReconciler) reconcileLBRule(
 		updatedLB, err := r.CreateOrUpdateLB(ctx, *lb)
 		if err != nil {
 			log.Error(err, "failed to update LB")
-			return "", 0, err
-		}
-		if needLB && frontendIP == "" {
-			frontendIP, err = findFrontendIP(updatedLB, names.frontendName)
-			if err != nil {
-				log.Error(err, "failed to find frontend ip")
-				return "", 0, err
-			} else if frontendIP == "" {
-				return "", 0, fmt.Errorf("frontend ip not found even after updating lb")
+			return "", 0, nil, err
+		}
+		if needLB {
+			for i, names := range namesList {
+				if frontendIPs[i] != "" {
+					continue
+				}
+				frontendIPs[i], err = findFrontendIP(updatedLB, names.frontendName)
+				if err != nil {
+					log.Error(err, "failed to find frontend ip")
+					return "", 0, nil, err
+				} else if frontendIPs[i] == "" {
+					return "", 0, nil, fmt.Errorf("frontend ip not found even after updating lb")
+				}
 			}
 		}
 	}
 
-	return frontendIP, lbPort, nil
+	var zonalFrontends []egressgatewayv1alpha1.ZonalFrontend
+	for i, names := range namesList {
+		if names.zone != "" {
+			zonalFrontends = append(zonalFrontends, egressgatewayv1alpha1.ZonalFrontend{Zone: names.zone, Ip: frontendIPs[i]})
+		}
+	}
+	return frontendIPs[0], lbPort, zonalFrontends, nil
+}
+
+// ownsPoolResource returns true if the frontend or backend pool is created for the gateway node pool,
+// either the regional one or one of the zonal ones.
+func ownsPoolResource(ap GatewayPool, name string) bool {
+	uid := strings.ToLower(ap.GetUniqueID())
+	name = strings.ToLower(name)
+	return name == uid || strings.HasPrefix(name, uid+"-")
+}
+
+// referredByLBRule returns true if any of the lb rules refers to the resource with the given ID.
+func referredByLBRule(
+	lbRules []*network.LoadBalancingRule,
+	id *string,
+	ref func(*network.LoadBalancingRulePropertiesFormat) *network.SubResource,
+) bool {
+	for _, lbRule := range lbRules {
+		if lbRule.Properties == nil {
+			continue
+		}
+		if subResource := ref(lbRule.Properties); subResource != nil && strings.EqualFold(to.Val(subResource.ID), to.Val(id)) {
+			return true
+		}
+	}
+	return false
+}
+
+// ownsLBRule returns true if the lb rule is created for the lbConfig, either
+// the regional rule or one of the zonal rules.
+func ownsLBRule(lbConfig *egressgatewayv1alpha1.GatewayLBConfiguration, ruleName string) bool {
+	uid := strings.ToLower(string(lbConfig.GetUID()))
+	ruleName = strings.ToLower(ruleName)
+	return ruleName == uid || strings.HasPrefix(ruleName, uid+"-")
+}
+
+func containsLBRule(lbRules []*network.LoadBalancingRule, ruleName string) bool {
+	for _, lbRule := range lbRules {
+		if strings.EqualFold(to.Val(lbRule.Name), ruleName) {
+			return true
+		}
+	}
+	return false
 }
 
 func findFrontendIP(
@@ -719,10 +896,16 @@ func sameLBRuleConfig(ctx context.Context, lbRule1, lbRule2 *network.LoadBalanci
 }
 
 func selectPortForLBRule(targetRule *network.LoadBalancingRule, lbRules []*network.LoadBalancingRule) (int32, error) {
+	return selectPortForLBRules([]*network.LoadBalancingRule{targetRule}, lbRules)
+}
+
+// selectPortForLBRules returns a port that is not used by any existing rule
+// sharing a backend pool with one of the target rules.
+func selectPortForLBRules(targetRules []*network.LoadBalancingRule, lbRules []*network.LoadBalancingRule) (int32, error) {
 	ports := make([]bool, consts.WireguardPortEnd-consts.WireguardPortStart)
 	for _, rule := range lbRules {
 		if rule.Properties != nil && rule.Properties.BackendAddressPool != nil &&
-			strings.EqualFold(to.Val(rule.Properties.BackendAddressPool.ID), to.Val(targetRule.Properties.BackendAddressPool.ID)) {
+			containsBackendPool(targetRules, to.Val(rule.Properties.BackendAddressPool.ID)) {
 			if rule.Properties.FrontendPort == nil || rule.Properties.BackendPort == nil || *rule.Properties.FrontendPort != *rule.Properties.BackendPort ||
 				*rule.Properties.BackendPort < consts.WireguardPortStart || *rule.Properties.BackendPort >= consts.WireguardPortEnd {
 				return 0, fmt.Errorf("selectPortForLBRule: found rule with invalid LB port")
@@ -738,6 +921,15 @@ func selectPortForLBRule(targetRule *network.LoadBalancingRule, lbRules []*netwo
 	return 0, fmt.Errorf("selectPortForLBRule: No available ports")
 }
 
+func containsBackendPool(lbRules []*network.LoadBalancingRule, backendID string) bool {
+	for _, rule := range lbRules {
+		if strings.EqualFold(backendID, to.Val(rule.Properties.BackendAddressPool.ID)) {
+			return true
+		}
+	}
+	return false
+}
+
 func (r *GatewayLBConfigurationReconciler) reconcileGatewayVMConfig(
 	ctx context.Context,
 	lbConfig *egressgatewayv1alpha1.GatewayLBConfiguration,
@@ -755,6 +947,10 @@ func (r *GatewayLBConfigurationReconciler) reconcileGatewayVMConfig(
 		vmConfig.Spec.GatewayVmssProfile = lbConfig.Spec.GatewayVmssProfile
 		vmConfig.Spec.ProvisionPublicIps = lbConfig.Spec.ProvisionPublicIps
 		vmConfig.Spec.PublicIpPrefixId = lbConfig.Spec.PublicIpPrefixId
+		vmConfig.Spec.EgressIpPoolClaim = lbConfig.Spec.EgressIpPoolClaim
+		vmConfig.Spec.SnatIpCount = lbConfig.Spec.SnatIpCount
+		vmConfig.Spec.DedicatedEgressIps = lbConfig.Spec.DedicatedEgressIps
+		vmConfig.Spec.ZoneAware = lbConfig.Spec.ZoneAware
 		return controllerutil.SetControllerReference(lbConfig, vmConfig, r.Client.Scheme())
 	}); err != nil {
 		log.Error(err, "failed to reconcile gateway vm configuration")
@@ -767,6 +963,7 @@ func (r *GatewayLBConfigurationReconciler) reconcileGatewayVMConfig(
 			lbConfig.Status = &egressgatewayv1alpha1.GatewayLBConfigurationStatus{}
 		}
 		lbConfig.Status.EgressIpPrefix = vmConfig.Status.EgressIpPrefix
+		lbConfig.Status.DedicatedEgressIps = vmConfig.Status.DedicatedEgressIps
 	}
 
 	return nil
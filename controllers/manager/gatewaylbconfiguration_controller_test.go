@@ -511,6 +511,159 @@ var _ = Describe("GatewayLBConfiguration controller unit tests", func() {
 			})
 		})
 
+		When("lbConfig is zone aware", func() {
+			BeforeEach(func() {
+				lbConfig.Spec.ZoneAware = true
+				controllerutil.AddFinalizer(lbConfig, consts.LBConfigFinalizerName)
+				az = getMockAzureManager(gomock.NewController(GinkgoT()))
+				cl = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithStatusSubresource(lbConfig).WithRuntimeObjects(gwConfig, lbConfig).Build()
+				r = &GatewayLBConfigurationReconciler{Client: cl, AzureManager: az, Recorder: recorder, LBProbePort: lbProbePort}
+			})
+
+			It("should report error if gateway VMSS is not zonal", func() {
+				vmss := &compute.VirtualMachineScaleSet{
+					Properties: &compute.VirtualMachineScaleSetProperties{UniqueID: to.Ptr(testVMSSUID)},
+					Tags:       map[string]*string{consts.AKSNodepoolTagKey: to.Ptr("testgw")},
+				}
+				mockLoadBalancerClient := az.LoadBalancerClient.(*mock_loadbalancerclient.MockInterface)
+				mockLoadBalancerClient.EXPECT().Get(gomock.Any(), testLBRG, testLBName, gomock.Any()).Return(getEmptyLB(), nil)
+				mockVMSSClient := az.VmssClient.(*mock_virtualmachinescalesetclient.MockInterface)
+				mockVMSSClient.EXPECT().List(gomock.Any(), testRG).Return([]*compute.VirtualMachineScaleSet{vmss}, nil)
+				_, reconcileErr = r.Reconcile(context.TODO(), req)
+				expectedErr := "zoneAware is enabled but gateway node pool is not deployed in any availability zone"
+				Expect(reconcileErr).To(Equal(fmt.Errorf("%s", expectedErr)))
+				assertEqualEvents([]string{"Warning ReconcileGatewayLBConfigurationError " + expectedErr}, recorder.Events)
+			})
+
+			It("should create one frontend, backend and lbRule per zone", func() {
+				vmss := &compute.VirtualMachineScaleSet{
+					Properties: &compute.VirtualMachineScaleSetProperties{UniqueID: to.Ptr(testVMSSUID)},
+					Tags:       map[string]*string{consts.AKSNodepoolTagKey: to.Ptr("testgw")},
+					Zones:      []*string{to.Ptr("2"), to.Ptr("1")},
+				}
+				mockVMSSClient := az.VmssClient.(*mock_virtualmachinescalesetclient.MockInterface)
+				mockVMSSClient.EXPECT().List(gomock.Any(), testRG).Return([]*compute.VirtualMachineScaleSet{vmss}, nil)
+				mockSubnetClient := az.SubnetClient.(*mock_subnetclient.MockInterface)
+				mockSubnetClient.EXPECT().Get(gomock.Any(), testVnetRG, testVnetName, testSubnetName, gomock.Any()).Return(&network.Subnet{
+					ID: to.Ptr("testSubnet"),
+				}, nil).Times(1)
+				// the existing regional rule, frontend and backend should be replaced by the zonal ones
+				existingLB := getExpectedLB()
+				mockLoadBalancerClient := az.LoadBalancerClient.(*mock_loadbalancerclient.MockInterface)
+				mockLoadBalancerClient.EXPECT().Get(gomock.Any(), testLBRG, testLBName, gomock.Any()).Return(existingLB, nil)
+				mockLoadBalancerClient.EXPECT().CreateOrUpdate(gomock.Any(), testLBRG, testLBName, gomock.Any()).DoAndReturn(func(ctx context.Context, resourceGroupName string, loadBalancerName string, loadBalancer network.LoadBalancer) (*network.LoadBalancer, error) {
+					Expect(loadBalancer.Properties.FrontendIPConfigurations).To(HaveLen(2))
+					Expect(loadBalancer.Properties.BackendAddressPools).To(HaveLen(2))
+					Expect(loadBalancer.Properties.Probes).To(HaveLen(1))
+					Expect(loadBalancer.Properties.LoadBalancingRules).To(HaveLen(2))
+					for i, zone := range []string{"1", "2"} {
+						rule := loadBalancer.Properties.LoadBalancingRules[i]
+						Expect(to.Val(rule.Name)).To(Equal(testLBConfigUID + "-" + zone))
+						Expect(to.Val(rule.Properties.FrontendIPConfiguration.ID)).To(HaveSuffix("/frontendIPConfigurations/" + testVMSSUID + "-" + zone))
+						Expect(to.Val(rule.Properties.BackendAddressPool.ID)).To(HaveSuffix("/backendAddressPools/" + testVMSSUID + "-" + zone))
+						Expect(to.Val(rule.Properties.Probe.ID)).To(HaveSuffix("/probes/" + testLBConfigUID))
+						Expect(to.Val(rule.Properties.FrontendPort)).To(Equal(int32(6000)))
+						Expect(to.Val(rule.Properties.BackendPort)).To(Equal(int32(6000)))
+
+						frontend := loadBalancer.Properties.FrontendIPConfigurations[i]
+						Expect(to.Val(frontend.Name)).To(Equal(testVMSSUID + "-" + zone))
+						frontend.Properties.PrivateIPAddress = to.Ptr("10.0.1." + zone)
+
+						backend := loadBalancer.Properties.BackendAddressPools[i]
+						Expect(to.Val(backend.Name)).To(Equal(testVMSSUID + "-" + zone))
+					}
+					return &loadBalancer, nil
+				})
+				_, reconcileErr = r.Reconcile(context.TODO(), req)
+				Expect(reconcileErr).To(BeNil())
+				assertEqualEvents([]string{"Normal ReconcileGatewayLBConfigurationSuccess GatewayLBConfiguration reconciled"}, recorder.Events)
+
+				Expect(getResource(cl, foundLBConfig)).To(Succeed())
+				Expect(foundLBConfig.Status.FrontendIp).To(Equal("10.0.1.1"))
+				Expect(foundLBConfig.Status.ServerPort).To(Equal(int32(6000)))
+				Expect(foundLBConfig.Status.ZonalFrontends).To(Equal([]egressgatewayv1alpha1.ZonalFrontend{
+					{Zone: "1", Ip: "10.0.1.1"},
+					{Zone: "2", Ip: "10.0.1.2"},
+				}))
+				Expect(getResource(cl, foundVMConfig)).To(Succeed())
+				Expect(foundVMConfig.Spec.ZoneAware).To(BeTrue())
+			})
+
+			It("should replace zonal frontends and backends when toggling zoneAware back and forth", func() {
+				vmss := &compute.VirtualMachineScaleSet{
+					Properties: &compute.VirtualMachineScaleSetProperties{UniqueID: to.Ptr(testVMSSUID)},
+					Tags:       map[string]*string{consts.AKSNodepoolTagKey: to.Ptr("testgw")},
+					Zones:      []*string{to.Ptr("1"), to.Ptr("2")},
+				}
+				mockVMSSClient := az.VmssClient.(*mock_virtualmachinescalesetclient.MockInterface)
+				mockVMSSClient.EXPECT().List(gomock.Any(), testRG).Return([]*compute.VirtualMachineScaleSet{vmss}, nil).AnyTimes()
+				mockSubnetClient := az.SubnetClient.(*mock_subnetclient.MockInterface)
+				mockSubnetClient.EXPECT().Get(gomock.Any(), testVnetRG, testVnetName, testSubnetName, gomock.Any()).Return(&network.Subnet{
+					ID: to.Ptr("testSubnet"),
+				}, nil).AnyTimes()
+				mockLoadBalancerClient := az.LoadBalancerClient.(*mock_loadbalancerclient.MockInterface)
+				frontendNames := func(lb *network.LoadBalancer) []string {
+					var names []string
+					for _, frontend := range lb.Properties.FrontendIPConfigurations {
+						names = append(names, to.Val(frontend.Name))
+					}
+					return names
+				}
+				backendNames := func(lb *network.LoadBalancer) []string {
+					var names []string
+					for _, backend := range lb.Properties.BackendAddressPools {
+						names = append(names, to.Val(backend.Name))
+					}
+					return names
+				}
+				// the LB as returned by Azure, with IDs and IPs of the new frontends and backends set
+				updateLB := func(loadBalancer network.LoadBalancer) *network.LoadBalancer {
+					lbPrefix := fmt.Sprintf("/subscriptions/testSub/resourceGroups/%s/providers/Microsoft.Network/loadBalancers/%s", testLBRG, testLBName)
+					for i, frontend := range loadBalancer.Properties.FrontendIPConfigurations {
+						frontend.ID = to.Ptr(lbPrefix + "/frontendIPConfigurations/" + to.Val(frontend.Name))
+						frontend.Properties.PrivateIPAddress = to.Ptr(fmt.Sprintf("10.0.1.%d", i+1))
+					}
+					for _, backend := range loadBalancer.Properties.BackendAddressPools {
+						backend.ID = to.Ptr(lbPrefix + "/backendAddressPools/" + to.Val(backend.Name))
+					}
+					return &loadBalancer
+				}
+
+				var currentLB *network.LoadBalancer
+				mockLoadBalancerClient.EXPECT().Get(gomock.Any(), testLBRG, testLBName, gomock.Any()).Return(getExpectedLB(), nil)
+				mockLoadBalancerClient.EXPECT().CreateOrUpdate(gomock.Any(), testLBRG, testLBName, gomock.Any()).DoAndReturn(func(ctx context.Context, resourceGroupName string, loadBalancerName string, loadBalancer network.LoadBalancer) (*network.LoadBalancer, error) {
+					Expect(frontendNames(&loadBalancer)).To(Equal([]string{testVMSSUID + "-1", testVMSSUID + "-2"}))
+					Expect(backendNames(&loadBalancer)).To(Equal([]string{testVMSSUID + "-1", testVMSSUID + "-2"}))
+					currentLB = updateLB(loadBalancer)
+					return currentLB, nil
+				})
+				_, reconcileErr = r.Reconcile(context.TODO(), req)
+				Expect(reconcileErr).To(BeNil())
+				assertEqualEvents([]string{"Normal ReconcileGatewayLBConfigurationSuccess GatewayLBConfiguration reconciled"}, recorder.Events)
+
+				Expect(getResource(cl, foundLBConfig)).To(Succeed())
+				foundLBConfig.Spec.ZoneAware = false
+				Expect(cl.Update(context.TODO(), foundLBConfig)).To(Succeed())
+				mockLoadBalancerClient.EXPECT().Get(gomock.Any(), testLBRG, testLBName, gomock.Any()).DoAndReturn(func(ctx context.Context, resourceGroupName string, loadBalancerName string, expand *string) (*network.LoadBalancer, error) {
+					return currentLB, nil
+				})
+				mockLoadBalancerClient.EXPECT().CreateOrUpdate(gomock.Any(), testLBRG, testLBName, gomock.Any()).DoAndReturn(func(ctx context.Context, resourceGroupName string, loadBalancerName string, loadBalancer network.LoadBalancer) (*network.LoadBalancer, error) {
+					Expect(frontendNames(&loadBalancer)).To(Equal([]string{testVMSSUID}))
+					Expect(backendNames(&loadBalancer)).To(Equal([]string{testVMSSUID}))
+					Expect(loadBalancer.Properties.LoadBalancingRules).To(HaveLen(1))
+					Expect(to.Val(loadBalancer.Properties.LoadBalancingRules[0].Name)).To(Equal(testLBConfigUID))
+					return updateLB(loadBalancer), nil
+				})
+				_, reconcileErr = r.Reconcile(context.TODO(), req)
+				Expect(reconcileErr).To(BeNil())
+				assertEqualEvents([]string{"Normal ReconcileGatewayLBConfigurationSuccess GatewayLBConfiguration reconciled"}, recorder.Events)
+
+				Expect(getResource(cl, foundLBConfig)).To(Succeed())
+				Expect(foundLBConfig.Status.FrontendIp).To(Equal("10.0.1.1"))
+				Expect(foundLBConfig.Status.ZonalFrontends).To(BeEmpty())
+			})
+		})
+
 		When("lb is reconciled", func() {
 			BeforeEach(func() {
 				controllerutil.AddFinalizer(lbConfig, consts.LBConfigFinalizerName)
@@ -684,6 +837,20 @@ var _ = Describe("GatewayLBConfiguration controller unit tests", func() {
 				Expect(apierrors.IsNotFound(getErr)).To(BeTrue())
 			})
 
+			It("should delete lb and lbConfig when zone aware gateway node pool is not zonal", func() {
+				Expect(getResource(cl, foundLBConfig)).To(Succeed())
+				foundLBConfig.Spec.ZoneAware = true
+				Expect(cl.Update(context.TODO(), foundLBConfig)).To(Succeed())
+				lb := getExpectedLB()
+				mockLoadBalancerClient := az.LoadBalancerClient.(*mock_loadbalancerclient.MockInterface)
+				mockLoadBalancerClient.EXPECT().Get(gomock.Any(), testLBRG, testLBName, gomock.Any()).Return(lb, nil)
+				mockLoadBalancerClient.EXPECT().Delete(gomock.Any(), testLBRG, testLBName).Return(nil)
+				_, reconcileErr = r.Reconcile(context.TODO(), req)
+				Expect(reconcileErr).To(BeNil())
+				getErr = getResource(cl, foundLBConfig)
+				Expect(apierrors.IsNotFound(getErr)).To(BeTrue())
+			})
+
 			It("should return error and not delete lbConfig if reconcile() returns error", func() {
 				lb := getExpectedLB()
 				mockLoadBalancerClient := az.LoadBalancerClient.(*mock_loadbalancerclient.MockInterface)
@@ -827,6 +994,26 @@ var _ = Describe("GatewayLBConfiguration controller unit tests", func() {
 				Expect(err).To(Equal(invalidErr))
 			})
 
+			It("should select a port unused in all target backend pools", func() {
+				zonalRule := &network.LoadBalancingRule{
+					Properties: &network.LoadBalancingRulePropertiesFormat{
+						BackendAddressPool: &network.SubResource{ID: to.Ptr("456")},
+					},
+				}
+				for i, backend := range []string{"123", "456", "789"} {
+					lbRules = append(lbRules, &network.LoadBalancingRule{
+						Properties: &network.LoadBalancingRulePropertiesFormat{
+							BackendAddressPool: &network.SubResource{ID: to.Ptr(backend)},
+							FrontendPort:       to.Ptr(consts.WireguardPortStart + int32(i)),
+							BackendPort:        to.Ptr(consts.WireguardPortStart + int32(i)),
+						},
+					})
+				}
+				port, err := selectPortForLBRules([]*network.LoadBalancingRule{targetRule, zonalRule}, lbRules)
+				Expect(err).To(BeNil())
+				Expect(port).To(Equal(consts.WireguardPortStart + 2))
+			})
+
 			It("should report error when all ports are occupied", func() {
 				for i := consts.WireguardPortStart; i < consts.WireguardPortEnd; i++ {
 					lbRules = append(lbRules, &network.LoadBalancingRule{
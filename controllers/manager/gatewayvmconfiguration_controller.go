@@ -31,6 +31,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	egressgatewayv1alpha1 "github.com/Azure/kube-egress-gateway/api/v1alpha1"
 	"github.com/Azure/kube-egress-gateway/pkg/azmanager"
@@ -62,6 +63,8 @@ var (
 // +kubebuilder:rbac:groups=egressgateway.kubernetes.azure.com,resources=gatewayvmconfigurations,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=egressgateway.kubernetes.azure.com,resources=gatewayvmconfigurations/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=egressgateway.kubernetes.azure.com,resources=gatewayvmconfigurations/finalizers,verbs=update
+// +kubebuilder:rbac:groups=egressgateway.kubernetes.azure.com,resources=egressippools,verbs=get;list;watch
+// +kubebuilder:rbac:groups=egressgateway.kubernetes.azure.com,resources=egressippools/status,verbs=get;update;patch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -157,9 +160,26 @@ func (r *GatewayVMConfigurationReconciler) SetupWithManager(mgr ctrl.Manager) er
 		// allow for node events to trigger reconciliation when either node label matches
 		Watches(&corev1.Node{}, &handler.EnqueueRequestForObject{}, builder.WithPredicates(resourceHasFilterLabel(
 			map[string]string{consts.AKSNodepoolModeLabel: consts.AKSNodepoolModeValue, consts.UpstreamNodepoolModeLabel: "true"}))).
+		// retry claims once the pool has free prefixes
+		Watches(&egressgatewayv1alpha1.EgressIPPool{}, handler.EnqueueRequestsFromMapFunc(r.enqueueClaimingVMConfigs)).
 		Complete(r)
 }
 
+func (r *GatewayVMConfigurationReconciler) enqueueClaimingVMConfigs(ctx context.Context, o client.Object) []reconcile.Request {
+	vmConfigs := &egressgatewayv1alpha1.GatewayVMConfigurationList{}
+	if err := r.List(ctx, vmConfigs); err != nil {
+		log.FromContext(ctx).Error(err, "failed to list GatewayVMConfigurations")
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, vmConfig := range vmConfigs.Items {
+		if claim := vmConfig.Spec.EgressIpPoolClaim; claim != nil && claim.PoolName == o.GetName() {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&vmConfig)})
+		}
+	}
+	return requests
+}
+
 // resourceHasFilterLabel returns a predicate that returns true only if the provided resource contains a label
 func resourceHasFilterLabel(m map[string]string) predicate.Funcs {
 	return predicate.Funcs{
@@ -260,6 +280,15 @@ func (r *GatewayVMConfigurationReconciler) reconcile(
 		}
 	}
 
+	keepPool := ""
+	if vmConfig.Spec.ProvisionPublicIps && vmConfig.Spec.EgressIpPoolClaim != nil {
+		keepPool = vmConfig.Spec.EgressIpPoolClaim.PoolName
+	}
+	if err := releasePoolClaims(ctx, r.Client, gatewayKey(vmConfig), keepPool); err != nil {
+		log.Error(err, "failed to release egress ip pool claims")
+		return ctrl.Result{}, err
+	}
+
 	if vmConfig.Status == nil {
 		vmConfig.Status = &egressgatewayv1alpha1.GatewayVMConfigurationStatus{}
 	}
@@ -268,6 +297,7 @@ func (r *GatewayVMConfigurationReconciler) reconcile(
 	} else {
 		vmConfig.Status.EgressIpPrefix = strings.Join(privateIPs, ",")
 	}
+	vmConfig.Status.DedicatedEgressIps = dedicatedEgressIPStatus(vmConfig)
 
 	if !equality.Semantic.DeepEqual(existing, vmConfig) {
 		log.Info(fmt.Sprintf("Updating GatewayVMConfiguration %s/%s", vmConfig.Namespace, vmConfig.Name))
@@ -322,6 +352,11 @@ func (r *GatewayVMConfigurationReconciler) ensureDeleted(
 		return ctrl.Result{}, err
 	}
 
+	if err := releasePoolClaims(ctx, r.Client, gatewayKey(vmConfig), ""); err != nil {
+		log.Error(err, "failed to release egress ip pool claims")
+		return ctrl.Result{}, err
+	}
+
 	log.Info("Removing finalizer")
 	controllerutil.RemoveFinalizer(vmConfig, consts.VMConfigFinalizerName)
 	if err := r.Update(ctx, vmConfig); err != nil {
@@ -393,6 +428,129 @@ func managedSubresourceName(vmConfig *egressgatewayv1alpha1.GatewayVMConfigurati
 	return consts.ManagedResourcePrefix + string(vmConfig.GetUID())
 }
 
+// managedIPConfigNames returns the names of the secondary ipConfigs providing the sNAT IPs of the gateway on
+// each VM. The first one keeps the name of the single ipConfig created before snatIpCount was introduced.
+// The ipConfigs of the dedicated egress IPs follow the shared sNAT ones and are suffixed with the names of the
+// dedicated egress IPs, which always start with a letter.
+func managedIPConfigNames(vmConfig *egressgatewayv1alpha1.GatewayVMConfiguration) []string {
+	name := managedSubresourceName(vmConfig)
+	names := []string{name}
+	for i := 1; i < int(vmConfig.Spec.SnatIpCount); i++ {
+		names = append(names, fmt.Sprintf("%s-%d", name, i))
+	}
+	for _, dedicated := range vmConfig.Spec.DedicatedEgressIps {
+		names = append(names, name+"-"+dedicated)
+	}
+	return names
+}
+
+// sharedSNATIPCount returns the number of ipConfigs providing the shared sNAT IPs of the gateway, the ones
+// returned by managedIPConfigNames before the dedicated egress IPs.
+func sharedSNATIPCount(vmConfig *egressgatewayv1alpha1.GatewayVMConfiguration) int {
+	return max(int(vmConfig.Spec.SnatIpCount), 1)
+}
+
+// isManagedIPConfig returns whether the ipConfig is a sNAT ipConfig of the gateway, including the ones no
+// longer wanted after snatIpCount is decreased.
+func isManagedIPConfig(vmConfig *egressgatewayv1alpha1.GatewayVMConfiguration, ipConfigName string) bool {
+	name := strings.ToLower(managedSubresourceName(vmConfig))
+	ipConfigName = strings.ToLower(ipConfigName)
+	return ipConfigName == name || strings.HasPrefix(ipConfigName, name+"-")
+}
+
+// dedicatedEgressIPStatus returns the private and public IPs of each dedicated egress IP across the gateway VMs.
+func dedicatedEgressIPStatus(vmConfig *egressgatewayv1alpha1.GatewayVMConfiguration) []egressgatewayv1alpha1.DedicatedEgressIpStatus {
+	var result []egressgatewayv1alpha1.DedicatedEgressIpStatus
+	for _, name := range vmConfig.Spec.DedicatedEgressIps {
+		status := egressgatewayv1alpha1.DedicatedEgressIpStatus{Name: name}
+		for _, profile := range vmConfig.Status.GatewayVMProfiles {
+			for _, dedicated := range profile.DedicatedIPs {
+				if dedicated.Name != name {
+					continue
+				}
+				if dedicated.IP != "" {
+					status.PrivateIps = append(status.PrivateIps, dedicated.IP)
+				}
+				if dedicated.PublicIP != "" {
+					status.PublicIps = append(status.PublicIps, dedicated.PublicIP)
+				}
+			}
+		}
+		result = append(result, status)
+	}
+	return result
+}
+
+// resolveDedicatedPublicIPs gets the addresses of the public IPs of the dedicated egress IP ipConfigs, as NICs only
+// reference their public IPs by ID.
+func resolveDedicatedPublicIPs(
+	ctx context.Context,
+	az *azmanager.AzureManager,
+	vmConfig *egressgatewayv1alpha1.GatewayVMConfiguration,
+	ipCfg *gatewayIPConfig,
+) error {
+	for i := sharedSNATIPCount(vmConfig); i < len(ipCfg.publicIPIDs); i++ {
+		if ipCfg.publicIPs[i] != "" || ipCfg.publicIPIDs[i] == "" {
+			continue
+		}
+		pipID, err := arm.ParseResourceID(ipCfg.publicIPIDs[i])
+		if err != nil {
+			return fmt.Errorf("failed to parse public ip id(%s): %w", ipCfg.publicIPIDs[i], err)
+		}
+		pip, err := az.GetPublicIP(ctx, pipID.ResourceGroupName, pipID.Name)
+		if err != nil {
+			return fmt.Errorf("failed to get public ip(%s): %w", ipCfg.publicIPIDs[i], err)
+		}
+		if pip.Properties != nil {
+			ipCfg.publicIPs[i] = to.Val(pip.Properties.IPAddress)
+		}
+	}
+	return nil
+}
+
+// setGatewayVMProfile records the IPs of a gateway VM in the status of vmConfig.
+func setGatewayVMProfile(ctx context.Context, vmConfig *egressgatewayv1alpha1.GatewayVMConfiguration, nodeName string, ipCfg gatewayIPConfig) {
+	logger := log.FromContext(ctx)
+	vmprofile := egressgatewayv1alpha1.GatewayVMProfile{
+		NodeName:    nodeName,
+		PrimaryIP:   ipCfg.primaryIP,
+		SecondaryIP: ipCfg.secondaryIPs[0],
+	}
+	shared := min(sharedSNATIPCount(vmConfig), len(ipCfg.secondaryIPs))
+	if shared > 1 {
+		vmprofile.SecondaryIPs = ipCfg.secondaryIPs[:shared]
+	}
+	for i, name := range vmConfig.Spec.DedicatedEgressIps {
+		if shared+i < len(ipCfg.secondaryIPs) {
+			dedicatedIP := egressgatewayv1alpha1.GatewayVMDedicatedIP{
+				Name: name,
+				IP:   ipCfg.secondaryIPs[shared+i],
+			}
+			if shared+i < len(ipCfg.publicIPs) {
+				dedicatedIP.PublicIP = ipCfg.publicIPs[shared+i]
+			}
+			vmprofile.DedicatedIPs = append(vmprofile.DedicatedIPs, dedicatedIP)
+		}
+	}
+	if vmConfig.Status == nil {
+		vmConfig.Status = &egressgatewayv1alpha1.GatewayVMConfigurationStatus{}
+	}
+	for i, profile := range vmConfig.Status.GatewayVMProfiles {
+		if profile.NodeName == vmprofile.NodeName {
+			if !equality.Semantic.DeepEqual(profile, vmprofile) {
+				vmConfig.Status.GatewayVMProfiles[i] = vmprofile
+				logger.Info("GatewayVMConfiguration status updated", "primaryIP", ipCfg.primaryIP, "secondaryIPs", ipCfg.secondaryIPs)
+				return
+			}
+			logger.Info("GatewayVMConfiguration status not changed", "primaryIP", ipCfg.primaryIP, "secondaryIPs", ipCfg.secondaryIPs)
+			return
+		}
+	}
+
+	logger.Info("GatewayVMConfiguration status updated for new nodes", "nodeName", vmprofile.NodeName, "primaryIP", ipCfg.primaryIP, "secondaryIPs", ipCfg.secondaryIPs)
+	vmConfig.Status.GatewayVMProfiles = append(vmConfig.Status.GatewayVMProfiles, vmprofile)
+}
+
 func isErrorNotFound(err error) bool {
 	var respErr *azcore.ResponseError
 	return errors.As(err, &respErr) && respErr.StatusCode == http.StatusNotFound
@@ -411,22 +569,22 @@ func (r *GatewayVMConfigurationReconciler) ensurePublicIPPrefix(
 		return "", "", false, nil
 	}
 
+	if vmConfig.Spec.EgressIpPoolClaim != nil {
+		// the claimed prefix belongs to the pool, return isManaged as false so that previously created managed
+		// public ip prefix can be deleted
+		prefix, err := claimPoolPrefix(ctx, r.Client, vmConfig.Spec.EgressIpPoolClaim, gatewayKey(vmConfig), ipPrefixLength)
+		if err != nil {
+			return "", "", false, err
+		}
+		log.Info("Found claimed public ip prefix", "pool", vmConfig.Spec.EgressIpPoolClaim.PoolName, "public ip prefix", prefix.IpPrefix)
+		return prefix.IpPrefix, prefix.PublicIpPrefixId, false, nil
+	}
+
 	if vmConfig.Spec.PublicIpPrefixId != "" {
 		// if there is public prefix ip specified, prioritize this one
-		matches := publicIPPrefixRE.FindStringSubmatch(vmConfig.Spec.PublicIpPrefixId)
-		if len(matches) != 4 {
-			return "", "", false, fmt.Errorf("failed to parse public ip prefix id: %s", vmConfig.Spec.PublicIpPrefixId)
-		}
-		subscriptionID, resourceGroupName, publicIpPrefixName := matches[1], matches[2], matches[3]
-		if subscriptionID != r.SubscriptionID() {
-			return "", "", false, fmt.Errorf("public ip prefix subscription(%s) is not in the same subscription(%s)", subscriptionID, r.SubscriptionID())
-		}
-		ipPrefix, err := r.GetPublicIPPrefix(ctx, resourceGroupName, publicIpPrefixName)
+		ipPrefix, err := getBYOPublicIPPrefix(ctx, r.AzureManager, vmConfig.Spec.PublicIpPrefixId)
 		if err != nil {
-			return "", "", false, fmt.Errorf("failed to get public ip prefix(%s): %w", vmConfig.Spec.PublicIpPrefixId, err)
-		}
-		if ipPrefix.Properties == nil {
-			return "", "", false, fmt.Errorf("public ip prefix(%s) has empty properties", vmConfig.Spec.PublicIpPrefixId)
+			return "", "", false, err
 		}
 		if to.Val(ipPrefix.Properties.PrefixLength) != ipPrefixLength {
 			return "", "", false, fmt.Errorf("provided public ip prefix has invalid length(%d), required(%d)", to.Val(ipPrefix.Properties.PrefixLength), ipPrefixLength)
@@ -448,23 +606,10 @@ func (r *GatewayVMConfigurationReconciler) ensurePublicIPPrefix(
 			if !isErrorNotFound(err) {
 				return "", "", false, fmt.Errorf("failed to get managed public ip prefix: %w", err)
 			}
-			// create new public ip prefix
-			newIPPrefix := network.PublicIPPrefix{
-				Name:     to.Ptr(publicIpPrefixName),
-				Location: to.Ptr(r.Location()),
-				Properties: &network.PublicIPPrefixPropertiesFormat{
-					PrefixLength:           to.Ptr(ipPrefixLength),
-					PublicIPAddressVersion: to.Ptr(network.IPVersionIPv4),
-				},
-				SKU: &network.PublicIPPrefixSKU{
-					Name: to.Ptr(network.PublicIPPrefixSKUNameStandard),
-					Tier: to.Ptr(network.PublicIPPrefixSKUTierRegional),
-				},
-			}
 			log.Info("Creating new managed public ip prefix")
-			ipPrefix, err := r.CreateOrUpdatePublicIPPrefix(ctx, "", publicIpPrefixName, newIPPrefix)
+			ipPrefix, err := createManagedPublicIPPrefix(ctx, r.AzureManager, publicIpPrefixName, ipPrefixLength)
 			if err != nil {
-				return "", "", false, fmt.Errorf("failed to create managed public ip prefix: %w", err)
+				return "", "", false, err
 			}
 			return to.Val(ipPrefix.Properties.IPPrefix), to.Val(ipPrefix.ID), true, nil
 		}
@@ -475,10 +620,63 @@ func (r *GatewayVMConfigurationReconciler) ensurePublicIPPrefixDeleted(
 	ctx context.Context,
 	vmConfig *egressgatewayv1alpha1.GatewayVMConfiguration,
 ) error {
-	log := log.FromContext(ctx)
 	// only ensure managed public prefix ip is deleted
-	publicIpPrefixName := managedSubresourceName(vmConfig)
-	prefix, err := r.GetPublicIPPrefix(ctx, "", publicIpPrefixName)
+	return deleteManagedPublicIPPrefix(ctx, r.AzureManager, managedSubresourceName(vmConfig))
+}
+
+// getBYOPublicIPPrefix returns the user provided public ip prefix with ID prefixID, which must be in the same
+// subscription.
+func getBYOPublicIPPrefix(ctx context.Context, az *azmanager.AzureManager, prefixID string) (*network.PublicIPPrefix, error) {
+	matches := publicIPPrefixRE.FindStringSubmatch(prefixID)
+	if len(matches) != 4 {
+		return nil, fmt.Errorf("failed to parse public ip prefix id: %s", prefixID)
+	}
+	subscriptionID, resourceGroupName, publicIpPrefixName := matches[1], matches[2], matches[3]
+	if subscriptionID != az.SubscriptionID() {
+		return nil, fmt.Errorf("public ip prefix subscription(%s) is not in the same subscription(%s)", subscriptionID, az.SubscriptionID())
+	}
+	ipPrefix, err := az.GetPublicIPPrefix(ctx, resourceGroupName, publicIpPrefixName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get public ip prefix(%s): %w", prefixID, err)
+	}
+	if ipPrefix.Properties == nil {
+		return nil, fmt.Errorf("public ip prefix(%s) has empty properties", prefixID)
+	}
+	return ipPrefix, nil
+}
+
+// createManagedPublicIPPrefix creates a public ip prefix of ipPrefixLength named publicIpPrefixName in the default
+// resource group.
+func createManagedPublicIPPrefix(
+	ctx context.Context,
+	az *azmanager.AzureManager,
+	publicIpPrefixName string,
+	ipPrefixLength int32,
+) (*network.PublicIPPrefix, error) {
+	newIPPrefix := network.PublicIPPrefix{
+		Name:     to.Ptr(publicIpPrefixName),
+		Location: to.Ptr(az.Location()),
+		Properties: &network.PublicIPPrefixPropertiesFormat{
+			PrefixLength:           to.Ptr(ipPrefixLength),
+			PublicIPAddressVersion: to.Ptr(network.IPVersionIPv4),
+		},
+		SKU: &network.PublicIPPrefixSKU{
+			Name: to.Ptr(network.PublicIPPrefixSKUNameStandard),
+			Tier: to.Ptr(network.PublicIPPrefixSKUTierRegional),
+		},
+	}
+	ipPrefix, err := az.CreateOrUpdatePublicIPPrefix(ctx, "", publicIpPrefixName, newIPPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create managed public ip prefix: %w", err)
+	}
+	return ipPrefix, nil
+}
+
+// deleteManagedPublicIPPrefix deletes the public ip prefix named publicIpPrefixName in the default resource group,
+// along with its public ips.
+func deleteManagedPublicIPPrefix(ctx context.Context, az *azmanager.AzureManager, publicIpPrefixName string) error {
+	log := log.FromContext(ctx)
+	prefix, err := az.GetPublicIPPrefix(ctx, "", publicIpPrefixName)
 	if err != nil {
 		if isErrorNotFound(err) {
 			// resource does not exist, directly return
@@ -501,14 +699,14 @@ func (r *GatewayVMConfigurationReconciler) ensurePublicIPPrefixDeleted(
 			if err != nil {
 				return fmt.Errorf("failed to parse managed public ip prefix(%s): %w", pipID, err)
 			}
-			if err = r.DeletePublicIP(ctx, "", resource.Name); err != nil && !isErrorNotFound(err) {
+			if err = az.DeletePublicIP(ctx, "", resource.Name); err != nil && !isErrorNotFound(err) {
 				return fmt.Errorf("failed to delete managed public ip prefix(%s): %w", pipID, err)
 			}
 		}
 	}
 
 	log.Info("Deleting managed public ip prefix", "public ip prefix name", publicIpPrefixName)
-	if err := r.DeletePublicIPPrefix(ctx, "", publicIpPrefixName); err != nil {
+	if err := az.DeletePublicIPPrefix(ctx, "", publicIpPrefixName); err != nil {
 		return fmt.Errorf("failed to delete public ip prefix(%s): %w", publicIpPrefixName, err)
 	}
 	return nil
@@ -522,7 +720,7 @@ func (r *agentPoolVMSS) reconcileVMSS(
 	wantIPConfig bool,
 ) ([]string, error) {
 	log := log.FromContext(ctx)
-	ipConfigName := managedSubresourceName(vmConfig)
+	ipConfigNames := managedIPConfigNames(vmConfig)
 	vmssRG := getVMSSResourceGroup(vmConfig)
 	needUpdate := false
 
@@ -532,8 +730,13 @@ func (r *agentPoolVMSS) reconcileVMSS(
 	}
 
 	lbBackendpoolID := r.GetLBBackendAddressPoolID(to.Val(vmss.Properties.UniqueID))
+	modelBackendpoolID := to.Val(lbBackendpoolID)
+	if vmConfig.Spec.ZoneAware {
+		// zonal backend pools can only be assigned per instance
+		modelBackendpoolID = ""
+	}
 	interfaces := vmss.Properties.VirtualMachineProfile.NetworkProfile.NetworkInterfaceConfigurations
-	needUpdate, err := r.reconcileVMSSNetworkInterface(ctx, ipConfigName, ipPrefixID, to.Val(lbBackendpoolID), wantIPConfig, interfaces)
+	needUpdate, err := r.reconcileVMSSNetworkInterface(ctx, vmConfig, ipConfigNames, ipPrefixID, modelBackendpoolID, wantIPConfig, interfaces)
 	if err != nil {
 		return nil, fmt.Errorf("failed to reconcile vmss interface(%s): %w", to.Val(vmss.Name), err)
 	}
@@ -560,12 +763,23 @@ func (r *agentPoolVMSS) reconcileVMSS(
 		return nil, fmt.Errorf("failed to get vm instances from vmss(%s): %w", to.Val(vmss.Name), err)
 	}
 	for _, instance := range instances {
-		privateIP, err := r.reconcileVMSSVM(ctx, vmConfig, to.Val(vmss.Name), instance, ipPrefixID, to.Val(lbBackendpoolID), wantIPConfig)
+		instanceBackendpoolID := to.Val(lbBackendpoolID)
+		if vmConfig.Spec.ZoneAware {
+			zones := sortedZones(instance.Zones)
+			if len(zones) == 0 && wantIPConfig {
+				// the regional backend pool is not created in zone aware mode
+				return nil, fmt.Errorf("zoneAware is enabled but vmss(%s) instance(%s) is not deployed in any availability zone", to.Val(vmss.Name), to.Val(instance.InstanceID))
+			}
+			if len(zones) > 0 {
+				instanceBackendpoolID = to.Val(r.GetLBBackendAddressPoolID(zonalResourceName(to.Val(vmss.Properties.UniqueID), zones[0])))
+			}
+		}
+		ips, err := r.reconcileVMSSVM(ctx, vmConfig, to.Val(vmss.Name), instance, ipPrefixID, instanceBackendpoolID, wantIPConfig)
 		if err != nil {
 			return nil, err
 		}
 		if wantIPConfig && ipPrefixID == "" {
-			privateIPs = append(privateIPs, privateIP)
+			privateIPs = append(privateIPs, ips...)
 		}
 	}
 	// clean up VMProfiles for deleted nodes
@@ -599,17 +813,17 @@ func (r *agentPoolVMSS) reconcileVMSSVM(
 	ipPrefixID string,
 	lbBackendpoolID string,
 	wantIPConfig bool,
-) (string, error) {
+) ([]string, error) {
 	logger := log.FromContext(ctx).WithValues("vmssInstance", to.Val(vm.ID), "wantIPConfig", wantIPConfig, "ipPrefixID", ipPrefixID)
 	ctx = log.IntoContext(ctx, logger)
-	ipConfigName := managedSubresourceName(vmConfig)
+	ipConfigNames := managedIPConfigNames(vmConfig)
 	vmssRG := getVMSSResourceGroup(vmConfig)
 
 	if vm.Properties == nil || vm.Properties.NetworkProfileConfiguration == nil {
-		return "", fmt.Errorf("vmss vm(%s) has empty network profile", to.Val(vm.InstanceID))
+		return nil, fmt.Errorf("vmss vm(%s) has empty network profile", to.Val(vm.InstanceID))
 	}
 	if vm.Properties.OSProfile == nil {
-		return "", fmt.Errorf("vmss vm(%s) has empty os profile", to.Val(vm.InstanceID))
+		return nil, fmt.Errorf("vmss vm(%s) has empty os profile", to.Val(vm.InstanceID))
 	}
 
 	forceUpdate := false
@@ -622,8 +836,8 @@ func (r *agentPoolVMSS) reconcileVMSSVM(
 		}
 	}
 
-	// check primary IP & secondary IP
-	var primaryIP, secondaryIP string
+	// check primary IP & secondary IPs
+	var ipCfg gatewayIPConfig
 	if !forceUpdate && wantIPConfig {
 		for _, nic := range vm.Properties.NetworkProfileConfiguration.NetworkInterfaceConfigurations {
 			if nic.Properties != nil && to.Val(nic.Properties.Primary) {
@@ -636,25 +850,19 @@ func (r *agentPoolVMSS) reconcileVMSSVM(
 					}
 					break
 				}
-				for _, ipConfig := range vmNic.Properties.IPConfigurations {
-					if ipConfig != nil && ipConfig.Properties != nil && strings.EqualFold(to.Val(ipConfig.Name), ipConfigName) {
-						secondaryIP = to.Val(ipConfig.Properties.PrivateIPAddress)
-					} else if ipConfig != nil && ipConfig.Properties != nil && to.Val(ipConfig.Properties.Primary) {
-						primaryIP = to.Val(ipConfig.Properties.PrivateIPAddress)
-					}
-				}
+				ipCfg = getGatewayIPConfig(vmNic.Properties.IPConfigurations, ipConfigNames)
 			}
 		}
-		if primaryIP == "" || secondaryIP == "" {
+		if !ipCfg.complete() {
 			forceUpdate = true
-			logger.Info("Force update for missing primary IP and/or secondary IP", "primaryIP", primaryIP, "secondaryIP", secondaryIP)
+			logger.Info("Force update for missing primary IP and/or secondary IP", "primaryIP", ipCfg.primaryIP, "secondaryIPs", ipCfg.secondaryIPs)
 		}
 	}
 
 	interfaces := vm.Properties.NetworkProfileConfiguration.NetworkInterfaceConfigurations
-	needUpdate, err := r.reconcileVMSSNetworkInterface(ctx, ipConfigName, ipPrefixID, lbBackendpoolID, wantIPConfig, interfaces)
+	needUpdate, err := r.reconcileVMSSNetworkInterface(ctx, vmConfig, ipConfigNames, ipPrefixID, lbBackendpoolID, wantIPConfig, interfaces)
 	if err != nil {
-		return "", fmt.Errorf("failed to reconcile vm interface(%s): %w", to.Val(vm.InstanceID), err)
+		return nil, fmt.Errorf("failed to reconcile vm interface(%s): %w", to.Val(vm.InstanceID), err)
 	}
 	vmUpdated := false
 	if needUpdate || forceUpdate {
@@ -670,115 +878,106 @@ func (r *agentPoolVMSS) reconcileVMSSVM(
 			},
 		}
 		if _, err := r.UpdateVMSSInstance(ctx, vmssRG, vmssName, to.Val(vm.InstanceID), newVM); err != nil {
-			return "", fmt.Errorf("failed to update vmss instance(%s): %w", to.Val(vm.InstanceID), err)
+			return nil, fmt.Errorf("failed to update vmss instance(%s): %w", to.Val(vm.InstanceID), err)
 		}
 		vmUpdated = true
 	}
 
 	// return earlier if it's deleting event
 	if !wantIPConfig {
-		return "", nil
+		return nil, nil
 	}
 
-	if vmUpdated || primaryIP == "" || secondaryIP == "" {
-		primaryIP, secondaryIP = "", ""
+	if vmUpdated || !ipCfg.complete() {
+		ipCfg = gatewayIPConfig{}
 		for _, nic := range interfaces {
 			if nic.Properties != nil && to.Val(nic.Properties.Primary) {
 				vmNic, err := r.GetVMSSInterface(ctx, vmssRG, vmssName, to.Val(vm.InstanceID), to.Val(nic.Name))
 				if err != nil {
-					return "", fmt.Errorf("failed to get vmss(%s) instance(%s) nic(%s): %w", vmssName, to.Val(vm.InstanceID), to.Val(nic.Name), err)
+					return nil, fmt.Errorf("failed to get vmss(%s) instance(%s) nic(%s): %w", vmssName, to.Val(vm.InstanceID), to.Val(nic.Name), err)
 				}
 				if vmNic.Properties == nil || vmNic.Properties.IPConfigurations == nil {
-					return "", fmt.Errorf("vmss(%s) instance(%s) nic(%s) has empty ip configurations", vmssName, to.Val(vm.InstanceID), to.Val(nic.Name))
-				}
-				for _, ipConfig := range vmNic.Properties.IPConfigurations {
-					if ipConfig != nil && ipConfig.Properties != nil && strings.EqualFold(to.Val(ipConfig.Name), ipConfigName) {
-						secondaryIP = to.Val(ipConfig.Properties.PrivateIPAddress)
-					} else if ipConfig != nil && ipConfig.Properties != nil && to.Val(ipConfig.Properties.Primary) {
-						primaryIP = to.Val(ipConfig.Properties.PrivateIPAddress)
-					}
+					return nil, fmt.Errorf("vmss(%s) instance(%s) nic(%s) has empty ip configurations", vmssName, to.Val(vm.InstanceID), to.Val(nic.Name))
 				}
+				ipCfg = getGatewayIPConfig(vmNic.Properties.IPConfigurations, ipConfigNames)
 			}
 		}
 	}
-	if primaryIP == "" || secondaryIP == "" {
-		return "", fmt.Errorf("failed to find private IP from vmss(%s), instance(%s), ipConfig(%s)", vmssName, to.Val(vm.InstanceID), ipConfigName)
-	}
-
-	vmprofile := egressgatewayv1alpha1.GatewayVMProfile{
-		NodeName:    to.Val(vm.Properties.OSProfile.ComputerName),
-		PrimaryIP:   primaryIP,
-		SecondaryIP: secondaryIP,
-	}
-	if vmConfig.Status == nil {
-		vmConfig.Status = &egressgatewayv1alpha1.GatewayVMConfigurationStatus{}
+	if !ipCfg.complete() {
+		return nil, fmt.Errorf("failed to find private IPs from vmss(%s), instance(%s), ipConfigs(%v)", vmssName, to.Val(vm.InstanceID), ipConfigNames)
 	}
-	for i, profile := range vmConfig.Status.GatewayVMProfiles {
-		if profile.NodeName == vmprofile.NodeName {
-			if profile.PrimaryIP != primaryIP || profile.SecondaryIP != secondaryIP {
-				vmConfig.Status.GatewayVMProfiles[i].PrimaryIP = primaryIP
-				vmConfig.Status.GatewayVMProfiles[i].SecondaryIP = secondaryIP
-				logger.Info("GatewayVMConfiguration status updated", "primaryIP", primaryIP, "secondaryIP", secondaryIP)
-				return secondaryIP, nil
-			}
-			logger.Info("GatewayVMConfiguration status not changed", "primaryIP", primaryIP, "secondaryIP", secondaryIP)
-			return secondaryIP, nil
-		}
+	if err := resolveDedicatedPublicIPs(ctx, r.AzureManager, vmConfig, &ipCfg); err != nil {
+		return nil, err
 	}
-
-	logger.Info("GatewayVMConfiguration status updated for new nodes", "nodeName", vmprofile.NodeName, "primaryIP", primaryIP, "secondaryIP", secondaryIP)
-	vmConfig.Status.GatewayVMProfiles = append(vmConfig.Status.GatewayVMProfiles, vmprofile)
-
-	return secondaryIP, nil
+	setGatewayVMProfile(ctx, vmConfig, to.Val(vm.Properties.OSProfile.ComputerName), ipCfg)
+	return ipCfg.secondaryIPs, nil
 }
 
 func (r *agentPoolVMSS) reconcileVMSSNetworkInterface(
 	ctx context.Context,
-	ipConfigName string,
+	vmConfig *egressgatewayv1alpha1.GatewayVMConfiguration,
+	ipConfigNames []string,
 	ipPrefixID string,
 	lbBackendpoolID string,
 	wantIPConfig bool,
 	interfaces []*compute.VirtualMachineScaleSetNetworkConfiguration,
 ) (bool, error) {
 	log := log.FromContext(ctx)
-	expectedConfig := r.getExpectedIPConfig(ipConfigName, ipPrefixID, interfaces)
+	expectedConfigs := make(map[string]*compute.VirtualMachineScaleSetIPConfiguration, len(ipConfigNames))
+	for _, ipConfigName := range ipConfigNames {
+		expectedConfigs[strings.ToLower(ipConfigName)] = r.getExpectedIPConfig(ipConfigName, ipPrefixID, interfaces)
+	}
 	var primaryNic *compute.VirtualMachineScaleSetNetworkConfiguration
 	needUpdate := false
-	foundConfig := false
+	foundConfigs := make(map[string]bool, len(ipConfigNames))
 
 	for _, nic := range interfaces {
 		if nic.Properties != nil && to.Val(nic.Properties.Primary) {
 			primaryNic = nic
-			for i, ipConfig := range nic.Properties.IPConfigurations {
-				if to.Val(ipConfig.Name) == ipConfigName {
-					if !wantIPConfig {
-						log.Info("Found unwanted ipConfig, dropping")
-						nic.Properties.IPConfigurations = append(nic.Properties.IPConfigurations[:i], nic.Properties.IPConfigurations[i+1:]...)
-						needUpdate = true
-					} else {
-						if different(ipConfig, expectedConfig) {
-							log.Info("Found target ipConfig with different configurations, dropping")
-							needUpdate = true
-							nic.Properties.IPConfigurations = append(nic.Properties.IPConfigurations[:i], nic.Properties.IPConfigurations[i+1:]...)
-						} else {
-							log.Info("Found expected ipConfig, keeping")
-							foundConfig = true
-						}
-					}
-					break
+			ipConfigs := make([]*compute.VirtualMachineScaleSetIPConfiguration, 0, len(nic.Properties.IPConfigurations))
+			for _, ipConfig := range nic.Properties.IPConfigurations {
+				if !isManagedIPConfig(vmConfig, to.Val(ipConfig.Name)) {
+					ipConfigs = append(ipConfigs, ipConfig)
+					continue
+				}
+				name := strings.ToLower(to.Val(ipConfig.Name))
+				expectedConfig, ok := expectedConfigs[name]
+				switch {
+				case !wantIPConfig || !ok:
+					log.Info("Found unwanted ipConfig, dropping", "ipConfig", to.Val(ipConfig.Name))
+					needUpdate = true
+				case different(ipConfig, expectedConfig):
+					log.Info("Found target ipConfig with different configurations, dropping", "ipConfig", to.Val(ipConfig.Name))
+					needUpdate = true
+				default:
+					log.Info("Found expected ipConfig, keeping", "ipConfig", to.Val(ipConfig.Name))
+					foundConfigs[name] = true
+					ipConfigs = append(ipConfigs, ipConfig)
 				}
 			}
+			nic.Properties.IPConfigurations = ipConfigs
 		}
 	}
 
-	if wantIPConfig && !foundConfig {
+	for _, ipConfigName := range ipConfigNames {
+		if !wantIPConfig || foundConfigs[strings.ToLower(ipConfigName)] {
+			continue
+		}
 		if primaryNic == nil {
 			return false, fmt.Errorf("vmss(vm) primary network interface not found")
 		}
-		primaryNic.Properties.IPConfigurations = append(primaryNic.Properties.IPConfigurations, expectedConfig)
+		primaryNic.Properties.IPConfigurations = append(primaryNic.Properties.IPConfigurations, expectedConfigs[strings.ToLower(ipConfigName)])
 		needUpdate = true
 	}
 
+	if lbBackendpoolID == "" {
+		// backend pool is not managed at this level
+		if primaryNic == nil {
+			return false, fmt.Errorf("vmss(vm) primary network interface not found")
+		}
+		return needUpdate, nil
+	}
+
 	changed, err := r.reconcileLbBackendPool(lbBackendpoolID, primaryNic)
 	if err != nil {
 		return false, err
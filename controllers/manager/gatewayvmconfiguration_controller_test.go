@@ -345,6 +345,77 @@ var _ = Describe("GatewayVMConfiguration controller unit tests", func() {
 				_, _, _, err := r.ensurePublicIPPrefix(context.TODO(), 31, vmConfig)
 				Expect(errors.Unwrap(err)).To(Equal(fmt.Errorf("failed")))
 			})
+
+			When("public ip prefix is claimed from an EgressIPPool", func() {
+				var pool *egressgatewayv1alpha1.EgressIPPool
+
+				BeforeEach(func() {
+					vmConfig.Spec.EgressIpPoolClaim = &egressgatewayv1alpha1.EgressIPPoolClaim{PoolName: "pool"}
+					pool = &egressgatewayv1alpha1.EgressIPPool{
+						ObjectMeta: metav1.ObjectMeta{Name: "pool"},
+						Status: egressgatewayv1alpha1.EgressIPPoolStatus{
+							Prefixes: []egressgatewayv1alpha1.EgressIPPoolPrefixStatus{
+								{Name: "taken", PublicIpPrefixId: "taken", IpPrefix: "1.2.3.0/31", PrefixLength: 31, ClaimedBy: "other/gw", ReclaimPolicy: egressgatewayv1alpha1.EgressIPReclaimRetain},
+								{Name: "short", PublicIpPrefixId: "short", IpPrefix: "1.2.4.0/30", PrefixLength: 30},
+								{Name: "free", PublicIpPrefixId: "free", IpPrefix: "1.2.5.0/31", PrefixLength: 31},
+							},
+						},
+					}
+				})
+
+				claimedBy := func(name string) string {
+					found := &egressgatewayv1alpha1.EgressIPPool{}
+					Expect(r.Get(context.TODO(), types.NamespacedName{Name: "pool"}, found)).To(Succeed())
+					for _, prefix := range found.Status.Prefixes {
+						if prefix.Name == name {
+							return prefix.ClaimedBy
+						}
+					}
+					return ""
+				}
+
+				It("should claim a free prefix of the required length", func() {
+					r.Client = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithStatusSubresource(pool).WithObjects(pool).Build()
+					foundPrefix, prefixID, isManaged, err := r.ensurePublicIPPrefix(context.TODO(), 31, vmConfig)
+					Expect(err).To(BeNil())
+					Expect(foundPrefix).To(Equal("1.2.5.0/31"))
+					Expect(prefixID).To(Equal("free"))
+					Expect(isManaged).To(BeFalse())
+					Expect(claimedBy("free")).To(Equal(testNamespace + "/" + testName))
+
+					By("keeping the claimed prefix")
+					foundPrefix, _, _, err = r.ensurePublicIPPrefix(context.TODO(), 31, vmConfig)
+					Expect(err).To(BeNil())
+					Expect(foundPrefix).To(Equal("1.2.5.0/31"))
+				})
+
+				It("should return error if the pool has no free prefix of the required length", func() {
+					pool.Status.Prefixes = pool.Status.Prefixes[:2]
+					r.Client = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithStatusSubresource(pool).WithObjects(pool).Build()
+					_, _, _, err := r.ensurePublicIPPrefix(context.TODO(), 31, vmConfig)
+					Expect(err).To(Equal(fmt.Errorf("EgressIPPool(pool) has no free public ip prefix of length 31")))
+				})
+
+				It("should return error if the pool is not found", func() {
+					r.Client = fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+					_, _, _, err := r.ensurePublicIPPrefix(context.TODO(), 31, vmConfig)
+					Expect(apierrors.IsNotFound(errors.Unwrap(err))).To(BeTrue())
+				})
+
+				It("should release claims with Delete reclaim policy only", func() {
+					pool.Status.Prefixes[0].ClaimedBy = testNamespace + "/" + testName
+					pool.Status.Prefixes[2].ClaimedBy = testNamespace + "/" + testName
+					pool.Status.Prefixes[2].ReclaimPolicy = egressgatewayv1alpha1.EgressIPReclaimDelete
+					r.Client = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithStatusSubresource(pool).WithObjects(pool).Build()
+
+					Expect(releasePoolClaims(context.TODO(), r.Client, testNamespace+"/"+testName, "pool")).To(Succeed())
+					Expect(claimedBy("free")).To(Equal(testNamespace + "/" + testName))
+
+					Expect(releasePoolClaims(context.TODO(), r.Client, testNamespace+"/"+testName, "")).To(Succeed())
+					Expect(claimedBy("free")).To(BeEmpty())
+					Expect(claimedBy("taken")).To(Equal(testNamespace + "/" + testName))
+				})
+			})
 		})
 
 		Context("TestEnsurePublicIPPrefixDeleted", func() {
@@ -670,6 +741,19 @@ var _ = Describe("GatewayVMConfiguration controller unit tests", func() {
 				Expect(err).To(Equal(fmt.Errorf("vmss vm(0) has empty network profile")))
 			})
 
+			It("should return error if vmss instance is not zonal when zoneAware is enabled", func() {
+				vmConfig.Spec.ZoneAware = true
+				existingVMSS := getEmptyVMSS()
+				expectedVMSS := getConfiguredVMSS()
+				mockVMSSClient := az.VmssClient.(*mock_virtualmachinescalesetclient.MockInterface)
+				mockVMSSClient.EXPECT().CreateOrUpdate(gomock.Any(), vmssRG, vmssName, gomock.Any()).Return(expectedVMSS, nil)
+				mockVMSSVMClient := az.VmssVMClient.(*mock_virtualmachinescalesetvmclient.MockInterface)
+				vms := []*compute.VirtualMachineScaleSetVM{{InstanceID: to.Ptr("0")}}
+				mockVMSSVMClient.EXPECT().List(gomock.Any(), vmssRG, vmssName).Return(vms, nil)
+				_, err := poolVMSS.reconcileVMSS(context.TODO(), vmConfig, existingVMSS, "prefix", true)
+				Expect(err).To(Equal(fmt.Errorf("zoneAware is enabled but vmss(%s) instance(0) is not deployed in any availability zone", vmssName)))
+			})
+
 			It("should return error if vmss instance has empty os profile", func() {
 				existingVMSS := getEmptyVMSS()
 				expectedVMSS := getConfiguredVMSS()
@@ -1212,6 +1296,167 @@ var _ = Describe("GatewayVMConfiguration controller unit tests", func() {
 				Expect(ips[0]).To(Equal("10.0.0.2"))
 			})
 
+			It("should add one ipconfig and public IP per sNAT IP", func() {
+				vmConfig.Spec.SnatIpCount = 2
+				nics := []*network.Interface{
+					{
+						Name: to.Ptr("gateway-nic"),
+						Tags: map[string]*string{
+							consts.AKSStaticGatewayNICTagKey: to.Ptr("true"),
+							consts.AKSNodepoolTagKey:         to.Ptr("testgw"),
+						},
+						Properties: &network.InterfacePropertiesFormat{
+							IPConfigurations: []*network.InterfaceIPConfiguration{
+								{
+									Name: to.Ptr("ipconfig1"),
+									Properties: &network.InterfaceIPConfigurationPropertiesFormat{
+										Primary:          to.Ptr(true),
+										PrivateIPAddress: to.Ptr("10.0.0.1"),
+										Subnet: &network.Subnet{
+											ID: to.Ptr("/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Network/virtualNetworks/vnet/subnets/subnet"),
+										},
+									},
+								},
+							},
+						},
+					},
+				}
+				mockInterfaceClient := az.InterfaceClient.(*mock_interfaceclient.MockInterface)
+				mockInterfaceClient.EXPECT().List(gomock.Any(), testRG).Return(nics, nil)
+				mockPublicIPClient := az.PublicIPClient.(*mock_publicipaddressclient.MockInterface)
+				for i, key := range []string{"gateway-nic", "gateway-nic-1"} {
+					pipName, err := poolVMs.buildPublicIPName(publicIPPrefixResourceID, key)
+					Expect(err).NotTo(HaveOccurred())
+					mockPublicIPClient.EXPECT().CreateOrUpdate(gomock.Any(), testRG, pipName, gomock.Any()).Return(&network.PublicIPAddress{
+						Properties: &network.PublicIPAddressPropertiesFormat{
+							IPAddress: to.Ptr(fmt.Sprintf("1.2.3.%d", 4+i)),
+						},
+					}, nil)
+				}
+				mockInterfaceClient.EXPECT().CreateOrUpdate(gomock.Any(), testRG, "gateway-nic", gomock.Any()).DoAndReturn(
+					func(ctx context.Context, rg, name string, nic network.Interface) (*network.Interface, error) {
+						Expect(len(nic.Properties.IPConfigurations)).To(Equal(3))
+						Expect(*nic.Properties.IPConfigurations[1].Name).To(Equal("egressgateway-testUID"))
+						Expect(*nic.Properties.IPConfigurations[1].Properties.PublicIPAddress.Properties.IPAddress).To(Equal("1.2.3.4"))
+						Expect(*nic.Properties.IPConfigurations[2].Name).To(Equal("egressgateway-testUID-1"))
+						Expect(*nic.Properties.IPConfigurations[2].Properties.PublicIPAddress.Properties.IPAddress).To(Equal("1.2.3.5"))
+
+						nic.Properties.IPConfigurations[1].Properties.PrivateIPAddress = to.Ptr("10.0.0.2")
+						nic.Properties.IPConfigurations[2].Properties.PrivateIPAddress = to.Ptr("10.0.0.3")
+						return &nic, nil
+					})
+				ips, err := poolVMs.Reconcile(context.Background(), vmConfig, publicIPPrefixResourceID, true)
+				Expect(err).To(BeNil())
+				Expect(ips).To(Equal([]string{"10.0.0.2", "10.0.0.3"}))
+				Expect(vmConfig.Status.GatewayVMProfiles).To(Equal([]egressgatewayv1alpha1.GatewayVMProfile{
+					{NodeName: "gateway-nic", PrimaryIP: "10.0.0.1", SecondaryIP: "10.0.0.2", SecondaryIPs: []string{"10.0.0.2", "10.0.0.3"}},
+				}))
+			})
+
+			It("should add one ipconfig and public IP per dedicated egress IP", func() {
+				vmConfig.Spec.DedicatedEgressIps = []string{"partner-a"}
+				nics := []*network.Interface{
+					{
+						Name: to.Ptr("gateway-nic"),
+						Tags: map[string]*string{
+							consts.AKSStaticGatewayNICTagKey: to.Ptr("true"),
+							consts.AKSNodepoolTagKey:         to.Ptr("testgw"),
+						},
+						Properties: &network.InterfacePropertiesFormat{
+							IPConfigurations: []*network.InterfaceIPConfiguration{
+								{
+									Name: to.Ptr("ipconfig1"),
+									Properties: &network.InterfaceIPConfigurationPropertiesFormat{
+										Primary:          to.Ptr(true),
+										PrivateIPAddress: to.Ptr("10.0.0.1"),
+										Subnet: &network.Subnet{
+											ID: to.Ptr("/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Network/virtualNetworks/vnet/subnets/subnet"),
+										},
+									},
+								},
+							},
+						},
+					},
+				}
+				mockInterfaceClient := az.InterfaceClient.(*mock_interfaceclient.MockInterface)
+				mockInterfaceClient.EXPECT().List(gomock.Any(), testRG).Return(nics, nil)
+				mockPublicIPClient := az.PublicIPClient.(*mock_publicipaddressclient.MockInterface)
+				for i, key := range []string{"gateway-nic", "gateway-nic-partner-a"} {
+					pipName, err := poolVMs.buildPublicIPName(publicIPPrefixResourceID, key)
+					Expect(err).NotTo(HaveOccurred())
+					mockPublicIPClient.EXPECT().CreateOrUpdate(gomock.Any(), testRG, pipName, gomock.Any()).Return(&network.PublicIPAddress{
+						Properties: &network.PublicIPAddressPropertiesFormat{
+							IPAddress: to.Ptr(fmt.Sprintf("1.2.3.%d", 4+i)),
+						},
+					}, nil)
+				}
+				mockInterfaceClient.EXPECT().CreateOrUpdate(gomock.Any(), testRG, "gateway-nic", gomock.Any()).DoAndReturn(
+					func(ctx context.Context, rg, name string, nic network.Interface) (*network.Interface, error) {
+						Expect(len(nic.Properties.IPConfigurations)).To(Equal(3))
+						Expect(*nic.Properties.IPConfigurations[1].Name).To(Equal("egressgateway-testUID"))
+						Expect(*nic.Properties.IPConfigurations[2].Name).To(Equal("egressgateway-testUID-partner-a"))
+						Expect(*nic.Properties.IPConfigurations[2].Properties.PublicIPAddress.Properties.IPAddress).To(Equal("1.2.3.5"))
+
+						nic.Properties.IPConfigurations[1].Properties.PrivateIPAddress = to.Ptr("10.0.0.2")
+						nic.Properties.IPConfigurations[2].Properties.PrivateIPAddress = to.Ptr("10.0.0.3")
+						return &nic, nil
+					})
+				ips, err := poolVMs.Reconcile(context.Background(), vmConfig, publicIPPrefixResourceID, true)
+				Expect(err).To(BeNil())
+				Expect(ips).To(Equal([]string{"10.0.0.2", "10.0.0.3"}))
+				Expect(vmConfig.Status.GatewayVMProfiles).To(Equal([]egressgatewayv1alpha1.GatewayVMProfile{
+					{
+						NodeName:     "gateway-nic",
+						PrimaryIP:    "10.0.0.1",
+						SecondaryIP:  "10.0.0.2",
+						DedicatedIPs: []egressgatewayv1alpha1.GatewayVMDedicatedIP{{Name: "partner-a", IP: "10.0.0.3", PublicIP: "1.2.3.5"}},
+					},
+				}))
+				Expect(dedicatedEgressIPStatus(vmConfig)).To(Equal([]egressgatewayv1alpha1.DedicatedEgressIpStatus{
+					{Name: "partner-a", PrivateIps: []string{"10.0.0.3"}, PublicIps: []string{"1.2.3.5"}},
+				}))
+			})
+
+			It("should get the public IPs of dedicated egress IPs not expanded in the NIC", func() {
+				vmConfig.Spec.DedicatedEgressIps = []string{"partner-a"}
+				pipID := "/subscriptions/testSub/resourceGroups/pipRG/providers/Microsoft.Network/publicIPAddresses/pip-partner-a"
+				ipCfg := getGatewayIPConfig([]*network.InterfaceIPConfiguration{
+					{
+						Name: to.Ptr("ipconfig1"),
+						Properties: &network.InterfaceIPConfigurationPropertiesFormat{
+							Primary:          to.Ptr(true),
+							PrivateIPAddress: to.Ptr("10.0.0.1"),
+						},
+					},
+					{
+						Name: to.Ptr("egressgateway-testUID"),
+						Properties: &network.InterfaceIPConfigurationPropertiesFormat{
+							PrivateIPAddress: to.Ptr("10.0.0.2"),
+							PublicIPAddress:  &network.PublicIPAddress{ID: to.Ptr("/subscriptions/testSub/resourceGroups/pipRG/providers/Microsoft.Network/publicIPAddresses/pip")},
+						},
+					},
+					{
+						Name: to.Ptr("egressgateway-testUID-partner-a"),
+						Properties: &network.InterfaceIPConfigurationPropertiesFormat{
+							PrivateIPAddress: to.Ptr("10.0.0.3"),
+							PublicIPAddress:  &network.PublicIPAddress{ID: to.Ptr(pipID)},
+						},
+					},
+				}, managedIPConfigNames(vmConfig))
+				mockPublicIPClient := az.PublicIPClient.(*mock_publicipaddressclient.MockInterface)
+				mockPublicIPClient.EXPECT().Get(gomock.Any(), "pipRG", "pip-partner-a", gomock.Any()).Return(&network.PublicIPAddress{
+					ID: to.Ptr(pipID),
+					Properties: &network.PublicIPAddressPropertiesFormat{
+						IPAddress: to.Ptr("1.2.3.5"),
+					},
+				}, nil)
+				Expect(resolveDedicatedPublicIPs(context.Background(), az, vmConfig, &ipCfg)).To(Succeed())
+				setGatewayVMProfile(context.Background(), vmConfig, "gateway-nic", ipCfg)
+				Expect(dedicatedEgressIPStatus(vmConfig)).To(Equal([]egressgatewayv1alpha1.DedicatedEgressIpStatus{
+					{Name: "partner-a", PrivateIps: []string{"10.0.0.3"}, PublicIps: []string{"1.2.3.5"}},
+				}))
+			})
+
 			It("should remove IP configuration when wantIPConfig is false", func() {
 				nics := []*network.Interface{
 					{
@@ -1258,6 +1503,36 @@ var _ = Describe("GatewayVMConfiguration controller unit tests", func() {
 				Expect(len(ips)).To(Equal(1))
 			})
 
+			It("should return error when gateway VM is not zonal and zoneAware is enabled", func() {
+				vmConfig.Spec.ZoneAware = true
+				vmID := "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Compute/virtualMachines/gateway-vm"
+				mockVMClient := az.VMClient.(*mock_virtualmachineclient.MockInterface)
+				mockVMClient.EXPECT().List(gomock.Any(), testRG).Return([]*compute.VirtualMachine{
+					{
+						ID:   to.Ptr(vmID),
+						Tags: map[string]*string{consts.AKSNodepoolTagKey: to.Ptr("testgw")},
+					},
+				}, nil)
+				nics := []*network.Interface{
+					{
+						Name: to.Ptr("gateway-nic"),
+						ID:   to.Ptr("/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Network/networkInterfaces/gateway-nic"),
+						Tags: map[string]*string{
+							consts.AKSStaticGatewayNICTagKey: to.Ptr("true"),
+							consts.AKSNodepoolTagKey:         to.Ptr("testgw"),
+						},
+						Properties: &network.InterfacePropertiesFormat{
+							VirtualMachine: &network.SubResource{ID: to.Ptr(vmID)},
+						},
+					},
+				}
+				mockInterfaceClient := az.InterfaceClient.(*mock_interfaceclient.MockInterface)
+				mockInterfaceClient.EXPECT().List(gomock.Any(), testRG).Return(nics, nil)
+
+				_, err := poolVMs.Reconcile(context.Background(), vmConfig, "prefix", true)
+				Expect(err).To(MatchError("zoneAware is enabled but gateway VM of nic(/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Network/networkInterfaces/gateway-nic) is not deployed in any availability zone"))
+			})
+
 			It("should return error when NIC has no subnet ID", func() {
 				nics := []*network.Interface{
 					{
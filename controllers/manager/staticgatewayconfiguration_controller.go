@@ -7,6 +7,8 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"regexp"
+	"sort"
 	"strings"
 
 	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
@@ -47,6 +49,7 @@ type StaticGatewayConfigurationReconciler struct {
 //+kubebuilder:rbac:groups=egressgateway.kubernetes.azure.com,resources=staticgatewayconfigurations/finalizers,verbs=update
 //+kubebuilder:rbac:groups=egressgateway.kubernetes.azure.com,resources=gatewaylbconfigurations,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=egressgateway.kubernetes.azure.com,resources=gatewaylbconfigurations/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=egressgateway.kubernetes.azure.com,resources=podendpoints,verbs=get;list;watch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -94,9 +97,28 @@ func (r *StaticGatewayConfigurationReconciler) SetupWithManager(mgr ctrl.Manager
 		Owns(&egressgatewayv1alpha1.GatewayLBConfiguration{}).
 		// generated secrets created in the dedicated namespace
 		Watches(&corev1.Secret{}, enqueueOwningSGCFromLabels(), builder.WithPredicates(secretPredicate)).
+		// pods using the dedicated egress IPs are reported in the status
+		Watches(&egressgatewayv1alpha1.PodEndpoint{}, enqueueSGCFromPodEndpoint()).
 		Complete(r)
 }
 
+func enqueueSGCFromPodEndpoint() handler.EventHandler {
+	return handler.EnqueueRequestsFromMapFunc(func(_ context.Context, o client.Object) []reconcile.Request {
+		podEndpoint, ok := o.(*egressgatewayv1alpha1.PodEndpoint)
+		if !ok || podEndpoint.Spec.StaticGatewayConfiguration == "" {
+			return nil
+		}
+		return []reconcile.Request{
+			{
+				NamespacedName: client.ObjectKey{
+					Name:      podEndpoint.Spec.StaticGatewayConfiguration,
+					Namespace: podEndpoint.Namespace,
+				},
+			},
+		}
+	})
+}
+
 func enqueueOwningSGCFromLabels() handler.EventHandler {
 	return handler.EnqueueRequestsFromMapFunc(func(_ context.Context, o client.Object) []reconcile.Request {
 		labels := o.GetLabels()
@@ -252,6 +274,8 @@ func (r *StaticGatewayConfigurationReconciler) ensureDeleted(
 	return nil
 }
 
+var dedicatedEgressIPNameRegexp = regexp.MustCompile(`^[a-z]([-a-z0-9]*[a-z0-9])?$`)
+
 func validate(gwConfig *egressgatewayv1alpha1.StaticGatewayConfiguration) error {
 	// need to validate either GatewayNodepoolName or GatewayVmssProfile is provided, but not both
 	var allErrs field.ErrorList
@@ -292,6 +316,59 @@ func validate(gwConfig *egressgatewayv1alpha1.StaticGatewayConfiguration) error
 			"PublicIpPrefixId should be empty when ProvisionPublicIps is false"))
 	}
 
+	if gwConfig.Spec.SnatIpCount < 0 || gwConfig.Spec.SnatIpCount > 16 {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("spec").Child("snatipcount"),
+			gwConfig.Spec.SnatIpCount,
+			"SnatIpCount should be between 1 and 16 inclusively"))
+	}
+
+	if size := gwConfig.Spec.SnatPortBlockSize; size != 0 && (size < 64 || size > 32768) {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("spec").Child("snatportblocksize"),
+			size,
+			"SnatPortBlockSize should be 0 or between 64 and 32768 inclusively"))
+	}
+
+	namespaceDefaults := 0
+	dedicatedNames := make(map[string]bool)
+	for i, dedicated := range gwConfig.Spec.DedicatedEgressIps {
+		path := field.NewPath("spec").Child("dedicatedegressips").Index(i)
+		if len(dedicated.Name) > 20 || !dedicatedEgressIPNameRegexp.MatchString(dedicated.Name) {
+			allErrs = append(allErrs, field.Invalid(path.Child("name"),
+				dedicated.Name,
+				"DedicatedEgressIp name should be a lowercase DNS label of at most 20 characters starting with a letter"))
+		}
+		if dedicatedNames[dedicated.Name] {
+			allErrs = append(allErrs, field.Duplicate(path.Child("name"), dedicated.Name))
+		}
+		dedicatedNames[dedicated.Name] = true
+		if dedicated.NamespaceDefault {
+			namespaceDefaults++
+		}
+	}
+	if namespaceDefaults > 1 {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("spec").Child("dedicatedegressips"),
+			namespaceDefaults,
+			"At most one DedicatedEgressIp can be the namespace default"))
+	}
+
+	if claim := gwConfig.Spec.EgressIpPoolClaim; claim != nil {
+		if !gwConfig.Spec.ProvisionPublicIps {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("spec").Child("egressippoolclaim"),
+				claim.PoolName,
+				"EgressIpPoolClaim should be empty when ProvisionPublicIps is false"))
+		}
+		if gwConfig.Spec.PublicIpPrefixId != "" {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("spec").Child("egressippoolclaim"),
+				claim.PoolName,
+				"EgressIpPoolClaim and PublicIpPrefixId cannot be specified at the same time"))
+		}
+		if claim.PoolName == "" {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("spec").Child("egressippoolclaim").Child("poolname"),
+				claim.PoolName,
+				"EgressIpPoolClaim pool name is empty"))
+		}
+	}
+
 	if len(allErrs) == 0 {
 		return nil
 	}
@@ -383,6 +460,13 @@ func (r *StaticGatewayConfigurationReconciler) reconcileGatewayLBConfig(
 		lbConfig.Spec.GatewayVmssProfile = gwConfig.Spec.GatewayVmssProfile
 		lbConfig.Spec.ProvisionPublicIps = gwConfig.Spec.ProvisionPublicIps
 		lbConfig.Spec.PublicIpPrefixId = gwConfig.Spec.PublicIpPrefixId
+		lbConfig.Spec.EgressIpPoolClaim = gwConfig.Spec.EgressIpPoolClaim
+		lbConfig.Spec.SnatIpCount = gwConfig.Spec.SnatIpCount
+		lbConfig.Spec.DedicatedEgressIps = nil
+		for _, dedicated := range gwConfig.Spec.DedicatedEgressIps {
+			lbConfig.Spec.DedicatedEgressIps = append(lbConfig.Spec.DedicatedEgressIps, dedicated.Name)
+		}
+		lbConfig.Spec.ZoneAware = gwConfig.Spec.ZoneAware
 		return controllerutil.SetControllerReference(gwConfig, lbConfig, r.Client.Scheme())
 	}); err != nil {
 		log.Error(err, "failed to reconcile gateway lb configuration")
@@ -392,7 +476,57 @@ func (r *StaticGatewayConfigurationReconciler) reconcileGatewayLBConfig(
 		gwConfig.Status.Ip = lbConfig.Status.FrontendIp
 		gwConfig.Status.Port = lbConfig.Status.ServerPort
 		gwConfig.Status.EgressIpPrefix = lbConfig.Status.EgressIpPrefix
+		gwConfig.Status.ZonalFrontends = lbConfig.Status.ZonalFrontends
+		dedicatedEgressIps, err := r.dedicatedEgressIPStatus(ctx, gwConfig, lbConfig.Status.DedicatedEgressIps)
+		if err != nil {
+			log.Error(err, "failed to list pods using dedicated egress IPs")
+			return err
+		}
+		gwConfig.Status.DedicatedEgressIps = dedicatedEgressIps
 	}
 
 	return nil
 }
+
+// dedicatedEgressIPStatus adds the pods using each dedicated egress IP, through the pod annotation or as the
+// namespace default, to the dedicated egress IPs reported by the lbConfig.
+func (r *StaticGatewayConfigurationReconciler) dedicatedEgressIPStatus(
+	ctx context.Context,
+	gwConfig *egressgatewayv1alpha1.StaticGatewayConfiguration,
+	dedicatedEgressIps []egressgatewayv1alpha1.DedicatedEgressIpStatus,
+) ([]egressgatewayv1alpha1.DedicatedEgressIpStatus, error) {
+	if len(dedicatedEgressIps) == 0 {
+		return nil, nil
+	}
+	namespaceDefault := ""
+	for _, dedicated := range gwConfig.Spec.DedicatedEgressIps {
+		if dedicated.NamespaceDefault {
+			namespaceDefault = dedicated.Name
+		}
+	}
+
+	podEndpoints := &egressgatewayv1alpha1.PodEndpointList{}
+	if err := r.List(ctx, podEndpoints, client.InNamespace(gwConfig.Namespace)); err != nil {
+		return nil, err
+	}
+	pods := make(map[string][]string)
+	for _, podEndpoint := range podEndpoints.Items {
+		if podEndpoint.Spec.StaticGatewayConfiguration != gwConfig.Name {
+			continue
+		}
+		name := podEndpoint.Spec.DedicatedEgressIp
+		if name == "" {
+			name = namespaceDefault
+		}
+		pods[name] = append(pods[name], podEndpoint.Name)
+	}
+
+	result := make([]egressgatewayv1alpha1.DedicatedEgressIpStatus, 0, len(dedicatedEgressIps))
+	for _, dedicated := range dedicatedEgressIps {
+		status := *dedicated.DeepCopy()
+		status.Pods = pods[dedicated.Name]
+		sort.Strings(status.Pods)
+		result = append(result, status)
+	}
+	return result, nil
+}
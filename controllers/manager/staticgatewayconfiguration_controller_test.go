@@ -19,6 +19,7 @@ import (
 	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
 	egressgatewayv1alpha1 "github.com/Azure/kube-egress-gateway/api/v1alpha1"
 	"github.com/Azure/kube-egress-gateway/pkg/consts"
@@ -390,6 +391,137 @@ var _ = Describe("test staticGatewayConfiguration validation", func() {
 			Expect(err).Should(HaveOccurred())
 		})
 	})
+
+	Context("validate snatIpCount", func() {
+		It("should pass when snatIpCount is within range", func() {
+			gwConfig.Spec.SnatIpCount = 16
+			err := validate(gwConfig)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		It("should fail when snatIpCount is too large", func() {
+			gwConfig.Spec.SnatIpCount = 17
+			err := validate(gwConfig)
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+
+	Context("validate snatPortBlockSize", func() {
+		It("should pass when snatPortBlockSize is within range", func() {
+			gwConfig.Spec.SnatPortBlockSize = 64
+			err := validate(gwConfig)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		It("should fail when snatPortBlockSize is too small", func() {
+			gwConfig.Spec.SnatPortBlockSize = 32
+			err := validate(gwConfig)
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+
+	Context("validate dedicatedEgressIps", func() {
+		It("should pass with valid dedicated egress IPs", func() {
+			gwConfig.Spec.DedicatedEgressIps = []egressgatewayv1alpha1.DedicatedEgressIp{
+				{Name: "partner-a", NamespaceDefault: true},
+				{Name: "partner-b"},
+			}
+			err := validate(gwConfig)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		It("should fail when name is invalid", func() {
+			gwConfig.Spec.DedicatedEgressIps = []egressgatewayv1alpha1.DedicatedEgressIp{{Name: "1partner"}}
+			err := validate(gwConfig)
+			Expect(err).Should(HaveOccurred())
+		})
+
+		It("should fail when names are duplicated", func() {
+			gwConfig.Spec.DedicatedEgressIps = []egressgatewayv1alpha1.DedicatedEgressIp{{Name: "partner-a"}, {Name: "partner-a"}}
+			err := validate(gwConfig)
+			Expect(err).Should(HaveOccurred())
+		})
+
+		It("should fail when more than one is namespace default", func() {
+			gwConfig.Spec.DedicatedEgressIps = []egressgatewayv1alpha1.DedicatedEgressIp{
+				{Name: "partner-a", NamespaceDefault: true},
+				{Name: "partner-b", NamespaceDefault: true},
+			}
+			err := validate(gwConfig)
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+
+	Context("validate egressIpPoolClaim", func() {
+		BeforeEach(func() {
+			gwConfig.Spec.PublicIpPrefixId = ""
+			gwConfig.Spec.EgressIpPoolClaim = &egressgatewayv1alpha1.EgressIPPoolClaim{PoolName: "pool"}
+		})
+
+		It("should pass when only EgressIpPoolClaim is provided", func() {
+			err := validate(gwConfig)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		It("should fail when PublicIpPrefixId is provided as well", func() {
+			gwConfig.Spec.PublicIpPrefixId = "testPipPrefix"
+			err := validate(gwConfig)
+			Expect(err).Should(HaveOccurred())
+		})
+
+		It("should fail when ProvisionPublicIps is false", func() {
+			gwConfig.Spec.ProvisionPublicIps = false
+			err := validate(gwConfig)
+			Expect(err).Should(HaveOccurred())
+		})
+
+		It("should fail when pool name is empty", func() {
+			gwConfig.Spec.EgressIpPoolClaim.PoolName = ""
+			err := validate(gwConfig)
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+})
+
+var _ = Describe("StaticGatewayConfiguration dedicated egress IP status", func() {
+	podEndpoint := func(name, gateway, dedicatedEgressIP string) *egressgatewayv1alpha1.PodEndpoint {
+		return &egressgatewayv1alpha1.PodEndpoint{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testNamespace},
+			Spec: egressgatewayv1alpha1.PodEndpointSpec{
+				StaticGatewayConfiguration: gateway,
+				DedicatedEgressIp:          dedicatedEgressIP,
+			},
+		}
+	}
+
+	It("should list the pods using each dedicated egress IP", func() {
+		gwConfig := &egressgatewayv1alpha1.StaticGatewayConfiguration{
+			ObjectMeta: metav1.ObjectMeta{Name: testName, Namespace: testNamespace},
+			Spec: egressgatewayv1alpha1.StaticGatewayConfigurationSpec{
+				DedicatedEgressIps: []egressgatewayv1alpha1.DedicatedEgressIp{
+					{Name: "partner-a"},
+					{Name: "partner-b", NamespaceDefault: true},
+				},
+			},
+		}
+		cl := fake.NewClientBuilder().WithScheme(scheme.Scheme).
+			WithObjects(
+				podEndpoint("pod1", testName, "partner-a"),
+				podEndpoint("pod2", testName, ""),
+				podEndpoint("pod3", "other", "partner-a"),
+			).
+			Build()
+		r := &StaticGatewayConfigurationReconciler{Client: cl}
+		status, err := r.dedicatedEgressIPStatus(context.Background(), gwConfig, []egressgatewayv1alpha1.DedicatedEgressIpStatus{
+			{Name: "partner-a", PrivateIps: []string{"10.0.0.3"}, PublicIps: []string{"1.2.3.5"}},
+			{Name: "partner-b", PrivateIps: []string{"10.0.0.4"}},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(status).To(Equal([]egressgatewayv1alpha1.DedicatedEgressIpStatus{
+			{Name: "partner-a", PrivateIps: []string{"10.0.0.3"}, PublicIps: []string{"1.2.3.5"}, Pods: []string{"pod1"}},
+			{Name: "partner-b", PrivateIps: []string{"10.0.0.4"}, Pods: []string{"pod2"}},
+		}))
+	})
 })
 
 func getResource(cl client.Client, object client.Object) error {
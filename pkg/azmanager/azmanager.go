@@ -421,6 +421,27 @@ func (az *AzureManager) DeletePublicIPPrefix(ctx context.Context, resourceGroup,
 	return err
 }
 
+func (az *AzureManager) GetPublicIP(ctx context.Context, resourceGroup, name string) (*network.PublicIPAddress, error) {
+	if resourceGroup == "" {
+		resourceGroup = az.ResourceGroup
+	}
+	if name == "" {
+		return nil, fmt.Errorf("public ip name is empty")
+	}
+	logger := log.FromContext(ctx).WithValues("operation", "GetPublicIP", "resourceGroup", resourceGroup, "resourceName", name)
+	ctx = log.IntoContext(ctx, logger)
+	var pip *network.PublicIPAddress
+	err := wrapRetry(ctx, "GetPublicIP", func(ctx context.Context) error {
+		var err error
+		pip, err = az.PublicIPClient.Get(ctx, resourceGroup, name, nil)
+		return err
+	}, isRateLimitError)
+	if err != nil {
+		return nil, err
+	}
+	return pip, nil
+}
+
 func (az *AzureManager) CreateOrUpdatePublicIP(ctx context.Context, resourceGroup, name string, pip network.PublicIPAddress) (*network.PublicIPAddress, error) {
 	if resourceGroup == "" {
 		resourceGroup = az.ResourceGroup
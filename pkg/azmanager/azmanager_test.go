@@ -872,6 +872,61 @@ func TestGetVM(t *testing.T) {
 	}
 }
 
+func TestGetPublicIP(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		desc         string
+		rg           string
+		expectedRG   string
+		pipName      string
+		pip          *network.PublicIPAddress
+		expectedCall bool
+		testErr      error
+	}{
+		{
+			desc:         "GetPublicIP() should return expected public ip",
+			expectedRG:   "testRG",
+			pipName:      "pip",
+			pip:          &network.PublicIPAddress{Name: to.Ptr("pip")},
+			expectedCall: true,
+		},
+		{
+			desc:         "GetPublicIP() should return public ip with specified resource group",
+			rg:           "customRG",
+			expectedRG:   "customRG",
+			pipName:      "pip",
+			pip:          &network.PublicIPAddress{Name: to.Ptr("pip")},
+			expectedCall: true,
+		},
+		{
+			desc:         "GetPublicIP() should return error when public ip name is empty",
+			expectedCall: false,
+			testErr:      fmt.Errorf("public ip name is empty"),
+		},
+		{
+			desc:         "GetPublicIP() should return expected error",
+			expectedRG:   "testRG",
+			pipName:      "pip",
+			expectedCall: true,
+			testErr:      fmt.Errorf("public ip not found"),
+		},
+	}
+	for i, test := range tests {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		config := getTestCloudConfig()
+		factory := getMockFactory(ctrl)
+		az, _ := CreateAzureManager(config, factory)
+		if test.expectedCall {
+			mockPublicIPClient := az.PublicIPClient.(*mock_publicipaddressclient.MockInterface)
+			mockPublicIPClient.EXPECT().Get(gomock.Any(), test.expectedRG, test.pipName, gomock.Any()).Return(test.pip, test.testErr)
+		}
+		pip, err := az.GetPublicIP(context.Background(), test.rg, test.pipName)
+		assert.Equal(t, err, test.testErr, "TestCase[%d]: %s", i, test.desc)
+		assert.Equal(t, to.Val(pip), to.Val(test.pip), "TestCase[%d]: %s", i, test.desc)
+	}
+}
+
 func TestCreateOrUpdatePublicIP(t *testing.T) {
 	t.Parallel()
 	tests := []struct {
@@ -0,0 +1,204 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+package attach
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+
+	current "github.com/containernetworking/cni/pkg/types/100"
+	"github.com/containernetworking/plugins/pkg/ns"
+	"github.com/vishvananda/netlink"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.zx2c4.com/wireguard/wgctrl"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+	"k8s.io/klog/v2"
+
+	"github.com/Azure/kube-egress-gateway/pkg/cni/ipam"
+	"github.com/Azure/kube-egress-gateway/pkg/cni/routes"
+	"github.com/Azure/kube-egress-gateway/pkg/cni/sandbox"
+	"github.com/Azure/kube-egress-gateway/pkg/cni/wireguard"
+	v1 "github.com/Azure/kube-egress-gateway/pkg/cniprotocol/v1"
+	"github.com/Azure/kube-egress-gateway/pkg/consts"
+	"github.com/Azure/kube-egress-gateway/pkg/netfilter"
+	"github.com/Azure/kube-egress-gateway/pkg/tracing"
+)
+
+// Attach creates the wireguard interface in the network namespace of the pod sandbox, registers it to the
+// gateway through cni manager and routes pod traffic through it. It's used by the cni plugin when the pod
+// is created and by cni manager when an existing pod is annotated. On success, the original eth0 routes and
+// the gateway are saved in record, for Detach.
+func Attach(ctx context.Context, client v1.NicServiceClient, record *sandbox.Record, gwName string, ipWrapper ipam.IPProvider, excludedCIDRs []string, firewallBackend netfilter.Backend, result *current.Result) (err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "attach gateway", trace.WithAttributes(attribute.String("gateway", record.PodNamespace+"/"+gwName)))
+	defer func() { tracing.EndSpan(span, err) }()
+
+	var eth0Routes []netlink.Route
+	err = wireguard.WithWireGuardNic(record.ContainerID, record.NetnsPath, consts.WireguardLinkName, ipWrapper, excludedCIDRs, result, func(podNs ns.NetNS, allowedIPNet string) error {
+		//generate private key
+		privateKey, err := wgtypes.GeneratePrivateKey()
+		if err != nil {
+			return fmt.Errorf("failed to generate wg private key: %w", err)
+		}
+		var wgDevice *wgtypes.Device
+		err = podNs.Do(func(nn ns.NetNS) error {
+			wgclient, err := wgctrl.New()
+			if err != nil {
+				return fmt.Errorf("failed to create wg client: %w", err)
+			}
+			defer func() {
+				if err := wgclient.Close(); err != nil {
+					// Log error but don't fail the operation
+					klog.ErrorS(err, "failed to close wireguard client")
+				}
+			}()
+			wgDevice, err = wgclient.Device(consts.WireguardLinkName)
+			if err != nil {
+				return fmt.Errorf("failed to find wg device (%s): %w", consts.WireguardLinkName, err)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		resp, err := client.NicAdd(ctx, &v1.NicAddRequest{
+			PodConfig: &v1.PodInfo{
+				PodName:      record.PodName,
+				PodNamespace: record.PodNamespace,
+				PodUid:       record.PodUID,
+			},
+			PublicKey:   privateKey.PublicKey().String(),
+			ListenPort:  int32(wgDevice.ListenPort),
+			AllowedIp:   allowedIPNet,
+			GatewayName: gwName,
+			ContainerId: record.ContainerID,
+			NetnsPath:   record.NetnsPath,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to send nicAdd request: %w", err)
+		}
+
+		gwPublicKey, err := wgtypes.ParseKey(resp.PublicKey)
+		if err != nil {
+			return fmt.Errorf("failed to parse gateway public key: %w", err)
+		}
+
+		return podNs.Do(func(nn ns.NetNS) (err error) {
+			_, span := tracing.Tracer().Start(ctx, "configure pod wireguard device and routes")
+			defer func() { tracing.EndSpan(span, err) }()
+			wgclient, err := wgctrl.New()
+			if err != nil {
+				return fmt.Errorf("failed to create wg client: %w", err)
+			}
+			defer func() {
+				if err := wgclient.Close(); err != nil {
+					// Log error but don't fail the operation
+					klog.ErrorS(err, "failed to close wireguard client")
+				}
+			}()
+			err = wgclient.ConfigureDevice(consts.WireguardLinkName, wgtypes.Config{
+				PrivateKey: &privateKey,
+				Peers: []wgtypes.PeerConfig{
+					{
+						PublicKey: gwPublicKey,
+						Endpoint: &net.UDPAddr{
+							IP:   net.ParseIP(resp.EndpointIp),
+							Port: int(resp.ListenPort),
+						},
+						AllowedIPs: []net.IPNet{
+							{
+								IP:   net.IPv4zero,
+								Mask: net.CIDRMask(0, 8*len(net.IPv4zero)),
+							},
+							{
+								IP:   net.IPv6zero,
+								Mask: net.CIDRMask(0, 8*len(net.IPv6zero)),
+							},
+						},
+					},
+				},
+			})
+			if err != nil {
+				return fmt.Errorf("failed to configure wg device: %w", err)
+
+			}
+
+			if os.Getenv("IS_UNIT_TEST_ENV") != "true" {
+				if err := wireguard.SetLinkMTU(consts.WireguardLinkName, int(resp.GetMtu())); err != nil {
+					return fmt.Errorf("failed to set wg device MTU: %w", err)
+				}
+				if eth0Routes, err = routes.Eth0Routes(); err != nil {
+					return err
+				}
+				if err := routes.SetPodRoutes(consts.WireguardLinkName, resp, excludedCIDRs, firewallBackend, "/proc/sys", result); err != nil {
+					return fmt.Errorf("failed to setup pod routes: %w", err)
+				}
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	record.GatewayName = gwName
+	record.Eth0Routes = nil
+	for _, route := range eth0Routes {
+		record.Eth0Routes = append(record.Eth0Routes, sandbox.NewRoute(route))
+	}
+	return nil
+}
+
+// Detach reverts Attach: it deletes the wireguard interface of the pod sandbox, restores the eth0 routes
+// saved in record and unregisters the pod from the gateway through cni manager.
+func Detach(ctx context.Context, client v1.NicServiceClient, record *sandbox.Record) error {
+	podNs, err := ns.GetNS(record.NetnsPath)
+	if err != nil {
+		return fmt.Errorf("failed to get pod namespace: %w", err)
+	}
+	defer func() { _ = podNs.Close() }()
+
+	var eth0Routes []netlink.Route
+	for _, r := range record.Eth0Routes {
+		route, err := r.NetlinkRoute(0)
+		if err != nil {
+			return err
+		}
+		eth0Routes = append(eth0Routes, route)
+	}
+	err = podNs.Do(func(nn ns.NetNS) error {
+		if wgLink, err := netlink.LinkByName(consts.WireguardLinkName); err == nil {
+			if err := netlink.LinkDel(wgLink); err != nil {
+				return fmt.Errorf("failed to delete wireguard link: %w", err)
+			}
+		} else if _, ok := err.(netlink.LinkNotFoundError); !ok {
+			return fmt.Errorf("failed to retrieve wireguard link: %w", err)
+		}
+		if len(eth0Routes) == 0 {
+			// routes were not set up by Attach
+			return nil
+		}
+		return routes.RestorePodRoutes(eth0Routes)
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = client.NicDel(ctx, &v1.NicDelRequest{
+		PodConfig: &v1.PodInfo{
+			PodName:      record.PodName,
+			PodNamespace: record.PodNamespace,
+			PodUid:       record.PodUID,
+		},
+		ContainerId: record.ContainerID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to send nicDel request: %w", err)
+	}
+	record.GatewayName = ""
+	record.Eth0Routes = nil
+	return nil
+}
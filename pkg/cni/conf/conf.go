@@ -5,9 +5,12 @@ package conf
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 
 	"github.com/containernetworking/cni/pkg/types"
 	"github.com/containernetworking/cni/pkg/version"
+
+	"github.com/Azure/kube-egress-gateway/pkg/consts"
 )
 
 type CNIConfig struct {
@@ -17,7 +20,17 @@ type CNIConfig struct {
 	// note: this is different from the exception CIDRs in the StaticEgressGatewayConfig CRD
 	// which will always be excluded from the default route.
 	ExcludedCIDRs []string `json:"excludedCIDRs"`
-	SocketPath    string   `json:"socketPath"`
+	// SocketPath is the address of cni manager daemon, either host:port or unix:///path/to/socket.
+	SocketPath string `json:"socketPath"`
+	// FirewallBackend is the backend used to program pod netfilter rules: auto, iptables or nftables.
+	// Empty value means auto.
+	FirewallBackend string `json:"firewallBackend,omitempty"`
+	// SandboxDir is the directory where pod sandboxes are recorded for cni manager to attach or detach
+	// gateways after the pods are created. Empty value disables recording.
+	SandboxDir string `json:"sandboxDir,omitempty"`
+	// TracingEndpoint is the OTLP/HTTP endpoint the plugin exports the spans of ADD requests to, it must be
+	// reachable from the host network namespace. Empty value disables tracing.
+	TracingEndpoint string `json:"tracingEndpoint,omitempty"`
 }
 
 func ParseCNIConfig(stdin []byte) (*CNIConfig, error) {
@@ -36,6 +49,14 @@ func ParseCNIConfig(stdin []byte) (*CNIConfig, error) {
 	return conf, nil
 }
 
+// ParseSocketPath returns the network and address to dial cni manager daemon with.
+func ParseSocketPath(socketPath string) (network, address string) {
+	if path, ok := strings.CutPrefix(socketPath, consts.UnixSocketScheme); ok {
+		return "unix", path
+	}
+	return "tcp", socketPath
+}
+
 // K8sArgs is the valid CNI_ARGS used for Kubernetes
 
 type K8sConfig struct {
@@ -43,6 +64,7 @@ type K8sConfig struct {
 	K8S_POD_NAME               types.UnmarshallableString
 	K8S_POD_NAMESPACE          types.UnmarshallableString
 	K8S_POD_INFRA_CONTAINER_ID types.UnmarshallableString
+	K8S_POD_UID                types.UnmarshallableString
 }
 
 func LoadK8sInfo(args string) (*K8sConfig, error) {
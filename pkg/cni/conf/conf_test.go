@@ -66,6 +66,7 @@ func TestLoadK8sInfo(t *testing.T) {
 		K8S_POD_NAME:               types.UnmarshallableString("testpod"),
 		K8S_POD_NAMESPACE:          types.UnmarshallableString("testns"),
 		K8S_POD_INFRA_CONTAINER_ID: types.UnmarshallableString("1234567890"),
+		K8S_POD_UID:                types.UnmarshallableString("12345678-1234-1234-1234-123456789012"),
 	}
 	res, err := LoadK8sInfo(testArg)
 	if err != nil {
@@ -75,3 +76,31 @@ func TestLoadK8sInfo(t *testing.T) {
 		t.Fatalf("got different k8sConfig from LoadK8sInfo, expected: %#v, got: %#v", *expected, *res)
 	}
 }
+
+func TestParseSocketPath(t *testing.T) {
+	tests := map[string]struct {
+		socketPath      string
+		expectedNetwork string
+		expectedAddress string
+	}{
+		"tcp address": {
+			socketPath:      "localhost:50051",
+			expectedNetwork: "tcp",
+			expectedAddress: "localhost:50051",
+		},
+		"unix socket path": {
+			socketPath:      "unix:///var/run/kube-egress-gateway/cnimanager.sock",
+			expectedNetwork: "unix",
+			expectedAddress: "/var/run/kube-egress-gateway/cnimanager.sock",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			network, address := ParseSocketPath(test.socketPath)
+			if network != test.expectedNetwork || address != test.expectedAddress {
+				t.Fatalf("got different network and address from ParseSocketPath, expected: %s %s, got: %s %s", test.expectedNetwork, test.expectedAddress, network, address)
+			}
+		})
+	}
+}
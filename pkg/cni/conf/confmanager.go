@@ -10,8 +10,8 @@ import (
 	"net"
 	"os"
 	"path/filepath"
-	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
@@ -21,6 +21,7 @@ import (
 	"k8s.io/client-go/util/retry"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	"github.com/Azure/kube-egress-gateway/pkg/cni/ipam"
 	"github.com/Azure/kube-egress-gateway/pkg/consts"
 	"github.com/Azure/kube-egress-gateway/pkg/logger"
 	"github.com/Azure/kube-egress-gateway/pkg/metrics"
@@ -36,15 +37,41 @@ type Manager struct {
 	cniConfWatcher            *fsnotify.Watcher
 	exceptionCidrs            []string
 	k8sClient                 client.Client
-	grpcPort                  int
+	socketPath                string
+	firewallBackend           string
+	sandboxDir                string
+	ipamRanges                []ipam.Range
+	masterSelector            MasterSelector
+	// multusNetwork is the name of the Multus network attachment our plugin is registered as, empty if the
+	// plugin is chained to the master conf file instead
+	multusNetwork string
+	// tracingEndpoint is the OTLP/HTTP endpoint the cni plugin exports its spans to, empty to disable tracing
+	tracingEndpoint string
+
+	mu sync.RWMutex
+	// master is the conf file or the network attachment the plugin is currently installed in
+	master string
 }
 
-func NewCNIConfManager(cniConfDir, cniConfFile, exceptionCidrs, cniUninstallConfigMapName string, k8sClient client.Client, grpcPort int) (*Manager, error) {
+func NewCNIConfManager(cniConfDir, cniConfFile, exceptionCidrs, cniUninstallConfigMapName string, k8sClient client.Client, socketPath string, firewallBackend string, sandboxDir string, ipamRanges string, masterSelector string, multusNetwork string, tracingEndpoint string) (*Manager, error) {
 	cidrs, err := parseCidrs(exceptionCidrs)
 	if err != nil {
 		return nil, err
 	}
 
+	ranges, err := ipam.ParseRanges(ipamRanges)
+	if err != nil {
+		return nil, err
+	}
+	if multusNetwork != "" && len(ranges) > 0 {
+		return nil, errors.New("ipam ranges are node specific and can't be used with multus network attachment shared by all nodes")
+	}
+
+	selector, err := ParseMasterSelector(masterSelector)
+	if err != nil {
+		return nil, err
+	}
+
 	watcher, err := newWatcher(cniConfDir)
 	if err != nil {
 		return nil, err
@@ -58,11 +85,51 @@ func NewCNIConfManager(cniConfDir, cniConfFile, exceptionCidrs, cniUninstallConf
 		cniConfWatcher:            watcher,
 		exceptionCidrs:            cidrs,
 		k8sClient:                 k8sClient,
-		grpcPort:                  grpcPort,
+		socketPath:                socketPath,
+		firewallBackend:           firewallBackend,
+		sandboxDir:                sandboxDir,
+		ipamRanges:                ranges,
+		masterSelector:            selector,
+		multusNetwork:             multusNetwork,
+		tracingEndpoint:           tracingEndpoint,
 	}, nil
 }
 
+// ExceptionCidrs returns the CIDRs bypassing the gateway written to the cni config.
+func (mgr *Manager) ExceptionCidrs() []string {
+	return mgr.exceptionCidrs
+}
+
+// IPAMRanges returns the ranges tunnel addresses are allocated from written to the cni config, empty if
+// pod eth0 addresses are used.
+func (mgr *Manager) IPAMRanges() []ipam.Range {
+	return mgr.ipamRanges
+}
+
+// MasterConf returns the conf file our plugin is chained to, or the Multus network attachment it's registered
+// as, empty if it's not installed yet.
+func (mgr *Manager) MasterConf() string {
+	mgr.mu.RLock()
+	defer mgr.mu.RUnlock()
+	return mgr.master
+}
+
+func (mgr *Manager) setMaster(master string) {
+	mgr.mu.Lock()
+	defer mgr.mu.Unlock()
+	if mgr.master == master {
+		return
+	}
+	logger.GetLogger().Info("cni plugin is installed", "master", master, "previous master", mgr.master)
+	mgr.master = master
+	metrics.CNIManagerMasterConfInfo.Reset()
+	metrics.CNIManagerMasterConfInfo.WithLabelValues(master).Set(1)
+}
+
 func (mgr *Manager) IsReady() bool {
+	if mgr.multusNetwork != "" {
+		return mgr.MasterConf() != ""
+	}
 	log := logger.GetLogger()
 	file := filepath.Join(mgr.cniConfDir, mgr.cniConfFile)
 	if _, err := os.Stat(file); err != nil {
@@ -84,7 +151,11 @@ func (mgr *Manager) Start(ctx context.Context) error {
 		}
 	}()
 
-	log.Info("Installing cni configuration")
+	if mgr.multusNetwork != "" {
+		return mgr.startMultus(ctx)
+	}
+
+	log.Info("Installing cni configuration", "master selector", mgr.masterSelector.String())
 	if err := mgr.insertCNIPluginConf(); err != nil {
 		if errors.Is(err, ErrMainCNINotFound) {
 			log.Info("Main CNI config file is missing, continue to watch changes")
@@ -123,7 +194,7 @@ func (mgr *Manager) Start(ctx context.Context) error {
 }
 
 func (mgr *Manager) insertCNIPluginConf() error {
-	file, err := findMasterPlugin(mgr.cniConfDir, mgr.cniConfFile)
+	file, err := findMasterPlugin(mgr.cniConfDir, mgr.cniConfFile, mgr.masterSelector)
 	if err != nil {
 		return err
 	}
@@ -155,6 +226,7 @@ func (mgr *Manager) insertCNIPluginConf() error {
 	if err != nil {
 		return fmt.Errorf("failed to rename file: %w", err)
 	}
+	mgr.setMaster(file)
 	return nil
 }
 
@@ -242,12 +314,7 @@ func (mgr *Manager) managePluginFromConf(file string) (map[string]interface{}, e
 
 	var plugins []interface{}
 	plugins = append(plugins, rawConf)
-	plugins = append(plugins, map[string]interface{}{
-		"type":          consts.KubeEgressCNIName,
-		"ipam":          map[string]interface{}{"type": consts.KubeEgressIPAMCNIName},
-		"excludedCIDRs": mgr.exceptionCidrs,
-		"socketPath":    fmt.Sprintf("localhost:%d", mgr.grpcPort),
-	})
+	plugins = append(plugins, mgr.egressGatewayPluginConf())
 	rawList["plugins"] = plugins
 	return rawList, nil
 }
@@ -292,42 +359,33 @@ func (mgr *Manager) managePluginFromConfList(file string) (map[string]interface{
 	}
 
 	// insert kube-egress-gateway-cni at plugins[1]
-	plugins = append(plugins[:1], append([]interface{}{map[string]interface{}{
-		"type":          consts.KubeEgressCNIName,
-		"ipam":          map[string]interface{}{"type": consts.KubeEgressIPAMCNIName},
-		"excludedCIDRs": mgr.exceptionCidrs,
-		"socketPath":    fmt.Sprintf("localhost:%d", mgr.grpcPort),
-	}}, plugins[1:]...)...)
+	plugins = append(plugins[:1], append([]interface{}{mgr.egressGatewayPluginConf()}, plugins[1:]...)...)
 
 	rawList["plugins"] = plugins
 	return rawList, nil
 }
 
-func findMasterPlugin(cniConfDir, cniConfFile string) (string, error) {
-	var confFiles []string
-	files, err := os.ReadDir(cniConfDir)
-	if err != nil {
-		return "", fmt.Errorf("failed to read cni config directory: %w", err)
+func (mgr *Manager) egressGatewayPluginConf() map[string]interface{} {
+	ipamConf := map[string]interface{}{"type": consts.KubeEgressIPAMCNIName}
+	if len(mgr.ipamRanges) > 0 {
+		ipamConf["ranges"] = mgr.ipamRanges
 	}
-
-	for _, file := range files {
-		if !file.Type().IsRegular() {
-			continue
-		}
-		if strings.EqualFold(file.Name(), cniConfFile) {
-			continue
-		}
-		fileExtension := filepath.Ext(file.Name())
-		if fileExtension == ".conflist" || fileExtension == ".conf" || fileExtension == ".json" {
-			confFiles = append(confFiles, file.Name())
-		}
+	conf := map[string]interface{}{
+		"type":          consts.KubeEgressCNIName,
+		"ipam":          ipamConf,
+		"excludedCIDRs": mgr.exceptionCidrs,
+		"socketPath":    mgr.socketPath,
 	}
-
-	if len(confFiles) == 0 {
-		return "", ErrMainCNINotFound
+	if mgr.firewallBackend != "" {
+		conf["firewallBackend"] = mgr.firewallBackend
+	}
+	if mgr.sandboxDir != "" {
+		conf["sandboxDir"] = mgr.sandboxDir
+	}
+	if mgr.tracingEndpoint != "" {
+		conf["tracingEndpoint"] = mgr.tracingEndpoint
 	}
-	sort.Strings(confFiles)
-	return filepath.Join(cniConfDir, confFiles[0]), nil
+	return conf
 }
 
 func parseCidrs(cidrs string) ([]string, error) {
@@ -13,6 +13,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/Azure/kube-egress-gateway/pkg/cni/ipam"
 	"github.com/Azure/kube-egress-gateway/pkg/consts"
 
 	corev1 "k8s.io/api/core/v1"
@@ -26,13 +27,16 @@ const (
 	testConf                      = "01-test.conf"
 	testConfList                  = "01-test.conflist"
 	testCniUninstallConfigMapName = "cni-uninstall"
-	testGrpcPort                  = 5051
+	testSocketPath                = "localhost:5051"
 )
 
 func TestNewCNIConfManager(t *testing.T) {
 	tests := map[string]struct {
 		testDir        string
 		exceptionCidrs string
+		ipamRanges     string
+		masterSelector string
+		multusNetwork  string
 		expectErr      bool
 		expectedCidrs  []string
 	}{
@@ -45,6 +49,17 @@ func TestNewCNIConfManager(t *testing.T) {
 			exceptionCidrs: "1000.1.2.3/32",
 			expectErr:      true,
 		},
+		"NewCNIConfManager should report error when masterSelector is not valid": {
+			testDir:        "testdata",
+			masterSelector: "name:cilium",
+			expectErr:      true,
+		},
+		"NewCNIConfManager should report error when ipamRanges are used with multusNetwork": {
+			testDir:       "testdata",
+			ipamRanges:    "100.64.1.0/24",
+			multusNetwork: "egressgateway",
+			expectErr:     true,
+		},
 		"NewCNIConfManager should return manager as expected": {
 			testDir:        "testdata",
 			exceptionCidrs: "1.2.3.4/32,10.1.0.0/16",
@@ -53,7 +68,7 @@ func TestNewCNIConfManager(t *testing.T) {
 	}
 	for name, test := range tests {
 		t.Run(name, func(t *testing.T) {
-			mgr, err := NewCNIConfManager(test.testDir, testConfList, test.exceptionCidrs, testCniUninstallConfigMapName, fake.NewFakeClient(), testGrpcPort)
+			mgr, err := NewCNIConfManager(test.testDir, testConfList, test.exceptionCidrs, testCniUninstallConfigMapName, fake.NewFakeClient(), testSocketPath, "", "", test.ipamRanges, test.masterSelector, test.multusNetwork, "")
 			defer func() {
 				if mgr != nil && mgr.cniConfWatcher != nil {
 					_ = mgr.cniConfWatcher.Close()
@@ -75,8 +90,8 @@ func TestNewCNIConfManager(t *testing.T) {
 				if mgr.cniUninstallConfigMapName != testCniUninstallConfigMapName {
 					t.Fatalf("mgr's cniUninstallConfigMapName is different: got: %s, expected: %s", mgr.cniUninstallConfigMapName, testCniUninstallConfigMapName)
 				}
-				if mgr.grpcPort != testGrpcPort {
-					t.Fatalf("mgr's grpcPort is different: got: %d, expected: %d", mgr.grpcPort, testGrpcPort)
+				if mgr.socketPath != testSocketPath {
+					t.Fatalf("mgr's socketPath is different: got: %s, expected: %s", mgr.socketPath, testSocketPath)
 				}
 				if mgr.cniConfWatcher == nil {
 					t.Fatalf("mgr's cniConfWatch is nil")
@@ -112,7 +127,7 @@ func TestIsReady(t *testing.T) {
 		},
 	}
 	for name, test := range tests {
-		mgr, err := NewCNIConfManager(testDir, test.fileName, "", "", nil, testGrpcPort)
+		mgr, err := NewCNIConfManager(testDir, test.fileName, "", "", nil, testSocketPath, "", "", "", "", "", "")
 		if err != nil {
 			t.Fatalf("failed to create cni conf manager: %v", err)
 		}
@@ -127,7 +142,7 @@ func TestStart(t *testing.T) {
 	_ = os.Setenv(consts.PodNamespaceEnvKey, "default")
 	defer func() { _ = os.Unsetenv(consts.PodNamespaceEnvKey) }()
 	client := fake.NewFakeClient(&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: testCniUninstallConfigMapName, Namespace: "default"}, Data: map[string]string{"uninstall": "true"}})
-	mgr, err := NewCNIConfManager(testDir, testConfList, "", testCniUninstallConfigMapName, client, testGrpcPort)
+	mgr, err := NewCNIConfManager(testDir, testConfList, "", testCniUninstallConfigMapName, client, testSocketPath, "", "", "", "", "", "")
 	if err != nil {
 		t.Fatalf("failed to create cni conf manager: %v", err)
 	}
@@ -197,7 +212,7 @@ func TestRemoveCNIPluginConf(t *testing.T) {
 		t.Run(name, func(t *testing.T) {
 			_ = os.Setenv(consts.PodNamespaceEnvKey, "default")
 			defer func() { _ = os.Unsetenv(consts.PodNamespaceEnvKey) }()
-			mgr, err := NewCNIConfManager(testDir, testConfList, "", testCniUninstallConfigMapName, test.client, testGrpcPort)
+			mgr, err := NewCNIConfManager(testDir, testConfList, "", testCniUninstallConfigMapName, test.client, testSocketPath, "", "", "", "", "", "")
 			if err != nil {
 				t.Fatalf("failed to create cni conf manager: %v", err)
 			}
@@ -353,7 +368,7 @@ func TestInsertCNIPluginConf(t *testing.T) {
 	for name, test := range tests {
 		t.Run(name, func(t *testing.T) {
 			confFileName := "50-result.conflist"
-			mgr, err := NewCNIConfManager(testDir, confFileName, "10.1.0.0/16,1.2.3.4/32", testCniUninstallConfigMapName, fake.NewFakeClient(), testGrpcPort)
+			mgr, err := NewCNIConfManager(testDir, confFileName, "10.1.0.0/16,1.2.3.4/32", testCniUninstallConfigMapName, fake.NewFakeClient(), testSocketPath, "", "", "", "", "", "")
 			if err != nil {
 				t.Fatalf("failed to create cni conf manager: %v", err)
 			}
@@ -450,3 +465,44 @@ func copyFile(dir, src, dest string) error {
 	}
 	return nil
 }
+
+func TestEgressGatewayPluginConf(t *testing.T) {
+	mgr := &Manager{socketPath: testSocketPath}
+	conf := mgr.egressGatewayPluginConf()
+	if _, ok := conf["firewallBackend"]; ok {
+		t.Fatalf("firewallBackend should be omitted when not set, got: %v", conf)
+	}
+	if _, ok := conf["sandboxDir"]; ok {
+		t.Fatalf("sandboxDir should be omitted when not set, got: %v", conf)
+	}
+	if _, ok := conf["tracingEndpoint"]; ok {
+		t.Fatalf("tracingEndpoint should be omitted when not set, got: %v", conf)
+	}
+
+	mgr.firewallBackend = "nftables"
+	conf = mgr.egressGatewayPluginConf()
+	if conf["firewallBackend"] != "nftables" {
+		t.Fatalf("firewallBackend is different: got: %v, expected: nftables", conf["firewallBackend"])
+	}
+
+	mgr.sandboxDir = "/var/run/kube-egress-gateway/sandboxes"
+	conf = mgr.egressGatewayPluginConf()
+	if conf["sandboxDir"] != "/var/run/kube-egress-gateway/sandboxes" {
+		t.Fatalf("sandboxDir is different: got: %v, expected: /var/run/kube-egress-gateway/sandboxes", conf["sandboxDir"])
+	}
+
+	mgr.tracingEndpoint = "http://127.0.0.1:4318"
+	conf = mgr.egressGatewayPluginConf()
+	if conf["tracingEndpoint"] != "http://127.0.0.1:4318" {
+		t.Fatalf("tracingEndpoint is different: got: %v, expected: http://127.0.0.1:4318", conf["tracingEndpoint"])
+	}
+
+	if ipamConf := conf["ipam"].(map[string]interface{}); !reflect.DeepEqual(ipamConf, map[string]interface{}{"type": "kube-egress-cni-ipam"}) {
+		t.Fatalf("ipam is different: got: %v, expected no ranges", ipamConf)
+	}
+	mgr.ipamRanges = []ipam.Range{{Subnet: "100.64.1.0/24"}}
+	conf = mgr.egressGatewayPluginConf()
+	if ranges := conf["ipam"].(map[string]interface{})["ranges"]; !reflect.DeepEqual(ranges, mgr.ipamRanges) {
+		t.Fatalf("ipam ranges are different: got: %v, expected: %v", ranges, mgr.ipamRanges)
+	}
+}
@@ -0,0 +1,169 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+package conf
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	masterSelectorNetwork = "network"
+	masterSelectorGlob    = "glob"
+	masterSelectorFile    = "file"
+)
+
+// multusPluginTypes are the plugin types of Multus, whose conf files delegate to the other conf files of the
+// directory, ours included, and are never picked as master unless selected by file.
+var multusPluginTypes = []string{"multus", "multus-shim"}
+
+// MasterSelector selects the cni conf file our plugin is chained to, at most one field is set. The first conf
+// file in lexical order, Multus ones excluded, is selected if none is set.
+type MasterSelector struct {
+	// Network is the network name in the conf file.
+	Network string
+	// Glob is a pattern matching the conf file name.
+	Glob string
+	// File is the conf file name.
+	File string
+}
+
+// ParseMasterSelector parses the selector in the format of "network:<name>", "glob:<pattern>" or "file:<name>".
+// Empty value selects the first conf file.
+func ParseMasterSelector(selector string) (MasterSelector, error) {
+	selector = strings.TrimSpace(selector)
+	if selector == "" {
+		return MasterSelector{}, nil
+	}
+	kind, value, ok := strings.Cut(selector, ":")
+	if !ok || value == "" {
+		return MasterSelector{}, fmt.Errorf("master cni conf selector %q is not in the format of <network|glob|file>:<value>", selector)
+	}
+	switch kind {
+	case masterSelectorNetwork:
+		return MasterSelector{Network: value}, nil
+	case masterSelectorGlob:
+		if _, err := filepath.Match(value, ""); err != nil {
+			return MasterSelector{}, fmt.Errorf("master cni conf glob %q is not valid: %w", value, err)
+		}
+		return MasterSelector{Glob: value}, nil
+	case masterSelectorFile:
+		if filepath.Base(value) != value {
+			return MasterSelector{}, fmt.Errorf("master cni conf file %q must be a file name in cni conf directory", value)
+		}
+		return MasterSelector{File: value}, nil
+	default:
+		return MasterSelector{}, fmt.Errorf("unknown master cni conf selector %q, expecting network, glob or file", kind)
+	}
+}
+
+func (s MasterSelector) String() string {
+	switch {
+	case s.Network != "":
+		return masterSelectorNetwork + ":" + s.Network
+	case s.Glob != "":
+		return masterSelectorGlob + ":" + s.Glob
+	case s.File != "":
+		return masterSelectorFile + ":" + s.File
+	default:
+		return "first"
+	}
+}
+
+// findMasterPlugin returns the path of the conf file in cniConfDir selected by selector, cniConfFile excluded.
+func findMasterPlugin(cniConfDir, cniConfFile string, selector MasterSelector) (string, error) {
+	var confFiles []string
+	files, err := os.ReadDir(cniConfDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to read cni config directory: %w", err)
+	}
+
+	for _, file := range files {
+		if !file.Type().IsRegular() {
+			continue
+		}
+		if strings.EqualFold(file.Name(), cniConfFile) {
+			continue
+		}
+		fileExtension := filepath.Ext(file.Name())
+		if fileExtension == ".conflist" || fileExtension == ".conf" || fileExtension == ".json" {
+			confFiles = append(confFiles, file.Name())
+		}
+	}
+	sort.Strings(confFiles)
+
+	for _, file := range confFiles {
+		path := filepath.Join(cniConfDir, file)
+		switch {
+		case selector.File != "":
+			if file == selector.File {
+				return path, nil
+			}
+		case selector.Glob != "":
+			// glob does not override the multus check, as multus conf would chain into our own conf
+			if matched, _ := filepath.Match(selector.Glob, file); matched && !isMultusConf(path) {
+				return path, nil
+			}
+		case selector.Network != "":
+			if name, _ := readNetworkName(path); name == selector.Network {
+				return path, nil
+			}
+		default:
+			if !isMultusConf(path) {
+				return path, nil
+			}
+		}
+	}
+	return "", ErrMainCNINotFound
+}
+
+type rawNetwork struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Plugins []struct {
+		Type string `json:"type"`
+	} `json:"plugins"`
+}
+
+func readNetwork(path string) (*rawNetwork, error) {
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	network := &rawNetwork{}
+	if err := json.Unmarshal(bytes, network); err != nil {
+		return nil, err
+	}
+	return network, nil
+}
+
+func readNetworkName(path string) (string, error) {
+	network, err := readNetwork(path)
+	if err != nil {
+		return "", err
+	}
+	return network.Name, nil
+}
+
+// isMultusConf returns whether the first plugin of the conf file is Multus. Files that can't be parsed are not,
+// so that the error is reported when they're chained.
+func isMultusConf(path string) bool {
+	network, err := readNetwork(path)
+	if err != nil {
+		return false
+	}
+	pluginType := network.Type
+	if len(network.Plugins) > 0 {
+		pluginType = network.Plugins[0].Type
+	}
+	for _, t := range multusPluginTypes {
+		if strings.EqualFold(pluginType, t) {
+			return true
+		}
+	}
+	return false
+}
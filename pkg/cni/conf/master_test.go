@@ -0,0 +1,127 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+package conf
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseMasterSelector(t *testing.T) {
+	tests := map[string]struct {
+		input       string
+		expected    MasterSelector
+		expectedErr bool
+	}{
+		"ParseMasterSelector should return empty selector for empty input": {
+			input: "",
+		},
+		"ParseMasterSelector should parse network selector": {
+			input:    "network:cilium",
+			expected: MasterSelector{Network: "cilium"},
+		},
+		"ParseMasterSelector should parse glob selector": {
+			input:    "glob:*-calico.conflist",
+			expected: MasterSelector{Glob: "*-calico.conflist"},
+		},
+		"ParseMasterSelector should parse file selector": {
+			input:    "file:05-cilium.conflist",
+			expected: MasterSelector{File: "05-cilium.conflist"},
+		},
+		"ParseMasterSelector should report error for unknown selector": {
+			input:       "name:cilium",
+			expectedErr: true,
+		},
+		"ParseMasterSelector should report error for missing value": {
+			input:       "network:",
+			expectedErr: true,
+		},
+		"ParseMasterSelector should report error for invalid glob": {
+			input:       "glob:[",
+			expectedErr: true,
+		},
+		"ParseMasterSelector should report error for file path": {
+			input:       "file:/etc/cni/net.d/05-cilium.conflist",
+			expectedErr: true,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			selector, err := ParseMasterSelector(test.input)
+			if test.expectedErr {
+				if err == nil {
+					t.Fatalf("ParseMasterSelector does not return expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseMasterSelector returns unexpected error: %v", err)
+			}
+			if selector != test.expected {
+				t.Fatalf("ParseMasterSelector returns unexpected result: got: %+v, expected: %+v", selector, test.expected)
+			}
+		})
+	}
+}
+
+func TestFindMasterPlugin(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"00-multus.conf":            `{"cniVersion":"0.3.1","name":"multus-cni-network","type":"multus-shim"}`,
+		"01-egressgateway.conflist": `{"cniVersion":"0.3.1","name":"cilium","plugins":[{"type":"cilium-cni"},{"type":"kube-egress-cni"}]}`,
+		"05-cilium.conflist":        `{"cniVersion":"0.3.1","name":"cilium","plugins":[{"type":"cilium-cni"}]}`,
+		"10-calico.conflist":        `{"cniVersion":"0.3.1","name":"k8s-pod-network","plugins":[{"type":"calico"}]}`,
+		"99-loopback.conf":          `{"cniVersion":"0.3.1","name":"lo","type":"loopback"}`,
+		"README":                    `not a conf file`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+
+	tests := map[string]struct {
+		selector    MasterSelector
+		expected    string
+		expectedErr error
+	}{
+		"findMasterPlugin should skip multus and our conf files by default": {
+			expected: "05-cilium.conflist",
+		},
+		"findMasterPlugin should select conf file by network name": {
+			selector: MasterSelector{Network: "k8s-pod-network"},
+			expected: "10-calico.conflist",
+		},
+		"findMasterPlugin should select first conf file matching glob": {
+			selector: MasterSelector{Glob: "*.conf"},
+			expected: "99-loopback.conf",
+		},
+		"findMasterPlugin should select conf file by name": {
+			selector: MasterSelector{File: "00-multus.conf"},
+			expected: "00-multus.conf",
+		},
+		"findMasterPlugin should report error when no conf file matches": {
+			selector:    MasterSelector{Network: "flannel"},
+			expectedErr: ErrMainCNINotFound,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			file, err := findMasterPlugin(dir, "01-egressgateway.conflist", test.selector)
+			if test.expectedErr != nil {
+				if !errors.Is(err, test.expectedErr) {
+					t.Fatalf("findMasterPlugin returns unexpected error: got: %v, expected: %v", err, test.expectedErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("findMasterPlugin returns unexpected error: %v", err)
+			}
+			if file != filepath.Join(dir, test.expected) {
+				t.Fatalf("findMasterPlugin returns unexpected file: got: %s, expected: %s", file, test.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+package conf
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/Azure/kube-egress-gateway/pkg/consts"
+	"github.com/Azure/kube-egress-gateway/pkg/logger"
+	"github.com/Azure/kube-egress-gateway/pkg/metrics"
+)
+
+const (
+	// multusCNIVersion is the cniVersion of the network attachment, the highest supported by Multus
+	multusCNIVersion = "1.0.0"
+	// multusRetryPeriod is how often the network attachment is applied until it succeeds
+	multusRetryPeriod = 10 * time.Second
+)
+
+// NetworkAttachmentDefinitionGVK is the kind of Multus network attachments. The type is not vendored, it's only
+// read and written as unstructured objects.
+var NetworkAttachmentDefinitionGVK = schema.GroupVersionKind{Group: "k8s.cni.cncf.io", Version: "v1", Kind: "NetworkAttachmentDefinition"}
+
+// multusNetworkConf returns the conflist of the network attachment, with our plugin alone.
+func (mgr *Manager) multusNetworkConf() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"cniVersion": multusCNIVersion,
+		"name":       mgr.multusNetwork,
+		"plugins":    []interface{}{mgr.egressGatewayPluginConf()},
+	})
+}
+
+// applyNetworkAttachment creates or updates the network attachment in the namespace of cniManager pod. The config
+// is the same on every node, so that cniManagers don't fight over it.
+func (mgr *Manager) applyNetworkAttachment(ctx context.Context) error {
+	config, err := mgr.multusNetworkConf()
+	if err != nil {
+		return fmt.Errorf("failed to marshal network attachment config: %w", err)
+	}
+	nad := &unstructured.Unstructured{}
+	nad.SetGroupVersionKind(NetworkAttachmentDefinitionGVK)
+	key := client.ObjectKey{Name: mgr.multusNetwork, Namespace: os.Getenv(consts.PodNamespaceEnvKey)}
+	if err := mgr.k8sClient.Get(ctx, key, nad); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to get NetworkAttachmentDefinition(%s): %w", key, err)
+		}
+		nad.SetName(key.Name)
+		nad.SetNamespace(key.Namespace)
+		if err := unstructured.SetNestedField(nad.Object, string(config), "spec", "config"); err != nil {
+			return err
+		}
+		if err := mgr.k8sClient.Create(ctx, nad); err != nil {
+			return fmt.Errorf("failed to create NetworkAttachmentDefinition(%s): %w", key, err)
+		}
+		return nil
+	}
+	if existing, _, _ := unstructured.NestedString(nad.Object, "spec", "config"); existing == string(config) {
+		return nil
+	}
+	if err := unstructured.SetNestedField(nad.Object, string(config), "spec", "config"); err != nil {
+		return err
+	}
+	if err := mgr.k8sClient.Update(ctx, nad); err != nil {
+		return fmt.Errorf("failed to update NetworkAttachmentDefinition(%s): %w", key, err)
+	}
+	return nil
+}
+
+// startMultus applies the network attachment until it succeeds, then waits for ctx to be done. The network
+// attachment is shared by all nodes and is not removed on exit.
+func (mgr *Manager) startMultus(ctx context.Context) error {
+	log := logger.GetLogger()
+	log.Info("Installing multus network attachment", "name", mgr.multusNetwork)
+	err := wait.PollUntilContextCancel(ctx, multusRetryPeriod, true, func(ctx context.Context) (bool, error) {
+		if err := mgr.applyNetworkAttachment(ctx); err != nil {
+			metrics.CNIManagerConfigOperationFailCount.WithLabelValues("install").Inc()
+			log.Error(err, "failed to apply multus network attachment, retrying")
+			return false, nil
+		}
+		return true, nil
+	})
+	if err != nil {
+		// ctx is done before the network attachment is applied
+		return nil
+	}
+	mgr.setMaster(fmt.Sprintf("NetworkAttachmentDefinition/%s/%s", os.Getenv(consts.PodNamespaceEnvKey), mgr.multusNetwork))
+	<-ctx.Done()
+	return nil
+}
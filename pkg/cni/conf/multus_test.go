@@ -0,0 +1,57 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+package conf
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/Azure/kube-egress-gateway/pkg/consts"
+)
+
+func TestApplyNetworkAttachment(t *testing.T) {
+	_ = os.Setenv(consts.PodNamespaceEnvKey, "kube-egress-gateway-system")
+	defer func() { _ = os.Unsetenv(consts.PodNamespaceEnvKey) }()
+	scheme := runtime.NewScheme()
+	scheme.AddKnownTypeWithName(NetworkAttachmentDefinitionGVK, &unstructured.Unstructured{})
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).Build()
+	mgr := &Manager{
+		k8sClient:      k8sClient,
+		socketPath:     testSocketPath,
+		exceptionCidrs: []string{"10.1.0.0/16"},
+		multusNetwork:  "egressgateway",
+	}
+
+	getConfig := func() string {
+		nad := &unstructured.Unstructured{}
+		nad.SetGroupVersionKind(NetworkAttachmentDefinitionGVK)
+		if err := k8sClient.Get(context.Background(), client.ObjectKey{Name: "egressgateway", Namespace: "kube-egress-gateway-system"}, nad); err != nil {
+			t.Fatalf("failed to get NetworkAttachmentDefinition: %v", err)
+		}
+		config, _, _ := unstructured.NestedString(nad.Object, "spec", "config")
+		return config
+	}
+
+	if err := mgr.applyNetworkAttachment(context.Background()); err != nil {
+		t.Fatalf("applyNetworkAttachment returns unexpected error: %v", err)
+	}
+	expected := `{"cniVersion":"1.0.0","name":"egressgateway","plugins":[{"excludedCIDRs":["10.1.0.0/16"],"ipam":{"type":"kube-egress-cni-ipam"},"socketPath":"localhost:5051","type":"kube-egress-cni"}]}`
+	if config := getConfig(); config != expected {
+		t.Fatalf("NetworkAttachmentDefinition has unexpected config: got: %s, expected: %s", config, expected)
+	}
+
+	mgr.firewallBackend = "nftables"
+	if err := mgr.applyNetworkAttachment(context.Background()); err != nil {
+		t.Fatalf("applyNetworkAttachment returns unexpected error: %v", err)
+	}
+	expected = `{"cniVersion":"1.0.0","name":"egressgateway","plugins":[{"excludedCIDRs":["10.1.0.0/16"],"firewallBackend":"nftables","ipam":{"type":"kube-egress-cni-ipam"},"socketPath":"localhost:5051","type":"kube-egress-cni"}]}`
+	if config := getConfig(); config != expected {
+		t.Fatalf("NetworkAttachmentDefinition has unexpected config: got: %s, expected: %s", config, expected)
+	}
+}
@@ -18,6 +18,7 @@ type IPWrapper struct {
 type IPProvider interface {
 	WithIP(configFunc func(ipamResult *current.Result) error) (err error)
 	DeleteIP() error
+	CheckIP() error
 }
 
 func New(pluginType string, netConf []byte) IPProvider {
@@ -57,3 +58,7 @@ func (wrapper *IPWrapper) WithIP(configFunc func(ipamResult *current.Result) err
 func (wrapper *IPWrapper) DeleteIP() error {
 	return ipam.ExecDel(wrapper.pluginType, wrapper.netConf)
 }
+
+func (wrapper *IPWrapper) CheckIP() error {
+	return ipam.ExecCheck(wrapper.pluginType, wrapper.netConf)
+}
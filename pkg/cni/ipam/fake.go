@@ -19,3 +19,7 @@ func (fake *fakeIPProvider) WithIP(configFunc func(ipamResult *current.Result) e
 func (*fakeIPProvider) DeleteIP() error {
 	return nil
 }
+
+func (*fakeIPProvider) CheckIP() error {
+	return nil
+}
@@ -0,0 +1,113 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+package ipam
+
+import (
+	"errors"
+	"net"
+
+	current "github.com/containernetworking/cni/pkg/types/100"
+	"github.com/containernetworking/plugins/pkg/ns"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+
+	"github.com/Azure/kube-egress-gateway/pkg/consts"
+)
+
+// PodAddresses returns the ipv4 universe address and the ipv6 link local address of pod's eth0.
+func PodAddresses(netnsPath string) (v4Address, v6Address net.IPNet, err error) {
+	podNetNS, err := ns.GetNS(netnsPath)
+	if err != nil {
+		return v4Address, v6Address, err
+	}
+	defer func() { _ = podNetNS.Close() }()
+
+	var ipv4AddrFound, ipv6AddrFound bool
+	err = podNetNS.Do(func(netNS ns.NetNS) error {
+		eth0Link, err := netlink.LinkByName("eth0")
+		if err != nil {
+			return err
+		}
+
+		addrList, err := netlink.AddrList(eth0Link, netlink.FAMILY_V6)
+		if err != nil {
+			return err
+		}
+		for _, item := range addrList {
+			if item.Scope == unix.RT_SCOPE_LINK {
+				v6Address = *item.IPNet
+				ipv6AddrFound = true
+			}
+		}
+
+		addrList, err = netlink.AddrList(eth0Link, netlink.FAMILY_V4)
+		if err != nil {
+			return err
+		}
+		for _, item := range addrList {
+			if item.Scope == unix.RT_SCOPE_UNIVERSE {
+				v4Address = *item.IPNet
+				ipv4AddrFound = true
+			}
+		}
+		return nil
+	})
+
+	if err != nil {
+		return v4Address, v6Address, err
+	}
+	if !ipv4AddrFound {
+		return v4Address, v6Address, errors.New("there is no enough ipv4 addr allocated for this pod")
+	}
+	if !ipv6AddrFound {
+		return v4Address, v6Address, errors.New("there is no enough ipv6 addr allocated for this pod")
+	}
+	return v4Address, v6Address, nil
+}
+
+// PodResult returns the ipam result of kube-egress-cni-ipam plugin for the pod addresses.
+func PodResult(v4Address, v6Address net.IPNet) *current.Result {
+	return &current.Result{
+		CNIVersion: current.ImplementedSpecVersion,
+		IPs: []*current.IPConfig{
+			{
+				Address: v6Address,
+				Gateway: net.ParseIP(consts.GatewayIP),
+			},
+			{
+				Address: v4Address,
+			},
+		},
+	}
+}
+
+type podIPProvider struct {
+	netnsPath string
+}
+
+// NewPodIPProvider returns an IPProvider computing the same result as kube-egress-cni-ipam plugin in process,
+// for callers not invoked by the container runtime, e.g. cni manager attaching a running pod to a gateway.
+func NewPodIPProvider(netnsPath string) IPProvider {
+	return &podIPProvider{netnsPath: netnsPath}
+}
+
+func (p *podIPProvider) WithIP(configFunc func(ipamResult *current.Result) error) error {
+	v4Address, v6Address, err := PodAddresses(p.netnsPath)
+	if err != nil {
+		return err
+	}
+	if configFunc == nil {
+		return errors.New("configure function is nil")
+	}
+	return configFunc(PodResult(v4Address, v6Address))
+}
+
+func (*podIPProvider) DeleteIP() error {
+	// addresses are derived from pod's eth0, nothing is allocated
+	return nil
+}
+
+func (p *podIPProvider) CheckIP() error {
+	_, _, err := PodAddresses(p.netnsPath)
+	return err
+}
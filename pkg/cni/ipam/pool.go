@@ -0,0 +1,347 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+package ipam
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+
+	current "github.com/containernetworking/cni/pkg/types/100"
+	"golang.org/x/sys/unix"
+
+	"github.com/Azure/kube-egress-gateway/pkg/consts"
+)
+
+const (
+	lastReservedFile = "last_reserved_ip"
+	lockFile         = "lock"
+)
+
+// PoolConfig is the ipam config of kube-egress-cni-ipam. With ranges, tunnel addresses are allocated from
+// them, otherwise the addresses of pod's eth0 are used.
+type PoolConfig struct {
+	Type string `json:"type"`
+	// Ranges tunnel addresses are allocated from.
+	Ranges []Range `json:"ranges,omitempty"`
+	// DataDir is the directory allocations are persisted in, defaults to consts.IPAMDataDir.
+	DataDir string `json:"dataDir,omitempty"`
+	// GatewayName is the StaticGatewayConfiguration of the pod in <namespace>/<name> form, it's set by
+	// kube-egress-cni when invoking the ipam plugin.
+	GatewayName string `json:"gatewayName,omitempty"`
+}
+
+// Range is an IPv4 subnet of tunnel addresses. Its first address is the gateway next hop of the pods.
+type Range struct {
+	Subnet string `json:"subnet"`
+	// StaticGatewayConfiguration restricts the range to the pods using the gateway, in <namespace>/<name>
+	// form. Ranges without it are used by the pods of other gateways.
+	StaticGatewayConfiguration string `json:"staticGatewayConfiguration,omitempty"`
+}
+
+// ParsePoolConfig returns the ipam section of the network config.
+func ParsePoolConfig(netConf []byte) (*PoolConfig, error) {
+	conf := struct {
+		IPAM PoolConfig `json:"ipam"`
+	}{}
+	if err := json.Unmarshal(netConf, &conf); err != nil {
+		return nil, fmt.Errorf("failed to parse ipam configuration: %w", err)
+	}
+	return &conf.IPAM, nil
+}
+
+// ParseRanges parses a comma separated list of ranges, each one being a subnet optionally prefixed with
+// the gateway it's restricted to, e.g. "100.64.1.0/24,default/gw1=100.65.1.0/24".
+func ParseRanges(ranges string) ([]Range, error) {
+	var res []Range
+	for _, item := range strings.Split(ranges, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		r := Range{Subnet: item}
+		if gateway, subnet, ok := strings.Cut(item, "="); ok {
+			if len(strings.Split(gateway, "/")) != 2 {
+				return nil, fmt.Errorf("gateway %s of range %s is not in <namespace>/<name> form", gateway, item)
+			}
+			r = Range{Subnet: subnet, StaticGatewayConfiguration: gateway}
+		}
+		if _, err := parseSubnet(r.Subnet); err != nil {
+			return nil, err
+		}
+		res = append(res, r)
+	}
+	return res, nil
+}
+
+// SetGatewayName returns the network config with the gateway of the pod set in its ipam section.
+func SetGatewayName(netConf []byte, gatewayName string) ([]byte, error) {
+	rawConf := make(map[string]interface{})
+	if err := json.Unmarshal(netConf, &rawConf); err != nil {
+		return nil, fmt.Errorf("failed to parse network configuration: %w", err)
+	}
+	rawIPAM, ok := rawConf["ipam"].(map[string]interface{})
+	if !ok {
+		return nil, errors.New("ipam should not be empty")
+	}
+	rawIPAM["gatewayName"] = gatewayName
+	return json.Marshal(rawConf)
+}
+
+func parseSubnet(subnet string) (netip.Prefix, error) {
+	prefix, err := netip.ParsePrefix(subnet)
+	if err != nil {
+		return prefix, fmt.Errorf("subnet %s is not valid: %w", subnet, err)
+	}
+	if !prefix.Addr().Is4() || prefix.Bits() > 30 {
+		return prefix, fmt.Errorf("subnet %s must be an IPv4 subnet of at least 4 addresses", subnet)
+	}
+	return prefix.Masked(), nil
+}
+
+// Pool allocates tunnel addresses from ranges and persists allocations in files, one per address named after
+// it and containing the container ID, under a directory per range. Allocations are serialized with a file
+// lock, as cni plugin invocations for different pods run concurrently.
+type Pool struct {
+	config PoolConfig
+	ranges []netip.Prefix
+}
+
+func NewPool(config PoolConfig) (*Pool, error) {
+	if len(config.Ranges) == 0 {
+		return nil, errors.New("no range is configured")
+	}
+	if config.DataDir == "" {
+		config.DataDir = consts.IPAMDataDir
+	}
+	pool := &Pool{config: config}
+	for _, r := range config.Ranges {
+		prefix, err := parseSubnet(r.Subnet)
+		if err != nil {
+			return nil, err
+		}
+		pool.ranges = append(pool.ranges, prefix)
+	}
+	return pool, nil
+}
+
+// Allocate returns the ipam result with the tunnel address of the container, allocated from the range of the
+// gateway. The same address is returned if the container already has one.
+func (p *Pool) Allocate(containerID, gatewayName string) (*current.Result, error) {
+	subnet, err := p.selectRange(gatewayName)
+	if err != nil {
+		return nil, err
+	}
+	dir := p.rangeDir(subnet)
+	var allocated netip.Addr
+	err = withLock(dir, func() error {
+		if allocated, err = findAllocation(dir, containerID); err != nil || allocated.IsValid() {
+			return err
+		}
+		// start after the last reserved address, so that released addresses are not reused right away
+		first, last := subnet.Addr().Next().Next(), lastAddr(subnet).Prev()
+		start := first
+		if data, err := os.ReadFile(filepath.Join(dir, lastReservedFile)); err == nil {
+			if addr, err := netip.ParseAddr(strings.TrimSpace(string(data))); err == nil && addr.Compare(first) >= 0 && addr.Compare(last) < 0 {
+				start = addr.Next()
+			}
+		}
+		addr := start
+		for {
+			f, err := os.OpenFile(filepath.Join(dir, addr.String()), os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+			if err == nil {
+				_, err = f.WriteString(containerID)
+				if closeErr := f.Close(); err == nil {
+					err = closeErr
+				}
+				if err != nil {
+					_ = os.Remove(f.Name())
+					return fmt.Errorf("failed to reserve %s: %w", addr, err)
+				}
+				allocated = addr
+				return os.WriteFile(filepath.Join(dir, lastReservedFile), []byte(addr.String()), 0600)
+			}
+			if !errors.Is(err, os.ErrExist) {
+				return fmt.Errorf("failed to reserve %s: %w", addr, err)
+			}
+			if addr = addr.Next(); addr.Compare(last) > 0 {
+				addr = first
+			}
+			if addr == start {
+				return fmt.Errorf("no address left in range %s", subnet)
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &current.Result{
+		CNIVersion: current.ImplementedSpecVersion,
+		IPs: []*current.IPConfig{
+			{
+				Address: net.IPNet{IP: allocated.AsSlice(), Mask: net.CIDRMask(subnet.Bits(), 32)},
+				Gateway: subnet.Addr().Next().AsSlice(),
+			},
+		},
+	}, nil
+}
+
+// Release releases the addresses allocated to the container, releasing a container without address is not an error.
+func (p *Pool) Release(containerID string) error {
+	var errs []error
+	for _, subnet := range p.ranges {
+		dir := p.rangeDir(subnet)
+		if _, err := os.Stat(dir); errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		err := withLock(dir, func() error {
+			for {
+				addr, err := findAllocation(dir, containerID)
+				if err != nil || !addr.IsValid() {
+					return err
+				}
+				if err := os.Remove(filepath.Join(dir, addr.String())); err != nil {
+					return fmt.Errorf("failed to release %s: %w", addr, err)
+				}
+			}
+		})
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Check returns an error if the container has no allocated address.
+func (p *Pool) Check(containerID string) error {
+	for _, subnet := range p.ranges {
+		addr, err := findAllocation(p.rangeDir(subnet), containerID)
+		if err != nil {
+			return err
+		}
+		if addr.IsValid() {
+			return nil
+		}
+	}
+	return fmt.Errorf("no address is allocated to container %s", containerID)
+}
+
+// selectRange returns the range of the gateway, or the first range not restricted to a gateway.
+func (p *Pool) selectRange(gatewayName string) (netip.Prefix, error) {
+	for i, r := range p.config.Ranges {
+		if gatewayName != "" && r.StaticGatewayConfiguration == gatewayName {
+			return p.ranges[i], nil
+		}
+	}
+	for i, r := range p.config.Ranges {
+		if r.StaticGatewayConfiguration == "" {
+			return p.ranges[i], nil
+		}
+	}
+	return netip.Prefix{}, fmt.Errorf("no range is configured for gateway %s", gatewayName)
+}
+
+func (p *Pool) rangeDir(subnet netip.Prefix) string {
+	return filepath.Join(p.config.DataDir, strings.ReplaceAll(subnet.String(), "/", "_"))
+}
+
+// findAllocation returns the address allocated to the container in the range directory, an invalid address if none.
+func findAllocation(dir, containerID string) (netip.Addr, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return netip.Addr{}, nil
+		}
+		return netip.Addr{}, fmt.Errorf("failed to read ipam data directory: %w", err)
+	}
+	for _, entry := range entries {
+		addr, err := netip.ParseAddr(entry.Name())
+		if err != nil {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return netip.Addr{}, fmt.Errorf("failed to read allocation of %s: %w", addr, err)
+		}
+		if strings.TrimSpace(string(data)) == containerID {
+			return addr, nil
+		}
+	}
+	return netip.Addr{}, nil
+}
+
+func withLock(dir string, f func() error) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create ipam data directory: %w", err)
+	}
+	lock, err := os.OpenFile(filepath.Join(dir, lockFile), os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open ipam lock: %w", err)
+	}
+	defer func() { _ = lock.Close() }()
+	if err := unix.Flock(int(lock.Fd()), unix.LOCK_EX); err != nil {
+		return fmt.Errorf("failed to lock ipam data directory: %w", err)
+	}
+	defer func() { _ = unix.Flock(int(lock.Fd()), unix.LOCK_UN) }()
+	return f()
+}
+
+func lastAddr(prefix netip.Prefix) netip.Addr {
+	addr := prefix.Addr().As4()
+	hostBits := 32 - prefix.Bits()
+	for i := 3; i >= 0 && hostBits > 0; i-- {
+		bits := min(hostBits, 8)
+		addr[i] |= byte(1<<bits - 1)
+		hostBits -= bits
+	}
+	return netip.AddrFrom4(addr)
+}
+
+type poolIPProvider struct {
+	pool        *Pool
+	containerID string
+	gatewayName string
+}
+
+// NewPoolIPProvider returns an IPProvider allocating the tunnel address of the container from pool in process,
+// for callers not invoked by the container runtime, e.g. cni manager attaching a running pod to a gateway.
+func NewPoolIPProvider(pool *Pool, containerID, gatewayName string) IPProvider {
+	return &poolIPProvider{pool: pool, containerID: containerID, gatewayName: gatewayName}
+}
+
+func (p *poolIPProvider) WithIP(configFunc func(ipamResult *current.Result) error) (err error) {
+	result, err := p.pool.Allocate(p.containerID, p.gatewayName)
+	if err != nil {
+		return err
+	}
+
+	// release IP in case of failure
+	defer func() {
+		if err != nil {
+			recoverErr := p.DeleteIP()
+			if recoverErr != nil {
+				err = fmt.Errorf("error occurred %w and failed to delete ip: %s", err, recoverErr.Error())
+			}
+		}
+	}()
+	if configFunc == nil {
+		return errors.New("configure function is nil")
+	}
+	return configFunc(result)
+}
+
+func (p *poolIPProvider) DeleteIP() error {
+	return p.pool.Release(p.containerID)
+}
+
+func (p *poolIPProvider) CheckIP() error {
+	return p.pool.Check(p.containerID)
+}
@@ -0,0 +1,103 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+package ipam
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPoolAllocate(t *testing.T) {
+	pool, err := NewPool(PoolConfig{
+		DataDir: t.TempDir(),
+		Ranges: []Range{
+			{Subnet: "100.64.1.0/29"},
+			{Subnet: "100.65.1.0/30", StaticGatewayConfiguration: "default/gw1"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewPool returns unexpected error: %v", err)
+	}
+
+	allocate := func(containerID, gatewayName string) string {
+		result, err := pool.Allocate(containerID, gatewayName)
+		if err != nil {
+			t.Fatalf("Allocate(%s, %s) returns unexpected error: %v", containerID, gatewayName, err)
+		}
+		if len(result.IPs) != 1 {
+			t.Fatalf("Allocate(%s, %s) returns %d IPs, expected 1", containerID, gatewayName, len(result.IPs))
+		}
+		return result.IPs[0].Address.String() + " via " + result.IPs[0].Gateway.String()
+	}
+	if got := allocate("c1", "default/gw0"); got != "100.64.1.2/29 via 100.64.1.1" {
+		t.Fatalf("Allocate returns %s for c1", got)
+	}
+	if got := allocate("c1", "default/gw0"); got != "100.64.1.2/29 via 100.64.1.1" {
+		t.Fatalf("Allocate returns %s for c1 again", got)
+	}
+	if got := allocate("c2", "default/gw1"); got != "100.65.1.2/30 via 100.65.1.1" {
+		t.Fatalf("Allocate returns %s for c2", got)
+	}
+	if _, err := pool.Allocate("c3", "default/gw1"); err == nil {
+		t.Fatalf("Allocate does not return error when range is exhausted")
+	}
+
+	var allocated []string
+	for _, containerID := range []string{"c3", "c4", "c5", "c6"} {
+		allocated = append(allocated, allocate(containerID, ""))
+	}
+	expected := []string{"100.64.1.3/29 via 100.64.1.1", "100.64.1.4/29 via 100.64.1.1", "100.64.1.5/29 via 100.64.1.1", "100.64.1.6/29 via 100.64.1.1"}
+	if !reflect.DeepEqual(allocated, expected) {
+		t.Fatalf("Allocate returns %v, expected %v", allocated, expected)
+	}
+	if _, err := pool.Allocate("c7", ""); err == nil {
+		t.Fatalf("Allocate does not return error when range is exhausted")
+	}
+
+	if err := pool.Release("c4"); err != nil {
+		t.Fatalf("Release returns unexpected error: %v", err)
+	}
+	if err := pool.Release("c4"); err != nil {
+		t.Fatalf("Release returns unexpected error for released container: %v", err)
+	}
+	if err := pool.Check("c4"); err == nil {
+		t.Fatalf("Check does not return error for released container")
+	}
+	if err := pool.Check("c2"); err != nil {
+		t.Fatalf("Check returns unexpected error: %v", err)
+	}
+	if got := allocate("c7", ""); got != "100.64.1.4/29 via 100.64.1.1" {
+		t.Fatalf("Allocate returns %s for c7, expected released address", got)
+	}
+}
+
+func TestParseRanges(t *testing.T) {
+	ranges, err := ParseRanges("100.64.1.0/24, default/gw1=100.65.1.0/24")
+	if err != nil {
+		t.Fatalf("ParseRanges returns unexpected error: %v", err)
+	}
+	expected := []Range{{Subnet: "100.64.1.0/24"}, {Subnet: "100.65.1.0/24", StaticGatewayConfiguration: "default/gw1"}}
+	if !reflect.DeepEqual(ranges, expected) {
+		t.Fatalf("ParseRanges returns %v, expected %v", ranges, expected)
+	}
+	for _, invalid := range []string{"100.64.1.0/31", "fd00::/64", "gw1=100.64.1.0/24", "100.64.1.0"} {
+		if _, err := ParseRanges(invalid); err == nil {
+			t.Fatalf("ParseRanges does not return error for %s", invalid)
+		}
+	}
+}
+
+func TestSetGatewayName(t *testing.T) {
+	netConf, err := SetGatewayName([]byte(`{"cniVersion":"1.0.0","ipam":{"type":"kube-egress-cni-ipam","ranges":[{"subnet":"100.64.1.0/24"}]}}`), "default/gw1")
+	if err != nil {
+		t.Fatalf("SetGatewayName returns unexpected error: %v", err)
+	}
+	config, err := ParsePoolConfig(netConf)
+	if err != nil {
+		t.Fatalf("ParsePoolConfig returns unexpected error: %v", err)
+	}
+	expected := &PoolConfig{Type: "kube-egress-cni-ipam", Ranges: []Range{{Subnet: "100.64.1.0/24"}}, GatewayName: "default/gw1"}
+	if !reflect.DeepEqual(config, expected) {
+		t.Fatalf("ParsePoolConfig returns %+v, expected %+v", config, expected)
+	}
+}
@@ -3,12 +3,12 @@
 package routes
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net"
 	"os"
 	"path/filepath"
-	"strconv"
 
 	"github.com/containernetworking/cni/pkg/types"
 	current "github.com/containernetworking/cni/pkg/types/100"
@@ -18,7 +18,7 @@ import (
 
 	v1 "github.com/Azure/kube-egress-gateway/pkg/cniprotocol/v1"
 	"github.com/Azure/kube-egress-gateway/pkg/consts"
-	"github.com/Azure/kube-egress-gateway/pkg/iptableswrapper"
+	"github.com/Azure/kube-egress-gateway/pkg/netfilter"
 	"github.com/Azure/kube-egress-gateway/pkg/netlinkwrapper"
 )
 
@@ -28,22 +28,24 @@ type nicSettings interface {
 }
 
 type runner struct {
-	netlink  netlinkwrapper.Interface
-	iptables iptableswrapper.Interface
+	netlink netlinkwrapper.Interface
+	// netfilter is created lazily, inside pod network namespace
+	netfilter func(netfilter.Backend) (netfilter.Interface, error)
 }
 
 var routesRunner runner
 
 func init() {
 	routesRunner = runner{
-		netlink:  netlinkwrapper.NewNetLink(),
-		iptables: iptableswrapper.NewIPTables(),
+		netlink:   netlinkwrapper.NewNetLink(),
+		netfilter: netfilter.New,
 	}
 }
 
 // SetPodRoutes sets up the routes for the pod based on the provided nic settings.
 // excludedCIDRs are the CIDRs that should not go through the egress gateway.
-func SetPodRoutes(ifName string, nic nicSettings, excludedCIDRs []string, sysctlDir string, result *current.Result) error {
+// firewallBackend is the backend used to program the pod netfilter rules.
+func SetPodRoutes(ifName string, nic nicSettings, excludedCIDRs []string, firewallBackend netfilter.Backend, sysctlDir string, result *current.Result) error {
 	exceptionCidrs := nic.GetExceptionCidrs()
 	defaultToGateway := nic.GetDefaultRoute() == v1.DefaultRoute_DEFAULT_ROUTE_STATIC_EGRESS_GATEWAY
 	if defaultToGateway {
@@ -81,15 +83,9 @@ func SetPodRoutes(ifName string, nic nicSettings, excludedCIDRs []string, sysctl
 		Protocol:  unix.RTPROT_STATIC,
 	}
 
-	wgRouteTmpl := netlink.Route{
-		Gw: nil,
-		Via: &netlink.Via{
-			Addr:       net.ParseIP("fe80::1"),
-			AddrFamily: nl.FAMILY_V6,
-		},
-		LinkIndex: wgLink.Attrs().Index,
-		Scope:     netlink.SCOPE_UNIVERSE,
-		Family:    nl.FAMILY_V4,
+	wgRouteTmpl, nextHop, err := wgRouteTemplate(wgLink)
+	if err != nil {
+		return err
 	}
 
 	if defaultToGateway {
@@ -118,7 +114,7 @@ func SetPodRoutes(ifName string, nic nicSettings, excludedCIDRs []string, sysctl
 		_, defaultRouteCidr, _ := net.ParseCIDR("0.0.0.0/0")
 		wgDefaultRoute := wgRouteTmpl
 		wgDefaultRoute.Dst = defaultRouteCidr
-		result.Routes = append(result.Routes, &types.Route{Dst: *defaultRouteCidr, GW: net.ParseIP("fe80::1")})
+		result.Routes = append(result.Routes, &types.Route{Dst: *defaultRouteCidr, GW: nextHop})
 
 		err = routesRunner.netlink.RouteReplace(&wgDefaultRoute)
 		if err != nil {
@@ -138,7 +134,7 @@ func SetPodRoutes(ifName string, nic nicSettings, excludedCIDRs []string, sysctl
 			gwIP = defaultRoute.Gw
 		} else {
 			gatewayRoute = wgRouteTmpl
-			gwIP = net.ParseIP("fe80::1")
+			gwIP = nextHop
 		}
 		gatewayRoute.Dst = cidr
 		err = routesRunner.netlink.RouteReplace(&gatewayRoute)
@@ -148,27 +144,21 @@ func SetPodRoutes(ifName string, nic nicSettings, excludedCIDRs []string, sysctl
 		result.Routes = append(result.Routes, &types.Route{Dst: *cidr, GW: gwIP})
 	}
 
-	err = addRoutingForIngress(eth0Link, *defaultRoute, sysctlDir)
+	err = addRoutingForIngress(eth0Link, *defaultRoute, firewallBackend, sysctlDir)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func addRoutingForIngress(eth0Link netlink.Link, defaultRoute netlink.Route, sysctlDir string) error {
-	// add iptables rule to mark traffic from eth0
-	ipt, err := routesRunner.iptables.New()
+func addRoutingForIngress(eth0Link netlink.Link, defaultRoute netlink.Route, firewallBackend netfilter.Backend, sysctlDir string) error {
+	// add netfilter rules to mark traffic from eth0
+	nf, err := routesRunner.netfilter(firewallBackend)
 	if err != nil {
-		return fmt.Errorf("failed to create iptable: %w", err)
-	}
-	if err := ipt.AppendUnique(consts.MangleTable, consts.PreRoutingChain, "-i", "eth0", "-j", "MARK", "--set-mark", strconv.Itoa(consts.Eth0Mark)); err != nil {
-		return fmt.Errorf("failed to append iptables set-mark rule: %w", err)
+		return fmt.Errorf("failed to create %s interface: %w", firewallBackend, err)
 	}
-	if err := ipt.AppendUnique(consts.MangleTable, consts.PreRoutingChain, "-j", "CONNMARK", "--save-mark"); err != nil {
-		return fmt.Errorf("failed to append iptables save-mark rule: %w", err)
-	}
-	if err := ipt.AppendUnique(consts.MangleTable, consts.OutputChain, "-m", "connmark", "--mark", strconv.Itoa(consts.Eth0Mark), "-j", "CONNMARK", "--restore-mark"); err != nil {
-		return fmt.Errorf("failed to append iptables restore-mark rule: %w", err)
+	if err := nf.EnsureIngressConnMark(context.Background(), eth0Link.Attrs().Name, consts.Eth0Mark); err != nil {
+		return fmt.Errorf("failed to ensure ingress connmark rules: %w", err)
 	}
 
 	// add ip rule: lookup separate routing table if packet is marked
@@ -194,3 +184,327 @@ func addRoutingForIngress(eth0Link netlink.Link, defaultRoute netlink.Route, sys
 	}
 	return nil
 }
+
+// CheckPodRoutes checks that the routes and ingress routing set up by SetPodRoutes are still in place.
+// It must be called in the pod network namespace.
+func CheckPodRoutes(ifName string, nic nicSettings, excludedCIDRs []string, firewallBackend netfilter.Backend) error {
+	exceptionCidrs := nic.GetExceptionCidrs()
+	defaultToGateway := nic.GetDefaultRoute() == v1.DefaultRoute_DEFAULT_ROUTE_STATIC_EGRESS_GATEWAY
+	if defaultToGateway {
+		exceptionCidrs = append(exceptionCidrs, excludedCIDRs...)
+	}
+	eth0Link, err := routesRunner.netlink.LinkByName("eth0")
+	if err != nil {
+		return fmt.Errorf("failed to retrieve eth0 interface: %w", err)
+	}
+
+	wgLink, err := routesRunner.netlink.LinkByName(ifName)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve wireguard interface: %w", err)
+	}
+
+	// the original default route is kept in the ingress routing table
+	defaultRoute, err := checkRoutingForIngress(eth0Link, firewallBackend)
+	if err != nil {
+		return err
+	}
+
+	eth0Routes, err := routesRunner.netlink.RouteList(eth0Link, nl.FAMILY_ALL)
+	if err != nil {
+		return fmt.Errorf("failed to list all routes on eth0: %w", err)
+	}
+	wgRoutes, err := routesRunner.netlink.RouteList(wgLink, nl.FAMILY_ALL)
+	if err != nil {
+		return fmt.Errorf("failed to list all routes on %s: %w", ifName, err)
+	}
+
+	if defaultToGateway {
+		gatewayDestination := net.IPNet{IP: defaultRoute.Gw, Mask: net.CIDRMask(32, 32)}
+		if !hasRoute(eth0Routes, func(route netlink.Route) bool {
+			return route.Dst != nil && route.Dst.String() == gatewayDestination.String()
+		}) {
+			return fmt.Errorf("route to original gateway %s via eth0 not found", defaultRoute.Gw)
+		}
+		if !hasRoute(wgRoutes, isDefaultRoute) {
+			return fmt.Errorf("default route via %s not found", ifName)
+		}
+	}
+
+	for _, exception := range exceptionCidrs {
+		_, cidr, err := net.ParseCIDR(exception)
+		if err != nil {
+			return fmt.Errorf("failed to parse cidr (%s): %w", exception, err)
+		}
+		if defaultToGateway {
+			if !hasRoute(eth0Routes, func(route netlink.Route) bool {
+				return route.Dst != nil && route.Dst.String() == cidr.String() && route.Gw.Equal(defaultRoute.Gw)
+			}) {
+				return fmt.Errorf("route to %s via eth0 not found", cidr)
+			}
+		} else if !hasRoute(wgRoutes, func(route netlink.Route) bool {
+			return route.Dst != nil && route.Dst.String() == cidr.String()
+		}) {
+			return fmt.Errorf("route to %s via %s not found", cidr, ifName)
+		}
+	}
+	return nil
+}
+
+// checkRoutingForIngress checks the rules added by addRoutingForIngress and returns the original default route.
+func checkRoutingForIngress(eth0Link netlink.Link, firewallBackend netfilter.Backend) (*netlink.Route, error) {
+	nf, err := routesRunner.netfilter(firewallBackend)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s interface: %w", firewallBackend, err)
+	}
+	ok, err := nf.CheckIngressConnMark(context.Background(), eth0Link.Attrs().Name, consts.Eth0Mark)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check ingress connmark rules: %w", err)
+	}
+	if !ok {
+		return nil, errors.New("ingress connmark rules not found")
+	}
+
+	rules, err := routesRunner.netlink.RuleList(nl.FAMILY_V4)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list routing rules: %w", err)
+	}
+	found := false
+	for _, rule := range rules {
+		if rule.Mark == uint32(consts.Eth0Mark) && rule.Table == consts.Eth0Mark {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("routing rule for mark %d not found", consts.Eth0Mark)
+	}
+
+	return ingressDefaultRoute(eth0Link)
+}
+
+// ingressDefaultRoute returns the original default route of eth0 kept in the ingress routing table.
+func ingressDefaultRoute(eth0Link netlink.Link) (*netlink.Route, error) {
+	routes, err := routesRunner.netlink.RouteListFiltered(nl.FAMILY_V4, &netlink.Route{Table: consts.Eth0Mark}, netlink.RT_FILTER_TABLE)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list routes in table %d: %w", consts.Eth0Mark, err)
+	}
+	for _, route := range routes {
+		if route.LinkIndex == eth0Link.Attrs().Index && isDefaultRoute(route) {
+			return &route, nil
+		}
+	}
+	return nil, fmt.Errorf("default route via eth0 not found in table %d", consts.Eth0Mark)
+}
+
+// UpdatePodRoutes updates the routes set up by SetPodRoutes in place after the nic settings of a running
+// pod changed: routes of removed exception cidrs are deleted, and the default route and exception routes
+// are moved between eth0 and the wireguard interface as needed. It must be called in the pod network namespace.
+func UpdatePodRoutes(ifName string, nic nicSettings, excludedCIDRs []string) error {
+	exceptionCidrs := nic.GetExceptionCidrs()
+	defaultToGateway := nic.GetDefaultRoute() == v1.DefaultRoute_DEFAULT_ROUTE_STATIC_EGRESS_GATEWAY
+	if defaultToGateway {
+		exceptionCidrs = append(exceptionCidrs, excludedCIDRs...)
+	}
+	eth0Link, err := routesRunner.netlink.LinkByName("eth0")
+	if err != nil {
+		return fmt.Errorf("failed to retrieve eth0 interface: %w", err)
+	}
+
+	wgLink, err := routesRunner.netlink.LinkByName(ifName)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve wireguard interface: %w", err)
+	}
+
+	// the original default route is kept in the ingress routing table
+	defaultRoute, err := ingressDefaultRoute(eth0Link)
+	if err != nil {
+		return err
+	}
+
+	eth0RouteTmpl := netlink.Route{
+		Gw:        defaultRoute.Gw,
+		LinkIndex: eth0Link.Attrs().Index,
+		Protocol:  unix.RTPROT_STATIC,
+	}
+	wgRouteTmpl, _, err := wgRouteTemplate(wgLink)
+	if err != nil {
+		return err
+	}
+
+	var desired []netlink.Route
+	_, defaultRouteCidr, _ := net.ParseCIDR("0.0.0.0/0")
+	if defaultToGateway {
+		desired = append(desired, netlink.Route{
+			Dst:       &net.IPNet{IP: defaultRoute.Gw, Mask: net.CIDRMask(32, 32)},
+			LinkIndex: eth0Link.Attrs().Index,
+			Scope:     netlink.SCOPE_LINK,
+		})
+		wgDefaultRoute := wgRouteTmpl
+		wgDefaultRoute.Dst = defaultRouteCidr
+		desired = append(desired, wgDefaultRoute)
+	} else {
+		eth0DefaultRoute := eth0RouteTmpl
+		eth0DefaultRoute.Dst = defaultRouteCidr
+		desired = append(desired, eth0DefaultRoute)
+	}
+	for _, exception := range exceptionCidrs {
+		_, cidr, err := net.ParseCIDR(exception)
+		if err != nil {
+			return fmt.Errorf("failed to parse cidr (%s): %w", exception, err)
+		}
+		route := wgRouteTmpl
+		if defaultToGateway {
+			route = eth0RouteTmpl
+		}
+		route.Dst = cidr
+		desired = append(desired, route)
+	}
+
+	// delete exception routes no longer desired, default routes are moved by RouteReplace below so
+	// that the pod never lacks a default route. Routes on eth0 not added by us are kept.
+	eth0Routes, err := routesRunner.netlink.RouteList(eth0Link, nl.FAMILY_V4)
+	if err != nil {
+		return fmt.Errorf("failed to list routes on eth0: %w", err)
+	}
+	wgRoutes, err := routesRunner.netlink.RouteList(wgLink, nl.FAMILY_V4)
+	if err != nil {
+		return fmt.Errorf("failed to list routes on %s: %w", ifName, err)
+	}
+	for _, route := range append(eth0Routes, wgRoutes...) {
+		if isDefaultRoute(route) || (route.LinkIndex == eth0Link.Attrs().Index && route.Protocol != unix.RTPROT_STATIC) {
+			continue
+		}
+		if hasRoute(desired, func(r netlink.Route) bool { return r.Dst.String() == route.Dst.String() }) {
+			continue
+		}
+		if err := routesRunner.netlink.RouteDel(&route); err != nil {
+			return fmt.Errorf("failed to delete route (%s): %w", route, err)
+		}
+	}
+
+	for _, route := range desired {
+		if err := routesRunner.netlink.RouteReplace(&route); err != nil {
+			return fmt.Errorf("failed to replace route (%s): %w", route, err)
+		}
+	}
+	return nil
+}
+
+// Eth0Routes returns the routes of eth0 in the main table, which SetPodRoutes replaces when the gateway
+// is the default route. They are recorded before a running pod is attached to a gateway so that
+// RestorePodRoutes can add them back. It must be called in the pod network namespace.
+func Eth0Routes() ([]netlink.Route, error) {
+	eth0Link, err := routesRunner.netlink.LinkByName("eth0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve eth0 interface: %w", err)
+	}
+	routes, err := routesRunner.netlink.RouteList(eth0Link, nl.FAMILY_ALL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list all routes on eth0: %w", err)
+	}
+	return routes, nil
+}
+
+// RestorePodRoutes reverts SetPodRoutes after the wireguard interface of the pod is deleted: eth0 routes
+// are set back to originalRoutes, as returned by Eth0Routes before the pod was attached, and the ingress
+// routing rule and table are removed. The ingress connmark rules are kept, they are harmless without the
+// rule and are reused if the pod is attached again. It must be called in the pod network namespace.
+func RestorePodRoutes(originalRoutes []netlink.Route) error {
+	eth0Link, err := routesRunner.netlink.LinkByName("eth0")
+	if err != nil {
+		return fmt.Errorf("failed to retrieve eth0 interface: %w", err)
+	}
+
+	original := make(map[string]bool, len(originalRoutes))
+	for _, route := range originalRoutes {
+		route.LinkIndex = eth0Link.Attrs().Index
+		if err := routesRunner.netlink.RouteReplace(&route); err != nil {
+			return fmt.Errorf("failed to restore route (%s): %w", route, err)
+		}
+		original[routeDestination(route)] = true
+	}
+
+	routes, err := routesRunner.netlink.RouteList(eth0Link, nl.FAMILY_ALL)
+	if err != nil {
+		return fmt.Errorf("failed to list all routes on eth0: %w", err)
+	}
+	for _, route := range routes {
+		if original[routeDestination(route)] {
+			continue
+		}
+		if err := routesRunner.netlink.RouteDel(&route); err != nil {
+			return fmt.Errorf("failed to delete route (%s): %w", route, err)
+		}
+	}
+
+	rule := netlink.NewRule()
+	rule.Mark = uint32(consts.Eth0Mark)
+	rule.Table = consts.Eth0Mark
+	if err := routesRunner.netlink.RuleDel(rule); err != nil && !errors.Is(err, unix.ENOENT) {
+		return fmt.Errorf("failed to delete routing rule: %w", err)
+	}
+	ingressRoutes, err := routesRunner.netlink.RouteListFiltered(nl.FAMILY_V4, &netlink.Route{Table: consts.Eth0Mark}, netlink.RT_FILTER_TABLE)
+	if err != nil {
+		return fmt.Errorf("failed to list routes in table %d: %w", consts.Eth0Mark, err)
+	}
+	for _, route := range ingressRoutes {
+		if err := routesRunner.netlink.RouteDel(&route); err != nil {
+			return fmt.Errorf("failed to delete route (%s) in table %d: %w", route, consts.Eth0Mark, err)
+		}
+	}
+	return nil
+}
+
+// routeDestination identifies a route by family and destination, nil destination being the default route.
+func routeDestination(route netlink.Route) string {
+	if route.Dst == nil || route.Dst.IP.IsUnspecified() {
+		return fmt.Sprintf("%d default", route.Family)
+	}
+	return fmt.Sprintf("%d %s", route.Family, route.Dst)
+}
+
+// wgRouteTemplate returns the template of routes via the gateway end of the wireguard interface, and the next hop.
+// With a tunnel address allocated from an ipam range on the interface, the next hop is the first address of the
+// range, otherwise it's the ipv6 link local address of the gateway.
+func wgRouteTemplate(wgLink netlink.Link) (netlink.Route, net.IP, error) {
+	addrs, err := routesRunner.netlink.AddrList(wgLink, nl.FAMILY_V4)
+	if err != nil {
+		return netlink.Route{}, nil, fmt.Errorf("failed to list addresses of %s: %w", wgLink.Attrs().Name, err)
+	}
+	for _, addr := range addrs {
+		if addr.IPNet == nil || addr.IP.To4() == nil {
+			continue
+		}
+		nextHop := addr.IP.To4().Mask(addr.Mask)
+		nextHop[3]++
+		return netlink.Route{
+			Gw:        nextHop,
+			LinkIndex: wgLink.Attrs().Index,
+			Scope:     netlink.SCOPE_UNIVERSE,
+			Family:    nl.FAMILY_V4,
+		}, nextHop, nil
+	}
+	nextHop := net.ParseIP(consts.GatewayLinkLocalIP)
+	return netlink.Route{
+		Via: &netlink.Via{
+			Addr:       nextHop,
+			AddrFamily: nl.FAMILY_V6,
+		},
+		LinkIndex: wgLink.Attrs().Index,
+		Scope:     netlink.SCOPE_UNIVERSE,
+		Family:    nl.FAMILY_V4,
+	}, nextHop, nil
+}
+
+func hasRoute(routes []netlink.Route, match func(netlink.Route) bool) bool {
+	for _, route := range routes {
+		if match(route) {
+			return true
+		}
+	}
+	return false
+}
+
+func isDefaultRoute(route netlink.Route) bool {
+	return route.Dst == nil || route.Dst.String() == "0.0.0.0/0"
+}
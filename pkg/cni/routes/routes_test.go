@@ -3,9 +3,13 @@
 package routes
 
 import (
+	"bytes"
+	"context"
+	"fmt"
 	"net"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/containernetworking/cni/pkg/types"
@@ -14,9 +18,11 @@ import (
 	"github.com/vishvananda/netlink/nl"
 	"go.uber.org/mock/gomock"
 	"golang.org/x/sys/unix"
+	utiliptables "k8s.io/kubernetes/pkg/util/iptables"
 
 	v1 "github.com/Azure/kube-egress-gateway/pkg/cniprotocol/v1"
-	"github.com/Azure/kube-egress-gateway/pkg/iptableswrapper/mockiptableswrapper"
+	fakeiptables "github.com/Azure/kube-egress-gateway/pkg/iptableswrapper"
+	"github.com/Azure/kube-egress-gateway/pkg/netfilter"
 	"github.com/Azure/kube-egress-gateway/pkg/netlinkwrapper/mocknetlinkwrapper"
 )
 
@@ -26,6 +32,15 @@ const (
 	eth0Dir  = "testdata/net/ipv4/conf/eth0"
 	allFile  = allDir + "/rp_filter"
 	eth0File = eth0Dir + "/rp_filter"
+
+	mangleBuiltinChains = `*mangle
+:PREROUTING - [0:0]
+:INPUT - [0:0]
+:FORWARD - [0:0]
+:OUTPUT - [0:0]
+:POSTROUTING - [0:0]
+COMMIT
+`
 )
 
 type testNicSettings struct {
@@ -45,11 +60,15 @@ func TestSetPodRoutes(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 	mnl := mocknetlinkwrapper.NewMockInterface(ctrl)
-	mipt := mockiptableswrapper.NewMockInterface(ctrl)
-	mtable := mockiptableswrapper.NewMockIpTables(ctrl)
+	var fipt *fakeiptables.FakeIPTables
 	routesRunner = runner{
-		netlink:  mnl,
-		iptables: mipt,
+		netlink: mnl,
+		netfilter: func(backend netfilter.Backend) (netfilter.Interface, error) {
+			if backend != netfilter.BackendIPTables {
+				t.Fatalf("Got unexpected firewall backend: %s", backend)
+			}
+			return netfilter.NewIPTables(fipt), nil
+		},
 	}
 
 	eth0 := &netlink.Device{LinkAttrs: netlink.LinkAttrs{Name: "eth0", Index: 1}}
@@ -92,6 +111,8 @@ func TestSetPodRoutes(t *testing.T) {
 			mnl.EXPECT().LinkByName("wg0").Return(wg0, nil),
 			// get existing routes
 			mnl.EXPECT().RouteList(eth0, netlink.FAMILY_ALL).Return(existingRoutes, nil),
+			// get tunnel address of wg0
+			mnl.EXPECT().AddrList(wg0, nl.FAMILY_V4).Return(nil, nil),
 			// delete existing routes
 			mnl.EXPECT().RouteDel(&existingRoutes[0]).Return(nil),
 			mnl.EXPECT().RouteDel(&existingRoutes[1]).Return(nil),
@@ -153,6 +174,8 @@ func TestSetPodRoutes(t *testing.T) {
 			mnl.EXPECT().LinkByName("wg0").Return(wg0, nil),
 			// get existing routes
 			mnl.EXPECT().RouteList(eth0, netlink.FAMILY_ALL).Return(existingRoutes, nil),
+			// get tunnel address of wg0
+			mnl.EXPECT().AddrList(wg0, nl.FAMILY_V4).Return(nil, nil),
 			// add routes to exceptional CIDRs via wg0
 			mnl.EXPECT().RouteReplace(&netlink.Route{
 				Dst: net1,
@@ -223,13 +246,12 @@ func TestSetPodRoutes(t *testing.T) {
 		},
 	}
 	for _, test := range tests {
+		fipt = fakeiptables.NewFake()
+		if err := fipt.RestoreAll([]byte(mangleBuiltinChains), utiliptables.NoFlushTables, utiliptables.NoRestoreCounters); err != nil {
+			t.Fatalf("Failed to create mangle builtin chains: %v", err)
+		}
 		test.routeSetupProcess()
 		gomock.InOrder(
-			// add iptables rules
-			mipt.EXPECT().New().Return(mtable, nil),
-			mtable.EXPECT().AppendUnique("mangle", "PREROUTING", "-i", "eth0", "-j", "MARK", "--set-mark", "8738").Return(nil),
-			mtable.EXPECT().AppendUnique("mangle", "PREROUTING", "-j", "CONNMARK", "--save-mark").Return(nil),
-			mtable.EXPECT().AppendUnique("mangle", "OUTPUT", "-m", "connmark", "--mark", "8738", "-j", "CONNMARK", "--restore-mark").Return(nil),
 			mnl.EXPECT().RuleAdd(rule).Return(nil),
 			// add route in 8738 table
 			mnl.EXPECT().RouteReplace(&defaultRoute).Return(nil),
@@ -260,7 +282,7 @@ func TestSetPodRoutes(t *testing.T) {
 		}
 
 		result := &current.Result{}
-		err := SetPodRoutes("wg0", nic, test.cniExcludedCIDRs, testDir, result)
+		err := SetPodRoutes("wg0", nic, test.cniExcludedCIDRs, netfilter.BackendIPTables, testDir, result)
 		if err != nil {
 			t.Fatalf("SetPodRoutes returns unexpected error: %v", err)
 		}
@@ -269,19 +291,305 @@ func TestSetPodRoutes(t *testing.T) {
 			t.Fatalf("Got unexpected routes in result: %v, expected: %v", result.Routes, test.expectedRouteResult)
 		}
 
-		bytes, err := os.ReadFile(allFile)
+		buf := bytes.NewBuffer(nil)
+		if err := fipt.SaveInto(utiliptables.TableMangle, buf); err != nil {
+			t.Fatalf("Failed to save iptables mangle table: %v", err)
+		}
+		for _, rule := range []string{
+			"-A PREROUTING -i eth0 -j MARK --set-mark 8738",
+			"-A PREROUTING -j CONNMARK --save-mark",
+			"-A OUTPUT -m connmark --mark 8738 -j CONNMARK --restore-mark",
+		} {
+			if !strings.Contains(buf.String(), rule) {
+				t.Fatalf("Expected iptables rule %q not found in mangle table: %s", rule, buf.String())
+			}
+		}
+
+		data, err := os.ReadFile(allFile)
 		if err != nil {
 			t.Fatalf("Failed to read file %s: %v", allFile, err)
 		}
-		if string(bytes) != "2" {
-			t.Fatalf("Got unexpected data in file %s: %s", allFile, string(bytes))
+		if string(data) != "2" {
+			t.Fatalf("Got unexpected data in file %s: %s", allFile, string(data))
 		}
-		bytes, err = os.ReadFile(eth0File)
+		data, err = os.ReadFile(eth0File)
 		if err != nil {
 			t.Fatalf("Failed to read file %s: %v", eth0File, err)
 		}
-		if string(bytes) != "2" {
-			t.Fatalf("Got unexpected data in file %s: %s", eth0File, string(bytes))
+		if string(data) != "2" {
+			t.Fatalf("Got unexpected data in file %s: %s", eth0File, string(data))
 		}
 	}
 }
+
+func TestCheckPodRoutes(t *testing.T) {
+	eth0 := &netlink.Device{LinkAttrs: netlink.LinkAttrs{Name: "eth0", Index: 1}}
+	wg0 := &netlink.Device{LinkAttrs: netlink.LinkAttrs{Name: "wg0", Index: 2}}
+	defaultGw := net.IPv4(10, 244, 0, 1)
+	_, net1, _ := net.ParseCIDR("1.2.3.4/32")
+	_, dnet, _ := net.ParseCIDR("0.0.0.0/0")
+	rule := netlink.NewRule()
+	rule.Mark = 8738
+	rule.Table = 8738
+	ingressRoutes := []netlink.Route{{Family: nl.FAMILY_V4, Gw: defaultGw, Dst: dnet, LinkIndex: 1, Table: 8738}}
+	gatewayEth0Routes := []netlink.Route{
+		{Dst: &net.IPNet{IP: defaultGw, Mask: net.CIDRMask(32, 32)}, LinkIndex: 1, Scope: netlink.SCOPE_LINK},
+		{Dst: net1, Gw: defaultGw, LinkIndex: 1},
+	}
+	gatewayWgRoutes := []netlink.Route{{Dst: dnet, LinkIndex: 2}}
+
+	tests := []struct {
+		desc             string
+		defaultToGateway bool
+		connMark         bool
+		rules            []netlink.Rule
+		eth0Routes       []netlink.Route
+		wgRoutes         []netlink.Route
+		expectedErr      string
+	}{
+		{
+			desc:             "default to gateway",
+			defaultToGateway: true,
+			connMark:         true,
+			rules:            []netlink.Rule{*rule},
+			eth0Routes:       gatewayEth0Routes,
+			wgRoutes:         gatewayWgRoutes,
+		},
+		{
+			desc:             "default to gateway without default route via wg0",
+			defaultToGateway: true,
+			connMark:         true,
+			rules:            []netlink.Rule{*rule},
+			eth0Routes:       gatewayEth0Routes,
+			expectedErr:      "default route via wg0 not found",
+		},
+		{
+			desc:             "default to gateway without exception route",
+			defaultToGateway: true,
+			connMark:         true,
+			rules:            []netlink.Rule{*rule},
+			eth0Routes:       gatewayEth0Routes[:1],
+			wgRoutes:         gatewayWgRoutes,
+			expectedErr:      "route to 1.2.3.4/32 via eth0 not found",
+		},
+		{
+			desc:       "default to azure network",
+			connMark:   true,
+			rules:      []netlink.Rule{*rule},
+			eth0Routes: []netlink.Route{{Dst: dnet, Gw: defaultGw, LinkIndex: 1}},
+			wgRoutes:   []netlink.Route{{Dst: net1, LinkIndex: 2}},
+		},
+		{
+			desc:        "default to azure network without exception route",
+			connMark:    true,
+			rules:       []netlink.Rule{*rule},
+			expectedErr: "route to 1.2.3.4/32 via wg0 not found",
+		},
+		{
+			desc:        "missing routing rule",
+			connMark:    true,
+			expectedErr: "routing rule for mark 8738 not found",
+		},
+		{
+			desc:        "missing connmark rules",
+			expectedErr: "ingress connmark rules not found",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			mnl := mocknetlinkwrapper.NewMockInterface(ctrl)
+			fipt := fakeiptables.NewFake()
+			if err := fipt.RestoreAll([]byte(mangleBuiltinChains), utiliptables.NoFlushTables, utiliptables.NoRestoreCounters); err != nil {
+				t.Fatalf("Failed to create mangle builtin chains: %v", err)
+			}
+			nf := netfilter.NewIPTables(fipt)
+			if test.connMark {
+				if err := nf.EnsureIngressConnMark(context.Background(), "eth0", 8738); err != nil {
+					t.Fatalf("Failed to ensure ingress connmark rules: %v", err)
+				}
+			}
+			routesRunner = runner{
+				netlink: mnl,
+				netfilter: func(backend netfilter.Backend) (netfilter.Interface, error) {
+					return nf, nil
+				},
+			}
+			mnl.EXPECT().LinkByName("eth0").Return(eth0, nil).AnyTimes()
+			mnl.EXPECT().LinkByName("wg0").Return(wg0, nil).AnyTimes()
+			mnl.EXPECT().RuleList(nl.FAMILY_V4).Return(test.rules, nil).AnyTimes()
+			mnl.EXPECT().RouteListFiltered(nl.FAMILY_V4, &netlink.Route{Table: 8738}, netlink.RT_FILTER_TABLE).Return(ingressRoutes, nil).AnyTimes()
+			mnl.EXPECT().RouteList(eth0, netlink.FAMILY_ALL).Return(test.eth0Routes, nil).AnyTimes()
+			mnl.EXPECT().RouteList(wg0, netlink.FAMILY_ALL).Return(test.wgRoutes, nil).AnyTimes()
+
+			nic := &testNicSettings{
+				exceptionCidrs: []string{"1.2.3.4/32"},
+				defaultRoute:   v1.DefaultRoute_DEFAULT_ROUTE_AZURE_NETWORKING,
+			}
+			if test.defaultToGateway {
+				nic.defaultRoute = v1.DefaultRoute_DEFAULT_ROUTE_STATIC_EGRESS_GATEWAY
+			}
+			err := CheckPodRoutes("wg0", nic, nil, netfilter.BackendIPTables)
+			if test.expectedErr == "" && err != nil {
+				t.Fatalf("CheckPodRoutes returns unexpected error: %v", err)
+			}
+			if test.expectedErr != "" && (err == nil || !strings.Contains(err.Error(), test.expectedErr)) {
+				t.Fatalf("CheckPodRoutes returns %v, expected error %q", err, test.expectedErr)
+			}
+		})
+	}
+}
+
+func TestUpdatePodRoutes(t *testing.T) {
+	eth0 := &netlink.Device{LinkAttrs: netlink.LinkAttrs{Name: "eth0", Index: 1}}
+	wg0 := &netlink.Device{LinkAttrs: netlink.LinkAttrs{Name: "wg0", Index: 2}}
+	defaultGw := net.IPv4(10, 244, 0, 1)
+	_, net1, _ := net.ParseCIDR("1.2.3.4/32")
+	_, dnet, _ := net.ParseCIDR("0.0.0.0/0")
+	ingressRoutes := []netlink.Route{{Family: nl.FAMILY_V4, Gw: defaultGw, Dst: dnet, LinkIndex: 1, Table: 8738}}
+	gatewayEth0Routes := []netlink.Route{
+		{Dst: &net.IPNet{IP: defaultGw, Mask: net.CIDRMask(32, 32)}, LinkIndex: 1, Scope: netlink.SCOPE_LINK},
+		{Dst: net1, Gw: defaultGw, LinkIndex: 1, Protocol: unix.RTPROT_STATIC},
+	}
+	gatewayWgRoutes := []netlink.Route{{Dst: dnet, LinkIndex: 2}}
+
+	tests := []struct {
+		desc             string
+		defaultToGateway bool
+		exceptionCidrs   []string
+		eth0Routes       []netlink.Route
+		wgRoutes         []netlink.Route
+		wgAddrs          []netlink.Addr
+		expectedDeleted  []string
+		expectedReplaced []string
+	}{
+		{
+			desc:             "exception cidrs changed",
+			defaultToGateway: true,
+			exceptionCidrs:   []string{"5.6.7.0/24"},
+			eth0Routes:       gatewayEth0Routes,
+			wgRoutes:         gatewayWgRoutes,
+			expectedDeleted:  []string{"1.2.3.4/32 dev 1"},
+			expectedReplaced: []string{"10.244.0.1/32 dev 1", "0.0.0.0/0 dev 2", "5.6.7.0/24 dev 1"},
+		},
+		{
+			desc:             "default route changed to azure network",
+			exceptionCidrs:   []string{"1.2.3.4/32"},
+			eth0Routes:       gatewayEth0Routes,
+			wgRoutes:         gatewayWgRoutes,
+			expectedReplaced: []string{"0.0.0.0/0 dev 1", "1.2.3.4/32 dev 2"},
+		},
+		{
+			desc:             "default route changed to gateway",
+			defaultToGateway: true,
+			eth0Routes:       []netlink.Route{{Dst: dnet, Gw: defaultGw, LinkIndex: 1}},
+			wgRoutes:         []netlink.Route{{Dst: net1, LinkIndex: 2}},
+			expectedDeleted:  []string{"1.2.3.4/32 dev 2"},
+			expectedReplaced: []string{"10.244.0.1/32 dev 1", "0.0.0.0/0 dev 2"},
+		},
+		{
+			desc:             "default route via tunnel address next hop",
+			defaultToGateway: true,
+			eth0Routes:       gatewayEth0Routes,
+			wgRoutes:         gatewayWgRoutes,
+			wgAddrs:          []netlink.Addr{{IPNet: &net.IPNet{IP: net.IPv4(100, 64, 1, 5), Mask: net.CIDRMask(24, 32)}}},
+			expectedDeleted:  []string{"1.2.3.4/32 dev 1"},
+			expectedReplaced: []string{"10.244.0.1/32 dev 1", "0.0.0.0/0 dev 2 via 100.64.1.1"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			mnl := mocknetlinkwrapper.NewMockInterface(ctrl)
+			routesRunner = runner{netlink: mnl}
+			var deleted, replaced []string
+			mnl.EXPECT().LinkByName("eth0").Return(eth0, nil)
+			mnl.EXPECT().LinkByName("wg0").Return(wg0, nil)
+			mnl.EXPECT().RouteListFiltered(nl.FAMILY_V4, &netlink.Route{Table: 8738}, netlink.RT_FILTER_TABLE).Return(ingressRoutes, nil)
+			mnl.EXPECT().AddrList(wg0, nl.FAMILY_V4).Return(test.wgAddrs, nil)
+			mnl.EXPECT().RouteList(eth0, nl.FAMILY_V4).Return(test.eth0Routes, nil)
+			mnl.EXPECT().RouteList(wg0, nl.FAMILY_V4).Return(test.wgRoutes, nil)
+			mnl.EXPECT().RouteDel(gomock.Any()).DoAndReturn(func(route *netlink.Route) error {
+				deleted = append(deleted, fmt.Sprintf("%s dev %d", route.Dst, route.LinkIndex))
+				return nil
+			}).AnyTimes()
+			mnl.EXPECT().RouteReplace(gomock.Any()).DoAndReturn(func(route *netlink.Route) error {
+				if route.LinkIndex == 2 && route.Gw != nil {
+					replaced = append(replaced, fmt.Sprintf("%s dev %d via %s", route.Dst, route.LinkIndex, route.Gw))
+				} else {
+					replaced = append(replaced, fmt.Sprintf("%s dev %d", route.Dst, route.LinkIndex))
+				}
+				return nil
+			}).AnyTimes()
+
+			nic := &testNicSettings{
+				exceptionCidrs: test.exceptionCidrs,
+				defaultRoute:   v1.DefaultRoute_DEFAULT_ROUTE_AZURE_NETWORKING,
+			}
+			if test.defaultToGateway {
+				nic.defaultRoute = v1.DefaultRoute_DEFAULT_ROUTE_STATIC_EGRESS_GATEWAY
+			}
+			if err := UpdatePodRoutes("wg0", nic, nil); err != nil {
+				t.Fatalf("UpdatePodRoutes returns unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(deleted, test.expectedDeleted) {
+				t.Fatalf("UpdatePodRoutes deleted %v, expected %v", deleted, test.expectedDeleted)
+			}
+			if !reflect.DeepEqual(replaced, test.expectedReplaced) {
+				t.Fatalf("UpdatePodRoutes replaced %v, expected %v", replaced, test.expectedReplaced)
+			}
+		})
+	}
+}
+
+func TestRestorePodRoutes(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mnl := mocknetlinkwrapper.NewMockInterface(ctrl)
+	routesRunner = runner{netlink: mnl}
+
+	eth0 := &netlink.Device{LinkAttrs: netlink.LinkAttrs{Name: "eth0", Index: 1}}
+	defaultGw := net.IPv4(10, 244, 0, 1)
+	_, net1, _ := net.ParseCIDR("1.2.3.4/32")
+	_, podNet, _ := net.ParseCIDR("10.244.0.0/24")
+	_, dnet, _ := net.ParseCIDR("0.0.0.0/0")
+	originalRoutes := []netlink.Route{
+		{Family: nl.FAMILY_V4, Dst: dnet, Gw: defaultGw, LinkIndex: 3},
+		{Family: nl.FAMILY_V4, Dst: podNet, LinkIndex: 3, Scope: netlink.SCOPE_LINK, Protocol: unix.RTPROT_KERNEL},
+	}
+	currentRoutes := []netlink.Route{
+		{Family: nl.FAMILY_V4, Dst: &net.IPNet{IP: defaultGw, Mask: net.CIDRMask(32, 32)}, LinkIndex: 1, Scope: netlink.SCOPE_LINK},
+		{Family: nl.FAMILY_V4, Dst: net1, Gw: defaultGw, LinkIndex: 1, Protocol: unix.RTPROT_STATIC},
+		{Family: nl.FAMILY_V4, Gw: defaultGw, LinkIndex: 1},
+		{Family: nl.FAMILY_V4, Dst: podNet, LinkIndex: 1, Scope: netlink.SCOPE_LINK, Protocol: unix.RTPROT_KERNEL},
+	}
+	ingressRoutes := []netlink.Route{{Family: nl.FAMILY_V4, Gw: defaultGw, Dst: dnet, LinkIndex: 1, Table: 8738}}
+	rule := netlink.NewRule()
+	rule.Mark = 8738
+	rule.Table = 8738
+
+	var replaced, deleted []string
+	mnl.EXPECT().LinkByName("eth0").Return(eth0, nil)
+	mnl.EXPECT().RouteReplace(gomock.Any()).DoAndReturn(func(route *netlink.Route) error {
+		replaced = append(replaced, fmt.Sprintf("%s dev %d", route.Dst, route.LinkIndex))
+		return nil
+	}).Times(2)
+	mnl.EXPECT().RouteList(eth0, nl.FAMILY_ALL).Return(currentRoutes, nil)
+	mnl.EXPECT().RouteDel(gomock.Any()).DoAndReturn(func(route *netlink.Route) error {
+		deleted = append(deleted, fmt.Sprintf("%s dev %d table %d", route.Dst, route.LinkIndex, route.Table))
+		return nil
+	}).AnyTimes()
+	mnl.EXPECT().RuleDel(rule).Return(unix.ENOENT)
+	mnl.EXPECT().RouteListFiltered(nl.FAMILY_V4, &netlink.Route{Table: 8738}, netlink.RT_FILTER_TABLE).Return(ingressRoutes, nil)
+
+	if err := RestorePodRoutes(originalRoutes); err != nil {
+		t.Fatalf("RestorePodRoutes returns unexpected error: %v", err)
+	}
+	expectedReplaced := []string{"0.0.0.0/0 dev 1", "10.244.0.0/24 dev 1"}
+	if !reflect.DeepEqual(replaced, expectedReplaced) {
+		t.Fatalf("RestorePodRoutes replaced %v, expected %v", replaced, expectedReplaced)
+	}
+	expectedDeleted := []string{"10.244.0.1/32 dev 1 table 0", "1.2.3.4/32 dev 1 table 0", "0.0.0.0/0 dev 1 table 8738"}
+	if !reflect.DeepEqual(deleted, expectedDeleted) {
+		t.Fatalf("RestorePodRoutes deleted %v, expected %v", deleted, expectedDeleted)
+	}
+}
@@ -0,0 +1,220 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+package sandbox
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/vishvananda/netlink"
+)
+
+// Route is a route of the pod eth0 recorded before the pod is attached to a gateway.
+type Route struct {
+	Family int `json:"family"`
+	// Destination CIDR, empty for the default route.
+	Dst string `json:"dst,omitempty"`
+	// Gateway IP, empty for link scope routes.
+	Gw string `json:"gw,omitempty"`
+	// Preferred source IP, empty if not set.
+	Src      string `json:"src,omitempty"`
+	Scope    uint8  `json:"scope,omitempty"`
+	Protocol int    `json:"protocol,omitempty"`
+	Priority int    `json:"priority,omitempty"`
+}
+
+// Record is a pod sandbox set up by the cni plugin on the node.
+type Record struct {
+	ContainerID  string `json:"containerID"`
+	NetnsPath    string `json:"netnsPath"`
+	PodName      string `json:"podName"`
+	PodNamespace string `json:"podNamespace"`
+	PodUID       string `json:"podUID,omitempty"`
+	// Name of the gateway the pod is attached to, empty if the pod is not attached.
+	GatewayName string `json:"gatewayName,omitempty"`
+	// Routes of the pod eth0 before the pod was attached, restored when the pod is detached.
+	Eth0Routes []Route `json:"eth0Routes,omitempty"`
+}
+
+// Store keeps sandbox records as json files in a directory on the node, one per sandbox container. The
+// cni plugin writes the records, and cni manager reads them to attach pods to gateways, or detach them,
+// after the pods are created.
+type Store struct {
+	dir string
+}
+
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+func (s *Store) path(containerID string) (string, error) {
+	if containerID == "" || strings.ContainsAny(containerID, `/\`) || containerID == "." || containerID == ".." {
+		return "", fmt.Errorf("invalid container ID %q", containerID)
+	}
+	return filepath.Join(s.dir, containerID+".json"), nil
+}
+
+// Save writes the record, replacing the existing one of the same container atomically.
+func (s *Store) Save(record *Record) error {
+	path, err := s.path(record.ContainerID)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal sandbox record: %w", err)
+	}
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return fmt.Errorf("failed to create sandbox directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(s.dir, ".tmp-")
+	if err != nil {
+		return fmt.Errorf("failed to create sandbox record: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write sandbox record: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write sandbox record: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write sandbox record: %w", err)
+	}
+	return nil
+}
+
+// Get returns the record of the container, the error wraps os.ErrNotExist if it's not found.
+func (s *Store) Get(containerID string) (*Record, error) {
+	path, err := s.path(containerID)
+	if err != nil {
+		return nil, err
+	}
+	return readRecord(path)
+}
+
+// List returns all records.
+func (s *Store) List() ([]*Record, error) {
+	files, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var records []*Record
+	for _, file := range files {
+		record, err := readRecord(file)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				// deleted in the meantime
+				continue
+			}
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// Delete deletes the record of the container, deleting a missing record is not an error.
+func (s *Store) Delete(containerID string) error {
+	path, err := s.path(containerID)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete sandbox record: %w", err)
+	}
+	return nil
+}
+
+// DeleteStale deletes the records of the containers not in validContainerIDs.
+func (s *Store) DeleteStale(validContainerIDs []string) error {
+	records, err := s.List()
+	if err != nil {
+		return err
+	}
+	valid := make(map[string]bool, len(validContainerIDs))
+	for _, containerID := range validContainerIDs {
+		valid[containerID] = true
+	}
+	var errs []error
+	for _, record := range records {
+		if !valid[record.ContainerID] {
+			if err := s.Delete(record.ContainerID); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func readRecord(path string) (*Record, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	record := &Record{}
+	if err := json.Unmarshal(data, record); err != nil {
+		return nil, fmt.Errorf("failed to parse sandbox record %s: %w", path, err)
+	}
+	return record, nil
+}
+
+// NewRoute converts a netlink route to its recorded form.
+func NewRoute(route netlink.Route) Route {
+	r := Route{
+		Family:   route.Family,
+		Scope:    uint8(route.Scope),
+		Protocol: int(route.Protocol),
+		Priority: route.Priority,
+	}
+	if route.Dst != nil && !route.Dst.IP.IsUnspecified() {
+		r.Dst = route.Dst.String()
+	}
+	if route.Gw != nil {
+		r.Gw = route.Gw.String()
+	}
+	if route.Src != nil {
+		r.Src = route.Src.String()
+	}
+	return r
+}
+
+// NetlinkRoute converts the recorded route to a netlink route via the link of linkIndex.
+func (r Route) NetlinkRoute(linkIndex int) (netlink.Route, error) {
+	route := netlink.Route{
+		Family:    r.Family,
+		LinkIndex: linkIndex,
+		Scope:     netlink.Scope(r.Scope),
+		Protocol:  netlink.RouteProtocol(r.Protocol),
+		Priority:  r.Priority,
+	}
+	dst := r.Dst
+	if dst == "" {
+		dst = "0.0.0.0/0"
+		if r.Family == netlink.FAMILY_V6 {
+			dst = "::/0"
+		}
+	}
+	_, cidr, err := net.ParseCIDR(dst)
+	if err != nil {
+		return route, fmt.Errorf("failed to parse route destination %s: %w", r.Dst, err)
+	}
+	route.Dst = cidr
+	if r.Gw != "" {
+		if route.Gw = net.ParseIP(r.Gw); route.Gw == nil {
+			return route, fmt.Errorf("failed to parse route gateway %s", r.Gw)
+		}
+	}
+	if r.Src != "" {
+		if route.Src = net.ParseIP(r.Src); route.Src == nil {
+			return route, fmt.Errorf("failed to parse route source %s", r.Src)
+		}
+	}
+	return route, nil
+}
@@ -0,0 +1,120 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+package sandbox
+
+import (
+	"errors"
+	"net"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+func TestStore(t *testing.T) {
+	store := NewStore(t.TempDir())
+	record := &Record{
+		ContainerID:  "0123456789abcdef",
+		NetnsPath:    "/var/run/netns/cni-1",
+		PodName:      "test",
+		PodNamespace: "default",
+		PodUID:       "pod-uid-1",
+	}
+	if err := store.Save(record); err != nil {
+		t.Fatalf("Save returns unexpected error: %v", err)
+	}
+	record.GatewayName = "tgw1"
+	record.Eth0Routes = []Route{{Family: netlink.FAMILY_V4, Gw: "10.244.0.1", Protocol: unix.RTPROT_DHCP}}
+	if err := store.Save(record); err != nil {
+		t.Fatalf("Save returns unexpected error: %v", err)
+	}
+	if err := store.Save(&Record{ContainerID: "other"}); err != nil {
+		t.Fatalf("Save returns unexpected error: %v", err)
+	}
+
+	got, err := store.Get(record.ContainerID)
+	if err != nil {
+		t.Fatalf("Get returns unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, record) {
+		t.Fatalf("Get returns %+v, expected %+v", got, record)
+	}
+	records, err := store.List()
+	if err != nil {
+		t.Fatalf("List returns unexpected error: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("List returns %d records, expected 2", len(records))
+	}
+
+	if err := store.DeleteStale([]string{record.ContainerID}); err != nil {
+		t.Fatalf("DeleteStale returns unexpected error: %v", err)
+	}
+	if _, err := store.Get("other"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("Get returns %v for stale record, expected not exist error", err)
+	}
+	if err := store.Delete(record.ContainerID); err != nil {
+		t.Fatalf("Delete returns unexpected error: %v", err)
+	}
+	if err := store.Delete(record.ContainerID); err != nil {
+		t.Fatalf("Delete returns unexpected error for missing record: %v", err)
+	}
+	records, err = store.List()
+	if err != nil || len(records) != 0 {
+		t.Fatalf("List returns %v, %v, expected no record", records, err)
+	}
+}
+
+func TestStoreInvalidContainerID(t *testing.T) {
+	store := NewStore(t.TempDir())
+	for _, containerID := range []string{"", "..", "../etc"} {
+		if err := store.Save(&Record{ContainerID: containerID}); err == nil {
+			t.Fatalf("Save does not return error for container ID %q", containerID)
+		}
+	}
+}
+
+func TestRouteConversion(t *testing.T) {
+	_, dst, _ := net.ParseCIDR("10.244.0.0/16")
+	_, defaultDst, _ := net.ParseCIDR("0.0.0.0/0")
+	_, defaultV6Dst, _ := net.ParseCIDR("::/0")
+	tests := []struct {
+		desc     string
+		route    netlink.Route
+		expected Route
+	}{
+		{
+			desc:     "default route",
+			route:    netlink.Route{Family: netlink.FAMILY_V4, Dst: defaultDst, Gw: net.ParseIP("10.244.0.1"), Protocol: unix.RTPROT_DHCP, Priority: 100},
+			expected: Route{Family: netlink.FAMILY_V4, Gw: "10.244.0.1", Protocol: unix.RTPROT_DHCP, Priority: 100},
+		},
+		{
+			desc:     "ipv6 default route",
+			route:    netlink.Route{Family: netlink.FAMILY_V6, Dst: defaultV6Dst, Gw: net.ParseIP("fe80::1234:5678:9abc")},
+			expected: Route{Family: netlink.FAMILY_V6, Gw: "fe80::1234:5678:9abc"},
+		},
+		{
+			desc:     "link scope route",
+			route:    netlink.Route{Family: netlink.FAMILY_V4, Dst: dst, Src: net.ParseIP("10.244.0.5"), Scope: netlink.SCOPE_LINK, Protocol: unix.RTPROT_KERNEL},
+			expected: Route{Family: netlink.FAMILY_V4, Dst: "10.244.0.0/16", Src: "10.244.0.5", Scope: uint8(netlink.SCOPE_LINK), Protocol: unix.RTPROT_KERNEL},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			got := NewRoute(test.route)
+			if !reflect.DeepEqual(got, test.expected) {
+				t.Fatalf("NewRoute returns %+v, expected %+v", got, test.expected)
+			}
+			route, err := got.NetlinkRoute(2)
+			if err != nil {
+				t.Fatalf("NetlinkRoute returns unexpected error: %v", err)
+			}
+			test.route.LinkIndex = 2
+			if !reflect.DeepEqual(route, test.route) {
+				t.Fatalf("NetlinkRoute returns %+v, expected %+v", route, test.route)
+			}
+		})
+	}
+}
@@ -5,22 +5,36 @@ package wireguard
 import (
 	"errors"
 	"fmt"
+	"net"
 	"os"
+	"slices"
+	"strings"
 
 	current "github.com/containernetworking/cni/pkg/types/100"
 	cniipam "github.com/containernetworking/plugins/pkg/ipam"
 	"github.com/containernetworking/plugins/pkg/ns"
 	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netlink/nl"
 	"go.uber.org/multierr"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
 
 	"github.com/Azure/kube-egress-gateway/pkg/cni/ipam"
+	"github.com/Azure/kube-egress-gateway/pkg/consts"
 	"github.com/Azure/kube-egress-gateway/pkg/netlinkwrapper"
 	"github.com/Azure/kube-egress-gateway/pkg/netnswrapper"
+	"github.com/Azure/kube-egress-gateway/pkg/wgctrlwrapper"
 )
 
 type runner struct {
 	netlink netlinkwrapper.Interface
 	netns   netnswrapper.Interface
+	wgctrl  wgctrlwrapper.Interface
+}
+
+type gatewaySettings interface {
+	GetEndpointIps() []string
+	GetListenPort() int32
+	GetPublicKey() string
 }
 
 var nicRunner runner
@@ -29,6 +43,7 @@ func init() {
 	nicRunner = runner{
 		netlink: netlinkwrapper.NewNetLink(),
 		netns:   netnswrapper.NewNetNS(),
+		wgctrl:  wgctrlwrapper.NewWgCtrl(),
 	}
 }
 
@@ -57,7 +72,7 @@ func WithWireGuardNic(containerID string, podNSPath string, ifName string, ipWra
 
 	// if not found create one and move to pod ns
 	if wgLink == nil {
-		wgNameInMain := "wg" + containerID[0:8] // avoid name conflict in main ns
+		wgNameInMain := hostLinkName(containerID) // avoid name conflict in main ns
 		linkAttributes := netlink.NewLinkAttrs()
 		linkAttributes.Name = wgNameInMain
 		wireguardInterface := &netlink.Wireguard{
@@ -161,15 +176,17 @@ func WithWireGuardNic(containerID string, podNSPath string, ifName string, ipWra
 			}
 			result.Interfaces = append(result.Interfaces, ipamResult.Interfaces[0])
 			for _, item := range ipamResult.IPs {
-				if item.Address.IP.To4() == nil {
-					// add ipv6 ip to result
+				if item.Address.IP.To4() == nil || item.Gateway != nil {
+					// ipv6 link local ip, or tunnel ip allocated from a range, is set on wireguard interface
 					item.Interface = current.Int(0)
 					result.IPs = append(result.IPs, &current.IPConfig{
 						Interface: current.Int(len(result.Interfaces) - 1),
 						Address:   item.Address,
+						Gateway:   item.Gateway,
 					})
-				} else {
-					// pod ipv4 ip should be added in wireguard configuration as allowed ip
+				}
+				if item.Address.IP.To4() != nil {
+					// pod ipv4 ip, or tunnel ip, should be added in wireguard configuration as allowed ip
 					allowedIPNet = fmt.Sprintf("%s/32", item.Address.IP.String())
 				}
 			}
@@ -193,3 +210,182 @@ func WithWireGuardNic(containerID string, podNSPath string, ifName string, ipWra
 		return nil
 	})
 }
+
+// SetLinkMTU sets the MTU of the wireguard link ifName, it must be called in the pod network namespace.
+// If mtu is 0, the MTU of the pod's eth0 minus the wireguard overhead is used.
+func SetLinkMTU(ifName string, mtu int) error {
+	if mtu == 0 {
+		eth0, err := nicRunner.netlink.LinkByName("eth0")
+		if err != nil {
+			return fmt.Errorf("failed to retrieve eth0 interface: %w", err)
+		}
+		mtu = eth0.Attrs().MTU - consts.WireguardMTUOverhead
+	}
+	wgLink, err := nicRunner.netlink.LinkByName(ifName)
+	if err != nil {
+		return fmt.Errorf("failed to find %q: %w", ifName, err)
+	}
+	if wgLink.Attrs().MTU == mtu {
+		return nil
+	}
+	if err := nicRunner.netlink.LinkSetMTU(wgLink, mtu); err != nil {
+		return fmt.Errorf("failed to set MTU of %q to %d: %w", ifName, mtu, err)
+	}
+	return nil
+}
+
+// DeleteStaleHostLinks deletes wireguard links left in host network namespace by an interrupted
+// WithWireGuardNic call, i.e. links named after a container that is not in validContainerIDs.
+// It must be called in host network namespace.
+func DeleteStaleHostLinks(validContainerIDs []string) error {
+	validLinks := make(map[string]bool, len(validContainerIDs))
+	for _, containerID := range validContainerIDs {
+		validLinks[hostLinkName(containerID)] = true
+	}
+	links, err := nicRunner.netlink.LinkList()
+	if err != nil {
+		return fmt.Errorf("failed to list links: %w", err)
+	}
+	var errs error
+	for _, link := range links {
+		name := link.Attrs().Name
+		if link.Type() != "wireguard" || !isHostLinkName(name) || validLinks[name] {
+			continue
+		}
+		if err := nicRunner.netlink.LinkDel(link); err != nil {
+			if _, ok := err.(netlink.LinkNotFoundError); !ok {
+				errs = multierr.Append(errs, fmt.Errorf("failed to delete stale wireguard link %s: %w", name, err))
+			}
+		}
+	}
+	return errs
+}
+
+// hostLinkName returns the name of the wireguard link created in host network namespace for the container.
+func hostLinkName(containerID string) string {
+	if len(containerID) > 8 {
+		containerID = containerID[0:8]
+	}
+	return "wg" + containerID
+}
+
+func isHostLinkName(name string) bool {
+	if len(name) != len("wg")+8 || !strings.HasPrefix(name, "wg") {
+		return false
+	}
+	for _, c := range name[len("wg"):] {
+		if !strings.ContainsRune("0123456789abcdef", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckWireGuardNic checks that the wireguard link ifName has the addresses recorded in prevResult and
+// a single peer matching the gateway. It must be called in the pod network namespace.
+func CheckWireGuardNic(ifName string, prevResult *current.Result, gateway gatewaySettings) error {
+	wgLink, err := nicRunner.netlink.LinkByName(ifName)
+	if err != nil {
+		return fmt.Errorf("failed to find %q: %w", ifName, err)
+	}
+
+	ifIndex := -1
+	for i, iface := range prevResult.Interfaces {
+		if iface.Name == ifName {
+			ifIndex = i
+		}
+	}
+	if ifIndex < 0 {
+		return fmt.Errorf("interface %q not found in prevResult", ifName)
+	}
+	addrs, err := nicRunner.netlink.AddrList(wgLink, nl.FAMILY_ALL)
+	if err != nil {
+		return fmt.Errorf("failed to list addresses of %q: %w", ifName, err)
+	}
+	for _, ipConfig := range prevResult.IPs {
+		if ipConfig.Interface == nil || *ipConfig.Interface != ifIndex {
+			continue
+		}
+		if !slices.ContainsFunc(addrs, func(addr netlink.Addr) bool { return addr.IP.Equal(ipConfig.Address.IP) }) {
+			return fmt.Errorf("address %s not found on %q", ipConfig.Address.IP, ifName)
+		}
+	}
+
+	wgClient, err := nicRunner.wgctrl.New()
+	if err != nil {
+		return fmt.Errorf("failed to create wg client: %w", err)
+	}
+	defer func() { _ = wgClient.Close() }()
+	device, err := wgClient.Device(ifName)
+	if err != nil {
+		return fmt.Errorf("failed to find wg device (%s): %w", ifName, err)
+	}
+	if len(device.Peers) != 1 {
+		return fmt.Errorf("wg device (%s) has %d peers, expected 1", ifName, len(device.Peers))
+	}
+	peer := device.Peers[0]
+	if peer.PublicKey.String() != gateway.GetPublicKey() {
+		return fmt.Errorf("wg device (%s) peer public key %s does not match gateway public key %s", ifName, peer.PublicKey, gateway.GetPublicKey())
+	}
+	if peer.Endpoint == nil || peer.Endpoint.Port != int(gateway.GetListenPort()) ||
+		!slices.ContainsFunc(gateway.GetEndpointIps(), func(ip string) bool { return peer.Endpoint.IP.Equal(net.ParseIP(ip)) }) {
+		return fmt.Errorf("wg device (%s) peer endpoint %v does not match gateway endpoints %v port %d", ifName, peer.Endpoint, gateway.GetEndpointIps(), gateway.GetListenPort())
+	}
+	return nil
+}
+
+type peerSettings interface {
+	GetEndpointIp() string
+	GetListenPort() int32
+	GetPublicKey() string
+}
+
+// UpdatePeer replaces the peer of the wireguard link ifName with the gateway if the gateway public key or
+// endpoint changed, the private key of the link is kept. It must be called in the pod network namespace.
+func UpdatePeer(ifName string, gateway peerSettings) error {
+	gwPublicKey, err := wgtypes.ParseKey(gateway.GetPublicKey())
+	if err != nil {
+		return fmt.Errorf("failed to parse gateway public key: %w", err)
+	}
+	endpoint := &net.UDPAddr{
+		IP:   net.ParseIP(gateway.GetEndpointIp()),
+		Port: int(gateway.GetListenPort()),
+	}
+
+	wgClient, err := nicRunner.wgctrl.New()
+	if err != nil {
+		return fmt.Errorf("failed to create wg client: %w", err)
+	}
+	defer func() { _ = wgClient.Close() }()
+	device, err := wgClient.Device(ifName)
+	if err != nil {
+		return fmt.Errorf("failed to find wg device (%s): %w", ifName, err)
+	}
+	if len(device.Peers) == 1 && device.Peers[0].PublicKey == gwPublicKey &&
+		device.Peers[0].Endpoint != nil && device.Peers[0].Endpoint.IP.Equal(endpoint.IP) && device.Peers[0].Endpoint.Port == endpoint.Port {
+		return nil
+	}
+	err = wgClient.ConfigureDevice(ifName, wgtypes.Config{
+		ReplacePeers: true,
+		Peers: []wgtypes.PeerConfig{
+			{
+				PublicKey: gwPublicKey,
+				Endpoint:  endpoint,
+				AllowedIPs: []net.IPNet{
+					{
+						IP:   net.IPv4zero,
+						Mask: net.CIDRMask(0, 8*len(net.IPv4zero)),
+					},
+					{
+						IP:   net.IPv6zero,
+						Mask: net.CIDRMask(0, 8*len(net.IPv6zero)),
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to configure wg device (%s): %w", ifName, err)
+	}
+	return nil
+}
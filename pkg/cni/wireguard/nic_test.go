@@ -12,11 +12,14 @@ import (
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netlink/nl"
 	"go.uber.org/mock/gomock"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
 
 	"github.com/Azure/kube-egress-gateway/pkg/cni/ipam"
 	"github.com/Azure/kube-egress-gateway/pkg/netlinkwrapper/mocknetlinkwrapper"
 	"github.com/Azure/kube-egress-gateway/pkg/netnswrapper/mocknetnswrapper"
+	"github.com/Azure/kube-egress-gateway/pkg/wgctrlwrapper/mockwgctrlwrapper"
 )
 
 const (
@@ -170,6 +173,36 @@ var _ = Describe("test WithWireGuardNic", func() {
 		Expect(err).To(HaveOccurred())
 	})
 
+	It("should set tunnel ip allocated from a range on wglink", func() {
+		ipamResult.IPs = []*current.IPConfig{
+			{Address: net.IPNet{IP: net.ParseIP("100.64.1.2"), Mask: net.CIDRMask(24, 32)}, Gateway: net.ParseIP("100.64.1.1")},
+		}
+		mns := nicRunner.netns.(*mocknetnswrapper.MockInterface)
+		mlink := nicRunner.netlink.(*mocknetlinkwrapper.MockInterface)
+		gwns := &mocknetnswrapper.MockNetNS{Name: nsName}
+		wg0 := &netlink.Wireguard{LinkAttrs: netlink.LinkAttrs{Name: ifName}}
+		gomock.InOrder(
+			mns.EXPECT().GetNSByPath(podNSPath).Return(gwns, nil),
+			mlink.EXPECT().LinkByName(ifName).Return(wg0, nil),
+			mlink.EXPECT().LinkByName(ifName).Return(wg0, nil),
+		)
+		result := &current.Result{}
+		var allowedIP string
+		err := WithWireGuardNic(containerID, podNSPath, ifName, ipam.NewFakeIPProvider(&ipamResult), []string{}, result, func(podNs ns.NetNS, allowedIPNet string) error {
+			allowedIP = allowedIPNet
+			return nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(allowedIP).To(Equal("100.64.1.2/32"))
+		Expect(result.IPs).To(Equal([]*current.IPConfig{
+			{
+				Interface: current.Int(0),
+				Address:   net.IPNet{IP: net.ParseIP("100.64.1.2"), Mask: net.CIDRMask(24, 32)},
+				Gateway:   net.ParseIP("100.64.1.1"),
+			},
+		}))
+	})
+
 	It("should return error if pod ipv4 ip is not found", func() {
 		ipamResult.IPs = ipamResult.IPs[1:]
 		mns := nicRunner.netns.(*mocknetnswrapper.MockInterface)
@@ -186,3 +219,215 @@ var _ = Describe("test WithWireGuardNic", func() {
 		Expect(err).To(HaveOccurred())
 	})
 })
+
+var _ = Describe("test SetLinkMTU", func() {
+	var mlink *mocknetlinkwrapper.MockInterface
+	BeforeEach(func() {
+		mctrl := gomock.NewController(GinkgoT())
+		mlink = mocknetlinkwrapper.NewMockInterface(mctrl)
+		nicRunner = runner{netlink: mlink}
+	})
+
+	It("should set configured MTU", func() {
+		wg0 := &netlink.Wireguard{LinkAttrs: netlink.LinkAttrs{Name: ifName, MTU: 1420}}
+		gomock.InOrder(
+			mlink.EXPECT().LinkByName(ifName).Return(wg0, nil),
+			mlink.EXPECT().LinkSetMTU(wg0, 1400).Return(nil),
+		)
+		Expect(SetLinkMTU(ifName, 1400)).To(Succeed())
+	})
+
+	It("should default MTU from eth0", func() {
+		eth0 := &netlink.Veth{LinkAttrs: netlink.LinkAttrs{Name: "eth0", MTU: 1500}}
+		wg0 := &netlink.Wireguard{LinkAttrs: netlink.LinkAttrs{Name: ifName, MTU: 1420}}
+		gomock.InOrder(
+			mlink.EXPECT().LinkByName("eth0").Return(eth0, nil),
+			mlink.EXPECT().LinkByName(ifName).Return(wg0, nil),
+		)
+		// already 1500 - 80, nothing to change
+		Expect(SetLinkMTU(ifName, 0)).To(Succeed())
+	})
+
+	It("should return error if MTU cannot be set", func() {
+		wg0 := &netlink.Wireguard{LinkAttrs: netlink.LinkAttrs{Name: ifName}}
+		gomock.InOrder(
+			mlink.EXPECT().LinkByName(ifName).Return(wg0, nil),
+			mlink.EXPECT().LinkSetMTU(wg0, 1400).Return(errors.New("error")),
+		)
+		Expect(SetLinkMTU(ifName, 1400)).To(MatchError(ContainSubstring("failed to set MTU")))
+	})
+})
+
+type testGatewaySettings struct {
+	endpointIPs []string
+	listenPort  int32
+	publicKey   string
+}
+
+func (t *testGatewaySettings) GetEndpointIps() []string { return t.endpointIPs }
+func (t *testGatewaySettings) GetListenPort() int32     { return t.listenPort }
+func (t *testGatewaySettings) GetPublicKey() string     { return t.publicKey }
+
+var _ = Describe("test CheckWireGuardNic", func() {
+	var (
+		mlink      *mocknetlinkwrapper.MockInterface
+		mwg        *mockwgctrlwrapper.MockInterface
+		mclient    *mockwgctrlwrapper.MockClient
+		wg0        *netlink.Wireguard
+		prevResult *current.Result
+		gateway    *testGatewaySettings
+		device     *wgtypes.Device
+	)
+	BeforeEach(func() {
+		mctrl := gomock.NewController(GinkgoT())
+		mlink = mocknetlinkwrapper.NewMockInterface(mctrl)
+		mwg = mockwgctrlwrapper.NewMockInterface(mctrl)
+		mclient = mockwgctrlwrapper.NewMockClient(mctrl)
+		nicRunner = runner{netlink: mlink, wgctrl: mwg}
+		wg0 = &netlink.Wireguard{LinkAttrs: netlink.LinkAttrs{Name: ifName}}
+		prevResult = &current.Result{
+			Interfaces: []*current.Interface{{Name: "eth0"}, {Name: ifName}},
+			IPs: []*current.IPConfig{
+				{Interface: current.Int(0), Address: net.IPNet{IP: net.ParseIP("10.0.0.4"), Mask: net.CIDRMask(24, 32)}},
+				{Interface: current.Int(1), Address: net.IPNet{IP: net.ParseIP("fe80::1234"), Mask: net.CIDRMask(128, 128)}},
+			},
+		}
+		key, err := wgtypes.GeneratePrivateKey()
+		Expect(err).NotTo(HaveOccurred())
+		gateway = &testGatewaySettings{endpointIPs: []string{"10.0.1.1", "10.0.1.2"}, listenPort: 6000, publicKey: key.PublicKey().String()}
+		device = &wgtypes.Device{Peers: []wgtypes.Peer{{
+			PublicKey: key.PublicKey(),
+			Endpoint:  &net.UDPAddr{IP: net.ParseIP("10.0.1.2"), Port: 6000},
+		}}}
+	})
+
+	expectDevice := func() {
+		gomock.InOrder(
+			mlink.EXPECT().LinkByName(ifName).Return(wg0, nil),
+			mlink.EXPECT().AddrList(wg0, nl.FAMILY_ALL).Return([]netlink.Addr{{IPNet: &net.IPNet{IP: net.ParseIP("fe80::1234"), Mask: net.CIDRMask(128, 128)}}}, nil),
+			mwg.EXPECT().New().Return(mclient, nil),
+			mclient.EXPECT().Device(ifName).Return(device, nil),
+			mclient.EXPECT().Close().Return(nil),
+		)
+	}
+
+	It("should succeed when nic matches gateway", func() {
+		expectDevice()
+		Expect(CheckWireGuardNic(ifName, prevResult, gateway)).To(Succeed())
+	})
+
+	It("should return error when address is missing", func() {
+		gomock.InOrder(
+			mlink.EXPECT().LinkByName(ifName).Return(wg0, nil),
+			mlink.EXPECT().AddrList(wg0, nl.FAMILY_ALL).Return(nil, nil),
+		)
+		Expect(CheckWireGuardNic(ifName, prevResult, gateway)).To(MatchError(ContainSubstring("address fe80::1234 not found")))
+	})
+
+	It("should return error when peer public key does not match", func() {
+		key, err := wgtypes.GeneratePrivateKey()
+		Expect(err).NotTo(HaveOccurred())
+		gateway.publicKey = key.PublicKey().String()
+		expectDevice()
+		Expect(CheckWireGuardNic(ifName, prevResult, gateway)).To(MatchError(ContainSubstring("does not match gateway public key")))
+	})
+
+	It("should return error when peer endpoint does not match", func() {
+		device.Peers[0].Endpoint.IP = net.ParseIP("10.0.1.3")
+		expectDevice()
+		Expect(CheckWireGuardNic(ifName, prevResult, gateway)).To(MatchError(ContainSubstring("does not match gateway endpoints")))
+	})
+
+	It("should return error when link is missing", func() {
+		mlink.EXPECT().LinkByName(ifName).Return(nil, netlink.LinkNotFoundError{})
+		Expect(CheckWireGuardNic(ifName, prevResult, gateway)).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("test DeleteStaleHostLinks", func() {
+	var mlink *mocknetlinkwrapper.MockInterface
+	BeforeEach(func() {
+		mctrl := gomock.NewController(GinkgoT())
+		mlink = mocknetlinkwrapper.NewMockInterface(mctrl)
+		nicRunner = runner{netlink: mlink}
+	})
+
+	It("should only delete wireguard links of invalid containers", func() {
+		valid := &netlink.Wireguard{LinkAttrs: netlink.LinkAttrs{Name: "wg01234567"}}
+		stale := &netlink.Wireguard{LinkAttrs: netlink.LinkAttrs{Name: "wg89abcdef"}}
+		other := &netlink.Wireguard{LinkAttrs: netlink.LinkAttrs{Name: "wg-gateway"}}
+		dummy := &netlink.Dummy{LinkAttrs: netlink.LinkAttrs{Name: "wg76543210"}}
+		mlink.EXPECT().LinkList().Return([]netlink.Link{valid, stale, other, dummy}, nil)
+		mlink.EXPECT().LinkDel(stale).Return(nil)
+		Expect(DeleteStaleHostLinks([]string{"0123456789abcdef"})).To(Succeed())
+	})
+
+	It("should return error when link deletion fails", func() {
+		stale := &netlink.Wireguard{LinkAttrs: netlink.LinkAttrs{Name: "wg89abcdef"}}
+		mlink.EXPECT().LinkList().Return([]netlink.Link{stale}, nil)
+		mlink.EXPECT().LinkDel(stale).Return(errors.New("failed"))
+		Expect(DeleteStaleHostLinks(nil)).To(MatchError(ContainSubstring("failed to delete stale wireguard link wg89abcdef")))
+	})
+})
+
+type testPeerSettings struct {
+	endpointIP string
+	listenPort int32
+	publicKey  string
+}
+
+func (t *testPeerSettings) GetEndpointIp() string { return t.endpointIP }
+func (t *testPeerSettings) GetListenPort() int32  { return t.listenPort }
+func (t *testPeerSettings) GetPublicKey() string  { return t.publicKey }
+
+var _ = Describe("test UpdatePeer", func() {
+	var (
+		mwg     *mockwgctrlwrapper.MockInterface
+		mclient *mockwgctrlwrapper.MockClient
+		key     wgtypes.Key
+		gateway *testPeerSettings
+		device  *wgtypes.Device
+	)
+	BeforeEach(func() {
+		mctrl := gomock.NewController(GinkgoT())
+		mwg = mockwgctrlwrapper.NewMockInterface(mctrl)
+		mclient = mockwgctrlwrapper.NewMockClient(mctrl)
+		nicRunner = runner{wgctrl: mwg}
+		privateKey, err := wgtypes.GeneratePrivateKey()
+		Expect(err).NotTo(HaveOccurred())
+		key = privateKey.PublicKey()
+		gateway = &testPeerSettings{endpointIP: "10.0.1.1", listenPort: 6000, publicKey: key.String()}
+		device = &wgtypes.Device{Peers: []wgtypes.Peer{{
+			PublicKey: key,
+			Endpoint:  &net.UDPAddr{IP: net.ParseIP("10.0.1.1"), Port: 6000},
+		}}}
+		mwg.EXPECT().New().Return(mclient, nil)
+		mclient.EXPECT().Device(ifName).Return(device, nil)
+		mclient.EXPECT().Close().Return(nil)
+	})
+
+	It("should not reconfigure device when peer matches gateway", func() {
+		Expect(UpdatePeer(ifName, gateway)).To(Succeed())
+	})
+
+	It("should replace peer when gateway endpoint changed", func() {
+		gateway.endpointIP = "10.0.1.2"
+		mclient.EXPECT().ConfigureDevice(ifName, gomock.Any()).DoAndReturn(func(_ string, cfg wgtypes.Config) error {
+			Expect(cfg.PrivateKey).To(BeNil())
+			Expect(cfg.ReplacePeers).To(BeTrue())
+			Expect(cfg.Peers).To(HaveLen(1))
+			Expect(cfg.Peers[0].PublicKey).To(Equal(key))
+			Expect(cfg.Peers[0].Endpoint.String()).To(Equal("10.0.1.2:6000"))
+			return nil
+		})
+		Expect(UpdatePeer(ifName, gateway)).To(Succeed())
+	})
+
+	It("should replace peer when gateway public key changed", func() {
+		privateKey, err := wgtypes.GeneratePrivateKey()
+		Expect(err).NotTo(HaveOccurred())
+		gateway.publicKey = privateKey.PublicKey().String()
+		mclient.EXPECT().ConfigureDevice(ifName, gomock.Any()).Return(errors.New("failed"))
+		Expect(UpdatePeer(ifName, gateway)).To(MatchError(ContainSubstring("failed to configure wg device")))
+	})
+})
@@ -75,9 +75,11 @@ func (DefaultRoute) EnumDescriptor() ([]byte, []int) {
 }
 
 type PodInfo struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	PodName       string                 `protobuf:"bytes,1,opt,name=pod_name,json=podName,proto3" json:"pod_name,omitempty"`
-	PodNamespace  string                 `protobuf:"bytes,2,opt,name=pod_namespace,json=podNamespace,proto3" json:"pod_namespace,omitempty"`
+	state        protoimpl.MessageState `protogen:"open.v1"`
+	PodName      string                 `protobuf:"bytes,1,opt,name=pod_name,json=podName,proto3" json:"pod_name,omitempty"`
+	PodNamespace string                 `protobuf:"bytes,2,opt,name=pod_namespace,json=podNamespace,proto3" json:"pod_namespace,omitempty"`
+	// UID of the pod, empty if not provided by the container runtime
+	PodUid        string `protobuf:"bytes,3,opt,name=pod_uid,json=podUid,proto3" json:"pod_uid,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -126,14 +128,25 @@ func (x *PodInfo) GetPodNamespace() string {
 	return ""
 }
 
+func (x *PodInfo) GetPodUid() string {
+	if x != nil {
+		return x.PodUid
+	}
+	return ""
+}
+
 // CNIAddRequest is the request for cni add function.
 type NicAddRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	PodConfig     *PodInfo               `protobuf:"bytes,1,opt,name=pod_config,json=podConfig,proto3" json:"pod_config,omitempty"`
-	ListenPort    int32                  `protobuf:"varint,2,opt,name=listen_port,json=listenPort,proto3" json:"listen_port,omitempty"`
-	AllowedIp     string                 `protobuf:"bytes,3,opt,name=allowed_ip,json=allowedIp,proto3" json:"allowed_ip,omitempty"`
-	PublicKey     string                 `protobuf:"bytes,4,opt,name=public_key,json=publicKey,proto3" json:"public_key,omitempty"`
-	GatewayName   string                 `protobuf:"bytes,5,opt,name=gateway_name,json=gatewayName,proto3" json:"gateway_name,omitempty"`
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	PodConfig   *PodInfo               `protobuf:"bytes,1,opt,name=pod_config,json=podConfig,proto3" json:"pod_config,omitempty"`
+	ListenPort  int32                  `protobuf:"varint,2,opt,name=listen_port,json=listenPort,proto3" json:"listen_port,omitempty"`
+	AllowedIp   string                 `protobuf:"bytes,3,opt,name=allowed_ip,json=allowedIp,proto3" json:"allowed_ip,omitempty"`
+	PublicKey   string                 `protobuf:"bytes,4,opt,name=public_key,json=publicKey,proto3" json:"public_key,omitempty"`
+	GatewayName string                 `protobuf:"bytes,5,opt,name=gateway_name,json=gatewayName,proto3" json:"gateway_name,omitempty"`
+	// ID of the pod sandbox container, used to garbage collect pod endpoints
+	ContainerId string `protobuf:"bytes,6,opt,name=container_id,json=containerId,proto3" json:"container_id,omitempty"`
+	// path of the pod network namespace, used to update the pod nic when the gateway changes
+	NetnsPath     string `protobuf:"bytes,7,opt,name=netns_path,json=netnsPath,proto3" json:"netns_path,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -203,6 +216,20 @@ func (x *NicAddRequest) GetGatewayName() string {
 	return ""
 }
 
+func (x *NicAddRequest) GetContainerId() string {
+	if x != nil {
+		return x.ContainerId
+	}
+	return ""
+}
+
+func (x *NicAddRequest) GetNetnsPath() string {
+	if x != nil {
+		return x.NetnsPath
+	}
+	return ""
+}
+
 // CNIAddResponse is the response for cni add function.
 type NicAddResponse struct {
 	state          protoimpl.MessageState `protogen:"open.v1"`
@@ -211,8 +238,10 @@ type NicAddResponse struct {
 	PublicKey      string                 `protobuf:"bytes,3,opt,name=public_key,json=publicKey,proto3" json:"public_key,omitempty"`
 	ExceptionCidrs []string               `protobuf:"bytes,4,rep,name=exception_cidrs,json=exceptionCidrs,proto3" json:"exception_cidrs,omitempty"`
 	DefaultRoute   DefaultRoute           `protobuf:"varint,5,opt,name=default_route,json=defaultRoute,proto3,enum=pkg.cniprotocol.v1.DefaultRoute" json:"default_route,omitempty"`
-	unknownFields  protoimpl.UnknownFields
-	sizeCache      protoimpl.SizeCache
+	// MTU of the pod wireguard link, 0 means the MTU of the pod's eth0 minus the wireguard overhead.
+	Mtu           int32 `protobuf:"varint,6,opt,name=mtu,proto3" json:"mtu,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
 }
 
 func (x *NicAddResponse) Reset() {
@@ -280,10 +309,19 @@ func (x *NicAddResponse) GetDefaultRoute() DefaultRoute {
 	return DefaultRoute_DEFAULT_ROUTE_UNSPECIFIED
 }
 
+func (x *NicAddResponse) GetMtu() int32 {
+	if x != nil {
+		return x.Mtu
+	}
+	return 0
+}
+
 // CNIDeleteRequest is the request for cni del function.
 type NicDelRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	PodConfig     *PodInfo               `protobuf:"bytes,1,opt,name=pod_config,json=podConfig,proto3" json:"pod_config,omitempty"`
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	PodConfig *PodInfo               `protobuf:"bytes,1,opt,name=pod_config,json=podConfig,proto3" json:"pod_config,omitempty"`
+	// ID of the pod sandbox container, used to skip deleting pod endpoint of a newer sandbox
+	ContainerId   string `protobuf:"bytes,2,opt,name=container_id,json=containerId,proto3" json:"container_id,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -325,6 +363,13 @@ func (x *NicDelRequest) GetPodConfig() *PodInfo {
 	return nil
 }
 
+func (x *NicDelRequest) GetContainerId() string {
+	if x != nil {
+		return x.ContainerId
+	}
+	return ""
+}
+
 // CNIDeleteResponse is the response for cni del function.
 type NicDelResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
@@ -362,6 +407,368 @@ func (*NicDelResponse) Descriptor() ([]byte, []int) {
 	return file_pkg_cniprotocol_v1_cni_proto_rawDescGZIP(), []int{4}
 }
 
+// NicCheckRequest is the request for cni check function.
+type NicCheckRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	PodConfig     *PodInfo               `protobuf:"bytes,1,opt,name=pod_config,json=podConfig,proto3" json:"pod_config,omitempty"`
+	GatewayName   string                 `protobuf:"bytes,2,opt,name=gateway_name,json=gatewayName,proto3" json:"gateway_name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *NicCheckRequest) Reset() {
+	*x = NicCheckRequest{}
+	mi := &file_pkg_cniprotocol_v1_cni_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *NicCheckRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NicCheckRequest) ProtoMessage() {}
+
+func (x *NicCheckRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_cniprotocol_v1_cni_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NicCheckRequest.ProtoReflect.Descriptor instead.
+func (*NicCheckRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_cniprotocol_v1_cni_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *NicCheckRequest) GetPodConfig() *PodInfo {
+	if x != nil {
+		return x.PodConfig
+	}
+	return nil
+}
+
+func (x *NicCheckRequest) GetGatewayName() string {
+	if x != nil {
+		return x.GatewayName
+	}
+	return ""
+}
+
+// NicCheckResponse is the response for cni check function.
+type NicCheckResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// gateway frontend IPs the pod may be connected to
+	EndpointIps    []string     `protobuf:"bytes,1,rep,name=endpoint_ips,json=endpointIps,proto3" json:"endpoint_ips,omitempty"`
+	ListenPort     int32        `protobuf:"varint,2,opt,name=listen_port,json=listenPort,proto3" json:"listen_port,omitempty"`
+	PublicKey      string       `protobuf:"bytes,3,opt,name=public_key,json=publicKey,proto3" json:"public_key,omitempty"`
+	ExceptionCidrs []string     `protobuf:"bytes,4,rep,name=exception_cidrs,json=exceptionCidrs,proto3" json:"exception_cidrs,omitempty"`
+	DefaultRoute   DefaultRoute `protobuf:"varint,5,opt,name=default_route,json=defaultRoute,proto3,enum=pkg.cniprotocol.v1.DefaultRoute" json:"default_route,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *NicCheckResponse) Reset() {
+	*x = NicCheckResponse{}
+	mi := &file_pkg_cniprotocol_v1_cni_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *NicCheckResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NicCheckResponse) ProtoMessage() {}
+
+func (x *NicCheckResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_cniprotocol_v1_cni_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NicCheckResponse.ProtoReflect.Descriptor instead.
+func (*NicCheckResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_cniprotocol_v1_cni_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *NicCheckResponse) GetEndpointIps() []string {
+	if x != nil {
+		return x.EndpointIps
+	}
+	return nil
+}
+
+func (x *NicCheckResponse) GetListenPort() int32 {
+	if x != nil {
+		return x.ListenPort
+	}
+	return 0
+}
+
+func (x *NicCheckResponse) GetPublicKey() string {
+	if x != nil {
+		return x.PublicKey
+	}
+	return ""
+}
+
+func (x *NicCheckResponse) GetExceptionCidrs() []string {
+	if x != nil {
+		return x.ExceptionCidrs
+	}
+	return nil
+}
+
+func (x *NicCheckResponse) GetDefaultRoute() DefaultRoute {
+	if x != nil {
+		return x.DefaultRoute
+	}
+	return DefaultRoute_DEFAULT_ROUTE_UNSPECIFIED
+}
+
+// NicGCRequest is the request for cni gc function.
+type NicGCRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// IDs of the pod sandbox containers still attached on the node
+	ValidContainerIds []string `protobuf:"bytes,1,rep,name=valid_container_ids,json=validContainerIds,proto3" json:"valid_container_ids,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *NicGCRequest) Reset() {
+	*x = NicGCRequest{}
+	mi := &file_pkg_cniprotocol_v1_cni_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *NicGCRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NicGCRequest) ProtoMessage() {}
+
+func (x *NicGCRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_cniprotocol_v1_cni_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NicGCRequest.ProtoReflect.Descriptor instead.
+func (*NicGCRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_cniprotocol_v1_cni_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *NicGCRequest) GetValidContainerIds() []string {
+	if x != nil {
+		return x.ValidContainerIds
+	}
+	return nil
+}
+
+// NicGCResponse is the response for cni gc function.
+type NicGCResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// pods whose pod endpoint resources were deleted
+	DeletedPods   []*PodInfo `protobuf:"bytes,1,rep,name=deleted_pods,json=deletedPods,proto3" json:"deleted_pods,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *NicGCResponse) Reset() {
+	*x = NicGCResponse{}
+	mi := &file_pkg_cniprotocol_v1_cni_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *NicGCResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NicGCResponse) ProtoMessage() {}
+
+func (x *NicGCResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_cniprotocol_v1_cni_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NicGCResponse.ProtoReflect.Descriptor instead.
+func (*NicGCResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_cniprotocol_v1_cni_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *NicGCResponse) GetDeletedPods() []*PodInfo {
+	if x != nil {
+		return x.DeletedPods
+	}
+	return nil
+}
+
+// WatchGatewayRequest is the request for watching gateway configuration of a pod.
+type WatchGatewayRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	PodConfig     *PodInfo               `protobuf:"bytes,1,opt,name=pod_config,json=podConfig,proto3" json:"pod_config,omitempty"`
+	GatewayName   string                 `protobuf:"bytes,2,opt,name=gateway_name,json=gatewayName,proto3" json:"gateway_name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchGatewayRequest) Reset() {
+	*x = WatchGatewayRequest{}
+	mi := &file_pkg_cniprotocol_v1_cni_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchGatewayRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchGatewayRequest) ProtoMessage() {}
+
+func (x *WatchGatewayRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_cniprotocol_v1_cni_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchGatewayRequest.ProtoReflect.Descriptor instead.
+func (*WatchGatewayRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_cniprotocol_v1_cni_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *WatchGatewayRequest) GetPodConfig() *PodInfo {
+	if x != nil {
+		return x.PodConfig
+	}
+	return nil
+}
+
+func (x *WatchGatewayRequest) GetGatewayName() string {
+	if x != nil {
+		return x.GatewayName
+	}
+	return ""
+}
+
+// WatchGatewayResponse is the gateway configuration of a pod nic, sent on every change.
+type WatchGatewayResponse struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	EndpointIp     string                 `protobuf:"bytes,1,opt,name=endpoint_ip,json=endpointIp,proto3" json:"endpoint_ip,omitempty"`
+	ListenPort     int32                  `protobuf:"varint,2,opt,name=listen_port,json=listenPort,proto3" json:"listen_port,omitempty"`
+	PublicKey      string                 `protobuf:"bytes,3,opt,name=public_key,json=publicKey,proto3" json:"public_key,omitempty"`
+	ExceptionCidrs []string               `protobuf:"bytes,4,rep,name=exception_cidrs,json=exceptionCidrs,proto3" json:"exception_cidrs,omitempty"`
+	DefaultRoute   DefaultRoute           `protobuf:"varint,5,opt,name=default_route,json=defaultRoute,proto3,enum=pkg.cniprotocol.v1.DefaultRoute" json:"default_route,omitempty"`
+	// MTU of the pod wireguard link, 0 means the MTU of the pod's eth0 minus the wireguard overhead.
+	Mtu           int32 `protobuf:"varint,6,opt,name=mtu,proto3" json:"mtu,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchGatewayResponse) Reset() {
+	*x = WatchGatewayResponse{}
+	mi := &file_pkg_cniprotocol_v1_cni_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchGatewayResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchGatewayResponse) ProtoMessage() {}
+
+func (x *WatchGatewayResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_cniprotocol_v1_cni_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchGatewayResponse.ProtoReflect.Descriptor instead.
+func (*WatchGatewayResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_cniprotocol_v1_cni_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *WatchGatewayResponse) GetEndpointIp() string {
+	if x != nil {
+		return x.EndpointIp
+	}
+	return ""
+}
+
+func (x *WatchGatewayResponse) GetListenPort() int32 {
+	if x != nil {
+		return x.ListenPort
+	}
+	return 0
+}
+
+func (x *WatchGatewayResponse) GetPublicKey() string {
+	if x != nil {
+		return x.PublicKey
+	}
+	return ""
+}
+
+func (x *WatchGatewayResponse) GetExceptionCidrs() []string {
+	if x != nil {
+		return x.ExceptionCidrs
+	}
+	return nil
+}
+
+func (x *WatchGatewayResponse) GetDefaultRoute() DefaultRoute {
+	if x != nil {
+		return x.DefaultRoute
+	}
+	return DefaultRoute_DEFAULT_ROUTE_UNSPECIFIED
+}
+
+func (x *WatchGatewayResponse) GetMtu() int32 {
+	if x != nil {
+		return x.Mtu
+	}
+	return 0
+}
+
 // PodRetrieveRequest is the request for retrieving pod function.
 type PodRetrieveRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
@@ -372,7 +779,7 @@ type PodRetrieveRequest struct {
 
 func (x *PodRetrieveRequest) Reset() {
 	*x = PodRetrieveRequest{}
-	mi := &file_pkg_cniprotocol_v1_cni_proto_msgTypes[5]
+	mi := &file_pkg_cniprotocol_v1_cni_proto_msgTypes[11]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -384,7 +791,7 @@ func (x *PodRetrieveRequest) String() string {
 func (*PodRetrieveRequest) ProtoMessage() {}
 
 func (x *PodRetrieveRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_cniprotocol_v1_cni_proto_msgTypes[5]
+	mi := &file_pkg_cniprotocol_v1_cni_proto_msgTypes[11]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -397,7 +804,7 @@ func (x *PodRetrieveRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use PodRetrieveRequest.ProtoReflect.Descriptor instead.
 func (*PodRetrieveRequest) Descriptor() ([]byte, []int) {
-	return file_pkg_cniprotocol_v1_cni_proto_rawDescGZIP(), []int{5}
+	return file_pkg_cniprotocol_v1_cni_proto_rawDescGZIP(), []int{11}
 }
 
 func (x *PodRetrieveRequest) GetPodConfig() *PodInfo {
@@ -417,7 +824,7 @@ type PodRetrieveResponse struct {
 
 func (x *PodRetrieveResponse) Reset() {
 	*x = PodRetrieveResponse{}
-	mi := &file_pkg_cniprotocol_v1_cni_proto_msgTypes[6]
+	mi := &file_pkg_cniprotocol_v1_cni_proto_msgTypes[12]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -429,7 +836,7 @@ func (x *PodRetrieveResponse) String() string {
 func (*PodRetrieveResponse) ProtoMessage() {}
 
 func (x *PodRetrieveResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_cniprotocol_v1_cni_proto_msgTypes[6]
+	mi := &file_pkg_cniprotocol_v1_cni_proto_msgTypes[12]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -442,7 +849,7 @@ func (x *PodRetrieveResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use PodRetrieveResponse.ProtoReflect.Descriptor instead.
 func (*PodRetrieveResponse) Descriptor() ([]byte, []int) {
-	return file_pkg_cniprotocol_v1_cni_proto_rawDescGZIP(), []int{6}
+	return file_pkg_cniprotocol_v1_cni_proto_rawDescGZIP(), []int{12}
 }
 
 func (x *PodRetrieveResponse) GetAnnotations() map[string]string {
@@ -456,10 +863,11 @@ var File_pkg_cniprotocol_v1_cni_proto protoreflect.FileDescriptor
 
 const file_pkg_cniprotocol_v1_cni_proto_rawDesc = "" +
 	"\n" +
-	"\x1cpkg/cniprotocol/v1/cni.proto\x12\x12pkg.cniprotocol.v1\"I\n" +
+	"\x1cpkg/cniprotocol/v1/cni.proto\x12\x12pkg.cniprotocol.v1\"b\n" +
 	"\aPodInfo\x12\x19\n" +
 	"\bpod_name\x18\x01 \x01(\tR\apodName\x12#\n" +
-	"\rpod_namespace\x18\x02 \x01(\tR\fpodNamespace\"\xcd\x01\n" +
+	"\rpod_namespace\x18\x02 \x01(\tR\fpodNamespace\x12\x17\n" +
+	"\apod_uid\x18\x03 \x01(\tR\x06podUid\"\x8f\x02\n" +
 	"\rNicAddRequest\x12:\n" +
 	"\n" +
 	"pod_config\x18\x01 \x01(\v2\x1b.pkg.cniprotocol.v1.PodInfoR\tpodConfig\x12\x1f\n" +
@@ -469,7 +877,10 @@ const file_pkg_cniprotocol_v1_cni_proto_rawDesc = "" +
 	"allowed_ip\x18\x03 \x01(\tR\tallowedIp\x12\x1d\n" +
 	"\n" +
 	"public_key\x18\x04 \x01(\tR\tpublicKey\x12!\n" +
-	"\fgateway_name\x18\x05 \x01(\tR\vgatewayName\"\xe1\x01\n" +
+	"\fgateway_name\x18\x05 \x01(\tR\vgatewayName\x12!\n" +
+	"\fcontainer_id\x18\x06 \x01(\tR\vcontainerId\x12\x1d\n" +
+	"\n" +
+	"netns_path\x18\a \x01(\tR\tnetnsPath\"\xf3\x01\n" +
 	"\x0eNicAddResponse\x12\x1f\n" +
 	"\vendpoint_ip\x18\x01 \x01(\tR\n" +
 	"endpointIp\x12\x1f\n" +
@@ -478,11 +889,43 @@ const file_pkg_cniprotocol_v1_cni_proto_rawDesc = "" +
 	"\n" +
 	"public_key\x18\x03 \x01(\tR\tpublicKey\x12'\n" +
 	"\x0fexception_cidrs\x18\x04 \x03(\tR\x0eexceptionCidrs\x12E\n" +
-	"\rdefault_route\x18\x05 \x01(\x0e2 .pkg.cniprotocol.v1.DefaultRouteR\fdefaultRoute\"K\n" +
+	"\rdefault_route\x18\x05 \x01(\x0e2 .pkg.cniprotocol.v1.DefaultRouteR\fdefaultRoute\x12\x10\n" +
+	"\x03mtu\x18\x06 \x01(\x05R\x03mtu\"n\n" +
 	"\rNicDelRequest\x12:\n" +
 	"\n" +
-	"pod_config\x18\x01 \x01(\v2\x1b.pkg.cniprotocol.v1.PodInfoR\tpodConfig\"\x10\n" +
-	"\x0eNicDelResponse\"P\n" +
+	"pod_config\x18\x01 \x01(\v2\x1b.pkg.cniprotocol.v1.PodInfoR\tpodConfig\x12!\n" +
+	"\fcontainer_id\x18\x02 \x01(\tR\vcontainerId\"\x10\n" +
+	"\x0eNicDelResponse\"p\n" +
+	"\x0fNicCheckRequest\x12:\n" +
+	"\n" +
+	"pod_config\x18\x01 \x01(\v2\x1b.pkg.cniprotocol.v1.PodInfoR\tpodConfig\x12!\n" +
+	"\fgateway_name\x18\x02 \x01(\tR\vgatewayName\"\xe5\x01\n" +
+	"\x10NicCheckResponse\x12!\n" +
+	"\fendpoint_ips\x18\x01 \x03(\tR\vendpointIps\x12\x1f\n" +
+	"\vlisten_port\x18\x02 \x01(\x05R\n" +
+	"listenPort\x12\x1d\n" +
+	"\n" +
+	"public_key\x18\x03 \x01(\tR\tpublicKey\x12'\n" +
+	"\x0fexception_cidrs\x18\x04 \x03(\tR\x0eexceptionCidrs\x12E\n" +
+	"\rdefault_route\x18\x05 \x01(\x0e2 .pkg.cniprotocol.v1.DefaultRouteR\fdefaultRoute\">\n" +
+	"\fNicGCRequest\x12.\n" +
+	"\x13valid_container_ids\x18\x01 \x03(\tR\x11validContainerIds\"O\n" +
+	"\rNicGCResponse\x12>\n" +
+	"\fdeleted_pods\x18\x01 \x03(\v2\x1b.pkg.cniprotocol.v1.PodInfoR\vdeletedPods\"t\n" +
+	"\x13WatchGatewayRequest\x12:\n" +
+	"\n" +
+	"pod_config\x18\x01 \x01(\v2\x1b.pkg.cniprotocol.v1.PodInfoR\tpodConfig\x12!\n" +
+	"\fgateway_name\x18\x02 \x01(\tR\vgatewayName\"\xf9\x01\n" +
+	"\x14WatchGatewayResponse\x12\x1f\n" +
+	"\vendpoint_ip\x18\x01 \x01(\tR\n" +
+	"endpointIp\x12\x1f\n" +
+	"\vlisten_port\x18\x02 \x01(\x05R\n" +
+	"listenPort\x12\x1d\n" +
+	"\n" +
+	"public_key\x18\x03 \x01(\tR\tpublicKey\x12'\n" +
+	"\x0fexception_cidrs\x18\x04 \x03(\tR\x0eexceptionCidrs\x12E\n" +
+	"\rdefault_route\x18\x05 \x01(\x0e2 .pkg.cniprotocol.v1.DefaultRouteR\fdefaultRoute\x12\x10\n" +
+	"\x03mtu\x18\x06 \x01(\x05R\x03mtu\"P\n" +
 	"\x12PodRetrieveRequest\x12:\n" +
 	"\n" +
 	"pod_config\x18\x01 \x01(\v2\x1b.pkg.cniprotocol.v1.PodInfoR\tpodConfig\"\xb1\x01\n" +
@@ -494,11 +937,14 @@ const file_pkg_cniprotocol_v1_cni_proto_rawDesc = "" +
 	"\fDefaultRoute\x12\x1d\n" +
 	"\x19DEFAULT_ROUTE_UNSPECIFIED\x10\x00\x12'\n" +
 	"#DEFAULT_ROUTE_STATIC_EGRESS_GATEWAY\x10\x01\x12\"\n" +
-	"\x1eDEFAULT_ROUTE_AZURE_NETWORKING\x10\x022\x8e\x02\n" +
+	"\x1eDEFAULT_ROUTE_AZURE_NETWORKING\x10\x022\x98\x04\n" +
 	"\n" +
 	"NicService\x12O\n" +
 	"\x06NicAdd\x12!.pkg.cniprotocol.v1.NicAddRequest\x1a\".pkg.cniprotocol.v1.NicAddResponse\x12O\n" +
-	"\x06NicDel\x12!.pkg.cniprotocol.v1.NicDelRequest\x1a\".pkg.cniprotocol.v1.NicDelResponse\x12^\n" +
+	"\x06NicDel\x12!.pkg.cniprotocol.v1.NicDelRequest\x1a\".pkg.cniprotocol.v1.NicDelResponse\x12U\n" +
+	"\bNicCheck\x12#.pkg.cniprotocol.v1.NicCheckRequest\x1a$.pkg.cniprotocol.v1.NicCheckResponse\x12L\n" +
+	"\x05NicGC\x12 .pkg.cniprotocol.v1.NicGCRequest\x1a!.pkg.cniprotocol.v1.NicGCResponse\x12c\n" +
+	"\fWatchGateway\x12'.pkg.cniprotocol.v1.WatchGatewayRequest\x1a(.pkg.cniprotocol.v1.WatchGatewayResponse0\x01\x12^\n" +
 	"\vPodRetrieve\x12&.pkg.cniprotocol.v1.PodRetrieveRequest\x1a'.pkg.cniprotocol.v1.PodRetrieveResponseB9Z7github.com/Azure/kube-egress-gateway/pkg/cniprotocol/v1b\x06proto3"
 
 var (
@@ -514,35 +960,52 @@ func file_pkg_cniprotocol_v1_cni_proto_rawDescGZIP() []byte {
 }
 
 var file_pkg_cniprotocol_v1_cni_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
-var file_pkg_cniprotocol_v1_cni_proto_msgTypes = make([]protoimpl.MessageInfo, 8)
+var file_pkg_cniprotocol_v1_cni_proto_msgTypes = make([]protoimpl.MessageInfo, 14)
 var file_pkg_cniprotocol_v1_cni_proto_goTypes = []any{
-	(DefaultRoute)(0),           // 0: pkg.cniprotocol.v1.DefaultRoute
-	(*PodInfo)(nil),             // 1: pkg.cniprotocol.v1.PodInfo
-	(*NicAddRequest)(nil),       // 2: pkg.cniprotocol.v1.NicAddRequest
-	(*NicAddResponse)(nil),      // 3: pkg.cniprotocol.v1.NicAddResponse
-	(*NicDelRequest)(nil),       // 4: pkg.cniprotocol.v1.NicDelRequest
-	(*NicDelResponse)(nil),      // 5: pkg.cniprotocol.v1.NicDelResponse
-	(*PodRetrieveRequest)(nil),  // 6: pkg.cniprotocol.v1.PodRetrieveRequest
-	(*PodRetrieveResponse)(nil), // 7: pkg.cniprotocol.v1.PodRetrieveResponse
-	nil,                         // 8: pkg.cniprotocol.v1.PodRetrieveResponse.AnnotationsEntry
+	(DefaultRoute)(0),            // 0: pkg.cniprotocol.v1.DefaultRoute
+	(*PodInfo)(nil),              // 1: pkg.cniprotocol.v1.PodInfo
+	(*NicAddRequest)(nil),        // 2: pkg.cniprotocol.v1.NicAddRequest
+	(*NicAddResponse)(nil),       // 3: pkg.cniprotocol.v1.NicAddResponse
+	(*NicDelRequest)(nil),        // 4: pkg.cniprotocol.v1.NicDelRequest
+	(*NicDelResponse)(nil),       // 5: pkg.cniprotocol.v1.NicDelResponse
+	(*NicCheckRequest)(nil),      // 6: pkg.cniprotocol.v1.NicCheckRequest
+	(*NicCheckResponse)(nil),     // 7: pkg.cniprotocol.v1.NicCheckResponse
+	(*NicGCRequest)(nil),         // 8: pkg.cniprotocol.v1.NicGCRequest
+	(*NicGCResponse)(nil),        // 9: pkg.cniprotocol.v1.NicGCResponse
+	(*WatchGatewayRequest)(nil),  // 10: pkg.cniprotocol.v1.WatchGatewayRequest
+	(*WatchGatewayResponse)(nil), // 11: pkg.cniprotocol.v1.WatchGatewayResponse
+	(*PodRetrieveRequest)(nil),   // 12: pkg.cniprotocol.v1.PodRetrieveRequest
+	(*PodRetrieveResponse)(nil),  // 13: pkg.cniprotocol.v1.PodRetrieveResponse
+	nil,                          // 14: pkg.cniprotocol.v1.PodRetrieveResponse.AnnotationsEntry
 }
 var file_pkg_cniprotocol_v1_cni_proto_depIdxs = []int32{
-	1, // 0: pkg.cniprotocol.v1.NicAddRequest.pod_config:type_name -> pkg.cniprotocol.v1.PodInfo
-	0, // 1: pkg.cniprotocol.v1.NicAddResponse.default_route:type_name -> pkg.cniprotocol.v1.DefaultRoute
-	1, // 2: pkg.cniprotocol.v1.NicDelRequest.pod_config:type_name -> pkg.cniprotocol.v1.PodInfo
-	1, // 3: pkg.cniprotocol.v1.PodRetrieveRequest.pod_config:type_name -> pkg.cniprotocol.v1.PodInfo
-	8, // 4: pkg.cniprotocol.v1.PodRetrieveResponse.annotations:type_name -> pkg.cniprotocol.v1.PodRetrieveResponse.AnnotationsEntry
-	2, // 5: pkg.cniprotocol.v1.NicService.NicAdd:input_type -> pkg.cniprotocol.v1.NicAddRequest
-	4, // 6: pkg.cniprotocol.v1.NicService.NicDel:input_type -> pkg.cniprotocol.v1.NicDelRequest
-	6, // 7: pkg.cniprotocol.v1.NicService.PodRetrieve:input_type -> pkg.cniprotocol.v1.PodRetrieveRequest
-	3, // 8: pkg.cniprotocol.v1.NicService.NicAdd:output_type -> pkg.cniprotocol.v1.NicAddResponse
-	5, // 9: pkg.cniprotocol.v1.NicService.NicDel:output_type -> pkg.cniprotocol.v1.NicDelResponse
-	7, // 10: pkg.cniprotocol.v1.NicService.PodRetrieve:output_type -> pkg.cniprotocol.v1.PodRetrieveResponse
-	8, // [8:11] is the sub-list for method output_type
-	5, // [5:8] is the sub-list for method input_type
-	5, // [5:5] is the sub-list for extension type_name
-	5, // [5:5] is the sub-list for extension extendee
-	0, // [0:5] is the sub-list for field type_name
+	1,  // 0: pkg.cniprotocol.v1.NicAddRequest.pod_config:type_name -> pkg.cniprotocol.v1.PodInfo
+	0,  // 1: pkg.cniprotocol.v1.NicAddResponse.default_route:type_name -> pkg.cniprotocol.v1.DefaultRoute
+	1,  // 2: pkg.cniprotocol.v1.NicDelRequest.pod_config:type_name -> pkg.cniprotocol.v1.PodInfo
+	1,  // 3: pkg.cniprotocol.v1.NicCheckRequest.pod_config:type_name -> pkg.cniprotocol.v1.PodInfo
+	0,  // 4: pkg.cniprotocol.v1.NicCheckResponse.default_route:type_name -> pkg.cniprotocol.v1.DefaultRoute
+	1,  // 5: pkg.cniprotocol.v1.NicGCResponse.deleted_pods:type_name -> pkg.cniprotocol.v1.PodInfo
+	1,  // 6: pkg.cniprotocol.v1.WatchGatewayRequest.pod_config:type_name -> pkg.cniprotocol.v1.PodInfo
+	0,  // 7: pkg.cniprotocol.v1.WatchGatewayResponse.default_route:type_name -> pkg.cniprotocol.v1.DefaultRoute
+	1,  // 8: pkg.cniprotocol.v1.PodRetrieveRequest.pod_config:type_name -> pkg.cniprotocol.v1.PodInfo
+	14, // 9: pkg.cniprotocol.v1.PodRetrieveResponse.annotations:type_name -> pkg.cniprotocol.v1.PodRetrieveResponse.AnnotationsEntry
+	2,  // 10: pkg.cniprotocol.v1.NicService.NicAdd:input_type -> pkg.cniprotocol.v1.NicAddRequest
+	4,  // 11: pkg.cniprotocol.v1.NicService.NicDel:input_type -> pkg.cniprotocol.v1.NicDelRequest
+	6,  // 12: pkg.cniprotocol.v1.NicService.NicCheck:input_type -> pkg.cniprotocol.v1.NicCheckRequest
+	8,  // 13: pkg.cniprotocol.v1.NicService.NicGC:input_type -> pkg.cniprotocol.v1.NicGCRequest
+	10, // 14: pkg.cniprotocol.v1.NicService.WatchGateway:input_type -> pkg.cniprotocol.v1.WatchGatewayRequest
+	12, // 15: pkg.cniprotocol.v1.NicService.PodRetrieve:input_type -> pkg.cniprotocol.v1.PodRetrieveRequest
+	3,  // 16: pkg.cniprotocol.v1.NicService.NicAdd:output_type -> pkg.cniprotocol.v1.NicAddResponse
+	5,  // 17: pkg.cniprotocol.v1.NicService.NicDel:output_type -> pkg.cniprotocol.v1.NicDelResponse
+	7,  // 18: pkg.cniprotocol.v1.NicService.NicCheck:output_type -> pkg.cniprotocol.v1.NicCheckResponse
+	9,  // 19: pkg.cniprotocol.v1.NicService.NicGC:output_type -> pkg.cniprotocol.v1.NicGCResponse
+	11, // 20: pkg.cniprotocol.v1.NicService.WatchGateway:output_type -> pkg.cniprotocol.v1.WatchGatewayResponse
+	13, // 21: pkg.cniprotocol.v1.NicService.PodRetrieve:output_type -> pkg.cniprotocol.v1.PodRetrieveResponse
+	16, // [16:22] is the sub-list for method output_type
+	10, // [10:16] is the sub-list for method input_type
+	10, // [10:10] is the sub-list for extension type_name
+	10, // [10:10] is the sub-list for extension extendee
+	0,  // [0:10] is the sub-list for field type_name
 }
 
 func init() { file_pkg_cniprotocol_v1_cni_proto_init() }
@@ -556,7 +1019,7 @@ func file_pkg_cniprotocol_v1_cni_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_pkg_cniprotocol_v1_cni_proto_rawDesc), len(file_pkg_cniprotocol_v1_cni_proto_rawDesc)),
 			NumEnums:      1,
-			NumMessages:   8,
+			NumMessages:   14,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -23,9 +23,12 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	NicService_NicAdd_FullMethodName      = "/pkg.cniprotocol.v1.NicService/NicAdd"
-	NicService_NicDel_FullMethodName      = "/pkg.cniprotocol.v1.NicService/NicDel"
-	NicService_PodRetrieve_FullMethodName = "/pkg.cniprotocol.v1.NicService/PodRetrieve"
+	NicService_NicAdd_FullMethodName       = "/pkg.cniprotocol.v1.NicService/NicAdd"
+	NicService_NicDel_FullMethodName       = "/pkg.cniprotocol.v1.NicService/NicDel"
+	NicService_NicCheck_FullMethodName     = "/pkg.cniprotocol.v1.NicService/NicCheck"
+	NicService_NicGC_FullMethodName        = "/pkg.cniprotocol.v1.NicService/NicGC"
+	NicService_WatchGateway_FullMethodName = "/pkg.cniprotocol.v1.NicService/WatchGateway"
+	NicService_PodRetrieve_FullMethodName  = "/pkg.cniprotocol.v1.NicService/PodRetrieve"
 )
 
 // NicServiceClient is the client API for NicService service.
@@ -38,6 +41,12 @@ type NicServiceClient interface {
 	NicAdd(ctx context.Context, in *NicAddRequest, opts ...grpc.CallOption) (*NicAddResponse, error)
 	// NicDel: delete pod endpoint resource
 	NicDel(ctx context.Context, in *NicDelRequest, opts ...grpc.CallOption) (*NicDelResponse, error)
+	// NicCheck: return gateway configuration the pod nic is expected to match
+	NicCheck(ctx context.Context, in *NicCheckRequest, opts ...grpc.CallOption) (*NicCheckResponse, error)
+	// NicGC: delete pod endpoint resources of this node not attached to any valid container
+	NicGC(ctx context.Context, in *NicGCRequest, opts ...grpc.CallOption) (*NicGCResponse, error)
+	// WatchGateway: stream gateway configuration of the pod nic, the current one first and then every change
+	WatchGateway(ctx context.Context, in *WatchGatewayRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[WatchGatewayResponse], error)
 	// PodRetrieve: send pod information and return pod information
 	PodRetrieve(ctx context.Context, in *PodRetrieveRequest, opts ...grpc.CallOption) (*PodRetrieveResponse, error)
 }
@@ -70,6 +79,45 @@ func (c *nicServiceClient) NicDel(ctx context.Context, in *NicDelRequest, opts .
 	return out, nil
 }
 
+func (c *nicServiceClient) NicCheck(ctx context.Context, in *NicCheckRequest, opts ...grpc.CallOption) (*NicCheckResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(NicCheckResponse)
+	err := c.cc.Invoke(ctx, NicService_NicCheck_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nicServiceClient) NicGC(ctx context.Context, in *NicGCRequest, opts ...grpc.CallOption) (*NicGCResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(NicGCResponse)
+	err := c.cc.Invoke(ctx, NicService_NicGC_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nicServiceClient) WatchGateway(ctx context.Context, in *WatchGatewayRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[WatchGatewayResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &NicService_ServiceDesc.Streams[0], NicService_WatchGateway_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchGatewayRequest, WatchGatewayResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type NicService_WatchGatewayClient = grpc.ServerStreamingClient[WatchGatewayResponse]
+
 func (c *nicServiceClient) PodRetrieve(ctx context.Context, in *PodRetrieveRequest, opts ...grpc.CallOption) (*PodRetrieveResponse, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(PodRetrieveResponse)
@@ -90,6 +138,12 @@ type NicServiceServer interface {
 	NicAdd(context.Context, *NicAddRequest) (*NicAddResponse, error)
 	// NicDel: delete pod endpoint resource
 	NicDel(context.Context, *NicDelRequest) (*NicDelResponse, error)
+	// NicCheck: return gateway configuration the pod nic is expected to match
+	NicCheck(context.Context, *NicCheckRequest) (*NicCheckResponse, error)
+	// NicGC: delete pod endpoint resources of this node not attached to any valid container
+	NicGC(context.Context, *NicGCRequest) (*NicGCResponse, error)
+	// WatchGateway: stream gateway configuration of the pod nic, the current one first and then every change
+	WatchGateway(*WatchGatewayRequest, grpc.ServerStreamingServer[WatchGatewayResponse]) error
 	// PodRetrieve: send pod information and return pod information
 	PodRetrieve(context.Context, *PodRetrieveRequest) (*PodRetrieveResponse, error)
 	mustEmbedUnimplementedNicServiceServer()
@@ -108,6 +162,15 @@ func (UnimplementedNicServiceServer) NicAdd(context.Context, *NicAddRequest) (*N
 func (UnimplementedNicServiceServer) NicDel(context.Context, *NicDelRequest) (*NicDelResponse, error) {
 	return nil, status.Error(codes.Unimplemented, "method NicDel not implemented")
 }
+func (UnimplementedNicServiceServer) NicCheck(context.Context, *NicCheckRequest) (*NicCheckResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method NicCheck not implemented")
+}
+func (UnimplementedNicServiceServer) NicGC(context.Context, *NicGCRequest) (*NicGCResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method NicGC not implemented")
+}
+func (UnimplementedNicServiceServer) WatchGateway(*WatchGatewayRequest, grpc.ServerStreamingServer[WatchGatewayResponse]) error {
+	return status.Error(codes.Unimplemented, "method WatchGateway not implemented")
+}
 func (UnimplementedNicServiceServer) PodRetrieve(context.Context, *PodRetrieveRequest) (*PodRetrieveResponse, error) {
 	return nil, status.Error(codes.Unimplemented, "method PodRetrieve not implemented")
 }
@@ -168,6 +231,53 @@ func _NicService_NicDel_Handler(srv interface{}, ctx context.Context, dec func(i
 	return interceptor(ctx, in, info, handler)
 }
 
+func _NicService_NicCheck_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(NicCheckRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NicServiceServer).NicCheck(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NicService_NicCheck_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NicServiceServer).NicCheck(ctx, req.(*NicCheckRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NicService_NicGC_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(NicGCRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NicServiceServer).NicGC(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NicService_NicGC_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NicServiceServer).NicGC(ctx, req.(*NicGCRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NicService_WatchGateway_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchGatewayRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(NicServiceServer).WatchGateway(m, &grpc.GenericServerStream[WatchGatewayRequest, WatchGatewayResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type NicService_WatchGatewayServer = grpc.ServerStreamingServer[WatchGatewayResponse]
+
 func _NicService_PodRetrieve_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(PodRetrieveRequest)
 	if err := dec(in); err != nil {
@@ -201,11 +311,25 @@ var NicService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "NicDel",
 			Handler:    _NicService_NicDel_Handler,
 		},
+		{
+			MethodName: "NicCheck",
+			Handler:    _NicService_NicCheck_Handler,
+		},
+		{
+			MethodName: "NicGC",
+			Handler:    _NicService_NicGC_Handler,
+		},
 		{
 			MethodName: "PodRetrieve",
 			Handler:    _NicService_PodRetrieve_Handler,
 		},
 	},
-	Streams:  []grpc.StreamDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchGateway",
+			Handler:       _NicService_WatchGateway_Handler,
+			ServerStreams: true,
+		},
+	},
 	Metadata: "pkg/cniprotocol/v1/cni.proto",
 }
@@ -8,8 +8,11 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"strings"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthgrpc "google.golang.org/grpc/health/grpc_health_v1"
 )
 
 const (
@@ -46,6 +49,22 @@ func (s *TestServer) NicDel(ctx context.Context, in *NicDelRequest) (*NicDelResp
 	return &NicDelResponse{}, nil
 }
 
+func (s *TestServer) NicCheck(ctx context.Context, in *NicCheckRequest) (*NicCheckResponse, error) {
+	s.Received <- in
+	return &NicCheckResponse{
+		EndpointIps:    []string{TEST_SERVER_WIREGUARD_SERVER_IP},
+		ListenPort:     TEST_SERVER_WIREGUARD_SERVER_PORT,
+		PublicKey:      TEST_SERVER_WIREGUARD_PUBLIC_KEY,
+		ExceptionCidrs: s.exceptionCidrs,
+		DefaultRoute:   s.defaultRoute,
+	}, nil
+}
+
+func (s *TestServer) NicGC(ctx context.Context, in *NicGCRequest) (*NicGCResponse, error) {
+	s.Received <- in
+	return &NicGCResponse{}, nil
+}
+
 func (s *TestServer) PodRetrieve(ctx context.Context, in *PodRetrieveRequest) (*PodRetrieveResponse, error) {
 	s.Received <- in
 	return &PodRetrieveResponse{Annotations: s.podAnnotations}, nil
@@ -76,12 +95,17 @@ func StartTestServerWithDefaultRoute(addr string, exceptionCidrs []string, podAn
 		defaultRoute:   defaultRoute,
 	}
 
-	s.lis, err = net.Listen("tcp", addr)
+	network := "tcp"
+	if path, ok := strings.CutPrefix(addr, "unix://"); ok {
+		network, addr = "unix", path
+	}
+	s.lis, err = net.Listen(network, addr)
 	if err != nil {
-		return nil, fmt.Errorf("failed to listen on tcp addr %s: %v", addr, err)
+		return nil, fmt.Errorf("failed to listen on %s addr %s: %v", network, addr, err)
 	}
 
 	RegisterNicServiceServer(s.grpcServer, s)
+	healthgrpc.RegisterHealthServer(s.grpcServer, health.NewServer())
 	go s.startServer()
 	return s, nil
 }
@@ -12,12 +12,18 @@ const (
 	// GatewayVMConfiguration finalizer name
 	VMConfigFinalizerName = "gateway-vm-configuration-controller.microsoft.com"
 
+	// EgressIPPool finalizer name
+	EgressIPPoolFinalizerName = "egress-ip-pool-controller.microsoft.com"
+
 	// Default gateway LoadBalancer name
 	DefaultGatewayLBName = "kubeegressgateway-ilb"
 
 	// Prefix for managed Azure resources (public IPPrefix, VMSS ipConfig, etc)
 	ManagedResourcePrefix = "egressgateway-"
 
+	// Prefix for public IP prefixes managed by EgressIPPools
+	ManagedPoolResourcePrefix = "egressgateway-pool-"
+
 	// Key name in the wireugard private key secret
 	WireguardPrivateKeyName = "PrivateKey"
 
@@ -63,6 +69,9 @@ const (
 	// Owning StaticGatewayConfiguration name key on secret label
 	OwningSGCNameLabel = "egressgateway.kubernetes.azure.com/owning-gateway-config-name"
 
+	// Name of the node the pod runs on, key on podEndpoint label
+	PodEndpointNodeNameLabel = "egressgateway.kubernetes.azure.com/node-name"
+
 	// Default user agent for Azure SDK
 	DefaultUserAgent = "kube-egress-gateway-controller"
 )
@@ -74,6 +83,10 @@ const (
 	// wireguard link name in gateway namespace
 	WireguardLinkName = "wg0"
 
+	// bytes added by wireguard encapsulation with an IPv6 outer header (40 IPv6 + 8 UDP + 32 wireguard),
+	// subtracted from the MTU of eth0 to get the default MTU of wireguard links
+	WireguardMTUOverhead = 80
+
 	// wireguard link name prefix in gateway namespace
 	WiregaurdLinkNamePrefix = "wg-"
 
@@ -86,6 +99,9 @@ const (
 	// gateway IP
 	GatewayIP = "fe80::1/64"
 
+	// gateway IP without prefix length, next hop of pods without tunnel address
+	GatewayLinkLocalIP = "fe80::1"
+
 	// post routing chain name
 	PostRoutingChain = "POSTROUTING"
 
@@ -101,6 +117,9 @@ const (
 	// mangle table name
 	MangleTable = "mangle"
 
+	// nftables table owned by kube-egress-gateway
+	NFTablesTableName = "kube-egress-gateway"
+
 	// environment variable name for pod namespace
 	PodNamespaceEnvKey = "MY_POD_NAMESPACE"
 
@@ -112,6 +131,13 @@ const (
 
 	// ilb ip address label
 	ILBIPLabel = "eth0:egress"
+
+	// node annotation to drain gateways on the node, "*" for all gateways or a comma separated
+	// list of StaticGatewayConfigurations in <namespace>/<name> pattern
+	GatewayDrainAnnotationKey = "egressgateway.kubernetes.azure.com/drain"
+
+	// GatewayDrainAllValue drains all gateways on the node
+	GatewayDrainAllValue = "*"
 )
 
 const (
@@ -119,6 +145,23 @@ const (
 
 	CNIGatewayAnnotationKey = "kubernetes.azure.com/static-gateway-configuration"
 
+	// pod annotation selecting a dedicated egress IP of the StaticGatewayConfiguration the pod uses
+	CNIDedicatedEgressIPAnnotationKey = "kubernetes.azure.com/dedicated-egress-ip"
+
+	// scheme prefix of cni manager unix socket path in cni config
+	UnixSocketScheme = "unix://"
+
+	// directory where cni plugin records pod sandboxes, so that cni manager can attach pods to gateways later
+	CNISandboxDir = "/var/run/kube-egress-gateway/sandboxes"
+
+	// directory where kube-egress-cni-ipam persists the tunnel addresses allocated from its ranges
+	IPAMDataDir = "/var/run/kube-egress-gateway/ipam"
+
+	// node annotation with the ranges tunnel addresses of the pods on the node are allocated from, a comma
+	// separated list of IPv4 subnets, optionally prefixed with a StaticGatewayConfiguration in
+	// <namespace>/<name>= pattern to restrict the range to the pods using it
+	IPAMRangesAnnotationKey = "egressgateway.kubernetes.azure.com/tunnel-ranges"
+
 	// this taint is applied to AKS nodes when cniManager is not ready
 	CNIManagerNotReadyTaintKey = "egressgateway.kubernetes.azure.com/cni-not-ready"
 )
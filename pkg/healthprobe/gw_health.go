@@ -4,6 +4,7 @@ package healthprobe
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"net"
 	"net/http"
@@ -26,12 +27,48 @@ const (
 	DefaultLBProbeDrainDelay = 10 * time.Second
 )
 
+// Checker checks the data path of a gateway on this node.
+type Checker interface {
+	// CheckGateway runs the data path checks of the gateway. The gateway is healthy when all checks pass.
+	CheckGateway(ctx context.Context, gatewayUID string) []CheckResult
+}
+
+// CheckResult is the result of one data path check of a gateway.
+type CheckResult struct {
+	Name    string `json:"name"`
+	Healthy bool   `json:"healthy"`
+	Message string `json:"message,omitempty"`
+}
+
+// GatewayHealth is the health of a gateway reported to the LB, it is returned as JSON by /gw/<uid>?verbose.
+type GatewayHealth struct {
+	Healthy      bool `json:"healthy"`
+	Active       bool `json:"active"`
+	Draining     bool `json:"draining,omitempty"`
+	ShuttingDown bool `json:"shuttingDown,omitempty"`
+	// LastCheckTime is the time of the last data path checks, empty if the gateway has not been checked yet
+	LastCheckTime *time.Time    `json:"lastCheckTime,omitempty"`
+	Checks        []CheckResult `json:"checks,omitempty"`
+}
+
+// gatewayChecks are the cached results of the data path checks of a gateway.
+type gatewayChecks struct {
+	time    time.Time
+	results []CheckResult
+}
+
 type LBProbeServer struct {
 	lock           sync.RWMutex
 	activeGateways map[string]bool
-	listenPort     int
-	drainDelay     time.Duration
-	shuttingDown   bool
+	// gateways reported unhealthy so that the LB stops sending new connections to them
+	drainingGateways map[string]bool
+	listenPort       int
+	drainDelay       time.Duration
+	shuttingDown     bool
+	// data path checks, the gateways are only checked when the checker is set
+	checker       Checker
+	checkInterval time.Duration
+	checks        map[string]gatewayChecks
 }
 
 func NewLBProbeServer(listenPort int, drainDelay time.Duration) *LBProbeServer {
@@ -39,12 +76,24 @@ func NewLBProbeServer(listenPort int, drainDelay time.Duration) *LBProbeServer {
 		drainDelay = DefaultLBProbeDrainDelay
 	}
 	return &LBProbeServer{
-		activeGateways: make(map[string]bool),
-		listenPort:     listenPort,
-		drainDelay:     drainDelay,
+		activeGateways:   make(map[string]bool),
+		drainingGateways: make(map[string]bool),
+		listenPort:       listenPort,
+		drainDelay:       drainDelay,
+		checks:           make(map[string]gatewayChecks),
 	}
 }
 
+// SetChecker runs the data path checks of every active gateway each interval once the server
+// is started. A gateway failing any check is reported unhealthy.
+func (svr *LBProbeServer) SetChecker(checker Checker, interval time.Duration) {
+	svr.lock.Lock()
+	defer svr.lock.Unlock()
+
+	svr.checker = checker
+	svr.checkInterval = interval
+}
+
 func (svr *LBProbeServer) Start(ctx context.Context) error {
 	log := log.FromContext(ctx)
 
@@ -59,6 +108,13 @@ func (svr *LBProbeServer) Start(ctx context.Context) error {
 		ReadHeaderTimeout: 32 * time.Second,
 	}
 
+	svr.lock.RLock()
+	checker, checkInterval := svr.checker, svr.checkInterval
+	svr.lock.RUnlock()
+	if checker != nil && checkInterval > 0 {
+		go svr.runChecks(ctx, checker, checkInterval)
+	}
+
 	go func() {
 		log.Info("Starting gateway lb health probe server")
 		if err := httpServer.ListenAndServe(); err != nil {
@@ -106,9 +162,31 @@ func (svr *LBProbeServer) RemoveGateway(gatewayUID string) error {
 	defer svr.lock.Unlock()
 
 	delete(svr.activeGateways, gatewayUID)
+	delete(svr.drainingGateways, gatewayUID)
+	delete(svr.checks, gatewayUID)
 	return nil
 }
 
+// SetDraining marks the gateway as draining or not. A draining gateway fails the
+// LB health probe while it stays configured, so that existing connections keep working.
+func (svr *LBProbeServer) SetDraining(gatewayUID string, draining bool) {
+	svr.lock.Lock()
+	defer svr.lock.Unlock()
+
+	if draining {
+		svr.drainingGateways[gatewayUID] = true
+	} else {
+		delete(svr.drainingGateways, gatewayUID)
+	}
+}
+
+func (svr *LBProbeServer) IsDraining(gatewayUID string) bool {
+	svr.lock.RLock()
+	defer svr.lock.RUnlock()
+
+	return svr.drainingGateways[gatewayUID]
+}
+
 func (svr *LBProbeServer) GetGateways() []string {
 	var res []string
 	svr.lock.RLock()
@@ -120,6 +198,63 @@ func (svr *LBProbeServer) GetGateways() []string {
 	return res
 }
 
+// runChecks checks the data path of the active gateways every interval until ctx is done.
+func (svr *LBProbeServer) runChecks(ctx context.Context, checker Checker, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			svr.checkGateways(ctx, checker)
+		}
+	}
+}
+
+func (svr *LBProbeServer) checkGateways(ctx context.Context, checker Checker) {
+	log := log.FromContext(ctx)
+	for _, gatewayUID := range svr.GetGateways() {
+		results := checker.CheckGateway(ctx, gatewayUID)
+		for _, result := range results {
+			if !result.Healthy {
+				log.Info("Gateway data path check failed", "gateway", gatewayUID, "check", result.Name, "message", result.Message)
+			}
+		}
+
+		svr.lock.Lock()
+		// the gateway may have been removed while it was checked
+		if svr.activeGateways[gatewayUID] {
+			svr.checks[gatewayUID] = gatewayChecks{time: time.Now(), results: append([]CheckResult(nil), results...)}
+		}
+		svr.lock.Unlock()
+	}
+}
+
+// GetGatewayHealth returns the health of the gateway as reported to the LB.
+func (svr *LBProbeServer) GetGatewayHealth(gatewayUID string) GatewayHealth {
+	svr.lock.RLock()
+	defer svr.lock.RUnlock()
+
+	health := GatewayHealth{
+		Active:       svr.activeGateways[gatewayUID],
+		Draining:     svr.drainingGateways[gatewayUID],
+		ShuttingDown: svr.shuttingDown,
+	}
+	// gateways are healthy right after they are configured, until they are checked
+	healthy := true
+	if checks, ok := svr.checks[gatewayUID]; ok {
+		checkTime := checks.time
+		health.LastCheckTime = &checkTime
+		health.Checks = append(health.Checks, checks.results...)
+		for _, result := range checks.results {
+			healthy = healthy && result.Healthy
+		}
+	}
+	health.Healthy = health.Active && !health.Draining && !health.ShuttingDown && healthy
+	return health
+}
+
 func (svr *LBProbeServer) serveHTTP(resp http.ResponseWriter, req *http.Request) {
 	reqPath := req.URL.Path
 	subPaths := strings.Split(reqPath, "/")
@@ -129,14 +264,17 @@ func (svr *LBProbeServer) serveHTTP(resp http.ResponseWriter, req *http.Request)
 	}
 	gatewayUID := subPaths[2]
 
-	svr.lock.RLock()
-	stopping := svr.shuttingDown
-	_, ok := svr.activeGateways[gatewayUID]
-	svr.lock.RUnlock()
+	health := svr.GetGatewayHealth(gatewayUID)
+	status := http.StatusOK
+	if !health.Healthy {
+		status = http.StatusServiceUnavailable
+	}
 
-	if stopping || !ok {
-		resp.WriteHeader(http.StatusServiceUnavailable)
-	} else {
-		resp.WriteHeader(http.StatusOK)
+	if _, verbose := req.URL.Query()["verbose"]; verbose {
+		resp.Header().Set("Content-Type", "application/json")
+		resp.WriteHeader(status)
+		_ = json.NewEncoder(resp).Encode(health)
+		return
 	}
+	resp.WriteHeader(status)
 }
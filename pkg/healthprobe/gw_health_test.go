@@ -4,6 +4,7 @@ package healthprobe
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net"
 	"net/http"
@@ -88,6 +89,108 @@ func TestGatewayHealthServer_ShutdownReturnsUnavailable(t *testing.T) {
 	testHandler(svr, "/gw/123", http.StatusServiceUnavailable, t)
 }
 
+func TestGatewayHealthServer_DrainingReturnsUnavailable(t *testing.T) {
+	svr := NewLBProbeServer(1000, 0)
+
+	assert.Nil(t, svr.AddGateway("123"))
+	assert.Nil(t, svr.AddGateway("456"))
+
+	// Draining gateway should return 503 while the others stay healthy
+	svr.SetDraining("123", true)
+	assert.True(t, svr.IsDraining("123"))
+	testHandler(svr, "/gw/123", http.StatusServiceUnavailable, t)
+	testHandler(svr, "/gw/456", http.StatusOK, t)
+	assert.Equal(t, 2, len(svr.GetGateways()), "draining gateway should stay active")
+
+	// Re-adding during reconcile should not undrain
+	assert.Nil(t, svr.AddGateway("123"))
+	testHandler(svr, "/gw/123", http.StatusServiceUnavailable, t)
+
+	// Undrain
+	svr.SetDraining("123", false)
+	assert.False(t, svr.IsDraining("123"))
+	testHandler(svr, "/gw/123", http.StatusOK, t)
+
+	// Removing gateway should clear draining state
+	svr.SetDraining("456", true)
+	assert.Nil(t, svr.RemoveGateway("456"))
+	assert.False(t, svr.IsDraining("456"))
+}
+
+type fakeChecker struct {
+	results map[string][]CheckResult
+}
+
+func (c *fakeChecker) CheckGateway(ctx context.Context, gatewayUID string) []CheckResult {
+	return c.results[gatewayUID]
+}
+
+func TestGatewayHealthServer_DataPathChecks(t *testing.T) {
+	svr := NewLBProbeServer(1000, 0)
+	checker := &fakeChecker{results: map[string][]CheckResult{
+		"123": {{Name: "wireguard", Healthy: true}, {Name: "snat", Healthy: true}},
+		"456": {{Name: "wireguard", Healthy: true}, {Name: "snat", Healthy: false, Message: "sNAT rules are missing"}},
+	}}
+	svr.SetChecker(checker, time.Second)
+	assert.Nil(t, svr.AddGateway("123"))
+	assert.Nil(t, svr.AddGateway("456"))
+
+	// gateways are healthy until they are checked
+	testHandler(svr, "/gw/123", http.StatusOK, t)
+	testHandler(svr, "/gw/456", http.StatusOK, t)
+
+	svr.checkGateways(context.Background(), checker)
+	testHandler(svr, "/gw/123", http.StatusOK, t)
+	testHandler(svr, "/gw/456", http.StatusServiceUnavailable, t)
+
+	// results are cached until the next check
+	checker.results["456"][1].Healthy = true
+	testHandler(svr, "/gw/456", http.StatusServiceUnavailable, t)
+	svr.checkGateways(context.Background(), checker)
+	testHandler(svr, "/gw/456", http.StatusOK, t)
+
+	// removed gateway does not keep its results
+	assert.Nil(t, svr.RemoveGateway("456"))
+	health := svr.GetGatewayHealth("456")
+	assert.False(t, health.Healthy)
+	assert.Nil(t, health.LastCheckTime)
+	assert.Empty(t, health.Checks)
+}
+
+func TestGatewayHealthServer_Verbose(t *testing.T) {
+	svr := NewLBProbeServer(1000, 0)
+	checker := &fakeChecker{results: map[string][]CheckResult{
+		"123": {{Name: "wireguard", Healthy: false, Message: "link is down"}},
+	}}
+	svr.SetChecker(checker, time.Second)
+	assert.Nil(t, svr.AddGateway("123"))
+	svr.SetDraining("123", true)
+	svr.checkGateways(context.Background(), checker)
+
+	req, err := http.NewRequest("GET", "/gw/123?verbose", nil)
+	assert.Nil(t, err)
+	resp := httptest.NewRecorder()
+	svr.serveHTTP(resp, req)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.Code)
+	assert.Equal(t, "application/json", resp.Header().Get("Content-Type"))
+
+	health := GatewayHealth{}
+	assert.Nil(t, json.Unmarshal(resp.Body.Bytes(), &health))
+	assert.False(t, health.Healthy)
+	assert.True(t, health.Active)
+	assert.True(t, health.Draining)
+	assert.NotNil(t, health.LastCheckTime)
+	assert.Equal(t, []CheckResult{{Name: "wireguard", Healthy: false, Message: "link is down"}}, health.Checks)
+
+	// unknown gateway
+	req, err = http.NewRequest("GET", "/gw/456?verbose", nil)
+	assert.Nil(t, err)
+	resp = httptest.NewRecorder()
+	svr.serveHTTP(resp, req)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.Code)
+	assert.JSONEq(t, `{"healthy": false, "active": false}`, resp.Body.String())
+}
+
 func TestGatewayHealthServer_GracefulShutdownLifecycle(t *testing.T) {
 	// Use port 0 to let the OS pick a free port
 	svr := NewLBProbeServer(0, 0)
@@ -18,6 +18,7 @@ type ComputeMetadata struct {
 	SubscriptionID    string    `json:"subscriptionId"`
 	Tags              string    `json:"tags"`
 	VMScaleSetName    string    `json:"vmScaleSetName"`
+	Zone              string    `json:"zone"`
 }
 
 type NetworkMetadata struct {
@@ -0,0 +1,406 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+// Package inspect joins the API objects of kube-egress-gateway into views of a gateway or of a pod using it,
+// and finds the first broken link between them, for kubectl-egressgateway.
+package inspect
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	current "github.com/Azure/kube-egress-gateway/api/v1alpha1"
+	"github.com/Azure/kube-egress-gateway/pkg/consts"
+)
+
+// GatewayReport is the joined view of a StaticGatewayConfiguration and the objects derived from it.
+type GatewayReport struct {
+	Name           string              `json:"name"`
+	Namespace      string              `json:"namespace"`
+	NodepoolName   string              `json:"nodepoolName,omitempty"`
+	VmssName       string              `json:"vmssName,omitempty"`
+	EgressIpPrefix string              `json:"egressIpPrefix,omitempty"`
+	FrontendIP     string              `json:"frontendIP,omitempty"`
+	Port           int32               `json:"port,omitempty"`
+	PublicKey      string              `json:"publicKey,omitempty"`
+	SecretFound    bool                `json:"secretFound"`
+	LBConfigFound  bool                `json:"lbConfigFound"`
+	VMConfigFound  bool                `json:"vmConfigFound"`
+	PodEndpoints   int                 `json:"podEndpoints"`
+	Nodes          []GatewayNodeReport `json:"nodes,omitempty"`
+}
+
+// GatewayNodeReport is the state of the gateway on one of its gateway nodes.
+type GatewayNodeReport struct {
+	Name        string `json:"name"`
+	PrimaryIP   string `json:"primaryIP,omitempty"`
+	SecondaryIP string `json:"secondaryIP,omitempty"`
+	// Ready is true if the daemon on the node reports the gateway network namespace as provisioned.
+	Ready         bool   `json:"ready"`
+	InterfaceName string `json:"interfaceName,omitempty"`
+	DrainPhase    string `json:"drainPhase,omitempty"`
+	Peers         int    `json:"peers"`
+	// LastError is the error of the last reconcile of the gateway on the node, empty if it succeeded.
+	LastError string `json:"lastError,omitempty"`
+}
+
+// PodReport is the joined view of a pod and the gateway it uses.
+type PodReport struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	NodeName  string `json:"nodeName,omitempty"`
+	// Gateway is the StaticGatewayConfiguration in the pod annotation, empty if the pod does not use one.
+	Gateway          string                 `json:"gateway,omitempty"`
+	GatewayFound     bool                   `json:"gatewayFound"`
+	EgressIpPrefix   string                 `json:"egressIpPrefix,omitempty"`
+	PodEndpointFound bool                   `json:"podEndpointFound"`
+	PodIpAddress     string                 `json:"podIpAddress,omitempty"`
+	PodPublicKey     string                 `json:"podPublicKey,omitempty"`
+	GatewayNodes     []PodGatewayNodeReport `json:"gatewayNodes,omitempty"`
+}
+
+// PodGatewayNodeReport is the state of the pod peer on one of the gateway nodes.
+type PodGatewayNodeReport struct {
+	Name  string `json:"name"`
+	Ready bool   `json:"ready"`
+	// PeerPresent is true if the daemon on the node reports the pod public key as a peer of the gateway.
+	PeerPresent bool `json:"peerPresent"`
+	// EgressIP is the source IP of the pod traffic leaving the node, when no public IP prefix is provisioned.
+	// Comma separated when the gateway has more than one sNAT IP per node.
+	EgressIP string `json:"egressIP,omitempty"`
+}
+
+// Diagnosis is the first broken link from a pod to its egress gateway.
+type Diagnosis struct {
+	// Healthy is true if no broken link is found.
+	Healthy bool `json:"healthy"`
+	// Check is the name of the failed check, or of the last check if healthy.
+	Check   string `json:"check"`
+	Message string `json:"message"`
+}
+
+// gateway is the set of objects of a StaticGatewayConfiguration.
+type gateway struct {
+	sgc           *current.StaticGatewayConfiguration
+	secretFound   bool
+	lbConfig      *current.GatewayLBConfiguration
+	vmConfig      *current.GatewayVMConfiguration
+	podEndpoints  []current.PodEndpoint
+	gatewayStatus map[string]*current.GatewayStatus
+}
+
+func getGateway(ctx context.Context, c client.Reader, key types.NamespacedName) (*gateway, error) {
+	gw := &gateway{sgc: &current.StaticGatewayConfiguration{}, gatewayStatus: make(map[string]*current.GatewayStatus)}
+	if err := c.Get(ctx, key, gw.sgc); err != nil {
+		return nil, err
+	}
+	if ref := gw.sgc.Status.PrivateKeySecretRef; ref != nil {
+		found, err := getOptional(ctx, c, types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}, &corev1.Secret{})
+		if err != nil {
+			return nil, err
+		}
+		gw.secretFound = found
+	}
+	lbConfig := &current.GatewayLBConfiguration{}
+	if found, err := getOptional(ctx, c, key, lbConfig); err != nil {
+		return nil, err
+	} else if found {
+		gw.lbConfig = lbConfig
+	}
+	vmConfig := &current.GatewayVMConfiguration{}
+	if found, err := getOptional(ctx, c, key, vmConfig); err != nil {
+		return nil, err
+	} else if found {
+		gw.vmConfig = vmConfig
+	}
+
+	podEndpoints := &current.PodEndpointList{}
+	if err := c.List(ctx, podEndpoints, client.InNamespace(key.Namespace)); err != nil {
+		return nil, fmt.Errorf("failed to list PodEndpoints: %w", err)
+	}
+	for _, podEndpoint := range podEndpoints.Items {
+		if podEndpoint.Spec.StaticGatewayConfiguration == key.Name {
+			gw.podEndpoints = append(gw.podEndpoints, podEndpoint)
+		}
+	}
+
+	// GatewayStatuses are named after the gateway nodes, in the namespace of the daemon
+	gwStatuses := &current.GatewayStatusList{}
+	if err := c.List(ctx, gwStatuses); err != nil {
+		return nil, fmt.Errorf("failed to list GatewayStatuses: %w", err)
+	}
+	for i := range gwStatuses.Items {
+		gw.gatewayStatus[gwStatuses.Items[i].Name] = &gwStatuses.Items[i]
+	}
+	return gw, nil
+}
+
+func getOptional(ctx context.Context, c client.Reader, key types.NamespacedName, obj client.Object) (bool, error) {
+	if err := c.Get(ctx, key, obj); err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to get %T %s: %w", obj, key, err)
+	}
+	return true, nil
+}
+
+func (gw *gateway) key() string {
+	return fmt.Sprintf("%s/%s", gw.sgc.Namespace, gw.sgc.Name)
+}
+
+func (gw *gateway) vmProfiles() []current.GatewayVMProfile {
+	if gw.vmConfig == nil || gw.vmConfig.Status == nil {
+		return nil
+	}
+	profiles := append([]current.GatewayVMProfile(nil), gw.vmConfig.Status.GatewayVMProfiles...)
+	sort.Slice(profiles, func(i, j int) bool { return profiles[i].NodeName < profiles[j].NodeName })
+	return profiles
+}
+
+// snatIPs returns the comma separated sNAT IPs of the gateway VM.
+func snatIPs(profile current.GatewayVMProfile) string {
+	if len(profile.SecondaryIPs) > 0 {
+		return strings.Join(profile.SecondaryIPs, ",")
+	}
+	return profile.SecondaryIP
+}
+
+// readyConfiguration returns the gateway configuration reported by the daemon on the node, nil if not ready.
+func (gw *gateway) readyConfiguration(nodeName string) *current.GatewayConfiguration {
+	gwStatus, ok := gw.gatewayStatus[nodeName]
+	if !ok {
+		return nil
+	}
+	for i, config := range gwStatus.Spec.ReadyGatewayConfigurations {
+		if strings.EqualFold(config.StaticGatewayConfiguration, gw.key()) {
+			return &gwStatus.Spec.ReadyGatewayConfigurations[i]
+		}
+	}
+	return nil
+}
+
+// hasPeer returns whether the daemon on the node reports the public key as a peer of the gateway.
+func (gw *gateway) hasPeer(nodeName, interfaceName, publicKey string) bool {
+	gwStatus, ok := gw.gatewayStatus[nodeName]
+	if !ok {
+		return false
+	}
+	for _, peer := range gwStatus.Spec.ReadyPeerConfigurations {
+		if peer.InterfaceName == interfaceName && peer.PublicKey == publicKey {
+			return true
+		}
+	}
+	return false
+}
+
+// GetGatewayReport returns the joined view of the StaticGatewayConfiguration of key.
+func GetGatewayReport(ctx context.Context, c client.Reader, key types.NamespacedName) (*GatewayReport, error) {
+	gw, err := getGateway(ctx, c, key)
+	if err != nil {
+		return nil, err
+	}
+	report := &GatewayReport{
+		Name:           gw.sgc.Name,
+		Namespace:      gw.sgc.Namespace,
+		NodepoolName:   gw.sgc.Spec.GatewayNodepoolName,
+		VmssName:       gw.sgc.Spec.VmssName,
+		EgressIpPrefix: gw.sgc.Status.EgressIpPrefix,
+		FrontendIP:     gw.sgc.Status.Ip,
+		Port:           gw.sgc.Status.Port,
+		PublicKey:      gw.sgc.Status.PublicKey,
+		SecretFound:    gw.secretFound,
+		LBConfigFound:  gw.lbConfig != nil,
+		VMConfigFound:  gw.vmConfig != nil,
+		PodEndpoints:   len(gw.podEndpoints),
+	}
+	for _, profile := range gw.vmProfiles() {
+		node := GatewayNodeReport{
+			Name:        profile.NodeName,
+			PrimaryIP:   profile.PrimaryIP,
+			SecondaryIP: snatIPs(profile),
+		}
+		if config := gw.readyConfiguration(profile.NodeName); config != nil {
+			node.Ready = true
+			node.InterfaceName = config.InterfaceName
+			node.LastError = config.LastError
+			if config.Drain != nil {
+				node.DrainPhase = string(config.Drain.Phase)
+			}
+			for _, peer := range gw.gatewayStatus[profile.NodeName].Spec.ReadyPeerConfigurations {
+				if peer.InterfaceName == config.InterfaceName {
+					node.Peers++
+				}
+			}
+		}
+		report.Nodes = append(report.Nodes, node)
+	}
+	return report, nil
+}
+
+// GetPodReport returns the joined view of the pod of key and the gateway it uses.
+func GetPodReport(ctx context.Context, c client.Reader, key types.NamespacedName) (*PodReport, error) {
+	pod := &corev1.Pod{}
+	if err := c.Get(ctx, key, pod); err != nil {
+		return nil, err
+	}
+	report := &PodReport{
+		Name:      pod.Name,
+		Namespace: pod.Namespace,
+		NodeName:  pod.Spec.NodeName,
+		Gateway:   pod.GetAnnotations()[consts.CNIGatewayAnnotationKey],
+	}
+	if report.Gateway == "" {
+		return report, nil
+	}
+
+	gw, err := getGateway(ctx, c, types.NamespacedName{Namespace: pod.Namespace, Name: report.Gateway})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return report, nil
+		}
+		return nil, err
+	}
+	report.GatewayFound = true
+	report.EgressIpPrefix = gw.sgc.Status.EgressIpPrefix
+	podEndpoint := findPodEndpoint(gw, pod)
+	if podEndpoint != nil {
+		report.PodEndpointFound = true
+		report.PodIpAddress = podEndpoint.Spec.PodIpAddress
+		report.PodPublicKey = podEndpoint.Spec.PodPublicKey
+	}
+	for _, profile := range gw.vmProfiles() {
+		node := PodGatewayNodeReport{Name: profile.NodeName}
+		if report.EgressIpPrefix == "" {
+			node.EgressIP = snatIPs(profile)
+		}
+		if config := gw.readyConfiguration(profile.NodeName); config != nil {
+			node.Ready = true
+			node.PeerPresent = podEndpoint != nil && gw.hasPeer(profile.NodeName, config.InterfaceName, podEndpoint.Spec.PodPublicKey)
+		}
+		report.GatewayNodes = append(report.GatewayNodes, node)
+	}
+	return report, nil
+}
+
+func findPodEndpoint(gw *gateway, pod *corev1.Pod) *current.PodEndpoint {
+	for i, podEndpoint := range gw.podEndpoints {
+		if podEndpoint.Name == pod.Name {
+			return &gw.podEndpoints[i]
+		}
+	}
+	return nil
+}
+
+// Diagnose walks the chain from the pod of key to its gateway nodes and returns the first broken link.
+func Diagnose(ctx context.Context, c client.Reader, key types.NamespacedName) (*Diagnosis, error) {
+	broken := func(check, format string, args ...interface{}) (*Diagnosis, error) {
+		return &Diagnosis{Check: check, Message: fmt.Sprintf(format, args...)}, nil
+	}
+
+	pod := &corev1.Pod{}
+	if found, err := getOptional(ctx, c, key, pod); err != nil {
+		return nil, err
+	} else if !found {
+		return broken("Pod", "pod %s is not found", key)
+	}
+	gwName := pod.GetAnnotations()[consts.CNIGatewayAnnotationKey]
+	if gwName == "" {
+		return broken("PodAnnotation", "pod %s does not have annotation %s, its traffic does not go through any egress gateway", key, consts.CNIGatewayAnnotationKey)
+	}
+
+	gwKey := types.NamespacedName{Namespace: pod.Namespace, Name: gwName}
+	gw, err := getGateway(ctx, c, gwKey)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return broken("StaticGatewayConfiguration", "StaticGatewayConfiguration %s in pod annotation is not found, it must be in the pod namespace", gwKey)
+		}
+		return nil, err
+	}
+	if gw.sgc.Status.PublicKey == "" || gw.sgc.Status.PrivateKeySecretRef == nil {
+		return broken("WireguardKey", "StaticGatewayConfiguration %s has no wireguard key in status yet, check its events and kube-egress-gateway-controller-manager logs", gwKey)
+	}
+	if !gw.secretFound {
+		ref := gw.sgc.Status.PrivateKeySecretRef
+		return broken("WireguardKey", "secret %s/%s of the gateway private key is not found", ref.Namespace, ref.Name)
+	}
+	if gw.lbConfig == nil {
+		return broken("GatewayLBConfiguration", "GatewayLBConfiguration %s is not found, check StaticGatewayConfiguration events", gwKey)
+	}
+	if gw.lbConfig.Status == nil || gw.lbConfig.Status.FrontendIp == "" || gw.lbConfig.Status.ServerPort == 0 {
+		return broken("GatewayLBConfiguration", "GatewayLBConfiguration %s has no load balancer frontend or port yet, check its events and kube-egress-gateway-controller-manager logs", gwKey)
+	}
+	if gw.sgc.Status.Ip == "" || gw.sgc.Status.Port == 0 {
+		return broken("GatewayServerProfile", "StaticGatewayConfiguration %s has no frontend ip or port in status yet", gwKey)
+	}
+	if gw.vmConfig == nil {
+		return broken("GatewayVMConfiguration", "GatewayVMConfiguration %s is not found, check GatewayLBConfiguration events", gwKey)
+	}
+	if gw.sgc.Spec.ProvisionPublicIps && gw.sgc.Status.EgressIpPrefix == "" {
+		return broken("EgressIpPrefix", "StaticGatewayConfiguration %s has no egress ip prefix in status yet, check GatewayVMConfiguration events", gwKey)
+	}
+	profiles := gw.vmProfiles()
+	if len(profiles) == 0 {
+		return broken("GatewayNodes", "GatewayVMConfiguration %s has no gateway node, check that the gateway nodepool or vmss has running instances", gwKey)
+	}
+	var readyNodes []string
+	for _, profile := range profiles {
+		if gw.readyConfiguration(profile.NodeName) != nil {
+			readyNodes = append(readyNodes, profile.NodeName)
+		}
+	}
+	if len(readyNodes) == 0 {
+		return broken("GatewayStatus", "no gateway node reports StaticGatewayConfiguration %s as ready in its GatewayStatus, check kube-egress-gateway-daemon-manager logs on gateway nodes %s", gwKey, profileNodeNames(profiles))
+	}
+
+	if pod.Spec.NodeName == "" {
+		return broken("PodEndpoint", "pod %s is not scheduled yet", key)
+	}
+	podEndpoint := findPodEndpoint(gw, pod)
+	if podEndpoint == nil {
+		return broken("PodEndpoint", "PodEndpoint %s of the gateway is not found, the cni plugin has not set up the pod, check kubelet and kube-egress-gateway-cni-manager logs on node %s", key, pod.Spec.NodeName)
+	}
+	if podEndpoint.Spec.PodUid != "" && podEndpoint.Spec.PodUid != string(pod.UID) {
+		return broken("PodEndpoint", "PodEndpoint %s belongs to a previous pod of the same name", key)
+	}
+	if podEndpoint.Spec.NodeName != "" && podEndpoint.Spec.NodeName != pod.Spec.NodeName {
+		return broken("PodEndpoint", "PodEndpoint %s is on node %s while the pod is on node %s", key, podEndpoint.Spec.NodeName, pod.Spec.NodeName)
+	}
+	if podEndpoint.Spec.PodPublicKey == "" || podEndpoint.Spec.PodIpAddress == "" {
+		return broken("PodEndpoint", "PodEndpoint %s has no public key or ip address", key)
+	}
+
+	var missing []string
+	for _, nodeName := range readyNodes {
+		config := gw.readyConfiguration(nodeName)
+		if !gw.hasPeer(nodeName, config.InterfaceName, podEndpoint.Spec.PodPublicKey) {
+			missing = append(missing, nodeName)
+		}
+	}
+	if len(missing) == len(readyNodes) {
+		return broken("GatewayPeer", "no ready gateway node reports the pod as a wireguard peer in its GatewayStatus, check kube-egress-gateway-daemon-manager logs on gateway nodes %s", strings.Join(readyNodes, ", "))
+	}
+	if len(missing) > 0 {
+		return broken("GatewayPeer", "gateway nodes %s do not report the pod as a wireguard peer, connections balanced to them fail", strings.Join(missing, ", "))
+	}
+	return &Diagnosis{
+		Healthy: true,
+		Check:   "GatewayPeer",
+		Message: fmt.Sprintf("pod %s is a wireguard peer of StaticGatewayConfiguration %s on all ready gateway nodes %s", key, gwKey, strings.Join(readyNodes, ", ")),
+	}, nil
+}
+
+func profileNodeNames(profiles []current.GatewayVMProfile) string {
+	var names []string
+	for _, profile := range profiles {
+		names = append(names, profile.NodeName)
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,248 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+package inspect
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	current "github.com/Azure/kube-egress-gateway/api/v1alpha1"
+	"github.com/Azure/kube-egress-gateway/pkg/consts"
+)
+
+const (
+	testNamespace       = "testns"
+	testGateway         = "testgw"
+	testPod             = "testpod"
+	testPodUID          = "pod-uid"
+	testPodKey          = "pod-public-key"
+	testDaemonNamespace = "kube-egress-gateway-system"
+)
+
+type testObjects struct {
+	pod          *corev1.Pod
+	sgc          *current.StaticGatewayConfiguration
+	secret       *corev1.Secret
+	lbConfig     *current.GatewayLBConfiguration
+	vmConfig     *current.GatewayVMConfiguration
+	podEndpoint  *current.PodEndpoint
+	gwStatusList []*current.GatewayStatus
+}
+
+// newTestObjects returns the objects of a pod using a healthy gateway of two gateway nodes.
+func newTestObjects() *testObjects {
+	meta := metav1.ObjectMeta{Name: testGateway, Namespace: testNamespace}
+	gwStatus := func(node string) *current.GatewayStatus {
+		return &current.GatewayStatus{
+			ObjectMeta: metav1.ObjectMeta{Name: node, Namespace: testDaemonNamespace},
+			Spec: current.GatewayStatusSpec{
+				ReadyGatewayConfigurations: []current.GatewayConfiguration{{StaticGatewayConfiguration: testNamespace + "/" + testGateway, InterfaceName: "wg-6000"}},
+				ReadyPeerConfigurations:    []current.PeerConfiguration{{PodEndpoint: testNamespace + "/" + testPod, InterfaceName: "wg-6000", PublicKey: testPodKey}},
+			},
+		}
+	}
+	return &testObjects{
+		pod: &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: testPod, Namespace: testNamespace, UID: testPodUID, Annotations: map[string]string{consts.CNIGatewayAnnotationKey: testGateway}},
+			Spec:       corev1.PodSpec{NodeName: "worker1"},
+		},
+		sgc: &current.StaticGatewayConfiguration{
+			ObjectMeta: meta,
+			Spec:       current.StaticGatewayConfigurationSpec{GatewayNodepoolName: "gwpool", ProvisionPublicIps: true},
+			Status: current.StaticGatewayConfigurationStatus{
+				EgressIpPrefix: "1.2.3.4/31",
+				GatewayServerProfile: current.GatewayServerProfile{
+					Ip:                  "10.243.0.6",
+					Port:                6000,
+					PublicKey:           "gateway-public-key",
+					PrivateKeySecretRef: &corev1.ObjectReference{Name: "sgw-uid", Namespace: testDaemonNamespace},
+				},
+			},
+		},
+		secret: &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "sgw-uid", Namespace: testDaemonNamespace}},
+		lbConfig: &current.GatewayLBConfiguration{
+			ObjectMeta: meta,
+			Status:     &current.GatewayLBConfigurationStatus{FrontendIp: "10.243.0.6", ServerPort: 6000, EgressIpPrefix: "1.2.3.4/31"},
+		},
+		vmConfig: &current.GatewayVMConfiguration{
+			ObjectMeta: meta,
+			Status: &current.GatewayVMConfigurationStatus{
+				EgressIpPrefix: "1.2.3.4/31",
+				GatewayVMProfiles: []current.GatewayVMProfile{
+					{NodeName: "gw2", PrimaryIP: "10.243.0.5", SecondaryIP: "10.243.0.8", SecondaryIPs: []string{"10.243.0.8", "10.243.0.9"}},
+					{NodeName: "gw1", PrimaryIP: "10.243.0.4", SecondaryIP: "10.243.0.7"},
+				},
+			},
+		},
+		podEndpoint: &current.PodEndpoint{
+			ObjectMeta: metav1.ObjectMeta{Name: testPod, Namespace: testNamespace},
+			Spec: current.PodEndpointSpec{
+				StaticGatewayConfiguration: testGateway,
+				PodIpAddress:               "10.244.0.5/32",
+				PodPublicKey:               testPodKey,
+				NodeName:                   "worker1",
+				PodUid:                     testPodUID,
+			},
+		},
+		gwStatusList: []*current.GatewayStatus{gwStatus("gw1"), gwStatus("gw2")},
+	}
+}
+
+func (o *testObjects) client() client.Client {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = current.AddToScheme(scheme)
+	var objects []client.Object
+	for _, obj := range []client.Object{o.pod, o.sgc, o.secret, o.lbConfig, o.vmConfig, o.podEndpoint} {
+		if !reflect.ValueOf(obj).IsNil() {
+			objects = append(objects, obj)
+		}
+	}
+	for _, gwStatus := range o.gwStatusList {
+		objects = append(objects, gwStatus)
+	}
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+}
+
+func TestGetGatewayReport(t *testing.T) {
+	objects := newTestObjects()
+	objects.gwStatusList[1].Spec.ReadyGatewayConfigurations[0].Drain = &current.GatewayDrainStatus{Phase: current.GatewayDrainPhase("Draining")}
+	objects.gwStatusList[1].Spec.ReadyPeerConfigurations = append(objects.gwStatusList[1].Spec.ReadyPeerConfigurations, current.PeerConfiguration{InterfaceName: "wg-6001", PublicKey: "other"})
+	objects.gwStatusList[0].Spec.ReadyGatewayConfigurations[0].LastError = "failed to add peer to wireguard device"
+	report, err := GetGatewayReport(context.Background(), objects.client(), types.NamespacedName{Namespace: testNamespace, Name: testGateway})
+	if err != nil {
+		t.Fatalf("GetGatewayReport returns unexpected error: %v", err)
+	}
+	expected := &GatewayReport{
+		Name:           testGateway,
+		Namespace:      testNamespace,
+		NodepoolName:   "gwpool",
+		EgressIpPrefix: "1.2.3.4/31",
+		FrontendIP:     "10.243.0.6",
+		Port:           6000,
+		PublicKey:      "gateway-public-key",
+		SecretFound:    true,
+		LBConfigFound:  true,
+		VMConfigFound:  true,
+		PodEndpoints:   1,
+		Nodes: []GatewayNodeReport{
+			{Name: "gw1", PrimaryIP: "10.243.0.4", SecondaryIP: "10.243.0.7", Ready: true, InterfaceName: "wg-6000", Peers: 1, LastError: "failed to add peer to wireguard device"},
+			{Name: "gw2", PrimaryIP: "10.243.0.5", SecondaryIP: "10.243.0.8,10.243.0.9", Ready: true, InterfaceName: "wg-6000", DrainPhase: "Draining", Peers: 1},
+		},
+	}
+	if !reflect.DeepEqual(report, expected) {
+		t.Fatalf("GetGatewayReport returns unexpected report: got: %+v, expected: %+v", report, expected)
+	}
+}
+
+func TestGetPodReport(t *testing.T) {
+	objects := newTestObjects()
+	objects.sgc.Spec.ProvisionPublicIps = false
+	objects.sgc.Status.EgressIpPrefix = ""
+	objects.gwStatusList[1].Spec.ReadyPeerConfigurations = nil
+	report, err := GetPodReport(context.Background(), objects.client(), types.NamespacedName{Namespace: testNamespace, Name: testPod})
+	if err != nil {
+		t.Fatalf("GetPodReport returns unexpected error: %v", err)
+	}
+	expected := &PodReport{
+		Name:             testPod,
+		Namespace:        testNamespace,
+		NodeName:         "worker1",
+		Gateway:          testGateway,
+		GatewayFound:     true,
+		PodEndpointFound: true,
+		PodIpAddress:     "10.244.0.5/32",
+		PodPublicKey:     testPodKey,
+		GatewayNodes: []PodGatewayNodeReport{
+			{Name: "gw1", Ready: true, PeerPresent: true, EgressIP: "10.243.0.7"},
+			{Name: "gw2", Ready: true, PeerPresent: false, EgressIP: "10.243.0.8,10.243.0.9"},
+		},
+	}
+	if !reflect.DeepEqual(report, expected) {
+		t.Fatalf("GetPodReport returns unexpected report: got: %+v, expected: %+v", report, expected)
+	}
+}
+
+func TestDiagnose(t *testing.T) {
+	tests := map[string]struct {
+		breakFunc     func(*testObjects)
+		expectedCheck string
+		expectHealthy bool
+	}{
+		"Diagnose should report healthy pod": {
+			breakFunc:     func(*testObjects) {},
+			expectedCheck: "GatewayPeer",
+			expectHealthy: true,
+		},
+		"Diagnose should report missing pod": {
+			breakFunc:     func(o *testObjects) { o.pod = nil },
+			expectedCheck: "Pod",
+		},
+		"Diagnose should report pod without annotation": {
+			breakFunc:     func(o *testObjects) { o.pod.Annotations = nil },
+			expectedCheck: "PodAnnotation",
+		},
+		"Diagnose should report missing StaticGatewayConfiguration": {
+			breakFunc:     func(o *testObjects) { o.sgc = nil },
+			expectedCheck: "StaticGatewayConfiguration",
+		},
+		"Diagnose should report missing wireguard key": {
+			breakFunc:     func(o *testObjects) { o.secret = nil },
+			expectedCheck: "WireguardKey",
+		},
+		"Diagnose should report GatewayLBConfiguration without frontend": {
+			breakFunc:     func(o *testObjects) { o.lbConfig.Status = nil },
+			expectedCheck: "GatewayLBConfiguration",
+		},
+		"Diagnose should report missing GatewayVMConfiguration": {
+			breakFunc:     func(o *testObjects) { o.vmConfig = nil },
+			expectedCheck: "GatewayVMConfiguration",
+		},
+		"Diagnose should report missing egress ip prefix": {
+			breakFunc:     func(o *testObjects) { o.sgc.Status.EgressIpPrefix = "" },
+			expectedCheck: "EgressIpPrefix",
+		},
+		"Diagnose should report gateway without gateway nodes": {
+			breakFunc:     func(o *testObjects) { o.vmConfig.Status.GatewayVMProfiles = nil },
+			expectedCheck: "GatewayNodes",
+		},
+		"Diagnose should report gateway not ready on any gateway node": {
+			breakFunc:     func(o *testObjects) { o.gwStatusList = nil },
+			expectedCheck: "GatewayStatus",
+		},
+		"Diagnose should report missing PodEndpoint": {
+			breakFunc:     func(o *testObjects) { o.podEndpoint = nil },
+			expectedCheck: "PodEndpoint",
+		},
+		"Diagnose should report PodEndpoint of previous pod": {
+			breakFunc:     func(o *testObjects) { o.podEndpoint.Spec.PodUid = "old-uid" },
+			expectedCheck: "PodEndpoint",
+		},
+		"Diagnose should report pod peer missing on some gateway nodes": {
+			breakFunc:     func(o *testObjects) { o.gwStatusList[0].Spec.ReadyPeerConfigurations = nil },
+			expectedCheck: "GatewayPeer",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			objects := newTestObjects()
+			test.breakFunc(objects)
+			diagnosis, err := Diagnose(context.Background(), objects.client(), types.NamespacedName{Namespace: testNamespace, Name: testPod})
+			if err != nil {
+				t.Fatalf("Diagnose returns unexpected error: %v", err)
+			}
+			if diagnosis.Check != test.expectedCheck || diagnosis.Healthy != test.expectHealthy {
+				t.Fatalf("Diagnose returns unexpected diagnosis: got: %+v, expected check: %s, healthy: %v", diagnosis, test.expectedCheck, test.expectHealthy)
+			}
+		})
+	}
+}
@@ -50,6 +50,48 @@ var (
 		},
 		[]string{"node"},
 	)
+
+	CNIManagerRequestLatency = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "cnimanager_request_duration_seconds",
+			Help:    "Latency of CNI requests served by CNI manager",
+			Buckets: []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}, // seconds
+		},
+		[]string{"method", "code"},
+	)
+
+	CNIManagerCacheMissCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cnimanager_cache_miss_total",
+			Help: "Number of CNI manager reads not found in the informer cache and sent to the API server",
+		},
+		[]string{"resource"},
+	)
+
+	CNIManagerMasterConfInfo = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cnimanager_cni_master_conf_info",
+			Help: "The cni conf file or Multus network attachment the egress gateway cni plugin is installed in, set to 1",
+		},
+		[]string{"master"},
+	)
+
+	// Gateway daemon metrics
+	GatewayDaemonDriftRepairedCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "gateway_daemon_drift_repaired_total",
+			Help: "Number of out-of-band changes to the gateway node network repaired by the daemon",
+		},
+		[]string{"resource", "source"},
+	)
+
+	GatewayDaemonSNATPortUtilization = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "gateway_daemon_snat_port_utilization_ratio",
+			Help: "Ratio of source ports of a gateway sNAT IP in use towards its busiest destination, from conntrack",
+		},
+		[]string{"gateway", "snat_ip"},
+	)
 )
 
 type MetricsContext struct {
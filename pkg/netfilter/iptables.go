@@ -0,0 +1,454 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+package netfilter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	utiliptables "k8s.io/kubernetes/pkg/util/iptables"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/Azure/kube-egress-gateway/pkg/consts"
+)
+
+const (
+	noSNATChain       utiliptables.Chain = "EGRESS-GATEWAY-SNAT"
+	noSNATJumpComment string             = "kube-egress-gateway no MASQUERADE"
+	// canary chain used to detect iptables flushes
+	canaryChain utiliptables.Chain = "EGRESS-GATEWAY-CANARY"
+)
+
+type ipTables struct {
+	ipt utiliptables.Interface
+}
+
+var _ Interface = &ipTables{}
+
+// NewIPTables returns an Interface programming rules through iptables.
+func NewIPTables(ipt utiliptables.Interface) Interface {
+	return &ipTables{ipt: ipt}
+}
+
+func (*ipTables) Backend() Backend {
+	return BackendIPTables
+}
+
+func (i *ipTables) EnsureNoSNAT(ctx context.Context, ip string) error {
+	// avoid masquerading packets from gateway namespace, as they're already sNATed
+	if err := i.ensureChain(
+		ctx,
+		utiliptables.TableNAT,
+		noSNATChain,                   // target chain
+		utiliptables.ChainPostrouting, // source chain
+		noSNATJumpComment,
+		nil); err != nil {
+		return err
+	}
+
+	return i.ensureChain(
+		ctx,
+		utiliptables.TableNAT,
+		noSNATIPChain(ip), // target chain
+		noSNATChain,       // source chain
+		noSNATIPComment(ip),
+		[][]string{
+			{"-s", ip + "/32", "-j", "ACCEPT"},
+		})
+}
+
+func (i *ipTables) DeleteNoSNAT(ctx context.Context, ip string) error {
+	return i.removeChains(
+		ctx,
+		utiliptables.TableNAT,
+		[]utiliptables.Chain{noSNATIPChain(ip)}, // target chain
+		[]utiliptables.Chain{noSNATChain},       // source chain
+		[]string{noSNATIPComment(ip)},
+	)
+}
+
+func (i *ipTables) CleanupNoSNAT(ctx context.Context) error {
+	return i.removeChains(
+		ctx,
+		utiliptables.TableNAT,
+		[]utiliptables.Chain{noSNATChain},
+		[]utiliptables.Chain{utiliptables.ChainPostrouting},
+		[]string{noSNATJumpComment},
+	)
+}
+
+func (i *ipTables) EnsureGatewaySNAT(ctx context.Context, linkName string, mark int, snatIPs []string, dedicatedSNATIPs map[string]string, snatPortBlocks map[string]SNATPortBlock) error {
+	if err := i.ensureChain(
+		ctx,
+		utiliptables.TableNAT,
+		gatewayMarkChain(mark),       // target chain
+		utiliptables.ChainPrerouting, // source chain
+		fmt.Sprintf("kube-egress-gateway mark packets from gateway link %s", linkName),
+		[][]string{
+			{"-i", linkName, "-j", "CONNMARK", "--set-mark", strconv.Itoa(mark)},
+		}); err != nil {
+		return err
+	}
+
+	sharedPortRange, err := sharedSNATPortRange(snatPortBlocks)
+	if err != nil {
+		return err
+	}
+	rules := gatewayDedicatedSNATRules(mark, dedicatedSNATIPs)
+	rules = append(rules, gatewayPortBlockSNATRules(mark, snatPortBlocks)...)
+	return i.ensureChain(
+		ctx,
+		utiliptables.TableNAT,
+		gatewaySNATChain(mark),        // target chain
+		utiliptables.ChainPostrouting, // source chain
+		fmt.Sprintf("kube-egress-gateway sNAT packets from gateway link %s", linkName),
+		append(rules, gatewaySNATRules(mark, snatIPs, sharedPortRange)...))
+}
+
+// gatewayDedicatedSNATRules sNATs connections from each source IP to its dedicated sNAT IP, they go ahead of
+// the rules of the shared sNAT IPs.
+func gatewayDedicatedSNATRules(mark int, dedicatedSNATIPs map[string]string) [][]string {
+	rules := make([][]string, 0, len(dedicatedSNATIPs))
+	for _, sourceIP := range sortedKeys(dedicatedSNATIPs) {
+		rules = append(rules, []string{"-o", consts.HostLinkName, "-m", "connmark", "--mark", strconv.Itoa(mark),
+			"-s", sourceIP + "/32", "-j", "SNAT", "--to-source", dedicatedSNATIPs[sourceIP], "--random-fully"})
+	}
+	return rules
+}
+
+// gatewayPortBlockSNATRules sNATs connections from each source IP to a source port within its port block, they
+// go ahead of the rules of the shared sNAT IPs.
+func gatewayPortBlockSNATRules(mark int, snatPortBlocks map[string]SNATPortBlock) [][]string {
+	rules := make([][]string, 0, len(snatPortBlocks))
+	for _, sourceIP := range sortedKeys(snatPortBlocks) {
+		block := snatPortBlocks[sourceIP]
+		rules = append(rules, []string{"-o", consts.HostLinkName, "-m", "connmark", "--mark", strconv.Itoa(mark),
+			"-s", sourceIP + "/32", "-j", "SNAT", "--to-source", fmt.Sprintf("%s:%d-%d", block.IP, block.PortStart, block.PortEnd), "--random-fully"})
+	}
+	return rules
+}
+
+// gatewaySNATRules spreads new connections evenly across snatIPs: the rule of the i-th IP takes one in every
+// len(snatIPs)-i of the connections not taken by the rules before it, and the last rule takes all the rest.
+// Only the first packet of a connection traverses the nat table, the others follow the conntrack entry.
+// Source ports are limited to portRange when it is not empty.
+func gatewaySNATRules(mark int, snatIPs []string, portRange string) [][]string {
+	rules := make([][]string, 0, len(snatIPs))
+	for idx, snatIP := range snatIPs {
+		rule := []string{"-o", consts.HostLinkName, "-m", "connmark", "--mark", strconv.Itoa(mark)}
+		if remaining := len(snatIPs) - idx; remaining > 1 {
+			rule = append(rule, "-m", "statistic", "--mode", "nth", "--every", strconv.Itoa(remaining), "--packet", "0")
+		}
+		target := snatIP
+		if portRange != "" {
+			target = snatIP + ":" + portRange
+		}
+		rules = append(rules, append(rule, "-j", "SNAT", "--to-source", target, "--random-fully"))
+	}
+	return rules
+}
+
+func (i *ipTables) DeleteGatewaySNAT(ctx context.Context, linkName string, mark int) error {
+	return i.removeChains(
+		ctx,
+		utiliptables.TableNAT,
+		[]utiliptables.Chain{
+			gatewayMarkChain(mark),
+			gatewaySNATChain(mark),
+		}, // target chain
+		[]utiliptables.Chain{
+			utiliptables.ChainPrerouting,
+			utiliptables.ChainPostrouting,
+		}, // source chain
+		[]string{
+			fmt.Sprintf("kube-egress-gateway mark packets from gateway link %s", linkName),
+			fmt.Sprintf("kube-egress-gateway sNAT packets from gateway link %s", linkName),
+		},
+	)
+}
+
+func (i *ipTables) CheckGatewaySNAT(ctx context.Context, linkName string, mark int, snatIPs []string) (bool, error) {
+	iptablesData := bytes.NewBuffer(nil)
+	if err := i.ipt.SaveInto(utiliptables.TableNAT, iptablesData); err != nil {
+		return false, fmt.Errorf("failed to save iptables data for table %s: %w", utiliptables.TableNAT, err)
+	}
+
+	markChain, snatChain := string(gatewayMarkChain(mark)), string(gatewaySNATChain(mark))
+	var markJump, snatJump, markRule bool
+	var ruleIPs []string
+	for _, line := range strings.Split(iptablesData.String(), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[0] != "-A" {
+			continue
+		}
+		switch fields[1] {
+		case string(utiliptables.ChainPrerouting):
+			markJump = markJump || hasArg(fields, "-j", markChain)
+		case string(utiliptables.ChainPostrouting):
+			snatJump = snatJump || hasArg(fields, "-j", snatChain)
+		case markChain:
+			markRule = markRule || hasArg(fields, "-i", linkName)
+		case snatChain:
+			if _, dedicated := argValue(fields, "-s"); dedicated {
+				continue
+			}
+			if target, ok := argValue(fields, "--to-source"); ok {
+				ip, _, _ := strings.Cut(target, ":")
+				ruleIPs = append(ruleIPs, ip)
+			}
+		}
+	}
+	return markJump && snatJump && markRule && slices.Equal(ruleIPs, snatIPs), nil
+}
+
+func (i *ipTables) EnsureTCPMSSClamping(ctx context.Context, linkName string, mark int) error {
+	return i.ensureChain(
+		ctx,
+		utiliptables.TableMangle,
+		tcpMSSClampingChain(mark), // target chain
+		utiliptables.ChainForward, // source chain
+		fmt.Sprintf("kube-egress-gateway clamp TCP MSS of gateway link %s", linkName),
+		[][]string{
+			{"-i", linkName, "-p", "tcp", "-m", "tcp", "--tcp-flags", "SYN,RST", "SYN", "-j", "TCPMSS", "--clamp-mss-to-pmtu"},
+			{"-o", linkName, "-p", "tcp", "-m", "tcp", "--tcp-flags", "SYN,RST", "SYN", "-j", "TCPMSS", "--clamp-mss-to-pmtu"},
+		})
+}
+
+func (i *ipTables) DeleteTCPMSSClamping(ctx context.Context, linkName string, mark int) error {
+	return i.removeChains(
+		ctx,
+		utiliptables.TableMangle,
+		[]utiliptables.Chain{tcpMSSClampingChain(mark)}, // target chain
+		[]utiliptables.Chain{utiliptables.ChainForward}, // source chain
+		[]string{fmt.Sprintf("kube-egress-gateway clamp TCP MSS of gateway link %s", linkName)},
+	)
+}
+
+func (i *ipTables) EnsureIngressConnMark(ctx context.Context, ifName string, mark int) error {
+	if _, err := i.ipt.EnsureRule(utiliptables.Append, utiliptables.TableMangle, utiliptables.ChainPrerouting, "-i", ifName, "-j", "MARK", "--set-mark", strconv.Itoa(mark)); err != nil {
+		return fmt.Errorf("failed to append iptables set-mark rule: %w", err)
+	}
+	if _, err := i.ipt.EnsureRule(utiliptables.Append, utiliptables.TableMangle, utiliptables.ChainPrerouting, "-j", "CONNMARK", "--save-mark"); err != nil {
+		return fmt.Errorf("failed to append iptables save-mark rule: %w", err)
+	}
+	if _, err := i.ipt.EnsureRule(utiliptables.Append, utiliptables.TableMangle, utiliptables.ChainOutput, "-m", "connmark", "--mark", strconv.Itoa(mark), "-j", "CONNMARK", "--restore-mark"); err != nil {
+		return fmt.Errorf("failed to append iptables restore-mark rule: %w", err)
+	}
+	return nil
+}
+
+func (i *ipTables) CheckIngressConnMark(ctx context.Context, ifName string, mark int) (bool, error) {
+	iptablesData := bytes.NewBuffer(nil)
+	if err := i.ipt.SaveInto(utiliptables.TableMangle, iptablesData); err != nil {
+		return false, fmt.Errorf("failed to save iptables data for table %s: %w", utiliptables.TableMangle, err)
+	}
+
+	var setMark, saveMark, restoreMark bool
+	for _, line := range strings.Split(iptablesData.String(), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[0] != "-A" {
+			continue
+		}
+		switch fields[1] {
+		case string(utiliptables.ChainPrerouting):
+			setMark = setMark || (hasArg(fields, "-i", ifName) && hasArg(fields, "-j", "MARK") && hasMarkArg(fields, mark, "--set-mark", "--set-xmark"))
+			saveMark = saveMark || (hasArg(fields, "-j", "CONNMARK") && slices.Contains(fields, "--save-mark"))
+		case string(utiliptables.ChainOutput):
+			restoreMark = restoreMark || (hasArg(fields, "-j", "CONNMARK") && hasMarkArg(fields, mark, "--mark") && slices.Contains(fields, "--restore-mark"))
+		}
+	}
+	return setMark && saveMark && restoreMark, nil
+}
+
+func (i *ipTables) Monitor(ctx context.Context, interval time.Duration, onChange func()) {
+	// same approach as kube-proxy: a flush of the nat or mangle table removes the canary chains
+	i.ipt.Monitor(canaryChain, []utiliptables.Table{utiliptables.TableNAT, utiliptables.TableMangle}, onChange, interval, ctx.Done())
+}
+
+// Dump returns the iptables-save lines of the nat and mangle tables that belong to kube-egress-gateway:
+// its chains, the jump rules to them and the connection mark rules in built-in chains.
+func (i *ipTables) Dump(ctx context.Context) (string, error) {
+	dump := bytes.NewBuffer(nil)
+	for _, table := range []utiliptables.Table{utiliptables.TableNAT, utiliptables.TableMangle} {
+		iptablesData := bytes.NewBuffer(nil)
+		if err := i.ipt.SaveInto(table, iptablesData); err != nil {
+			return "", fmt.Errorf("failed to save iptables data for table %s: %w", table, err)
+		}
+		writeLine(dump, "*"+string(table))
+		for _, line := range strings.Split(iptablesData.String(), "\n") {
+			if isOwnedIPTablesLine(line) {
+				writeLine(dump, line)
+			}
+		}
+	}
+	return dump.String(), nil
+}
+
+func (i *ipTables) ensureChain(
+	ctx context.Context,
+	table utiliptables.Table,
+	targetChain utiliptables.Chain,
+	sourceChain utiliptables.Chain,
+	jumpRuleComment string,
+	chainRules [][]string,
+) error {
+	log := log.FromContext(ctx)
+
+	// ensure target chain exists
+	log.Info("Ensuring iptables chain", "table", table, "target chain", targetChain)
+	if _, err := i.ipt.EnsureChain(table, targetChain); err != nil {
+		return fmt.Errorf("failed to ensure chain %s in table %s: %w", targetChain, table, err)
+	}
+
+	// ensure jump rule exists, we use EnsureRule because we do not want to flush all rules in the source chain
+	log.Info("Ensuring jump rule", "source chain", sourceChain)
+	if _, err := i.ipt.EnsureRule(utiliptables.Prepend, table, sourceChain, "-m", "comment", "--comment", jumpRuleComment, "-j", string(targetChain)); err != nil {
+		return fmt.Errorf("failed to ensure jump rule from chain %s to chain %s in table %s: %w", sourceChain, targetChain, table, err)
+	}
+
+	if len(chainRules) == 0 {
+		return nil
+	}
+
+	// ensure all rules in the target chain atomically
+	lines := bytes.NewBuffer(nil)
+	writeLine(lines, "*"+string(table))
+	writeLine(lines, utiliptables.MakeChainLine(targetChain))
+	for _, rule := range chainRules {
+		writeRule(lines, string(utiliptables.Append), targetChain, rule...)
+	}
+	writeLine(lines, "COMMIT")
+	log.Info("Restoring rules", "rules", lines.String())
+	if err := i.ipt.RestoreAll(lines.Bytes(), utiliptables.NoFlushTables, utiliptables.NoRestoreCounters); err != nil {
+		return fmt.Errorf("failed to restore rules in chain %s in table %s: %w", targetChain, table, err)
+	}
+	return nil
+}
+
+func (i *ipTables) removeChains(
+	ctx context.Context,
+	table utiliptables.Table,
+	targetChains []utiliptables.Chain,
+	sourceChains []utiliptables.Chain,
+	jumpRuleComments []string,
+) error {
+	log := log.FromContext(ctx)
+
+	iptablesData := bytes.NewBuffer(nil)
+	if err := i.ipt.SaveInto(table, iptablesData); err != nil {
+		return fmt.Errorf("failed to save iptables data for table %s: %w", table, err)
+	}
+
+	existingChains := utiliptables.GetChainsFromTable(iptablesData.Bytes())
+	for idx, targetChain := range targetChains {
+		sourceChain := sourceChains[idx]
+		jumpRuleComment := jumpRuleComments[idx]
+		if _, ok := existingChains[targetChain]; ok {
+			// delete jump rule first
+			log.Info("Deleting jump rule", "source chain", sourceChain, "target chain", targetChain)
+			if err := i.ipt.DeleteRule(table, sourceChain, "-m", "comment", "--comment", jumpRuleComment, "-j", string(targetChain)); err != nil {
+				return fmt.Errorf("failed to delete jump rule from chain %s to chain %s in table %s: %w", sourceChain, targetChain, table, err)
+			}
+
+			log.Info("Flushing and deleting chain", "table", table, "target chain", targetChain)
+			lines := bytes.NewBuffer(nil)
+			writeLine(lines, "*"+string(table))
+			writeLine(lines, utiliptables.MakeChainLine(targetChain))
+			writeLine(lines, "-X", string(targetChain))
+			writeLine(lines, "COMMIT")
+			if err := i.ipt.Restore(table, lines.Bytes(), utiliptables.NoFlushTables, utiliptables.NoRestoreCounters); err != nil {
+				return fmt.Errorf("failed to restore iptables table %s: %w", table, err)
+			}
+		}
+	}
+	return nil
+}
+
+func gatewayMarkChain(mark int) utiliptables.Chain {
+	return utiliptables.Chain(fmt.Sprintf("EGRESS-GATEWAY-MARK-%d", mark))
+}
+
+func gatewaySNATChain(mark int) utiliptables.Chain {
+	return utiliptables.Chain(fmt.Sprintf("EGRESS-GATEWAY-SNAT-%d", mark))
+}
+
+func tcpMSSClampingChain(mark int) utiliptables.Chain {
+	return utiliptables.Chain(fmt.Sprintf("EGRESS-GATEWAY-MSS-%d", mark))
+}
+
+// hasArg returns whether the iptables rule has the flag with the value.
+func hasArg(fields []string, flag, value string) bool {
+	for i := 0; i+1 < len(fields); i++ {
+		if fields[i] == flag && fields[i+1] == value {
+			return true
+		}
+	}
+	return false
+}
+
+// argValue returns the value of the flag in the iptables rule.
+func argValue(fields []string, flag string) (string, bool) {
+	for i := 0; i+1 < len(fields); i++ {
+		if fields[i] == flag {
+			return fields[i+1], true
+		}
+	}
+	return "", false
+}
+
+// hasMarkArg returns whether the iptables rule has one of the flags with the mark as value.
+// iptables-save prints marks in hex with a mask, e.g. --set-xmark 0x2222/0xffffffff.
+func hasMarkArg(fields []string, mark int, flags ...string) bool {
+	for i := 0; i+1 < len(fields); i++ {
+		if !slices.Contains(flags, fields[i]) {
+			continue
+		}
+		value, _, _ := strings.Cut(fields[i+1], "/")
+		if parsed, err := strconv.ParseInt(value, 0, 64); err == nil && int(parsed) == mark {
+			return true
+		}
+	}
+	return false
+}
+
+// isOwnedIPTablesLine returns whether the iptables-save line declares, jumps to or is in a kube-egress-gateway chain,
+// or is a connection mark rule added by EnsureIngressConnMark.
+func isOwnedIPTablesLine(line string) bool {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return false
+	}
+	if strings.HasPrefix(fields[0], ":EGRESS-") {
+		return true
+	}
+	if fields[0] != "-A" {
+		return false
+	}
+	return strings.Contains(line, "EGRESS-") || strings.Contains(line, "kube-egress-gateway") ||
+		hasArg(fields, "-j", "MARK") || hasArg(fields, "-j", "CONNMARK")
+}
+
+func noSNATIPChain(ip string) utiliptables.Chain {
+	return utiliptables.Chain(fmt.Sprintf("EGRESS-%s", strings.ReplaceAll(ip, ".", "-")))
+}
+
+func noSNATIPComment(ip string) string {
+	return fmt.Sprintf("kube-egress-gateway no sNAT packet from ip %s", ip)
+}
+
+// Similar syntax to utiliptables.Interface.EnsureRule, except you don't pass a table
+// (you must write these rules under the line with the table name)
+func writeRule(lines *bytes.Buffer, position string, chain utiliptables.Chain, args ...string) {
+	fullArgs := append([]string{position, string(chain)}, args...)
+	writeLine(lines, fullArgs...)
+}
+
+// Join all words with spaces, terminate with newline and write to buf.
+func writeLine(lines *bytes.Buffer, words ...string) {
+	lines.WriteString(strings.Join(words, " ") + "\n")
+}
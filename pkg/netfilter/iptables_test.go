@@ -0,0 +1,208 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+package netfilter
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	utiliptables "k8s.io/kubernetes/pkg/util/iptables"
+
+	fakeiptables "github.com/Azure/kube-egress-gateway/pkg/iptableswrapper"
+)
+
+func TestIPTablesCheckGatewaySNAT(t *testing.T) {
+	ctx := context.Background()
+	fipt := fakeiptables.NewFake()
+	nf := NewIPTables(fipt)
+
+	ok, err := nf.CheckGatewaySNAT(ctx, "wg-6000", 6000, []string{"10.0.0.6"})
+	assert.Nil(t, err)
+	assert.False(t, ok, "rules should be missing before ensured")
+
+	assert.Nil(t, nf.EnsureGatewaySNAT(ctx, "wg-6000", 6000, []string{"10.0.0.6"}, nil, nil))
+	ok, err = nf.CheckGatewaySNAT(ctx, "wg-6000", 6000, []string{"10.0.0.6"})
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	ok, err = nf.CheckGatewaySNAT(ctx, "wg-6000", 6000, []string{"10.0.0.60"})
+	assert.Nil(t, err)
+	assert.False(t, ok, "sNAT IP should match exactly")
+
+	// chain flushed by someone else
+	assert.Nil(t, fipt.FlushChain(utiliptables.TableNAT, gatewaySNATChain(6000)))
+	ok, err = nf.CheckGatewaySNAT(ctx, "wg-6000", 6000, []string{"10.0.0.6"})
+	assert.Nil(t, err)
+	assert.False(t, ok)
+}
+
+func TestIPTablesGatewaySNATMultipleIPs(t *testing.T) {
+	ctx := context.Background()
+	fipt := fakeiptables.NewFake()
+	nf := NewIPTables(fipt)
+
+	snatIPs := []string{"10.0.0.6", "10.0.0.7", "10.0.0.8"}
+	assert.Nil(t, nf.EnsureGatewaySNAT(ctx, "wg-6000", 6000, snatIPs, nil, nil))
+	buf := bytes.NewBuffer(nil)
+	assert.Nil(t, fipt.SaveInto(utiliptables.TableNAT, buf))
+	assert.Contains(t, buf.String(), `-A EGRESS-GATEWAY-SNAT-6000 -o host0 -m connmark --mark 6000 -m statistic --mode nth --every 3 --packet 0 -j SNAT --to-source 10.0.0.6 --random-fully
+-A EGRESS-GATEWAY-SNAT-6000 -o host0 -m connmark --mark 6000 -m statistic --mode nth --every 2 --packet 0 -j SNAT --to-source 10.0.0.7 --random-fully
+-A EGRESS-GATEWAY-SNAT-6000 -o host0 -m connmark --mark 6000 -j SNAT --to-source 10.0.0.8 --random-fully
+`)
+
+	ok, err := nf.CheckGatewaySNAT(ctx, "wg-6000", 6000, snatIPs)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	ok, err = nf.CheckGatewaySNAT(ctx, "wg-6000", 6000, snatIPs[:2])
+	assert.Nil(t, err)
+	assert.False(t, ok, "all sNAT IPs should match")
+
+	// decrease sNAT IPs
+	assert.Nil(t, nf.EnsureGatewaySNAT(ctx, "wg-6000", 6000, snatIPs[:1], nil, nil))
+	ok, err = nf.CheckGatewaySNAT(ctx, "wg-6000", 6000, snatIPs[:1])
+	assert.Nil(t, err)
+	assert.True(t, ok)
+}
+
+func TestIPTablesGatewayDedicatedSNAT(t *testing.T) {
+	ctx := context.Background()
+	fipt := fakeiptables.NewFake()
+	nf := NewIPTables(fipt)
+
+	dedicatedSNATIPs := map[string]string{"10.244.0.5": "10.0.0.9", "10.244.0.4": "10.0.0.10"}
+	assert.Nil(t, nf.EnsureGatewaySNAT(ctx, "wg-6000", 6000, []string{"10.0.0.6"}, dedicatedSNATIPs, nil))
+	buf := bytes.NewBuffer(nil)
+	assert.Nil(t, fipt.SaveInto(utiliptables.TableNAT, buf))
+	assert.Contains(t, buf.String(), `-A EGRESS-GATEWAY-SNAT-6000 -o host0 -m connmark --mark 6000 -s 10.244.0.4/32 -j SNAT --to-source 10.0.0.10 --random-fully
+-A EGRESS-GATEWAY-SNAT-6000 -o host0 -m connmark --mark 6000 -s 10.244.0.5/32 -j SNAT --to-source 10.0.0.9 --random-fully
+-A EGRESS-GATEWAY-SNAT-6000 -o host0 -m connmark --mark 6000 -j SNAT --to-source 10.0.0.6 --random-fully
+`)
+
+	ok, err := nf.CheckGatewaySNAT(ctx, "wg-6000", 6000, []string{"10.0.0.6"})
+	assert.Nil(t, err)
+	assert.True(t, ok, "dedicated sNAT rules should not be checked")
+
+	// remove dedicated sNAT IPs
+	assert.Nil(t, nf.EnsureGatewaySNAT(ctx, "wg-6000", 6000, []string{"10.0.0.6"}, nil, nil))
+	buf.Reset()
+	assert.Nil(t, fipt.SaveInto(utiliptables.TableNAT, buf))
+	assert.NotContains(t, buf.String(), "10.244.0.")
+}
+
+func TestIPTablesGatewaySNATPortBlocks(t *testing.T) {
+	ctx := context.Background()
+	fipt := fakeiptables.NewFake()
+	nf := NewIPTables(fipt)
+
+	snatPortBlocks := map[string]SNATPortBlock{"10.244.0.4": {IP: "10.0.0.6", PortStart: 1024, PortEnd: 2047}}
+	assert.Nil(t, nf.EnsureGatewaySNAT(ctx, "wg-6000", 6000, []string{"10.0.0.6"}, map[string]string{"10.244.0.5": "10.0.0.9"}, snatPortBlocks))
+	buf := bytes.NewBuffer(nil)
+	assert.Nil(t, fipt.SaveInto(utiliptables.TableNAT, buf))
+	assert.Contains(t, buf.String(), `-A EGRESS-GATEWAY-SNAT-6000 -o host0 -m connmark --mark 6000 -s 10.244.0.5/32 -j SNAT --to-source 10.0.0.9 --random-fully
+-A EGRESS-GATEWAY-SNAT-6000 -o host0 -m connmark --mark 6000 -s 10.244.0.4/32 -j SNAT --to-source 10.0.0.6:1024-2047 --random-fully
+-A EGRESS-GATEWAY-SNAT-6000 -o host0 -m connmark --mark 6000 -j SNAT --to-source 10.0.0.6:64512-65535 --random-fully
+`, "shared sNAT rules should only use the last block slot")
+
+	ok, err := nf.CheckGatewaySNAT(ctx, "wg-6000", 6000, []string{"10.0.0.6"})
+	assert.Nil(t, err)
+	assert.True(t, ok, "port block sNAT rules should not be checked")
+
+	// port block within the ports of the shared sNAT rules
+	snatPortBlocks["10.244.0.6"] = SNATPortBlock{IP: "10.0.0.6", PortStart: 64512, PortEnd: 65535}
+	assert.NotNil(t, nf.EnsureGatewaySNAT(ctx, "wg-6000", 6000, []string{"10.0.0.6"}, nil, snatPortBlocks))
+}
+
+func TestIPTablesTCPMSSClamping(t *testing.T) {
+	ctx := context.Background()
+	fipt := fakeiptables.NewFake()
+	fipt.AddBuiltinTargets("TCPMSS")
+	_, err := fipt.EnsureChain(utiliptables.TableMangle, utiliptables.ChainForward)
+	assert.Nil(t, err)
+	nf := NewIPTables(fipt)
+
+	assert.Nil(t, nf.EnsureTCPMSSClamping(ctx, "wg-6000", 6000))
+	assert.Nil(t, nf.EnsureTCPMSSClamping(ctx, "wg-6000", 6000))
+	buf := bytes.NewBuffer(nil)
+	assert.Nil(t, fipt.SaveInto(utiliptables.TableMangle, buf))
+	assert.Equal(t, `*mangle
+:FORWARD - [0:0]
+:EGRESS-GATEWAY-MSS-6000 - [0:0]
+-A FORWARD -m comment --comment kube-egress-gateway clamp TCP MSS of gateway link wg-6000 -j EGRESS-GATEWAY-MSS-6000
+-A EGRESS-GATEWAY-MSS-6000 -i wg-6000 -p tcp -m tcp --tcp-flags SYN,RST SYN -j TCPMSS --clamp-mss-to-pmtu
+-A EGRESS-GATEWAY-MSS-6000 -o wg-6000 -p tcp -m tcp --tcp-flags SYN,RST SYN -j TCPMSS --clamp-mss-to-pmtu
+COMMIT
+`, buf.String())
+
+	assert.Nil(t, nf.DeleteTCPMSSClamping(ctx, "wg-6000", 6000))
+	assert.Nil(t, nf.DeleteTCPMSSClamping(ctx, "wg-6000", 6000))
+	buf.Reset()
+	assert.Nil(t, fipt.SaveInto(utiliptables.TableMangle, buf))
+	assert.Equal(t, "*mangle\n:FORWARD - [0:0]\nCOMMIT\n", buf.String())
+}
+
+func TestIPTablesCheckIngressConnMark(t *testing.T) {
+	ctx := context.Background()
+	fipt := fakeiptables.NewFake()
+	for _, chain := range []utiliptables.Chain{utiliptables.ChainPrerouting, utiliptables.ChainOutput} {
+		_, err := fipt.EnsureChain(utiliptables.TableMangle, chain)
+		assert.Nil(t, err)
+	}
+	nf := NewIPTables(fipt)
+
+	ok, err := nf.CheckIngressConnMark(ctx, "eth0", 8738)
+	assert.Nil(t, err)
+	assert.False(t, ok, "rules should be missing before ensured")
+
+	assert.Nil(t, nf.EnsureIngressConnMark(ctx, "eth0", 8738))
+	ok, err = nf.CheckIngressConnMark(ctx, "eth0", 8738)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	ok, err = nf.CheckIngressConnMark(ctx, "eth1", 8738)
+	assert.Nil(t, err)
+	assert.False(t, ok, "interface should match")
+
+	// iptables-save prints marks in hex with a mask
+	fipt = fakeiptables.NewFake()
+	assert.Nil(t, fipt.RestoreAll([]byte(`*mangle
+:PREROUTING - [0:0]
+:OUTPUT - [0:0]
+-A PREROUTING -i eth0 -j MARK --set-xmark 0x2222/0xffffffff
+-A PREROUTING -j CONNMARK --save-mark --nfmask 0xffffffff --ctmask 0xffffffff
+-A OUTPUT -m connmark --mark 0x2222 -j CONNMARK --restore-mark --nfmask 0xffffffff --ctmask 0xffffffff
+COMMIT
+`), utiliptables.NoFlushTables, utiliptables.NoRestoreCounters))
+	ok, err = NewIPTables(fipt).CheckIngressConnMark(ctx, "eth0", 8738)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+}
+
+func TestIPTablesDump(t *testing.T) {
+	ctx := context.Background()
+	fipt := fakeiptables.NewFake()
+	assert.Nil(t, fipt.RestoreAll([]byte(`*nat
+:PREROUTING - [0:0]
+:POSTROUTING - [0:0]
+:KUBE-POSTROUTING - [0:0]
+-A POSTROUTING -m comment --comment "kubernetes postrouting rules" -j KUBE-POSTROUTING
+-A KUBE-POSTROUTING -j MASQUERADE
+COMMIT
+*mangle
+:PREROUTING - [0:0]
+:OUTPUT - [0:0]
+COMMIT
+`), utiliptables.NoFlushTables, utiliptables.NoRestoreCounters))
+	nf := NewIPTables(fipt)
+	assert.Nil(t, nf.EnsureGatewaySNAT(ctx, "wg-6000", 6000, []string{"10.0.0.6"}, nil, nil))
+	assert.Nil(t, nf.EnsureIngressConnMark(ctx, "eth0", 8738))
+
+	dump, err := nf.Dump(ctx)
+	assert.Nil(t, err)
+	assert.Contains(t, dump, "*nat\n")
+	assert.Contains(t, dump, ":EGRESS-GATEWAY-SNAT-6000")
+	assert.Contains(t, dump, "-A EGRESS-GATEWAY-SNAT-6000")
+	assert.Contains(t, dump, "-j EGRESS-GATEWAY-MARK-6000")
+	assert.Contains(t, dump, "*mangle\n")
+	assert.Contains(t, dump, "-A PREROUTING -i eth0 -j MARK --set-mark 8738")
+	assert.NotContains(t, dump, "KUBE-POSTROUTING", "rules of others should be filtered out")
+}
@@ -0,0 +1,171 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+package netfilter
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	utiliptables "k8s.io/kubernetes/pkg/util/iptables"
+	"sigs.k8s.io/knftables"
+
+	"github.com/Azure/kube-egress-gateway/pkg/consts"
+)
+
+// Backend is the packet filtering framework used to program kube-egress-gateway rules.
+type Backend string
+
+const (
+	// BackendAuto detects the backend from the host.
+	BackendAuto Backend = "auto"
+	// BackendIPTables programs rules with iptables(-restore).
+	BackendIPTables Backend = "iptables"
+	// BackendNFTables programs rules in a dedicated nftables table.
+	BackendNFTables Backend = "nftables"
+)
+
+const (
+	// SNATPortRangeStart and SNATPortRangeEnd bound the sNAT source ports given out in port blocks,
+	// well-known ports are left out
+	SNATPortRangeStart = 1024
+	SNATPortRangeEnd   = 65535
+)
+
+// SNATPortBlock is a range of source ports of a sNAT IP.
+type SNATPortBlock struct {
+	IP        string
+	PortStart int
+	PortEnd   int
+}
+
+// Interface programs the netfilter rules owned by kube-egress-gateway in the
+// network namespace of the calling thread.
+type Interface interface {
+	// Backend returns the backend programming the rules.
+	Backend() Backend
+	// EnsureNoSNAT ensures packets sourced from ip are not masqueraded when leaving the host.
+	EnsureNoSNAT(ctx context.Context, ip string) error
+	// DeleteNoSNAT removes the rule added by EnsureNoSNAT for ip.
+	DeleteNoSNAT(ctx context.Context, ip string) error
+	// CleanupNoSNAT removes all rules added by EnsureNoSNAT.
+	CleanupNoSNAT(ctx context.Context) error
+	// EnsureGatewaySNAT marks connections coming from the gateway link and sNATs them to one of
+	// snatIPs, with fully randomized source ports, when they leave the gateway namespace.
+	// Connections from the source IPs in dedicatedSNATIPs are sNATed to the mapped IP instead, and
+	// connections from the source IPs in snatPortBlocks to a source port within the mapped block. With
+	// port blocks, which must be of the same size, the other connections only get the source ports from
+	// SharedSNATPortRangeStart of the block size.
+	EnsureGatewaySNAT(ctx context.Context, linkName string, mark int, snatIPs []string, dedicatedSNATIPs map[string]string, snatPortBlocks map[string]SNATPortBlock) error
+	// DeleteGatewaySNAT removes the rules added by EnsureGatewaySNAT for the gateway link.
+	DeleteGatewaySNAT(ctx context.Context, linkName string, mark int) error
+	// CheckGatewaySNAT returns whether the rules added by EnsureGatewaySNAT are in place, the rules
+	// of dedicatedSNATIPs and snatPortBlocks are not checked.
+	CheckGatewaySNAT(ctx context.Context, linkName string, mark int, snatIPs []string) (bool, error)
+	// EnsureTCPMSSClamping clamps the MSS of TCP connections going in and out of the gateway link
+	// to the path MTU, so that endpoints blocking ICMP do not send segments too large for the link.
+	// The rules match linkName only, mark merely identifies the gateway to backends that keep rules
+	// per gateway, others may ignore it.
+	EnsureTCPMSSClamping(ctx context.Context, linkName string, mark int) error
+	// DeleteTCPMSSClamping removes the rules added by EnsureTCPMSSClamping for the gateway link.
+	DeleteTCPMSSClamping(ctx context.Context, linkName string, mark int) error
+	// EnsureIngressConnMark marks connections coming in from ifName so that reply
+	// packets carry the same mark and can be routed back through ifName.
+	EnsureIngressConnMark(ctx context.Context, ifName string, mark int) error
+	// CheckIngressConnMark returns whether the rules added by EnsureIngressConnMark are in place.
+	CheckIngressConnMark(ctx context.Context, ifName string, mark int) (bool, error)
+	// Monitor blocks until ctx is done and calls onChange whenever the rules owned by
+	// kube-egress-gateway may have been flushed by someone else. Changes are polled every interval.
+	Monitor(ctx context.Context, interval time.Duration, onChange func())
+	// Dump returns the rules owned by kube-egress-gateway in the backend's own syntax, for diagnostics.
+	Dump(ctx context.Context) (string, error)
+}
+
+var (
+	// ipTablesNamesFile lists the legacy iptables tables loaded in the kernel
+	ipTablesNamesFile = "/proc/net/ip_tables_names"
+
+	// newNFTables creates the knftables interface for the kube-egress-gateway table
+	newNFTables = func() (knftables.Interface, error) {
+		return knftables.New(knftables.IPv4Family, consts.NFTablesTableName)
+	}
+)
+
+// ParseBackend validates a backend name, empty value means BackendAuto.
+func ParseBackend(name string) (Backend, error) {
+	switch backend := Backend(strings.ToLower(name)); backend {
+	case "", BackendAuto:
+		return BackendAuto, nil
+	case BackendIPTables, BackendNFTables:
+		return backend, nil
+	default:
+		return "", fmt.Errorf("unknown firewall backend %q, must be one of %q, %q or %q", name, BackendAuto, BackendIPTables, BackendNFTables)
+	}
+}
+
+// DetectBackend resolves BackendAuto to a concrete backend, other values are returned as is.
+// iptables is used if the host already has legacy iptables tables loaded or nft is not
+// available, otherwise nftables is used.
+// This must be called from the host network namespace.
+func DetectBackend(backend Backend) Backend {
+	if backend != BackendAuto {
+		return backend
+	}
+	if data, err := os.ReadFile(ipTablesNamesFile); err == nil && len(strings.TrimSpace(string(data))) > 0 {
+		return BackendIPTables
+	}
+	if _, err := newNFTables(); err != nil {
+		return BackendIPTables
+	}
+	return BackendNFTables
+}
+
+// New creates an Interface for the given backend, BackendAuto is detected from the host.
+func New(backend Backend) (Interface, error) {
+	switch DetectBackend(backend) {
+	case BackendIPTables:
+		return NewIPTables(utiliptables.New(utiliptables.ProtocolIPv4)), nil
+	case BackendNFTables:
+		nft, err := newNFTables()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create nftables interface: %w", err)
+		}
+		return NewNFTables(nft), nil
+	default:
+		return nil, fmt.Errorf("unknown firewall backend %q", backend)
+	}
+}
+
+// SharedSNATPortRangeStart returns the first source port of the shared sNAT rules of a gateway with port blocks of
+// blockSize. Port blocks are laid out from SNATPortRangeStart, the shared sNAT rules get the last block slot of each
+// sNAT IP and the ports left above it, so that the range does not move as blocks are given out.
+func SharedSNATPortRangeStart(blockSize int) int {
+	return SNATPortRangeStart + ((SNATPortRangeEnd-SNATPortRangeStart+1)/blockSize-1)*blockSize
+}
+
+// sharedSNATPortRange returns the source port range of the shared sNAT rules of a gateway with port blocks, empty
+// when there are no port blocks. Port blocks must be of the same size and below the range, so that connections
+// without a block never get a source port of a block.
+func sharedSNATPortRange(snatPortBlocks map[string]SNATPortBlock) (string, error) {
+	blockSize := 0
+	for _, block := range snatPortBlocks {
+		size := block.PortEnd - block.PortStart + 1
+		if size <= 0 || (blockSize != 0 && size != blockSize) {
+			return "", fmt.Errorf("sNAT port blocks must be non-empty and of the same size")
+		}
+		blockSize = size
+	}
+	if blockSize == 0 {
+		return "", nil
+	}
+	portStart := SharedSNATPortRangeStart(blockSize)
+	for _, block := range snatPortBlocks {
+		if block.PortStart < SNATPortRangeStart || block.PortEnd >= portStart {
+			return "", fmt.Errorf("sNAT port block %s:%d-%d is out of %d-%d, the ports below the shared sNAT rules",
+				block.IP, block.PortStart, block.PortEnd, SNATPortRangeStart, portStart-1)
+		}
+	}
+	return fmt.Sprintf("%d-%d", portStart, SNATPortRangeEnd), nil
+}
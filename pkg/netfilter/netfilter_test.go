@@ -0,0 +1,70 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+package netfilter
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/knftables"
+
+	"github.com/Azure/kube-egress-gateway/pkg/consts"
+)
+
+func TestParseBackend(t *testing.T) {
+	tests := map[string]struct {
+		backend  Backend
+		hasError bool
+	}{
+		"":         {backend: BackendAuto},
+		"auto":     {backend: BackendAuto},
+		"iptables": {backend: BackendIPTables},
+		"NFTables": {backend: BackendNFTables},
+		"ebpf":     {hasError: true},
+	}
+	for name, test := range tests {
+		backend, err := ParseBackend(name)
+		if test.hasError {
+			assert.NotNil(t, err, "ParseBackend(%q) should return error", name)
+			continue
+		}
+		assert.Nil(t, err, "ParseBackend(%q) should not return error", name)
+		assert.Equal(t, test.backend, backend, "ParseBackend(%q) returns unexpected backend", name)
+	}
+}
+
+func TestDetectBackend(t *testing.T) {
+	origNamesFile, origNewNFTables := ipTablesNamesFile, newNFTables
+	defer func() {
+		ipTablesNamesFile, newNFTables = origNamesFile, origNewNFTables
+	}()
+	nftAvailable := true
+	newNFTables = func() (knftables.Interface, error) {
+		if !nftAvailable {
+			return nil, errors.New("nft not found")
+		}
+		return knftables.NewFake(knftables.IPv4Family, consts.NFTablesTableName), nil
+	}
+	ipTablesNamesFile = filepath.Join(t.TempDir(), "ip_tables_names")
+
+	// explicit backend is not changed
+	assert.Equal(t, BackendIPTables, DetectBackend(BackendIPTables))
+	assert.Equal(t, BackendNFTables, DetectBackend(BackendNFTables))
+
+	// no legacy iptables tables loaded
+	assert.Equal(t, BackendNFTables, DetectBackend(BackendAuto))
+	assert.Nil(t, os.WriteFile(ipTablesNamesFile, []byte(""), 0644))
+	assert.Equal(t, BackendNFTables, DetectBackend(BackendAuto))
+
+	// nft is not available
+	nftAvailable = false
+	assert.Equal(t, BackendIPTables, DetectBackend(BackendAuto))
+
+	// legacy iptables tables are loaded
+	nftAvailable = true
+	assert.Nil(t, os.WriteFile(ipTablesNamesFile, []byte("nat\nfilter\n"), 0644))
+	assert.Equal(t, BackendIPTables, DetectBackend(BackendAuto))
+}
@@ -0,0 +1,590 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+package netfilter
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/knftables"
+
+	"github.com/Azure/kube-egress-gateway/pkg/consts"
+)
+
+const (
+	noSNATIPsMap        = "no-snat-ips"
+	gatewayMarksMap     = "gateway-marks"
+	gatewaySNATIPsSet   = "gateway-snat-addrs"
+	gatewayDedicatedMap = "gateway-dedicated-snat-ips"
+	gatewayPortBlockSet = "gateway-snat-port-blocks"
+	ingressMarksMap     = "ingress-marks"
+	mssClampingLinksSet = "mss-clamping-links"
+
+	// map of a single sNAT IP per connection mark written by earlier versions, read on upgrade
+	legacyGatewaySNATIPsMap = "gateway-snat-ips"
+
+	natPreroutingChain    = "nat-prerouting"
+	natPostroutingChain   = "nat-postrouting"
+	manglePreroutingChain = "mangle-prerouting"
+	mangleOutputChain     = "mangle-output"
+	mangleForwardChain    = "mangle-forward"
+)
+
+// nftRuleset is the content of the kube-egress-gateway table in one network namespace.
+// All state is kept in maps, so that the table can be read back and rebuilt from scratch.
+type nftRuleset struct {
+	// source IPs that must not be masqueraded
+	noSNATIPs map[string]bool
+	// gateway link name -> connection mark
+	gatewayMarks map[string]int
+	// connection mark -> sNAT IPs
+	gatewaySNATIPs map[int][]string
+	// connection mark -> source IP -> dedicated sNAT IP
+	gatewayDedicatedSNATIPs map[int]map[string]string
+	// connection mark -> source IP -> sNAT port block
+	gatewaySNATPortBlocks map[int]map[string]SNATPortBlock
+	// ingress interface name -> connection mark
+	ingressMarks map[string]int
+	// gateway link names to clamp TCP MSS of
+	mssClampingLinks map[string]bool
+}
+
+type nfTables struct {
+	nft knftables.Interface
+	// serializes read-modify-replace of the table
+	lock sync.Mutex
+}
+
+var _ Interface = &nfTables{}
+
+// NewNFTables returns an Interface programming rules in the dedicated kube-egress-gateway nftables table.
+// Every change reads the table, applies the change and replaces the whole table in a single transaction.
+func NewNFTables(nft knftables.Interface) Interface {
+	return &nfTables{nft: nft}
+}
+
+func (*nfTables) Backend() Backend {
+	return BackendNFTables
+}
+
+func (n *nfTables) EnsureNoSNAT(ctx context.Context, ip string) error {
+	return n.update(ctx, func(rs *nftRuleset) {
+		rs.noSNATIPs[ip] = true
+	})
+}
+
+func (n *nfTables) DeleteNoSNAT(ctx context.Context, ip string) error {
+	return n.update(ctx, func(rs *nftRuleset) {
+		delete(rs.noSNATIPs, ip)
+	})
+}
+
+func (n *nfTables) CleanupNoSNAT(ctx context.Context) error {
+	return n.update(ctx, func(rs *nftRuleset) {
+		rs.noSNATIPs = map[string]bool{}
+	})
+}
+
+func (n *nfTables) EnsureGatewaySNAT(ctx context.Context, linkName string, mark int, snatIPs []string, dedicatedSNATIPs map[string]string, snatPortBlocks map[string]SNATPortBlock) error {
+	if _, err := sharedSNATPortRange(snatPortBlocks); err != nil {
+		return err
+	}
+	return n.update(ctx, func(rs *nftRuleset) {
+		rs.gatewayMarks[linkName] = mark
+		rs.gatewaySNATIPs[mark] = sortedIPs(snatIPs)
+		delete(rs.gatewayDedicatedSNATIPs, mark)
+		if len(dedicatedSNATIPs) > 0 {
+			rs.gatewayDedicatedSNATIPs[mark] = dedicatedSNATIPs
+		}
+		delete(rs.gatewaySNATPortBlocks, mark)
+		if len(snatPortBlocks) > 0 {
+			rs.gatewaySNATPortBlocks[mark] = snatPortBlocks
+		}
+	})
+}
+
+func (n *nfTables) DeleteGatewaySNAT(ctx context.Context, linkName string, mark int) error {
+	return n.update(ctx, func(rs *nftRuleset) {
+		delete(rs.gatewayMarks, linkName)
+		delete(rs.gatewaySNATIPs, mark)
+		delete(rs.gatewayDedicatedSNATIPs, mark)
+		delete(rs.gatewaySNATPortBlocks, mark)
+	})
+}
+
+func (n *nfTables) CheckGatewaySNAT(ctx context.Context, linkName string, mark int, snatIPs []string) (bool, error) {
+	rs, err := n.read(ctx)
+	if err != nil {
+		return false, err
+	}
+	linkMark, ok := rs.gatewayMarks[linkName]
+	return ok && linkMark == mark && slices.Equal(rs.gatewaySNATIPs[mark], sortedIPs(snatIPs)), nil
+}
+
+// EnsureTCPMSSClamping adds linkName to a set matched by shared rules, so mark is not needed to tell gateways apart.
+func (n *nfTables) EnsureTCPMSSClamping(ctx context.Context, linkName string, _ int) error {
+	return n.update(ctx, func(rs *nftRuleset) {
+		rs.mssClampingLinks[linkName] = true
+	})
+}
+
+func (n *nfTables) DeleteTCPMSSClamping(ctx context.Context, linkName string, _ int) error {
+	return n.update(ctx, func(rs *nftRuleset) {
+		delete(rs.mssClampingLinks, linkName)
+	})
+}
+
+func (n *nfTables) EnsureIngressConnMark(ctx context.Context, ifName string, mark int) error {
+	return n.update(ctx, func(rs *nftRuleset) {
+		rs.ingressMarks[ifName] = mark
+	})
+}
+
+func (n *nfTables) CheckIngressConnMark(ctx context.Context, ifName string, mark int) (bool, error) {
+	rs, err := n.read(ctx)
+	if err != nil {
+		return false, err
+	}
+	ifMark, ok := rs.ingressMarks[ifName]
+	return ok && ifMark == mark, nil
+}
+
+func (n *nfTables) Monitor(ctx context.Context, interval time.Duration, onChange func()) {
+	log := log.FromContext(ctx)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	existed := false
+	for {
+		exists, err := n.tableExists(ctx)
+		if err != nil {
+			log.Error(err, "failed to check nftables table", "table", consts.NFTablesTableName)
+		} else {
+			// the table is deleted only when it has no element left, so a table that
+			// disappears has most likely been flushed by someone else
+			if existed && !exists {
+				log.Info("nftables table is gone", "table", consts.NFTablesTableName)
+				onChange()
+			}
+			existed = exists
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Dump returns the kube-egress-gateway table as nft commands. The table is rebuilt from its maps and
+// sets, which hold all the state, so rules changed by someone else are not shown.
+func (n *nfTables) Dump(ctx context.Context) (string, error) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	exists, err := n.tableExists(ctx)
+	if err != nil || !exists {
+		return "", err
+	}
+	rs, err := n.read(ctx)
+	if err != nil {
+		return "", err
+	}
+	return n.buildTransaction(rs).String(), nil
+}
+
+func (n *nfTables) tableExists(ctx context.Context) (bool, error) {
+	if _, err := n.nft.List(ctx, "chains"); err != nil {
+		if knftables.IsNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to list chains of nftables table %s: %w", consts.NFTablesTableName, err)
+	}
+	return true, nil
+}
+
+func (n *nfTables) update(ctx context.Context, change func(*nftRuleset)) error {
+	log := log.FromContext(ctx)
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	rs, err := n.read(ctx)
+	if err != nil {
+		return err
+	}
+	change(rs)
+
+	tx := n.buildTransaction(rs)
+	log.V(5).Info("Replacing nftables table", "table", consts.NFTablesTableName, "transaction", tx.String())
+	if err := n.nft.Run(ctx, tx); err != nil {
+		return fmt.Errorf("failed to replace nftables table %s: %w", consts.NFTablesTableName, err)
+	}
+	return nil
+}
+
+// read loads the current ruleset from the kernel, a missing table or map is treated as empty.
+func (n *nfTables) read(ctx context.Context) (*nftRuleset, error) {
+	rs := &nftRuleset{
+		noSNATIPs:               map[string]bool{},
+		gatewayMarks:            map[string]int{},
+		gatewaySNATIPs:          map[int][]string{},
+		gatewayDedicatedSNATIPs: map[int]map[string]string{},
+		gatewaySNATPortBlocks:   map[int]map[string]SNATPortBlock{},
+		ingressMarks:            map[string]int{},
+		mssClampingLinks:        map[string]bool{},
+	}
+
+	elements, err := n.listMapElements(ctx, noSNATIPsMap, 1)
+	if err != nil {
+		return nil, err
+	}
+	for _, element := range elements {
+		rs.noSNATIPs[unquote(element.Key[0])] = true
+	}
+
+	elements, err = n.listMapElements(ctx, gatewayMarksMap, 1)
+	if err != nil {
+		return nil, err
+	}
+	for _, element := range elements {
+		mark, err := parseMark(element.Value[0])
+		if err != nil {
+			return nil, err
+		}
+		rs.gatewayMarks[unquote(element.Key[0])] = mark
+	}
+
+	elements, err = n.listMapElements(ctx, legacyGatewaySNATIPsMap, 1)
+	if err != nil {
+		return nil, err
+	}
+	for _, element := range elements {
+		mark, err := parseMark(element.Key[0])
+		if err != nil {
+			return nil, err
+		}
+		rs.gatewaySNATIPs[mark] = []string{unquote(element.Value[0])}
+	}
+
+	elements, err = n.listSetElements(ctx, gatewaySNATIPsSet)
+	if err != nil {
+		return nil, err
+	}
+	for _, element := range elements {
+		if len(element.Key) != 2 {
+			return nil, fmt.Errorf("unexpected element in nftables set %s: key %v", gatewaySNATIPsSet, element.Key)
+		}
+		mark, err := parseMark(element.Key[0])
+		if err != nil {
+			return nil, err
+		}
+		rs.gatewaySNATIPs[mark] = sortedIPs(append(rs.gatewaySNATIPs[mark], unquote(element.Key[1])))
+	}
+
+	elements, err = n.listMapElements(ctx, gatewayDedicatedMap, 2)
+	if err != nil {
+		return nil, err
+	}
+	for _, element := range elements {
+		mark, err := parseMark(element.Key[0])
+		if err != nil {
+			return nil, err
+		}
+		if rs.gatewayDedicatedSNATIPs[mark] == nil {
+			rs.gatewayDedicatedSNATIPs[mark] = map[string]string{}
+		}
+		rs.gatewayDedicatedSNATIPs[mark][unquote(element.Key[1])] = unquote(element.Value[0])
+	}
+
+	elements, err = n.listSetElements(ctx, gatewayPortBlockSet)
+	if err != nil {
+		return nil, err
+	}
+	for _, element := range elements {
+		if len(element.Key) != 5 {
+			return nil, fmt.Errorf("unexpected element in nftables set %s: key %v", gatewayPortBlockSet, element.Key)
+		}
+		mark, err := parseMark(element.Key[0])
+		if err != nil {
+			return nil, err
+		}
+		portStart, err := strconv.Atoi(unquote(element.Key[3]))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse port %q: %w", element.Key[3], err)
+		}
+		portEnd, err := strconv.Atoi(unquote(element.Key[4]))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse port %q: %w", element.Key[4], err)
+		}
+		if rs.gatewaySNATPortBlocks[mark] == nil {
+			rs.gatewaySNATPortBlocks[mark] = map[string]SNATPortBlock{}
+		}
+		rs.gatewaySNATPortBlocks[mark][unquote(element.Key[1])] = SNATPortBlock{IP: unquote(element.Key[2]), PortStart: portStart, PortEnd: portEnd}
+	}
+
+	elements, err = n.listMapElements(ctx, ingressMarksMap, 1)
+	if err != nil {
+		return nil, err
+	}
+	for _, element := range elements {
+		mark, err := parseMark(element.Value[0])
+		if err != nil {
+			return nil, err
+		}
+		rs.ingressMarks[unquote(element.Key[0])] = mark
+	}
+
+	elements, err = n.listSetElements(ctx, mssClampingLinksSet)
+	if err != nil {
+		return nil, err
+	}
+	for _, element := range elements {
+		if len(element.Key) != 1 {
+			return nil, fmt.Errorf("unexpected element in nftables set %s: key %v", mssClampingLinksSet, element.Key)
+		}
+		rs.mssClampingLinks[unquote(element.Key[0])] = true
+	}
+	return rs, nil
+}
+
+func (n *nfTables) listMapElements(ctx context.Context, name string, keyLen int) ([]*knftables.Element, error) {
+	elements, err := n.nft.ListElements(ctx, "map", name)
+	if err != nil {
+		if knftables.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to list elements of nftables map %s: %w", name, err)
+	}
+	for _, element := range elements {
+		if len(element.Key) != keyLen || len(element.Value) != 1 {
+			return nil, fmt.Errorf("unexpected element in nftables map %s: key %v, value %v", name, element.Key, element.Value)
+		}
+	}
+	return elements, nil
+}
+
+func (n *nfTables) listSetElements(ctx context.Context, name string) ([]*knftables.Element, error) {
+	elements, err := n.nft.ListElements(ctx, "set", name)
+	if err != nil {
+		if knftables.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to list elements of nftables set %s: %w", name, err)
+	}
+	return elements, nil
+}
+
+// buildTransaction deletes the table and recreates it with the given ruleset, so the
+// whole table is replaced atomically. The table is left deleted if the ruleset is empty.
+func (n *nfTables) buildTransaction(rs *nftRuleset) *knftables.Transaction {
+	tx := n.nft.NewTransaction()
+	// "add" before "delete" so that deleting a non-existent table does not fail
+	tx.Add(&knftables.Table{})
+	tx.Delete(&knftables.Table{})
+	if len(rs.noSNATIPs) == 0 && len(rs.gatewayMarks) == 0 && len(rs.gatewaySNATIPs) == 0 && len(rs.gatewayDedicatedSNATIPs) == 0 &&
+		len(rs.gatewaySNATPortBlocks) == 0 && len(rs.ingressMarks) == 0 && len(rs.mssClampingLinks) == 0 {
+		return tx
+	}
+
+	tx.Add(&knftables.Table{
+		Comment: knftables.PtrTo("rules for kube-egress-gateway"),
+	})
+
+	var natPrerouting, natPostrouting, manglePrerouting, mangleOutput, mangleForward []string
+
+	if len(rs.noSNATIPs) > 0 {
+		tx.Add(&knftables.Map{
+			Name:    noSNATIPsMap,
+			Type:    "ipv4_addr : ipv4_addr",
+			Comment: knftables.PtrTo("source IPs that must not be masqueraded"),
+		})
+		for _, ip := range sortedKeys(rs.noSNATIPs) {
+			tx.Add(&knftables.Element{Map: noSNATIPsMap, Key: []string{ip}, Value: []string{ip}})
+		}
+		// packets from gateway namespace are already sNATed, sNAT them to their own source IP
+		// so that masquerade rules in other tables, evaluated later, do not apply
+		natPostrouting = append(natPostrouting, knftables.Concat("snat to ip saddr map", "@", noSNATIPsMap))
+	}
+
+	if len(rs.gatewayMarks) > 0 {
+		tx.Add(&knftables.Map{
+			Name:    gatewayMarksMap,
+			Type:    "ifname : mark",
+			Comment: knftables.PtrTo("connection marks of gateway links"),
+		})
+		for _, link := range sortedKeys(rs.gatewayMarks) {
+			tx.Add(&knftables.Element{Map: gatewayMarksMap, Key: []string{strconv.Quote(link)}, Value: []string{strconv.Itoa(rs.gatewayMarks[link])}})
+		}
+		natPrerouting = append(natPrerouting, knftables.Concat("ct mark set iifname map", "@", gatewayMarksMap))
+	}
+
+	if len(rs.gatewayDedicatedSNATIPs) > 0 {
+		tx.Add(&knftables.Map{
+			Name:    gatewayDedicatedMap,
+			Type:    "mark . ipv4_addr : ipv4_addr",
+			Comment: knftables.PtrTo("dedicated sNAT IPs of source IPs of gateway connection marks"),
+		})
+		for _, mark := range sortedKeys(rs.gatewayDedicatedSNATIPs) {
+			for _, sourceIP := range sortedKeys(rs.gatewayDedicatedSNATIPs[mark]) {
+				tx.Add(&knftables.Element{
+					Map:   gatewayDedicatedMap,
+					Key:   []string{strconv.Itoa(mark), sourceIP},
+					Value: []string{rs.gatewayDedicatedSNATIPs[mark][sourceIP]},
+				})
+			}
+		}
+		// connections from source IPs not in the map fail the lookup and fall through to the rules below
+		natPostrouting = append(natPostrouting, knftables.Concat(
+			"oifname", strconv.Quote(consts.HostLinkName), "snat to ct mark . ip saddr map", "@", gatewayDedicatedMap, "fully-random"))
+	}
+
+	if len(rs.gatewaySNATPortBlocks) > 0 {
+		// the set only keeps the state, each source IP is sNATed to its port block by its own rule
+		tx.Add(&knftables.Set{
+			Name:    gatewayPortBlockSet,
+			Type:    "mark . ipv4_addr . ipv4_addr . inet_service . inet_service",
+			Comment: knftables.PtrTo("sNAT port blocks of source IPs of gateway connection marks"),
+		})
+		for _, mark := range sortedKeys(rs.gatewaySNATPortBlocks) {
+			for _, sourceIP := range sortedKeys(rs.gatewaySNATPortBlocks[mark]) {
+				block := rs.gatewaySNATPortBlocks[mark][sourceIP]
+				tx.Add(&knftables.Element{
+					Set: gatewayPortBlockSet,
+					Key: []string{strconv.Itoa(mark), sourceIP, block.IP, strconv.Itoa(block.PortStart), strconv.Itoa(block.PortEnd)},
+				})
+				natPostrouting = append(natPostrouting, knftables.Concat(
+					"oifname", strconv.Quote(consts.HostLinkName), "ct mark", mark, "ip saddr", sourceIP,
+					"snat to", fmt.Sprintf("%s:%d-%d", block.IP, block.PortStart, block.PortEnd), "fully-random"))
+			}
+		}
+	}
+
+	if len(rs.gatewaySNATIPs) > 0 {
+		// the set only keeps the state, the sNAT IPs of a gateway are spread over by its own rule
+		tx.Add(&knftables.Set{
+			Name:    gatewaySNATIPsSet,
+			Type:    "mark . ipv4_addr",
+			Comment: knftables.PtrTo("sNAT IPs of gateway connection marks"),
+		})
+		for _, mark := range sortedKeys(rs.gatewaySNATIPs) {
+			for _, ip := range rs.gatewaySNATIPs[mark] {
+				tx.Add(&knftables.Element{Set: gatewaySNATIPsSet, Key: []string{strconv.Itoa(mark), ip}})
+			}
+			// port blocks are checked by EnsureGatewaySNAT
+			portRange, _ := sharedSNATPortRange(rs.gatewaySNATPortBlocks[mark])
+			natPostrouting = append(natPostrouting, gatewaySNATRule(mark, rs.gatewaySNATIPs[mark], portRange))
+		}
+	}
+
+	if len(rs.ingressMarks) > 0 {
+		tx.Add(&knftables.Map{
+			Name:    ingressMarksMap,
+			Type:    "ifname : mark",
+			Comment: knftables.PtrTo("marks of ingress interfaces"),
+		})
+		marks := []string{}
+		for _, ifName := range sortedKeys(rs.ingressMarks) {
+			mark := strconv.Itoa(rs.ingressMarks[ifName])
+			tx.Add(&knftables.Element{Map: ingressMarksMap, Key: []string{strconv.Quote(ifName)}, Value: []string{mark}})
+			marks = append(marks, mark)
+		}
+		manglePrerouting = append(manglePrerouting,
+			knftables.Concat("meta mark set iifname map", "@", ingressMarksMap),
+			"ct mark set meta mark",
+		)
+		mangleOutput = append(mangleOutput, knftables.Concat("ct mark", "{", strings.Join(marks, ", "), "}", "meta mark set ct mark"))
+	}
+
+	if len(rs.mssClampingLinks) > 0 {
+		tx.Add(&knftables.Set{
+			Name:    mssClampingLinksSet,
+			Type:    "ifname",
+			Comment: knftables.PtrTo("gateway links to clamp TCP MSS of"),
+		})
+		for _, link := range sortedKeys(rs.mssClampingLinks) {
+			tx.Add(&knftables.Element{Set: mssClampingLinksSet, Key: []string{strconv.Quote(link)}})
+		}
+		mangleForward = append(mangleForward,
+			knftables.Concat("iifname", "@", mssClampingLinksSet, "tcp flags syn / syn,rst tcp option maxseg size set rt mtu"),
+			knftables.Concat("oifname", "@", mssClampingLinksSet, "tcp flags syn / syn,rst tcp option maxseg size set rt mtu"),
+		)
+	}
+
+	addBaseChain(tx, natPreroutingChain, knftables.NATType, knftables.PreroutingHook, knftables.DNATPriority, natPrerouting)
+	// run before the srcnat priority used by other tables, see the no-sNAT rule above
+	addBaseChain(tx, natPostroutingChain, knftables.NATType, knftables.PostroutingHook, knftables.SNATPriority+"-10", natPostrouting)
+	addBaseChain(tx, manglePreroutingChain, knftables.FilterType, knftables.PreroutingHook, knftables.ManglePriority, manglePrerouting)
+	addBaseChain(tx, mangleOutputChain, knftables.RouteType, knftables.OutputHook, knftables.ManglePriority, mangleOutput)
+	addBaseChain(tx, mangleForwardChain, knftables.FilterType, knftables.ForwardHook, knftables.ManglePriority, mangleForward)
+	return tx
+}
+
+// gatewaySNATRule sNATs connections with the mark to the sNAT IPs in turn. Only the first packet of a
+// connection traverses the nat chains, so numgen picks the sNAT IP once per connection. Source ports
+// are limited to portRange when it is not empty.
+func gatewaySNATRule(mark int, snatIPs []string, portRange string) string {
+	target := snatIPs[0]
+	if len(snatIPs) > 1 {
+		ipMap := make([]string, 0, len(snatIPs))
+		for idx, ip := range snatIPs {
+			ipMap = append(ipMap, fmt.Sprintf("%d : %s", idx, ip))
+		}
+		target = knftables.Concat("numgen inc mod", len(snatIPs), "map {", strings.Join(ipMap, ", "), "}")
+		if portRange != "" {
+			target = knftables.Concat(target, ":", portRange)
+		}
+	} else if portRange != "" {
+		target = target + ":" + portRange
+	}
+	return knftables.Concat("oifname", strconv.Quote(consts.HostLinkName), "ct mark", mark, "snat to", target, "fully-random")
+}
+
+func addBaseChain(tx *knftables.Transaction, name string, chainType knftables.BaseChainType, hook knftables.BaseChainHook, priority knftables.BaseChainPriority, rules []string) {
+	if len(rules) == 0 {
+		return
+	}
+	tx.Add(&knftables.Chain{
+		Name:     name,
+		Type:     knftables.PtrTo(chainType),
+		Hook:     knftables.PtrTo(hook),
+		Priority: knftables.PtrTo(priority),
+	})
+	for _, rule := range rules {
+		tx.Add(&knftables.Rule{Chain: name, Rule: rule})
+	}
+}
+
+func parseMark(value string) (int, error) {
+	// nft may print marks in hex
+	mark, err := strconv.ParseInt(unquote(value), 0, 64)
+	if err != nil {
+		return -1, fmt.Errorf("failed to parse mark %q: %w", value, err)
+	}
+	return int(mark), nil
+}
+
+func unquote(value string) string {
+	return strings.Trim(value, `"`)
+}
+
+// sortedIPs returns a sorted copy of ips, so that the sNAT IPs read back from the set compare equal.
+func sortedIPs(ips []string) []string {
+	sorted := slices.Clone(ips)
+	slices.Sort(sorted)
+	return sorted
+}
+
+func sortedKeys[K int | string, V any](m map[K]V) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
@@ -0,0 +1,298 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+package netfilter
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/knftables"
+
+	"github.com/Azure/kube-egress-gateway/pkg/consts"
+)
+
+func TestNFTablesNoSNAT(t *testing.T) {
+	ctx := context.Background()
+	fake := knftables.NewFake(knftables.IPv4Family, consts.NFTablesTableName)
+	nf := NewNFTables(fake)
+	assert.Equal(t, BackendNFTables, nf.Backend())
+
+	assert.Nil(t, nf.EnsureNoSNAT(ctx, "10.0.0.6"))
+	assert.Nil(t, nf.EnsureNoSNAT(ctx, "10.0.0.7"))
+	// ensure is idempotent
+	assert.Nil(t, nf.EnsureNoSNAT(ctx, "10.0.0.6"))
+	dump := fake.Dump()
+	assert.Contains(t, dump, "add chain ip kube-egress-gateway nat-postrouting { type nat hook postrouting priority 90 ; }")
+	assert.Contains(t, dump, "add rule ip kube-egress-gateway nat-postrouting snat to ip saddr map @no-snat-ips")
+	assert.Contains(t, dump, "add element ip kube-egress-gateway no-snat-ips { 10.0.0.6 : 10.0.0.6 }")
+	assert.Contains(t, dump, "add element ip kube-egress-gateway no-snat-ips { 10.0.0.7 : 10.0.0.7 }")
+	assert.Equal(t, 1, strings.Count(dump, "add rule"), "rules should not be duplicated")
+
+	assert.Nil(t, nf.DeleteNoSNAT(ctx, "10.0.0.7"))
+	dump = fake.Dump()
+	assert.Contains(t, dump, "10.0.0.6 : 10.0.0.6")
+	assert.NotContains(t, dump, "10.0.0.7")
+
+	// deleting a non-existing rule is no-op
+	assert.Nil(t, nf.DeleteNoSNAT(ctx, "10.0.0.8"))
+
+	assert.Nil(t, nf.CleanupNoSNAT(ctx))
+	assert.Empty(t, fake.Dump(), "table should be deleted when there's no rule left")
+
+	// cleanup when table does not exist
+	assert.Nil(t, nf.CleanupNoSNAT(ctx))
+}
+
+func TestNFTablesGatewaySNAT(t *testing.T) {
+	ctx := context.Background()
+	fake := knftables.NewFake(knftables.IPv4Family, consts.NFTablesTableName)
+	nf := NewNFTables(fake)
+
+	assert.Nil(t, nf.EnsureGatewaySNAT(ctx, "wg-6000", 6000, []string{"10.0.0.6"}, nil, nil))
+	assert.Nil(t, nf.EnsureGatewaySNAT(ctx, "wg-6001", 6001, []string{"10.0.0.7"}, nil, nil))
+	// sNAT IP update
+	assert.Nil(t, nf.EnsureGatewaySNAT(ctx, "wg-6000", 6000, []string{"10.0.0.8"}, nil, nil))
+	dump := fake.Dump()
+	assert.Contains(t, dump, "add rule ip kube-egress-gateway nat-prerouting ct mark set iifname map @gateway-marks")
+	assert.Contains(t, dump, `add rule ip kube-egress-gateway nat-postrouting oifname "host0" ct mark 6000 snat to 10.0.0.8 fully-random`)
+	assert.Contains(t, dump, `add rule ip kube-egress-gateway nat-postrouting oifname "host0" ct mark 6001 snat to 10.0.0.7 fully-random`)
+	assert.Contains(t, dump, `add element ip kube-egress-gateway gateway-marks { "wg-6000" : 6000 }`)
+	assert.Contains(t, dump, `add element ip kube-egress-gateway gateway-marks { "wg-6001" : 6001 }`)
+	assert.Contains(t, dump, "add element ip kube-egress-gateway gateway-snat-addrs { 6000 . 10.0.0.8 }")
+	assert.Contains(t, dump, "add element ip kube-egress-gateway gateway-snat-addrs { 6001 . 10.0.0.7 }")
+	assert.NotContains(t, dump, "10.0.0.6")
+	assert.NotContains(t, dump, "no-snat-ips")
+
+	ok, err := nf.CheckGatewaySNAT(ctx, "wg-6000", 6000, []string{"10.0.0.8"})
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	ok, err = nf.CheckGatewaySNAT(ctx, "wg-6000", 6000, []string{"10.0.0.6"})
+	assert.Nil(t, err)
+	assert.False(t, ok, "sNAT IP should match")
+	ok, err = nf.CheckGatewaySNAT(ctx, "wg-6002", 6002, []string{"10.0.0.8"})
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	assert.Nil(t, nf.DeleteGatewaySNAT(ctx, "wg-6001", 6001))
+	dump = fake.Dump()
+	assert.NotContains(t, dump, "wg-6001")
+	assert.NotContains(t, dump, "6001 .")
+
+	assert.Nil(t, nf.DeleteGatewaySNAT(ctx, "wg-6000", 6000))
+	assert.Empty(t, fake.Dump())
+}
+
+func TestNFTablesGatewaySNATMultipleIPs(t *testing.T) {
+	ctx := context.Background()
+	fake := knftables.NewFake(knftables.IPv4Family, consts.NFTablesTableName)
+	nf := NewNFTables(fake)
+
+	assert.Nil(t, nf.EnsureGatewaySNAT(ctx, "wg-6000", 6000, []string{"10.0.0.8", "10.0.0.6", "10.0.0.7"}, nil, nil))
+	// rebuilding the table from the set keeps the rule
+	assert.Nil(t, nf.EnsureNoSNAT(ctx, "10.0.0.6"))
+	dump := fake.Dump()
+	assert.Contains(t, dump, `add rule ip kube-egress-gateway nat-postrouting oifname "host0" ct mark 6000 snat to numgen inc mod 3 map { 0 : 10.0.0.6, 1 : 10.0.0.7, 2 : 10.0.0.8 } fully-random`)
+	assert.Equal(t, 3, strings.Count(dump, "add element ip kube-egress-gateway gateway-snat-addrs"))
+
+	ok, err := nf.CheckGatewaySNAT(ctx, "wg-6000", 6000, []string{"10.0.0.7", "10.0.0.8", "10.0.0.6"})
+	assert.Nil(t, err)
+	assert.True(t, ok, "order of sNAT IPs should not matter")
+	ok, err = nf.CheckGatewaySNAT(ctx, "wg-6000", 6000, []string{"10.0.0.6", "10.0.0.7"})
+	assert.Nil(t, err)
+	assert.False(t, ok, "all sNAT IPs should match")
+
+	// decrease sNAT IPs
+	assert.Nil(t, nf.EnsureGatewaySNAT(ctx, "wg-6000", 6000, []string{"10.0.0.6"}, nil, nil))
+	dump = fake.Dump()
+	assert.Contains(t, dump, `ct mark 6000 snat to 10.0.0.6 fully-random`)
+	assert.NotContains(t, dump, "numgen")
+	assert.NotContains(t, dump, "10.0.0.8")
+}
+
+func TestNFTablesGatewayDedicatedSNAT(t *testing.T) {
+	ctx := context.Background()
+	fake := knftables.NewFake(knftables.IPv4Family, consts.NFTablesTableName)
+	nf := NewNFTables(fake)
+
+	assert.Nil(t, nf.EnsureGatewaySNAT(ctx, "wg-6000", 6000, []string{"10.0.0.6"}, map[string]string{"10.244.0.5": "10.0.0.9"}, nil))
+	assert.Nil(t, nf.EnsureGatewaySNAT(ctx, "wg-6001", 6001, []string{"10.0.0.7"}, map[string]string{"10.244.0.5": "10.0.0.10"}, nil))
+	// rebuilding the table from the map keeps the dedicated sNAT IPs
+	assert.Nil(t, nf.EnsureNoSNAT(ctx, "10.0.0.6"))
+	dump := fake.Dump()
+	assert.Contains(t, dump, "add element ip kube-egress-gateway gateway-dedicated-snat-ips { 6000 . 10.244.0.5 : 10.0.0.9 }")
+	assert.Contains(t, dump, "add element ip kube-egress-gateway gateway-dedicated-snat-ips { 6001 . 10.244.0.5 : 10.0.0.10 }")
+	dedicatedRule := `add rule ip kube-egress-gateway nat-postrouting oifname "host0" snat to ct mark . ip saddr map @gateway-dedicated-snat-ips fully-random`
+	assert.Contains(t, dump, dedicatedRule)
+	assert.Less(t, strings.Index(dump, dedicatedRule), strings.Index(dump, "ct mark 6000 snat to 10.0.0.6"), "dedicated sNAT rule should go first")
+
+	ok, err := nf.CheckGatewaySNAT(ctx, "wg-6000", 6000, []string{"10.0.0.6"})
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	assert.Nil(t, nf.EnsureGatewaySNAT(ctx, "wg-6000", 6000, []string{"10.0.0.6"}, nil, nil))
+	assert.NotContains(t, fake.Dump(), "6000 . 10.244.0.5")
+	assert.Nil(t, nf.DeleteGatewaySNAT(ctx, "wg-6001", 6001))
+	assert.NotContains(t, fake.Dump(), "gateway-dedicated-snat-ips")
+}
+
+func TestNFTablesGatewaySNATPortBlocks(t *testing.T) {
+	ctx := context.Background()
+	fake := knftables.NewFake(knftables.IPv4Family, consts.NFTablesTableName)
+	nf := NewNFTables(fake)
+
+	snatPortBlocks := map[string]SNATPortBlock{
+		"10.244.0.4": {IP: "10.0.0.6", PortStart: 1024, PortEnd: 2047},
+		"10.244.0.5": {IP: "10.0.0.6", PortStart: 2048, PortEnd: 3071},
+	}
+	assert.Nil(t, nf.EnsureGatewaySNAT(ctx, "wg-6000", 6000, []string{"10.0.0.6"}, nil, snatPortBlocks))
+	// rebuilding the table from the set keeps the port blocks
+	assert.Nil(t, nf.EnsureNoSNAT(ctx, "10.0.0.6"))
+	dump := fake.Dump()
+	assert.Contains(t, dump, "add element ip kube-egress-gateway gateway-snat-port-blocks { 6000 . 10.244.0.4 . 10.0.0.6 . 1024 . 2047 }")
+	portBlockRule := `add rule ip kube-egress-gateway nat-postrouting oifname "host0" ct mark 6000 ip saddr 10.244.0.5 snat to 10.0.0.6:2048-3071 fully-random`
+	assert.Contains(t, dump, portBlockRule)
+	assert.Less(t, strings.Index(dump, portBlockRule), strings.Index(dump, "ct mark 6000 snat to 10.0.0.6"), "port block sNAT rules should go first")
+	assert.Contains(t, dump, `oifname "host0" ct mark 6000 snat to 10.0.0.6:64512-65535 fully-random`,
+		"shared sNAT rule should only use the last block slot")
+
+	// shared sNAT rule spreading connections over several sNAT IPs
+	assert.Nil(t, nf.EnsureGatewaySNAT(ctx, "wg-6000", 6000, []string{"10.0.0.6", "10.0.0.7"}, nil, snatPortBlocks))
+	assert.Contains(t, fake.Dump(), `ct mark 6000 snat to numgen inc mod 2 map { 0 : 10.0.0.6, 1 : 10.0.0.7 } : 64512-65535 fully-random`)
+	assert.Nil(t, nf.EnsureGatewaySNAT(ctx, "wg-6000", 6000, []string{"10.0.0.6"}, nil, snatPortBlocks))
+
+	ok, err := nf.CheckGatewaySNAT(ctx, "wg-6000", 6000, []string{"10.0.0.6"})
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	assert.Nil(t, nf.EnsureGatewaySNAT(ctx, "wg-6000", 6000, []string{"10.0.0.6"}, nil, nil))
+	dump = fake.Dump()
+	assert.NotContains(t, dump, "gateway-snat-port-blocks")
+	assert.Contains(t, dump, `ct mark 6000 snat to 10.0.0.6 fully-random`)
+
+	// port blocks of different sizes
+	snatPortBlocks["10.244.0.6"] = SNATPortBlock{IP: "10.0.0.6", PortStart: 3072, PortEnd: 3583}
+	assert.NotNil(t, nf.EnsureGatewaySNAT(ctx, "wg-6000", 6000, []string{"10.0.0.6"}, nil, snatPortBlocks))
+	// port block within the ports of the shared sNAT rules
+	snatPortBlocks["10.244.0.6"] = SNATPortBlock{IP: "10.0.0.6", PortStart: 64512, PortEnd: 65535}
+	assert.NotNil(t, nf.EnsureGatewaySNAT(ctx, "wg-6000", 6000, []string{"10.0.0.6"}, nil, snatPortBlocks))
+}
+
+func TestNFTablesIngressConnMark(t *testing.T) {
+	ctx := context.Background()
+	fake := knftables.NewFake(knftables.IPv4Family, consts.NFTablesTableName)
+	nf := NewNFTables(fake)
+
+	assert.Nil(t, nf.EnsureIngressConnMark(ctx, "eth0", consts.Eth0Mark))
+	assert.Nil(t, nf.EnsureIngressConnMark(ctx, "eth0", consts.Eth0Mark))
+	dump := fake.Dump()
+	assert.Contains(t, dump, "add chain ip kube-egress-gateway mangle-prerouting { type filter hook prerouting priority -150 ; }")
+	assert.Contains(t, dump, "add chain ip kube-egress-gateway mangle-output { type route hook output priority -150 ; }")
+	assert.Contains(t, dump, `add element ip kube-egress-gateway ingress-marks { "eth0" : 8738 }`)
+	assert.Contains(t, dump, "add rule ip kube-egress-gateway mangle-prerouting meta mark set iifname map @ingress-marks")
+	assert.Contains(t, dump, "add rule ip kube-egress-gateway mangle-prerouting ct mark set meta mark")
+	assert.Contains(t, dump, "add rule ip kube-egress-gateway mangle-output ct mark { 8738 } meta mark set ct mark")
+	assert.Equal(t, 3, strings.Count(dump, "add rule"), "rules should not be duplicated")
+
+	ok, err := nf.CheckIngressConnMark(ctx, "eth0", consts.Eth0Mark)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	ok, err = nf.CheckIngressConnMark(ctx, "eth1", consts.Eth0Mark)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+}
+
+func TestNFTablesReadHexMark(t *testing.T) {
+	ctx := context.Background()
+	fake := knftables.NewFake(knftables.IPv4Family, consts.NFTablesTableName)
+	// nft prints marks in hex, the legacy sNAT IP map is migrated to the set
+	tx := fake.NewTransaction()
+	tx.Add(&knftables.Table{})
+	tx.Add(&knftables.Map{Name: gatewayMarksMap, Type: "ifname : mark"})
+	tx.Add(&knftables.Map{Name: legacyGatewaySNATIPsMap, Type: "mark : ipv4_addr"})
+	tx.Add(&knftables.Element{Map: gatewayMarksMap, Key: []string{`"wg-6000"`}, Value: []string{"0x00001770"}})
+	tx.Add(&knftables.Element{Map: legacyGatewaySNATIPsMap, Key: []string{"0x00001770"}, Value: []string{"10.0.0.6"}})
+	assert.Nil(t, fake.Run(ctx, tx))
+	nf := NewNFTables(fake)
+	assert.Nil(t, nf.EnsureNoSNAT(ctx, "10.0.0.6"))
+	dump := fake.Dump()
+	assert.Contains(t, dump, `add element ip kube-egress-gateway gateway-marks { "wg-6000" : 6000 }`)
+	assert.Contains(t, dump, "add element ip kube-egress-gateway gateway-snat-addrs { 6000 . 10.0.0.6 }")
+	assert.NotContains(t, dump, "gateway-snat-ips")
+}
+
+func TestNFTablesMonitor(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	fake := knftables.NewFake(knftables.IPv4Family, consts.NFTablesTableName)
+	nf := NewNFTables(fake)
+	var changes atomic.Int32
+	done := make(chan struct{})
+	go func() {
+		nf.Monitor(ctx, 10*time.Millisecond, func() { changes.Add(1) })
+		close(done)
+	}()
+
+	// a table that never existed is not a change
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int32(0), changes.Load())
+
+	assert.Nil(t, nf.EnsureNoSNAT(ctx, "10.0.0.6"))
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int32(0), changes.Load())
+
+	// someone else flushes the ruleset
+	tx := fake.NewTransaction()
+	tx.Delete(&knftables.Table{})
+	assert.Nil(t, fake.Run(ctx, tx))
+	assert.Eventually(t, func() bool { return changes.Load() == 1 }, time.Second, 10*time.Millisecond)
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Monitor should return when context is done")
+	}
+}
+
+func TestNFTablesTCPMSSClamping(t *testing.T) {
+	ctx := context.Background()
+	fake := knftables.NewFake(knftables.IPv4Family, consts.NFTablesTableName)
+	nf := NewNFTables(fake)
+
+	assert.Nil(t, nf.EnsureTCPMSSClamping(ctx, "wg-6000", 6000))
+	assert.Nil(t, nf.EnsureTCPMSSClamping(ctx, "wg-6001", 6001))
+	assert.Nil(t, nf.EnsureTCPMSSClamping(ctx, "wg-6001", 6001))
+	dump := fake.Dump()
+	assert.Contains(t, dump, "add chain ip kube-egress-gateway mangle-forward { type filter hook forward priority -150 ; }")
+	assert.Contains(t, dump, `add element ip kube-egress-gateway mss-clamping-links { "wg-6000" }`)
+	assert.Contains(t, dump, `add element ip kube-egress-gateway mss-clamping-links { "wg-6001" }`)
+	assert.Contains(t, dump, "add rule ip kube-egress-gateway mangle-forward iifname @mss-clamping-links tcp flags syn / syn,rst tcp option maxseg size set rt mtu")
+	assert.Contains(t, dump, "add rule ip kube-egress-gateway mangle-forward oifname @mss-clamping-links tcp flags syn / syn,rst tcp option maxseg size set rt mtu")
+	assert.Equal(t, 2, strings.Count(dump, "add rule"), "rules should not be duplicated")
+
+	assert.Nil(t, nf.DeleteTCPMSSClamping(ctx, "wg-6000", 6000))
+	assert.NotContains(t, fake.Dump(), "wg-6000")
+	assert.Nil(t, nf.DeleteTCPMSSClamping(ctx, "wg-6001", 6001))
+	assert.Empty(t, fake.Dump())
+}
+
+func TestNFTablesDump(t *testing.T) {
+	ctx := context.Background()
+	fake := knftables.NewFake(knftables.IPv4Family, consts.NFTablesTableName)
+	nf := NewNFTables(fake)
+
+	dump, err := nf.Dump(ctx)
+	assert.Nil(t, err)
+	assert.Empty(t, dump, "missing table should be dumped as empty")
+
+	assert.Nil(t, nf.EnsureGatewaySNAT(ctx, "wg-6000", 6000, []string{"10.0.0.6"}, nil, nil))
+	dump, err = nf.Dump(ctx)
+	assert.Nil(t, err)
+	assert.Contains(t, dump, `add element ip kube-egress-gateway gateway-marks { "wg-6000" : 6000 }`)
+	assert.Contains(t, dump, "add element ip kube-egress-gateway gateway-snat-addrs { 6000 . 10.0.0.6 }")
+	assert.Contains(t, dump, "add rule ip kube-egress-gateway nat-prerouting ct mark set iifname map @gateway-marks")
+}
@@ -91,6 +91,21 @@ func (mr *MockInterfaceMockRecorder) AddrReplace(link, addr interface{}) *gomock
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddrReplace", reflect.TypeOf((*MockInterface)(nil).AddrReplace), link, addr)
 }
 
+// ConntrackTableList mocks base method.
+func (m *MockInterface) ConntrackTableList(table netlink.ConntrackTableType, family netlink.InetFamily) ([]*netlink.ConntrackFlow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConntrackTableList", table, family)
+	ret0, _ := ret[0].([]*netlink.ConntrackFlow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ConntrackTableList indicates an expected call of ConntrackTableList.
+func (mr *MockInterfaceMockRecorder) ConntrackTableList(table, family interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConntrackTableList", reflect.TypeOf((*MockInterface)(nil).ConntrackTableList), table, family)
+}
+
 // LinkAdd mocks base method.
 func (m *MockInterface) LinkAdd(link netlink.Link) error {
 	m.ctrl.T.Helper()
@@ -177,6 +192,20 @@ func (mr *MockInterfaceMockRecorder) LinkSetDown(link interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LinkSetDown", reflect.TypeOf((*MockInterface)(nil).LinkSetDown), link)
 }
 
+// LinkSetMTU mocks base method.
+func (m *MockInterface) LinkSetMTU(link netlink.Link, mtu int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LinkSetMTU", link, mtu)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// LinkSetMTU indicates an expected call of LinkSetMTU.
+func (mr *MockInterfaceMockRecorder) LinkSetMTU(link, mtu interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LinkSetMTU", reflect.TypeOf((*MockInterface)(nil).LinkSetMTU), link, mtu)
+}
+
 // LinkSetName mocks base method.
 func (m *MockInterface) LinkSetName(link netlink.Link, name string) error {
 	m.ctrl.T.Helper()
@@ -248,6 +277,21 @@ func (mr *MockInterfaceMockRecorder) RouteList(link, family interface{}) *gomock
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RouteList", reflect.TypeOf((*MockInterface)(nil).RouteList), link, family)
 }
 
+// RouteListFiltered mocks base method.
+func (m *MockInterface) RouteListFiltered(family int, filter *netlink.Route, filterMask uint64) ([]netlink.Route, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RouteListFiltered", family, filter, filterMask)
+	ret0, _ := ret[0].([]netlink.Route)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RouteListFiltered indicates an expected call of RouteListFiltered.
+func (mr *MockInterfaceMockRecorder) RouteListFiltered(family, filter, filterMask interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RouteListFiltered", reflect.TypeOf((*MockInterface)(nil).RouteListFiltered), family, filter, filterMask)
+}
+
 // RouteReplace mocks base method.
 func (m *MockInterface) RouteReplace(route *netlink.Route) error {
 	m.ctrl.T.Helper()
@@ -275,3 +319,32 @@ func (mr *MockInterfaceMockRecorder) RuleAdd(rule interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RuleAdd", reflect.TypeOf((*MockInterface)(nil).RuleAdd), rule)
 }
+
+// RuleDel mocks base method.
+func (m *MockInterface) RuleDel(rule *netlink.Rule) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RuleDel", rule)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RuleDel indicates an expected call of RuleDel.
+func (mr *MockInterfaceMockRecorder) RuleDel(rule interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RuleDel", reflect.TypeOf((*MockInterface)(nil).RuleDel), rule)
+}
+
+// RuleList mocks base method.
+func (m *MockInterface) RuleList(family int) ([]netlink.Rule, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RuleList", family)
+	ret0, _ := ret[0].([]netlink.Rule)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RuleList indicates an expected call of RuleList.
+func (mr *MockInterfaceMockRecorder) RuleList(family interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RuleList", reflect.TypeOf((*MockInterface)(nil).RuleList), family)
+}
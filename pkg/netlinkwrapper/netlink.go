@@ -23,6 +23,8 @@ type Interface interface {
 	LinkSetName(link netlink.Link, name string) error
 	// LinkSetAlias sets the alias of the link device
 	LinkSetAlias(link netlink.Link, name string) error
+	// LinkSetMTU sets the mtu of the link device
+	LinkSetMTU(link netlink.Link, mtu int) error
 	// AddrList gets a list of IP addresses in the system
 	AddrList(link netlink.Link, family int) ([]netlink.Addr, error)
 	// AddrAdd adds an IP address to a link device
@@ -37,8 +39,16 @@ type Interface interface {
 	RouteDel(route *netlink.Route) error
 	// RouteList gets a list of routes in the system
 	RouteList(link netlink.Link, family int) ([]netlink.Route, error)
+	// RouteListFiltered gets a list of routes in the system matching the filter
+	RouteListFiltered(family int, filter *netlink.Route, filterMask uint64) ([]netlink.Route, error)
 	// RuleAdd adds a rule
 	RuleAdd(rule *netlink.Rule) error
+	// RuleDel deletes a rule
+	RuleDel(rule *netlink.Rule) error
+	// RuleList lists rules in the system
+	RuleList(family int) ([]netlink.Rule, error)
+	// ConntrackTableList lists the flows of a conntrack table
+	ConntrackTableList(table netlink.ConntrackTableType, family netlink.InetFamily) ([]*netlink.ConntrackFlow, error)
 }
 
 type nl struct{}
@@ -83,6 +93,10 @@ func (*nl) LinkSetAlias(link netlink.Link, name string) error {
 	return netlink.LinkSetAlias(link, name)
 }
 
+func (*nl) LinkSetMTU(link netlink.Link, mtu int) error {
+	return netlink.LinkSetMTU(link, mtu)
+}
+
 func (*nl) AddrList(link netlink.Link, family int) ([]netlink.Addr, error) {
 	return netlink.AddrList(link, family)
 }
@@ -111,6 +125,22 @@ func (*nl) RouteList(link netlink.Link, family int) ([]netlink.Route, error) {
 	return netlink.RouteList(link, family)
 }
 
+func (*nl) RouteListFiltered(family int, filter *netlink.Route, filterMask uint64) ([]netlink.Route, error) {
+	return netlink.RouteListFiltered(family, filter, filterMask)
+}
+
 func (*nl) RuleAdd(rule *netlink.Rule) error {
 	return netlink.RuleAdd(rule)
 }
+
+func (*nl) RuleDel(rule *netlink.Rule) error {
+	return netlink.RuleDel(rule)
+}
+
+func (*nl) RuleList(family int) ([]netlink.Rule, error) {
+	return netlink.RuleList(family)
+}
+
+func (*nl) ConntrackTableList(table netlink.ConntrackTableType, family netlink.InetFamily) ([]*netlink.ConntrackFlow, error) {
+	return netlink.ConntrackTableList(table, family)
+}
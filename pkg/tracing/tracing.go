@@ -0,0 +1,103 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// TracerName is the name of the tracer all kube-egress-gateway components create spans with
+	TracerName = "github.com/Azure/kube-egress-gateway"
+
+	// TraceParentAnnotationKey is the annotation the W3C traceparent of the pod attach is recorded in on the
+	// PodEndpoint, so that the gateway daemons programming the peer continue the trace
+	TraceParentAnnotationKey = "egressgateway.kubernetes.azure.com/traceparent"
+
+	// traceParentHeader is the W3C trace context header the annotation carries
+	traceParentHeader = "traceparent"
+
+	// tracesPath is the path OTLP/HTTP receivers accept traces on
+	tracesPath = "/v1/traces"
+)
+
+// Setup installs the global tracer provider exporting the spans of serviceName to the OTLP/HTTP endpoint, e.g.
+// http://otel-collector.monitoring:4318. The W3C trace context propagator is installed regardless, so that traces
+// started by other components are continued even if endpoint is empty and this component does not export. The
+// returned function flushes the pending spans and must be called before exit. TLS, headers and compression of the
+// exporter are configured with the standard OTEL_EXPORTER_OTLP_* environment variables.
+func Setup(serviceName, endpoint string) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	if endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+	if u, err := url.Parse(endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid tracing endpoint %q, expected http(s)://host:port", endpoint)
+	}
+	exporter, err := otlptracehttp.New(context.Background(), otlptracehttp.WithEndpointURL(strings.TrimSuffix(endpoint, "/")+tracesPath))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tracing exporter: %w", err)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", serviceName))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Tracer returns the tracer of kube-egress-gateway from the global tracer provider.
+func Tracer() trace.Tracer {
+	return otel.Tracer(TracerName)
+}
+
+// TraceID returns the ID of the trace in ctx, or an empty string if there is none.
+func TraceID(ctx context.Context) string {
+	spanContext := trace.SpanContextFromContext(ctx)
+	if !spanContext.HasTraceID() {
+		return ""
+	}
+	return spanContext.TraceID().String()
+}
+
+// EndSpan records err on span, if any, and ends it.
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// InjectAnnotations records the trace context of ctx in annotations, replacing the one of a previous trace. The
+// recorded trace context is removed if ctx is not traced.
+func InjectAnnotations(ctx context.Context, annotations map[string]string) {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	if traceParent := carrier.Get(traceParentHeader); traceParent != "" {
+		annotations[TraceParentAnnotationKey] = traceParent
+	} else {
+		delete(annotations, TraceParentAnnotationKey)
+	}
+}
+
+// ExtractAnnotations returns ctx with the remote trace context recorded in annotations by InjectAnnotations.
+func ExtractAnnotations(ctx context.Context, annotations map[string]string) context.Context {
+	traceParent, ok := annotations[TraceParentAnnotationKey]
+	if !ok {
+		return ctx
+	}
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier{traceParentHeader: traceParent})
+}
@@ -0,0 +1,71 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestSetupValidatesEndpoint(t *testing.T) {
+	for _, endpoint := range []string{"otel-collector:4318", "grpc://otel-collector:4317", "http://"} {
+		_, err := Setup("test", endpoint)
+		assert.Error(t, err, endpoint)
+	}
+	shutdown, err := Setup("test", "")
+	require.NoError(t, err)
+	assert.NoError(t, shutdown(context.Background()))
+}
+
+func TestAnnotationsPropagateTraceContext(t *testing.T) {
+	_, err := Setup("test", "")
+	require.NoError(t, err)
+	provider := sdktrace.NewTracerProvider()
+	ctx, span := provider.Tracer(TracerName).Start(context.Background(), "attach")
+	defer span.End()
+
+	annotations := map[string]string{}
+	InjectAnnotations(context.Background(), annotations)
+	assert.Empty(t, annotations)
+	assert.Empty(t, TraceID(context.Background()))
+
+	InjectAnnotations(ctx, annotations)
+	assert.Contains(t, annotations[TraceParentAnnotationKey], span.SpanContext().TraceID().String())
+
+	remote := trace.SpanContextFromContext(ExtractAnnotations(context.Background(), map[string]string{TraceParentAnnotationKey: annotations[TraceParentAnnotationKey]}))
+	assert.True(t, remote.IsRemote())
+	assert.Equal(t, span.SpanContext().TraceID(), remote.TraceID())
+	assert.Equal(t, span.SpanContext().SpanID(), remote.SpanID())
+	assert.Equal(t, span.SpanContext().TraceID().String(), TraceID(ctx))
+
+	assert.Equal(t, context.Background(), ExtractAnnotations(context.Background(), nil))
+
+	InjectAnnotations(context.Background(), annotations)
+	assert.Empty(t, annotations, "trace context of the previous trace should be removed")
+}
+
+func TestSetupExportsSpans(t *testing.T) {
+	var path, contentType string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		contentType = r.Header.Get("Content-Type")
+	}))
+	defer server.Close()
+
+	shutdown, err := Setup("test", server.URL+"/")
+	require.NoError(t, err)
+	_, span := Tracer().Start(context.Background(), "cni.add")
+	EndSpan(span, errors.New("failed"))
+	require.NoError(t, shutdown(context.Background()))
+
+	assert.Equal(t, tracesPath, path)
+	assert.Equal(t, "application/x-protobuf", contentType)
+}
@@ -83,6 +83,21 @@ func (mr *MockClientMockRecorder) Device(name interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Device", reflect.TypeOf((*MockClient)(nil).Device), name)
 }
 
+// Devices mocks base method.
+func (m *MockClient) Devices() ([]*wgtypes.Device, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Devices")
+	ret0, _ := ret[0].([]*wgtypes.Device)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Devices indicates an expected call of Devices.
+func (mr *MockClientMockRecorder) Devices() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Devices", reflect.TypeOf((*MockClient)(nil).Devices))
+}
+
 // MockInterface is a mock of Interface interface.
 type MockInterface struct {
 	ctrl     *gomock.Controller
@@ -10,6 +10,8 @@ import (
 type Client interface {
 	// Device retrieves a WireGuard device by its interface name
 	Device(name string) (*wgtypes.Device, error)
+	// Devices retrieves all WireGuard devices on this system
+	Devices() ([]*wgtypes.Device, error)
 	// ConfigureDevice configures a WireGuard device by its interface name
 	ConfigureDevice(name string, cfg wgtypes.Config) error
 	// Close releases resources used by a Client